@@ -0,0 +1,119 @@
+// Команда candles - загрузка свечей OHLCV с бирж в БД котировок.
+//
+// Использование:
+//
+//	candles backfill -exchange 1 -market FUTURES -symbol BTCUSDT -interval 1h -from 2024-01-01 [-to 2024-02-01]
+//	candles sync
+//
+// backfill загружает историю одного контракта за период (даты в UTC),
+// sync догружает часовые свечи по всем открытым позициям (как фоновая задача веб-приложения).
+package main
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/db"
+	"ctweb/internal/logger"
+	"ctweb/internal/services"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  candles backfill -exchange ID -market SPOT|FUTURES -symbol SYMBOL -interval 1m|5m|15m|1h|4h|1d -from DATE [-to DATE]")
+	fmt.Fprintln(os.Stderr, "  candles sync")
+}
+
+// parseDate разбирает дату в UTC: "2006-01-02" или "2006-01-02 15:04:05".
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	if _, err := config.Load(""); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Close()
+
+	db.Connect()
+	if err := db.ConnectQuotes(); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to connect to quotes database")
+	}
+	if db.QuotesEngine() == "" {
+		logger.Fatal().Msg("databases.quotes.engine is not set")
+	}
+	defer db.CloseQuotes()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	service := services.NewCandleService()
+	start := time.Now()
+
+	switch os.Args[1] {
+	case "backfill":
+		fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+		exchangeID := fs.Int("exchange", 0, "exchange ID (EXCHANGES.ID)")
+		market := fs.String("market", "FUTURES", "market type: SPOT or FUTURES")
+		symbol := fs.String("symbol", "", "contract symbol, e.g. BTCUSDT")
+		interval := fs.String("interval", "1h", "candle interval")
+		fromRaw := fs.String("from", "", "period start (UTC)")
+		toRaw := fs.String("to", "", "period end (UTC, default now)")
+		fs.Parse(os.Args[2:])
+
+		from, err := parseDate(*fromRaw)
+		if err != nil || from.IsZero() {
+			usage()
+			os.Exit(2)
+		}
+		to, err := parseDate(*toRaw)
+		if err != nil {
+			usage()
+			os.Exit(2)
+		}
+
+		count, err := service.Backfill(ctx, *exchangeID, *market, *symbol, *interval, from, to)
+		if err != nil {
+			logger.Fatal().Err(err).Int("saved", count).Msg("Candle backfill failed")
+		}
+		logger.Info().
+			Int("exchange_id", *exchangeID).
+			Str("symbol", *symbol).
+			Str("interval", *interval).
+			Int("saved", count).
+			Dur("duration", time.Since(start)).
+			Msg("Candle backfill completed")
+	case "sync":
+		count, err := service.SyncOpenPositions(ctx)
+		if err != nil {
+			logger.Fatal().Err(err).Int("saved", count).Msg("Candle sync failed")
+		}
+		logger.Info().Int("saved", count).Dur("duration", time.Since(start)).Msg("Candle sync completed")
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,107 @@
+// Команда keys - обслуживание шифрования API-ключей бирж (security.encryption).
+//
+// Использование:
+//
+//	keys generate                       новый мастер-ключ (base64) для security.encryption.keys
+//	keys encrypt [-dry-run]             зашифровать ключи, которые хранятся открытым текстом
+//	keys rotate [-reencrypt] [-dry-run] перевести все ключи на current_key_id
+//
+// Ротация мастер-ключа: добавить новый ключ в keys, сделать его current_key_id,
+// запустить rotate и после успешного завершения удалить старый ключ из конфига.
+package main
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/db"
+	"ctweb/internal/logger"
+	"ctweb/internal/secrets"
+	"ctweb/internal/services"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  keys generate")
+	fmt.Fprintln(os.Stderr, "  keys encrypt [-dry-run]")
+	fmt.Fprintln(os.Stderr, "  keys rotate [-reencrypt] [-dry-run]")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	if os.Args[1] == "generate" {
+		key, err := secrets.GenerateMasterKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only count accounts that would be updated")
+	reencrypt := fs.Bool("reencrypt", false, "rotate: also re-encrypt values with new data keys")
+	fs.Parse(os.Args[2:])
+
+	if _, err := config.Load(""); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if err := logger.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Close()
+
+	if err := secrets.Init(); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize encryption keys")
+	}
+	db.Connect()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	service := services.NewAccountKeyService()
+	var (
+		result services.KeyMigrationResult
+		err    error
+	)
+	switch os.Args[1] {
+	case "encrypt":
+		result, err = service.EncryptExisting(ctx, *dryRun)
+	case "rotate":
+		result, err = service.Rotate(ctx, *reencrypt, *dryRun)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	event := logger.Info()
+	if err != nil || result.Failed > 0 || result.Changed > 0 {
+		event = logger.Warn()
+	}
+	if err != nil {
+		event = event.Err(err)
+	}
+	event.
+		Str("command", os.Args[1]).
+		Bool("dry_run", *dryRun).
+		Int("total", result.Total).
+		Int("updated", result.Updated).
+		Int("changed_concurrently", result.Changed).
+		Int("failed", result.Failed).
+		Msg("Account key migration finished")
+
+	if err != nil || result.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"ctweb/internal/db"          // Подключение к базе данных
 	"ctweb/internal/logger"      // Система логирования
 	"ctweb/internal/middleware"  // Middleware (промежуточные обработчики)
+	"ctweb/internal/secrets"     // Шифрование API-ключей бирж
+	"ctweb/internal/services"    // Бизнес-логика и фоновые задачи
 	"ctweb/internal/session"     // Управление сессиями
 	"fmt"                        // Форматирование строк
 	"html/template"              // HTML шаблоны
@@ -75,6 +77,18 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// ============================================
+	// ШАГ 3.1: Инициализация ключей шифрования
+	// ============================================
+	// Мастер-ключи шифрования API-ключей бирж (security.encryption).
+	// Без них ключи хранятся открытым текстом.
+	if err := secrets.Init(); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize encryption keys")
+	}
+	if !secrets.Default().Enabled() {
+		logger.Warn().Msg("security.encryption is not configured, exchange API keys are stored in plaintext")
+	}
+
 	// ============================================
 	// ШАГ 4: Подключение к базе данных
 	// ============================================
@@ -85,6 +99,13 @@ func main() {
 	db.Connect()
 	logger.Info().Msg("Database connected successfully")
 
+	// БД котировок необязательна: без неё недоступны только графики и свечи.
+	if err := db.ConnectQuotes(); err != nil {
+		logger.Warn().Err(err).Msg("Quotes database is unavailable, candle storage disabled")
+	} else if engine := db.QuotesEngine(); engine != "" {
+		logger.Info().Str("engine", engine).Msg("Quotes database connected successfully")
+	}
+
 	// ============================================
 	// ШАГ 5: Настройка HTTP роутера (маршрутизатора)
 	// ============================================
@@ -147,6 +168,16 @@ func main() {
 	exchangeController := controllers.NewExchangeController()
 	exchangeAccountController := controllers.NewExchangeAccountController()
 	positionController := controllers.NewPositionController()
+	quoteController := controllers.NewQuoteController()
+	marketAnalysisController := controllers.NewMarketAnalysisController()
+	coinController := controllers.NewCoinController()
+	daemonController := controllers.NewDaemonController()
+	spreadMonitorController := controllers.NewSpreadMonitorController()
+	fundingArbitrageController := controllers.NewFundingArbitrageController()
+	alertController := controllers.NewAlertController()
+	profileController := controllers.NewProfileController()
+	reportController := controllers.NewReportController()
+	taxController := controllers.NewTaxController()
 
 	// ============================================
 	// ШАГ 8: Регистрация Auth Middleware
@@ -236,6 +267,13 @@ func main() {
 	exchanges.POST("/ajax_getid_exchange", exchangeController.AjaxGetExchangeByID)
 	exchanges.POST("/ajax_create_exchange", exchangeController.AjaxCreateExchange)
 	exchanges.POST("/ajax_edit_exchange", exchangeController.AjaxEditExchange)
+	exchanges.POST("/ajax_sync_instruments", exchangeController.AjaxSyncInstruments)
+	exchanges.POST("/ajax_get_fees", exchangeController.AjaxGetFees)
+	exchanges.POST("/ajax_save_fees", exchangeController.AjaxSaveFees)
+	exchanges.POST("/ajax_get_account_fees", exchangeController.AjaxGetAccountFees)
+	exchanges.POST("/ajax_save_account_fees", exchangeController.AjaxSaveAccountFees)
+	exchanges.POST("/ajax_import_funding", exchangeController.AjaxImportFunding)
+	exchanges.POST("/ajax_sync_funding", exchangeController.AjaxSyncFunding)
 
 	// ============================================
 	// ШАГ 9.4: Маршруты для управления аккаунтами бирж (требуют авторизации)
@@ -247,26 +285,119 @@ func main() {
 	exAccounts.POST("/ajax_getid_accounts", exchangeAccountController.AjaxGetAccountByID)
 	exAccounts.POST("/ajax_create_account", exchangeAccountController.AjaxCreateAccount)
 	exAccounts.POST("/ajax_edit_account", exchangeAccountController.AjaxEditAccount)
+	exAccounts.POST("/ajax_test_connection", exchangeAccountController.AjaxTestConnection)
+	exAccounts.POST("/ajax_snapshot_balances", exchangeAccountController.AjaxSnapshotBalances)
+	exAccounts.POST("/ajax_get_balances", exchangeAccountController.AjaxGetBalances)
+	exAccounts.POST("/ajax_get_rollup", exchangeAccountController.AjaxGetRollup)
+	// Аккаунты всех пользователей (только администратор, ключи маскируются)
+	exAccounts.GET("/admin/", exchangeAccountController.AdminList)
+	exAccounts.POST("/ajax_get_all_accounts", exchangeAccountController.AjaxGetAllAccounts)
+	exAccounts.POST("/ajax_disable_accounts", exchangeAccountController.AjaxDisableAccounts)
 
 	positions := r.Group("/positions_calc")
 	positions.GET("/", positionController.List)
 	positions.POST("/ajax_get_positions.php", positionController.AjaxGetPositions)
+	positions.GET("/export.php", positionController.Export)
 	positions.POST("/ajax_create_position.php", positionController.AjaxCreatePosition)
 	positions.POST("/ajax_close_position.php", positionController.AjaxClosePosition)
 	positions.POST("/ajax_delete_position.php", positionController.AjaxDeletePosition)
+	positions.POST("/ajax_instruments.php", positionController.AjaxInstruments)
+	positions.POST("/ajax_reconcile.php", positionController.AjaxReconcile)
+	positions.POST("/ajax_get_reconciliation.php", positionController.AjaxGetReconciliation)
+	positions.POST("/ajax_get_groups.php", positionController.AjaxGetGroups)
+	positions.POST("/ajax_create_group.php", positionController.AjaxCreateGroup)
+	positions.POST("/ajax_close_group.php", positionController.AjaxCloseGroup)
+	positions.POST("/ajax_delete_group.php", positionController.AjaxDeleteGroup)
 
 	positionDetails := r.Group("/positions_calc/position")
 	positionDetails.GET("/", positionController.PositionPage)
 	positionDetails.POST("/ajax_get_position.php", positionController.AjaxGetPosition)
 	positionDetails.POST("/ajax_edit_position.php", positionController.AjaxEditPosition)
 	positionDetails.POST("/ajax_get_trans.php", positionController.AjaxGetTransactions)
+	positionDetails.GET("/export_trans.php", positionController.ExportTransactions)
 	positionDetails.POST("/ajax_create_trans.php", positionController.AjaxCreateTransaction)
+	positionDetails.POST("/ajax_get_fee.php", positionController.AjaxGetFee)
+	positionDetails.POST("/ajax_funding_forecast.php", positionController.AjaxFundingForecast)
+	positionDetails.POST("/ajax_funding_gaps.php", positionController.AjaxFundingGaps)
+	positionDetails.POST("/ajax_get_chart.php", positionController.AjaxGetChart)
 	positionDetails.POST("/ajax_edit_trans.php", positionController.AjaxEditTransaction)
 	positionDetails.POST("/ajax_upload_trans_csv.php", positionController.AjaxUploadTransactionCSV)
 	positionDetails.POST("/ajax_delete_trans.php", positionController.AjaxDeleteTransaction)
 	positionDetails.POST("/ajax_kucoin_price.php", positionController.AjaxKucoinPrice)
 	positionDetails.POST("/ajax_kucoin_token.php", positionController.AjaxKucoinToken)
 
+	quotes := r.Group("/quotes")
+	quotes.POST("/ajax_get_candles", quoteController.AjaxGetCandles)
+
+	marketAnalysis := r.Group("/market_analysis")
+	marketAnalysis.GET("/", marketAnalysisController.List)
+	marketAnalysis.GET("/direct_exs", marketAnalysisController.DirectExs)
+	marketAnalysis.POST("/ajax_exchanges_step_1.php", marketAnalysisController.AjaxExchangesStep1)
+	marketAnalysis.POST("/ajax_exchanges_step_2.php", marketAnalysisController.AjaxExchangesStep2)
+	marketAnalysis.POST("/ajax_exchanges_step_3.php", marketAnalysisController.AjaxExchangesStep3)
+	marketAnalysis.POST("/ajax_exchanges_step_4.php", marketAnalysisController.AjaxExchangesStep4)
+	marketAnalysis.POST("/ajax_direct_exs.php", marketAnalysisController.AjaxDirectExs)
+
+	coins := r.Group("/coins")
+	coins.GET("/", coinController.List)
+	coins.POST("/ajax_get_coins.php", coinController.AjaxGetCoins)
+	coins.POST("/ajax_update_coins.php", coinController.AjaxUpdateCoins)
+	coins.POST("/ajax_gen_icons.php", coinController.AjaxGenerateIcons)
+	coins.POST("/ajax_coin_info.php", coinController.AjaxCoinInfo)
+
+	spreadMonitor := r.Group("/spread_monitor")
+	spreadMonitor.GET("/", spreadMonitorController.List)
+	spreadMonitor.POST("/ajax_get_watches.php", spreadMonitorController.AjaxGetWatches)
+	spreadMonitor.POST("/ajax_create_watch.php", spreadMonitorController.AjaxCreateWatch)
+	spreadMonitor.POST("/ajax_edit_watch.php", spreadMonitorController.AjaxEditWatch)
+	spreadMonitor.POST("/ajax_delete_watch.php", spreadMonitorController.AjaxDeleteWatch)
+	spreadMonitor.POST("/ajax_get_spread_stats.php", spreadMonitorController.AjaxGetSpreadStats)
+
+	fundingArbitrage := r.Group("/funding_arbitrage")
+	fundingArbitrage.GET("/", fundingArbitrageController.List)
+	fundingArbitrage.POST("/ajax_funding_scan.php", fundingArbitrageController.AjaxScan)
+	fundingArbitrage.POST("/ajax_create_pair.php", fundingArbitrageController.AjaxCreatePair)
+
+	alerts := r.Group("/alerts")
+	alerts.GET("/", alertController.List)
+	alerts.POST("/ajax_get_rules.php", alertController.AjaxGetRules)
+	alerts.POST("/ajax_create_rule.php", alertController.AjaxCreateRule)
+	alerts.POST("/ajax_edit_rule.php", alertController.AjaxEditRule)
+	alerts.POST("/ajax_delete_rule.php", alertController.AjaxDeleteRule)
+	alerts.POST("/ajax_test_rule.php", alertController.AjaxTestRule)
+
+	profile := r.Group("/profile")
+	profile.GET("/", profileController.Show)
+	profile.POST("/ajax_get_telegram.php", profileController.AjaxGetTelegram)
+	profile.POST("/ajax_create_telegram_code.php", profileController.AjaxCreateTelegramCode)
+	profile.POST("/ajax_delete_telegram_link.php", profileController.AjaxDeleteTelegramLink)
+	profile.GET("/backup.php", profileController.Backup)
+	profile.POST("/ajax_import_backup.php", profileController.AjaxImportBackup)
+
+	reports := r.Group("/reports")
+	reports.GET("/", reportController.List)
+	reports.GET("/download.php", reportController.Download)
+	reports.POST("/ajax_get_reports.php", reportController.AjaxGetReports)
+	reports.POST("/ajax_save_subscription.php", reportController.AjaxSaveSubscription)
+	reports.POST("/ajax_create_report.php", reportController.AjaxCreateReport)
+	reports.POST("/ajax_send_report.php", reportController.AjaxSendReport)
+	reports.POST("/ajax_delete_report.php", reportController.AjaxDeleteReport)
+
+	tax := r.Group("/tax")
+	tax.GET("/", taxController.Index)
+	tax.GET("/export.php", taxController.Export)
+	tax.POST("/ajax_get_tax_summary.php", taxController.AjaxGetTaxSummary)
+	tax.POST("/ajax_get_rates.php", taxController.AjaxGetRates)
+	tax.POST("/ajax_import_rates.php", taxController.AjaxImportRates)
+	tax.POST("/ajax_delete_rates.php", taxController.AjaxDeleteRates)
+
+	daemon := r.Group("/daemon")
+	daemon.GET("/", daemonController.List)
+	daemon.POST("/ajax_check_status.php", daemonController.AjaxCheckStatus)
+	daemon.POST("/ajax_start.php", daemonController.AjaxStart)
+	daemon.POST("/ajax_stop.php", daemonController.AjaxStop)
+	daemon.POST("/ajax_daemon_stat.php", daemonController.AjaxDaemonStat)
+
 	// ============================================
 	// ШАГ 10: Настройка статических файлов и шаблонов
 	// ============================================
@@ -359,6 +490,56 @@ func main() {
 		}
 	}
 
+	// ============================================
+	// ШАГ 12: Фоновые задачи
+	// ============================================
+	// Задачи останавливаются через jobsCtx при graceful shutdown.
+	// Нулевой интервал в конфиге отключает задачу.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	instrumentService := services.NewInstrumentService()
+	services.RunPeriodic(jobsCtx, "instrument_sync", cfg.Jobs.InstrumentSyncInterval, func(ctx context.Context) error {
+		_, err := instrumentService.SyncAll(ctx)
+		return err
+	})
+
+	fundingService := services.NewFundingService()
+	services.RunPeriodic(jobsCtx, "funding_sync", cfg.Jobs.FundingSyncInterval, func(ctx context.Context) error {
+		_, err := fundingService.SyncOpenPositions(ctx)
+		return err
+	})
+
+	candleService := services.NewCandleService()
+	services.RunPeriodic(jobsCtx, "candle_sync", cfg.Jobs.CandleSyncInterval, func(ctx context.Context) error {
+		_, err := candleService.SyncOpenPositions(ctx)
+		return err
+	})
+
+	balanceService := services.NewBalanceService()
+	services.RunPeriodic(jobsCtx, "balance_snapshot", cfg.Jobs.BalanceSnapshotInterval, func(ctx context.Context) error {
+		_, err := balanceService.SnapshotAll(ctx)
+		return err
+	})
+
+	reconciliationService := services.NewReconciliationService()
+	services.RunPeriodic(jobsCtx, "reconcile", cfg.Jobs.ReconcileInterval, func(ctx context.Context) error {
+		_, err := reconciliationService.ReconcileAll(ctx)
+		return err
+	})
+
+	spreadMonitorService := services.NewSpreadMonitorService()
+	services.RunPeriodic(jobsCtx, "spread_sample", cfg.Jobs.SpreadSampleInterval, spreadMonitorService.Sample)
+
+	alertService := services.NewAlertService()
+	services.RunPeriodic(jobsCtx, "alert_evaluate", cfg.Jobs.AlertEvaluateInterval, alertService.Evaluate)
+
+	reportService := services.NewReportService()
+	services.RunPeriodic(jobsCtx, "pnl_reports", cfg.Jobs.ReportInterval, reportService.RunScheduled)
+
+	// Telegram-бот отвечает на команды, пока задан notify.telegram.bot_token.
+	go services.NewTelegramService().Run(jobsCtx)
+
 	go func() {
 		var serveErr error
 		if cfg.Server.TLS.Enabled {
@@ -380,6 +561,7 @@ func main() {
 	<-quit
 
 	logger.Info().Msg("Shutdown signal received")
+	stopJobs()
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.Timeouts.ShutdownGrace)
 	defer cancel()
@@ -5,8 +5,10 @@ package config
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -22,6 +24,9 @@ type Config struct {
 	Security  SecurityConfig  `mapstructure:"security"`   // Настройки безопасности
 	RateLimit RateLimitConfig `mapstructure:"rate_limit"` // Глобальные настройки rate limiting
 	Logging   LoggingConfig   `mapstructure:"logging"`    // Настройки логирования
+	Jobs      JobsConfig      `mapstructure:"jobs"`       // Фоновые задачи (синхронизация с биржами и т.д.)
+	Daemon    DaemonConfig    `mapstructure:"daemon"`     // Процесс-обработчик, которым управляет страница /daemon/
+	Notify    NotifyConfig    `mapstructure:"notify"`     // Доставка уведомлений по правилам /alerts/
 }
 
 // ProxyConfig - настройки работы web-ui за reverse proxy (nginx).
@@ -123,22 +128,55 @@ type OracleConfig struct {
 	Database string `mapstructure:"database"` // Имя базы данных (SID)
 }
 
-// ClickHouseConfig - задел для унифицированной схемы подключений.
+// ClickHouseConfig - подключение к ClickHouse через HTTP-интерфейс (порт 8123).
 type ClickHouseConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	Database string `mapstructure:"database"`
+	Host           string `mapstructure:"host"`
+	Port           int    `mapstructure:"port"`
+	User           string `mapstructure:"username"`
+	Password       string `mapstructure:"password"`
+	Database       string `mapstructure:"database"`
+	UseTLS         bool   `mapstructure:"use_tls"`         // https вместо http
+	ConnectTimeout int    `mapstructure:"connect_timeout"` // Таймаут запроса, секунды
+	MaxBatchSize   int    `mapstructure:"max_batch_size"`  // Максимум строк в одном INSERT
 }
 
 // SecurityConfig - настройки безопасности приложения
 type SecurityConfig struct {
-	CSRF           CSRFConfig    `mapstructure:"csrf"`             // Настройки CSRF middleware
-	Session        SessionConfig `mapstructure:"session"`          // Настройки сессий и session cookie
-	BcryptCost     int           `mapstructure:"bcrypt_cost"`      // Сложность хеширования паролей (10 = хороший баланс скорости/безопасности)
-	RateLimitLogin int           `mapstructure:"rate_limit_login"` // DEPRECATED: используйте rate_limit.login.requests_per_minute
-	RateLimitAPI   int           `mapstructure:"rate_limit_api"`   // DEPRECATED: используйте rate_limit.api.requests_per_second
+	CSRF           CSRFConfig       `mapstructure:"csrf"`             // Настройки CSRF middleware
+	Session        SessionConfig    `mapstructure:"session"`          // Настройки сессий и session cookie
+	BcryptCost     int              `mapstructure:"bcrypt_cost"`      // Сложность хеширования паролей (10 = хороший баланс скорости/безопасности)
+	RateLimitLogin int              `mapstructure:"rate_limit_login"` // DEPRECATED: используйте rate_limit.login.requests_per_minute
+	RateLimitAPI   int              `mapstructure:"rate_limit_api"`   // DEPRECATED: используйте rate_limit.api.requests_per_second
+	Encryption     EncryptionConfig `mapstructure:"encryption"`       // Шифрование API-ключей бирж в БД
+	// WithdrawKeyPolicy - что делать с ключом API, у которого есть право вывода средств:
+	// block (по умолчанию) - не сохранять и не активировать аккаунт, warn - сохранить с пометкой,
+	// off - не проверять права ключа при сохранении.
+	WithdrawKeyPolicy string `mapstructure:"withdraw_key_policy"`
+}
+
+// Значения security.withdraw_key_policy.
+const (
+	WithdrawKeyPolicyBlock = "block"
+	WithdrawKeyPolicyWarn  = "warn"
+	WithdrawKeyPolicyOff   = "off"
+)
+
+// EncryptionConfig - envelope-шифрование API-ключей бирж (AES-256-GCM).
+// Значения шифруются случайным ключом данных, который заворачивается мастер-ключом.
+// Пустой список keys - шифрование отключено, ключи хранятся как есть.
+type EncryptionConfig struct {
+	Provider     string            `mapstructure:"provider"`       // Хранилище мастер-ключей (сейчас только local)
+	CurrentKeyID string            `mapstructure:"current_key_id"` // Мастер-ключ для новых значений
+	Keys         []MasterKeyConfig `mapstructure:"keys"`           // Мастер-ключи; прежние оставляются до завершения ротации
+}
+
+// MasterKeyConfig - мастер-ключ local-провайдера: 32 байта в base64.
+// Источник задаётся ровно одним из полей key, file или env.
+type MasterKeyConfig struct {
+	ID   string `mapstructure:"id"`   // Идентификатор ключа, сохраняется вместе с зашифрованным значением
+	Key  string `mapstructure:"key"`  // Ключ прямо в конфиге (только для разработки)
+	File string `mapstructure:"file"` // Путь к файлу с ключом
+	Env  string `mapstructure:"env"`  // Имя переменной окружения с ключом
 }
 
 // CSRFConfig - настройки включения и политики CSRF middleware.
@@ -202,6 +240,83 @@ type LoggingConfig struct {
 	AuditMaxAgeDays    int    `mapstructure:"audit_max_age_days"`    // Хранить audit log N дней
 }
 
+// JobsConfig - интервалы фоновых задач веб-приложения.
+// Нулевой интервал отключает задачу (её можно запускать вручную из админки).
+type JobsConfig struct {
+	InstrumentSyncInterval  time.Duration `mapstructure:"instrument_sync_interval"`  // Синхронизация справочника инструментов
+	FundingSyncInterval     time.Duration `mapstructure:"funding_sync_interval"`     // Загрузка ставок финансирования по открытым позициям
+	CandleSyncInterval      time.Duration `mapstructure:"candle_sync_interval"`      // Догрузка свечей по открытым позициям в БД котировок
+	BalanceSnapshotInterval time.Duration `mapstructure:"balance_snapshot_interval"` // Снимки балансов активных аккаунтов бирж
+	ReconcileInterval       time.Duration `mapstructure:"reconcile_interval"`        // Сверка открытых позиций с позициями и балансами на бирже
+	SpreadSampleInterval    time.Duration `mapstructure:"spread_sample_interval"`    // Замеры спреда по наблюдениям /spread_monitor/ (не реже 10s)
+	AlertEvaluateInterval   time.Duration `mapstructure:"alert_evaluate_interval"`   // Проверка правил уведомлений /alerts/ (не реже 10s)
+	ReportInterval          time.Duration `mapstructure:"report_interval"`           // Генерация и рассылка PnL-отчётов по подпискам /reports/ (не реже 1m)
+}
+
+// Режимы управления процессом-обработчиком (daemon.mode).
+const (
+	DaemonModeExec = "exec" // веб-приложение само запускает команду и следит за PID-файлом
+	DaemonModeHTTP = "http" // процесс запущен отдельно и управляется через control endpoint
+)
+
+// DaemonConfig - процесс-обработчик, которым администратор управляет со страницы /daemon/.
+// Пустой mode отключает управление.
+type DaemonConfig struct {
+	Mode          string        `mapstructure:"mode"`           // exec, http или "" (отключено)
+	Command       string        `mapstructure:"command"`        // Исполняемый файл (mode: exec)
+	Args          []string      `mapstructure:"args"`           // Аргументы команды
+	WorkDir       string        `mapstructure:"work_dir"`       // Рабочий каталог процесса
+	PIDFile       string        `mapstructure:"pid_file"`       // PID запущенного процесса (переживает перезапуск веб-приложения)
+	LogFile       string        `mapstructure:"log_file"`       // Куда пишутся stdout/stderr процесса
+	HeartbeatFile string        `mapstructure:"heartbeat_file"` // Файл, который процесс периодически обновляет ("" - время последней записи в лог)
+	ControlURL    string        `mapstructure:"control_url"`    // http://127.0.0.1:9100 или unix:///run/ct/daemon.sock (mode: http)
+	StopTimeout   time.Duration `mapstructure:"stop_timeout"`   // Ожидание завершения после SIGTERM перед SIGKILL
+	LogLines      int           `mapstructure:"log_lines"`      // Сколько последних строк лога показывать
+}
+
+// Режимы шифрования SMTP (notify.smtp.tls).
+const (
+	SMTPTLSStartTLS = "starttls" // STARTTLS после подключения (порт 587)
+	SMTPTLSImplicit = "tls"      // TLS с первого байта (порт 465)
+	SMTPTLSNone     = "none"     // Без шифрования (локальный relay)
+)
+
+// NotifyConfig - каналы доставки уведомлений. Канал без настроек недоступен в правилах.
+type NotifyConfig struct {
+	SMTP     SMTPConfig     `mapstructure:"smtp"`
+	Webhook  WebhookConfig  `mapstructure:"webhook"`
+	Telegram TelegramConfig `mapstructure:"telegram"`
+}
+
+// SMTPConfig - отправка уведомлений по email. Пустой host отключает канал.
+type SMTPConfig struct {
+	Host     string        `mapstructure:"host"`
+	Port     int           `mapstructure:"port"`
+	Username string        `mapstructure:"username"` // "" - без авторизации
+	Password string        `mapstructure:"password"`
+	From     string        `mapstructure:"from"`    // Адрес отправителя
+	TLS      string        `mapstructure:"tls"`     // starttls, tls или none
+	Timeout  time.Duration `mapstructure:"timeout"` // Подключение и отправка одного письма
+}
+
+// WebhookConfig - отправка уведомлений POST-запросом с JSON на URL из правила.
+type WebhookConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Secret  string        `mapstructure:"secret"`  // Ключ HMAC-SHA256 подписи тела (заголовок X-CT-Signature), "" - без подписи
+	Timeout time.Duration `mapstructure:"timeout"` // Таймаут одного запроса
+}
+
+// TelegramConfig - Telegram-бот: уведомления по правилам /alerts/ и команды по позициям
+// в привязанном чате. Пустой bot_token отключает бота и канал.
+type TelegramConfig struct {
+	BotToken    string        `mapstructure:"bot_token"`
+	BotName     string        `mapstructure:"bot_name"`      // Имя бота без @ для ссылки привязки на странице профиля
+	APIURL      string        `mapstructure:"api_url"`       // Адрес Bot API (локальный сервер или заглушка для тестов)
+	PollTimeout time.Duration `mapstructure:"poll_timeout"`  // Long polling getUpdates
+	Timeout     time.Duration `mapstructure:"timeout"`       // Таймаут отправки сообщения
+	LinkCodeTTL time.Duration `mapstructure:"link_code_ttl"` // Срок действия одноразового кода привязки
+}
+
 var (
 	// globalConfig - глобальная переменная для хранения загруженной конфигурации.
 	// После вызова Load() конфигурация доступна через Get() из любого места программы.
@@ -464,6 +579,46 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	// БД котировок необязательна: пустой engine отключает хранилище свечей.
+	switch cfg.Databases.Quotes.Engine {
+	case "":
+	case "mysql":
+		quotes := &cfg.Databases.Quotes.MySQL
+		if quotes.Host == "" || quotes.Database == "" || quotes.User == "" {
+			return fmt.Errorf("databases.quotes.mysql host, database and user are required")
+		}
+		// Время свечей сканируется в time.Time.
+		quotes.ParseTime = true
+		if quotes.Pool.MaxOpenConns == 0 {
+			quotes.Pool.MaxOpenConns = 10
+		}
+		if quotes.Pool.MaxIdleConns == 0 {
+			quotes.Pool.MaxIdleConns = 2
+		}
+		if quotes.Pool.ConnMaxLifetime == 0 {
+			quotes.Pool.ConnMaxLifetime = 300 * time.Second
+		}
+		if quotes.Pool.MaxIdleConns > quotes.Pool.MaxOpenConns {
+			return fmt.Errorf("databases.quotes.mysql.pool.max_idle_conns cannot be greater than max_open_conns")
+		}
+	case "clickhouse":
+		quotes := &cfg.Databases.Quotes.ClickHouse
+		if quotes.Host == "" || quotes.Database == "" {
+			return fmt.Errorf("databases.quotes.clickhouse host and database are required")
+		}
+		if quotes.Port == 0 {
+			quotes.Port = 8123
+		}
+		if quotes.ConnectTimeout <= 0 {
+			quotes.ConnectTimeout = 10
+		}
+		if quotes.MaxBatchSize <= 0 {
+			quotes.MaxBatchSize = 10000
+		}
+	default:
+		return fmt.Errorf("unsupported databases.quotes.engine: %s", cfg.Databases.Quotes.Engine)
+	}
+
 	// Проверка, что секретные ключи изменены с дефолтных значений
 	// Это важно для безопасности - нельзя использовать дефолтные ключи в продакшн
 	if cfg.Security.Session.Secret == "" || cfg.Security.Session.Secret == "change-this-session-secret-in-production" {
@@ -557,6 +712,147 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("rate_limit.api.burst must be >= 0")
 	}
 
+	if err := validateEncryption(&cfg.Security.Encryption); err != nil {
+		return err
+	}
+
+	cfg.Security.WithdrawKeyPolicy = strings.ToLower(strings.TrimSpace(cfg.Security.WithdrawKeyPolicy))
+	switch cfg.Security.WithdrawKeyPolicy {
+	case "":
+		cfg.Security.WithdrawKeyPolicy = WithdrawKeyPolicyBlock
+	case WithdrawKeyPolicyBlock, WithdrawKeyPolicyWarn, WithdrawKeyPolicyOff:
+	default:
+		return fmt.Errorf("security.withdraw_key_policy must be one of: block, warn, off")
+	}
+
+	jobIntervals := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"instrument_sync_interval", cfg.Jobs.InstrumentSyncInterval},
+		{"funding_sync_interval", cfg.Jobs.FundingSyncInterval},
+		{"candle_sync_interval", cfg.Jobs.CandleSyncInterval},
+		{"balance_snapshot_interval", cfg.Jobs.BalanceSnapshotInterval},
+		{"reconcile_interval", cfg.Jobs.ReconcileInterval},
+	}
+	for _, job := range jobIntervals {
+		if job.interval < 0 {
+			return fmt.Errorf("jobs.%s must be >= 0", job.name)
+		}
+		if job.interval > 0 && job.interval < time.Minute {
+			return fmt.Errorf("jobs.%s must be at least 1m", job.name)
+		}
+	}
+
+	// Спред меняется быстрее остальных данных, поэтому замеры допускаются чаще раза в минуту.
+	if cfg.Jobs.SpreadSampleInterval < 0 {
+		return fmt.Errorf("jobs.spread_sample_interval must be >= 0")
+	}
+	if cfg.Jobs.SpreadSampleInterval > 0 && cfg.Jobs.SpreadSampleInterval < 10*time.Second {
+		return fmt.Errorf("jobs.spread_sample_interval must be at least 10s")
+	}
+	if cfg.Jobs.AlertEvaluateInterval < 0 {
+		return fmt.Errorf("jobs.alert_evaluate_interval must be >= 0")
+	}
+	if cfg.Jobs.AlertEvaluateInterval > 0 && cfg.Jobs.AlertEvaluateInterval < 10*time.Second {
+		return fmt.Errorf("jobs.alert_evaluate_interval must be at least 10s")
+	}
+	if cfg.Jobs.ReportInterval < 0 {
+		return fmt.Errorf("jobs.report_interval must be >= 0")
+	}
+	if cfg.Jobs.ReportInterval > 0 && cfg.Jobs.ReportInterval < time.Minute {
+		return fmt.Errorf("jobs.report_interval must be at least 1m")
+	}
+
+	if err := validateNotify(&cfg.Notify); err != nil {
+		return err
+	}
+	return validateDaemon(&cfg.Daemon)
+}
+
+// validateNotify проверяет настройки каналов уведомлений и подставляет значения по умолчанию.
+func validateNotify(cfg *NotifyConfig) error {
+	if cfg.SMTP.Host != "" {
+		cfg.SMTP.TLS = strings.ToLower(strings.TrimSpace(cfg.SMTP.TLS))
+		switch cfg.SMTP.TLS {
+		case "":
+			cfg.SMTP.TLS = SMTPTLSStartTLS
+		case SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+		default:
+			return fmt.Errorf("notify.smtp.tls must be one of: starttls, tls, none")
+		}
+		if cfg.SMTP.Port == 0 {
+			cfg.SMTP.Port = 587
+			if cfg.SMTP.TLS == SMTPTLSImplicit {
+				cfg.SMTP.Port = 465
+			}
+		}
+		if cfg.SMTP.Port < 0 || cfg.SMTP.Port > 65535 {
+			return fmt.Errorf("notify.smtp.port must be between 1 and 65535")
+		}
+		if cfg.SMTP.From == "" {
+			return fmt.Errorf("notify.smtp.from is required when notify.smtp.host is set")
+		}
+	}
+	if cfg.Telegram.BotToken != "" {
+		cfg.Telegram.APIURL = strings.TrimRight(strings.TrimSpace(cfg.Telegram.APIURL), "/")
+		if cfg.Telegram.APIURL == "" {
+			cfg.Telegram.APIURL = "https://api.telegram.org"
+		}
+		if _, err := url.ParseRequestURI(cfg.Telegram.APIURL); err != nil {
+			return fmt.Errorf("notify.telegram.api_url is invalid: %w", err)
+		}
+		cfg.Telegram.BotName = strings.TrimPrefix(strings.TrimSpace(cfg.Telegram.BotName), "@")
+		if cfg.Telegram.PollTimeout < 0 || cfg.Telegram.LinkCodeTTL < 0 {
+			return fmt.Errorf("notify.telegram.poll_timeout and link_code_ttl must be >= 0")
+		}
+		if cfg.Telegram.PollTimeout == 0 {
+			cfg.Telegram.PollTimeout = 30 * time.Second
+		}
+		if cfg.Telegram.LinkCodeTTL == 0 {
+			cfg.Telegram.LinkCodeTTL = 10 * time.Minute
+		}
+	}
+	for name, timeout := range map[string]*time.Duration{"smtp": &cfg.SMTP.Timeout, "webhook": &cfg.Webhook.Timeout, "telegram": &cfg.Telegram.Timeout} {
+		if *timeout < 0 {
+			return fmt.Errorf("notify.%s.timeout must be >= 0", name)
+		}
+		if *timeout == 0 {
+			*timeout = 10 * time.Second
+		}
+	}
+	return nil
+}
+
+// validateDaemon проверяет настройки процесса-обработчика и подставляет значения по умолчанию.
+func validateDaemon(cfg *DaemonConfig) error {
+	cfg.Mode = strings.ToLower(strings.TrimSpace(cfg.Mode))
+	switch cfg.Mode {
+	case "":
+		return nil
+	case DaemonModeExec:
+		if cfg.Command == "" {
+			return fmt.Errorf("daemon.command is required for mode exec")
+		}
+		if cfg.PIDFile == "" || cfg.LogFile == "" {
+			return fmt.Errorf("daemon.pid_file and daemon.log_file are required for mode exec")
+		}
+	case DaemonModeHTTP:
+		if !strings.HasPrefix(cfg.ControlURL, "http://") && !strings.HasPrefix(cfg.ControlURL, "https://") && !strings.HasPrefix(cfg.ControlURL, "unix://") {
+			return fmt.Errorf("daemon.control_url must start with http://, https:// or unix://")
+		}
+	default:
+		return fmt.Errorf("daemon.mode must be one of: exec, http")
+	}
+	if cfg.StopTimeout < 0 || cfg.LogLines < 0 {
+		return fmt.Errorf("daemon.stop_timeout and daemon.log_lines must be >= 0")
+	}
+	if cfg.StopTimeout == 0 {
+		cfg.StopTimeout = 10 * time.Second
+	}
+	if cfg.LogLines == 0 {
+		cfg.LogLines = 50
+	}
 	return nil
 }
 
@@ -572,8 +868,15 @@ func validate(cfg *Config) error {
 // Возвращает:
 //   - string: строка подключения к MySQL
 func (c *Config) GetMySQLDSN() string {
-	cfg := c.Databases.System.MySQL
+	return mysqlDSN(c.Databases.System.MySQL)
+}
+
+// GetQuotesMySQLDSN формирует DSN для БД котировок, если она размещена в MySQL.
+func (c *Config) GetQuotesMySQLDSN() string {
+	return mysqlDSN(c.Databases.Quotes.MySQL)
+}
 
+func mysqlDSN(cfg MySQLConfig) string {
 	// Базовая часть DSN: user:password@tcp(host:port)/database
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
 
@@ -643,3 +946,51 @@ func ConfigPath() string {
 	// Если ничего не найдено, возвращаем путь по умолчанию
 	return "./config"
 }
+
+// masterKeyIDPattern - допустимые идентификаторы мастер-ключей (ID входит в формат шифротекста).
+var masterKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// validateEncryption проверяет настройки шифрования ключей. Сами ключи читаются
+// и проверяются при инициализации пакета secrets.
+func validateEncryption(enc *EncryptionConfig) error {
+	if enc.Provider == "" {
+		enc.Provider = "local"
+	}
+	if enc.Provider != "local" {
+		return fmt.Errorf("unsupported security.encryption.provider: %s", enc.Provider)
+	}
+	if len(enc.Keys) == 0 {
+		if enc.CurrentKeyID != "" {
+			return fmt.Errorf("security.encryption.current_key_id is set but no keys are configured")
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool, len(enc.Keys))
+	for i, key := range enc.Keys {
+		if !masterKeyIDPattern.MatchString(key.ID) {
+			return fmt.Errorf("security.encryption.keys[%d].id must match %s", i, masterKeyIDPattern.String())
+		}
+		if seen[key.ID] {
+			return fmt.Errorf("security.encryption.keys: duplicate id %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		sources := 0
+		for _, value := range []string{key.Key, key.File, key.Env} {
+			if value != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("security.encryption.keys[%d] must set exactly one of key, file, env", i)
+		}
+	}
+	if enc.CurrentKeyID == "" {
+		return fmt.Errorf("security.encryption.current_key_id is required when keys are configured")
+	}
+	if !seen[enc.CurrentKeyID] {
+		return fmt.Errorf("security.encryption.current_key_id %q is not in keys", enc.CurrentKeyID)
+	}
+	return nil
+}
@@ -255,3 +255,172 @@ func TestValidateProxyTrustedHopsSkippedWhenProxyDisabled(t *testing.T) {
 		t.Fatalf("expected validate() to skip proxy.trusted_hops when proxy.enabled=false, got error: %v", err)
 	}
 }
+
+func TestValidateQuotesDatabase(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Databases.Quotes = DatabaseTargetConfig{Engine: "clickhouse", ClickHouse: ClickHouseConfig{Host: "ch", Database: "crypto"}}
+
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if cfg.Databases.Quotes.ClickHouse.Port != 8123 || cfg.Databases.Quotes.ClickHouse.ConnectTimeout == 0 {
+		t.Fatalf("expected clickhouse defaults to be populated, got %+v", cfg.Databases.Quotes.ClickHouse)
+	}
+
+	cfg = baseConfig()
+	cfg.Databases.Quotes = DatabaseTargetConfig{Engine: "mysql"}
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected validate() to fail for quotes mysql without host")
+	}
+
+	cfg = baseConfig()
+	cfg.Databases.Quotes = DatabaseTargetConfig{Engine: "oracle"}
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected validate() to fail for unsupported quotes engine")
+	}
+}
+
+func TestValidateEncryption(t *testing.T) {
+	cfg := baseConfig()
+	if err := validate(cfg); err != nil {
+		t.Fatalf("encryption must be optional, got %v", err)
+	}
+
+	cfg = baseConfig()
+	cfg.Security.Encryption = EncryptionConfig{
+		CurrentKeyID: "k2",
+		Keys: []MasterKeyConfig{
+			{ID: "k1", File: "/etc/web-ui/k1.key"},
+			{ID: "k2", Env: "CT_MASTER_KEY_K2"},
+		},
+	}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if cfg.Security.Encryption.Provider != "local" {
+		t.Fatalf("expected provider default local, got %q", cfg.Security.Encryption.Provider)
+	}
+
+	cases := map[string]EncryptionConfig{
+		"unknown current key": {CurrentKeyID: "k3", Keys: []MasterKeyConfig{{ID: "k1", Env: "A"}}},
+		"two sources":         {CurrentKeyID: "k1", Keys: []MasterKeyConfig{{ID: "k1", Env: "A", File: "/k"}}},
+		"duplicate id":        {CurrentKeyID: "k1", Keys: []MasterKeyConfig{{ID: "k1", Env: "A"}, {ID: "k1", Env: "B"}}},
+		"bad id":              {CurrentKeyID: "k:1", Keys: []MasterKeyConfig{{ID: "k:1", Env: "A"}}},
+		"unknown provider":    {Provider: "vault", CurrentKeyID: "k1", Keys: []MasterKeyConfig{{ID: "k1", Env: "A"}}},
+	}
+	for name, enc := range cases {
+		cfg = baseConfig()
+		cfg.Security.Encryption = enc
+		if err := validate(cfg); err == nil {
+			t.Errorf("%s: expected validate() to fail", name)
+		}
+	}
+}
+
+func TestValidateWithdrawKeyPolicy(t *testing.T) {
+	cfg := baseConfig()
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if cfg.Security.WithdrawKeyPolicy != WithdrawKeyPolicyBlock {
+		t.Fatalf("expected default policy block, got %q", cfg.Security.WithdrawKeyPolicy)
+	}
+
+	cfg = baseConfig()
+	cfg.Security.WithdrawKeyPolicy = " Warn "
+	if err := validate(cfg); err != nil || cfg.Security.WithdrawKeyPolicy != WithdrawKeyPolicyWarn {
+		t.Fatalf("expected policy warn, got %q (%v)", cfg.Security.WithdrawKeyPolicy, err)
+	}
+
+	cfg = baseConfig()
+	cfg.Security.WithdrawKeyPolicy = "deny"
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected validate() to fail for unknown policy")
+	}
+}
+
+func TestValidateDaemon(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Daemon = DaemonConfig{Mode: "EXEC", Command: "/opt/ct/bin/ct-daemon"}
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected error for exec mode without pid_file/log_file")
+	}
+
+	cfg.Daemon.PIDFile = "/run/ct/daemon.pid"
+	cfg.Daemon.LogFile = "/var/log/ct/daemon.log"
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if cfg.Daemon.Mode != DaemonModeExec || cfg.Daemon.StopTimeout != 10*time.Second || cfg.Daemon.LogLines != 50 {
+		t.Fatalf("unexpected daemon defaults: %+v", cfg.Daemon)
+	}
+
+	cfg.Daemon = DaemonConfig{Mode: "http", ControlURL: "tcp://127.0.0.1:9100"}
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected error for unsupported control_url scheme")
+	}
+}
+
+func TestValidateSpreadSampleInterval(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Jobs.SpreadSampleInterval = 5 * time.Second
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected error for jobs.spread_sample_interval below 10s")
+	}
+	cfg.Jobs.SpreadSampleInterval = 30 * time.Second
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+}
+
+func TestValidateNotify(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Notify.SMTP = SMTPConfig{Host: "smtp.example.com", TLS: "TLS"}
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected error for notify.smtp without from")
+	}
+
+	cfg.Notify.SMTP.From = "alerts@example.com"
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if cfg.Notify.SMTP.TLS != SMTPTLSImplicit || cfg.Notify.SMTP.Port != 465 || cfg.Notify.SMTP.Timeout != 10*time.Second {
+		t.Fatalf("unexpected smtp defaults: %+v", cfg.Notify.SMTP)
+	}
+	if cfg.Notify.Webhook.Timeout != 10*time.Second {
+		t.Fatalf("unexpected webhook timeout default: %v", cfg.Notify.Webhook.Timeout)
+	}
+
+	cfg.Notify.SMTP.TLS = "ssl"
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected error for unsupported notify.smtp.tls")
+	}
+}
+
+func TestValidateNotifyTelegram(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Notify.Telegram = TelegramConfig{BotToken: "123:abc", BotName: "@ct_bot", APIURL: "http://127.0.0.1:8081/"}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	tg := cfg.Notify.Telegram
+	if tg.APIURL != "http://127.0.0.1:8081" || tg.BotName != "ct_bot" {
+		t.Fatalf("unexpected telegram normalization: %+v", tg)
+	}
+	if tg.PollTimeout != 30*time.Second || tg.Timeout != 10*time.Second || tg.LinkCodeTTL != 10*time.Minute {
+		t.Fatalf("unexpected telegram defaults: %+v", tg)
+	}
+
+	cfg.Notify.Telegram = TelegramConfig{BotToken: "123:abc"}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	if cfg.Notify.Telegram.APIURL != "https://api.telegram.org" {
+		t.Fatalf("unexpected telegram api_url default: %q", cfg.Notify.Telegram.APIURL)
+	}
+
+	cfg.Notify.Telegram.APIURL = "not a url"
+	if err := validate(cfg); err == nil {
+		t.Fatal("expected error for invalid notify.telegram.api_url")
+	}
+}
@@ -0,0 +1,450 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	binanceDefaultBaseURL    = "https://api.binance.com"
+	binanceDefaultFuturesURL = "https://fapi.binance.com"
+)
+
+// binanceConnector - коннектор Binance. Спот - api.binance.com, фьючерсы - USDⓈ-M (fapi).
+type binanceConnector struct {
+	baseURL    string
+	futuresURL string
+	client     *http.Client
+}
+
+func newBinance(opts Options) *binanceConnector {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = binanceDefaultBaseURL
+	}
+	futuresURL := opts.FuturesBaseURL
+	if futuresURL == "" {
+		futuresURL = binanceDefaultFuturesURL
+		// Если BASE_URL переопределён (локальный стенд), фьючерсы идут туда же.
+		if opts.BaseURL != "" && !strings.Contains(opts.BaseURL, "binance.com") {
+			futuresURL = opts.BaseURL
+		}
+	}
+	return &binanceConnector{baseURL: baseURL, futuresURL: futuresURL, client: opts.HTTPClient}
+}
+
+func (c *binanceConnector) Name() string { return "binance" }
+
+func (c *binanceConnector) FetchInstruments(ctx context.Context, market string) ([]Instrument, error) {
+	market = NormalizeMarket(market)
+
+	type filter struct {
+		FilterType string `json:"filterType"`
+		TickSize   string `json:"tickSize"`
+		StepSize   string `json:"stepSize"`
+		MinQty     string `json:"minQty"`
+	}
+	type symbol struct {
+		Symbol       string   `json:"symbol"`
+		Status       string   `json:"status"`
+		BaseAsset    string   `json:"baseAsset"`
+		QuoteAsset   string   `json:"quoteAsset"`
+		ContractType string   `json:"contractType"`
+		Filters      []filter `json:"filters"`
+	}
+	var resp struct {
+		Symbols []symbol `json:"symbols"`
+	}
+
+	baseURL, path := c.baseURL, "/api/v3/exchangeInfo"
+	if market == MarketFutures {
+		baseURL, path = c.futuresURL, "/fapi/v1/exchangeInfo"
+	}
+	if err := getJSON(ctx, c.client, baseURL, path, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	instruments := make([]Instrument, 0, len(resp.Symbols))
+	for _, s := range resp.Symbols {
+		if market == MarketFutures && s.ContractType != "" && s.ContractType != "PERPETUAL" {
+			continue
+		}
+		inst := Instrument{
+			Symbol:        s.Symbol,
+			MarketType:    market,
+			BaseAsset:     s.BaseAsset,
+			QuoteAsset:    s.QuoteAsset,
+			ContractValue: 1,
+			Status:        binanceStatus(s.Status),
+		}
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				inst.TickSize = parseFloat(f.TickSize)
+			case "LOT_SIZE":
+				inst.LotSize = parseFloat(f.StepSize)
+				inst.MinQty = parseFloat(f.MinQty)
+			}
+		}
+		instruments = append(instruments, inst)
+	}
+
+	return instruments, nil
+}
+
+func binanceStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case "TRADING":
+		return InstrumentStatusTrading
+	case "BREAK", "HALT", "PENDING_TRADING", "PRE_TRADING", "POST_TRADING", "AUCTION_MATCH":
+		return InstrumentStatusHalted
+	default:
+		return InstrumentStatusDelisted
+	}
+}
+
+// FetchFundingHistory - /fapi/v1/fundingRate (до 1000 записей за запрос, от старых к новым).
+func (c *binanceConnector) FetchFundingHistory(ctx context.Context, symbol string, start, end time.Time) ([]FundingRate, error) {
+	type item struct {
+		Symbol      string `json:"symbol"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime int64  `json:"fundingTime"`
+		MarkPrice   string `json:"markPrice"`
+	}
+
+	var rates []FundingRate
+	from := start
+	for page := 0; page < 100 && from.Before(end); page++ {
+		query := url.Values{}
+		query.Set("symbol", symbol)
+		query.Set("startTime", strconv.FormatInt(from.UnixMilli(), 10))
+		query.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+		query.Set("limit", "1000")
+
+		var resp []item
+		if err := getJSON(ctx, c.client, c.futuresURL, "/fapi/v1/fundingRate", query, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp) == 0 {
+			break
+		}
+
+		latest := from
+		for _, it := range resp {
+			ts := time.UnixMilli(it.FundingTime).UTC()
+			rates = append(rates, FundingRate{
+				Symbol:      it.Symbol,
+				Rate:        parseFloat(it.FundingRate),
+				FundingTime: ts,
+				MarkPrice:   parseFloat(it.MarkPrice),
+			})
+			if ts.After(latest) {
+				latest = ts
+			}
+		}
+		if len(resp) < 1000 || !latest.After(from) {
+			break
+		}
+		from = latest.Add(time.Millisecond)
+	}
+
+	return sortFunding(rates), nil
+}
+
+// FetchFundingForecast - /fapi/v1/premiumIndex (последняя ставка = ставка следующего расчёта).
+func (c *binanceConnector) FetchFundingForecast(ctx context.Context, symbol string) (*FundingForecast, error) {
+	var resp struct {
+		Symbol          string `json:"symbol"`
+		MarkPrice       string `json:"markPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+	}
+
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	if err := getJSON(ctx, c.client, c.futuresURL, "/fapi/v1/premiumIndex", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Symbol == "" {
+		return nil, fmt.Errorf("binance premium index: symbol %s not found", symbol)
+	}
+
+	return &FundingForecast{
+		Symbol:          resp.Symbol,
+		Rate:            parseFloat(resp.LastFundingRate),
+		NextFundingTime: time.UnixMilli(resp.NextFundingTime).UTC(),
+		Interval:        DefaultFundingInterval,
+		MarkPrice:       parseFloat(resp.MarkPrice),
+	}, nil
+}
+
+// FetchCandles - /api/v3/klines (спот) и /fapi/v1/klines (фьючерсы), от старых к новым.
+func (c *binanceConnector) FetchCandles(ctx context.Context, market, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	if _, ok := CandleIntervals[interval]; !ok {
+		return nil, fmt.Errorf("%w: interval %q", ErrNotSupported, interval)
+	}
+
+	baseURL, path := c.baseURL, "/api/v3/klines"
+	if NormalizeMarket(market) == MarketFutures {
+		baseURL, path = c.futuresURL, "/fapi/v1/klines"
+	}
+
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("interval", interval)
+	query.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	query.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+	query.Set("limit", strconv.Itoa(candleLimit(interval, start, end)))
+
+	var rows [][]interface{}
+	if err := getJSON(ctx, c.client, baseURL, path, query, &rows); err != nil {
+		return nil, err
+	}
+
+	candles := make([]Candle, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		candles = append(candles, Candle{
+			OpenTime: rawMillis(row[0]),
+			Open:     rawFloat(row[1]),
+			High:     rawFloat(row[2]),
+			Low:      rawFloat(row[3]),
+			Close:    rawFloat(row[4]),
+			Volume:   rawFloat(row[5]),
+		})
+	}
+	return sortCandles(candles, start, end), nil
+}
+
+// FetchTicker - /api/v3/ticker/bookTicker (спот) и /fapi/v1/ticker/bookTicker (фьючерсы).
+func (c *binanceConnector) FetchTicker(ctx context.Context, market, symbol string) (*Ticker, error) {
+	var resp struct {
+		Symbol   string `json:"symbol"`
+		BidPrice string `json:"bidPrice"`
+		BidQty   string `json:"bidQty"`
+		AskPrice string `json:"askPrice"`
+		AskQty   string `json:"askQty"`
+		Time     int64  `json:"time"`
+	}
+
+	baseURL, path := c.baseURL, "/api/v3/ticker/bookTicker"
+	if NormalizeMarket(market) == MarketFutures {
+		baseURL, path = c.futuresURL, "/fapi/v1/ticker/bookTicker"
+	}
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	if err := getJSON(ctx, c.client, baseURL, path, query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Symbol == "" {
+		return nil, fmt.Errorf("binance book ticker: symbol %s not found", symbol)
+	}
+
+	ticker := &Ticker{
+		Symbol: resp.Symbol,
+		Bid:    parseFloat(resp.BidPrice),
+		BidQty: parseFloat(resp.BidQty),
+		Ask:    parseFloat(resp.AskPrice),
+		AskQty: parseFloat(resp.AskQty),
+	}
+	if resp.Time > 0 {
+		ticker.Time = time.UnixMilli(resp.Time).UTC()
+	}
+	return ticker, nil
+}
+
+// binanceRecvWindow - окно приёма подписанного запроса, мс.
+const binanceRecvWindow = "5000"
+
+// signedGet выполняет подписанный GET запрос Binance (SIGNED endpoint) и декодирует ответ в out.
+// Подпись: HMAC_SHA256(queryString) передаётся последним параметром signature.
+func (c *binanceConnector) signedGet(ctx context.Context, creds Credentials, baseURL, path string, query url.Values, out interface{}) error {
+	if err := requireCredentials(creds, false); err != nil {
+		return err
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	query.Set("recvWindow", binanceRecvWindow)
+	rawQuery := query.Encode()
+	rawQuery += "&signature=" + signHex(creds.SecretKey, rawQuery)
+	headers := map[string]string{"X-MBX-APIKEY": creds.APIKey}
+
+	status, body, err := doRaw(ctx, c.client, http.MethodGet, baseURL, path, rawQuery, headers)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		var failure struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		apiErr := &APIError{Exchange: "binance", HTTPStatus: status, Code: strconv.Itoa(status), Message: truncate(string(body), 256)}
+		if json.Unmarshal(body, &failure) == nil && failure.Code != 0 {
+			apiErr.Code, apiErr.Message = strconv.Itoa(failure.Code), failure.Msg
+		}
+		switch failure.Code {
+		case -2015:
+			// "Invalid API-key, IP, or permissions for action": Binance не различает причины,
+			// но IP вне whitelist - самая частая.
+			apiErr.Auth = true
+			apiErr.IPBlocked = strings.Contains(strings.ToLower(failure.Msg), "ip")
+		case -2014, -1022, -2008: // неверный формат ключа, подпись, несуществующий ключ
+			apiErr.Auth = true
+		}
+		if status == http.StatusUnauthorized {
+			apiErr.Auth = true
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	return nil
+}
+
+// FetchKeyPermissions читает ограничения ключа (/sapi/v1/account/apiRestrictions).
+func (c *binanceConnector) FetchKeyPermissions(ctx context.Context, creds Credentials) (*KeyPermissions, error) {
+	var resp struct {
+		IPRestrict                   bool `json:"ipRestrict"`
+		EnableReading                bool `json:"enableReading"`
+		EnableWithdrawals            bool `json:"enableWithdrawals"`
+		EnableSpotAndMarginTrading   bool `json:"enableSpotAndMarginTrading"`
+		EnableFutures                bool `json:"enableFutures"`
+		EnableMargin                 bool `json:"enableMargin"`
+		EnableVanillaOptions         bool `json:"enableVanillaOptions"`
+		EnablePortfolioMarginTrading bool `json:"enablePortfolioMarginTrading"`
+	}
+	if err := c.signedGet(ctx, creds, c.baseURL, "/sapi/v1/account/apiRestrictions", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &KeyPermissions{
+		Read: resp.EnableReading,
+		Trade: resp.EnableSpotAndMarginTrading || resp.EnableFutures || resp.EnableMargin ||
+			resp.EnableVanillaOptions || resp.EnablePortfolioMarginTrading,
+		Withdraw:     resp.EnableWithdrawals,
+		IPRestricted: resp.IPRestrict,
+	}, nil
+}
+
+// FetchBalances читает спотовый кошелёк (/api/v3/account) и кошелёк USDⓈ-M фьючерсов
+// (/fapi/v2/balance). Если ключу не разрешены фьючерсы, возвращается только спот.
+func (c *binanceConnector) FetchBalances(ctx context.Context, creds Credentials) ([]Balance, error) {
+	var account struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	query := url.Values{}
+	query.Set("omitZeroBalances", "true")
+	if err := c.signedGet(ctx, creds, c.baseURL, "/api/v3/account", query, &account); err != nil {
+		return nil, err
+	}
+
+	var balances []Balance
+	for _, it := range account.Balances {
+		free, locked := parseFloat(it.Free), parseFloat(it.Locked)
+		if free == 0 && locked == 0 {
+			continue
+		}
+		balances = append(balances, Balance{Wallet: MarketSpot, Asset: it.Asset, Free: free, Locked: locked})
+	}
+
+	var futures []struct {
+		Asset            string `json:"asset"`
+		Balance          string `json:"balance"`
+		AvailableBalance string `json:"availableBalance"`
+	}
+	if err := c.signedGet(ctx, creds, c.futuresURL, "/fapi/v2/balance", nil, &futures); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Auth {
+			return balances, nil
+		}
+		return nil, err
+	}
+	for _, it := range futures {
+		total, free := parseFloat(it.Balance), parseFloat(it.AvailableBalance)
+		if total == 0 {
+			continue
+		}
+		balances = append(balances, Balance{Wallet: MarketFutures, Asset: it.Asset, Free: free, Locked: total - free})
+	}
+	return balances, nil
+}
+
+// FetchPositions читает позиции USDⓈ-M фьючерсов (/fapi/v2/positionRisk).
+func (c *binanceConnector) FetchPositions(ctx context.Context, creds Credentials) ([]LivePosition, error) {
+	var resp []struct {
+		Symbol      string `json:"symbol"`
+		PositionAmt string `json:"positionAmt"`
+		EntryPrice  string `json:"entryPrice"`
+	}
+	if err := c.signedGet(ctx, creds, c.futuresURL, "/fapi/v2/positionRisk", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	var positions []LivePosition
+	for _, it := range resp {
+		size := parseFloat(it.PositionAmt)
+		if size == 0 {
+			continue
+		}
+		positions = append(positions, LivePosition{Symbol: it.Symbol, Size: size, EntryPrice: parseFloat(it.EntryPrice)})
+	}
+	return positions, nil
+}
+
+// FetchCurrencies читает справочник монет и сетей (/sapi/v1/capital/config/getall).
+func (c *binanceConnector) FetchCurrencies(ctx context.Context, creds Credentials) ([]Currency, error) {
+	var resp []struct {
+		Coin        string `json:"coin"`
+		Name        string `json:"name"`
+		NetworkList []struct {
+			Network         string `json:"network"`
+			Name            string `json:"name"`
+			ContractAddress string `json:"contractAddress"`
+			DepositEnable   bool   `json:"depositEnable"`
+			WithdrawEnable  bool   `json:"withdrawEnable"`
+			WithdrawFee     string `json:"withdrawFee"`
+			WithdrawMin     string `json:"withdrawMin"`
+			MinConfirm      int    `json:"minConfirm"`
+		} `json:"networkList"`
+	}
+	if err := c.signedGet(ctx, creds, c.baseURL, "/sapi/v1/capital/config/getall", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	result := make([]Currency, 0, len(resp))
+	for _, it := range resp {
+		currency := Currency{Asset: it.Coin, Name: it.Name}
+		for _, n := range it.NetworkList {
+			currency.Networks = append(currency.Networks, CurrencyNetwork{
+				Network:         n.Network,
+				Name:            n.Name,
+				ContractAddress: n.ContractAddress,
+				DepositEnabled:  n.DepositEnable,
+				WithdrawEnabled: n.WithdrawEnable,
+				WithdrawFee:     parseFloat(n.WithdrawFee),
+				WithdrawMin:     parseFloat(n.WithdrawMin),
+				Confirmations:   n.MinConfirm,
+			})
+		}
+		result = append(result, currency)
+	}
+	return result, nil
+}
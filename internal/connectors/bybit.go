@@ -0,0 +1,513 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const bybitDefaultBaseURL = "https://api.bybit.com"
+
+// bybitConnector - коннектор Bybit (API v5). Фьючерсы = category=linear (USDT/USDC perpetual).
+type bybitConnector struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newBybit(opts Options) *bybitConnector {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = bybitDefaultBaseURL
+	}
+	return &bybitConnector{baseURL: baseURL, client: opts.HTTPClient}
+}
+
+func (c *bybitConnector) Name() string { return "bybit" }
+
+// bybitResponse - общая обёртка ответов Bybit v5.
+type bybitResponse[T any] struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  T      `json:"result"`
+}
+
+func (c *bybitConnector) category(market string) string {
+	if NormalizeMarket(market) == MarketSpot {
+		return "spot"
+	}
+	return "linear"
+}
+
+func (c *bybitConnector) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return getJSON(ctx, c.client, c.baseURL, path, query, out)
+}
+
+func (c *bybitConnector) FetchInstruments(ctx context.Context, market string) ([]Instrument, error) {
+	market = NormalizeMarket(market)
+
+	type item struct {
+		Symbol      string `json:"symbol"`
+		BaseCoin    string `json:"baseCoin"`
+		QuoteCoin   string `json:"quoteCoin"`
+		SettleCoin  string `json:"settleCoin"`
+		Status      string `json:"status"`
+		PriceFilter struct {
+			TickSize string `json:"tickSize"`
+		} `json:"priceFilter"`
+		LotSizeFilter struct {
+			BasePrecision string `json:"basePrecision"`
+			QtyStep       string `json:"qtyStep"`
+			MinOrderQty   string `json:"minOrderQty"`
+		} `json:"lotSizeFilter"`
+	}
+	type result struct {
+		List           []item `json:"list"`
+		NextPageCursor string `json:"nextPageCursor"`
+	}
+
+	var instruments []Instrument
+	cursor := ""
+	for page := 0; page < 50; page++ {
+		query := url.Values{}
+		query.Set("category", c.category(market))
+		query.Set("limit", "1000")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		var resp bybitResponse[result]
+		if err := c.get(ctx, "/v5/market/instruments-info", query, &resp); err != nil {
+			return nil, err
+		}
+		if resp.RetCode != 0 {
+			return nil, fmt.Errorf("bybit instruments: %d %s", resp.RetCode, resp.RetMsg)
+		}
+
+		for _, it := range resp.Result.List {
+			lot := it.LotSizeFilter.QtyStep
+			if market == MarketSpot {
+				lot = it.LotSizeFilter.BasePrecision
+			}
+			instruments = append(instruments, Instrument{
+				Symbol:        it.Symbol,
+				MarketType:    market,
+				BaseAsset:     it.BaseCoin,
+				QuoteAsset:    it.QuoteCoin,
+				TickSize:      parseFloat(it.PriceFilter.TickSize),
+				LotSize:       parseFloat(lot),
+				MinQty:        parseFloat(it.LotSizeFilter.MinOrderQty),
+				ContractValue: 1,
+				Status:        bybitStatus(it.Status),
+			})
+		}
+
+		cursor = resp.Result.NextPageCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	return instruments, nil
+}
+
+func bybitStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "trading":
+		return InstrumentStatusTrading
+	case "closed", "delivering", "settling":
+		return InstrumentStatusDelisted
+	default:
+		return InstrumentStatusHalted
+	}
+}
+
+// FetchFundingHistory - /v5/market/funding/history (до 200 записей за запрос, от новых к старым).
+func (c *bybitConnector) FetchFundingHistory(ctx context.Context, symbol string, start, end time.Time) ([]FundingRate, error) {
+	type item struct {
+		Symbol               string `json:"symbol"`
+		FundingRate          string `json:"fundingRate"`
+		FundingRateTimestamp string `json:"fundingRateTimestamp"`
+	}
+	type result struct {
+		List []item `json:"list"`
+	}
+
+	var rates []FundingRate
+	cursorEnd := end
+	for page := 0; page < 100 && cursorEnd.After(start); page++ {
+		query := url.Values{}
+		query.Set("category", "linear")
+		query.Set("symbol", symbol)
+		query.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+		query.Set("endTime", strconv.FormatInt(cursorEnd.UnixMilli(), 10))
+		query.Set("limit", "200")
+
+		var resp bybitResponse[result]
+		if err := c.get(ctx, "/v5/market/funding/history", query, &resp); err != nil {
+			return nil, err
+		}
+		if resp.RetCode != 0 {
+			return nil, fmt.Errorf("bybit funding history: %d %s", resp.RetCode, resp.RetMsg)
+		}
+		if len(resp.Result.List) == 0 {
+			break
+		}
+
+		oldest := cursorEnd
+		for _, it := range resp.Result.List {
+			ts := parseMillis(it.FundingRateTimestamp)
+			if ts.IsZero() {
+				continue
+			}
+			rates = append(rates, FundingRate{Symbol: it.Symbol, Rate: parseFloat(it.FundingRate), FundingTime: ts})
+			if ts.Before(oldest) {
+				oldest = ts
+			}
+		}
+		if len(resp.Result.List) < 200 || !oldest.Before(cursorEnd) {
+			break
+		}
+		cursorEnd = oldest.Add(-time.Millisecond)
+	}
+
+	return sortFunding(rates), nil
+}
+
+// FetchFundingForecast - текущая ставка и время следующего расчёта из /v5/market/tickers.
+func (c *bybitConnector) FetchFundingForecast(ctx context.Context, symbol string) (*FundingForecast, error) {
+	type item struct {
+		Symbol              string `json:"symbol"`
+		FundingRate         string `json:"fundingRate"`
+		NextFundingTime     string `json:"nextFundingTime"`
+		FundingIntervalHour string `json:"fundingIntervalHour"`
+		MarkPrice           string `json:"markPrice"`
+	}
+	type result struct {
+		List []item `json:"list"`
+	}
+
+	query := url.Values{}
+	query.Set("category", "linear")
+	query.Set("symbol", symbol)
+
+	var resp bybitResponse[result]
+	if err := c.get(ctx, "/v5/market/tickers", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.RetCode != 0 {
+		return nil, fmt.Errorf("bybit tickers: %d %s", resp.RetCode, resp.RetMsg)
+	}
+	if len(resp.Result.List) == 0 {
+		return nil, fmt.Errorf("bybit tickers: symbol %s not found", symbol)
+	}
+
+	it := resp.Result.List[0]
+	interval := DefaultFundingInterval
+	if hours := parseFloat(it.FundingIntervalHour); hours > 0 {
+		interval = time.Duration(hours * float64(time.Hour))
+	}
+	return &FundingForecast{
+		Symbol:          it.Symbol,
+		Rate:            parseFloat(it.FundingRate),
+		NextFundingTime: parseMillis(it.NextFundingTime),
+		Interval:        interval,
+		MarkPrice:       parseFloat(it.MarkPrice),
+	}, nil
+}
+
+var bybitCandleIntervals = map[string]string{"1m": "1", "5m": "5", "15m": "15", "1h": "60", "4h": "240", "1d": "D"}
+
+// FetchCandles - /v5/market/kline (от новых к старым, элементы - строки).
+func (c *bybitConnector) FetchCandles(ctx context.Context, market, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	code, ok := bybitCandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: interval %q", ErrNotSupported, interval)
+	}
+
+	query := url.Values{}
+	query.Set("category", c.category(market))
+	query.Set("symbol", symbol)
+	query.Set("interval", code)
+	query.Set("start", strconv.FormatInt(start.UnixMilli(), 10))
+	query.Set("end", strconv.FormatInt(end.UnixMilli(), 10))
+	query.Set("limit", strconv.Itoa(candleLimit(interval, start, end)))
+
+	var resp bybitResponse[struct {
+		List [][]interface{} `json:"list"`
+	}]
+	if err := c.get(ctx, "/v5/market/kline", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.RetCode != 0 {
+		return nil, fmt.Errorf("bybit kline: %d %s", resp.RetCode, resp.RetMsg)
+	}
+
+	candles := make([]Candle, 0, len(resp.Result.List))
+	for _, row := range resp.Result.List {
+		if len(row) < 6 {
+			continue
+		}
+		candles = append(candles, Candle{
+			OpenTime: rawMillis(row[0]),
+			Open:     rawFloat(row[1]),
+			High:     rawFloat(row[2]),
+			Low:      rawFloat(row[3]),
+			Close:    rawFloat(row[4]),
+			Volume:   rawFloat(row[5]),
+		})
+	}
+	return sortCandles(candles, start, end), nil
+}
+
+// FetchTicker - /v5/market/tickers (лучшие bid/ask по инструменту).
+func (c *bybitConnector) FetchTicker(ctx context.Context, market, symbol string) (*Ticker, error) {
+	query := url.Values{}
+	query.Set("category", c.category(market))
+	query.Set("symbol", symbol)
+
+	var resp struct {
+		bybitResponse[struct {
+			List []struct {
+				Symbol    string `json:"symbol"`
+				Bid1Price string `json:"bid1Price"`
+				Bid1Size  string `json:"bid1Size"`
+				Ask1Price string `json:"ask1Price"`
+				Ask1Size  string `json:"ask1Size"`
+			} `json:"list"`
+		}]
+		Time int64 `json:"time"`
+	}
+	if err := c.get(ctx, "/v5/market/tickers", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.RetCode != 0 {
+		return nil, fmt.Errorf("bybit tickers: %d %s", resp.RetCode, resp.RetMsg)
+	}
+	if len(resp.Result.List) == 0 {
+		return nil, fmt.Errorf("bybit tickers: symbol %s not found", symbol)
+	}
+
+	item := resp.Result.List[0]
+	ticker := &Ticker{
+		Symbol: item.Symbol,
+		Bid:    parseFloat(item.Bid1Price),
+		BidQty: parseFloat(item.Bid1Size),
+		Ask:    parseFloat(item.Ask1Price),
+		AskQty: parseFloat(item.Ask1Size),
+	}
+	if resp.Time > 0 {
+		ticker.Time = time.UnixMilli(resp.Time).UTC()
+	}
+	return ticker, nil
+}
+
+// bybitRecvWindow - окно приёма подписанного запроса, мс.
+const bybitRecvWindow = "5000"
+
+// signedGet выполняет подписанный GET запрос Bybit v5 и декодирует ответ в out.
+// Подпись: HMAC_SHA256(timestamp + apiKey + recvWindow + queryString).
+func (c *bybitConnector) signedGet(ctx context.Context, creds Credentials, path string, query url.Values, out interface{}) error {
+	if err := requireCredentials(creds, false); err != nil {
+		return err
+	}
+	rawQuery := query.Encode()
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	headers := map[string]string{
+		"X-BAPI-API-KEY":     creds.APIKey,
+		"X-BAPI-TIMESTAMP":   timestamp,
+		"X-BAPI-RECV-WINDOW": bybitRecvWindow,
+		"X-BAPI-SIGN":        signHex(creds.SecretKey, timestamp+creds.APIKey+bybitRecvWindow+rawQuery),
+	}
+
+	status, body, err := doRaw(ctx, c.client, http.MethodGet, c.baseURL, path, rawQuery, headers)
+	if err != nil {
+		return err
+	}
+	var envelope bybitResponse[json.RawMessage]
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if status != http.StatusOK {
+			return &APIError{Exchange: "bybit", HTTPStatus: status, Code: strconv.Itoa(status), Message: truncate(string(body), 256), Auth: status == http.StatusUnauthorized}
+		}
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	if status != http.StatusOK || envelope.RetCode != 0 {
+		apiErr := &APIError{Exchange: "bybit", HTTPStatus: status, Code: strconv.Itoa(envelope.RetCode), Message: envelope.RetMsg}
+		switch envelope.RetCode {
+		case 10010: // unmatched IP
+			apiErr.IPBlocked = true
+		case 10003, 10004, 10007, 33004: // invalid key, signature, auth failed, key expired
+			apiErr.Auth = true
+		}
+		if status == http.StatusUnauthorized {
+			apiErr.Auth = true
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Result, out); err != nil {
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	return nil
+}
+
+// FetchKeyPermissions читает права ключа (/v5/user/query-api).
+func (c *bybitConnector) FetchKeyPermissions(ctx context.Context, creds Credentials) (*KeyPermissions, error) {
+	var result struct {
+		ReadOnly    int                 `json:"readOnly"`
+		Permissions map[string][]string `json:"permissions"`
+		IPs         []string            `json:"ips"`
+	}
+	if err := c.signedGet(ctx, creds, "/v5/user/query-api", url.Values{}, &result); err != nil {
+		return nil, err
+	}
+
+	perms := &KeyPermissions{Read: true}
+	for group, items := range result.Permissions {
+		for _, item := range items {
+			switch {
+			case strings.EqualFold(item, "Withdraw"):
+				perms.Withdraw = true
+			case group == "ContractTrade" || group == "Spot" || group == "Options" || group == "Derivatives" || group == "CopyTrading":
+				perms.Trade = true
+			}
+		}
+	}
+	if result.ReadOnly == 1 {
+		perms.Trade = false
+	}
+	perms.IPWhitelist = parseIPList(result.IPs...)
+	perms.IPRestricted = len(perms.IPWhitelist) > 0
+	return perms, nil
+}
+
+// FetchBalances читает единый торговый аккаунт (/v5/account/wallet-balance, accountType=UNIFIED).
+func (c *bybitConnector) FetchBalances(ctx context.Context, creds Credentials) ([]Balance, error) {
+	var result struct {
+		List []struct {
+			Coin []struct {
+				Coin          string `json:"coin"`
+				WalletBalance string `json:"walletBalance"`
+				Locked        string `json:"locked"`
+				UsdValue      string `json:"usdValue"`
+			} `json:"coin"`
+		} `json:"list"`
+	}
+	query := url.Values{}
+	query.Set("accountType", "UNIFIED")
+	if err := c.signedGet(ctx, creds, "/v5/account/wallet-balance", query, &result); err != nil {
+		return nil, err
+	}
+
+	var balances []Balance
+	for _, account := range result.List {
+		for _, coin := range account.Coin {
+			total := parseFloat(coin.WalletBalance)
+			locked := parseFloat(coin.Locked)
+			if total == 0 && locked == 0 {
+				continue
+			}
+			balances = append(balances, Balance{
+				Wallet:   "unified",
+				Asset:    coin.Coin,
+				Free:     total - locked,
+				Locked:   locked,
+				USDValue: parseFloat(coin.UsdValue),
+			})
+		}
+	}
+	return balances, nil
+}
+
+// FetchPositions читает открытые позиции USDT-перпетуалов (/v5/position/list, category=linear).
+func (c *bybitConnector) FetchPositions(ctx context.Context, creds Credentials) ([]LivePosition, error) {
+	type item struct {
+		Symbol   string `json:"symbol"`
+		Side     string `json:"side"`
+		Size     string `json:"size"`
+		AvgPrice string `json:"avgPrice"`
+	}
+
+	var positions []LivePosition
+	cursor := ""
+	for page := 0; page < 50; page++ {
+		query := url.Values{}
+		query.Set("category", "linear")
+		query.Set("settleCoin", "USDT")
+		query.Set("limit", "200")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var result struct {
+			List           []item `json:"list"`
+			NextPageCursor string `json:"nextPageCursor"`
+		}
+		if err := c.signedGet(ctx, creds, "/v5/position/list", query, &result); err != nil {
+			return nil, err
+		}
+		for _, it := range result.List {
+			size := parseFloat(it.Size)
+			if size == 0 {
+				continue
+			}
+			if strings.EqualFold(it.Side, "Sell") {
+				size = -size
+			}
+			positions = append(positions, LivePosition{Symbol: it.Symbol, Size: size, EntryPrice: parseFloat(it.AvgPrice)})
+		}
+		if result.NextPageCursor == "" || result.NextPageCursor == cursor {
+			break
+		}
+		cursor = result.NextPageCursor
+	}
+	return positions, nil
+}
+
+// FetchCurrencies читает справочник монет и сетей (/v5/asset/coin/query-info).
+func (c *bybitConnector) FetchCurrencies(ctx context.Context, creds Credentials) ([]Currency, error) {
+	var result struct {
+		Rows []struct {
+			Name   string `json:"name"`
+			Coin   string `json:"coin"`
+			Chains []struct {
+				Chain           string `json:"chain"`
+				ChainType       string `json:"chainType"`
+				Confirmation    string `json:"confirmation"`
+				WithdrawFee     string `json:"withdrawFee"`
+				WithdrawMin     string `json:"withdrawMin"`
+				ChainDeposit    string `json:"chainDeposit"`
+				ChainWithdraw   string `json:"chainWithdraw"`
+				ContractAddress string `json:"contractAddress"`
+			} `json:"chains"`
+		} `json:"rows"`
+	}
+	if err := c.signedGet(ctx, creds, "/v5/asset/coin/query-info", url.Values{}, &result); err != nil {
+		return nil, err
+	}
+
+	currencies := make([]Currency, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		currency := Currency{Asset: row.Coin, Name: row.Name}
+		for _, ch := range row.Chains {
+			confirmations, _ := strconv.Atoi(ch.Confirmation)
+			currency.Networks = append(currency.Networks, CurrencyNetwork{
+				Network:         ch.Chain,
+				Name:            ch.ChainType,
+				ContractAddress: ch.ContractAddress,
+				DepositEnabled:  ch.ChainDeposit == "1",
+				WithdrawEnabled: ch.ChainWithdraw == "1",
+				WithdrawFee:     parseFloat(ch.WithdrawFee),
+				WithdrawMin:     parseFloat(ch.WithdrawMin),
+				Confirmations:   confirmations,
+			})
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, nil
+}
@@ -0,0 +1,188 @@
+// Package connectors содержит клиентов публичных и приватных REST API бирж.
+//
+// Коннектор выбирается по полю EXCHANGE.CLASS_TO_FACTORY (как фабрика классов в PHP-версии),
+// базовый URL берётся из EXCHANGE.BASE_URL, поэтому в тестах и на стендах
+// биржу можно подменить локальным сервером.
+package connectors
+
+import (
+	"context"
+	"ctweb/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Типы рынков, которые используются в POS_POSITIONS.MARKET_TYPE.
+const (
+	MarketSpot    = "SPOT"
+	MarketFutures = "FUTURES"
+)
+
+// Статусы инструмента после нормализации.
+const (
+	InstrumentStatusTrading  = "TRADING"
+	InstrumentStatusHalted   = "HALTED"
+	InstrumentStatusDelisted = "DELISTED"
+)
+
+// ErrNotSupported возвращается, если биржа не поддерживает операцию
+// или коннектор для неё ещё не реализован.
+var ErrNotSupported = errors.New("operation is not supported by connector")
+
+// Instrument - торговый инструмент в том виде, в котором его отдаёт биржа,
+// приведённый к общему формату.
+type Instrument struct {
+	Symbol        string  // Символ на бирже (например, "BTCUSDT" или "BTC-USDT")
+	MarketType    string  // SPOT или FUTURES
+	BaseAsset     string  // Базовая валюта
+	QuoteAsset    string  // Котируемая валюта (для фьючерсов - валюта расчётов)
+	TickSize      float64 // Шаг цены
+	LotSize       float64 // Шаг объёма
+	MinQty        float64 // Минимальный объём заявки
+	ContractValue float64 // Множитель контракта (1 для спота и линейных USDT-контрактов)
+	Status        string  // TRADING / HALTED / DELISTED
+}
+
+// Connector - минимальный набор операций, который должен поддерживать каждый коннектор.
+// Дополнительные возможности (funding, балансы и т.д.) описываются отдельными интерфейсами.
+type Connector interface {
+	// Name возвращает имя коннектора (совпадает с CLASS_TO_FACTORY в нижнем регистре).
+	Name() string
+	// FetchInstruments загружает список инструментов указанного рынка.
+	FetchInstruments(ctx context.Context, market string) ([]Instrument, error)
+}
+
+// FundingRate - одна выплата funding по бессрочному контракту.
+type FundingRate struct {
+	Symbol      string
+	Rate        float64   // Ставка за период (0.0001 = 0.01%)
+	FundingTime time.Time // Время расчёта (UTC)
+	MarkPrice   float64   // Mark price на момент расчёта (0, если биржа не отдаёт)
+}
+
+// FundingForecast - текущая (прогнозная) ставка и время следующего расчёта.
+type FundingForecast struct {
+	Symbol          string
+	Rate            float64
+	NextFundingTime time.Time
+	Interval        time.Duration // Период между расчётами
+	MarkPrice       float64
+}
+
+// DefaultFundingInterval - период funding у большинства бирж, если биржа его не сообщает.
+const DefaultFundingInterval = 8 * time.Hour
+
+// FundingRateProvider - коннектор умеет отдавать историю и прогноз funding по бессрочным контрактам.
+type FundingRateProvider interface {
+	// FetchFundingHistory возвращает выплаты funding в интервале [start, end], отсортированные по времени.
+	FetchFundingHistory(ctx context.Context, symbol string, start, end time.Time) ([]FundingRate, error)
+	// FetchFundingForecast возвращает текущую ставку и время следующего расчёта.
+	FetchFundingForecast(ctx context.Context, symbol string) (*FundingForecast, error)
+}
+
+// Candle - свеча OHLCV. OpenTime - начало периода (UTC).
+type Candle struct {
+	OpenTime time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64 // Объём в базовой валюте (для фьючерсов - в контрактах, как отдаёт биржа)
+}
+
+// CandleIntervals - поддерживаемые интервалы свечей и их длительность.
+var CandleIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// CandlePageSize - сколько свечей гарантированно отдаёт один запрос к любой из бирж
+// (минимальный лимит среди поддерживаемых API - у OKX).
+const CandlePageSize = 100
+
+// CandleProvider - коннектор умеет отдавать исторические свечи.
+type CandleProvider interface {
+	// FetchCandles возвращает свечи с OpenTime в [start, end], отсортированные по времени.
+	// За один вызов отдаётся не больше CandlePageSize свечей - постраничную загрузку делает вызывающий.
+	FetchCandles(ctx context.Context, market, symbol, interval string, start, end time.Time) ([]Candle, error)
+}
+
+// Ticker - лучшие цены стакана (bid/ask) по инструменту.
+type Ticker struct {
+	Symbol string
+	Bid    float64
+	BidQty float64
+	Ask    float64
+	AskQty float64
+	Time   time.Time // Время котировки по данным биржи (нулевое - биржа не отдаёт)
+}
+
+// Mid возвращает середину спреда (0, если одной из сторон нет).
+func (t *Ticker) Mid() float64 {
+	if t.Bid <= 0 || t.Ask <= 0 {
+		return 0
+	}
+	return (t.Bid + t.Ask) / 2
+}
+
+// TickerProvider - коннектор умеет отдавать текущие лучшие bid/ask инструмента.
+type TickerProvider interface {
+	FetchTicker(ctx context.Context, market, symbol string) (*Ticker, error)
+}
+
+// Options - параметры создания коннектора.
+type Options struct {
+	BaseURL        string       // Базовый URL REST API (EXCHANGE.BASE_URL)
+	FuturesBaseURL string       // Отдельный URL для фьючерсов (Binance, KuCoin), если пусто - значение по умолчанию
+	HTTPClient     *http.Client // HTTP клиент (если nil - клиент с таймаутом по умолчанию)
+}
+
+// DefaultTimeout - таймаут HTTP запросов к биржам по умолчанию.
+const DefaultTimeout = 15 * time.Second
+
+// New создаёт коннектор по записи биржи.
+//
+// Возвращает ErrNotSupported, если для CLASS_TO_FACTORY нет реализации.
+func New(exchange *models.Exchange) (Connector, error) {
+	if exchange == nil {
+		return nil, fmt.Errorf("exchange is nil")
+	}
+	return NewByClass(exchange.ClassToFactory, Options{BaseURL: exchange.BaseURL})
+}
+
+// NewByClass создаёт коннектор по имени класса и опциям.
+func NewByClass(class string, opts Options) (Connector, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	opts.BaseURL = strings.TrimRight(strings.TrimSpace(opts.BaseURL), "/")
+	opts.FuturesBaseURL = strings.TrimRight(strings.TrimSpace(opts.FuturesBaseURL), "/")
+
+	switch strings.ToLower(strings.TrimSpace(class)) {
+	case "bybit":
+		return newBybit(opts), nil
+	case "binance":
+		return newBinance(opts), nil
+	case "okx", "okex":
+		return newOKX(opts), nil
+	case "kucoin":
+		return newKucoin(opts), nil
+	default:
+		return nil, fmt.Errorf("%w: class %q", ErrNotSupported, class)
+	}
+}
+
+// NormalizeMarket приводит тип рынка к SPOT/FUTURES.
+func NormalizeMarket(market string) string {
+	if strings.EqualFold(strings.TrimSpace(market), MarketSpot) {
+		return MarketSpot
+	}
+	return MarketFutures
+}
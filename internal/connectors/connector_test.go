@@ -0,0 +1,210 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewByClassUnknown(t *testing.T) {
+	if _, err := NewByClass("SomeExchange", Options{}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestBybitFetchInstruments(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/v5/market/instruments-info": `{"retCode":0,"retMsg":"OK","result":{"list":[
+			{"symbol":"BTCUSDT","baseCoin":"BTC","quoteCoin":"USDT","status":"Trading",
+			 "priceFilter":{"tickSize":"0.10"},"lotSizeFilter":{"qtyStep":"0.001","minOrderQty":"0.001"}},
+			{"symbol":"OLDUSDT","baseCoin":"OLD","quoteCoin":"USDT","status":"Closed",
+			 "priceFilter":{"tickSize":"0.0001"},"lotSizeFilter":{"qtyStep":"1","minOrderQty":"1"}}
+		],"nextPageCursor":""}}`,
+	})
+
+	conn, err := NewByClass("Bybit", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	items, err := conn.FetchInstruments(context.Background(), "futures")
+	if err != nil {
+		t.Fatalf("FetchInstruments: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 instruments, got %d", len(items))
+	}
+	if items[0].TickSize != 0.1 || items[0].LotSize != 0.001 || items[0].Status != InstrumentStatusTrading {
+		t.Fatalf("unexpected instrument: %+v", items[0])
+	}
+	if items[1].Status != InstrumentStatusDelisted {
+		t.Fatalf("expected delisted status, got %s", items[1].Status)
+	}
+}
+
+func TestBinanceFetchFuturesSkipsDelivery(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/fapi/v1/exchangeInfo": `{"symbols":[
+			{"symbol":"BTCUSDT","status":"TRADING","baseAsset":"BTC","quoteAsset":"USDT","contractType":"PERPETUAL",
+			 "filters":[{"filterType":"PRICE_FILTER","tickSize":"0.10"},{"filterType":"LOT_SIZE","stepSize":"0.001","minQty":"0.001"}]},
+			{"symbol":"BTCUSDT_250627","status":"TRADING","baseAsset":"BTC","quoteAsset":"USDT","contractType":"CURRENT_QUARTER","filters":[]}
+		]}`,
+	})
+
+	conn, err := NewByClass("binance", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	items, err := conn.FetchInstruments(context.Background(), MarketFutures)
+	if err != nil {
+		t.Fatalf("FetchInstruments: %v", err)
+	}
+	if len(items) != 1 || items[0].Symbol != "BTCUSDT" || items[0].MinQty != 0.001 {
+		t.Fatalf("unexpected instruments: %+v", items)
+	}
+}
+
+func TestOKXFetchSwapContractValue(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v5/public/instruments": `{"code":"0","msg":"","data":[
+			{"instId":"BTC-USDT-SWAP","uly":"BTC-USDT","settleCcy":"USDT","ctVal":"0.01","tickSz":"0.1","lotSz":"1","minSz":"1","state":"live"}
+		]}`,
+	})
+
+	conn, err := NewByClass("OKX", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	items, err := conn.FetchInstruments(context.Background(), MarketFutures)
+	if err != nil {
+		t.Fatalf("FetchInstruments: %v", err)
+	}
+	if len(items) != 1 || items[0].BaseAsset != "BTC" || items[0].ContractValue != 0.01 {
+		t.Fatalf("unexpected instruments: %+v", items)
+	}
+}
+
+func TestBybitFundingRates(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/v5/market/funding/history": `{"retCode":0,"retMsg":"OK","result":{"list":[
+			{"symbol":"BTCUSDT","fundingRate":"0.0001","fundingRateTimestamp":"1704096000000"},
+			{"symbol":"BTCUSDT","fundingRate":"-0.0002","fundingRateTimestamp":"1704067200000"}
+		]}}`,
+		"/v5/market/tickers": `{"retCode":0,"retMsg":"OK","result":{"list":[
+			{"symbol":"BTCUSDT","fundingRate":"0.00015","nextFundingTime":"1704124800000","fundingIntervalHour":"4","markPrice":"42000.5"}
+		]}}`,
+	})
+
+	conn, err := NewByClass("Bybit", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	provider, ok := conn.(FundingRateProvider)
+	if !ok {
+		t.Fatal("bybit connector must implement FundingRateProvider")
+	}
+
+	start := time.UnixMilli(1704000000000)
+	end := time.UnixMilli(1704100000000)
+	rates, err := provider.FetchFundingHistory(context.Background(), "BTCUSDT", start, end)
+	if err != nil {
+		t.Fatalf("FetchFundingHistory: %v", err)
+	}
+	if len(rates) != 2 || rates[0].Rate != -0.0002 || !rates[0].FundingTime.Before(rates[1].FundingTime) {
+		t.Fatalf("unexpected funding history: %+v", rates)
+	}
+
+	forecast, err := provider.FetchFundingForecast(context.Background(), "BTCUSDT")
+	if err != nil {
+		t.Fatalf("FetchFundingForecast: %v", err)
+	}
+	if forecast.Rate != 0.00015 || forecast.Interval != 4*time.Hour || forecast.MarkPrice != 42000.5 {
+		t.Fatalf("unexpected forecast: %+v", forecast)
+	}
+}
+
+func TestBybitFetchCandles(t *testing.T) {
+	// Bybit отдаёт свечи от новых к старым, цены - строками.
+	srv := newTestServer(t, map[string]string{
+		"/v5/market/kline": `{"retCode":0,"retMsg":"OK","result":{"list":[
+			["1704070800000","42100","42300","42000","42250","12.5","0"],
+			["1704067200000","42000","42200","41900","42100","10","0"],
+			["1704063600000","41000","41100","40900","41050","7","0"]
+		]}}`,
+	})
+
+	conn, err := NewByClass("Bybit", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	provider, ok := conn.(CandleProvider)
+	if !ok {
+		t.Fatal("bybit connector must implement CandleProvider")
+	}
+
+	start := time.UnixMilli(1704067200000)
+	end := time.UnixMilli(1704070800000)
+	candles, err := provider.FetchCandles(context.Background(), MarketFutures, "BTCUSDT", "1h", start, end)
+	if err != nil {
+		t.Fatalf("FetchCandles: %v", err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles inside the range, got %+v", candles)
+	}
+	if !candles[0].OpenTime.Equal(start) || candles[0].Open != 42000 || candles[1].Close != 42250 || candles[1].Volume != 12.5 {
+		t.Fatalf("unexpected candles: %+v", candles)
+	}
+
+	if _, err := provider.FetchCandles(context.Background(), MarketFutures, "BTCUSDT", "2h", start, end); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported for unknown interval, got %v", err)
+	}
+}
+
+func TestKucoinFetchTicker(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v1/market/orderbook/level1": `{"code":"200000","data":{"time":1704067200000,"bestBid":"42000.1","bestBidSize":"0.5","bestAsk":"42000.2","bestAskSize":"1.5"}}`,
+		"/api/v1/ticker":                  `{"code":"200000","data":{"symbol":"XBTUSDTM","bestBidPrice":"42001","bestBidSize":10,"bestAskPrice":"42002","bestAskSize":20,"ts":1704067200000000000}}`,
+	})
+
+	conn, err := NewByClass("kucoin", Options{BaseURL: srv.URL, FuturesBaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	provider, ok := conn.(TickerProvider)
+	if !ok {
+		t.Fatal("kucoin connector must implement TickerProvider")
+	}
+
+	spot, err := provider.FetchTicker(context.Background(), MarketSpot, "BTC-USDT")
+	if err != nil {
+		t.Fatalf("FetchTicker spot: %v", err)
+	}
+	if spot.Bid != 42000.1 || spot.Ask != 42000.2 || spot.AskQty != 1.5 || spot.Time.UnixMilli() != 1704067200000 {
+		t.Fatalf("unexpected spot ticker: %+v", spot)
+	}
+
+	futures, err := provider.FetchTicker(context.Background(), MarketFutures, "XBTUSDTM")
+	if err != nil {
+		t.Fatalf("FetchTicker futures: %v", err)
+	}
+	if futures.Bid != 42001 || futures.BidQty != 10 || futures.Mid() != 42001.5 || !futures.Time.Equal(spot.Time) {
+		t.Fatalf("unexpected futures ticker: %+v", futures)
+	}
+}
@@ -0,0 +1,171 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseBytes ограничивает размер ответа биржи, чтобы не держать в памяти мусор.
+const maxResponseBytes = 32 << 20
+
+// getJSON выполняет GET запрос и декодирует JSON ответ в out.
+func getJSON(ctx context.Context, client *http.Client, baseURL, path string, query url.Values, out interface{}) error {
+	return doJSON(ctx, client, http.MethodGet, baseURL, path, query, nil, out)
+}
+
+// doJSON выполняет HTTP запрос с дополнительными заголовками и декодирует JSON ответ.
+func doJSON(ctx context.Context, client *http.Client, method, baseURL, path string, query url.Values, headers map[string]string, out interface{}) error {
+	rawQuery := ""
+	if len(query) > 0 {
+		rawQuery = query.Encode()
+	}
+	status, body, err := doRaw(ctx, client, method, baseURL, path, rawQuery, headers)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("request %s: HTTP %d: %s", path, status, truncate(string(body), 256))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	return nil
+}
+
+// doRaw выполняет HTTP запрос с готовой строкой запроса (подписанные запросы должны
+// отправить ровно ту строку, что подписана) и возвращает код ответа и тело.
+func doRaw(ctx context.Context, client *http.Client, method, baseURL, path, rawQuery string, headers map[string]string) (int, []byte, error) {
+	endpoint := baseURL + path
+	if rawQuery != "" {
+		endpoint += "?" + rawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return 0, nil, fmt.Errorf("read response %s: %w", path, err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// parseFloat разбирает числовое значение из строки ответа биржи (пустая строка = 0).
+func parseFloat(raw string) float64 {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func truncate(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	return value[:limit] + "..."
+}
+
+// parseMillis разбирает unix-время в миллисекундах из строки ответа биржи.
+func parseMillis(raw string) time.Time {
+	ms, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
+// sortFunding сортирует выплаты по времени и убирает дубликаты (страницы могут пересекаться).
+func sortFunding(items []FundingRate) []FundingRate {
+	sort.Slice(items, func(i, j int) bool { return items[i].FundingTime.Before(items[j].FundingTime) })
+	result := items[:0]
+	for i, item := range items {
+		if i > 0 && item.FundingTime.Equal(result[len(result)-1].FundingTime) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// rawFloat разбирает число из элемента массива ответа (биржи отдают и строки, и числа).
+func rawFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		return parseFloat(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	default:
+		return 0
+	}
+}
+
+// rawMillis разбирает unix-время в миллисекундах из элемента массива ответа.
+func rawMillis(value interface{}) time.Time {
+	ms := int64(rawFloat(value))
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
+// sortCandles сортирует свечи по времени, убирает дубликаты и свечи вне [start, end].
+func sortCandles(items []Candle, start, end time.Time) []Candle {
+	sort.Slice(items, func(i, j int) bool { return items[i].OpenTime.Before(items[j].OpenTime) })
+	result := make([]Candle, 0, len(items))
+	for _, item := range items {
+		if item.OpenTime.IsZero() || item.OpenTime.Before(start) || item.OpenTime.After(end) {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].OpenTime.Equal(item.OpenTime) {
+			result[n-1] = item
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// candleLimit - сколько свечей запрашивать для окна [start, end], не больше CandlePageSize.
+func candleLimit(interval string, start, end time.Time) int {
+	step := CandleIntervals[interval]
+	if step <= 0 {
+		return CandlePageSize
+	}
+	count := int(end.Sub(start)/step) + 1
+	if count < 1 {
+		count = 1
+	}
+	if count > CandlePageSize {
+		count = CandlePageSize
+	}
+	return count
+}
@@ -0,0 +1,491 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kucoinDefaultBaseURL    = "https://api.kucoin.com"
+	kucoinDefaultFuturesURL = "https://api-futures.kucoin.com"
+)
+
+// kucoinConnector - коннектор KuCoin. Спот - api.kucoin.com, фьючерсы - api-futures.kucoin.com.
+type kucoinConnector struct {
+	baseURL    string
+	futuresURL string
+	client     *http.Client
+}
+
+func newKucoin(opts Options) *kucoinConnector {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = kucoinDefaultBaseURL
+	}
+	futuresURL := opts.FuturesBaseURL
+	if futuresURL == "" {
+		futuresURL = kucoinDefaultFuturesURL
+		if opts.BaseURL != "" && !strings.Contains(opts.BaseURL, "kucoin.com") {
+			futuresURL = opts.BaseURL
+		}
+	}
+	return &kucoinConnector{baseURL: baseURL, futuresURL: futuresURL, client: opts.HTTPClient}
+}
+
+func (c *kucoinConnector) Name() string { return "kucoin" }
+
+// kucoinResponse - общая обёртка ответов KuCoin.
+type kucoinResponse[T any] struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data T      `json:"data"`
+}
+
+func (c *kucoinConnector) FetchInstruments(ctx context.Context, market string) ([]Instrument, error) {
+	if NormalizeMarket(market) == MarketSpot {
+		return c.fetchSpotInstruments(ctx)
+	}
+	return c.fetchFuturesInstruments(ctx)
+}
+
+func (c *kucoinConnector) fetchSpotInstruments(ctx context.Context) ([]Instrument, error) {
+	type item struct {
+		Symbol         string `json:"symbol"`
+		BaseCurrency   string `json:"baseCurrency"`
+		QuoteCurrency  string `json:"quoteCurrency"`
+		PriceIncrement string `json:"priceIncrement"`
+		BaseIncrement  string `json:"baseIncrement"`
+		BaseMinSize    string `json:"baseMinSize"`
+		EnableTrading  bool   `json:"enableTrading"`
+	}
+
+	var resp kucoinResponse[[]item]
+	if err := getJSON(ctx, c.client, c.baseURL, "/api/v2/symbols", nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin symbols: %s %s", resp.Code, resp.Msg)
+	}
+
+	instruments := make([]Instrument, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		status := InstrumentStatusTrading
+		if !it.EnableTrading {
+			status = InstrumentStatusHalted
+		}
+		instruments = append(instruments, Instrument{
+			Symbol:        it.Symbol,
+			MarketType:    MarketSpot,
+			BaseAsset:     it.BaseCurrency,
+			QuoteAsset:    it.QuoteCurrency,
+			TickSize:      parseFloat(it.PriceIncrement),
+			LotSize:       parseFloat(it.BaseIncrement),
+			MinQty:        parseFloat(it.BaseMinSize),
+			ContractValue: 1,
+			Status:        status,
+		})
+	}
+	return instruments, nil
+}
+
+func (c *kucoinConnector) fetchFuturesInstruments(ctx context.Context) ([]Instrument, error) {
+	type item struct {
+		Symbol         string  `json:"symbol"`
+		BaseCurrency   string  `json:"baseCurrency"`
+		QuoteCurrency  string  `json:"quoteCurrency"`
+		SettleCurrency string  `json:"settleCurrency"`
+		TickSize       float64 `json:"tickSize"`
+		LotSize        float64 `json:"lotSize"`
+		Multiplier     float64 `json:"multiplier"`
+		Status         string  `json:"status"`
+		IsInverse      bool    `json:"isInverse"`
+	}
+
+	var resp kucoinResponse[[]item]
+	if err := getJSON(ctx, c.client, c.futuresURL, "/api/v1/contracts/active", nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin contracts: %s %s", resp.Code, resp.Msg)
+	}
+
+	instruments := make([]Instrument, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		status := InstrumentStatusHalted
+		if strings.EqualFold(it.Status, "Open") {
+			status = InstrumentStatusTrading
+		}
+		multiplier := it.Multiplier
+		if multiplier == 0 {
+			multiplier = 1
+		}
+		instruments = append(instruments, Instrument{
+			Symbol:        it.Symbol,
+			MarketType:    MarketFutures,
+			BaseAsset:     kucoinAsset(it.BaseCurrency),
+			QuoteAsset:    it.QuoteCurrency,
+			TickSize:      it.TickSize,
+			LotSize:       it.LotSize,
+			MinQty:        it.LotSize,
+			ContractValue: multiplier,
+			Status:        status,
+		})
+	}
+	return instruments, nil
+}
+
+// kucoinAsset приводит внутренние тикеры KuCoin Futures к общепринятым (XBT -> BTC).
+func kucoinAsset(asset string) string {
+	if strings.EqualFold(asset, "XBT") {
+		return "BTC"
+	}
+	return asset
+}
+
+// FetchFundingHistory - /api/v1/contract/funding-rates (from/to в миллисекундах).
+func (c *kucoinConnector) FetchFundingHistory(ctx context.Context, symbol string, start, end time.Time) ([]FundingRate, error) {
+	type item struct {
+		Symbol      string  `json:"symbol"`
+		FundingRate float64 `json:"fundingRate"`
+		Timepoint   int64   `json:"timepoint"`
+	}
+
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("from", strconv.FormatInt(start.UnixMilli(), 10))
+	query.Set("to", strconv.FormatInt(end.UnixMilli(), 10))
+
+	var resp kucoinResponse[[]item]
+	if err := getJSON(ctx, c.client, c.futuresURL, "/api/v1/contract/funding-rates", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin funding history: %s %s", resp.Code, resp.Msg)
+	}
+
+	rates := make([]FundingRate, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		rates = append(rates, FundingRate{Symbol: it.Symbol, Rate: it.FundingRate, FundingTime: time.UnixMilli(it.Timepoint).UTC()})
+	}
+	return sortFunding(rates), nil
+}
+
+// FetchFundingForecast - /api/v1/funding-rate/{symbol}/current.
+func (c *kucoinConnector) FetchFundingForecast(ctx context.Context, symbol string) (*FundingForecast, error) {
+	var resp kucoinResponse[struct {
+		Symbol      string  `json:"symbol"`
+		Granularity int64   `json:"granularity"`
+		TimePoint   int64   `json:"timePoint"`
+		Value       float64 `json:"value"`
+	}]
+
+	path := "/api/v1/funding-rate/" + url.PathEscape(symbol) + "/current"
+	if err := getJSON(ctx, c.client, c.futuresURL, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin funding rate: %s %s", resp.Code, resp.Msg)
+	}
+
+	interval := DefaultFundingInterval
+	if resp.Data.Granularity > 0 {
+		interval = time.Duration(resp.Data.Granularity) * time.Millisecond
+	}
+	// timePoint - начало текущего периода, расчёт по ставке value - в его конце.
+	next := time.UnixMilli(resp.Data.TimePoint).UTC().Add(interval)
+	return &FundingForecast{
+		Symbol:          resp.Data.Symbol,
+		Rate:            resp.Data.Value,
+		NextFundingTime: next,
+		Interval:        interval,
+	}, nil
+}
+
+var kucoinSpotCandleIntervals = map[string]string{"1m": "1min", "5m": "5min", "15m": "15min", "1h": "1hour", "4h": "4hour", "1d": "1day"}
+
+// FetchCandles - спот /api/v1/market/candles (секунды, порядок OCHL), фьючерсы /api/v1/kline/query.
+func (c *kucoinConnector) FetchCandles(ctx context.Context, market, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	step, ok := CandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: interval %q", ErrNotSupported, interval)
+	}
+	// Окно ограничивается CandlePageSize свечами: KuCoin не принимает limit.
+	if limit := candleLimit(interval, start, end); end.Sub(start) > step*time.Duration(limit-1) {
+		end = start.Add(step * time.Duration(limit-1))
+	}
+
+	var resp kucoinResponse[[][]interface{}]
+	candles := make([]Candle, 0)
+	if NormalizeMarket(market) == MarketSpot {
+		query := url.Values{}
+		query.Set("symbol", symbol)
+		query.Set("type", kucoinSpotCandleIntervals[interval])
+		query.Set("startAt", strconv.FormatInt(start.Unix(), 10))
+		query.Set("endAt", strconv.FormatInt(end.Unix()+1, 10))
+		if err := getJSON(ctx, c.client, c.baseURL, "/api/v1/market/candles", query, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != "200000" {
+			return nil, fmt.Errorf("kucoin candles: %s %s", resp.Code, resp.Msg)
+		}
+		for _, row := range resp.Data {
+			if len(row) < 6 {
+				continue
+			}
+			candles = append(candles, Candle{
+				OpenTime: time.Unix(int64(rawFloat(row[0])), 0).UTC(),
+				Open:     rawFloat(row[1]),
+				Close:    rawFloat(row[2]),
+				High:     rawFloat(row[3]),
+				Low:      rawFloat(row[4]),
+				Volume:   rawFloat(row[5]),
+			})
+		}
+		return sortCandles(candles, start, end), nil
+	}
+
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("granularity", strconv.Itoa(int(step/time.Minute)))
+	query.Set("from", strconv.FormatInt(start.UnixMilli(), 10))
+	query.Set("to", strconv.FormatInt(end.UnixMilli(), 10))
+	if err := getJSON(ctx, c.client, c.futuresURL, "/api/v1/kline/query", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin kline: %s %s", resp.Code, resp.Msg)
+	}
+	for _, row := range resp.Data {
+		if len(row) < 6 {
+			continue
+		}
+		candles = append(candles, Candle{
+			OpenTime: rawMillis(row[0]),
+			Open:     rawFloat(row[1]),
+			High:     rawFloat(row[2]),
+			Low:      rawFloat(row[3]),
+			Close:    rawFloat(row[4]),
+			Volume:   rawFloat(row[5]),
+		})
+	}
+	return sortCandles(candles, start, end), nil
+}
+
+// FetchTicker - спот /api/v1/market/orderbook/level1, фьючерсы /api/v1/ticker (ts в наносекундах).
+func (c *kucoinConnector) FetchTicker(ctx context.Context, market, symbol string) (*Ticker, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+
+	if NormalizeMarket(market) == MarketSpot {
+		var resp kucoinResponse[*struct {
+			Time        int64  `json:"time"`
+			BestBid     string `json:"bestBid"`
+			BestBidSize string `json:"bestBidSize"`
+			BestAsk     string `json:"bestAsk"`
+			BestAskSize string `json:"bestAskSize"`
+		}]
+		if err := getJSON(ctx, c.client, c.baseURL, "/api/v1/market/orderbook/level1", query, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != "200000" {
+			return nil, fmt.Errorf("kucoin ticker: %s %s", resp.Code, resp.Msg)
+		}
+		if resp.Data == nil {
+			return nil, fmt.Errorf("kucoin ticker: symbol %s not found", symbol)
+		}
+		ticker := &Ticker{
+			Symbol: symbol,
+			Bid:    parseFloat(resp.Data.BestBid),
+			BidQty: parseFloat(resp.Data.BestBidSize),
+			Ask:    parseFloat(resp.Data.BestAsk),
+			AskQty: parseFloat(resp.Data.BestAskSize),
+		}
+		if resp.Data.Time > 0 {
+			ticker.Time = time.UnixMilli(resp.Data.Time).UTC()
+		}
+		return ticker, nil
+	}
+
+	var resp kucoinResponse[*struct {
+		Symbol       string  `json:"symbol"`
+		BestBidPrice string  `json:"bestBidPrice"`
+		BestBidSize  float64 `json:"bestBidSize"`
+		BestAskPrice string  `json:"bestAskPrice"`
+		BestAskSize  float64 `json:"bestAskSize"`
+		TS           int64   `json:"ts"`
+	}]
+	if err := getJSON(ctx, c.client, c.futuresURL, "/api/v1/ticker", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin ticker: %s %s", resp.Code, resp.Msg)
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("kucoin ticker: symbol %s not found", symbol)
+	}
+	ticker := &Ticker{
+		Symbol: resp.Data.Symbol,
+		Bid:    parseFloat(resp.Data.BestBidPrice),
+		BidQty: resp.Data.BestBidSize,
+		Ask:    parseFloat(resp.Data.BestAskPrice),
+		AskQty: resp.Data.BestAskSize,
+	}
+	if resp.Data.TS > 0 {
+		ticker.Time = time.Unix(0, resp.Data.TS).UTC()
+	}
+	return ticker, nil
+}
+
+// signedGet выполняет подписанный GET запрос KuCoin (ключ API v2) и декодирует data в out.
+// Подпись: Base64(HMAC_SHA256(timestamp + method + endpoint)), passphrase тоже подписывается секретом.
+func (c *kucoinConnector) signedGet(ctx context.Context, creds Credentials, baseURL, path string, query url.Values, out interface{}) error {
+	if err := requireCredentials(creds, true); err != nil {
+		return err
+	}
+	rawQuery := query.Encode()
+	endpoint := path
+	if rawQuery != "" {
+		endpoint += "?" + rawQuery
+	}
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	headers := map[string]string{
+		"KC-API-KEY":         creds.APIKey,
+		"KC-API-SIGN":        signBase64(creds.SecretKey, timestamp+http.MethodGet+endpoint),
+		"KC-API-TIMESTAMP":   timestamp,
+		"KC-API-PASSPHRASE":  signBase64(creds.SecretKey, creds.Passphrase),
+		"KC-API-KEY-VERSION": "2",
+	}
+
+	status, body, err := doRaw(ctx, c.client, http.MethodGet, baseURL, path, rawQuery, headers)
+	if err != nil {
+		return err
+	}
+	var envelope kucoinResponse[json.RawMessage]
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if status != http.StatusOK {
+			return &APIError{Exchange: "kucoin", HTTPStatus: status, Code: strconv.Itoa(status), Message: truncate(string(body), 256), Auth: status == http.StatusUnauthorized}
+		}
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	if status != http.StatusOK || envelope.Code != "200000" {
+		apiErr := &APIError{Exchange: "kucoin", HTTPStatus: status, Code: envelope.Code, Message: envelope.Msg}
+		switch envelope.Code {
+		case "400006": // IP не в whitelist ключа
+			apiErr.IPBlocked = true
+		case "400003", "400004", "400005": // ключ, passphrase, подпись
+			apiErr.Auth = true
+		}
+		if status == http.StatusUnauthorized {
+			apiErr.Auth = true
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	return nil
+}
+
+// FetchKeyPermissions читает права ключа (/api/v1/user/api-key).
+func (c *kucoinConnector) FetchKeyPermissions(ctx context.Context, creds Credentials) (*KeyPermissions, error) {
+	var data struct {
+		Permission  string `json:"permission"` // "General,Spot,Futures,Withdraw"
+		IPWhitelist string `json:"ipWhitelist"`
+	}
+	if err := c.signedGet(ctx, creds, c.baseURL, "/api/v1/user/api-key", url.Values{}, &data); err != nil {
+		return nil, err
+	}
+
+	perms := &KeyPermissions{}
+	for _, perm := range strings.Split(data.Permission, ",") {
+		perm = strings.ToLower(strings.TrimSpace(perm))
+		switch {
+		case perm == "general":
+			perms.Read = true
+		case strings.Contains(perm, "withdraw"):
+			perms.Withdraw = true
+		case perm == "spot" || perm == "futures" || perm == "margin":
+			perms.Trade = true
+		}
+	}
+	perms.IPWhitelist = parseIPList(data.IPWhitelist)
+	perms.IPRestricted = len(perms.IPWhitelist) > 0
+	return perms, nil
+}
+
+// FetchBalances читает счета спота (/api/v1/accounts): main, trade, margin.
+// Фьючерсный счёт KuCoin (api-futures) не читается.
+func (c *kucoinConnector) FetchBalances(ctx context.Context, creds Credentials) ([]Balance, error) {
+	var data []struct {
+		Currency  string `json:"currency"`
+		Type      string `json:"type"`
+		Balance   string `json:"balance"`
+		Available string `json:"available"`
+		Holds     string `json:"holds"`
+	}
+	if err := c.signedGet(ctx, creds, c.baseURL, "/api/v1/accounts", url.Values{}, &data); err != nil {
+		return nil, err
+	}
+
+	var balances []Balance
+	for _, it := range data {
+		free, locked := parseFloat(it.Available), parseFloat(it.Holds)
+		if free == 0 && locked == 0 {
+			continue
+		}
+		balances = append(balances, Balance{Wallet: it.Type, Asset: kucoinAsset(it.Currency), Free: free, Locked: locked})
+	}
+	return balances, nil
+}
+
+// FetchCurrencies читает публичный справочник монет (/api/v3/currencies), ключи не нужны.
+func (c *kucoinConnector) FetchCurrencies(ctx context.Context, _ Credentials) ([]Currency, error) {
+	var resp kucoinResponse[[]struct {
+		Currency string `json:"currency"`
+		FullName string `json:"fullName"`
+		Chains   []struct {
+			ChainName         string `json:"chainName"`
+			ChainID           string `json:"chainId"`
+			WithdrawalMinSize string `json:"withdrawalMinSize"`
+			WithdrawalMinFee  string `json:"withdrawalMinFee"`
+			IsWithdrawEnabled bool   `json:"isWithdrawEnabled"`
+			IsDepositEnabled  bool   `json:"isDepositEnabled"`
+			Confirms          int    `json:"confirms"`
+			ContractAddress   string `json:"contractAddress"`
+		} `json:"chains"`
+	}]
+	if err := getJSON(ctx, c.client, c.baseURL, "/api/v3/currencies", nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "200000" {
+		return nil, fmt.Errorf("kucoin currencies: %s %s", resp.Code, resp.Msg)
+	}
+
+	currencies := make([]Currency, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		currency := Currency{Asset: kucoinAsset(it.Currency), Name: it.FullName}
+		for _, ch := range it.Chains {
+			currency.Networks = append(currency.Networks, CurrencyNetwork{
+				Network:         strings.ToUpper(ch.ChainID),
+				Name:            ch.ChainName,
+				ContractAddress: ch.ContractAddress,
+				DepositEnabled:  ch.IsDepositEnabled,
+				WithdrawEnabled: ch.IsWithdrawEnabled,
+				WithdrawFee:     parseFloat(ch.WithdrawalMinFee),
+				WithdrawMin:     parseFloat(ch.WithdrawalMinSize),
+				Confirmations:   ch.Confirms,
+			})
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, nil
+}
@@ -0,0 +1,504 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const okxDefaultBaseURL = "https://www.okx.com"
+
+// okxConnector - коннектор OKX (API v5). Фьючерсы = instType=SWAP (perpetual).
+type okxConnector struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newOKX(opts Options) *okxConnector {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = okxDefaultBaseURL
+	}
+	return &okxConnector{baseURL: baseURL, client: opts.HTTPClient}
+}
+
+func (c *okxConnector) Name() string { return "okx" }
+
+// okxResponse - общая обёртка ответов OKX v5.
+type okxResponse[T any] struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []T    `json:"data"`
+}
+
+func (c *okxConnector) FetchInstruments(ctx context.Context, market string) ([]Instrument, error) {
+	market = NormalizeMarket(market)
+
+	type item struct {
+		InstID   string `json:"instId"`
+		BaseCcy  string `json:"baseCcy"`
+		QuoteCcy string `json:"quoteCcy"`
+		Uly      string `json:"uly"`
+		SettleCy string `json:"settleCcy"`
+		CtVal    string `json:"ctVal"`
+		CtValCcy string `json:"ctValCcy"`
+		TickSz   string `json:"tickSz"`
+		LotSz    string `json:"lotSz"`
+		MinSz    string `json:"minSz"`
+		State    string `json:"state"`
+	}
+
+	instType := "SPOT"
+	if market == MarketFutures {
+		instType = "SWAP"
+	}
+
+	query := url.Values{}
+	query.Set("instType", instType)
+
+	var resp okxResponse[item]
+	if err := getJSON(ctx, c.client, c.baseURL, "/api/v5/public/instruments", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx instruments: %s %s", resp.Code, resp.Msg)
+	}
+
+	instruments := make([]Instrument, 0, len(resp.Data))
+	for _, it := range resp.Data {
+		inst := Instrument{
+			Symbol:        it.InstID,
+			MarketType:    market,
+			BaseAsset:     it.BaseCcy,
+			QuoteAsset:    it.QuoteCcy,
+			TickSize:      parseFloat(it.TickSz),
+			LotSize:       parseFloat(it.LotSz),
+			MinQty:        parseFloat(it.MinSz),
+			ContractValue: 1,
+			Status:        okxStatus(it.State),
+		}
+		if market == MarketFutures {
+			// Для SWAP базовая/котируемая валюта берутся из базового актива (uly = "BTC-USDT").
+			if parts := strings.SplitN(it.Uly, "-", 2); len(parts) == 2 {
+				inst.BaseAsset, inst.QuoteAsset = parts[0], parts[1]
+			}
+			if it.SettleCy != "" {
+				inst.QuoteAsset = it.SettleCy
+			}
+			if v := parseFloat(it.CtVal); v > 0 {
+				inst.ContractValue = v
+			}
+		}
+		instruments = append(instruments, inst)
+	}
+
+	return instruments, nil
+}
+
+func okxStatus(state string) string {
+	switch strings.ToLower(state) {
+	case "live":
+		return InstrumentStatusTrading
+	case "suspend", "preopen", "test":
+		return InstrumentStatusHalted
+	default:
+		return InstrumentStatusDelisted
+	}
+}
+
+// FetchFundingHistory - /api/v5/public/funding-rate-history (до 100 записей, от новых к старым,
+// постраничный переход через after = время самой старой записи).
+func (c *okxConnector) FetchFundingHistory(ctx context.Context, symbol string, start, end time.Time) ([]FundingRate, error) {
+	type item struct {
+		InstID       string `json:"instId"`
+		FundingRate  string `json:"fundingRate"`
+		RealizedRate string `json:"realizedRate"`
+		FundingTime  string `json:"fundingTime"`
+	}
+
+	var rates []FundingRate
+	after := end.Add(time.Millisecond)
+	for page := 0; page < 100; page++ {
+		query := url.Values{}
+		query.Set("instId", symbol)
+		query.Set("after", strconv.FormatInt(after.UnixMilli(), 10))
+		query.Set("limit", "100")
+
+		var resp okxResponse[item]
+		if err := getJSON(ctx, c.client, c.baseURL, "/api/v5/public/funding-rate-history", query, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Code != "0" {
+			return nil, fmt.Errorf("okx funding history: %s %s", resp.Code, resp.Msg)
+		}
+		if len(resp.Data) == 0 {
+			break
+		}
+
+		oldest := after
+		for _, it := range resp.Data {
+			ts := parseMillis(it.FundingTime)
+			if ts.IsZero() {
+				continue
+			}
+			if ts.Before(oldest) {
+				oldest = ts
+			}
+			if ts.Before(start) || ts.After(end) {
+				continue
+			}
+			rate := it.RealizedRate
+			if rate == "" {
+				rate = it.FundingRate
+			}
+			rates = append(rates, FundingRate{Symbol: it.InstID, Rate: parseFloat(rate), FundingTime: ts})
+		}
+		if len(resp.Data) < 100 || !oldest.After(start) || !oldest.Before(after) {
+			break
+		}
+		after = oldest
+	}
+
+	return sortFunding(rates), nil
+}
+
+// FetchFundingForecast - /api/v5/public/funding-rate.
+func (c *okxConnector) FetchFundingForecast(ctx context.Context, symbol string) (*FundingForecast, error) {
+	type item struct {
+		InstID          string `json:"instId"`
+		FundingRate     string `json:"fundingRate"`
+		FundingTime     string `json:"fundingTime"`
+		NextFundingTime string `json:"nextFundingTime"`
+	}
+
+	query := url.Values{}
+	query.Set("instId", symbol)
+
+	var resp okxResponse[item]
+	if err := getJSON(ctx, c.client, c.baseURL, "/api/v5/public/funding-rate", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx funding rate: %s %s", resp.Code, resp.Msg)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("okx funding rate: symbol %s not found", symbol)
+	}
+
+	it := resp.Data[0]
+	// fundingTime - ближайший расчёт по текущей ставке, nextFundingTime - следующий за ним.
+	current := parseMillis(it.FundingTime)
+	next := parseMillis(it.NextFundingTime)
+	interval := DefaultFundingInterval
+	if !current.IsZero() && next.After(current) {
+		interval = next.Sub(current)
+	}
+	return &FundingForecast{
+		Symbol:          it.InstID,
+		Rate:            parseFloat(it.FundingRate),
+		NextFundingTime: current,
+		Interval:        interval,
+	}, nil
+}
+
+var okxCandleIntervals = map[string]string{"1m": "1m", "5m": "5m", "15m": "15m", "1h": "1H", "4h": "4H", "1d": "1Dutc"}
+
+// FetchCandles - /api/v5/market/history-candles (от новых к старым, after/before - исключающие границы).
+func (c *okxConnector) FetchCandles(ctx context.Context, market, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	bar, ok := okxCandleIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: interval %q", ErrNotSupported, interval)
+	}
+
+	query := url.Values{}
+	query.Set("instId", symbol)
+	query.Set("bar", bar)
+	query.Set("after", strconv.FormatInt(end.UnixMilli()+1, 10))
+	query.Set("before", strconv.FormatInt(start.UnixMilli()-1, 10))
+	query.Set("limit", strconv.Itoa(candleLimit(interval, start, end)))
+
+	var resp okxResponse[[]string]
+	if err := getJSON(ctx, c.client, c.baseURL, "/api/v5/market/history-candles", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx candles: %s %s", resp.Code, resp.Msg)
+	}
+
+	candles := make([]Candle, 0, len(resp.Data))
+	for _, row := range resp.Data {
+		if len(row) < 6 {
+			continue
+		}
+		candles = append(candles, Candle{
+			OpenTime: parseMillis(row[0]),
+			Open:     parseFloat(row[1]),
+			High:     parseFloat(row[2]),
+			Low:      parseFloat(row[3]),
+			Close:    parseFloat(row[4]),
+			Volume:   parseFloat(row[5]),
+		})
+	}
+	return sortCandles(candles, start, end), nil
+}
+
+// FetchTicker - /api/v5/market/ticker (спот и swap различаются только instId).
+func (c *okxConnector) FetchTicker(ctx context.Context, market, symbol string) (*Ticker, error) {
+	type item struct {
+		InstID string `json:"instId"`
+		BidPx  string `json:"bidPx"`
+		BidSz  string `json:"bidSz"`
+		AskPx  string `json:"askPx"`
+		AskSz  string `json:"askSz"`
+		TS     string `json:"ts"`
+	}
+
+	query := url.Values{}
+	query.Set("instId", symbol)
+
+	var resp okxResponse[item]
+	if err := getJSON(ctx, c.client, c.baseURL, "/api/v5/market/ticker", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("okx ticker: %s %s", resp.Code, resp.Msg)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("okx ticker: symbol %s not found", symbol)
+	}
+
+	it := resp.Data[0]
+	return &Ticker{
+		Symbol: it.InstID,
+		Bid:    parseFloat(it.BidPx),
+		BidQty: parseFloat(it.BidSz),
+		Ask:    parseFloat(it.AskPx),
+		AskQty: parseFloat(it.AskSz),
+		Time:   parseMillis(it.TS),
+	}, nil
+}
+
+// signedGet выполняет подписанный GET запрос OKX v5 и декодирует data в out.
+// Подпись: Base64(HMAC_SHA256(timestamp + method + requestPath)), requestPath включает query.
+func (c *okxConnector) signedGet(ctx context.Context, creds Credentials, path string, query url.Values, out interface{}) error {
+	if err := requireCredentials(creds, true); err != nil {
+		return err
+	}
+	rawQuery := query.Encode()
+	requestPath := path
+	if rawQuery != "" {
+		requestPath += "?" + rawQuery
+	}
+	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	headers := map[string]string{
+		"OK-ACCESS-KEY":        creds.APIKey,
+		"OK-ACCESS-SIGN":       signBase64(creds.SecretKey, timestamp+http.MethodGet+requestPath),
+		"OK-ACCESS-TIMESTAMP":  timestamp,
+		"OK-ACCESS-PASSPHRASE": creds.Passphrase,
+	}
+
+	status, body, err := doRaw(ctx, c.client, http.MethodGet, c.baseURL, path, rawQuery, headers)
+	if err != nil {
+		return err
+	}
+	// data остаётся сырым массивом: его декодирует вызывающий код.
+	var envelope struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if status != http.StatusOK {
+			return &APIError{Exchange: "okx", HTTPStatus: status, Code: strconv.Itoa(status), Message: truncate(string(body), 256), Auth: status == http.StatusUnauthorized}
+		}
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	if status != http.StatusOK || envelope.Code != "0" {
+		apiErr := &APIError{Exchange: "okx", HTTPStatus: status, Code: envelope.Code, Message: envelope.Msg}
+		switch envelope.Code {
+		case "50110": // IP не в whitelist ключа
+			apiErr.IPBlocked = true
+		case "50100", "50105", "50111", "50112", "50113": // ключ заморожен, passphrase, ключ, timestamp, подпись
+			apiErr.Auth = true
+		}
+		if status == http.StatusUnauthorized {
+			apiErr.Auth = true
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("decode response %s: %w", path, err)
+	}
+	return nil
+}
+
+// FetchKeyPermissions читает права ключа из конфигурации аккаунта (/api/v5/account/config).
+func (c *okxConnector) FetchKeyPermissions(ctx context.Context, creds Credentials) (*KeyPermissions, error) {
+	type item struct {
+		Perm string `json:"perm"` // "read_only,trade,withdraw"
+		IP   string `json:"ip"`
+	}
+	var data []item
+	if err := c.signedGet(ctx, creds, "/api/v5/account/config", url.Values{}, &data); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("okx account config: empty response")
+	}
+
+	perms := &KeyPermissions{}
+	for _, perm := range strings.Split(data[0].Perm, ",") {
+		switch strings.TrimSpace(perm) {
+		case "read_only":
+			perms.Read = true
+		case "trade":
+			perms.Trade = true
+		case "withdraw":
+			perms.Withdraw = true
+		}
+	}
+	// Любой действующий ключ OKX даёт чтение.
+	perms.Read = true
+	perms.IPWhitelist = parseIPList(data[0].IP)
+	perms.IPRestricted = len(perms.IPWhitelist) > 0
+	return perms, nil
+}
+
+// FetchBalances читает торговый аккаунт (/api/v5/account/balance).
+func (c *okxConnector) FetchBalances(ctx context.Context, creds Credentials) ([]Balance, error) {
+	type item struct {
+		Details []struct {
+			Ccy       string `json:"ccy"`
+			CashBal   string `json:"cashBal"`
+			AvailBal  string `json:"availBal"`
+			FrozenBal string `json:"frozenBal"`
+			EqUsd     string `json:"eqUsd"`
+		} `json:"details"`
+	}
+	var data []item
+	if err := c.signedGet(ctx, creds, "/api/v5/account/balance", url.Values{}, &data); err != nil {
+		return nil, err
+	}
+
+	var balances []Balance
+	for _, account := range data {
+		for _, d := range account.Details {
+			free, locked := parseFloat(d.AvailBal), parseFloat(d.FrozenBal)
+			if free == 0 && locked == 0 {
+				free = parseFloat(d.CashBal)
+			}
+			if free == 0 && locked == 0 {
+				continue
+			}
+			balances = append(balances, Balance{
+				Wallet:   "trading",
+				Asset:    d.Ccy,
+				Free:     free,
+				Locked:   locked,
+				USDValue: parseFloat(d.EqUsd),
+			})
+		}
+	}
+	return balances, nil
+}
+
+// FetchPositions читает позиции SWAP (/api/v5/account/positions). OKX отдаёт размер
+// в контрактах, поэтому он переводится в базовую валюту по ctVal из справочника инструментов.
+func (c *okxConnector) FetchPositions(ctx context.Context, creds Credentials) ([]LivePosition, error) {
+	type item struct {
+		InstID  string `json:"instId"`
+		Pos     string `json:"pos"`
+		PosSide string `json:"posSide"` // net, long, short
+		AvgPx   string `json:"avgPx"`
+	}
+	query := url.Values{}
+	query.Set("instType", "SWAP")
+	var data []item
+	if err := c.signedGet(ctx, creds, "/api/v5/account/positions", query, &data); err != nil {
+		return nil, err
+	}
+
+	var positions []LivePosition
+	var contractValues map[string]float64
+	for _, it := range data {
+		size := parseFloat(it.Pos)
+		if size == 0 {
+			continue
+		}
+		if strings.EqualFold(it.PosSide, "short") && size > 0 {
+			size = -size
+		}
+		if contractValues == nil {
+			instruments, err := c.FetchInstruments(ctx, MarketFutures)
+			if err != nil {
+				return nil, fmt.Errorf("load contract values: %w", err)
+			}
+			contractValues = make(map[string]float64, len(instruments))
+			for _, inst := range instruments {
+				contractValues[inst.Symbol] = inst.ContractValue
+			}
+		}
+		if ctVal := contractValues[it.InstID]; ctVal > 0 {
+			size *= ctVal
+		}
+		positions = append(positions, LivePosition{Symbol: it.InstID, Size: size, EntryPrice: parseFloat(it.AvgPx)})
+	}
+	return positions, nil
+}
+
+// FetchCurrencies читает справочник монет (/api/v5/asset/currencies). OKX отдаёт
+// по строке на каждую сеть (chain вида USDT-ERC20), строки группируются по монете.
+func (c *okxConnector) FetchCurrencies(ctx context.Context, creds Credentials) ([]Currency, error) {
+	type item struct {
+		Ccy                  string `json:"ccy"`
+		Name                 string `json:"name"`
+		Chain                string `json:"chain"`
+		CanDep               bool   `json:"canDep"`
+		CanWd                bool   `json:"canWd"`
+		Fee                  string `json:"fee"`
+		MinFee               string `json:"minFee"`
+		MinWd                string `json:"minWd"`
+		MinDepArrivalConfirm string `json:"minDepArrivalConfirm"`
+		CtAddr               string `json:"ctAddr"`
+	}
+	var data []item
+	if err := c.signedGet(ctx, creds, "/api/v5/asset/currencies", url.Values{}, &data); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var currencies []Currency
+	for _, it := range data {
+		pos, ok := index[it.Ccy]
+		if !ok {
+			pos = len(currencies)
+			index[it.Ccy] = pos
+			currencies = append(currencies, Currency{Asset: it.Ccy, Name: it.Name})
+		}
+		fee := it.Fee
+		if fee == "" {
+			fee = it.MinFee
+		}
+		network := strings.TrimPrefix(it.Chain, it.Ccy+"-")
+		confirmations, _ := strconv.Atoi(it.MinDepArrivalConfirm)
+		currencies[pos].Networks = append(currencies[pos].Networks, CurrencyNetwork{
+			Network:         network,
+			Name:            it.Chain,
+			ContractAddress: it.CtAddr,
+			DepositEnabled:  it.CanDep,
+			WithdrawEnabled: it.CanWd,
+			WithdrawFee:     parseFloat(fee),
+			WithdrawMin:     parseFloat(it.MinWd),
+			Confirmations:   confirmations,
+		})
+	}
+	return currencies, nil
+}
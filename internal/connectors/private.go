@@ -0,0 +1,147 @@
+package connectors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Credentials - ключи API аккаунта биржи (EXCHANGE_ACCOUNTS).
+type Credentials struct {
+	APIKey     string
+	SecretKey  string
+	Passphrase string // ADD_KEY: passphrase OKX и KuCoin
+}
+
+// APIError - отказ биржи на приватный запрос (неверный ключ, подпись, IP вне whitelist и т.д.).
+type APIError struct {
+	Exchange   string
+	HTTPStatus int
+	Code       string
+	Message    string
+	Auth       bool // Биржа не приняла ключ, подпись или passphrase
+	IPBlocked  bool // Запрос с IP, которого нет в whitelist ключа
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.Exchange, e.Code, e.Message)
+}
+
+// KeyPermissions - права ключа API по данным биржи.
+type KeyPermissions struct {
+	Read         bool
+	Trade        bool
+	Withdraw     bool
+	IPRestricted bool     // Ключ привязан к списку IP
+	IPWhitelist  []string // Список IP, если биржа его отдаёт
+}
+
+// PermissionProvider - коннектор умеет проверять ключ API и его права.
+//
+// Если биржа отклонила ключ, возвращается *APIError (см. Auth и IPBlocked).
+type PermissionProvider interface {
+	FetchKeyPermissions(ctx context.Context, creds Credentials) (*KeyPermissions, error)
+}
+
+// Balance - остаток актива на одном из кошельков аккаунта.
+type Balance struct {
+	Wallet   string // Кошелёк/тип счёта биржи: spot, futures, unified, trading, main, trade...
+	Asset    string
+	Free     float64
+	Locked   float64
+	USDValue float64 // Оценка биржи в USD (0 - биржа не отдаёт оценку)
+}
+
+// Total - полный остаток актива (свободный + заблокированный).
+func (b Balance) Total() float64 {
+	return b.Free + b.Locked
+}
+
+// BalanceProvider - коннектор умеет читать балансы аккаунта (ненулевые остатки по всем
+// поддерживаемым кошелькам). Ошибки доступа возвращаются как *APIError.
+type BalanceProvider interface {
+	FetchBalances(ctx context.Context, creds Credentials) ([]Balance, error)
+}
+
+// LivePosition - открытая позиция по бессрочному контракту на бирже.
+type LivePosition struct {
+	Symbol     string
+	Size       float64 // В базовой валюте, со знаком: > 0 - long, < 0 - short
+	EntryPrice float64
+}
+
+// PositionProvider - коннектор умеет читать открытые фьючерсные позиции аккаунта.
+// В режиме hedge одна пара может вернуться двумя записями (long и short).
+type PositionProvider interface {
+	FetchPositions(ctx context.Context, creds Credentials) ([]LivePosition, error)
+}
+
+// CurrencyNetwork - сеть (блокчейн) ввода и вывода монеты на бирже.
+type CurrencyNetwork struct {
+	Network         string // Код сети на бирже: ETH, TRX, BSC, ERC20...
+	Name            string
+	ContractAddress string
+	DepositEnabled  bool
+	WithdrawEnabled bool
+	WithdrawFee     float64
+	WithdrawMin     float64
+	Confirmations   int
+}
+
+// Currency - монета биржи с сетями ввода и вывода.
+type Currency struct {
+	Asset    string
+	Name     string
+	Networks []CurrencyNetwork
+}
+
+// CurrencyProvider - коннектор умеет читать справочник монет биржи.
+// У большинства бирж справочник приватный, поэтому нужны ключи; KuCoin их не требует.
+type CurrencyProvider interface {
+	FetchCurrencies(ctx context.Context, creds Credentials) ([]Currency, error)
+}
+
+func hmacSHA256(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// signHex - HMAC-SHA256 в hex (Binance, Bybit).
+func signHex(secret, payload string) string {
+	return hex.EncodeToString(hmacSHA256(secret, payload))
+}
+
+// signBase64 - HMAC-SHA256 в base64 (OKX, KuCoin).
+func signBase64(secret, payload string) string {
+	return base64.StdEncoding.EncodeToString(hmacSHA256(secret, payload))
+}
+
+// requireCredentials проверяет, что заданы ключи, без которых подпись невозможна.
+func requireCredentials(creds Credentials, passphrase bool) error {
+	if strings.TrimSpace(creds.APIKey) == "" || strings.TrimSpace(creds.SecretKey) == "" {
+		return fmt.Errorf("api key and secret key are required")
+	}
+	if passphrase && strings.TrimSpace(creds.Passphrase) == "" {
+		return fmt.Errorf("passphrase (additional key) is required")
+	}
+	return nil
+}
+
+// parseIPList разбирает список IP из строки через запятую; "*" и пустые значения отбрасываются.
+func parseIPList(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		for _, ip := range strings.Split(value, ",") {
+			ip = strings.TrimSpace(ip)
+			if ip != "" && ip != "*" {
+				result = append(result, ip)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,186 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBinanceFetchKeyPermissions(t *testing.T) {
+	var gotKey, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sapi/v1/account/apiRestrictions" {
+			http.NotFound(w, r)
+			return
+		}
+		gotKey, gotQuery = r.Header.Get("X-MBX-APIKEY"), r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"ipRestrict":true,"enableReading":true,"enableWithdrawals":true,
+			"enableSpotAndMarginTrading":false,"enableFutures":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := NewByClass("binance", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	perms, err := conn.(PermissionProvider).FetchKeyPermissions(context.Background(), Credentials{APIKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("FetchKeyPermissions: %v", err)
+	}
+	if !perms.Read || !perms.Trade || !perms.Withdraw || !perms.IPRestricted {
+		t.Fatalf("unexpected permissions: %+v", perms)
+	}
+	if gotKey != "key" {
+		t.Fatalf("expected api key header, got %q", gotKey)
+	}
+	// Подпись - последний параметр и считается от строки запроса без неё.
+	idx := len(gotQuery) - len("&signature=") - 64
+	if idx < 0 || gotQuery[idx:idx+len("&signature=")] != "&signature=" {
+		t.Fatalf("signature is not the last parameter: %s", gotQuery)
+	}
+	if want := signHex("secret", gotQuery[:idx]); gotQuery[idx+len("&signature="):] != want {
+		t.Fatalf("bad signature in %s", gotQuery)
+	}
+}
+
+func TestOKXFetchKeyPermissionsInvalidKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":"50111","msg":"Invalid OK-ACCESS-KEY","data":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := NewByClass("OKX", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	_, err = conn.(PermissionProvider).FetchKeyPermissions(context.Background(), Credentials{APIKey: "key", SecretKey: "secret", Passphrase: "pass"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Auth || apiErr.IPBlocked || apiErr.Code != "50111" {
+		t.Fatalf("expected auth APIError, got %v", err)
+	}
+}
+
+func TestBybitFetchKeyPermissionsIPBlocked(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/v5/user/query-api": `{"retCode":10010,"retMsg":"Unmatched IP, please check your API key's bound IP addresses.","result":{}}`,
+	})
+
+	conn, err := NewByClass("Bybit", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	_, err = conn.(PermissionProvider).FetchKeyPermissions(context.Background(), Credentials{APIKey: "key", SecretKey: "secret"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.IPBlocked {
+		t.Fatalf("expected IP blocked APIError, got %v", err)
+	}
+}
+
+func TestKucoinFetchKeyPermissions(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v1/user/api-key": `{"code":"200000","data":{"permission":"General,Spot","ipWhitelist":"1.2.3.4,5.6.7.8"}}`,
+	})
+
+	conn, err := NewByClass("kucoin", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	if _, err := conn.(PermissionProvider).FetchKeyPermissions(context.Background(), Credentials{APIKey: "key", SecretKey: "secret"}); err == nil {
+		t.Fatalf("expected error without passphrase")
+	}
+	perms, err := conn.(PermissionProvider).FetchKeyPermissions(context.Background(), Credentials{APIKey: "key", SecretKey: "secret", Passphrase: "pass"})
+	if err != nil {
+		t.Fatalf("FetchKeyPermissions: %v", err)
+	}
+	if !perms.Read || !perms.Trade || perms.Withdraw || !perms.IPRestricted || len(perms.IPWhitelist) != 2 {
+		t.Fatalf("unexpected permissions: %+v", perms)
+	}
+}
+
+func TestBinanceFetchBalancesWithoutFutures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/account":
+			_, _ = w.Write([]byte(`{"balances":[
+				{"asset":"BTC","free":"0.5","locked":"0.1"},
+				{"asset":"ETH","free":"0","locked":"0"}
+			]}`))
+		case "/fapi/v2/balance":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code":-2015,"msg":"Invalid API-key, IP, or permissions for action."}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := NewByClass("binance", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	balances, err := conn.(BalanceProvider).FetchBalances(context.Background(), Credentials{APIKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("FetchBalances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Asset != "BTC" || balances[0].Wallet != MarketSpot || balances[0].Free != 0.5 || balances[0].Locked != 0.1 {
+		t.Fatalf("unexpected balances: %+v", balances)
+	}
+}
+
+func TestBybitFetchPositions(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/v5/position/list": `{"retCode":0,"retMsg":"OK","result":{"list":[
+			{"symbol":"BTCUSDT","side":"Buy","size":"0.5","avgPrice":"60000"},
+			{"symbol":"ETHUSDT","side":"Sell","size":"2","avgPrice":"3000.5"},
+			{"symbol":"SOLUSDT","side":"","size":"0","avgPrice":"0"}
+		],"nextPageCursor":""}}`,
+	})
+
+	conn, err := NewByClass("Bybit", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	positions, err := conn.(PositionProvider).FetchPositions(context.Background(), Credentials{APIKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("FetchPositions: %v", err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("expected 2 positions, got %+v", positions)
+	}
+	if positions[0].Symbol != "BTCUSDT" || positions[0].Size != 0.5 || positions[0].EntryPrice != 60000 {
+		t.Fatalf("unexpected long position: %+v", positions[0])
+	}
+	if positions[1].Symbol != "ETHUSDT" || positions[1].Size != -2 || positions[1].EntryPrice != 3000.5 {
+		t.Fatalf("unexpected short position: %+v", positions[1])
+	}
+}
+
+func TestKucoinFetchCurrencies(t *testing.T) {
+	srv := newTestServer(t, map[string]string{
+		"/api/v3/currencies": `{"code":"200000","data":[{"currency":"XBT","fullName":"Bitcoin","chains":[{"chainName":"BTC","chainId":"btc","withdrawalMinSize":"0.0008","withdrawalMinFee":"0.0005","isWithdrawEnabled":true,"isDepositEnabled":false,"confirms":2,"contractAddress":""}]}]}`,
+	})
+
+	conn, err := NewByClass("kucoin", Options{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewByClass: %v", err)
+	}
+	provider, ok := conn.(CurrencyProvider)
+	if !ok {
+		t.Fatal("kucoin connector must implement CurrencyProvider")
+	}
+
+	currencies, err := provider.FetchCurrencies(context.Background(), Credentials{})
+	if err != nil {
+		t.Fatalf("FetchCurrencies: %v", err)
+	}
+	if len(currencies) != 1 || currencies[0].Asset != "BTC" || len(currencies[0].Networks) != 1 {
+		t.Fatalf("unexpected currencies: %+v", currencies)
+	}
+	n := currencies[0].Networks[0]
+	if n.Network != "BTC" || n.DepositEnabled || !n.WithdrawEnabled || n.WithdrawFee != 0.0005 || n.WithdrawMin != 0.0008 || n.Confirmations != 2 {
+		t.Fatalf("unexpected network: %+v", n)
+	}
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"ctweb/internal/services"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertController - правила уведомлений по позициям и инструментам (/alerts/)
+// и история их срабатываний.
+type AlertController struct {
+	service *services.AlertService
+}
+
+// NewAlertController создаёт новый экземпляр AlertController.
+func NewAlertController() *AlertController {
+	return &AlertController{
+		service: services.NewAlertService(),
+	}
+}
+
+// List отображает правила пользователя и форму добавления.
+func (ac *AlertController) List(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	exchanges, _ := repositories.NewExchangeRepository().FindAllActive()
+	sort.Slice(exchanges, func(i, j int) bool {
+		return exchanges[i].Name < exchanges[j].Name
+	})
+	positions, err := ac.service.OpenPositions(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get open positions for alerts")
+	}
+
+	c.HTML(http.StatusOK, "alerts/index.html", gin.H{
+		"Title":     "Alerts",
+		"User":      user,
+		"Exchanges": exchanges,
+		"Markets":   []string{connectors.MarketSpot, connectors.MarketFutures},
+		"Positions": positions,
+		"Channels":  ac.service.Channels(),
+	})
+}
+
+// AjaxGetRules отдаёт правила пользователя с результатом последней проверки и последние срабатывания.
+func (ac *AlertController) AjaxGetRules(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	rules, err := ac.service.ListRules(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get alert rules")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to load alert rules"})
+		return
+	}
+	events, err := ac.service.ListEvents(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get alert events")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to load alert events"})
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.In(loc).Format("2006-01-02 15:04:05")
+	}
+	rows := make([]gin.H, 0, len(rules))
+	for _, r := range rules {
+		rows = append(rows, gin.H{
+			"id":             r.ID,
+			"type":           r.Type,
+			"position_id":    r.PositionID,
+			"exchange":       r.Exchange,
+			"market":         r.MarketType,
+			"symbol":         r.Symbol,
+			"direction":      r.Direction,
+			"threshold":      r.Threshold,
+			"channel":        r.Channel,
+			"target":         r.Target,
+			"is_active":      r.IsActive,
+			"is_triggered":   r.IsTriggered,
+			"last_value":     r.LastValue,
+			"date_checked":   formatTime(r.DateChecked),
+			"date_triggered": formatTime(r.DateTriggered),
+			"last_error":     r.LastError,
+		})
+	}
+	eventRows := make([]gin.H, 0, len(events))
+	for _, e := range events {
+		eventRows = append(eventRows, gin.H{
+			"rule_id":   e.RuleID,
+			"date":      formatTime(&e.DateCreate),
+			"subject":   e.Subject,
+			"message":   e.Message,
+			"delivered": e.Delivered,
+			"error":     e.Error,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": rows, "events": eventRows})
+}
+
+// AjaxCreateRule добавляет правило: type, position_id или exchange_id + market + symbol,
+// direction и threshold (уровень цены или допустимый убыток), channel, target.
+func (ac *AlertController) AjaxCreateRule(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	positionID, _ := strconv.Atoi(c.PostForm("position_id"))
+	exchangeID, _ := strconv.Atoi(c.PostForm("exchange_id"))
+	threshold, _ := strconv.ParseFloat(c.PostForm("threshold"), 64)
+	rule, err := ac.service.CreateRule(user.ID, services.AlertRuleInput{
+		Type:       c.PostForm("type"),
+		PositionID: positionID,
+		ExchangeID: exchangeID,
+		Market:     c.PostForm("market"),
+		Symbol:     c.PostForm("symbol"),
+		Direction:  c.PostForm("direction"),
+		Threshold:  threshold,
+		Channel:    c.PostForm("channel"),
+		Target:     c.PostForm("target"),
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "id": rule.ID})
+}
+
+// AjaxEditRule включает (active=1) или приостанавливает (active=0) правило.
+func (ac *AlertController) AjaxEditRule(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := ac.service.SetRuleActive(user.ID, id, c.PostForm("active") == "1"); err != nil {
+		ac.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// AjaxDeleteRule удаляет правило.
+func (ac *AlertController) AjaxDeleteRule(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := ac.service.DeleteRule(user.ID, id); err != nil {
+		ac.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// AjaxTestRule отправляет пробное уведомление по каналу правила.
+func (ac *AlertController) AjaxTestRule(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := ac.service.SendTest(c.Request.Context(), user.ID, id); err != nil {
+		ac.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+func (ac *AlertController) respondError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrAlertRuleNotFound) {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "alert rule not found"})
+		return
+	}
+	logger.Error().Err(err).Msg("alert request failed")
+	c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/services"
+	"ctweb/internal/utils"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CoinController - справочник монет: страница администратора (/coins/) и JSON для других страниц.
+type CoinController struct {
+	service *services.CoinService
+}
+
+// NewCoinController создаёт новый экземпляр CoinController.
+func NewCoinController() *CoinController {
+	return &CoinController{
+		service: services.NewCoinService(),
+	}
+}
+
+// List отображает страницу справочника монет (только для администратора).
+func (cc *CoinController) List(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	c.HTML(http.StatusOK, "coins/index.html", gin.H{
+		"Title": "Coins",
+		"User":  user,
+	})
+}
+
+// AjaxGetCoins отдаёт справочник монет для DataTables.
+func (cc *CoinController) AjaxGetCoins(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+	req := utils.ParseDataTablesRequest(c)
+	resp, err := cc.service.List(utils.ConvertToExchangeRepositoryRequest(req))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get coins")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load coins"})
+		return
+	}
+
+	rows := make([]gin.H, 0, len(resp.Data))
+	for _, coin := range resp.Data {
+		icon := ""
+		if coin.Icon != nil && *coin.Icon != "" {
+			icon = "/assets/" + *coin.Icon
+		}
+		rows = append(rows, gin.H{
+			"id":                coin.ID,
+			"icon":              icon,
+			"symbol":            coin.Symbol,
+			"name":              coin.Name,
+			"exchanges":         coin.Exchanges,
+			"networks":          coin.Networks,
+			"deposit_networks":  coin.DepositNetworks,
+			"withdraw_networks": coin.WithdrawNetworks,
+			"date_sync":         coin.DateSync.Format("2006-01-02 15:04:05"),
+		})
+	}
+	c.JSON(http.StatusOK, utils.DataTablesResponse{
+		Draw:            req.Draw,
+		RecordsTotal:    resp.RecordsTotal,
+		RecordsFiltered: resp.RecordsFiltered,
+		AAData:          rows,
+	})
+}
+
+// AjaxUpdateCoins синхронизирует справочник монет со всеми активными биржами.
+// В data отдаётся HTML-сводка по биржам для блока #result_update_coins.
+func (cc *CoinController) AjaxUpdateCoins(c *gin.Context) {
+	user, ok := requireAdminJSON(c)
+	if !ok {
+		return
+	}
+	if c.PostForm("action") != "update" {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "unknown action"})
+		return
+	}
+
+	results, err := cc.service.Sync(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var summary strings.Builder
+	for _, r := range results {
+		name := template.HTMLEscapeString(r.ExchangeName)
+		if r.Error != "" {
+			fmt.Fprintf(&summary, `<div class="text-danger">%s: %s</div>`, name, template.HTMLEscapeString(r.Error))
+			continue
+		}
+		fmt.Fprintf(&summary, `<div>%s: %d coins, %d networks</div>`, name, r.Coins, r.Networks)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": summary.String(), "results": results})
+}
+
+// AjaxGenerateIcons загружает недостающие иконки монет в локальный набор.
+func (cc *CoinController) AjaxGenerateIcons(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+	loaded, missing, err := cc.service.GenerateIcons(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error(), "loaded": loaded, "missing": missing})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "loaded": loaded, "missing": missing})
+}
+
+// AjaxCoinInfo отдаёт монеты с иконками и сетями по списку тикеров (symbols через запятую).
+// Доступно всем пользователям: используется страницами позиций и анализа рынка.
+func (cc *CoinController) AjaxCoinInfo(c *gin.Context) {
+	if _, exists := c.Get("user"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	coins, err := cc.service.Info(strings.Split(c.PostForm("symbols"), ","))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": coins})
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"ctweb/internal/models"
+	"ctweb/internal/services"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DaemonController - страница управления процессом-обработчиком (/daemon/, только для администратора).
+type DaemonController struct {
+	service *services.DaemonService
+}
+
+// NewDaemonController создаёт новый экземпляр DaemonController.
+func NewDaemonController() *DaemonController {
+	return &DaemonController{
+		service: services.NewDaemonService(),
+	}
+}
+
+// List отображает страницу управления процессом-обработчиком.
+func (dc *DaemonController) List(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	c.HTML(http.StatusOK, "daemon/index.html", gin.H{
+		"Title": "Daemon Manage",
+		"User":  user,
+	})
+}
+
+// AjaxCheckStatus отдаёт состояние процесса: status (ACTIVE/STOPPED) и подробности в info.
+func (dc *DaemonController) AjaxCheckStatus(c *gin.Context) {
+	user, ok := requireAdminJSON(c)
+	if !ok {
+		return
+	}
+	status, err := dc.service.Status(c.Request.Context())
+	dc.respond(c, user, status, err)
+}
+
+// AjaxStart запускает процесс-обработчик.
+func (dc *DaemonController) AjaxStart(c *gin.Context) {
+	user, ok := requireAdminJSON(c)
+	if !ok {
+		return
+	}
+	status, err := dc.service.Start(c.Request.Context(), user.ID)
+	dc.respond(c, user, status, err)
+}
+
+// AjaxStop останавливает процесс-обработчик.
+func (dc *DaemonController) AjaxStop(c *gin.Context) {
+	user, ok := requireAdminJSON(c)
+	if !ok {
+		return
+	}
+	status, err := dc.service.Stop(c.Request.Context(), user.ID)
+	dc.respond(c, user, status, err)
+}
+
+// AjaxDaemonStat отдаёт дерево процессов: status - диаграмма mermaid, processes - то же дерево в JSON.
+func (dc *DaemonController) AjaxDaemonStat(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+	status, err := dc.service.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"error":     false,
+		"status":    services.DaemonDiagram(status.Processes),
+		"processes": status.Processes,
+	})
+}
+
+// respond отдаёт состояние процесса в формате daemon_manage.js. При ошибке
+// status всё равно заполняется, если состояние удалось получить.
+func (dc *DaemonController) respond(c *gin.Context, user *models.User, status *services.DaemonStatus, err error) {
+	resp := gin.H{"success": err == nil, "error": false, "status": "", "info": nil}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	if status == nil {
+		if err == nil {
+			resp["success"] = false
+			resp["error"] = "daemon status is unavailable"
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	now := time.Now()
+	info := gin.H{
+		"pid":            status.PID,
+		"started_at":     "",
+		"uptime":         "",
+		"last_heartbeat": "",
+		"heartbeat_age":  "",
+		"logs":           status.Logs,
+	}
+	if status.Running {
+		resp["status"] = "ACTIVE"
+		if status.StartedAt != nil {
+			info["started_at"] = status.StartedAt.In(loc).Format("2006-01-02 15:04:05")
+			info["uptime"] = formatUptime(status.Uptime(now))
+		}
+	} else {
+		resp["status"] = "STOPPED"
+	}
+	if status.LastHeartbeat != nil {
+		info["last_heartbeat"] = status.LastHeartbeat.In(loc).Format("2006-01-02 15:04:05")
+		info["heartbeat_age"] = formatUptime(now.Sub(*status.LastHeartbeat))
+	}
+	if status.Logs == nil {
+		info["logs"] = []string{}
+	}
+	resp["info"] = info
+	c.JSON(http.StatusOK, resp)
+}
+
+// formatUptime форматирует длительность как "2d 03:04:05".
+func formatUptime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Truncate(time.Second)
+	days := int(d / (24 * time.Hour))
+	d -= time.Duration(days) * 24 * time.Hour
+	clock := fmt.Sprintf("%02d:%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second))
+	if days > 0 {
+		return fmt.Sprintf("%dd %s", days, clock)
+	}
+	return clock
+}
@@ -15,26 +15,44 @@ import (
 
 // ExchangeAccountController обрабатывает запросы, связанные с аккаунтами бирж.
 type ExchangeAccountController struct {
-	service *services.ExchangeService
+	service  *services.ExchangeService
+	checks   *services.AccountCheckService
+	balances *services.BalanceService
+	admin    *services.AccountAdminService
 }
 
 // NewExchangeAccountController создаёт новый экземпляр ExchangeAccountController.
 func NewExchangeAccountController() *ExchangeAccountController {
 	return &ExchangeAccountController{
-		service: services.NewExchangeService(),
+		service:  services.NewExchangeService(),
+		checks:   services.NewAccountCheckService(),
+		balances: services.NewBalanceService(),
+		admin:    services.NewAccountAdminService(),
 	}
 }
 
-// List отображает страницу аккаунтов бирж (шаблон добавим в 4.6).
+// List отображает страницу аккаунтов бирж.
 func (eac *ExchangeAccountController) List(c *gin.Context) {
 	user, ok := c.Get("user")
 	if !ok {
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
+	// Мастер-аккаунты для выбора при создании субаккаунта.
+	var masters []*models.ExchangeAccount
+	accounts, err := eac.service.AccountRepo().FindAllByUser(user.(*models.User).ID)
+	if err != nil {
+		logger.Error().Err(err).Int("user_id", user.(*models.User).ID).Msg("failed to load exchange accounts")
+	}
+	for _, acc := range accounts {
+		if !acc.IsSubAccount() {
+			masters = append(masters, acc)
+		}
+	}
 	c.HTML(http.StatusOK, "exchange_accounts/index.html", gin.H{
-		"Title": "Exchange Accounts",
-		"User":  user.(*models.User),
+		"Title":   "Exchange Accounts",
+		"User":    user.(*models.User),
+		"Masters": masters,
 	})
 }
 
@@ -69,6 +87,13 @@ func (eac *ExchangeAccountController) AjaxGetAccounts(c *gin.Context) {
 		exName[ex.ID] = ex.Name
 	}
 
+	// Субаккаунты выводятся сразу под своим мастер-аккаунтом.
+	accounts = services.OrderAccountTree(accounts)
+	accName := make(map[int]string, len(accounts))
+	for _, acc := range accounts {
+		accName[acc.ID] = acc.AccountName
+	}
+
 	recordsTotal := len(accounts)
 	recordsFiltered := recordsTotal // пока без серверной фильтрации
 
@@ -101,8 +126,16 @@ func (eac *ExchangeAccountController) AjaxGetAccounts(c *gin.Context) {
 			"account_name":  acc.AccountName,
 			"priority":      acc.Priority,
 			"status":        status,
-			"api_key":       acc.ApiKey,
+			"api_key":       services.MaskKey(acc.ApiKey),
 			"note":          acc.Note,
+			"key_check":     acc.LastCheck,
+			"key_violation": acc.LastCheck.Valid() && acc.LastCheck.Withdraw,
+			"parent_id":     acc.ParentID,
+			"parent_name":   "",
+			"sub_label":     acc.SubAccountLabel,
+		}
+		if acc.ParentID != nil {
+			aaData[i]["parent_name"] = accName[*acc.ParentID]
 		}
 	}
 
@@ -114,7 +147,8 @@ func (eac *ExchangeAccountController) AjaxGetAccounts(c *gin.Context) {
 	})
 }
 
-// AjaxGetAccountByID возвращает данные аккаунта по ID.
+// AjaxGetAccountByID возвращает данные аккаунта по ID. Ключи в браузер не отдаются:
+// API key - в маскированном виде, для секрета и passphrase - только признак, что они заданы.
 func (eac *ExchangeAccountController) AjaxGetAccountByID(c *gin.Context) {
 	userVal, exists := c.Get("user")
 	if !exists {
@@ -150,13 +184,24 @@ func (eac *ExchangeAccountController) AjaxGetAccountByID(c *gin.Context) {
 		"account_name": acc.AccountName,
 		"priority":     acc.Priority,
 		"status":       status,
-		"api_key":      acc.ApiKey,
-		"secret_key":   acc.SecretKey,
-		"add_key":      acc.AddKey,
+		"api_key":      services.MaskKey(acc.ApiKey),
+		"has_secret":   acc.SecretKey != "",
+		"has_add_key":  acc.AddKey != nil && *acc.AddKey != "",
 		"note":         acc.Note,
+		"parent_id":    acc.ParentID,
+		"sub_label":    acc.SubAccountLabel,
 	})
 }
 
+// optionalID разбирает необязательный ID из формы (пусто или 0 - не задан).
+func optionalID(value string) *int {
+	id, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || id <= 0 {
+		return nil
+	}
+	return &id
+}
+
 // AjaxCreateAccount создаёт новый аккаунт биржи.
 func (eac *ExchangeAccountController) AjaxCreateAccount(c *gin.Context) {
 	userVal, exists := c.Get("user")
@@ -174,6 +219,8 @@ func (eac *ExchangeAccountController) AjaxCreateAccount(c *gin.Context) {
 	secretKey := c.PostForm("create_exchange_account_secret_key")
 	addKey := c.PostForm("create_exchange_account_add_key")
 	note := c.PostForm("create_exchange_account_note")
+	parentID := optionalID(c.PostForm("create_exchange_account_parent_id"))
+	subLabel := c.PostForm("create_exchange_account_sub_label")
 
 	exid, err := strconv.Atoi(exidStr)
 	if err != nil || exid <= 0 {
@@ -195,7 +242,7 @@ func (eac *ExchangeAccountController) AjaxCreateAccount(c *gin.Context) {
 		}
 	}
 
-	id, err := eac.service.CreateExchangeAccount(user.ID, exid, accountName, status, priority, apiKey, secretKey, addKey, note)
+	id, err := eac.service.CreateExchangeAccount(c.Request.Context(), user.ID, exid, accountName, status, priority, apiKey, secretKey, addKey, note, parentID, subLabel)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -222,6 +269,8 @@ func (eac *ExchangeAccountController) AjaxEditAccount(c *gin.Context) {
 	secretKey := c.PostForm("edit_exchange_account_secret_key")
 	addKey := c.PostForm("edit_exchange_account_add_key")
 	note := c.PostForm("edit_exchange_account_note")
+	parentID := optionalID(c.PostForm("edit_exchange_account_parent_id"))
+	subLabel := c.PostForm("edit_exchange_account_sub_label")
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil || id <= 0 {
@@ -248,10 +297,273 @@ func (eac *ExchangeAccountController) AjaxEditAccount(c *gin.Context) {
 		}
 	}
 
-	if err := eac.service.UpdateExchangeAccount(id, user.ID, exid, accountName, status, priority, apiKey, secretKey, addKey, note); err != nil {
+	if err := eac.service.UpdateExchangeAccount(c.Request.Context(), id, user.ID, exid, accountName, status, priority, apiKey, secretKey, addKey, note, parentID, subLabel); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
+
+// AjaxTestConnection проверяет ключ API аккаунта на бирже: принят ли ключ,
+// его права (чтение/торговля/вывод) и привязку к IP. Результат сохраняется в аккаунте.
+func (eac *ExchangeAccountController) AjaxTestConnection(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	check, err := eac.checks.TestConnection(c.Request.Context(), user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "check": check})
+}
+
+// AjaxSnapshotBalances запрашивает балансы аккаунта на бирже и сохраняет снимок.
+func (eac *ExchangeAccountController) AjaxSnapshotBalances(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	snapshot, err := eac.balances.SnapshotAccount(c.Request.Context(), user.ID, id)
+	if err != nil {
+		logger.Warn().Err(err).Int("user_id", user.ID).Int("account_id", id).Msg("balance snapshot failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "snapshot": snapshot})
+}
+
+// AjaxGetBalances отдаёт последний снимок балансов аккаунта и историю его стоимости
+// за days дней (по умолчанию 30). Время в истории - unix-время в миллисекундах.
+func (eac *ExchangeAccountController) AjaxGetBalances(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	days, _ := strconv.Atoi(c.PostForm("days"))
+
+	latest, err := eac.balances.Latest(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	history, err := eac.balances.History(user.ID, id, days)
+	if err != nil {
+		logger.Error().Err(err).Int("user_id", user.ID).Int("account_id", id).Msg("failed to load balance history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load balance history"})
+		return
+	}
+
+	points := make([]gin.H, 0, len(history))
+	for _, snapshot := range history {
+		points = append(points, gin.H{
+			"t":        snapshot.SnapshotAt.UnixMilli(),
+			"v":        snapshot.USDValue,
+			"unpriced": snapshot.UnpricedAssets,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "latest": latest, "history": points})
+}
+
+// AjaxGetRollup отдаёт балансы и открытые позиции пользователя, сведённые к мастер-аккаунтам.
+func (eac *ExchangeAccountController) AjaxGetRollup(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	rollup, err := eac.balances.Rollup(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Int("user_id", user.ID).Msg("failed to build account rollup")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build account rollup"})
+		return
+	}
+
+	exchanges, err := eac.service.ExchangeRepo().FindAllActive()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to load exchanges for rollup")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load exchanges"})
+		return
+	}
+	exName := make(map[int]string)
+	for _, ex := range exchanges {
+		exName[ex.ID] = ex.Name
+	}
+
+	items := make([]gin.H, 0, len(rollup))
+	for _, item := range rollup {
+		items = append(items, gin.H{
+			"master_id":         item.MasterID,
+			"master_name":       item.MasterName,
+			"exchange_name":     exName[item.ExID],
+			"sub_accounts":      item.SubAccounts,
+			"usd_value":         item.USDValue,
+			"unpriced_assets":   item.UnpricedAssets,
+			"missing_snapshots": item.MissingSnapshots,
+			"open_positions":    item.OpenPositions,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "items": items})
+}
+
+// AdminList отображает страницу аккаунтов бирж всех пользователей (только для администратора).
+func (eac *ExchangeAccountController) AdminList(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.Redirect(http.StatusFound, "/exchange_accounts/")
+		return
+	}
+
+	owners, err := eac.admin.Owners()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to load exchange account owners")
+	}
+	c.HTML(http.StatusOK, "exchange_accounts/admin.html", gin.H{
+		"Title":  "All Exchange Accounts",
+		"User":   user,
+		"Owners": owners,
+	})
+}
+
+// AjaxGetAllAccounts отдаёт аккаунты всех пользователей для DataTables (серверная
+// пагинация, сортировка и поиск). Ключи отдаются только маскированными.
+func (eac *ExchangeAccountController) AjaxGetAllAccounts(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	req := utils.ParseDataTablesRequest(c)
+	resp, err := eac.admin.List(utils.ConvertToExchangeRepositoryRequest(req))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to load exchange accounts for admin")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load exchange accounts"})
+		return
+	}
+
+	aaData := make([]map[string]interface{}, len(resp.Data))
+	for i, row := range resp.Data {
+		acc := row.Account
+		status := "Blocked"
+		if acc.Active {
+			status = "Active"
+		}
+		aaData[i] = map[string]interface{}{
+			"chbx":          "",
+			"DT_RowId":      "row_" + strconv.Itoa(acc.ID),
+			"id":            acc.ID,
+			"uid":           acc.UID,
+			"user_login":    row.UserLogin,
+			"exchange_name": row.ExchangeName,
+			"account_name":  acc.AccountName,
+			"status":        status,
+			"api_key":       acc.ApiKey,
+			"secret_key":    acc.SecretKey,
+			"add_key":       acc.AddKey,
+			"key_check":     acc.LastCheck,
+			"key_violation": acc.LastCheck.Valid() && acc.LastCheck.Withdraw,
+			"date_create":   acc.DateCreate.Format("2006-01-02 15:04:05"),
+			"parent_id":     acc.ParentID,
+			"parent_name":   row.ParentName,
+			"sub_label":     acc.SubAccountLabel,
+		}
+	}
+
+	c.JSON(http.StatusOK, utils.DataTablesResponse{
+		Draw:            req.Draw,
+		RecordsTotal:    resp.RecordsTotal,
+		RecordsFiltered: resp.RecordsFiltered,
+		AAData:          aaData,
+	})
+}
+
+// AjaxDisableAccounts отключает аккаунты: все активные аккаунты пользователя user_id
+// (отключение уходящего пользователя) или выбранные аккаунты ids (через запятую).
+func (eac *ExchangeAccountController) AjaxDisableAccounts(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	var ownerID int
+	if value := strings.TrimSpace(c.PostForm("user_id")); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		ownerID = id
+	}
+	var ids []int
+	for _, value := range strings.Split(c.PostForm("ids"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	disabled, err := eac.admin.DisableAccounts(user.ID, ownerID, ids)
+	if err != nil {
+		logger.Warn().Err(err).Int("admin_id", user.ID).Int("owner_id", ownerID).Msg("failed to disable exchange accounts")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "disabled": len(disabled), "ids": disabled})
+}
@@ -7,6 +7,7 @@ import (
 	"ctweb/internal/repositories"
 	"ctweb/internal/services"
 	"ctweb/internal/utils"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,15 +15,24 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxFundingFileSize - ограничение размера файла импорта ставок финансирования.
+const maxFundingFileSize = 20 << 20
+
 // ExchangeController обрабатывает запросы, связанные с биржами.
 type ExchangeController struct {
-	service *services.ExchangeService
+	service     *services.ExchangeService
+	instruments *services.InstrumentService
+	fees        *services.FeeService
+	funding     *services.FundingService
 }
 
 // NewExchangeController создаёт новый экземпляр ExchangeController.
 func NewExchangeController() *ExchangeController {
 	return &ExchangeController{
-		service: services.NewExchangeService(),
+		service:     services.NewExchangeService(),
+		instruments: services.NewInstrumentService(),
+		fees:        services.NewFeeService(),
+		funding:     services.NewFundingService(),
 	}
 }
 
@@ -186,6 +196,199 @@ func (ec *ExchangeController) AjaxEditExchange(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// AjaxSyncInstruments запускает синхронизацию справочника инструментов.
+// Если exchange_id не передан, синхронизируются все активные биржи с коннектором.
+func (ec *ExchangeController) AjaxSyncInstruments(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	var (
+		results []services.InstrumentSyncResult
+		err     error
+	)
+	if idStr := strings.TrimSpace(c.PostForm("exchange_id")); idStr != "" {
+		id, convErr := strconv.Atoi(idStr)
+		if convErr != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		results, err = ec.instruments.SyncExchange(c.Request.Context(), id)
+	} else {
+		results, err = ec.instruments.SyncAll(c.Request.Context())
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "results": results})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "results": results})
+}
+
+// AjaxGetFees возвращает комиссии биржи по умолчанию (SPOT и FUTURES).
+func (ec *ExchangeController) AjaxGetFees(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.PostForm("exchange_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	fees, err := ec.fees.GetExchangeFees(id)
+	if err != nil {
+		logger.Error().Err(err).Int("exchange_id", id).Msg("failed to get exchange fees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load fees"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "fees": fees})
+}
+
+// AjaxSaveFees сохраняет комиссии биржи по умолчанию для SPOT и FUTURES.
+func (ec *ExchangeController) AjaxSaveFees(c *gin.Context) {
+	user, ok := requireAdminJSON(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.PostForm("fee_exchange_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	for _, market := range []string{"spot", "futures"} {
+		if err := ec.fees.SaveExchangeFee(id, market, c.PostForm("fee_"+market+"_maker"), c.PostForm("fee_"+market+"_taker"), user.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": market + ": " + err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AjaxGetAccountFees возвращает VIP-переопределения комиссий аккаунта биржи.
+func (ec *ExchangeController) AjaxGetAccountFees(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.PostForm("account_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	fees, err := ec.fees.GetAccountFees(id)
+	if err != nil {
+		logger.Error().Err(err).Int("account_id", id).Msg("failed to get account fees")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load fees"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "fees": fees})
+}
+
+// AjaxSaveAccountFees сохраняет VIP-переопределение комиссий аккаунта для рынка.
+// Пустые maker/taker удаляют переопределение.
+func (ec *ExchangeController) AjaxSaveAccountFees(c *gin.Context) {
+	user, ok := requireAdminJSON(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.PostForm("account_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := ec.fees.SaveAccountFee(id, c.PostForm("market"), c.PostForm("vip_tier"), c.PostForm("maker"), c.PostForm("taker"), user.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AjaxImportFunding импортирует историю ставок финансирования биржи из CSV-файла.
+func (ec *ExchangeController) AjaxImportFunding(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.PostForm("exchange_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file not attached"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can not read file"})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxFundingFileSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can not read file"})
+		return
+	}
+	if len(content) > maxFundingFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is too large"})
+		return
+	}
+
+	count, err := ec.funding.ImportFile(id, content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "count": count})
+}
+
+// AjaxSyncFunding загружает с бирж ставки финансирования по контрактам открытых FUTURES-позиций.
+func (ec *ExchangeController) AjaxSyncFunding(c *gin.Context) {
+	if _, ok := requireAdminJSON(c); !ok {
+		return
+	}
+
+	count, err := ec.funding.SyncOpenPositions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "count": count})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "count": count})
+}
+
+// requireAdminJSON проверяет, что запрос выполняет администратор, и отвечает JSON-ошибкой иначе.
+func requireAdminJSON(c *gin.Context) (*models.User, bool) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+	user := userVal.(*models.User)
+	if !user.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return nil, false
+	}
+	return user, true
+}
+
 // ExchangeRepo позволяет переиспользовать репозиторий (для ajax_get_exchanges).
 func (ec *ExchangeController) ExchangeRepo() *repositories.ExchangeRepository {
 	return ec.service.ExchangeRepo()
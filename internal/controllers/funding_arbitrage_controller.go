@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"ctweb/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fundingHorizons - сроки удержания связки на странице /funding_arbitrage/, в часах.
+var fundingHorizons = []int{24, 72, 168, 720}
+
+// FundingArbitrageController - сканер арбитража ставок финансирования (/funding_arbitrage/)
+// и открытие группы позиций по выбранной связке.
+type FundingArbitrageController struct {
+	service *services.FundingArbitrageService
+}
+
+// NewFundingArbitrageController создаёт новый экземпляр FundingArbitrageController.
+func NewFundingArbitrageController() *FundingArbitrageController {
+	return &FundingArbitrageController{
+		service: services.NewFundingArbitrageService(),
+	}
+}
+
+// List отображает форму сканирования.
+func (fc *FundingArbitrageController) List(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	exchanges, _ := repositories.NewExchangeRepository().FindAllActive()
+	c.HTML(http.StatusOK, "funding_arbitrage/index.html", gin.H{
+		"Title":    "Funding Arbitrage",
+		"User":     user,
+		"Accounts": accountOptions(user.ID, exchanges),
+		"Horizons": fundingHorizons,
+	})
+}
+
+// AjaxScan сканирует ставки: pairs (BASE/QUOTE через запятую) или quote (котируемая валюта
+// для всех пар на нескольких биржах), horizon - срок удержания в часах.
+func (fc *FundingArbitrageController) AjaxScan(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	horizon, _ := strconv.Atoi(c.PostForm("horizon"))
+	result, err := fc.service.Scan(c.Request.Context(), user.ID, c.PostForm("pairs"),
+		c.DefaultPostForm("quote", "USDT"), time.Duration(horizon)*time.Hour)
+	if err != nil {
+		logger.Warn().Err(err).Msg("funding arbitrage scan failed")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	rows := make([]gin.H, 0, len(result.Opportunities))
+	for _, o := range result.Opportunities {
+		rows = append(rows, gin.H{
+			"strategy":         o.Strategy,
+			"pair":             o.Pair,
+			"long":             fundingLegJSON(o.Long, loc),
+			"short":            fundingLegJSON(o.Short, loc),
+			"differential":     o.Differential,
+			"apr":              o.APR,
+			"fees":             o.Fees,
+			"net":              o.Net,
+			"break_even_hours": o.BreakEvenHours,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"error":      false,
+		"scanned_at": result.ScannedAt.In(loc).Format("2006-01-02 15:04:05"),
+		"exchanges":  result.Exchanges,
+		"pairs":      result.Pairs,
+		"truncated":  result.Truncated,
+		"errors":     result.Errors,
+		"data":       rows,
+	})
+}
+
+// fundingLegJSON - нога связки для таблицы результатов (ставка приведена к 8 часам).
+func fundingLegJSON(q services.FundingQuote, loc *time.Location) gin.H {
+	next := ""
+	if !q.NextFundingTime.IsZero() {
+		next = q.NextFundingTime.In(loc).Format("2006-01-02 15:04")
+	}
+	return gin.H{
+		"exchange_id":  q.ExchangeID,
+		"exchange":     q.Exchange,
+		"market":       q.Market,
+		"symbol":       q.Symbol,
+		"rate":         q.Rate,
+		"rate_8h":      q.Rate8h(),
+		"interval":     q.Interval.Hours(),
+		"next_funding": next,
+		"mark_price":   q.MarkPrice,
+		"taker_fee":    q.TakerFee,
+	}
+}
+
+// AjaxCreatePair открывает группу из двух позиций по связке: strategy, long_exchange_id, long_symbol,
+// long_account_id, short_exchange_id, short_symbol, short_account_id, start_date.
+func (fc *FundingArbitrageController) AjaxCreatePair(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	longExchangeID, _ := strconv.Atoi(c.PostForm("long_exchange_id"))
+	longAccountID, _ := strconv.Atoi(c.PostForm("long_account_id"))
+	shortExchangeID, _ := strconv.Atoi(c.PostForm("short_exchange_id"))
+	shortAccountID, _ := strconv.Atoi(c.PostForm("short_account_id"))
+	pair, err := fc.service.CreatePair(c.Request.Context(), user.ID, user.Timezone, services.FundingPairRequest{
+		Strategy:        c.PostForm("strategy"),
+		LongExchangeID:  longExchangeID,
+		LongSymbol:      c.PostForm("long_symbol"),
+		LongAccountID:   longAccountID,
+		ShortExchangeID: shortExchangeID,
+		ShortSymbol:     c.PostForm("short_symbol"),
+		ShortAccountID:  shortAccountID,
+		StartDate:       c.PostForm("start_date"),
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":           true,
+		"error":             false,
+		"group_id":          pair.GroupID,
+		"long_position_id":  pair.LongPositionID,
+		"short_position_id": pair.ShortPositionID,
+	})
+}
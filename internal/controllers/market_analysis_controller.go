@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"ctweb/internal/services"
+	"ctweb/internal/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MarketAnalysisController - страницы анализа межбиржевого арбитража
+// (/market_analysis/) и таблицы прямого арбитража (/market_analysis/direct_exs).
+type MarketAnalysisController struct {
+	service *services.MarketAnalysisService
+}
+
+func NewMarketAnalysisController() *MarketAnalysisController {
+	return &MarketAnalysisController{
+		service: services.NewMarketAnalysisService(),
+	}
+}
+
+func (mc *MarketAnalysisController) List(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	exchanges, _ := repositories.NewExchangeRepository().FindAllActive()
+	sort.Slice(exchanges, func(i, j int) bool {
+		return exchanges[i].Name < exchanges[j].Name
+	})
+
+	c.HTML(http.StatusOK, "market_analysis/index.html", gin.H{
+		"Title":     "Market Analysis",
+		"User":      user.(*models.User),
+		"Exchanges": exchanges,
+	})
+}
+
+func (mc *MarketAnalysisController) DirectExs(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	pairs, _ := mc.service.SharedPairs()
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+
+	c.HTML(http.StatusOK, "market_analysis/direct_exs.html", gin.H{
+		"Title":     "Direct Exchange Arbitrage",
+		"User":      user,
+		"Pairs":     pairs,
+		"DateStart": now.Add(-24 * time.Hour).Format("2006-01-02 15:04:05"),
+		"DateStop":  now.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// AjaxExchangesStep1 отдаёт биржи, у которых есть общие пары с выбранной биржей.
+func (mc *MarketAnalysisController) AjaxExchangesStep1(c *gin.Context) {
+	if _, exists := c.Get("user"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	exchangeID, _ := strconv.Atoi(c.PostForm("exchange_id"))
+	rows, success, errText := mc.service.ExchangesSharingPairs(c.Request.Context(), exchangeID)
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": rows})
+}
+
+// AjaxExchangesStep2 отдаёт пары, торгуемые на обеих выбранных биржах.
+func (mc *MarketAnalysisController) AjaxExchangesStep2(c *gin.Context) {
+	if _, exists := c.Get("user"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	exchangeID1, _ := strconv.Atoi(c.PostForm("exchange_id1"))
+	exchangeID2, _ := strconv.Atoi(c.PostForm("exchange_id2"))
+	rows, success, errText := mc.service.CommonPairs(c.Request.Context(), exchangeID1, exchangeID2)
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": rows})
+}
+
+// AjaxExchangesStep3 отдаёт таймфреймы, комиссии обеих бирж и текущий спред пары.
+func (mc *MarketAnalysisController) AjaxExchangesStep3(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	exchangeID1, _ := strconv.Atoi(c.PostForm("exchange_id1"))
+	exchangeID2, _ := strconv.Atoi(c.PostForm("exchange_id2"))
+	row, success, errText := mc.service.PairDetails(c.Request.Context(), user.ID, exchangeID1, exchangeID2, c.PostForm("trade_pair"))
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	row["success"] = true
+	row["error"] = false
+	c.JSON(http.StatusOK, row)
+}
+
+// AjaxExchangesStep4 отдаёт историю спреда пары между биржами по свечам таймфрейма.
+func (mc *MarketAnalysisController) AjaxExchangesStep4(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	exchangeID1, _ := strconv.Atoi(c.PostForm("exchange_id1"))
+	exchangeID2, _ := strconv.Atoi(c.PostForm("exchange_id2"))
+	row, success, errText := mc.service.SpreadHistory(c.Request.Context(), user.ID, exchangeID1, exchangeID2,
+		c.PostForm("trade_pair"), c.PostForm("timeframe"))
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	row["success"] = true
+	row["error"] = false
+	c.JSON(http.StatusOK, row)
+}
+
+// AjaxDirectExs отдаёт для DataTables связки прямого арбитража пары за период.
+// Ошибки загрузки отдельных бирж отдаются в поле errors, остальные биржи считаются.
+func (mc *MarketAnalysisController) AjaxDirectExs(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+	req := utils.ParseDataTablesRequest(c)
+
+	empty := gin.H{"draw": req.Draw, "recordsTotal": 0, "recordsFiltered": 0, "data": []interface{}{}}
+	if strings.TrimSpace(c.PostForm("symbol")) == "" {
+		c.JSON(http.StatusOK, empty)
+		return
+	}
+
+	found, warnings, err := mc.service.DirectArbitrageRows(c.Request.Context(), user.ID, user.Timezone,
+		c.PostForm("symbol"), c.PostForm("date_start"), c.PostForm("date_stop"))
+	if err != nil {
+		empty["errors"] = err.Error()
+		c.JSON(http.StatusOK, empty)
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	rows := make([]gin.H, 0, len(found))
+	for _, item := range found {
+		row := gin.H{
+			"time":         item.Time.In(loc).Format("2006-01-02 15:04:05"),
+			"sell":         item.Sell,
+			"buy":          item.Buy,
+			"price_sell":   strconv.FormatFloat(item.PriceSell, 'f', -1, 64),
+			"price_buy":    strconv.FormatFloat(item.PriceBuy, 'f', -1, 64),
+			"fees":         strconv.FormatFloat(item.Fees*100, 'f', 4, 64) + "%",
+			"volume_max":   strconv.FormatFloat(item.VolumeMax, 'f', -1, 64),
+			"profit":       strconv.FormatFloat(item.Profit*100, 'f', 4, 64) + "%",
+			"profit_total": strconv.FormatFloat(item.ProfitTotal, 'f', 2, 64),
+		}
+		if matchColumnSearch(req, row) {
+			rows = append(rows, row)
+		}
+	}
+
+	page := rows
+	if req.Start > 0 && req.Start < len(page) {
+		page = page[req.Start:]
+	} else if req.Start >= len(page) {
+		page = page[:0]
+	}
+	if req.Length > 0 && req.Length < len(page) {
+		page = page[:req.Length]
+	}
+
+	resp := gin.H{
+		"draw":            req.Draw,
+		"recordsTotal":    len(found),
+		"recordsFiltered": len(rows),
+		"data":            page,
+	}
+	if len(warnings) > 0 {
+		resp["errors"] = strings.Join(warnings, "; ")
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// matchColumnSearch проверяет строку по поиску в заголовках колонок (подстрока без учёта регистра).
+func matchColumnSearch(req *utils.DataTablesRequest, row gin.H) bool {
+	for _, col := range req.Columns {
+		term := strings.ToLower(strings.TrimSpace(col.Search.Value))
+		if term == "" {
+			continue
+		}
+		value, _ := row[col.Data].(string)
+		if !strings.Contains(strings.ToLower(value), term) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"ctweb/internal/logger"
 	"ctweb/internal/models"
 	"ctweb/internal/repositories"
 	"ctweb/internal/services"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"sort"
@@ -18,11 +21,58 @@ import (
 )
 
 type PositionController struct {
-	service *services.PositionService
+	service     *services.PositionService
+	instruments *services.InstrumentService
+	funding     *services.FundingService
+	chart       *services.PositionChartService
+	reconcile   *services.ReconciliationService
+	groups      *services.PositionGroupService
 }
 
 func NewPositionController() *PositionController {
-	return &PositionController{service: services.NewPositionService()}
+	return &PositionController{
+		service:     services.NewPositionService(),
+		instruments: services.NewInstrumentService(),
+		funding:     services.NewFundingService(),
+		chart:       services.NewPositionChartService(),
+		reconcile:   services.NewReconciliationService(),
+		groups:      services.NewPositionGroupService(),
+	}
+}
+
+// accountOption - аккаунт биржи в списках выбора на страницах позиций.
+type accountOption struct {
+	ID     int
+	ExID   int
+	Label  string
+	Active bool
+}
+
+// accountOptions возвращает аккаунты пользователя для привязки позиций и фильтра списка.
+func accountOptions(userID int, exchanges []*models.Exchange) []accountOption {
+	accounts, err := repositories.NewExchangeAccountRepository().FindAllByUser(userID)
+	if err != nil {
+		return nil
+	}
+	names := make(map[int]string, len(exchanges))
+	for _, ex := range exchanges {
+		names[ex.ID] = ex.Name
+	}
+	accNames := make(map[int]string, len(accounts))
+	for _, acc := range accounts {
+		accNames[acc.ID] = acc.AccountName
+	}
+	options := make([]accountOption, 0, len(accounts))
+	for _, acc := range accounts {
+		label := names[acc.ExID] + " / " + acc.AccountName
+		if acc.ParentID != nil && accNames[*acc.ParentID] != "" {
+			// Субаккаунт подписывается вместе с мастер-аккаунтом, чтобы он шёл рядом с ним.
+			label = names[acc.ExID] + " / " + accNames[*acc.ParentID] + " / " + acc.AccountName
+		}
+		options = append(options, accountOption{ID: acc.ID, ExID: acc.ExID, Label: label, Active: acc.IsActive()})
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].Label < options[j].Label })
+	return options
 }
 
 func (pc *PositionController) List(c *gin.Context) {
@@ -43,6 +93,7 @@ func (pc *PositionController) List(c *gin.Context) {
 		"Title":     "Trade Positions",
 		"User":      user.(*models.User),
 		"Exchanges": exchanges,
+		"Accounts":  accountOptions(user.(*models.User).ID, exchanges),
 		"Now":       nowMoscow,
 	})
 }
@@ -72,6 +123,7 @@ func (pc *PositionController) PositionPage(c *gin.Context) {
 		"Title":             "Position",
 		"User":              user.(*models.User),
 		"Exchanges":         exchanges,
+		"Accounts":          accountOptions(user.(*models.User).ID, exchanges),
 		"ExchangeImportCSV": exchangeImportCSV,
 		"Now":               nowMoscow,
 	})
@@ -91,7 +143,7 @@ func (pc *PositionController) AjaxGetPositions(c *gin.Context) {
 		length = 50
 	}
 
-	count, rows, err := pc.service.GetPositionsData(user.ID, start, length)
+	count, rows, err := pc.service.GetPositionsData(user.ID, accountFilter(c.PostForm("filter_account")), start, length)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"recordsTotal":    0,
@@ -108,6 +160,93 @@ func (pc *PositionController) AjaxGetPositions(c *gin.Context) {
 	})
 }
 
+// accountFilter разбирает фильтр списка позиций по аккаунту: "" - все, "none" - без аккаунта, иначе ID.
+func accountFilter(value string) models.PositionFilter {
+	var filter models.PositionFilter
+	switch account := strings.TrimSpace(value); account {
+	case "":
+	case "none":
+		filter.AccountID = models.PositionFilterNoAccount
+	default:
+		filter.AccountID, _ = strconv.Atoi(account)
+	}
+	return filter
+}
+
+// Export отдаёт файл со списком позиций и итогами: format (csv, xlsx, json) и filter_account.
+func (pc *PositionController) Export(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	format := c.Query("format")
+	if !startExport(c, user, "positions", format) {
+		return
+	}
+	if err := pc.service.ExportPositions(c.Writer, user.ID, user.Timezone, accountFilter(c.Query("filter_account")), format); err != nil {
+		failExport(c, user, err)
+	}
+}
+
+// ExportTransactions отдаёт файл с операциями позиции: position_id и format (csv, xlsx, json).
+func (pc *PositionController) ExportTransactions(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	positionID, _ := strconv.Atoi(c.Query("position_id"))
+	if positionID <= 0 {
+		c.String(http.StatusBadRequest, "Failed Position ID")
+		return
+	}
+	format := c.Query("format")
+	if !startExport(c, user, "position-"+strconv.Itoa(positionID)+"-transactions", format) {
+		return
+	}
+	if err := pc.service.ExportTransactions(c.Writer, user.ID, user.Timezone, positionID, format); err != nil {
+		failExport(c, user, err)
+	}
+}
+
+// startExport проверяет формат и выставляет заголовки файла выгрузки; тело пишется потоком.
+func startExport(c *gin.Context, user *models.User, prefix, format string) bool {
+	contentType := services.ExportContentType(format)
+	if contentType == "" {
+		c.String(http.StatusBadRequest, "unknown export format")
+		return false
+	}
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": services.ExportFileName(prefix, format, time.Now().In(loc))}))
+	c.Status(http.StatusOK)
+	return true
+}
+
+// failExport отвечает ошибкой, если файл ещё не начал отправляться; иначе ошибка только пишется в лог.
+func failExport(c *gin.Context, user *models.User, err error) {
+	if c.Writer.Written() {
+		logger.Error().Err(err).Int("uid", user.ID).Msg("export failed")
+		return
+	}
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	if errors.Is(err, services.ErrPositionNotFound) {
+		c.String(http.StatusNotFound, "Position not found")
+		return
+	}
+	logger.Error().Err(err).Int("uid", user.ID).Msg("export failed")
+	c.String(http.StatusInternalServerError, "Export failed")
+}
+
 func (pc *PositionController) AjaxCreatePosition(c *gin.Context) {
 	userVal, exists := c.Get("user")
 	if !exists {
@@ -118,10 +257,11 @@ func (pc *PositionController) AjaxCreatePosition(c *gin.Context) {
 
 	name := c.PostForm("add_position_name_contract")
 	exchangeID, _ := strconv.Atoi(c.PostForm("add_position_exchange"))
+	accountID, _ := strconv.Atoi(c.PostForm("add_position_account"))
 	startDate := c.PostForm("add_position_date_start")
 	market := c.PostForm("add_position_market")
 
-	success, errText := pc.service.CreatePosition(user.ID, user.Timezone, name, exchangeID, startDate, market)
+	success, errText := pc.service.CreatePosition(user.ID, user.Timezone, name, exchangeID, accountID, startDate, market)
 	c.JSON(http.StatusOK, gin.H{
 		"error":   boolOrError(errText),
 		"success": success,
@@ -139,15 +279,52 @@ func (pc *PositionController) AjaxEditPosition(c *gin.Context) {
 	positionID, _ := strconv.Atoi(c.PostForm("position_id"))
 	name := c.PostForm("name_contract")
 	exchangeID, _ := strconv.Atoi(c.PostForm("exchange_id"))
+	accountID, _ := strconv.Atoi(c.PostForm("account_id"))
 	startDate := c.PostForm("date_start")
 
-	success, errText := pc.service.EditPosition(user.ID, user.Timezone, positionID, name, exchangeID, startDate)
+	success, errText := pc.service.EditPosition(user.ID, user.Timezone, positionID, name, exchangeID, accountID, startDate)
 	c.JSON(http.StatusOK, gin.H{
 		"error":   boolOrError(errText),
 		"success": success,
 	})
 }
 
+// AjaxInstruments отдаёт варианты контрактов биржи для автодополнения поля "Contract".
+func (pc *PositionController) AjaxInstruments(c *gin.Context) {
+	if _, exists := c.Get("user"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	exchangeID, _ := strconv.Atoi(c.PostForm("exchange_id"))
+	market := c.PostForm("market")
+	term := c.PostForm("term")
+	limit, _ := strconv.Atoi(c.DefaultPostForm("limit", "20"))
+
+	items, err := pc.instruments.Search(exchangeID, market, term, limit)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Error load instruments", "items": []interface{}{}})
+		return
+	}
+
+	rows := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, gin.H{
+			"symbol":         item.Symbol,
+			"market":         item.MarketType,
+			"base":           item.BaseAsset,
+			"quote":          item.QuoteAsset,
+			"tick_size":      item.TickSize,
+			"lot_size":       item.LotSize,
+			"min_qty":        item.MinQty,
+			"contract_value": item.ContractValue,
+			"status":         item.Status,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "items": rows})
+}
+
 func (pc *PositionController) AjaxGetPosition(c *gin.Context) {
 	userVal, exists := c.Get("user")
 	if !exists {
@@ -235,6 +412,7 @@ func (pc *PositionController) AjaxCreateTransaction(c *gin.Context) {
 		"add_trans_price":     c.PostForm("add_trans_price"),
 		"add_trans_fee_quote": c.PostForm("add_trans_fee_quote"),
 		"add_trans_fee_base":  c.PostForm("add_trans_fee_base"),
+		"add_trans_liquidity": c.PostForm("add_trans_liquidity"),
 	}
 
 	success, errText := pc.service.CreateTransaction(user.ID, user.Timezone, req)
@@ -244,6 +422,163 @@ func (pc *PositionController) AjaxCreateTransaction(c *gin.Context) {
 	})
 }
 
+// AjaxGetFee отдаёт комиссию для предзаполнения формы транзакции по ставке биржи/аккаунта.
+func (pc *PositionController) AjaxGetFee(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	positionID, _ := strconv.Atoi(c.PostForm("position_id"))
+	if positionID <= 0 {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Empty ID"})
+		return
+	}
+
+	row, success, errText := pc.service.SuggestTransactionFee(
+		user.ID,
+		positionID,
+		c.PostForm("action"),
+		c.PostForm("price"),
+		c.PostForm("volume"),
+		c.PostForm("liquidity"),
+	)
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	row["success"] = true
+	row["error"] = false
+	c.JSON(http.StatusOK, row)
+}
+
+// AjaxFundingForecast отдаёт прогноз ближайшего платежа funding и годовую стоимость удержания позиции.
+func (pc *PositionController) AjaxFundingForecast(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	positionID, _ := strconv.Atoi(c.PostForm("position_id"))
+	if positionID <= 0 {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Empty ID"})
+		return
+	}
+
+	row, success, errText := pc.funding.Forecast(c.Request.Context(), user.ID, user.Timezone, positionID)
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	row["success"] = true
+	row["error"] = false
+	c.JSON(http.StatusOK, row)
+}
+
+// AjaxFundingGaps отдаёт расчёты funding, для которых у позиции нет FUNDING-транзакции.
+func (pc *PositionController) AjaxFundingGaps(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	positionID, _ := strconv.Atoi(c.PostForm("position_id"))
+	if positionID <= 0 {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Empty ID"})
+		return
+	}
+
+	row, success, errText := pc.funding.Gaps(user.ID, user.Timezone, positionID)
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	row["success"] = true
+	row["error"] = false
+	c.JSON(http.StatusOK, row)
+}
+
+// AjaxGetChart отдаёт данные графика позиции: свечи за период позиции, отметки сделок
+// и линию средней цены.
+func (pc *PositionController) AjaxGetChart(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	positionID, _ := strconv.Atoi(c.PostForm("position_id"))
+	if positionID <= 0 {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Empty ID"})
+		return
+	}
+
+	row, success, errText := pc.chart.Chart(c.Request.Context(), user.ID, positionID)
+	if !success {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	row["success"] = true
+	row["error"] = false
+	c.JSON(http.StatusOK, row)
+}
+
+// AjaxReconcile сверяет открытые позиции пользователя с биржами. Необязательный параметр
+// tolerance - допуск расхождения размера и средней цены в процентах.
+func (pc *PositionController) AjaxReconcile(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	tol := services.DefaultReconcileTolerance
+	if raw := strings.TrimSpace(c.PostForm("tolerance")); raw != "" {
+		percent, err := strconv.ParseFloat(raw, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			c.JSON(http.StatusOK, gin.H{"success": false, "error": "Tolerance must be a percent between 0 and 100"})
+			return
+		}
+		tol = services.ReconcileTolerance{Size: percent / 100, Price: percent / 100}
+	}
+
+	report, err := pc.reconcile.Reconcile(c.Request.Context(), user.ID, tol)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "report": report})
+}
+
+// AjaxGetReconciliation отдаёт последнюю сохранённую сверку пользователя (report = null, если сверок не было).
+func (pc *PositionController) AjaxGetReconciliation(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	report, err := pc.reconcile.LastReport(user.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "report": report})
+}
+
 func (pc *PositionController) AjaxEditTransaction(c *gin.Context) {
 	userVal, exists := c.Get("user")
 	if !exists {
@@ -400,6 +735,123 @@ func (pc *PositionController) AjaxDeletePosition(c *gin.Context) {
 	})
 }
 
+// AjaxGetGroups возвращает группы позиций пользователя с ногами и суммарными показателями,
+// а также открытые позиции без группы для формы создания.
+func (pc *PositionController) AjaxGetGroups(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	groups, err := pc.groups.ListGroups(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	available, err := pc.groups.AvailablePositions(user.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	rows := make([]gin.H, 0, len(groups))
+	for _, g := range groups {
+		closed := ""
+		if g.Group.Closed != nil {
+			closed = g.Group.Closed.In(loc).Format("2006-01-02 15:04:05")
+		}
+		rows = append(rows, gin.H{
+			"id":         g.Group.ID,
+			"name":       g.Group.Name,
+			"strategy":   g.Group.Strategy,
+			"entry_diff": g.Group.EntryDiff,
+			"status":     g.Group.Status,
+			"created":    g.Group.Created.In(loc).Format("2006-01-02 15:04:05"),
+			"closed":     closed,
+			"legs":       g.Legs,
+			"totals":     g.Totals,
+		})
+	}
+	positions := make([]gin.H, 0, len(available))
+	for _, p := range available {
+		positions = append(positions, gin.H{
+			"id":       p.PositionID,
+			"name":     p.ContractName,
+			"exchange": p.ExchangeName,
+			"market":   p.MarketType,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"error":     false,
+		"data":      rows,
+		"positions": positions,
+	})
+}
+
+// AjaxCreateGroup объединяет открытые позиции в группу: name, position_ids[].
+func (pc *PositionController) AjaxCreateGroup(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	var ids []int
+	for _, raw := range c.PostFormArray("position_ids[]") {
+		if id, err := strconv.Atoi(raw); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	groupID, err := pc.groups.CreateGroup(user.ID, c.PostForm("name"), ids)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "group_id": groupID})
+}
+
+// AjaxCloseGroup закрывает группу вместе с ногами.
+func (pc *PositionController) AjaxCloseGroup(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	groupID, _ := strconv.Atoi(c.PostForm("group_id"))
+	success, errText := pc.groups.CloseGroup(user.ID, groupID)
+	c.JSON(http.StatusOK, gin.H{
+		"error":   boolOrError(errText),
+		"success": success,
+	})
+}
+
+// AjaxDeleteGroup расформировывает группу, позиции остаются.
+func (pc *PositionController) AjaxDeleteGroup(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	groupID, _ := strconv.Atoi(c.PostForm("group_id"))
+	if err := pc.groups.DeleteGroup(user.ID, groupID); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
 func (pc *PositionController) AjaxKucoinPrice(c *gin.Context) {
 	if _, exists := c.Get("user"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
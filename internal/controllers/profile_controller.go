@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"ctweb/internal/services"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBackupFileSize - ограничение размера загружаемой резервной копии.
+const maxBackupFileSize = 50 << 20
+
+// ProfileController - профиль текущего пользователя (/profile/): учётные данные,
+// привязка Telegram-чата одноразовым кодом и резервная копия позиций.
+type ProfileController struct {
+	telegram *services.TelegramService
+	backup   *services.BackupService
+	users    *repositories.UserRepository
+}
+
+// NewProfileController создаёт новый экземпляр ProfileController.
+func NewProfileController() *ProfileController {
+	return &ProfileController{
+		telegram: services.NewTelegramService(),
+		backup:   services.NewBackupService(),
+		users:    repositories.NewUserRepository(),
+	}
+}
+
+// Show отображает профиль пользователя.
+func (pc *ProfileController) Show(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	// Администратор может снять копию другого пользователя и восстановить её под любым пользователем
+	var users []*models.User
+	if user.IsAdmin() {
+		var err error
+		if users, err = pc.users.FindAll(); err != nil {
+			logger.Error().Err(err).Msg("failed to get users")
+		}
+	}
+
+	c.HTML(http.StatusOK, "profile/index.html", gin.H{
+		"Title":           "Profile",
+		"User":            user,
+		"Users":           users,
+		"TelegramEnabled": pc.telegram.Enabled(),
+		"TelegramBot":     pc.telegram.BotName(),
+	})
+}
+
+// AjaxGetTelegram отдаёт состояние привязки Telegram-чата.
+func (pc *ProfileController) AjaxGetTelegram(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	link, err := pc.telegram.Link(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get telegram link")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to load telegram link"})
+		return
+	}
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	result := gin.H{"success": true, "error": false, "enabled": pc.telegram.Enabled(), "linked": link != nil}
+	if link != nil {
+		result["username"] = link.Username
+		result["date_link"] = link.DateLink.In(loc).Format("2006-01-02 15:04:05")
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// AjaxCreateTelegramCode выдаёт одноразовый код привязки чата и ссылку на бота с этим кодом.
+func (pc *ProfileController) AjaxCreateTelegramCode(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	code, expire, err := pc.telegram.CreateLinkCode(user.ID)
+	if err != nil {
+		if !errors.Is(err, services.ErrTelegramDisabled) {
+			logger.Error().Err(err).Msg("failed to create telegram link code")
+		}
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	link := ""
+	if bot := pc.telegram.BotName(); bot != "" {
+		link = "https://t.me/" + url.PathEscape(bot) + "?start=" + code
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"error":   false,
+		"code":    code,
+		"expire":  expire.In(loc).Format("2006-01-02 15:04:05"),
+		"link":    link,
+	})
+}
+
+// AjaxDeleteTelegramLink отвязывает Telegram-чат пользователя.
+func (pc *ProfileController) AjaxDeleteTelegramLink(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	if err := pc.telegram.Unlink(user.ID); err != nil {
+		logger.Error().Err(err).Msg("failed to unlink telegram chat")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to unlink telegram chat"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// backupUser возвращает пользователя из параметра uid: другого пользователя может выбрать
+// только администратор, без параметра - текущий пользователь.
+func (pc *ProfileController) backupUser(user *models.User, uid string) (*models.User, int, string) {
+	id, _ := strconv.Atoi(uid)
+	if id <= 0 || id == user.ID {
+		return user, http.StatusOK, ""
+	}
+	if !user.IsAdmin() {
+		return nil, http.StatusForbidden, "Access denied"
+	}
+	target, err := pc.users.FindByID(id)
+	if err != nil || target == nil {
+		return nil, http.StatusNotFound, "User not found"
+	}
+	return target, http.StatusOK, ""
+}
+
+// Backup отдаёт резервную копию позиций, операций и настроек в JSON (uid - только администратор).
+func (pc *ProfileController) Backup(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	target, status, errText := pc.backupUser(user, c.Query("uid"))
+	if errText != "" {
+		c.String(status, errText)
+		return
+	}
+	backup, err := pc.backup.Export(target)
+	if err != nil {
+		logger.Error().Err(err).Int("uid", target.ID).Msg("failed to export backup")
+		c.String(http.StatusInternalServerError, "Backup failed")
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": services.BackupFileName(target.Login, time.Now().UTC())}))
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(backup); err != nil {
+		logger.Error().Err(err).Int("uid", target.ID).Msg("failed to write backup")
+	}
+}
+
+// AjaxImportBackup восстанавливает копию из file под пользователем target_uid
+// (другого пользователя может выбрать только администратор). dry_run=1 - только проверка.
+func (pc *ProfileController) AjaxImportBackup(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	target, status, errText := pc.backupUser(user, c.PostForm("target_uid"))
+	if errText != "" {
+		if status == http.StatusForbidden {
+			c.JSON(status, gin.H{"error": errText})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": errText})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "File Not Attached"})
+		return
+	}
+	if fileHeader.Size > maxBackupFileSize {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "File is too large"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Can not read data from file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxBackupFileSize+1))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Can not read data from file"})
+		return
+	}
+	if len(content) > maxBackupFileSize {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "File is too large"})
+		return
+	}
+
+	backup, err := services.ParseBackup(content)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	result, err := pc.backup.Restore(target, backup, c.PostForm("dry_run") == "1")
+	if err != nil {
+		logger.Error().Err(err).Int("uid", target.ID).Msg("failed to restore backup")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Restore failed, nothing was changed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "target": target.Login, "data": result})
+}
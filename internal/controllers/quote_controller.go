@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"ctweb/internal/models"
+	"ctweb/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteController отдаёт сохранённые котировки (свечи) для графиков и аналитики.
+type QuoteController struct {
+	candles *services.CandleService
+}
+
+// NewQuoteController создаёт новый экземпляр QuoteController.
+func NewQuoteController() *QuoteController {
+	return &QuoteController{candles: services.NewCandleService()}
+}
+
+// parseQuoteTime разбирает границу периода: unix-время в секундах или дату в часовом поясе пользователя.
+func parseQuoteTime(raw, timezone string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, strconv.ErrSyntax
+}
+
+// AjaxGetCandles возвращает свечи контракта из БД котировок без обращения к бирже.
+//
+// Параметры: exchange_id, market, symbol, interval (1m..1d или auto), from, to.
+// Время свечи "t" - unix-время начала периода в миллисекундах.
+func (qc *QuoteController) AjaxGetCandles(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	exchangeID, _ := strconv.Atoi(c.PostForm("exchange_id"))
+	from, err := parseQuoteTime(c.PostForm("from"), user.Timezone)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Invalid date from"})
+		return
+	}
+	to, err := parseQuoteTime(c.PostForm("to"), user.Timezone)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Invalid date to"})
+		return
+	}
+
+	interval, candles, err := qc.candles.GetCandles(
+		c.Request.Context(),
+		exchangeID,
+		c.PostForm("market"),
+		c.PostForm("symbol"),
+		strings.TrimSpace(c.PostForm("interval")),
+		from,
+		to,
+	)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rows := make([]gin.H, 0, len(candles))
+	for _, candle := range candles {
+		rows = append(rows, gin.H{
+			"t": candle.OpenTime.UnixMilli(),
+			"o": candle.Open,
+			"h": candle.High,
+			"l": candle.Low,
+			"c": candle.Close,
+			"v": candle.Volume,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "interval": interval, "candles": rows})
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/services"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportController - дневные и месячные PnL-отчёты (/reports/): подписка на рассылку,
+// формирование по запросу и история со скачиванием.
+type ReportController struct {
+	service *services.ReportService
+}
+
+// NewReportController создаёт новый экземпляр ReportController.
+func NewReportController() *ReportController {
+	return &ReportController{
+		service: services.NewReportService(),
+	}
+}
+
+// List отображает подписку и историю отчётов.
+func (rc *ReportController) List(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	sub, err := rc.service.Subscription(user)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get report subscription")
+		sub = &models.ReportSubscription{UID: user.ID, Email: user.Email}
+	}
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+
+	c.HTML(http.StatusOK, "reports/index.html", gin.H{
+		"Title":        "PnL Reports",
+		"User":         user,
+		"EmailEnabled": rc.service.EmailEnabled(),
+		"Subscription": sub,
+		"Timezone":     loc.String(),
+		"Today":        now.Format("2006-01-02"),
+		"Month":        now.Format("2006-01"),
+	})
+}
+
+// AjaxGetReports отдаёт историю отчётов пользователя.
+func (rc *ReportController) AjaxGetReports(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	reports, err := rc.service.History(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get pnl reports")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to load reports"})
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.In(loc).Format("2006-01-02 15:04:05")
+	}
+	rows := make([]gin.H, 0, len(reports))
+	for _, r := range reports {
+		rows = append(rows, gin.H{
+			"id":           r.ID,
+			"period":       r.Period,
+			"period_start": r.PeriodStart.Format("2006-01-02"),
+			"period_end":   r.PeriodEnd.Format("2006-01-02"),
+			"timezone":     r.Timezone,
+			"realized_pnl": r.RealizedPnL,
+			"date_create":  formatTime(&r.DateCreate),
+			"email":        r.Email,
+			"date_emailed": formatTime(r.DateEmailed),
+			"email_error":  r.EmailError,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": rows})
+}
+
+// AjaxSaveSubscription сохраняет подписку: daily, monthly (1/0) и email.
+func (rc *ReportController) AjaxSaveSubscription(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	if err := rc.service.SaveSubscription(user, c.PostForm("daily") == "1", c.PostForm("monthly") == "1", c.PostForm("email")); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// AjaxCreateReport формирует отчёт: period (daily/monthly) и date (YYYY-MM-DD или YYYY-MM).
+func (rc *ReportController) AjaxCreateReport(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	report, err := rc.service.Generate(c.Request.Context(), user, c.PostForm("period"), c.PostForm("date"))
+	if err != nil {
+		rc.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "id": report.ID})
+}
+
+// AjaxSendReport отправляет отчёт на адрес подписки.
+func (rc *ReportController) AjaxSendReport(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := rc.service.Send(c.Request.Context(), user, id); err != nil {
+		rc.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// AjaxDeleteReport удаляет отчёт из истории.
+func (rc *ReportController) AjaxDeleteReport(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := rc.service.Delete(user.ID, id); err != nil {
+		rc.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// Download отдаёт отчёт: id и format (html - открывается в браузере, csv - файлом).
+func (rc *ReportController) Download(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.Query("id"))
+	report, err := rc.service.Get(user.ID, id)
+	if errors.Is(err, services.ErrReportNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get pnl report")
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": services.ReportFileName(report, "csv")}))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(report.CSV))
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": services.ReportFileName(report, "html")}))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(report.HTML))
+}
+
+func (rc *ReportController) respondError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrReportNotFound) {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "report not found"})
+		return
+	}
+	logger.Error().Err(err).Msg("pnl report request failed")
+	c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+}
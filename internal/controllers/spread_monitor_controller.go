@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"ctweb/internal/services"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpreadMonitorController - страница мониторинга межбиржевого спреда (/spread_monitor/)
+// и JSON истории спреда наблюдения с перцентилями.
+type SpreadMonitorController struct {
+	service *services.SpreadMonitorService
+}
+
+// NewSpreadMonitorController создаёт новый экземпляр SpreadMonitorController.
+func NewSpreadMonitorController() *SpreadMonitorController {
+	return &SpreadMonitorController{
+		service: services.NewSpreadMonitorService(),
+	}
+}
+
+// List отображает наблюдения пользователя и форму добавления.
+func (sc *SpreadMonitorController) List(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	exchanges, _ := repositories.NewExchangeRepository().FindAllActive()
+	sort.Slice(exchanges, func(i, j int) bool {
+		return exchanges[i].Name < exchanges[j].Name
+	})
+
+	c.HTML(http.StatusOK, "spread_monitor/index.html", gin.H{
+		"Title":     "Spread Monitor",
+		"User":      user.(*models.User),
+		"Exchanges": exchanges,
+		"Markets":   []string{connectors.MarketSpot, connectors.MarketFutures},
+	})
+}
+
+// AjaxGetWatches отдаёт наблюдения пользователя с временем и ошибкой последнего замера.
+func (sc *SpreadMonitorController) AjaxGetWatches(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	watches, err := sc.service.ListWatches(user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get spread watches")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to load spread watches"})
+		return
+	}
+
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	rows := make([]gin.H, 0, len(watches))
+	for _, w := range watches {
+		lastSample := ""
+		if w.DateLastSample != nil {
+			lastSample = w.DateLastSample.In(loc).Format("2006-01-02 15:04:05")
+		}
+		rows = append(rows, gin.H{
+			"id":          w.ID,
+			"exchange1":   w.Exchange1,
+			"exchange2":   w.Exchange2,
+			"market":      w.MarketType,
+			"pair":        w.Pair(),
+			"symbol1":     w.Symbol1,
+			"symbol2":     w.Symbol2,
+			"is_active":   w.IsActive,
+			"last_sample": lastSample,
+			"last_error":  w.LastError,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": rows})
+}
+
+// AjaxCreateWatch добавляет наблюдение: exchange_id1, exchange_id2, market, trade_pair (BASE/QUOTE).
+func (sc *SpreadMonitorController) AjaxCreateWatch(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	exchangeID1, _ := strconv.Atoi(c.PostForm("exchange_id1"))
+	exchangeID2, _ := strconv.Atoi(c.PostForm("exchange_id2"))
+	watch, err := sc.service.CreateWatch(user.ID, exchangeID1, exchangeID2, c.PostForm("market"), c.PostForm("trade_pair"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "id": watch.ID})
+}
+
+// AjaxEditWatch включает (active=1) или приостанавливает (active=0) наблюдение.
+func (sc *SpreadMonitorController) AjaxEditWatch(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := sc.service.SetWatchActive(user.ID, id, c.PostForm("active") == "1"); err != nil {
+		sc.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// AjaxDeleteWatch удаляет наблюдение.
+func (sc *SpreadMonitorController) AjaxDeleteWatch(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	if err := sc.service.DeleteWatch(user.ID, id); err != nil {
+		sc.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// AjaxGetSpreadStats отдаёт историю спреда наблюдения id за период (1h, 6h, 24h, 7d, 30d):
+// точки графика (time - unix ms) и перцентили валового и чистого спреда в долях.
+func (sc *SpreadMonitorController) AjaxGetSpreadStats(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	id, _ := strconv.Atoi(c.PostForm("id"))
+	period := c.DefaultPostForm("period", "24h")
+	history, err := sc.service.History(c.Request.Context(), user.ID, id, period)
+	if err != nil {
+		sc.respondError(c, err)
+		return
+	}
+
+	points := make([]gin.H, 0, len(history.Points))
+	for _, p := range history.Points {
+		points = append(points, gin.H{
+			"time":   p.SampleTime.UnixMilli(),
+			"bid1":   p.Bid1,
+			"ask1":   p.Ask1,
+			"bid2":   p.Bid2,
+			"ask2":   p.Ask2,
+			"buy_on": p.BuyOn,
+			"gross":  p.Gross,
+			"net":    p.Net,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"error":     false,
+		"period":    period,
+		"data":      points,
+		"gross":     history.Gross,
+		"net":       history.Net,
+		"last":      history.Last,
+		"truncated": history.Truncated,
+	})
+}
+
+func (sc *SpreadMonitorController) respondError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrSpreadWatchNotFound) {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "spread watch not found"})
+		return
+	}
+	logger.Error().Err(err).Msg("spread monitor request failed")
+	c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/services"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taxYearsShown - сколько последних налоговых лет предлагается на странице /tax/.
+const taxYearsShown = 6
+
+// TaxController - налоговая выгрузка (/tax/): продажи SPOT по FIFO и доходы FUTURES
+// за год в CSV для Koinly, CoinTracking и Form 8949, курсы к фиатным валютам.
+type TaxController struct {
+	service *services.TaxService
+}
+
+// NewTaxController создаёт новый экземпляр TaxController.
+func NewTaxController() *TaxController {
+	return &TaxController{
+		service: services.NewTaxService(),
+	}
+}
+
+// Index отображает форму выгрузки и загруженные курсы.
+func (tc *TaxController) Index(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	currencies, err := tc.service.Currencies()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get fiat currencies")
+	}
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	current := time.Now().In(loc).Year()
+	years := make([]int, 0, taxYearsShown)
+	for year := current; year > current-taxYearsShown; year-- {
+		years = append(years, year)
+	}
+
+	c.HTML(http.StatusOK, "tax/index.html", gin.H{
+		"Title":       "Tax Export",
+		"User":        user,
+		"Currencies":  currencies,
+		"Years":       years,
+		"DefaultYear": current - 1,
+		"Formats":     services.TaxFormats,
+		"Timezone":    loc.String(),
+	})
+}
+
+// AjaxGetTaxSummary считает итоги года: year и currency.
+func (tc *TaxController) AjaxGetTaxSummary(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	user := userVal.(*models.User)
+
+	year, _ := strconv.Atoi(c.PostForm("year"))
+	report, err := tc.service.Build(user, year, c.PostForm("currency"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": taxErrorText(err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "currency": report.Currency, "data": report.Summary})
+}
+
+// Export отдаёт CSV-файл выгрузки: year, currency и format (koinly, cointracking, form8949).
+func (tc *TaxController) Export(c *gin.Context) {
+	userVal, ok := c.Get("user")
+	if !ok {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	user := userVal.(*models.User)
+
+	format := c.Query("format")
+	known := false
+	for _, f := range services.TaxFormats {
+		known = known || f == format
+	}
+	if !known {
+		c.String(http.StatusBadRequest, "unknown export format")
+		return
+	}
+	year, _ := strconv.Atoi(c.Query("year"))
+	report, err := tc.service.Build(user, year, c.Query("currency"))
+	if err != nil {
+		c.String(http.StatusBadRequest, taxErrorText(err))
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": services.TaxFileName(report, format)}))
+	c.Status(http.StatusOK)
+	if err := services.WriteTaxCSV(c.Writer, report, format); err != nil {
+		logger.Error().Err(err).Int("uid", user.ID).Msg("tax export failed")
+	}
+}
+
+// AjaxGetRates отдаёт загруженные курсы по парам актив/валюта.
+func (tc *TaxController) AjaxGetRates(c *gin.Context) {
+	if _, exists := c.Get("user"); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	series, err := tc.service.RateSeries()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to get fiat rates")
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "failed to load rates"})
+		return
+	}
+	rows := make([]gin.H, 0, len(series))
+	for _, s := range series {
+		rows = append(rows, gin.H{
+			"asset":    s.Asset,
+			"currency": s.Currency,
+			"count":    s.Count,
+			"from":     s.From.Format("2006-01-02"),
+			"to":       s.To.Format("2006-01-02"),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": rows})
+}
+
+// AjaxImportRates загружает курсы из CSV (только администратор): asset, currency и file.
+func (tc *TaxController) AjaxImportRates(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if !userVal.(*models.User).IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "File Not Attached"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Can not read data from file"})
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "Can not read data from file"})
+		return
+	}
+
+	count, err := tc.service.ImportRates(c.PostForm("asset"), c.PostForm("currency"), content)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false, "data": count})
+}
+
+// AjaxDeleteRates удаляет курсы пары актив/валюта (только администратор).
+func (tc *TaxController) AjaxDeleteRates(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if !userVal.(*models.User).IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if err := tc.service.DeleteRates(c.PostForm("asset"), c.PostForm("currency")); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "error": false})
+}
+
+// taxErrorText возвращает текст ошибки для пользователя; непредвиденные ошибки пишутся в лог.
+func taxErrorText(err error) string {
+	if !errors.Is(err, services.ErrFiatRateMissing) {
+		logger.Error().Err(err).Msg("tax export request failed")
+	}
+	return err.Error()
+}
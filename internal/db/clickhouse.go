@@ -0,0 +1,146 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"ctweb/internal/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClickHouseClient - минимальный клиент HTTP-интерфейса ClickHouse.
+//
+// Параметры запросов передаются через подстановки {name:Type} и параметры
+// param_<name>, поэтому значения никогда не склеиваются с текстом SQL.
+type ClickHouseClient struct {
+	baseURL      string
+	database     string
+	user         string
+	password     string
+	maxBatchSize int
+	client       *http.Client
+}
+
+// NewClickHouseClient создаёт клиент по настройкам из конфигурации.
+func NewClickHouseClient(cfg config.ClickHouseConfig) *ClickHouseClient {
+	scheme := "http"
+	if cfg.UseTLS {
+		scheme = "https"
+	}
+	return &ClickHouseClient{
+		baseURL:      fmt.Sprintf("%s://%s:%d/", scheme, cfg.Host, cfg.Port),
+		database:     cfg.Database,
+		user:         cfg.User,
+		password:     cfg.Password,
+		maxBatchSize: cfg.MaxBatchSize,
+		client:       &http.Client{Timeout: time.Duration(cfg.ConnectTimeout) * time.Second},
+	}
+}
+
+// MaxBatchSize - максимум строк в одном INSERT (0 - без ограничения).
+func (c *ClickHouseClient) MaxBatchSize() int {
+	return c.maxBatchSize
+}
+
+func (c *ClickHouseClient) do(ctx context.Context, query string, params map[string]string, body io.Reader) ([]byte, error) {
+	values := url.Values{}
+	values.Set("database", c.database)
+	values.Set("output_format_json_quote_64bit_integers", "0")
+	for name, value := range params {
+		values.Set("param_"+name, value)
+	}
+
+	var reqBody io.Reader = strings.NewReader(query)
+	if body != nil {
+		// При INSERT запрос передаётся в параметре, а данные - телом.
+		values.Set("query", query)
+		reqBody = body
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"?"+values.Encode(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("clickhouse: %s", strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// Ping проверяет доступность сервера.
+func (c *ClickHouseClient) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "SELECT 1", nil, nil)
+	return err
+}
+
+// Exec выполняет запрос без результата (DDL, ALTER, DELETE).
+func (c *ClickHouseClient) Exec(ctx context.Context, query string, params map[string]string) error {
+	_, err := c.do(ctx, query, params, nil)
+	return err
+}
+
+// Query выполняет SELECT и раскладывает строки результата в dest (указатель на срез структур).
+// К запросу добавляется FORMAT JSON, поля сопоставляются по json-тегам.
+func (c *ClickHouseClient) Query(ctx context.Context, query string, params map[string]string, dest interface{}) error {
+	data, err := c.do(ctx, query+" FORMAT JSON", params, nil)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("clickhouse decode response: %w", err)
+	}
+	if len(envelope.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, dest); err != nil {
+		return fmt.Errorf("clickhouse decode rows: %w", err)
+	}
+	return nil
+}
+
+// InsertJSONEachRow вставляет строки в таблицу в формате JSONEachRow.
+func (c *ClickHouseClient) InsertJSONEachRow(ctx context.Context, table string, rows []interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("clickhouse encode row: %w", err)
+		}
+	}
+	_, err := c.do(ctx, "INSERT INTO "+table+" FORMAT JSONEachRow", nil, &body)
+	return err
+}
+
+// ClickHouseTimeFormat - формат значений DateTime('UTC') в параметрах и JSON.
+const ClickHouseTimeFormat = "2006-01-02 15:04:05"
+
+// ClickHouseTime форматирует время для параметров типа DateTime('UTC').
+func ClickHouseTime(t time.Time) string {
+	return t.UTC().Format(ClickHouseTimeFormat)
+}
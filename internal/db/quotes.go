@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Движки БД котировок (databases.quotes.engine).
+const (
+	QuotesEngineMySQL      = "mysql"
+	QuotesEngineClickHouse = "clickhouse"
+)
+
+// ErrQuotesNotConfigured - БД котировок не настроена или не подключилась при старте.
+var ErrQuotesNotConfigured = errors.New("quotes database is not configured")
+
+// Quotes - соединение с БД котировок, если она размещена в MySQL.
+var Quotes *sql.DB
+
+// QuotesClickHouse - клиент БД котировок, если она размещена в ClickHouse.
+var QuotesClickHouse *ClickHouseClient
+
+// ConnectQuotes подключает БД котировок (свечи, спреды).
+//
+// В отличие от Connect(), ошибка не завершает программу: без БД котировок
+// приложение работает, а графики и аналитика возвращают ErrQuotesNotConfigured.
+// Пустой databases.quotes.engine - хранилище отключено, возвращается nil.
+func ConnectQuotes() error {
+	cfg := config.Get()
+	target := cfg.Databases.Quotes
+
+	switch target.Engine {
+	case "":
+		return nil
+	case QuotesEngineMySQL:
+		conn, err := sql.Open("mysql", cfg.GetQuotesMySQLDSN())
+		if err != nil {
+			return fmt.Errorf("open quotes database: %w", err)
+		}
+		pool := target.MySQL.Pool
+		conn.SetMaxOpenConns(pool.MaxOpenConns)
+		conn.SetMaxIdleConns(pool.MaxIdleConns)
+		conn.SetConnMaxLifetime(pool.ConnMaxLifetime)
+		conn.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+		if err := conn.Ping(); err != nil {
+			conn.Close()
+			return fmt.Errorf("ping quotes database: %w", err)
+		}
+		Quotes = conn
+		return nil
+	case QuotesEngineClickHouse:
+		client := NewClickHouseClient(target.ClickHouse)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx); err != nil {
+			return fmt.Errorf("ping quotes clickhouse: %w", err)
+		}
+		QuotesClickHouse = client
+		return nil
+	default:
+		return fmt.Errorf("unsupported databases.quotes.engine: %s", target.Engine)
+	}
+}
+
+// QuotesEngine возвращает движок подключённой БД котировок ("" - не подключена).
+func QuotesEngine() string {
+	switch {
+	case Quotes != nil:
+		return QuotesEngineMySQL
+	case QuotesClickHouse != nil:
+		return QuotesEngineClickHouse
+	default:
+		return ""
+	}
+}
+
+// CloseQuotes закрывает соединение с БД котировок.
+func CloseQuotes() error {
+	QuotesClickHouse = nil
+	if Quotes != nil {
+		err := Quotes.Close()
+		Quotes = nil
+		return err
+	}
+	return nil
+}
@@ -131,7 +131,8 @@ func shouldAudit(method, path string) bool {
 		return true
 	}
 
-	if strings.Contains(p, "/ajax_get") {
+	if strings.Contains(p, "/ajax_get") || strings.Contains(p, "/ajax_instruments") || strings.Contains(p, "/ajax_funding_") || strings.Contains(p, "/ajax_coin_info") ||
+		strings.Contains(p, "/ajax_check_status") || strings.Contains(p, "/ajax_daemon_stat") {
 		return false
 	}
 
@@ -158,6 +159,24 @@ func inferAuditAction(path string, method string) (string, string) {
 		resourceType = "exchange"
 	} else if strings.HasPrefix(p, "/exchange_accounts") {
 		resourceType = "exchange_account"
+	} else if strings.HasPrefix(p, "/coins") {
+		resourceType = "coin"
+	} else if strings.HasPrefix(p, "/daemon") {
+		resourceType = "daemon"
+	} else if strings.HasPrefix(p, "/spread_monitor") {
+		resourceType = "spread_watch"
+	} else if strings.HasPrefix(p, "/funding_arbitrage") {
+		resourceType = "position_group"
+	} else if strings.HasPrefix(p, "/alerts") {
+		resourceType = "alert_rule"
+	} else if strings.HasPrefix(p, "/profile/ajax_import_backup") {
+		resourceType = "backup"
+	} else if strings.HasPrefix(p, "/profile") {
+		resourceType = "profile"
+	} else if strings.HasPrefix(p, "/reports") {
+		resourceType = "pnl_report"
+	} else if strings.HasPrefix(p, "/tax") {
+		resourceType = "fiat_rate"
 	} else if strings.HasPrefix(p, "/auth") {
 		resourceType = "auth"
 	}
@@ -165,10 +184,30 @@ func inferAuditAction(path string, method string) (string, string) {
 	action := m + "_" + resourceType
 	if strings.Contains(p, "ajax_create") {
 		action = "CREATE_" + resourceType
-	} else if strings.Contains(p, "ajax_edit") {
+	} else if strings.Contains(p, "ajax_edit") || strings.Contains(p, "ajax_save") || strings.Contains(p, "ajax_update") {
 		action = "UPDATE_" + resourceType
 	} else if strings.Contains(p, "ajax_delete") {
 		action = "DELETE_" + resourceType
+	} else if strings.Contains(p, "ajax_sync") {
+		action = "SYNC_" + resourceType
+	} else if strings.Contains(p, "ajax_import") {
+		action = "IMPORT_" + resourceType
+	} else if strings.Contains(p, "ajax_snapshot") {
+		action = "SNAPSHOT_" + resourceType
+	} else if strings.Contains(p, "ajax_test") {
+		action = "TEST_" + resourceType
+	} else if strings.Contains(p, "ajax_send") {
+		action = "SEND_" + resourceType
+	} else if strings.Contains(p, "ajax_reconcile") {
+		action = "RECONCILE_" + resourceType
+	} else if strings.Contains(p, "ajax_disable") {
+		action = "DISABLE_" + resourceType
+	} else if strings.Contains(p, "ajax_gen_") {
+		action = "GENERATE_" + resourceType
+	} else if strings.Contains(p, "ajax_start") {
+		action = "START_" + resourceType
+	} else if strings.Contains(p, "ajax_stop") {
+		action = "STOP_" + resourceType
 	} else if p == "/auth/login" {
 		action = "LOGIN"
 	} else if p == "/auth/logout" {
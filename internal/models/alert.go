@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Типы правил уведомлений (ALERT_RULES.RULE_TYPE).
+const (
+	AlertTypeLoss        = "loss"         // PnL позиции опустился до -THRESHOLD
+	AlertTypePrice       = "price"        // Цена пересекла уровень THRESHOLD в направлении DIRECTION
+	AlertTypeFundingFlip = "funding_flip" // Текущая ставка funding сменила знак
+)
+
+// Направления пересечения уровня цены (ALERT_RULES.DIRECTION).
+const (
+	AlertDirectionAbove = "above"
+	AlertDirectionBelow = "below"
+)
+
+// AlertRule - правило уведомления пользователя по позиции или инструменту (таблица ALERT_RULES).
+type AlertRule struct {
+	ID            int        `json:"id"`
+	UID           int        `json:"uid"`
+	Type          string     `json:"type"`
+	PositionID    *int       `json:"position_id"`
+	ExID          int        `json:"exid"`
+	Exchange      string     `json:"exchange"`
+	MarketType    string     `json:"market"`
+	Symbol        string     `json:"symbol"`
+	Direction     string     `json:"direction"`
+	Threshold     float64    `json:"threshold"`
+	Channel       string     `json:"channel"`
+	Target        string     `json:"target"`
+	IsActive      bool       `json:"is_active"`
+	IsTriggered   bool       `json:"is_triggered"`
+	LastValue     *float64   `json:"last_value"`
+	DateCreate    time.Time  `json:"date_create"`
+	DateChecked   *time.Time `json:"date_checked"`
+	DateTriggered *time.Time `json:"date_triggered"`
+	LastError     string     `json:"last_error"`
+}
+
+// AlertEvent - срабатывание правила и результат доставки (таблица ALERT_EVENTS).
+type AlertEvent struct {
+	ID         int       `json:"id"`
+	RuleID     int       `json:"rule_id"`
+	UID        int       `json:"uid"`
+	DateCreate time.Time `json:"date_create"`
+	Subject    string    `json:"subject"`
+	Message    string    `json:"message"`
+	Delivered  bool      `json:"delivered"`
+	Error      string    `json:"error"`
+}
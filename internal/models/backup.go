@@ -0,0 +1,129 @@
+package models
+
+import "time"
+
+// BackupFormat и BackupVersion - признак файла резервной копии и версия его схемы.
+// Версия увеличивается при несовместимом изменении структуры; импорт принимает версии
+// не выше текущей.
+const (
+	BackupFormat  = "ctweb-backup"
+	BackupVersion = 1
+)
+
+// Backup - резервная копия позиций и настроек пользователя. Даты хранятся в UTC,
+// суммы операций - строками в том виде, в котором они лежат в DECIMAL-колонках.
+// ID в файле - ID исходной базы; при восстановлении они заменяются новыми.
+type Backup struct {
+	Format    string           `json:"format"`
+	Version   int              `json:"version"`
+	Exported  time.Time        `json:"exported"`
+	User      BackupUser       `json:"user"`
+	Settings  BackupSettings   `json:"settings"`
+	Exchanges []BackupExchange `json:"exchanges"`
+	Accounts  []BackupAccount  `json:"accounts"`
+	Groups    []BackupGroup    `json:"groups"`
+	Positions []BackupPosition `json:"positions"`
+}
+
+// BackupUser - владелец копии (для справки, при восстановлении не используется).
+type BackupUser struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+}
+
+// BackupSettings - настройки пользователя.
+type BackupSettings struct {
+	Timezone           string                    `json:"timezone"`
+	ReportSubscription *BackupReportSubscription `json:"report_subscription"`
+	AlertRules         []BackupAlertRule         `json:"alert_rules"`
+}
+
+// BackupReportSubscription - подписка на PnL-отчёты.
+type BackupReportSubscription struct {
+	Daily   bool   `json:"daily"`
+	Monthly bool   `json:"monthly"`
+	Email   string `json:"email"`
+}
+
+// BackupAlertRule - правило уведомлений; PositionID - ID позиции из этого же файла.
+type BackupAlertRule struct {
+	Type       string  `json:"type"`
+	PositionID *int    `json:"position_id"`
+	ExchangeID int     `json:"exchange_id"`
+	MarketType string  `json:"market"`
+	Symbol     string  `json:"symbol"`
+	Direction  string  `json:"direction"`
+	Threshold  float64 `json:"threshold"`
+	Channel    string  `json:"channel"`
+	Target     string  `json:"target"`
+	IsActive   bool    `json:"is_active"`
+}
+
+// BackupExchange - биржа исходной базы: при восстановлении сопоставляется по названию.
+type BackupExchange struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// BackupAccount - аккаунт биржи без ключей API: при восстановлении позиции привязываются
+// к аккаунту пользователя с тем же названием на той же бирже.
+type BackupAccount struct {
+	ID         int    `json:"id"`
+	ExchangeID int    `json:"exchange_id"`
+	Name       string `json:"name"`
+}
+
+// BackupGroup - группа позиций.
+type BackupGroup struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Strategy  string     `json:"strategy"`
+	EntryDiff *float64   `json:"entry_diff"`
+	Status    string     `json:"status"` // OPEN, CLOSE
+	Created   time.Time  `json:"created"`
+	Closed    *time.Time `json:"closed"`
+}
+
+// BackupPosition - позиция со всеми операциями.
+type BackupPosition struct {
+	ID           int                 `json:"id"`
+	Name         string              `json:"name"`
+	ExchangeID   int                 `json:"exchange_id"`
+	AccountID    *int                `json:"account_id"`
+	GroupID      *int                `json:"group_id"`
+	MarketType   string              `json:"market"`
+	Status       string              `json:"status"` // OPEN, CLOSE
+	Created      time.Time           `json:"created"`
+	Closed       *time.Time          `json:"closed"`
+	Transactions []BackupTransaction `json:"transactions"`
+}
+
+// BackupTransaction - операция позиции. Пустые (NULL) суммы - nil.
+type BackupTransaction struct {
+	ID            int       `json:"id"`
+	Type          string    `json:"type"` // TRADE, FUNDING
+	Price         *string   `json:"price"`
+	Volume        *string   `json:"volume"`
+	Fee           *string   `json:"fee"`
+	FeeBase       *string   `json:"fee_base"`
+	Funding       *string   `json:"funding"`
+	TransDate     time.Time `json:"trans_date"`
+	SourceOrderID *string   `json:"source_order_id"`
+	SourceTradeID *string   `json:"source_trade_id"`
+}
+
+// BackupRestoreResult - итог восстановления (при пробном запуске - что было бы сделано).
+type BackupRestoreResult struct {
+	DryRun                bool        `json:"dry_run"`
+	PositionsCreated      int         `json:"positions_created"`
+	PositionsMatched      int         `json:"positions_matched"` // Уже были у пользователя: операции дописаны в них
+	PositionsSkipped      int         `json:"positions_skipped"`
+	GroupsCreated         int         `json:"groups_created"`
+	TransactionsCreated   int         `json:"transactions_created"`
+	TransactionsDuplicate int         `json:"transactions_duplicate"`
+	AlertRulesCreated     int         `json:"alert_rules_created"`
+	AlertRulesSkipped     int         `json:"alert_rules_skipped"`
+	SubscriptionRestored  bool        `json:"subscription_restored"`
+	PositionIDs           map[int]int `json:"position_ids"` // ID позиции в файле -> ID после восстановления
+	Warnings              []string    `json:"warnings"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// BalanceSnapshot - снимок балансов аккаунта биржи (таблица ACCOUNT_BALANCE_SNAPSHOTS).
+// USDValue - сумма оценённых активов, UnpricedAssets - активы, для которых цена не найдена.
+type BalanceSnapshot struct {
+	ID             int               `json:"id"`
+	AccountID      int               `json:"account_id"`
+	UID            int               `json:"uid"`
+	SnapshotAt     time.Time         `json:"snapshot_at"`
+	USDValue       float64           `json:"usd_value"`
+	UnpricedAssets int               `json:"unpriced_assets"`
+	Items          []*AccountBalance `json:"items,omitempty"`
+}
+
+// AccountBalance - остаток актива на кошельке аккаунта в момент снимка (таблица ACCOUNT_BALANCES).
+type AccountBalance struct {
+	Wallet   string   `json:"wallet"`
+	Asset    string   `json:"asset"`
+	Free     float64  `json:"free"`
+	Locked   float64  `json:"locked"`
+	USDValue *float64 `json:"usd_value"` // nil - цена актива не найдена
+}
+
+// Total - полный остаток актива (свободный + заблокированный).
+func (b *AccountBalance) Total() float64 {
+	return b.Free + b.Locked
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Candle - свеча OHLCV из БД котировок (таблица CANDLES).
+type Candle struct {
+	ExID       int       `json:"exid"`
+	MarketType string    `json:"market"`
+	Symbol     string    `json:"symbol"`
+	Interval   string    `json:"interval"`
+	OpenTime   time.Time `json:"open_time"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     float64   `json:"volume"`
+}
+
+// ContractRef - контракт открытых позиций и дата открытия самой ранней из них.
+type ContractRef struct {
+	ExID       int
+	MarketType string
+	Symbol     string
+	Since      time.Time
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Coin - монета из справочника COINS.
+type Coin struct {
+	ID       int       `json:"id"`
+	Symbol   string    `json:"symbol"`
+	Name     string    `json:"name"`
+	Icon     *string   `json:"icon"` // Путь к иконке относительно /assets: nil - не загружалась, "" - нет в источнике
+	DateSync time.Time `json:"date_sync"`
+}
+
+// CoinNetwork - сеть ввода/вывода монеты на бирже (таблица COIN_NETWORKS).
+type CoinNetwork struct {
+	CoinID          int       `json:"coin_id"`
+	ExID            int       `json:"exid"`
+	ExchangeName    string    `json:"exchange_name"`
+	Network         string    `json:"network"`
+	NetworkName     string    `json:"network_name"`
+	ContractAddress string    `json:"contract_address"`
+	DepositEnabled  bool      `json:"deposit_enabled"`
+	WithdrawEnabled bool      `json:"withdraw_enabled"`
+	WithdrawFee     float64   `json:"withdraw_fee"`
+	WithdrawMin     float64   `json:"withdraw_min"`
+	Confirmations   int       `json:"confirmations"`
+	DateSync        time.Time `json:"date_sync"`
+}
+
+// CoinSummary - строка списка монет: количество бирж и сетей, доступность депозита и вывода.
+type CoinSummary struct {
+	Coin
+	Exchanges        int `json:"exchanges"`
+	Networks         int `json:"networks"`
+	DepositNetworks  int `json:"deposit_networks"`
+	WithdrawNetworks int `json:"withdraw_networks"`
+}
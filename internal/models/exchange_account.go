@@ -21,25 +21,85 @@ import "time"
 //   - DateModify: дата последнего изменения (nullable)
 //   - UserCreated: ID пользователя, создавшего запись (nullable)
 //   - UserModify: ID пользователя, изменившего запись (nullable)
+//   - LastCheck: результат последней проверки ключа API (nil - не проверялся)
+//   - ParentID: ID мастер-аккаунта, если аккаунт - субаккаунт (nil - мастер/самостоятельный)
+//   - SubAccountLabel: имя субаккаунта на бирже (может быть пустым)
 type ExchangeAccount struct {
-	ID          int        `json:"id" db:"ID"`
-	ExID        int        `json:"exid" db:"EXID"`
-	UID         int        `json:"uid" db:"UID"`
-	AccountName string     `json:"account_name" db:"ACCOUNT_NAME"`
-	Priority    int        `json:"priority" db:"PRIORITY"`
-	Active      bool       `json:"active" db:"ACTIVE"`
-	ApiKey      string     `json:"api_key" db:"API_KEY"`
-	SecretKey   string     `json:"secret_key" db:"SECRET_KEY"`
-	AddKey      *string    `json:"add_key,omitempty" db:"ADD_KEY"`
-	Note        *string    `json:"note,omitempty" db:"NOTE"`
-	Deleted     bool       `json:"deleted" db:"DELETED"`
-	DateCreate  time.Time  `json:"date_create" db:"TIMESTAMP_X"`
-	DateModify  *time.Time `json:"date_modify,omitempty" db:"DATE_MODIFY"`
-	UserCreated *int       `json:"user_created,omitempty" db:"USER_CREATED"`
-	UserModify  *int       `json:"user_modify,omitempty" db:"USER_MODIFY"`
+	ID          int              `json:"id" db:"ID"`
+	ExID        int              `json:"exid" db:"EXID"`
+	UID         int              `json:"uid" db:"UID"`
+	AccountName string           `json:"account_name" db:"ACCOUNT_NAME"`
+	Priority    int              `json:"priority" db:"PRIORITY"`
+	Active      bool             `json:"active" db:"ACTIVE"`
+	ApiKey      string           `json:"api_key" db:"API_KEY"`
+	SecretKey   string           `json:"secret_key" db:"SECRET_KEY"`
+	AddKey      *string          `json:"add_key,omitempty" db:"ADD_KEY"`
+	Note        *string          `json:"note,omitempty" db:"NOTE"`
+	Deleted     bool             `json:"deleted" db:"DELETED"`
+	DateCreate  time.Time        `json:"date_create" db:"TIMESTAMP_X"`
+	DateModify  *time.Time       `json:"date_modify,omitempty" db:"DATE_MODIFY"`
+	UserCreated *int             `json:"user_created,omitempty" db:"USER_CREATED"`
+	UserModify  *int             `json:"user_modify,omitempty" db:"USER_MODIFY"`
+	LastCheck   *AccountKeyCheck `json:"last_check,omitempty"`
+
+	ParentID        *int   `json:"parent_id,omitempty" db:"PARENT_ID"`
+	SubAccountLabel string `json:"sub_account_label,omitempty" db:"SUB_ACCOUNT_LABEL"`
 }
 
 // IsActive возвращает true, если аккаунт активен и не удалён.
 func (a *ExchangeAccount) IsActive() bool {
 	return a.Active && !a.Deleted
 }
+
+// IsSubAccount возвращает true, если аккаунт - субаккаунт другого аккаунта.
+func (a *ExchangeAccount) IsSubAccount() bool {
+	return a.ParentID != nil
+}
+
+// AccountRollup - мастер-аккаунт вместе с субаккаунтами: сумма последних снимков балансов
+// и количество открытых позиций (отчёты по мастер-аккаунту).
+type AccountRollup struct {
+	MasterID         int     `json:"master_id"`
+	MasterName       string  `json:"master_name"`
+	ExID             int     `json:"exid"`
+	AccountIDs       []int   `json:"account_ids"`
+	SubAccounts      int     `json:"sub_accounts"`
+	USDValue         float64 `json:"usd_value"`
+	UnpricedAssets   int     `json:"unpriced_assets"`
+	MissingSnapshots int     `json:"missing_snapshots"` // Аккаунты без снимка балансов
+	OpenPositions    int     `json:"open_positions"`
+}
+
+// StoredAccountKeys - ключи аккаунта в том виде, в каком они лежат в EXCHANGE_ACCOUNTS
+// (зашифрованные или, для старых записей, открытым текстом).
+type StoredAccountKeys struct {
+	ID        int
+	ApiKey    string
+	SecretKey string
+	AddKey    *string
+}
+
+// Статусы проверки ключа API (LAST_CHECK_STATUS).
+const (
+	KeyCheckValid       = "valid"       // Биржа приняла ключ
+	KeyCheckInvalid     = "invalid"     // Неверный ключ, подпись или passphrase
+	KeyCheckIPBlocked   = "ip_blocked"  // Ключ привязан к IP, и сервер в whitelist не входит
+	KeyCheckError       = "error"       // Биржа недоступна или вернула неожиданный ответ
+	KeyCheckUnsupported = "unsupported" // Коннектор биржи не умеет проверять ключи
+)
+
+// AccountKeyCheck - результат последней проверки ключа API аккаунта.
+type AccountKeyCheck struct {
+	CheckedAt    time.Time `json:"checked_at"`
+	Status       string    `json:"status"`
+	Message      string    `json:"message,omitempty"`
+	Read         bool      `json:"read"`
+	Trade        bool      `json:"trade"`
+	Withdraw     bool      `json:"withdraw"`
+	IPRestricted bool      `json:"ip_restricted"`
+}
+
+// Valid сообщает, что биржа приняла ключ.
+func (c *AccountKeyCheck) Valid() bool {
+	return c != nil && c.Status == KeyCheckValid
+}
@@ -0,0 +1,22 @@
+package models
+
+// FeeSchedule - ставки комиссии биржи для рынка (в процентах, 0.1 = 0.1%).
+//
+// Если AccountID != nil, ставка является VIP-переопределением для аккаунта
+// (таблица EXCHANGE_ACCOUNT_FEES), иначе - ставкой биржи по умолчанию (EXCHANGE_FEES).
+type FeeSchedule struct {
+	ExID       int     `json:"exid"`
+	AccountID  *int    `json:"account_id,omitempty"`
+	MarketType string  `json:"market"`
+	VIPTier    string  `json:"vip_tier,omitempty"`
+	MakerFee   float64 `json:"maker_fee"`
+	TakerFee   float64 `json:"taker_fee"`
+}
+
+// Rate возвращает ставку в долях (0.001 для 0.1%) для maker или taker.
+func (f *FeeSchedule) Rate(maker bool) float64 {
+	if maker {
+		return f.MakerFee / 100
+	}
+	return f.TakerFee / 100
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// FundingRate - ставка финансирования бессрочного контракта на момент расчёта
+// (таблица FUNDING_RATES). Rate - в долях (0.0001 = 0.01%).
+type FundingRate struct {
+	ExID        int       `json:"exid"`
+	Symbol      string    `json:"symbol"`
+	FundingTime time.Time `json:"funding_time"`
+	Rate        float64   `json:"rate"`
+	MarkPrice   *float64  `json:"mark_price,omitempty"`
+	Source      string    `json:"source"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Instrument - торговый инструмент биржи из справочника INSTRUMENTS.
+//
+// Справочник заполняется синхронизацией через коннекторы бирж и используется
+// для автодополнения и проверки контракта позиции.
+type Instrument struct {
+	ID            int
+	ExID          int
+	Symbol        string
+	MarketType    string
+	BaseAsset     string
+	QuoteAsset    string
+	TickSize      float64
+	LotSize       float64
+	MinQty        float64
+	ContractValue float64
+	Status        string
+	DateSync      time.Time
+}
+
+// IsTrading возвращает true, если инструмент торгуется.
+func (i *Instrument) IsTrading() bool {
+	return i.Status == "TRADING"
+}
@@ -2,10 +2,29 @@ package models
 
 import "time"
 
+// PositionFilterNoAccount - значение PositionFilter.AccountID для позиций без аккаунта.
+const PositionFilterNoAccount = -1
+
+// PositionFilter - фильтр списка позиций (нулевое значение - все позиции пользователя).
+type PositionFilter struct {
+	PositionID int    // > 0 - одна позиция
+	AccountID  int    // > 0 - позиции аккаунта, PositionFilterNoAccount - позиции без аккаунта
+	GroupID    int    // > 0 - ноги группы
+	Grouped    bool   // Только позиции, входящие в группы
+	Status     string // OPEN, CLOSE; пусто - позиции в любом статусе
+	// TradedBefore - не нулевое: только позиции с операциями раньше этого момента
+	TradedBefore time.Time
+}
+
 type PositionSummary struct {
 	PositionID       int
 	ContractName     string
 	ExchangeName     string
+	ExchangeID       int
+	AccountID        *int // nil - позиция не привязана к аккаунту биржи
+	AccountName      string
+	GroupID          *int // nil - позиция не входит в группу
+	GroupName        string
 	MarketType       string
 	Status           string
 	Created          *time.Time
@@ -22,6 +41,9 @@ type PositionDetail struct {
 	PositionID       int
 	ContractName     string
 	ExchangeName     string
+	ExchangeID       int
+	AccountID        *int // nil - позиция не привязана к аккаунту биржи
+	AccountName      string
 	MarketType       string
 	Status           string
 	Created          *time.Time
@@ -45,3 +67,22 @@ type PositionTransaction struct {
 	Funding   float64
 	TransDate *time.Time
 }
+
+// Стратегии групп позиций, открытых со страницы арбитража ставок финансирования (POS_GROUPS.STRATEGY).
+const (
+	GroupStrategySpotFutures    = "SPOT+FUTURES"
+	GroupStrategyFuturesFutures = "FUTURES+FUTURES"
+)
+
+// PositionGroup - группа позиций (таблица POS_GROUPS): ноги хеджированной сделки,
+// которые показываются вместе и закрываются вместе.
+type PositionGroup struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Name      string     `json:"name"`
+	Strategy  string     `json:"strategy"`   // '' - группа собрана вручную
+	EntryDiff *float64   `json:"entry_diff"` // Разница ставок финансирования за 8 часов при открытии
+	Status    string     `json:"status"`     // OPEN, CLOSE - как у позиций
+	Created   time.Time  `json:"created"`
+	Closed    *time.Time `json:"closed"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Статусы сверки позиций с биржей (POS_RECONCILIATION.STATUS).
+const (
+	ReconcileOK        = "ok"         // Расчёт совпадает с биржей в пределах допуска
+	ReconcileMismatch  = "mismatch"   // Размер или средняя цена расходятся
+	ReconcileMissing   = "missing"    // Позиция открыта в системе, на бирже её нет
+	ReconcileUntracked = "untracked"  // Позиция есть на бирже, в системе её нет
+	ReconcileNoAccount = "no_account" // У пользователя нет активного аккаунта на бирже
+	ReconcileError     = "error"      // Биржа не ответила или коннектор не умеет читать данные
+)
+
+// ReconciliationItem - результат сверки контракта (фьючерсы) или актива (спот).
+// Несколько открытых позиций по одному контракту сверяются суммарно.
+type ReconciliationItem struct {
+	ExID         int      `json:"exid"`
+	ExchangeName string   `json:"exchange_name"`
+	AccountID    *int     `json:"account_id"` // nil - все аккаунты пользователя на бирже
+	AccountName  string   `json:"account_name,omitempty"`
+	MarketType   string   `json:"market_type"`
+	Symbol       string   `json:"symbol"`
+	Asset        string   `json:"asset,omitempty"`
+	PositionIDs  []int    `json:"position_ids"`
+	CalcSize     float64  `json:"calc_size"`
+	LiveSize     *float64 `json:"live_size"`
+	CalcPrice    *float64 `json:"calc_price"`
+	LivePrice    *float64 `json:"live_price"`
+	Status       string   `json:"status"`
+	Message      string   `json:"message,omitempty"`
+}
+
+// IsMismatch сообщает, что расчёт позиции не сходится с биржей.
+func (i *ReconciliationItem) IsMismatch() bool {
+	switch i.Status {
+	case ReconcileMismatch, ReconcileMissing, ReconcileUntracked:
+		return true
+	}
+	return false
+}
+
+// ReconciliationReport - результат сверки всех открытых позиций пользователя.
+type ReconciliationReport struct {
+	UID        int                   `json:"uid"`
+	RunAt      time.Time             `json:"run_at"`
+	Items      []*ReconciliationItem `json:"items"`
+	Mismatches int                   `json:"mismatches"`
+}
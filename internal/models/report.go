@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Периоды PnL-отчётов (PNL_REPORTS.PERIOD).
+const (
+	ReportPeriodDaily   = "daily"
+	ReportPeriodMonthly = "monthly"
+)
+
+// ReportSubscription - подписка пользователя на рассылку отчётов (таблица REPORT_SUBSCRIPTIONS).
+type ReportSubscription struct {
+	UID     int    `json:"uid"`
+	Daily   bool   `json:"daily"`
+	Monthly bool   `json:"monthly"`
+	Email   string `json:"email"`
+}
+
+// PnLReport - сформированный отчёт за период (таблица PNL_REPORTS). HTML и CSV
+// заполняются только при чтении одного отчёта.
+type PnLReport struct {
+	ID          int        `json:"id"`
+	UID         int        `json:"uid"`
+	Period      string     `json:"period"`
+	PeriodStart time.Time  `json:"period_start"` // Календарные даты в часовом поясе Timezone
+	PeriodEnd   time.Time  `json:"period_end"`
+	Timezone    string     `json:"timezone"`
+	RealizedPnL float64    `json:"realized_pnl"`
+	HTML        string     `json:"-"`
+	CSV         string     `json:"-"`
+	DateCreate  time.Time  `json:"date_create"`
+	Email       string     `json:"email"`
+	DateEmailed *time.Time `json:"date_emailed"`
+	EmailError  string     `json:"email_error"`
+}
+
+// PositionPeriodTotals - операции позиции за период: число сделок, комиссии и funding.
+type PositionPeriodTotals struct {
+	PositionID int
+	Trades     int
+	Fee        float64
+	Funding    float64
+}
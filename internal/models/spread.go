@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// SpreadWatch - наблюдение за спредом пары между двумя биржами (таблица SPREAD_WATCHES).
+type SpreadWatch struct {
+	ID             int        `json:"id"`
+	UID            int        `json:"uid"`
+	ExID1          int        `json:"exid1"`
+	ExID2          int        `json:"exid2"`
+	Exchange1      string     `json:"exchange1"`
+	Exchange2      string     `json:"exchange2"`
+	MarketType     string     `json:"market"`
+	BaseAsset      string     `json:"base"`
+	QuoteAsset     string     `json:"quote"`
+	Symbol1        string     `json:"symbol1"`
+	Symbol2        string     `json:"symbol2"`
+	IsActive       bool       `json:"is_active"`
+	DateCreate     time.Time  `json:"date_create"`
+	DateLastSample *time.Time `json:"date_last_sample"`
+	LastError      string     `json:"last_error"`
+}
+
+// Pair возвращает пару в виде BASE/QUOTE.
+func (w *SpreadWatch) Pair() string {
+	return PairName(w.BaseAsset, w.QuoteAsset)
+}
+
+// PairName возвращает пару базовой и котируемой валют в виде BASE/QUOTE.
+func PairName(base, quote string) string {
+	return base + "/" + quote
+}
+
+// SpreadSample - замер спреда по наблюдению (таблица SPREADS БД котировок).
+// Gross, Fees, Net - в долях, BuyOn - биржа покупки (1 или 2).
+type SpreadSample struct {
+	WatchID    int       `json:"watch_id"`
+	SampleTime time.Time `json:"time"`
+	Bid1       float64   `json:"bid1"`
+	Ask1       float64   `json:"ask1"`
+	Bid2       float64   `json:"bid2"`
+	Ask2       float64   `json:"ask2"`
+	BuyOn      int       `json:"buy_on"`
+	Gross      float64   `json:"gross"`
+	Fees       float64   `json:"fees"`
+	Net        float64   `json:"net"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// FiatRate - дневной курс актива к фиатной валюте (таблица FIAT_RATES).
+type FiatRate struct {
+	Asset    string
+	Currency string
+	Date     time.Time // Дата курса (UTC)
+	Rate     float64   // Стоимость 1 Asset в Currency
+}
+
+// FiatRateSeries - загруженные курсы одной пары актив/валюта.
+type FiatRateSeries struct {
+	Asset    string    `json:"asset"`
+	Currency string    `json:"currency"`
+	Count    int       `json:"count"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+}
+
+// TaxTransaction - операция позиции для налогового расчёта (POS_TRANSACTIONS с данными позиции).
+type TaxTransaction struct {
+	ID           int
+	PositionID   int
+	ContractName string
+	ExchangeID   int
+	ExchangeName string
+	MarketType   string
+	OpType       string // TRADE, FUNDING
+	Price        float64
+	Volume       float64 // > 0 - покупка, < 0 - продажа
+	Fee          float64 // Комиссия в котируемой валюте
+	FeeBase      float64 // Комиссия покупки SPOT в базовом активе
+	Funding      float64
+	TransDate    time.Time // UTC
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// TelegramLink - Telegram-чат, привязанный к пользователю (таблица TELEGRAM_LINKS).
+type TelegramLink struct {
+	UID      int       `json:"uid"`
+	ChatID   int64     `json:"chat_id"`
+	Username string    `json:"username"`
+	DateLink time.Time `json:"date_link"`
+}
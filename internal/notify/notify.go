@@ -0,0 +1,74 @@
+// Package notify доставляет уведомления по правилам /alerts/ через внешние каналы
+// (email, webhook, Telegram). Каждый канал реализует Notifier; доступные каналы собираются
+// из настроек notify.* функцией FromConfig.
+package notify
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Каналы доставки (ALERT_RULES.CHANNEL).
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// ErrInvalidTarget - адрес получателя не подходит каналу.
+var ErrInvalidTarget = errors.New("invalid notification target")
+
+// Message - уведомление, независимое от канала.
+type Message struct {
+	Event       string                 // Тип события (тип правила), для webhook
+	Subject     string                 // Тема письма / заголовок
+	Text        string                 // Текст уведомления
+	HTML        string                 // HTML-версия текста (только email, "" - письмо без HTML)
+	Attachments []Attachment           // Вложения (только email)
+	Fields      map[string]interface{} // Значения, на которых сработало правило (webhook)
+	Time        time.Time              // Время события (UTC)
+}
+
+// Attachment - файл, вложенный в письмо.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Notifier - канал доставки уведомлений.
+type Notifier interface {
+	// Channel возвращает название канала (ChannelEmail, ChannelWebhook, ...).
+	Channel() string
+	// ValidateTarget проверяет адрес получателя при сохранении правила.
+	ValidateTarget(target string) error
+	// Send доставляет сообщение получателю target.
+	Send(ctx context.Context, target string, msg Message) error
+}
+
+// FromConfig возвращает настроенные каналы по названию. Канал без настроек не возвращается.
+func FromConfig(cfg config.NotifyConfig) map[string]Notifier {
+	result := make(map[string]Notifier)
+	if cfg.SMTP.Host != "" {
+		result[ChannelEmail] = NewSMTPNotifier(cfg.SMTP)
+	}
+	if cfg.Webhook.Enabled {
+		result[ChannelWebhook] = NewWebhookNotifier(cfg.Webhook)
+	}
+	if cfg.Telegram.BotToken != "" {
+		result[ChannelTelegram] = NewTelegramNotifier(NewTelegramClient(cfg.Telegram))
+	}
+	return result
+}
+
+// Channels возвращает названия каналов в алфавитном порядке.
+func Channels(notifiers map[string]Notifier) []string {
+	result := make([]string, 0, len(notifiers))
+	for name := range notifiers {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,237 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"ctweb/internal/config"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier отправляет уведомления письмом через SMTP-сервер из notify.smtp.
+type SMTPNotifier struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTPNotifier создаёт канал email.
+func NewSMTPNotifier(cfg config.SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{cfg: cfg}
+}
+
+// Channel возвращает ChannelEmail.
+func (n *SMTPNotifier) Channel() string {
+	return ChannelEmail
+}
+
+// ValidateTarget проверяет адрес получателя.
+func (n *SMTPNotifier) ValidateTarget(target string) error {
+	if _, err := parseMailbox(target); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	return nil
+}
+
+func parseMailbox(value string) (*mail.Address, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(addr.Address, "\r\n") {
+		return nil, fmt.Errorf("address contains line breaks")
+	}
+	return addr, nil
+}
+
+// Send отправляет письмо на адрес target.
+func (n *SMTPNotifier) Send(ctx context.Context, target string, msg Message) error {
+	from, err := parseMailbox(n.cfg.From)
+	if err != nil {
+		return fmt.Errorf("notify.smtp.from: %w", err)
+	}
+	to, err := parseMailbox(target)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	body, err := buildMail(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	if n.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.cfg.Timeout)
+		defer cancel()
+	}
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.Port))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp connect: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if n.cfg.TLS == config.SMTPTLSImplicit {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: n.cfg.Host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("smtp tls: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer client.Close()
+	if n.cfg.TLS == config.SMTPTLSStartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: n.cfg.Host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMail собирает письмо в UTF-8: text/plain, либо multipart с HTML-версией и вложениями.
+func buildMail(from, to *mail.Address, msg Message) ([]byte, error) {
+	sent := msg.Time
+	if sent.IsZero() {
+		sent = time.Now()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", sent.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" && len(msg.Attachments) == 0 {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed.Boundary())
+	if err := writeMailBody(mixed, msg); err != nil {
+		return nil, err
+	}
+	for _, file := range msg.Attachments {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": file.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": file.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(file.Data)
+		for len(encoded) > 76 {
+			if _, err := io.WriteString(part, encoded[:76]+"\r\n"); err != nil {
+				return nil, err
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := io.WriteString(part, encoded+"\r\n"); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeMailBody добавляет текст письма: text/plain или multipart/alternative с HTML-версией.
+func writeMailBody(mixed *multipart.Writer, msg Message) error {
+	if msg.HTML == "" {
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		return writeQuotedPrintable(part, msg.Text)
+	}
+
+	var body bytes.Buffer
+	alternative := multipart.NewWriter(&body)
+	for _, alt := range []struct{ contentType, text string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeQuotedPrintable(part, alt.text); err != nil {
+			return err
+		}
+	}
+	if err := alternative.Close(); err != nil {
+		return err
+	}
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/alternative; boundary=%q", alternative.Boundary())},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(body.Bytes())
+	return err
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
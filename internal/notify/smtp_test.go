@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"ctweb/internal/config"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStub - минимальный SMTP-сервер на одно соединение: принимает письмо
+// и отдаёт команды клиента и тело DATA.
+type smtpStub struct {
+	ln       net.Listener
+	commands chan []string
+	data     chan string
+}
+
+func newSMTPStub(t *testing.T, rcptReply string) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &smtpStub{ln: ln, commands: make(chan []string, 1), data: make(chan string, 1)}
+	go stub.serve(rcptReply)
+	return stub
+}
+
+func (s *smtpStub) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) serve(rcptReply string) {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	var commands []string
+	var data strings.Builder
+	defer func() {
+		s.commands <- commands
+		s.data <- data.String()
+	}()
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		commands = append(commands, cmd)
+		switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250-stub")
+			reply("250 8BITMIME")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			reply(rcptReply)
+		case "DATA":
+			reply("354 go ahead")
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	stub := newSMTPStub(t, "250 OK")
+	defer stub.ln.Close()
+
+	n := NewSMTPNotifier(config.SMTPConfig{
+		Host: "127.0.0.1", Port: stub.port(), From: "CT Alerts <alerts@example.com>",
+		TLS: config.SMTPTLSNone, Timeout: 5 * time.Second,
+	})
+	msg := Message{Subject: "Убыток по позиции #7", Text: "PnL -150.5\nlimit -100", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := n.Send(context.Background(), "trader@example.com", msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	commands := strings.Join(<-stub.commands, "\n")
+	for _, want := range []string{"MAIL FROM:<alerts@example.com>", "RCPT TO:<trader@example.com>", "QUIT"} {
+		if !strings.Contains(commands, want) {
+			t.Fatalf("command %q not sent, got:\n%s", want, commands)
+		}
+	}
+	data := <-stub.data
+	for _, want := range []string{
+		"To: <trader@example.com>\r\n",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"PnL -150.5\r\nlimit -100",
+	} {
+		if !strings.Contains(data, want) {
+			t.Fatalf("message does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestSMTPNotifierRejectedRecipient(t *testing.T) {
+	stub := newSMTPStub(t, "550 no such user")
+	defer stub.ln.Close()
+
+	n := NewSMTPNotifier(config.SMTPConfig{
+		Host: "127.0.0.1", Port: stub.port(), From: "alerts@example.com",
+		TLS: config.SMTPTLSNone, Timeout: 5 * time.Second,
+	})
+	err := n.Send(context.Background(), "nobody@example.com", Message{Subject: "x", Text: "y"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("expected 550 error, got %v", err)
+	}
+}
+
+func TestSMTPNotifierValidateTarget(t *testing.T) {
+	n := NewSMTPNotifier(config.SMTPConfig{Host: "127.0.0.1", Port: 25, From: "alerts@example.com"})
+	if err := n.ValidateTarget("Trader <trader@example.com>"); err != nil {
+		t.Fatalf("ValidateTarget() error = %v", err)
+	}
+	for _, target := range []string{"", "not-an-email", "a@b.c\r\nBcc: x@y.z"} {
+		if err := n.ValidateTarget(target); !errors.Is(err, ErrInvalidTarget) {
+			t.Fatalf("ValidateTarget(%q) = %v, want ErrInvalidTarget", target, err)
+		}
+	}
+}
+
+func TestBuildMailWithHTMLAndAttachment(t *testing.T) {
+	from, _ := parseMailbox("ct-web <reports@example.com>")
+	to, _ := parseMailbox("trader@example.com")
+	raw, err := buildMail(from, to, Message{
+		Subject:     "Daily PnL report",
+		Text:        "Realized: +10",
+		HTML:        "<p>Realized: <b>+10</b></p>",
+		Attachments: []Attachment{{Name: "pnl-2026-01-02.csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")}},
+	})
+	if err != nil {
+		t.Fatalf("buildMail() error = %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("invalid mail: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("unexpected content type %q: %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	body, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("body part: %v", err)
+	}
+	bodyType, bodyParams, _ := mime.ParseMediaType(body.Header.Get("Content-Type"))
+	if bodyType != "multipart/alternative" {
+		t.Fatalf("unexpected body type %q", bodyType)
+	}
+	alternatives := multipart.NewReader(body, bodyParams["boundary"])
+	var types []string
+	for {
+		part, err := alternatives.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("alternative part: %v", err)
+		}
+		types = append(types, strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0])
+	}
+	if strings.Join(types, ",") != "text/plain,text/html" {
+		t.Fatalf("unexpected alternatives: %v", types)
+	}
+
+	file, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("attachment part: %v", err)
+	}
+	if file.FileName() != "pnl-2026-01-02.csv" {
+		t.Fatalf("unexpected attachment name %q", file.FileName())
+	}
+	data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, file))
+	if string(data) != "a,b\n1,2\n" {
+		t.Fatalf("unexpected attachment data %q", data)
+	}
+}
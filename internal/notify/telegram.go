@@ -0,0 +1,182 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"ctweb/internal/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ChannelTelegram - канал доставки в привязанный Telegram-чат пользователя.
+const ChannelTelegram = "telegram"
+
+// telegramMaxText - предел длины текста одного сообщения Bot API (символов).
+const telegramMaxText = 4096
+
+// TelegramChat - чат, из которого пришло сообщение.
+type TelegramChat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Username string `json:"username"`
+}
+
+// TelegramUser - отправитель сообщения.
+type TelegramUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// TelegramMessage - входящее сообщение бота.
+type TelegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	From      *TelegramUser `json:"from"`
+	Chat      TelegramChat  `json:"chat"`
+	Text      string        `json:"text"`
+}
+
+// TelegramUpdate - элемент ответа getUpdates. Обновления кроме сообщений не запрашиваются.
+type TelegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *TelegramMessage `json:"message"`
+}
+
+// TelegramClient - минимальный клиент Bot API: отправка текста и long polling обновлений.
+type TelegramClient struct {
+	baseURL     string
+	pollTimeout time.Duration
+	client      *http.Client
+	pollClient  *http.Client
+}
+
+// NewTelegramClient создаёт клиент Bot API по настройкам notify.telegram.
+func NewTelegramClient(cfg config.TelegramConfig) *TelegramClient {
+	return &TelegramClient{
+		baseURL:     strings.TrimRight(cfg.APIURL, "/") + "/bot" + cfg.BotToken,
+		pollTimeout: cfg.PollTimeout,
+		client:      &http.Client{Timeout: cfg.Timeout},
+		// Сервер держит getUpdates до poll_timeout, поэтому запасной таймаут клиента больше.
+		pollClient: &http.Client{Timeout: cfg.PollTimeout + cfg.Timeout},
+	}
+}
+
+// telegramResponse - общий конверт ответов Bot API.
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// call выполняет метод Bot API. URL запроса содержит токен бота, поэтому в ошибку
+// попадает только название метода.
+func (c *TelegramClient) call(ctx context.Context, client *http.Client, method string, params, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s: invalid api url", method)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope telegramResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&envelope); err != nil {
+		return fmt.Errorf("telegram %s: status %d: %w", method, resp.StatusCode, err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram %s: %s", method, envelope.Description)
+	}
+	if result != nil {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return fmt.Errorf("telegram %s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// SendMessage отправляет текст в чат без разметки. Слишком длинный текст обрезается.
+func (c *TelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	if utf8.RuneCountInString(text) > telegramMaxText {
+		runes := []rune(text)
+		text = string(runes[:telegramMaxText-1]) + "…"
+	}
+	return c.call(ctx, c.client, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// GetUpdates ждёт новые сообщения начиная с offset (long polling на poll_timeout).
+func (c *TelegramClient) GetUpdates(ctx context.Context, offset int64) ([]TelegramUpdate, error) {
+	var updates []TelegramUpdate
+	err := c.call(ctx, c.pollClient, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(c.pollTimeout / time.Second),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+// TelegramNotifier отправляет уведомления в Telegram-чат. Адрес получателя - ID чата;
+// сервис подставляет его из привязки пользователя при каждой отправке.
+type TelegramNotifier struct {
+	client *TelegramClient
+}
+
+// NewTelegramNotifier создаёт канал Telegram.
+func NewTelegramNotifier(client *TelegramClient) *TelegramNotifier {
+	return &TelegramNotifier{client: client}
+}
+
+// Channel возвращает ChannelTelegram.
+func (n *TelegramNotifier) Channel() string {
+	return ChannelTelegram
+}
+
+// ValidateTarget проверяет, что target - числовой ID чата.
+func (n *TelegramNotifier) ValidateTarget(target string) error {
+	if _, err := parseChatID(target); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Send отправляет тему и текст уведомления в чат target.
+func (n *TelegramNotifier) Send(ctx context.Context, target string, msg Message) error {
+	chatID, err := parseChatID(target)
+	if err != nil {
+		return err
+	}
+	text := msg.Subject
+	if msg.Text != "" {
+		text += "\n\n" + msg.Text
+	}
+	return n.client.SendMessage(ctx, chatID, text)
+}
+
+func parseChatID(target string) (int64, error) {
+	chatID, err := strconv.ParseInt(strings.TrimSpace(target), 10, 64)
+	if err != nil || chatID == 0 {
+		return 0, fmt.Errorf("%w: telegram chat is not linked", ErrInvalidTarget)
+	}
+	return chatID, nil
+}
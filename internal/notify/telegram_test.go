@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramNotifierSend(t *testing.T) {
+	var gotPath string
+	var gotParams map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotParams)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	defer srv.Close()
+
+	client := NewTelegramClient(config.TelegramConfig{BotToken: "123:abc", APIURL: srv.URL, Timeout: time.Second})
+	n := NewTelegramNotifier(client)
+	if err := n.Send(context.Background(), "-100500", Message{Subject: "BTCUSDT above 70000", Text: "Price 70010"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if gotPath != "/bot123:abc/sendMessage" {
+		t.Fatalf("unexpected path %q", gotPath)
+	}
+	if gotParams["chat_id"] != -100500.0 || gotParams["text"] != "BTCUSDT above 70000\n\nPrice 70010" {
+		t.Fatalf("unexpected params: %v", gotParams)
+	}
+
+	if err := n.Send(context.Background(), "", Message{Subject: "x"}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected ErrInvalidTarget for empty chat, got %v", err)
+	}
+}
+
+func TestTelegramClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+	}))
+	client := NewTelegramClient(config.TelegramConfig{BotToken: "123:secret", APIURL: srv.URL, Timeout: time.Second})
+	err := client.SendMessage(context.Background(), 42, "hi")
+	if err == nil || !strings.Contains(err.Error(), "bot was blocked") {
+		t.Fatalf("expected Bot API description in error, got %v", err)
+	}
+	srv.Close()
+
+	// Сетевая ошибка не должна раскрывать токен из URL запроса
+	err = client.SendMessage(context.Background(), 42, "hi")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("expected network error without token, got %v", err)
+	}
+}
+
+func TestTelegramClientGetUpdates(t *testing.T) {
+	var gotParams map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot1:t/getUpdates" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&gotParams)
+		_, _ = w.Write([]byte(`{"ok":true,"result":[{"update_id":7,"message":{"message_id":3,"chat":{"id":42,"type":"private"},"from":{"id":42,"username":"trader"},"text":"/pnl"}}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewTelegramClient(config.TelegramConfig{BotToken: "1:t", APIURL: srv.URL, PollTimeout: 5 * time.Second, Timeout: time.Second})
+	updates, err := client.GetUpdates(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetUpdates() error = %v", err)
+	}
+	if gotParams["offset"] != 7.0 || gotParams["timeout"] != 5.0 {
+		t.Fatalf("unexpected params: %v", gotParams)
+	}
+	if len(updates) != 1 || updates[0].Message == nil || updates[0].Message.Chat.ID != 42 || updates[0].Message.Text != "/pnl" {
+		t.Fatalf("unexpected updates: %+v", updates)
+	}
+}
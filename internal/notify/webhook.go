@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ctweb/internal/config"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SignatureHeader - заголовок с HMAC-SHA256 подписью тела запроса webhook.
+const SignatureHeader = "X-CT-Signature"
+
+// ErrWebhookAddressBlocked - URL webhook ведёт на локальный или внутренний адрес.
+var ErrWebhookAddressBlocked = errors.New("webhook address is not allowed")
+
+// webhookBlockedPrefixes - сети, в которые webhook не отправляется, помимо loopback,
+// частных, link-local (в том числе 169.254.169.254) и multicast адресов.
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// WebhookNotifier отправляет уведомление POST-запросом с JSON на URL из правила.
+// URL задаёт пользователь, поэтому адреса сервера и внутренней сети запрещены:
+// проверяется адрес каждого соединения (после DNS), редиректы не выполняются.
+type WebhookNotifier struct {
+	secret       string
+	client       *http.Client
+	allowPrivate bool // только для тестов с локальным сервером
+}
+
+// NewWebhookNotifier создаёт канал webhook.
+func NewWebhookNotifier(cfg config.WebhookConfig) *WebhookNotifier {
+	n := &WebhookNotifier{secret: cfg.Secret}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: n.checkDial}
+	n.client = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return n
+}
+
+// webhookAddressAllowed сообщает, можно ли отправлять webhook на адрес addr.
+func webhookAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDial проверяет адрес соединения после разрешения имени (защита от DNS rebinding).
+func (n *WebhookNotifier) checkDial(network, address string, _ syscall.RawConn) error {
+	if n.allowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, address)
+	}
+	if !webhookAddressAllowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+// webhookPayload - тело запроса webhook.
+type webhookPayload struct {
+	Event   string                 `json:"event"`
+	Subject string                 `json:"subject"`
+	Text    string                 `json:"text"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Time    string                 `json:"time"`
+}
+
+// Channel возвращает ChannelWebhook.
+func (n *WebhookNotifier) Channel() string {
+	return ChannelWebhook
+}
+
+// ValidateTarget проверяет, что target - абсолютный http(s) URL.
+func (n *WebhookNotifier) ValidateTarget(target string) error {
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: webhook URL must start with http:// or https://", ErrInvalidTarget)
+	}
+	if n.allowPrivate {
+		return nil
+	}
+	// Имена проверяются при соединении, здесь - только IP в самом URL
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !webhookAddressAllowed(addr) {
+		return fmt.Errorf("%w: webhook URL must not point to a local or internal address", ErrInvalidTarget)
+	}
+	return nil
+}
+
+// Sign возвращает значение заголовка SignatureHeader для тела body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send отправляет сообщение на URL target. Ответ не 2xx (в том числе редирект)
+// считается ошибкой доставки.
+func (n *WebhookNotifier) Send(ctx context.Context, target string, msg Message) error {
+	if err := n.ValidateTarget(target); err != nil {
+		return err
+	}
+	sent := msg.Time
+	if sent.IsZero() {
+		sent = time.Now()
+	}
+	body, err := json.Marshal(webhookPayload{
+		Event:   msg.Event,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		Fields:  msg.Fields,
+		Time:    sent.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(target), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ct-web-alerts")
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	// Тело ответа не возвращается: ошибка показывается пользователю
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifierSend(t *testing.T) {
+	var gotBody []byte
+	var gotSignature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		gotSignature = r.Header.Get(SignatureHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(config.WebhookConfig{Secret: "s3cret", Timeout: time.Second})
+	n.allowPrivate = true
+	msg := Message{
+		Event:   "price",
+		Subject: "BTCUSDT above 70000",
+		Text:    "Price 70010",
+		Fields:  map[string]interface{}{"price": 70010.0},
+		Time:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := n.Send(context.Background(), srv.URL+"/hook", msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if gotSignature != Sign("s3cret", gotBody) {
+		t.Fatalf("signature mismatch: %q", gotSignature)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(gotBody, &payload); err != nil {
+		t.Fatalf("invalid payload %s: %v", gotBody, err)
+	}
+	if payload["event"] != "price" || payload["subject"] != msg.Subject || payload["time"] != "2026-01-02T03:04:05Z" {
+		t.Fatalf("unexpected payload: %s", gotBody)
+	}
+	if fields, _ := payload["fields"].(map[string]interface{}); fields["price"] != 70010.0 {
+		t.Fatalf("unexpected fields: %s", gotBody)
+	}
+}
+
+func TestWebhookNotifierErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Error("unsigned webhook must not send a signature")
+		}
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(config.WebhookConfig{Timeout: time.Second})
+	n.allowPrivate = true
+	err := n.Send(context.Background(), srv.URL, Message{Subject: "x"})
+	if err == nil || err.Error() != "webhook returned 502" {
+		t.Fatalf("Send() error = %v, want status without body", err)
+	}
+	for _, target := range []string{"", "ftp://example.com/hook", "/relative", "http://"} {
+		if err := n.ValidateTarget(target); !errors.Is(err, ErrInvalidTarget) {
+			t.Fatalf("ValidateTarget(%q) = %v, want ErrInvalidTarget", target, err)
+		}
+	}
+}
+
+func TestWebhookNotifierBlocksInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("webhook reached a loopback server")
+	}))
+	defer srv.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+
+	n := NewWebhookNotifier(config.WebhookConfig{Timeout: time.Second})
+	if err := n.Send(context.Background(), srv.URL, Message{Subject: "x"}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("Send(%s) = %v, want ErrInvalidTarget", srv.URL, err)
+	}
+	// Имя проверяется по адресу соединения
+	local := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	if err := n.Send(context.Background(), local, Message{Subject: "x"}); !errors.Is(err, ErrWebhookAddressBlocked) {
+		t.Fatalf("Send(%s) = %v, want ErrWebhookAddressBlocked", local, err)
+	}
+	for _, target := range []string{"http://10.0.0.1/", "http://192.168.1.1/", "http://172.16.0.1/",
+		"http://169.254.169.254/latest/meta-data/", "http://[::1]:8080/", "http://[::ffff:127.0.0.1]/", "http://0.0.0.0/"} {
+		if err := n.ValidateTarget(target); !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("ValidateTarget(%q) = %v, want ErrInvalidTarget", target, err)
+		}
+	}
+	if err := n.ValidateTarget("https://hooks.example.com/alerts"); err != nil {
+		t.Fatalf("ValidateTarget() error = %v", err)
+	}
+
+	// Редирект на внутренний адрес не выполняется
+	n.allowPrivate = true
+	if err := n.Send(context.Background(), redirect.URL, Message{Subject: "x"}); err == nil || err.Error() != "webhook returned 307" {
+		t.Fatalf("Send() with redirect = %v, want status 307", err)
+	}
+}
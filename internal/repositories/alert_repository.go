@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AlertRepository - правила уведомлений (ALERT_RULES) и история срабатываний (ALERT_EVENTS).
+type AlertRepository struct{}
+
+// NewAlertRepository создаёт новый экземпляр AlertRepository.
+func NewAlertRepository() *AlertRepository {
+	return &AlertRepository{}
+}
+
+// alertRuleSelect - выборка правил с названием биржи, которую читает scanAlertRule.
+const alertRuleSelect = `SELECT r.ID, r.UID, r.RULE_TYPE, r.POSITION_ID, r.EXID, COALESCE(e.NAME, ''),
+		r.MARKET_TYPE, r.SYMBOL, r.DIRECTION, CAST(r.THRESHOLD AS DOUBLE), r.CHANNEL, r.TARGET,
+		r.IS_ACTIVE, r.IS_TRIGGERED, r.LAST_VALUE, r.DATE_CREATE, r.DATE_CHECKED, r.DATE_TRIGGERED, r.LAST_ERROR
+	FROM ALERT_RULES r
+	LEFT JOIN EXCHANGE e ON e.ID = r.EXID`
+
+func scanAlertRule(scanner interface{ Scan(...interface{}) error }) (*models.AlertRule, error) {
+	var r models.AlertRule
+	var positionID sql.NullInt64
+	var lastValue sql.NullFloat64
+	var checked, triggered sql.NullTime
+	if err := scanner.Scan(&r.ID, &r.UID, &r.Type, &positionID, &r.ExID, &r.Exchange,
+		&r.MarketType, &r.Symbol, &r.Direction, &r.Threshold, &r.Channel, &r.Target,
+		&r.IsActive, &r.IsTriggered, &lastValue, &r.DateCreate, &checked, &triggered, &r.LastError); err != nil {
+		return nil, err
+	}
+	if positionID.Valid {
+		id := int(positionID.Int64)
+		r.PositionID = &id
+	}
+	if lastValue.Valid {
+		r.LastValue = &lastValue.Float64
+	}
+	if checked.Valid {
+		r.DateChecked = &checked.Time
+	}
+	if triggered.Valid {
+		r.DateTriggered = &triggered.Time
+	}
+	return &r, nil
+}
+
+func (r *AlertRepository) query(query string, args ...interface{}) ([]*models.AlertRule, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return rules, nil
+}
+
+// FindAllByUser возвращает правила пользователя.
+func (r *AlertRepository) FindAllByUser(userID int) ([]*models.AlertRule, error) {
+	return r.query(alertRuleSelect+` WHERE r.UID = ? ORDER BY r.ID ASC`, userID)
+}
+
+// FindActive возвращает активные правила всех пользователей (для задачи проверки).
+func (r *AlertRepository) FindActive() ([]*models.AlertRule, error) {
+	return r.query(alertRuleSelect + ` WHERE r.IS_ACTIVE = 1 ORDER BY r.ID ASC`)
+}
+
+// FindByID возвращает правило пользователя (nil - не найдено или чужое).
+func (r *AlertRepository) FindByID(id, userID int) (*models.AlertRule, error) {
+	rule, err := scanAlertRule(db.DB.QueryRow(alertRuleSelect+` WHERE r.ID = ? AND r.UID = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return rule, nil
+}
+
+// CountByUser возвращает количество правил пользователя.
+func (r *AlertRepository) CountByUser(userID int) (int, error) {
+	var count int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM ALERT_RULES WHERE UID = ?`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	return count, nil
+}
+
+// Create сохраняет правило и возвращает его ID.
+func (r *AlertRepository) Create(rule *models.AlertRule) (int, error) {
+	result, err := db.DB.Exec(`INSERT INTO ALERT_RULES
+		(UID, RULE_TYPE, POSITION_ID, EXID, MARKET_TYPE, SYMBOL, DIRECTION, THRESHOLD, CHANNEL, TARGET, IS_ACTIVE)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.UID, rule.Type, rule.PositionID, rule.ExID, rule.MarketType, rule.Symbol, rule.Direction,
+		rule.Threshold, rule.Channel, rule.Target, rule.IsActive)
+	if err != nil {
+		return 0, fmt.Errorf("insert alert rule: %w", err)
+	}
+	id, err := db.GetLastInsertID(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return int(id), nil
+}
+
+// SetActive включает или приостанавливает правило. Состояние срабатывания сбрасывается,
+// чтобы после включения правило сработало по текущему значению.
+func (r *AlertRepository) SetActive(id, userID int, active bool) error {
+	if _, err := db.DB.Exec(`UPDATE ALERT_RULES SET IS_ACTIVE = ?, IS_TRIGGERED = 0, LAST_ERROR = ''
+		WHERE ID = ? AND UID = ?`, active, id, userID); err != nil {
+		return fmt.Errorf("update alert rule: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет правило пользователя вместе с историей срабатываний.
+func (r *AlertRepository) Delete(id, userID int) (bool, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM ALERT_RULES WHERE ID = ? AND UID = ?`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete alert rule: %w", err)
+	}
+	affected, err := db.GetRowsAffected(result)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM ALERT_EVENTS WHERE RULE_ID = ? AND UID = ?`, id, userID); err != nil {
+		return false, fmt.Errorf("delete alert events: %w", err)
+	}
+	if err := db.CommitTransaction(tx); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetCheckResult запоминает результат проверки правила. triggeredAt != nil - правило сработало.
+func (r *AlertRepository) SetCheckResult(id int, triggered bool, value *float64, checkedAt time.Time, triggeredAt *time.Time, lastError string) error {
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+	if _, err := db.DB.Exec(`UPDATE ALERT_RULES SET IS_TRIGGERED = ?, LAST_VALUE = COALESCE(?, LAST_VALUE),
+			DATE_CHECKED = ?, DATE_TRIGGERED = COALESCE(?, DATE_TRIGGERED), LAST_ERROR = ?
+		WHERE ID = ?`, triggered, value, checkedAt, triggeredAt, lastError, id); err != nil {
+		return fmt.Errorf("update alert rule check: %w", err)
+	}
+	return nil
+}
+
+// InsertEvent сохраняет срабатывание правила.
+func (r *AlertRepository) InsertEvent(event *models.AlertEvent) error {
+	errText := event.Error
+	if len(errText) > 255 {
+		errText = errText[:255]
+	}
+	subject := event.Subject
+	if len(subject) > 255 {
+		subject = subject[:255]
+	}
+	if _, err := db.DB.Exec(`INSERT INTO ALERT_EVENTS (RULE_ID, UID, DATE_CREATE, SUBJECT, MESSAGE, DELIVERED, ERROR)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		event.RuleID, event.UID, event.DateCreate, subject, event.Message, event.Delivered, errText); err != nil {
+		return fmt.Errorf("insert alert event: %w", err)
+	}
+	return nil
+}
+
+// FindEventsByUser возвращает последние limit срабатываний правил пользователя (от новых к старым).
+func (r *AlertRepository) FindEventsByUser(userID, limit int) ([]*models.AlertEvent, error) {
+	rows, err := db.DB.Query(`SELECT ID, RULE_ID, UID, DATE_CREATE, SUBJECT, MESSAGE, DELIVERED, ERROR
+		FROM ALERT_EVENTS WHERE UID = ? ORDER BY DATE_CREATE DESC, ID DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*models.AlertEvent, 0)
+	for rows.Next() {
+		var e models.AlertEvent
+		if err := rows.Scan(&e.ID, &e.RuleID, &e.UID, &e.DateCreate, &e.Subject, &e.Message, &e.Delivered, &e.Error); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return events, nil
+}
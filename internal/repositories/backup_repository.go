@@ -0,0 +1,296 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+)
+
+// BackupRepository - чтение позиций пользователя для резервной копии и запись
+// восстановленных данных. Методы восстановления работают в транзакции вызывающего:
+// пробный запуск откатывает её целиком.
+type BackupRepository struct{}
+
+// NewBackupRepository создаёт новый экземпляр BackupRepository.
+func NewBackupRepository() *BackupRepository {
+	return &BackupRepository{}
+}
+
+type backupQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func backupStatus(open bool) string {
+	if open {
+		return "OPEN"
+	}
+	return "CLOSE"
+}
+
+// FindPositions возвращает позиции пользователя без операций.
+func (r *BackupRepository) FindPositions(userID int) ([]models.BackupPosition, error) {
+	rows, err := db.DB.Query(`SELECT ID, NAME, EXID, ACCOUNT_ID, GROUP_ID, MARKET_TYPE, STATUS = 1, CREATED, CLOSED
+		FROM POS_POSITIONS WHERE USER_ID = ? ORDER BY ID`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]models.BackupPosition, 0)
+	for rows.Next() {
+		var p models.BackupPosition
+		var accountID, groupID sql.NullInt64
+		var closed sql.NullTime
+		var open bool
+		if err := rows.Scan(&p.ID, &p.Name, &p.ExchangeID, &accountID, &groupID, &p.MarketType, &open, &p.Created, &closed); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if accountID.Valid {
+			id := int(accountID.Int64)
+			p.AccountID = &id
+		}
+		if groupID.Valid {
+			id := int(groupID.Int64)
+			p.GroupID = &id
+		}
+		if closed.Valid {
+			p.Closed = &closed.Time
+		}
+		p.Status = backupStatus(open)
+		p.Transactions = make([]models.BackupTransaction, 0)
+		result = append(result, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+const backupTransactionSelect = `SELECT t.ID, t.POSITION_ID, t.OP_TYPE, CAST(t.PRICE AS CHAR), CAST(t.VOLUME AS CHAR),
+		CAST(t.FEE AS CHAR), CAST(t.FEE_BASE AS CHAR), CAST(t.FUNDING_AMOUNT AS CHAR), t.TRANS_DATE,
+		t.SOURCE_ORDER_ID, t.SOURCE_TRADE_ID
+	FROM POS_TRANSACTIONS t
+	JOIN POS_POSITIONS p ON p.ID = t.POSITION_ID`
+
+func findBackupTransactions(q backupQuerier, where string, args ...interface{}) (map[int][]models.BackupTransaction, error) {
+	rows, err := q.Query(backupTransactionSelect+` WHERE `+where+` ORDER BY t.POSITION_ID, t.TRANS_DATE, t.ID`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	nullString := func(v sql.NullString) *string {
+		if !v.Valid {
+			return nil
+		}
+		return &v.String
+	}
+	result := make(map[int][]models.BackupTransaction)
+	for rows.Next() {
+		var t models.BackupTransaction
+		var positionID int
+		var price, volume, fee, feeBase, funding, orderID, tradeID sql.NullString
+		if err := rows.Scan(&t.ID, &positionID, &t.Type, &price, &volume, &fee, &feeBase, &funding, &t.TransDate, &orderID, &tradeID); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		t.Price, t.Volume, t.Fee, t.FeeBase, t.Funding = nullString(price), nullString(volume), nullString(fee), nullString(feeBase), nullString(funding)
+		t.SourceOrderID, t.SourceTradeID = nullString(orderID), nullString(tradeID)
+		result[positionID] = append(result[positionID], t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// FindTransactions возвращает операции всех позиций пользователя по ID позиции
+// в порядке исполнения.
+func (r *BackupRepository) FindTransactions(userID int) (map[int][]models.BackupTransaction, error) {
+	return findBackupTransactions(db.DB, `p.USER_ID = ?`, userID)
+}
+
+// FindPositionTransactions возвращает операции позиции пользователя в транзакции tx.
+func (r *BackupRepository) FindPositionTransactions(tx *sql.Tx, userID, positionID int) ([]models.BackupTransaction, error) {
+	result, err := findBackupTransactions(tx, `p.USER_ID = ? AND p.ID = ?`, userID, positionID)
+	if err != nil {
+		return nil, err
+	}
+	return result[positionID], nil
+}
+
+// FindExistingPositions возвращает позиции пользователя для сопоставления с копией.
+func (r *BackupRepository) FindExistingPositions(tx *sql.Tx, userID int) ([]models.BackupPosition, error) {
+	rows, err := tx.Query(`SELECT ID, NAME, EXID, MARKET_TYPE, CREATED FROM POS_POSITIONS WHERE USER_ID = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]models.BackupPosition, 0)
+	for rows.Next() {
+		var p models.BackupPosition
+		if err := rows.Scan(&p.ID, &p.Name, &p.ExchangeID, &p.MarketType, &p.Created); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		result = append(result, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// FindSourceTradeIDs возвращает SOURCE_TRADE_ID операций пользователя по ID биржи.
+func (r *BackupRepository) FindSourceTradeIDs(tx *sql.Tx, userID int) (map[int]map[string]bool, error) {
+	rows, err := tx.Query(`SELECT p.EXID, t.SOURCE_TRADE_ID
+		FROM POS_TRANSACTIONS t
+		JOIN POS_POSITIONS p ON p.ID = t.POSITION_ID
+		WHERE p.USER_ID = ? AND t.SOURCE_TRADE_ID IS NOT NULL AND t.SOURCE_TRADE_ID <> ''`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int]map[string]bool)
+	for rows.Next() {
+		var exchangeID int
+		var tradeID string
+		if err := rows.Scan(&exchangeID, &tradeID); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if result[exchangeID] == nil {
+			result[exchangeID] = make(map[string]bool)
+		}
+		result[exchangeID][tradeID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// FindExistingGroups возвращает группы пользователя для сопоставления с копией.
+func (r *BackupRepository) FindExistingGroups(tx *sql.Tx, userID int) ([]*models.PositionGroup, error) {
+	rows, err := tx.Query(positionGroupSelect+` WHERE USER_ID = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.PositionGroup, 0)
+	for rows.Next() {
+		group, err := scanPositionGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		result = append(result, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// InsertGroup создаёт группу пользователя с состоянием из копии.
+func (r *BackupRepository) InsertGroup(tx *sql.Tx, userID int, g *models.BackupGroup) (int, error) {
+	var closed interface{}
+	if g.Closed != nil {
+		closed = g.Closed.UTC().Format("2006-01-02 15:04:05")
+	}
+	res, err := tx.Exec(`INSERT INTO POS_GROUPS (USER_ID, NAME, STRATEGY, ENTRY_DIFF, STATUS, CREATED, CLOSED) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, g.Name, g.Strategy, g.EntryDiff, g.Status == "OPEN", g.Created.UTC().Format("2006-01-02 15:04:05"), closed)
+	if err != nil {
+		return 0, fmt.Errorf("create position group: %w", err)
+	}
+	id, err := db.GetLastInsertID(res)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return int(id), nil
+}
+
+// InsertPosition создаёт позицию пользователя с состоянием из копии.
+func (r *BackupRepository) InsertPosition(tx *sql.Tx, userID int, p *models.BackupPosition, exchangeID int, accountID, groupID *int) (int, error) {
+	var closed interface{}
+	if p.Closed != nil {
+		closed = p.Closed.UTC().Format("2006-01-02 15:04:05")
+	}
+	res, err := tx.Exec(`INSERT INTO POS_POSITIONS (NAME, EXID, ACCOUNT_ID, GROUP_ID, CREATED, CLOSED, STATUS, MARKET_TYPE, USER_ID)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Name, exchangeID, accountID, groupID, p.Created.UTC().Format("2006-01-02 15:04:05"), closed, p.Status == "OPEN", p.MarketType, userID)
+	if err != nil {
+		return 0, fmt.Errorf("create position: %w", err)
+	}
+	id, err := db.GetLastInsertID(res)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return int(id), nil
+}
+
+// InsertTransaction добавляет операцию позиции. false - строка отклонена уникальным ключом.
+func (r *BackupRepository) InsertTransaction(tx *sql.Tx, positionID int, t *models.BackupTransaction) (bool, error) {
+	res, err := tx.Exec(`INSERT IGNORE INTO POS_TRANSACTIONS
+		(POSITION_ID, OP_TYPE, PRICE, VOLUME, FEE, FEE_BASE, FUNDING_AMOUNT, TRANS_DATE, SOURCE_ORDER_ID, SOURCE_TRADE_ID)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		positionID, t.Type, t.Price, t.Volume, t.Fee, t.FeeBase, t.Funding, t.TransDate.UTC().Format("2006-01-02 15:04:05.000"),
+		t.SourceOrderID, t.SourceTradeID)
+	if err != nil {
+		return false, fmt.Errorf("insert transaction: %w", err)
+	}
+	affected, err := db.GetRowsAffected(res)
+	if err != nil {
+		return false, fmt.Errorf("insert transaction rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// FindAlertRules возвращает правила уведомлений пользователя в транзакции tx.
+func (r *BackupRepository) FindAlertRules(tx *sql.Tx, userID int) ([]*models.AlertRule, error) {
+	rows, err := tx.Query(alertRuleSelect+` WHERE r.UID = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		result = append(result, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// InsertAlertRule создаёт правило уведомлений пользователя.
+func (r *BackupRepository) InsertAlertRule(tx *sql.Tx, rule *models.AlertRule) error {
+	if _, err := tx.Exec(`INSERT INTO ALERT_RULES
+		(UID, RULE_TYPE, POSITION_ID, EXID, MARKET_TYPE, SYMBOL, DIRECTION, THRESHOLD, CHANNEL, TARGET, IS_ACTIVE)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.UID, rule.Type, rule.PositionID, rule.ExID, rule.MarketType, rule.Symbol, rule.Direction,
+		rule.Threshold, rule.Channel, rule.Target, rule.IsActive); err != nil {
+		return fmt.Errorf("insert alert rule: %w", err)
+	}
+	return nil
+}
+
+// InsertReportSubscription создаёт подписку на отчёты, если у пользователя её ещё нет.
+// false - подписка уже настроена и не изменена.
+func (r *BackupRepository) InsertReportSubscription(tx *sql.Tx, sub *models.ReportSubscription) (bool, error) {
+	res, err := tx.Exec(`INSERT IGNORE INTO REPORT_SUBSCRIPTIONS (UID, DAILY, MONTHLY, EMAIL, DATE_UPDATE)
+		VALUES (?, ?, ?, ?, NOW())`, sub.UID, sub.Daily, sub.Monthly, sub.Email)
+	if err != nil {
+		return false, fmt.Errorf("insert report subscription: %w", err)
+	}
+	affected, err := db.GetRowsAffected(res)
+	if err != nil {
+		return false, fmt.Errorf("insert report subscription rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
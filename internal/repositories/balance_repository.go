@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BalanceRepository - снимки балансов аккаунтов бирж (ACCOUNT_BALANCE_SNAPSHOTS, ACCOUNT_BALANCES).
+type BalanceRepository struct{}
+
+// NewBalanceRepository создаёт новый экземпляр BalanceRepository.
+func NewBalanceRepository() *BalanceRepository {
+	return &BalanceRepository{}
+}
+
+// SaveSnapshot сохраняет снимок с остатками в одной транзакции и возвращает ID снимка.
+func (r *BalanceRepository) SaveSnapshot(snapshot *models.BalanceSnapshot) (int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer db.RollbackTransaction(tx)
+
+	result, err := tx.Exec(`INSERT INTO ACCOUNT_BALANCE_SNAPSHOTS
+		(ACCOUNT_ID, UID, SNAPSHOT_AT, USD_VALUE, UNPRICED_ASSETS)
+		VALUES (?, ?, ?, ?, ?)`,
+		snapshot.AccountID, snapshot.UID, snapshot.SnapshotAt, snapshot.USDValue, snapshot.UnpricedAssets,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert balance snapshot: %w", err)
+	}
+	id, err := db.GetLastInsertID(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if len(snapshot.Items) > 0 {
+		stmt, err := tx.Prepare(`INSERT INTO ACCOUNT_BALANCES
+			(SNAPSHOT_ID, ACCOUNT_ID, SNAPSHOT_AT, WALLET, ASSET, FREE, LOCKED, USD_VALUE)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return 0, fmt.Errorf("prepare balance insert: %w", err)
+		}
+		defer stmt.Close()
+		for _, item := range snapshot.Items {
+			if _, err := stmt.Exec(id, snapshot.AccountID, snapshot.SnapshotAt, item.Wallet, item.Asset, item.Free, item.Locked, item.USDValue); err != nil {
+				return 0, fmt.Errorf("insert balance %s: %w", item.Asset, err)
+			}
+		}
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(id), nil
+}
+
+// LatestSnapshot возвращает последний снимок аккаунта пользователя вместе с остатками.
+// Возвращает nil, nil если снимков ещё нет.
+func (r *BalanceRepository) LatestSnapshot(accountID, userID int) (*models.BalanceSnapshot, error) {
+	var snapshot models.BalanceSnapshot
+	err := db.DB.QueryRow(`SELECT ID, ACCOUNT_ID, UID, SNAPSHOT_AT, USD_VALUE, UNPRICED_ASSETS
+		FROM ACCOUNT_BALANCE_SNAPSHOTS
+		WHERE ACCOUNT_ID = ? AND UID = ?
+		ORDER BY SNAPSHOT_AT DESC, ID DESC
+		LIMIT 1`, accountID, userID).Scan(
+		&snapshot.ID,
+		&snapshot.AccountID,
+		&snapshot.UID,
+		&snapshot.SnapshotAt,
+		&snapshot.USDValue,
+		&snapshot.UnpricedAssets,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	rows, err := db.DB.Query(`SELECT WALLET, ASSET, FREE, LOCKED, USD_VALUE
+		FROM ACCOUNT_BALANCES
+		WHERE SNAPSHOT_ID = ?
+		ORDER BY USD_VALUE IS NULL, USD_VALUE DESC, ASSET ASC`, snapshot.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.AccountBalance
+		var usdValue sql.NullFloat64
+		if err := rows.Scan(&item.Wallet, &item.Asset, &item.Free, &item.Locked, &usdValue); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if usdValue.Valid {
+			item.USDValue = &usdValue.Float64
+		}
+		snapshot.Items = append(snapshot.Items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// History возвращает снимки аккаунта (без остатков) начиная с from в порядке времени.
+func (r *BalanceRepository) History(accountID, userID int, from time.Time, limit int) ([]*models.BalanceSnapshot, error) {
+	if limit <= 0 || limit > 5000 {
+		limit = 1000
+	}
+	// Берём последние limit снимков и разворачиваем их в хронологический порядок.
+	rows, err := db.DB.Query(`SELECT ID, ACCOUNT_ID, UID, SNAPSHOT_AT, USD_VALUE, UNPRICED_ASSETS FROM (
+			SELECT ID, ACCOUNT_ID, UID, SNAPSHOT_AT, USD_VALUE, UNPRICED_ASSETS
+			FROM ACCOUNT_BALANCE_SNAPSHOTS
+			WHERE ACCOUNT_ID = ? AND UID = ? AND SNAPSHOT_AT >= ?
+			ORDER BY SNAPSHOT_AT DESC, ID DESC
+			LIMIT ?
+		) AS S
+		ORDER BY SNAPSHOT_AT ASC, ID ASC`, accountID, userID, from, limit)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.BalanceSnapshot
+	for rows.Next() {
+		var snapshot models.BalanceSnapshot
+		if err := rows.Scan(
+			&snapshot.ID,
+			&snapshot.AccountID,
+			&snapshot.UID,
+			&snapshot.SnapshotAt,
+			&snapshot.USDValue,
+			&snapshot.UnpricedAssets,
+		); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		result = append(result, &snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,278 @@
+package repositories
+
+import (
+	"context"
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// candleUpsertBatch - сколько свечей вставляется одним INSERT в MySQL.
+const candleUpsertBatch = 500
+
+// CandleRepository - хранилище свечей OHLCV в БД котировок (таблица CANDLES).
+// Работает с MySQL или ClickHouse в зависимости от databases.quotes.engine.
+type CandleRepository struct{}
+
+// NewCandleRepository создаёт новый экземпляр CandleRepository.
+func NewCandleRepository() *CandleRepository {
+	return &CandleRepository{}
+}
+
+// Available сообщает, подключена ли БД котировок.
+func (r *CandleRepository) Available() bool {
+	return db.QuotesEngine() != ""
+}
+
+// candleRow - строка CANDLES в формате JSON ClickHouse.
+type candleRow struct {
+	ExID       int     `json:"EXID"`
+	MarketType string  `json:"MARKET_TYPE"`
+	Symbol     string  `json:"SYMBOL"`
+	Interval   string  `json:"INTERVAL_CODE"`
+	OpenTime   string  `json:"OPEN_TIME"`
+	Open       float64 `json:"OPEN_PRICE"`
+	High       float64 `json:"HIGH_PRICE"`
+	Low        float64 `json:"LOW_PRICE"`
+	Close      float64 `json:"CLOSE_PRICE"`
+	Volume     float64 `json:"VOLUME"`
+}
+
+// UpsertBatch сохраняет свечи. Повторно загруженная свеча перезаписывается
+// (последняя свеча периода догружается, пока период не закрыт).
+func (r *CandleRepository) UpsertBatch(ctx context.Context, items []*models.Candle) (int, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	switch db.QuotesEngine() {
+	case db.QuotesEngineClickHouse:
+		batch := db.QuotesClickHouse.MaxBatchSize()
+		if batch <= 0 {
+			batch = len(items)
+		}
+		for start := 0; start < len(items); start += batch {
+			end := start + batch
+			if end > len(items) {
+				end = len(items)
+			}
+			rows := make([]interface{}, 0, end-start)
+			for _, item := range items[start:end] {
+				rows = append(rows, candleRow{
+					ExID:       item.ExID,
+					MarketType: item.MarketType,
+					Symbol:     item.Symbol,
+					Interval:   item.Interval,
+					OpenTime:   db.ClickHouseTime(item.OpenTime),
+					Open:       item.Open,
+					High:       item.High,
+					Low:        item.Low,
+					Close:      item.Close,
+					Volume:     item.Volume,
+				})
+			}
+			if err := db.QuotesClickHouse.InsertJSONEachRow(ctx, "CANDLES", rows); err != nil {
+				return 0, fmt.Errorf("insert candles: %w", err)
+			}
+		}
+		return len(items), nil
+
+	case db.QuotesEngineMySQL:
+		tx, err := db.Quotes.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, fmt.Errorf("begin candles transaction: %w", err)
+		}
+		defer db.RollbackTransaction(tx)
+
+		for start := 0; start < len(items); start += candleUpsertBatch {
+			end := start + candleUpsertBatch
+			if end > len(items) {
+				end = len(items)
+			}
+			chunk := items[start:end]
+
+			placeholders := make([]string, 0, len(chunk))
+			args := make([]interface{}, 0, len(chunk)*10)
+			for _, item := range chunk {
+				placeholders = append(placeholders, "(?,?,?,?,?,?,?,?,?,?)")
+				args = append(args,
+					item.ExID,
+					item.MarketType,
+					item.Symbol,
+					item.Interval,
+					item.OpenTime.UTC().Format("2006-01-02 15:04:05"),
+					item.Open,
+					item.High,
+					item.Low,
+					item.Close,
+					item.Volume,
+				)
+			}
+
+			query := `INSERT INTO CANDLES
+				(EXID, MARKET_TYPE, SYMBOL, INTERVAL_CODE, OPEN_TIME, OPEN_PRICE, HIGH_PRICE, LOW_PRICE, CLOSE_PRICE, VOLUME)
+				VALUES ` + strings.Join(placeholders, ",") + `
+				ON DUPLICATE KEY UPDATE
+					OPEN_PRICE = VALUES(OPEN_PRICE),
+					HIGH_PRICE = VALUES(HIGH_PRICE),
+					LOW_PRICE = VALUES(LOW_PRICE),
+					CLOSE_PRICE = VALUES(CLOSE_PRICE),
+					VOLUME = VALUES(VOLUME)`
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return 0, fmt.Errorf("upsert candles: %w", err)
+			}
+		}
+
+		if err := db.CommitTransaction(tx); err != nil {
+			return 0, err
+		}
+		return len(items), nil
+
+	default:
+		return 0, db.ErrQuotesNotConfigured
+	}
+}
+
+// FindRange возвращает свечи за период [from, to] по возрастанию времени, не больше limit.
+func (r *CandleRepository) FindRange(ctx context.Context, exchangeID int, market, symbol, interval string, from, to time.Time, limit int) ([]*models.Candle, error) {
+	switch db.QuotesEngine() {
+	case db.QuotesEngineClickHouse:
+		query := `SELECT EXID, MARKET_TYPE, SYMBOL, INTERVAL_CODE, toString(OPEN_TIME) AS OPEN_TIME,
+				OPEN_PRICE, HIGH_PRICE, LOW_PRICE, CLOSE_PRICE, VOLUME
+			FROM CANDLES FINAL
+			WHERE EXID = {exid:Int32} AND MARKET_TYPE = {market:String} AND SYMBOL = {symbol:String}
+				AND INTERVAL_CODE = {interval:String}
+				AND OPEN_TIME BETWEEN {from:DateTime('UTC')} AND {to:DateTime('UTC')}
+			ORDER BY OPEN_TIME
+			LIMIT {limit:UInt32}`
+		var rows []candleRow
+		if err := db.QuotesClickHouse.Query(ctx, query, map[string]string{
+			"exid":     strconv.Itoa(exchangeID),
+			"market":   market,
+			"symbol":   symbol,
+			"interval": interval,
+			"from":     db.ClickHouseTime(from),
+			"to":       db.ClickHouseTime(to),
+			"limit":    strconv.Itoa(limit),
+		}, &rows); err != nil {
+			return nil, fmt.Errorf("find candles: %w", err)
+		}
+
+		result := make([]*models.Candle, 0, len(rows))
+		for _, row := range rows {
+			openTime, err := time.ParseInLocation(db.ClickHouseTimeFormat, row.OpenTime, time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("parse candle time: %w", err)
+			}
+			result = append(result, &models.Candle{
+				ExID:       row.ExID,
+				MarketType: row.MarketType,
+				Symbol:     row.Symbol,
+				Interval:   row.Interval,
+				OpenTime:   openTime,
+				Open:       row.Open,
+				High:       row.High,
+				Low:        row.Low,
+				Close:      row.Close,
+				Volume:     row.Volume,
+			})
+		}
+		return result, nil
+
+	case db.QuotesEngineMySQL:
+		query := `SELECT EXID, MARKET_TYPE, SYMBOL, INTERVAL_CODE, OPEN_TIME,
+				CAST(OPEN_PRICE AS DOUBLE), CAST(HIGH_PRICE AS DOUBLE), CAST(LOW_PRICE AS DOUBLE),
+				CAST(CLOSE_PRICE AS DOUBLE), CAST(VOLUME AS DOUBLE)
+			FROM CANDLES
+			WHERE EXID = ? AND MARKET_TYPE = ? AND SYMBOL = ? AND INTERVAL_CODE = ?
+				AND OPEN_TIME BETWEEN ? AND ?
+			ORDER BY OPEN_TIME
+			LIMIT ?`
+		rows, err := db.Quotes.QueryContext(ctx, query,
+			exchangeID, market, symbol, interval,
+			from.UTC().Format("2006-01-02 15:04:05"),
+			to.UTC().Format("2006-01-02 15:04:05"),
+			limit,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("find candles: %w", err)
+		}
+		defer rows.Close()
+
+		result := make([]*models.Candle, 0)
+		for rows.Next() {
+			var item models.Candle
+			if err := rows.Scan(
+				&item.ExID,
+				&item.MarketType,
+				&item.Symbol,
+				&item.Interval,
+				&item.OpenTime,
+				&item.Open,
+				&item.High,
+				&item.Low,
+				&item.Close,
+				&item.Volume,
+			); err != nil {
+				return nil, fmt.Errorf("scan candle row: %w", err)
+			}
+			result = append(result, &item)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate candle rows: %w", err)
+		}
+		return result, nil
+
+	default:
+		return nil, db.ErrQuotesNotConfigured
+	}
+}
+
+// LastOpenTime возвращает время последней сохранённой свечи (nil - свечей нет).
+func (r *CandleRepository) LastOpenTime(ctx context.Context, exchangeID int, market, symbol, interval string) (*time.Time, error) {
+	switch db.QuotesEngine() {
+	case db.QuotesEngineClickHouse:
+		query := `SELECT toString(max(OPEN_TIME)) AS LAST, count() AS CNT
+			FROM CANDLES
+			WHERE EXID = {exid:Int32} AND MARKET_TYPE = {market:String} AND SYMBOL = {symbol:String}
+				AND INTERVAL_CODE = {interval:String}`
+		var rows []struct {
+			Last  string `json:"LAST"`
+			Count int64  `json:"CNT"`
+		}
+		if err := db.QuotesClickHouse.Query(ctx, query, map[string]string{
+			"exid":     strconv.Itoa(exchangeID),
+			"market":   market,
+			"symbol":   symbol,
+			"interval": interval,
+		}, &rows); err != nil {
+			return nil, fmt.Errorf("get last candle time: %w", err)
+		}
+		if len(rows) == 0 || rows[0].Count == 0 {
+			return nil, nil
+		}
+		last, err := time.ParseInLocation(db.ClickHouseTimeFormat, rows[0].Last, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("parse candle time: %w", err)
+		}
+		return &last, nil
+
+	case db.QuotesEngineMySQL:
+		var last sql.NullTime
+		query := `SELECT MAX(OPEN_TIME) FROM CANDLES WHERE EXID = ? AND MARKET_TYPE = ? AND SYMBOL = ? AND INTERVAL_CODE = ?`
+		if err := db.Quotes.QueryRowContext(ctx, query, exchangeID, market, symbol, interval).Scan(&last); err != nil {
+			return nil, fmt.Errorf("get last candle time: %w", err)
+		}
+		if !last.Valid {
+			return nil, nil
+		}
+		return &last.Time, nil
+
+	default:
+		return nil, db.ErrQuotesNotConfigured
+	}
+}
@@ -0,0 +1,290 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CoinRepository - репозиторий справочника монет (таблицы COINS и COIN_NETWORKS).
+type CoinRepository struct{}
+
+// NewCoinRepository создаёт новый экземпляр CoinRepository.
+func NewCoinRepository() *CoinRepository {
+	return &CoinRepository{}
+}
+
+// CoinsResponse - страница списка монет для DataTables.
+type CoinsResponse struct {
+	Data            []*models.CoinSummary
+	RecordsTotal    int
+	RecordsFiltered int
+}
+
+// SyncExchange сохраняет справочник монет биржи: монеты добавляются или обновляются по тикеру,
+// а сети биржи заменяются целиком (сети, которых биржа больше не отдаёт, удаляются).
+// networks - сети по тикеру монеты. Возвращает количество сохранённых сетей.
+func (r *CoinRepository) SyncExchange(exchangeID int, coins []*models.Coin, networks map[string][]*models.CoinNetwork, syncedAt time.Time) (int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer db.RollbackTransaction(tx)
+
+	syncTime := syncedAt.UTC().Format("2006-01-02 15:04:05")
+	ids := make(map[string]int, len(coins))
+	for _, coin := range coins {
+		// Пустое название от биржи не затирает уже известное.
+		if _, err := tx.Exec(`INSERT INTO COINS (SYMBOL, NAME, DATE_SYNC) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE NAME = IF(VALUES(NAME) <> '', VALUES(NAME), NAME), DATE_SYNC = VALUES(DATE_SYNC)`,
+			coin.Symbol, coin.Name, syncTime); err != nil {
+			return 0, fmt.Errorf("upsert coin %s: %w", coin.Symbol, err)
+		}
+		var id int
+		if err := tx.QueryRow(`SELECT ID FROM COINS WHERE SYMBOL = ?`, coin.Symbol).Scan(&id); err != nil {
+			return 0, fmt.Errorf("find coin %s: %w", coin.Symbol, err)
+		}
+		ids[coin.Symbol] = id
+	}
+
+	if _, err := tx.Exec(`DELETE FROM COIN_NETWORKS WHERE EXID = ?`, exchangeID); err != nil {
+		return 0, fmt.Errorf("delete coin networks: %w", err)
+	}
+	saved := 0
+	for symbol, items := range networks {
+		coinID, ok := ids[symbol]
+		if !ok {
+			continue
+		}
+		for _, n := range items {
+			if _, err := tx.Exec(`INSERT INTO COIN_NETWORKS
+				(COIN_ID, EXID, NETWORK, NETWORK_NAME, CONTRACT_ADDRESS, DEPOSIT_ENABLED, WITHDRAW_ENABLED,
+				 WITHDRAW_FEE, WITHDRAW_MIN, CONFIRMATIONS, DATE_SYNC)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON DUPLICATE KEY UPDATE NETWORK_NAME = VALUES(NETWORK_NAME), CONTRACT_ADDRESS = VALUES(CONTRACT_ADDRESS),
+					DEPOSIT_ENABLED = VALUES(DEPOSIT_ENABLED), WITHDRAW_ENABLED = VALUES(WITHDRAW_ENABLED),
+					WITHDRAW_FEE = VALUES(WITHDRAW_FEE), WITHDRAW_MIN = VALUES(WITHDRAW_MIN),
+					CONFIRMATIONS = VALUES(CONFIRMATIONS), DATE_SYNC = VALUES(DATE_SYNC)`,
+				coinID, exchangeID, n.Network, n.NetworkName, n.ContractAddress, n.DepositEnabled, n.WithdrawEnabled,
+				n.WithdrawFee, n.WithdrawMin, n.Confirmations, syncTime); err != nil {
+				return 0, fmt.Errorf("insert coin network %s/%s: %w", symbol, n.Network, err)
+			}
+			saved++
+		}
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, err
+	}
+	return saved, nil
+}
+
+// FindAllWithPagination возвращает страницу монет со сводкой по сетям для DataTables.
+func (r *CoinRepository) FindAllWithPagination(req *DataTablesRequest) (*CoinsResponse, error) {
+	columnMap := map[string]string{
+		"id":                "ID",
+		"symbol":            "SYMBOL",
+		"name":              "NAME",
+		"exchanges":         "EXCHANGES",
+		"networks":          "NETWORKS",
+		"deposit_networks":  "DEPOSIT_NETWORKS",
+		"withdraw_networks": "WITHDRAW_NETWORKS",
+		"date_sync":         "DATE_SYNC",
+	}
+
+	var whereConditions []string
+	var whereArgs []interface{}
+	if req.Search != "" {
+		whereConditions = append(whereConditions, `(q.SYMBOL LIKE CONCAT('%', ?, '%') OR q.NAME LIKE CONCAT('%', ?, '%'))`)
+		whereArgs = append(whereArgs, req.Search, req.Search)
+	}
+	for _, col := range req.Columns {
+		value := strings.TrimSpace(col.Search.Value)
+		dbColumn, exists := columnMap[col.Data]
+		if !col.Searchable || value == "" || !exists {
+			continue
+		}
+		switch dbColumn {
+		case "ID", "EXCHANGES", "NETWORKS", "DEPOSIT_NETWORKS", "WITHDRAW_NETWORKS":
+			whereConditions = append(whereConditions, `q.`+dbColumn+` = ?`)
+			whereArgs = append(whereArgs, value)
+		default:
+			whereConditions = append(whereConditions, `q.`+dbColumn+` LIKE CONCAT('%', ?, '%')`)
+			whereArgs = append(whereArgs, value)
+		}
+	}
+	whereClause := ""
+	if len(whereConditions) > 0 {
+		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
+	}
+
+	from := `FROM (
+			SELECT c.ID, c.SYMBOL, c.NAME, c.ICON, c.DATE_SYNC,
+				COUNT(DISTINCT n.EXID) AS EXCHANGES,
+				COUNT(n.ID) AS NETWORKS,
+				COALESCE(SUM(n.DEPOSIT_ENABLED), 0) AS DEPOSIT_NETWORKS,
+				COALESCE(SUM(n.WITHDRAW_ENABLED), 0) AS WITHDRAW_NETWORKS
+			FROM COINS c
+			LEFT JOIN COIN_NETWORKS n ON n.COIN_ID = c.ID
+			GROUP BY c.ID, c.SYMBOL, c.NAME, c.ICON, c.DATE_SYNC
+		) q `
+
+	var recordsTotal int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM COINS`).Scan(&recordsTotal); err != nil {
+		return nil, fmt.Errorf("failed to count total records: %w", err)
+	}
+	var recordsFiltered int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) `+from+whereClause, whereArgs...).Scan(&recordsFiltered); err != nil {
+		return nil, fmt.Errorf("failed to count filtered records: %w", err)
+	}
+
+	orderClause := "ORDER BY q.EXCHANGES DESC, q.SYMBOL ASC"
+	if len(req.Order) > 0 {
+		orderCol := req.Order[0].Column
+		if orderCol >= 0 && orderCol < len(req.Columns) {
+			if dbColumn, exists := columnMap[req.Columns[orderCol].Data]; exists {
+				dir := "ASC"
+				if req.Order[0].Dir == "desc" {
+					dir = "DESC"
+				}
+				orderClause = "ORDER BY q." + dbColumn + " " + dir + ", q.SYMBOL ASC"
+			}
+		}
+	}
+	length := req.Length
+	if length <= 0 {
+		length = 50
+	}
+	start := req.Start
+	if start < 0 {
+		start = 0
+	}
+
+	query := `SELECT q.ID, q.SYMBOL, q.NAME, q.ICON, q.DATE_SYNC, q.EXCHANGES, q.NETWORKS, q.DEPOSIT_NETWORKS, q.WITHDRAW_NETWORKS
+		` + from + whereClause + `
+		` + orderClause + fmt.Sprintf(" LIMIT %d, %d", start, length)
+	rows, err := db.DB.Query(query, whereArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	resp := &CoinsResponse{RecordsTotal: recordsTotal, RecordsFiltered: recordsFiltered}
+	for rows.Next() {
+		var item models.CoinSummary
+		var icon sql.NullString
+		if err := rows.Scan(&item.ID, &item.Symbol, &item.Name, &icon, &item.DateSync,
+			&item.Exchanges, &item.Networks, &item.DepositNetworks, &item.WithdrawNetworks); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if icon.Valid {
+			item.Icon = &icon.String
+		}
+		resp.Data = append(resp.Data, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return resp, nil
+}
+
+// FindBySymbols возвращает монеты по тикерам (для подписей и иконок на других страницах).
+func (r *CoinRepository) FindBySymbols(symbols []string) ([]*models.Coin, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, 0, len(symbols))
+	args := make([]interface{}, 0, len(symbols))
+	for _, symbol := range symbols {
+		placeholders = append(placeholders, "?")
+		args = append(args, strings.ToUpper(strings.TrimSpace(symbol)))
+	}
+	rows, err := db.DB.Query(`SELECT ID, SYMBOL, NAME, ICON, DATE_SYNC FROM COINS
+		WHERE SYMBOL IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY SYMBOL ASC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find coins: %w", err)
+	}
+	defer rows.Close()
+	return scanCoins(rows)
+}
+
+// FindWithoutIcon возвращает монеты, для которых ещё не загружена иконка.
+func (r *CoinRepository) FindWithoutIcon(limit int) ([]*models.Coin, error) {
+	rows, err := db.DB.Query(`SELECT ID, SYMBOL, NAME, ICON, DATE_SYNC FROM COINS
+		WHERE ICON IS NULL
+		ORDER BY SYMBOL ASC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("find coins without icon: %w", err)
+	}
+	defer rows.Close()
+	return scanCoins(rows)
+}
+
+func scanCoins(rows *sql.Rows) ([]*models.Coin, error) {
+	result := make([]*models.Coin, 0)
+	for rows.Next() {
+		var item models.Coin
+		var icon sql.NullString
+		if err := rows.Scan(&item.ID, &item.Symbol, &item.Name, &icon, &item.DateSync); err != nil {
+			return nil, fmt.Errorf("scan coin: %w", err)
+		}
+		if icon.Valid {
+			item.Icon = &icon.String
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate coins rows: %w", err)
+	}
+	return result, nil
+}
+
+// SetIcon сохраняет путь к локальной иконке монеты.
+func (r *CoinRepository) SetIcon(coinID int, icon string) error {
+	if _, err := db.DB.Exec(`UPDATE COINS SET ICON = ? WHERE ID = ?`, icon, coinID); err != nil {
+		return fmt.Errorf("update coin icon: %w", err)
+	}
+	return nil
+}
+
+// FindNetworks возвращает сети монеты на всех биржах.
+func (r *CoinRepository) FindNetworks(coinID int) ([]*models.CoinNetwork, error) {
+	rows, err := db.DB.Query(`SELECT n.COIN_ID, n.EXID, COALESCE(e.NAME, ''), n.NETWORK, n.NETWORK_NAME, n.CONTRACT_ADDRESS,
+			n.DEPOSIT_ENABLED, n.WITHDRAW_ENABLED, n.WITHDRAW_FEE, n.WITHDRAW_MIN, n.CONFIRMATIONS, n.DATE_SYNC
+		FROM COIN_NETWORKS n
+		LEFT JOIN EXCHANGE e ON e.ID = n.EXID
+		WHERE n.COIN_ID = ?
+		ORDER BY e.NAME ASC, n.NETWORK ASC`, coinID)
+	if err != nil {
+		return nil, fmt.Errorf("find coin networks: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.CoinNetwork, 0)
+	for rows.Next() {
+		var n models.CoinNetwork
+		if err := rows.Scan(&n.CoinID, &n.ExID, &n.ExchangeName, &n.Network, &n.NetworkName, &n.ContractAddress,
+			&n.DepositEnabled, &n.WithdrawEnabled, &n.WithdrawFee, &n.WithdrawMin, &n.Confirmations, &n.DateSync); err != nil {
+			return nil, fmt.Errorf("scan coin network: %w", err)
+		}
+		result = append(result, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate coin networks rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindCoinBySymbol находит монету по тикеру. Возвращает nil, nil если монеты нет.
+func (r *CoinRepository) FindCoinBySymbol(symbol string) (*models.Coin, error) {
+	coins, err := r.FindBySymbols([]string{symbol})
+	if err != nil || len(coins) == 0 {
+		return nil, err
+	}
+	return coins[0], nil
+}
@@ -3,8 +3,10 @@ package repositories
 import (
 	"ctweb/internal/db"
 	"ctweb/internal/models"
+	"ctweb/internal/secrets"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // ExchangeAccountRepository - репозиторий для работы с аккаунтами бирж.
@@ -16,11 +18,62 @@ func NewExchangeAccountRepository() *ExchangeAccountRepository {
 	return &ExchangeAccountRepository{}
 }
 
-// FindByID находит аккаунт по ID и UID владельца (только не удалённые).
-//
-// Используем фильтр по UID, поскольку в PHP все операции выполняются от имени авторизованного пользователя.
-func (r *ExchangeAccountRepository) FindByID(id int, userID int) (*models.ExchangeAccount, error) {
-	query := `SELECT
+// Колонки EXCHANGE_ACCOUNTS с ключами API.
+const (
+	AccountKeyColumnAPI    = "API_KEY"
+	AccountKeyColumnSecret = "SECRET_KEY"
+	AccountKeyColumnAdd    = "ADD_KEY"
+)
+
+// AccountKeyScope возвращает scope шифрования ключа: значение привязано к колонке
+// и аккаунту и не расшифруется, если его скопировать в другую запись.
+func AccountKeyScope(accountID int, column string) string {
+	return fmt.Sprintf("EXCHANGE_ACCOUNTS.%s:%d", column, accountID)
+}
+
+// decryptKeys расшифровывает API_KEY/SECRET_KEY/ADD_KEY прочитанного аккаунта.
+// Записи, сохранённые до включения шифрования, читаются как есть.
+func decryptKeys(acc *models.ExchangeAccount) error {
+	env := secrets.Default()
+	var err error
+	if acc.ApiKey, err = env.Decrypt(acc.ApiKey, AccountKeyScope(acc.ID, AccountKeyColumnAPI)); err != nil {
+		return fmt.Errorf("decrypt api key of account %d: %w", acc.ID, err)
+	}
+	if acc.SecretKey, err = env.Decrypt(acc.SecretKey, AccountKeyScope(acc.ID, AccountKeyColumnSecret)); err != nil {
+		return fmt.Errorf("decrypt secret key of account %d: %w", acc.ID, err)
+	}
+	if acc.AddKey != nil {
+		addKey, err := env.Decrypt(*acc.AddKey, AccountKeyScope(acc.ID, AccountKeyColumnAdd))
+		if err != nil {
+			return fmt.Errorf("decrypt additional key of account %d: %w", acc.ID, err)
+		}
+		acc.AddKey = &addKey
+	}
+	return nil
+}
+
+// encryptKeys возвращает значения ключей аккаунта acc.ID для записи в БД
+// (зашифрованные, если шифрование настроено).
+func encryptKeys(acc *models.ExchangeAccount) (apiKey, secretKey string, addKey *string, err error) {
+	env := secrets.Default()
+	if apiKey, err = env.Encrypt(acc.ApiKey, AccountKeyScope(acc.ID, AccountKeyColumnAPI)); err != nil {
+		return "", "", nil, fmt.Errorf("encrypt api key: %w", err)
+	}
+	if secretKey, err = env.Encrypt(acc.SecretKey, AccountKeyScope(acc.ID, AccountKeyColumnSecret)); err != nil {
+		return "", "", nil, fmt.Errorf("encrypt secret key: %w", err)
+	}
+	if acc.AddKey != nil {
+		value, err := env.Encrypt(*acc.AddKey, AccountKeyScope(acc.ID, AccountKeyColumnAdd))
+		if err != nil {
+			return "", "", nil, fmt.Errorf("encrypt additional key: %w", err)
+		}
+		addKey = &value
+	}
+	return apiKey, secretKey, addKey, nil
+}
+
+// accountColumns - колонки EXCHANGE_ACCOUNTS, которые читает scanAccount.
+const accountColumns = `
 		ID,
 		EXID,
 		UID,
@@ -32,14 +85,32 @@ func (r *ExchangeAccountRepository) FindByID(id int, userID int) (*models.Exchan
 		ADD_KEY,
 		NOTE,
 		DELETED,
-		TIMESTAMP_X
-	FROM EXCHANGE_ACCOUNTS
-	WHERE ID = ? AND UID = ? AND DELETED = 0`
+		TIMESTAMP_X,
+		LAST_CHECK_AT,
+		LAST_CHECK_STATUS,
+		LAST_CHECK_MESSAGE,
+		PERM_READ,
+		PERM_TRADE,
+		PERM_WITHDRAW,
+		IP_RESTRICTED,
+		PARENT_ID,
+		SUB_ACCOUNT_LABEL`
+
+// rowScanner - общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanAccount читает аккаунт (колонки accountColumns, затем колонки extra) и расшифровывает ключи.
+func scanAccount(row rowScanner, extra ...interface{}) (*models.ExchangeAccount, error) {
 	var acc models.ExchangeAccount
-	var addKey, note sql.NullString
+	var addKey, note, checkStatus, checkMessage sql.NullString
+	var checkedAt sql.NullTime
+	var permRead, permTrade, permWithdraw, ipRestricted sql.NullBool
+	var parentID sql.NullInt64
+	var subLabel sql.NullString
 
-	err := db.DB.QueryRow(query, id, userID).Scan(
+	dest := []interface{}{
 		&acc.ID,
 		&acc.ExID,
 		&acc.UID,
@@ -52,12 +123,18 @@ func (r *ExchangeAccountRepository) FindByID(id int, userID int) (*models.Exchan
 		&note,
 		&acc.Deleted,
 		&acc.DateCreate,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("exchange account with ID %d not found", id)
-		}
-		return nil, fmt.Errorf("database error: %w", err)
+		&checkedAt,
+		&checkStatus,
+		&checkMessage,
+		&permRead,
+		&permTrade,
+		&permWithdraw,
+		&ipRestricted,
+		&parentID,
+		&subLabel,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
 
 	if addKey.Valid {
@@ -66,25 +143,49 @@ func (r *ExchangeAccountRepository) FindByID(id int, userID int) (*models.Exchan
 	if note.Valid {
 		acc.Note = &note.String
 	}
-
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		acc.ParentID = &id
+	}
+	acc.SubAccountLabel = subLabel.String
+	if checkedAt.Valid && checkStatus.Valid {
+		acc.LastCheck = &models.AccountKeyCheck{
+			CheckedAt:    checkedAt.Time,
+			Status:       checkStatus.String,
+			Message:      checkMessage.String,
+			Read:         permRead.Bool,
+			Trade:        permTrade.Bool,
+			Withdraw:     permWithdraw.Bool,
+			IPRestricted: ipRestricted.Bool,
+		}
+	}
+	if err := decryptKeys(&acc); err != nil {
+		return nil, err
+	}
 	return &acc, nil
 }
 
+// FindByID находит аккаунт по ID и UID владельца (только не удалённые).
+//
+// Используем фильтр по UID, поскольку в PHP все операции выполняются от имени авторизованного пользователя.
+func (r *ExchangeAccountRepository) FindByID(id int, userID int) (*models.ExchangeAccount, error) {
+	query := `SELECT` + accountColumns + `
+	FROM EXCHANGE_ACCOUNTS
+	WHERE ID = ? AND UID = ? AND DELETED = 0`
+
+	acc, err := scanAccount(db.DB.QueryRow(query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("exchange account with ID %d not found", id)
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return acc, nil
+}
+
 // FindAllByUser находит все аккаунты пользователя (не удалённые).
 func (r *ExchangeAccountRepository) FindAllByUser(userID int) ([]*models.ExchangeAccount, error) {
-	query := `SELECT
-		ID,
-		EXID,
-		UID,
-		ACCOUNT_NAME,
-		PRIORITY,
-		ACTIVE,
-		API_KEY,
-		SECRET_KEY,
-		ADD_KEY,
-		NOTE,
-		DELETED,
-		TIMESTAMP_X
+	query := `SELECT` + accountColumns + `
 	FROM EXCHANGE_ACCOUNTS
 	WHERE UID = ? AND DELETED = 0
 	ORDER BY PRIORITY DESC, EXID ASC, ID ASC`
@@ -97,41 +198,44 @@ func (r *ExchangeAccountRepository) FindAllByUser(userID int) ([]*models.Exchang
 
 	var accounts []*models.ExchangeAccount
 	for rows.Next() {
-		var acc models.ExchangeAccount
-		var addKey, note sql.NullString
-
-		err := rows.Scan(
-			&acc.ID,
-			&acc.ExID,
-			&acc.UID,
-			&acc.AccountName,
-			&acc.Priority,
-			&acc.Active,
-			&acc.ApiKey,
-			&acc.SecretKey,
-			&addKey,
-			&note,
-			&acc.Deleted,
-			&acc.DateCreate,
-		)
+		acc, err := scanAccount(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan error: %w", err)
 		}
+		accounts = append(accounts, acc)
+	}
 
-		if addKey.Valid {
-			acc.AddKey = &addKey.String
-		}
-		if note.Valid {
-			acc.Note = &note.String
-		}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// FindAllActive находит активные аккаунты всех пользователей (для фоновых задач).
+func (r *ExchangeAccountRepository) FindAllActive() ([]*models.ExchangeAccount, error) {
+	query := `SELECT` + accountColumns + `
+	FROM EXCHANGE_ACCOUNTS
+	WHERE ACTIVE = 1 AND DELETED = 0
+	ORDER BY UID ASC, ID ASC`
 
-		accounts = append(accounts, &acc)
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
 	}
+	defer rows.Close()
 
+	var accounts []*models.ExchangeAccount
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		accounts = append(accounts, acc)
+	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %w", err)
 	}
-
 	return accounts, nil
 }
 
@@ -170,6 +274,7 @@ func (r *ExchangeAccountRepository) ExistsByNameExcludingID(userID, exchangeID,
 }
 
 // Create создаёт новый аккаунт (soft-delete = 0, активность по статусу).
+// Ключи шифруются с привязкой к ID, поэтому записываются после вставки в той же транзакции.
 func (r *ExchangeAccountRepository) Create(acc *models.ExchangeAccount) (int, error) {
 	tx, err := db.BeginTransaction()
 	if err != nil {
@@ -178,20 +283,18 @@ func (r *ExchangeAccountRepository) Create(acc *models.ExchangeAccount) (int, er
 	defer db.RollbackTransaction(tx)
 
 	query := `INSERT INTO EXCHANGE_ACCOUNTS
-		(ACCOUNT_NAME, EXID, ACTIVE, UID, PRIORITY, API_KEY, SECRET_KEY, NOTE, ADD_KEY, DELETED)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0)`
+		(ACCOUNT_NAME, EXID, ACTIVE, UID, PRIORITY, API_KEY, SECRET_KEY, NOTE, PARENT_ID, SUB_ACCOUNT_LABEL, DELETED)
+		VALUES (?, ?, ?, ?, ?, '', '', ?, ?, ?, 0)`
 
-	var note, addKey interface{}
+	var note, addKey, subLabel interface{}
+	if acc.SubAccountLabel != "" {
+		subLabel = acc.SubAccountLabel
+	}
 	if acc.Note != nil {
 		note = *acc.Note
 	} else {
 		note = nil
 	}
-	if acc.AddKey != nil {
-		addKey = *acc.AddKey
-	} else {
-		addKey = nil
-	}
 
 	result, err := tx.Exec(query,
 		acc.AccountName,
@@ -199,10 +302,9 @@ func (r *ExchangeAccountRepository) Create(acc *models.ExchangeAccount) (int, er
 		acc.Active,
 		acc.UID,
 		acc.Priority,
-		acc.ApiKey,
-		acc.SecretKey,
 		note,
-		addKey,
+		acc.ParentID,
+		subLabel,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("database error: %w", err)
@@ -213,6 +315,22 @@ func (r *ExchangeAccountRepository) Create(acc *models.ExchangeAccount) (int, er
 		return 0, fmt.Errorf("failed to get last insert id: %w", err)
 	}
 
+	stored := *acc
+	stored.ID = int(id)
+	apiKey, secretKey, storedAddKey, err := encryptKeys(&stored)
+	if err != nil {
+		return 0, err
+	}
+	if storedAddKey != nil {
+		addKey = *storedAddKey
+	} else {
+		addKey = nil
+	}
+	if _, err := tx.Exec(`UPDATE EXCHANGE_ACCOUNTS SET API_KEY = ?, SECRET_KEY = ?, ADD_KEY = ? WHERE ID = ?`,
+		apiKey, secretKey, addKey, id); err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+
 	if err := db.CommitTransaction(tx); err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -220,20 +338,39 @@ func (r *ExchangeAccountRepository) Create(acc *models.ExchangeAccount) (int, er
 	return int(id), nil
 }
 
+// CountSubAccounts возвращает количество субаккаунтов мастер-аккаунта (не удалённых).
+func (r *ExchangeAccountRepository) CountSubAccounts(parentID, userID int) (int, error) {
+	query := `SELECT COUNT(*) FROM EXCHANGE_ACCOUNTS WHERE PARENT_ID = ? AND UID = ? AND DELETED = 0`
+
+	var count int
+	if err := db.DB.QueryRow(query, parentID, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	return count, nil
+}
+
 // Update обновляет аккаунт. SecretKey/AddKey обновляются только если переданы непустые значения.
 func (r *ExchangeAccountRepository) Update(acc *models.ExchangeAccount) error {
+	apiKey, secretKey, addKey, err := encryptKeys(acc)
+	if err != nil {
+		return err
+	}
+
 	tx, err := db.BeginTransaction()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer db.RollbackTransaction(tx)
 
-	var note interface{}
+	var note, subLabel interface{}
 	if acc.Note != nil {
 		note = *acc.Note
 	} else {
 		note = nil
 	}
+	if acc.SubAccountLabel != "" {
+		subLabel = acc.SubAccountLabel
+	}
 
 	// Базовые поля
 	query := `UPDATE EXCHANGE_ACCOUNTS SET
@@ -242,24 +379,28 @@ func (r *ExchangeAccountRepository) Update(acc *models.ExchangeAccount) error {
 		ACTIVE = ?,
 		PRIORITY = ?,
 		API_KEY = ?,
-		NOTE = ?`
+		NOTE = ?,
+		PARENT_ID = ?,
+		SUB_ACCOUNT_LABEL = ?`
 	args := []interface{}{
 		acc.AccountName,
 		acc.ExID,
 		acc.Active,
 		acc.Priority,
-		acc.ApiKey,
+		apiKey,
 		note,
+		acc.ParentID,
+		subLabel,
 	}
 
 	// Опциональные поля
-	if acc.SecretKey != "" {
+	if secretKey != "" {
 		query += `, SECRET_KEY = ?`
-		args = append(args, acc.SecretKey)
+		args = append(args, secretKey)
 	}
-	if acc.AddKey != nil && *acc.AddKey != "" {
+	if addKey != nil && *addKey != "" {
 		query += `, ADD_KEY = ?`
-		args = append(args, *acc.AddKey)
+		args = append(args, *addKey)
 	}
 
 	query += ` WHERE ID = ? AND UID = ? AND DELETED = 0`
@@ -306,8 +447,339 @@ func (r *ExchangeAccountRepository) SoftDelete(id int, userID int) error {
 		return fmt.Errorf("exchange account with ID %d not found or already deleted", id)
 	}
 
+	// Субаккаунты удалённого мастер-аккаунта становятся самостоятельными.
+	if _, err := tx.Exec(`UPDATE EXCHANGE_ACCOUNTS SET PARENT_ID = NULL WHERE PARENT_ID = ? AND UID = ?`, id, userID); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
 	if err := db.CommitTransaction(tx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return nil
 }
+
+// FindAllStoredKeys возвращает ключи всех аккаунтов (включая удалённые) в том виде,
+// в каком они хранятся в БД, без расшифровки. Используется миграцией и ротацией ключей.
+func (r *ExchangeAccountRepository) FindAllStoredKeys() ([]*models.StoredAccountKeys, error) {
+	rows, err := db.DB.Query(`SELECT ID, API_KEY, SECRET_KEY, ADD_KEY FROM EXCHANGE_ACCOUNTS ORDER BY ID`)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.StoredAccountKeys
+	for rows.Next() {
+		var item models.StoredAccountKeys
+		var addKey sql.NullString
+		if err := rows.Scan(&item.ID, &item.ApiKey, &item.SecretKey, &addKey); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if addKey.Valid {
+			item.AddKey = &addKey.String
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// ReplaceStoredKeys перезаписывает ключи аккаунта, если они не изменились с момента чтения
+// (old). Возвращает false, если запись успели изменить - её нужно обработать повторно.
+func (r *ExchangeAccountRepository) ReplaceStoredKeys(old, updated *models.StoredAccountKeys) (bool, error) {
+	query := `UPDATE EXCHANGE_ACCOUNTS SET API_KEY = ?, SECRET_KEY = ?, ADD_KEY = ?
+		WHERE ID = ? AND API_KEY = ? AND SECRET_KEY = ? AND ADD_KEY <=> ?`
+	result, err := db.DB.Exec(query,
+		updated.ApiKey,
+		updated.SecretKey,
+		updated.AddKey,
+		old.ID,
+		old.ApiKey,
+		old.SecretKey,
+		old.AddKey,
+	)
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// SaveKeyCheck сохраняет результат проверки ключа API аккаунта.
+func (r *ExchangeAccountRepository) SaveKeyCheck(accountID, userID int, check *models.AccountKeyCheck) error {
+	query := `UPDATE EXCHANGE_ACCOUNTS SET
+		LAST_CHECK_AT = ?,
+		LAST_CHECK_STATUS = ?,
+		LAST_CHECK_MESSAGE = ?,
+		PERM_READ = ?,
+		PERM_TRADE = ?,
+		PERM_WITHDRAW = ?,
+		IP_RESTRICTED = ?
+	WHERE ID = ? AND UID = ? AND DELETED = 0`
+
+	message := check.Message
+	if runes := []rune(message); len(runes) > 255 {
+		message = string(runes[:255])
+	}
+	result, err := db.DB.Exec(query,
+		check.CheckedAt,
+		check.Status,
+		message,
+		check.Read,
+		check.Trade,
+		check.Withdraw,
+		check.IPRestricted,
+		accountID,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("exchange account with ID %d not found", accountID)
+	}
+	return nil
+}
+
+// Deactivate отключает аккаунт (ACTIVE = 0), не меняя остальные поля.
+func (r *ExchangeAccountRepository) Deactivate(id, userID int) error {
+	query := `UPDATE EXCHANGE_ACCOUNTS SET ACTIVE = 0 WHERE ID = ? AND UID = ? AND DELETED = 0`
+	if _, err := db.DB.Exec(query, id, userID); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
+
+// AdminAccountRow - аккаунт в списке аккаунтов всех пользователей (админка).
+type AdminAccountRow struct {
+	Account      *models.ExchangeAccount
+	UserLogin    string
+	ExchangeName string
+	ParentName   string // Имя мастер-аккаунта (пусто - не субаккаунт)
+}
+
+// AdminAccountsResponse - страница списка аккаунтов всех пользователей для DataTables.
+type AdminAccountsResponse struct {
+	Data            []*AdminAccountRow
+	RecordsTotal    int
+	RecordsFiltered int
+}
+
+// AccountOwner - пользователь, у которого есть аккаунты бирж (фильтр и отключение в админке).
+type AccountOwner struct {
+	ID       int
+	Login    string
+	Accounts int
+	Active   int
+}
+
+// FindAllWithPagination находит аккаунты всех пользователей (не удалённые) для DataTables.
+// Поиск по ключам невозможен: они хранятся в зашифрованном виде.
+func (r *ExchangeAccountRepository) FindAllWithPagination(req *DataTablesRequest) (*AdminAccountsResponse, error) {
+	columnMap := map[string]string{
+		"id":            "ID",
+		"uid":           "UID",
+		"user_login":    "USER_LOGIN",
+		"exchange_name": "EXCHANGE_NAME",
+		"account_name":  "ACCOUNT_NAME",
+		"status":        "ACTIVE",
+		"key_check":     "LAST_CHECK_STATUS",
+		"date_create":   "TIMESTAMP_X",
+	}
+
+	var whereConditions []string
+	var whereArgs []interface{}
+	if req.Search != "" {
+		whereConditions = append(whereConditions,
+			`(q.USER_LOGIN LIKE CONCAT('%', ?, '%') OR q.EXCHANGE_NAME LIKE CONCAT('%', ?, '%') OR q.ACCOUNT_NAME LIKE CONCAT('%', ?, '%'))`)
+		whereArgs = append(whereArgs, req.Search, req.Search, req.Search)
+	}
+	for _, col := range req.Columns {
+		value := strings.TrimSpace(col.Search.Value)
+		dbColumn, exists := columnMap[col.Data]
+		if !col.Searchable || value == "" || !exists {
+			continue
+		}
+		switch dbColumn {
+		case "ACTIVE":
+			lower := strings.ToLower(value)
+			switch {
+			case strings.Contains(lower, "active") || value == "1":
+				whereConditions = append(whereConditions, `q.ACTIVE = 1`)
+			case strings.Contains(lower, "blocked") || value == "0":
+				whereConditions = append(whereConditions, `q.ACTIVE = 0`)
+			}
+		case "ID", "UID":
+			whereConditions = append(whereConditions, `q.`+dbColumn+` = ?`)
+			whereArgs = append(whereArgs, value)
+		default:
+			whereConditions = append(whereConditions, `q.`+dbColumn+` LIKE CONCAT('%', ?, '%')`)
+			whereArgs = append(whereArgs, value)
+		}
+	}
+	whereClause := ""
+	if len(whereConditions) > 0 {
+		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
+	}
+
+	from := `FROM (
+			SELECT ea.*, COALESCE(u.LOGIN, '') AS USER_LOGIN, COALESCE(e.NAME, '') AS EXCHANGE_NAME,
+				COALESCE(pa.ACCOUNT_NAME, '') AS PARENT_NAME, COALESCE(pa.ID, ea.ID) AS MASTER_ID
+			FROM EXCHANGE_ACCOUNTS ea
+			LEFT JOIN USER u ON u.ID = ea.UID
+			LEFT JOIN EXCHANGE e ON e.ID = ea.EXID
+			LEFT JOIN EXCHANGE_ACCOUNTS pa ON pa.ID = ea.PARENT_ID AND pa.UID = ea.UID AND pa.DELETED = 0
+			WHERE ea.DELETED = 0
+		) q `
+
+	var recordsTotal int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM EXCHANGE_ACCOUNTS WHERE DELETED = 0`).Scan(&recordsTotal); err != nil {
+		return nil, fmt.Errorf("failed to count total records: %w", err)
+	}
+	var recordsFiltered int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) `+from+whereClause, whereArgs...).Scan(&recordsFiltered); err != nil {
+		return nil, fmt.Errorf("failed to count filtered records: %w", err)
+	}
+
+	// По умолчанию субаккаунты идут сразу за своим мастер-аккаунтом.
+	orderClause := "ORDER BY q.USER_LOGIN ASC, q.EXCHANGE_NAME ASC, q.MASTER_ID ASC, q.PARENT_NAME != '' ASC, q.ID ASC"
+	if len(req.Order) > 0 {
+		orderCol := req.Order[0].Column
+		if orderCol >= 0 && orderCol < len(req.Columns) {
+			if dbColumn, exists := columnMap[req.Columns[orderCol].Data]; exists {
+				dir := "ASC"
+				if req.Order[0].Dir == "desc" {
+					dir = "DESC"
+				}
+				orderClause = "ORDER BY q." + dbColumn + " " + dir + ", q.MASTER_ID ASC, q.PARENT_NAME != '' ASC, q.ID ASC"
+			}
+		}
+	}
+	length := req.Length
+	if length <= 0 {
+		length = 50
+	}
+	start := req.Start
+	if start < 0 {
+		start = 0
+	}
+
+	query := `SELECT` + accountColumns + `, USER_LOGIN, EXCHANGE_NAME, PARENT_NAME
+		` + from + whereClause + `
+		` + orderClause + fmt.Sprintf(" LIMIT %d, %d", start, length)
+	rows, err := db.DB.Query(query, whereArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	resp := &AdminAccountsResponse{RecordsTotal: recordsTotal, RecordsFiltered: recordsFiltered}
+	for rows.Next() {
+		var row AdminAccountRow
+		acc, err := scanAccount(rows, &row.UserLogin, &row.ExchangeName, &row.ParentName)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		row.Account = acc
+		resp.Data = append(resp.Data, &row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return resp, nil
+}
+
+// FindAccountOwners возвращает пользователей, у которых есть не удалённые аккаунты бирж.
+func (r *ExchangeAccountRepository) FindAccountOwners() ([]*AccountOwner, error) {
+	rows, err := db.DB.Query(`SELECT ea.UID, COALESCE(u.LOGIN, ''), COUNT(*), SUM(ea.ACTIVE = 1)
+		FROM EXCHANGE_ACCOUNTS ea
+		LEFT JOIN USER u ON u.ID = ea.UID
+		WHERE ea.DELETED = 0
+		GROUP BY ea.UID, u.LOGIN
+		ORDER BY u.LOGIN, ea.UID`)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var owners []*AccountOwner
+	for rows.Next() {
+		var owner AccountOwner
+		if err := rows.Scan(&owner.ID, &owner.Login, &owner.Accounts, &owner.Active); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		owners = append(owners, &owner)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return owners, nil
+}
+
+// DisableAccounts отключает активные аккаунты: все аккаунты пользователя userID (если ids пуст)
+// или аккаунты из ids. Возвращает ID отключённых аккаунтов.
+func (r *ExchangeAccountRepository) DisableAccounts(userID int, ids []int, modifiedBy int) ([]int, error) {
+	var condition string
+	var args []interface{}
+	switch {
+	case len(ids) > 0:
+		condition = `ID IN (?` + strings.Repeat(",?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	case userID > 0:
+		condition = `UID = ?`
+		args = append(args, userID)
+	default:
+		return nil, fmt.Errorf("no accounts to disable")
+	}
+
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer db.RollbackTransaction(tx)
+
+	rows, err := tx.Query(`SELECT ID FROM EXCHANGE_ACCOUNTS WHERE DELETED = 0 AND ACTIVE = 1 AND `+condition+` FOR UPDATE`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	var disabled []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		disabled = append(disabled, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	if len(disabled) == 0 {
+		return disabled, nil
+	}
+
+	updateArgs := []interface{}{modifiedBy}
+	for _, id := range disabled {
+		updateArgs = append(updateArgs, id)
+	}
+	query := `UPDATE EXCHANGE_ACCOUNTS SET ACTIVE = 0, USER_MODIFY = ?, DATE_MODIFY = NOW()
+		WHERE ID IN (?` + strings.Repeat(",?", len(disabled)-1) + `)`
+	if _, err := tx.Exec(query, updateArgs...); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if err := db.CommitTransaction(tx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return disabled, nil
+}
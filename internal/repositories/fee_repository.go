@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+)
+
+// FeeRepository - репозиторий комиссий бирж (EXCHANGE_FEES) и VIP-переопределений аккаунтов (EXCHANGE_ACCOUNT_FEES).
+type FeeRepository struct{}
+
+// NewFeeRepository создаёт новый экземпляр FeeRepository.
+func NewFeeRepository() *FeeRepository {
+	return &FeeRepository{}
+}
+
+// FindExchangeFees возвращает комиссии биржи по умолчанию для всех рынков.
+func (r *FeeRepository) FindExchangeFees(exchangeID int) ([]*models.FeeSchedule, error) {
+	rows, err := db.DB.Query(
+		`SELECT EXID, MARKET_TYPE, MAKER_FEE, TAKER_FEE FROM EXCHANGE_FEES WHERE EXID = ? ORDER BY MARKET_TYPE`,
+		exchangeID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find exchange fees: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.FeeSchedule, 0, 2)
+	for rows.Next() {
+		var item models.FeeSchedule
+		if err := rows.Scan(&item.ExID, &item.MarketType, &item.MakerFee, &item.TakerFee); err != nil {
+			return nil, fmt.Errorf("scan exchange fee: %w", err)
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate exchange fees rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindExchangeFee возвращает комиссию биржи по умолчанию для рынка (nil, если не задана).
+func (r *FeeRepository) FindExchangeFee(exchangeID int, market string) (*models.FeeSchedule, error) {
+	var item models.FeeSchedule
+	err := db.DB.QueryRow(
+		`SELECT EXID, MARKET_TYPE, MAKER_FEE, TAKER_FEE FROM EXCHANGE_FEES WHERE EXID = ? AND MARKET_TYPE = ?`,
+		exchangeID, market,
+	).Scan(&item.ExID, &item.MarketType, &item.MakerFee, &item.TakerFee)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find exchange fee: %w", err)
+	}
+	return &item, nil
+}
+
+// UpsertExchangeFee сохраняет комиссию биржи по умолчанию для рынка.
+func (r *FeeRepository) UpsertExchangeFee(fee *models.FeeSchedule, userModifyID int) error {
+	_, err := db.DB.Exec(
+		`INSERT INTO EXCHANGE_FEES (EXID, MARKET_TYPE, MAKER_FEE, TAKER_FEE, USER_MODIFY) VALUES(?,?,?,?,?)
+		ON DUPLICATE KEY UPDATE MAKER_FEE = VALUES(MAKER_FEE), TAKER_FEE = VALUES(TAKER_FEE), USER_MODIFY = VALUES(USER_MODIFY)`,
+		fee.ExID, fee.MarketType, fee.MakerFee, fee.TakerFee, userModifyID,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert exchange fee: %w", err)
+	}
+	return nil
+}
+
+// FindAccountFees возвращает VIP-переопределения комиссий аккаунта.
+func (r *FeeRepository) FindAccountFees(accountID int) ([]*models.FeeSchedule, error) {
+	rows, err := db.DB.Query(
+		`SELECT a.EXID, f.ACCOUNT_ID, f.MARKET_TYPE, f.VIP_TIER, f.MAKER_FEE, f.TAKER_FEE
+		FROM EXCHANGE_ACCOUNT_FEES f
+		JOIN EXCHANGE_ACCOUNTS a ON a.ID = f.ACCOUNT_ID
+		WHERE f.ACCOUNT_ID = ?
+		ORDER BY f.MARKET_TYPE`,
+		accountID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find account fees: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.FeeSchedule, 0, 2)
+	for rows.Next() {
+		var item models.FeeSchedule
+		var accID int
+		if err := rows.Scan(&item.ExID, &accID, &item.MarketType, &item.VIPTier, &item.MakerFee, &item.TakerFee); err != nil {
+			return nil, fmt.Errorf("scan account fee: %w", err)
+		}
+		item.AccountID = &accID
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate account fees rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindUserAccountFee возвращает VIP-переопределение комиссии для активного аккаунта
+// пользователя на бирже. Если у пользователя несколько аккаунтов с переопределением,
+// берётся аккаунт с наибольшим PRIORITY. Возвращает nil, если переопределений нет.
+func (r *FeeRepository) FindUserAccountFee(userID, exchangeID int, market string) (*models.FeeSchedule, error) {
+	var item models.FeeSchedule
+	var accID int
+	err := db.DB.QueryRow(
+		`SELECT a.EXID, f.ACCOUNT_ID, f.MARKET_TYPE, f.VIP_TIER, f.MAKER_FEE, f.TAKER_FEE
+		FROM EXCHANGE_ACCOUNT_FEES f
+		JOIN EXCHANGE_ACCOUNTS a ON a.ID = f.ACCOUNT_ID
+		WHERE a.UID = ? AND a.EXID = ? AND a.ACTIVE = 1 AND a.DELETED = 0 AND f.MARKET_TYPE = ?
+		ORDER BY a.PRIORITY DESC, a.ID ASC
+		LIMIT 1`,
+		userID, exchangeID, market,
+	).Scan(&item.ExID, &accID, &item.MarketType, &item.VIPTier, &item.MakerFee, &item.TakerFee)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find user account fee: %w", err)
+	}
+	item.AccountID = &accID
+	return &item, nil
+}
+
+// FindLinkedAccountFee возвращает VIP-переопределение комиссии аккаунта accountID
+// пользователя на бирже (аккаунт позиции). Возвращает nil, если переопределения нет.
+func (r *FeeRepository) FindLinkedAccountFee(userID, exchangeID, accountID int, market string) (*models.FeeSchedule, error) {
+	var item models.FeeSchedule
+	var accID int
+	err := db.DB.QueryRow(
+		`SELECT a.EXID, f.ACCOUNT_ID, f.MARKET_TYPE, f.VIP_TIER, f.MAKER_FEE, f.TAKER_FEE
+		FROM EXCHANGE_ACCOUNT_FEES f
+		JOIN EXCHANGE_ACCOUNTS a ON a.ID = f.ACCOUNT_ID
+		WHERE f.ACCOUNT_ID = ? AND a.UID = ? AND a.EXID = ? AND a.DELETED = 0 AND f.MARKET_TYPE = ?`,
+		accountID, userID, exchangeID, market,
+	).Scan(&item.ExID, &accID, &item.MarketType, &item.VIPTier, &item.MakerFee, &item.TakerFee)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find linked account fee: %w", err)
+	}
+	item.AccountID = &accID
+	return &item, nil
+}
+
+// UpsertAccountFee сохраняет VIP-переопределение комиссии аккаунта для рынка.
+func (r *FeeRepository) UpsertAccountFee(fee *models.FeeSchedule, userModifyID int) error {
+	if fee.AccountID == nil {
+		return fmt.Errorf("account id is required")
+	}
+	_, err := db.DB.Exec(
+		`INSERT INTO EXCHANGE_ACCOUNT_FEES (ACCOUNT_ID, MARKET_TYPE, VIP_TIER, MAKER_FEE, TAKER_FEE, USER_MODIFY) VALUES(?,?,?,?,?,?)
+		ON DUPLICATE KEY UPDATE VIP_TIER = VALUES(VIP_TIER), MAKER_FEE = VALUES(MAKER_FEE), TAKER_FEE = VALUES(TAKER_FEE), USER_MODIFY = VALUES(USER_MODIFY)`,
+		*fee.AccountID, fee.MarketType, fee.VIPTier, fee.MakerFee, fee.TakerFee, userModifyID,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert account fee: %w", err)
+	}
+	return nil
+}
+
+// DeleteAccountFee удаляет переопределение аккаунта (после этого действует ставка биржи).
+func (r *FeeRepository) DeleteAccountFee(accountID int, market string) error {
+	if _, err := db.DB.Exec(`DELETE FROM EXCHANGE_ACCOUNT_FEES WHERE ACCOUNT_ID = ? AND MARKET_TYPE = ?`, accountID, market); err != nil {
+		return fmt.Errorf("delete account fee: %w", err)
+	}
+	return nil
+}
+
+// FindAccountExchangeID возвращает биржу аккаунта без проверки владельца (для админки).
+func (r *FeeRepository) FindAccountExchangeID(accountID int) (int, error) {
+	var exchangeID int
+	err := db.DB.QueryRow(`SELECT EXID FROM EXCHANGE_ACCOUNTS WHERE ID = ? AND DELETED = 0`, accountID).Scan(&exchangeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("exchange account with ID %d not found", accountID)
+		}
+		return 0, fmt.Errorf("find account exchange: %w", err)
+	}
+	return exchangeID, nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fiatRateUpsertBatch - сколько курсов сохраняется одним INSERT.
+const fiatRateUpsertBatch = 500
+
+// FiatRateRepository - дневные курсы активов к фиатным валютам (таблица FIAT_RATES).
+type FiatRateRepository struct{}
+
+// NewFiatRateRepository создаёт новый экземпляр FiatRateRepository.
+func NewFiatRateRepository() *FiatRateRepository {
+	return &FiatRateRepository{}
+}
+
+// UpsertBatch сохраняет курсы; курс на уже загруженную дату заменяется.
+// Возвращает количество сохранённых курсов.
+func (r *FiatRateRepository) UpsertBatch(rates []*models.FiatRate) (int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer db.RollbackTransaction(tx)
+
+	for start := 0; start < len(rates); start += fiatRateUpsertBatch {
+		end := start + fiatRateUpsertBatch
+		if end > len(rates) {
+			end = len(rates)
+		}
+		chunk := rates[start:end]
+
+		placeholders := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*4)
+		for _, rate := range chunk {
+			placeholders = append(placeholders, "(?,?,?,?)")
+			args = append(args, rate.Asset, rate.Currency, rate.Date.Format("2006-01-02"), rate.Rate)
+		}
+		query := `INSERT INTO FIAT_RATES (ASSET, CURRENCY, RATE_DATE, RATE)
+			VALUES ` + strings.Join(placeholders, ",") + `
+			ON DUPLICATE KEY UPDATE RATE = VALUES(RATE)`
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("upsert fiat rates: %w", err)
+		}
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// FindSeries возвращает загруженные пары актив/валюта с диапазоном дат.
+func (r *FiatRateRepository) FindSeries() ([]*models.FiatRateSeries, error) {
+	rows, err := db.DB.Query(`SELECT ASSET, CURRENCY, COUNT(*), MIN(RATE_DATE), MAX(RATE_DATE)
+		FROM FIAT_RATES GROUP BY ASSET, CURRENCY ORDER BY CURRENCY, ASSET`)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.FiatRateSeries, 0)
+	for rows.Next() {
+		var item models.FiatRateSeries
+		if err := rows.Scan(&item.Asset, &item.Currency, &item.Count, &item.From, &item.To); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return result, nil
+}
+
+// FindCurrencies возвращает фиатные валюты, для которых загружены курсы.
+func (r *FiatRateRepository) FindCurrencies() ([]string, error) {
+	rows, err := db.DB.Query(`SELECT DISTINCT CURRENCY FROM FIAT_RATES ORDER BY CURRENCY`)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		result = append(result, currency)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return result, nil
+}
+
+// FindRates возвращает курсы пары актив/валюта с датой не позже to, по возрастанию даты.
+func (r *FiatRateRepository) FindRates(asset, currency string, to time.Time) ([]*models.FiatRate, error) {
+	rows, err := db.DB.Query(`SELECT ASSET, CURRENCY, RATE_DATE, CAST(RATE AS DOUBLE) FROM FIAT_RATES
+		WHERE ASSET = ? AND CURRENCY = ? AND RATE_DATE <= ?
+		ORDER BY RATE_DATE`, asset, currency, to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.FiatRate, 0)
+	for rows.Next() {
+		var item models.FiatRate
+		if err := rows.Scan(&item.Asset, &item.Currency, &item.Date, &item.Rate); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteSeries удаляет все курсы пары актив/валюта.
+func (r *FiatRateRepository) DeleteSeries(asset, currency string) (int64, error) {
+	result, err := db.DB.Exec(`DELETE FROM FIAT_RATES WHERE ASSET = ? AND CURRENCY = ?`, asset, currency)
+	if err != nil {
+		return 0, fmt.Errorf("delete fiat rates: %w", err)
+	}
+	return db.GetRowsAffected(result)
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fundingUpsertBatch - сколько строк вставляется одним INSERT при загрузке истории.
+const fundingUpsertBatch = 500
+
+// FundingRepository - репозиторий истории ставок финансирования (таблица FUNDING_RATES).
+type FundingRepository struct{}
+
+// NewFundingRepository создаёт новый экземпляр FundingRepository.
+func NewFundingRepository() *FundingRepository {
+	return &FundingRepository{}
+}
+
+const fundingColumns = `EXID, SYMBOL, FUNDING_TIME, CAST(RATE AS DOUBLE), CAST(MARK_PRICE AS DOUBLE), SOURCE`
+
+func scanFundingRate(scanner interface{ Scan(...interface{}) error }) (*models.FundingRate, error) {
+	var item models.FundingRate
+	var markPrice sql.NullFloat64
+	if err := scanner.Scan(
+		&item.ExID,
+		&item.Symbol,
+		&item.FundingTime,
+		&item.Rate,
+		&markPrice,
+		&item.Source,
+	); err != nil {
+		return nil, err
+	}
+	if markPrice.Valid {
+		item.MarkPrice = &markPrice.Float64
+	}
+	return &item, nil
+}
+
+// UpsertBatch сохраняет ставки биржи. Повторная загрузка того же расчёта
+// перезаписывает ставку. Возвращает количество сохранённых записей.
+func (r *FundingRepository) UpsertBatch(exchangeID int, items []*models.FundingRate) (int, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer db.RollbackTransaction(tx)
+
+	for start := 0; start < len(items); start += fundingUpsertBatch {
+		end := start + fundingUpsertBatch
+		if end > len(items) {
+			end = len(items)
+		}
+		chunk := items[start:end]
+
+		placeholders := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*6)
+		for _, item := range chunk {
+			placeholders = append(placeholders, "(?,?,?,?,?,?)")
+			args = append(args,
+				exchangeID,
+				item.Symbol,
+				item.FundingTime.UTC().Format("2006-01-02 15:04:05"),
+				item.Rate,
+				item.MarkPrice,
+				item.Source,
+			)
+		}
+
+		query := `INSERT INTO FUNDING_RATES (EXID, SYMBOL, FUNDING_TIME, RATE, MARK_PRICE, SOURCE)
+			VALUES ` + strings.Join(placeholders, ",") + `
+			ON DUPLICATE KEY UPDATE
+				RATE = VALUES(RATE),
+				MARK_PRICE = COALESCE(VALUES(MARK_PRICE), MARK_PRICE),
+				SOURCE = VALUES(SOURCE),
+				DATE_SYNC = CURRENT_TIMESTAMP`
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("upsert funding rates: %w", err)
+		}
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// FindRange возвращает ставки контракта за период [from, to] по возрастанию времени.
+func (r *FundingRepository) FindRange(exchangeID int, symbol string, from, to time.Time) ([]*models.FundingRate, error) {
+	query := `SELECT ` + fundingColumns + `
+		FROM FUNDING_RATES
+		WHERE EXID = ? AND SYMBOL = ? AND FUNDING_TIME BETWEEN ? AND ?
+		ORDER BY FUNDING_TIME`
+
+	rows, err := db.DB.Query(query,
+		exchangeID,
+		symbol,
+		from.UTC().Format("2006-01-02 15:04:05"),
+		to.UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find funding rates: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.FundingRate, 0)
+	for rows.Next() {
+		item, err := scanFundingRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan funding rate row: %w", err)
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate funding rate rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindLatest возвращает последние count ставок контракта (от новых к старым).
+func (r *FundingRepository) FindLatest(exchangeID int, symbol string, count int) ([]*models.FundingRate, error) {
+	query := `SELECT ` + fundingColumns + `
+		FROM FUNDING_RATES
+		WHERE EXID = ? AND SYMBOL = ?
+		ORDER BY FUNDING_TIME DESC
+		LIMIT ?`
+
+	rows, err := db.DB.Query(query, exchangeID, symbol, count)
+	if err != nil {
+		return nil, fmt.Errorf("find latest funding rates: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.FundingRate, 0, count)
+	for rows.Next() {
+		item, err := scanFundingRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan funding rate row: %w", err)
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate funding rate rows: %w", err)
+	}
+	return result, nil
+}
+
+// LastFundingTime возвращает время последней сохранённой ставки контракта (nil - истории нет).
+func (r *FundingRepository) LastFundingTime(exchangeID int, symbol string) (*time.Time, error) {
+	var last sql.NullTime
+	query := `SELECT MAX(FUNDING_TIME) FROM FUNDING_RATES WHERE EXID = ? AND SYMBOL = ?`
+	if err := db.DB.QueryRow(query, exchangeID, symbol).Scan(&last); err != nil {
+		return nil, fmt.Errorf("get last funding time: %w", err)
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
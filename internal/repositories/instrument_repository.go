@@ -0,0 +1,345 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// instrumentUpsertBatch - сколько строк вставляется одним INSERT при синхронизации.
+const instrumentUpsertBatch = 500
+
+// InstrumentRepository - репозиторий справочника инструментов (таблица INSTRUMENTS).
+type InstrumentRepository struct{}
+
+// NewInstrumentRepository создаёт новый экземпляр InstrumentRepository.
+func NewInstrumentRepository() *InstrumentRepository {
+	return &InstrumentRepository{}
+}
+
+const instrumentColumns = `ID, EXID, SYMBOL, MARKET_TYPE, BASE_ASSET, QUOTE_ASSET, TICK_SIZE, LOT_SIZE, MIN_QTY, CONTRACT_VALUE, STATUS, DATE_SYNC`
+
+func scanInstrument(scanner interface{ Scan(...interface{}) error }) (*models.Instrument, error) {
+	var item models.Instrument
+	if err := scanner.Scan(
+		&item.ID,
+		&item.ExID,
+		&item.Symbol,
+		&item.MarketType,
+		&item.BaseAsset,
+		&item.QuoteAsset,
+		&item.TickSize,
+		&item.LotSize,
+		&item.MinQty,
+		&item.ContractValue,
+		&item.Status,
+		&item.DateSync,
+	); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpsertBatch сохраняет инструменты биржи одного рынка.
+//
+// Существующие записи обновляются, а инструменты, которых нет в новом списке,
+// помечаются как DELISTED (удалять их нельзя - на них могут ссылаться позиции).
+// Возвращает количество сохранённых инструментов.
+func (r *InstrumentRepository) UpsertBatch(exchangeID int, market string, items []*models.Instrument, syncedAt time.Time) (int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer db.RollbackTransaction(tx)
+
+	syncTime := syncedAt.UTC().Format("2006-01-02 15:04:05")
+	for start := 0; start < len(items); start += instrumentUpsertBatch {
+		end := start + instrumentUpsertBatch
+		if end > len(items) {
+			end = len(items)
+		}
+		chunk := items[start:end]
+
+		placeholders := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*11)
+		for _, item := range chunk {
+			placeholders = append(placeholders, "(?,?,?,?,?,?,?,?,?,?,?)")
+			args = append(args,
+				exchangeID,
+				item.Symbol,
+				market,
+				item.BaseAsset,
+				item.QuoteAsset,
+				item.TickSize,
+				item.LotSize,
+				item.MinQty,
+				item.ContractValue,
+				item.Status,
+				syncTime,
+			)
+		}
+
+		query := `INSERT INTO INSTRUMENTS
+			(EXID, SYMBOL, MARKET_TYPE, BASE_ASSET, QUOTE_ASSET, TICK_SIZE, LOT_SIZE, MIN_QTY, CONTRACT_VALUE, STATUS, DATE_SYNC)
+			VALUES ` + strings.Join(placeholders, ",") + `
+			ON DUPLICATE KEY UPDATE
+				BASE_ASSET = VALUES(BASE_ASSET),
+				QUOTE_ASSET = VALUES(QUOTE_ASSET),
+				TICK_SIZE = VALUES(TICK_SIZE),
+				LOT_SIZE = VALUES(LOT_SIZE),
+				MIN_QTY = VALUES(MIN_QTY),
+				CONTRACT_VALUE = VALUES(CONTRACT_VALUE),
+				STATUS = VALUES(STATUS),
+				DATE_SYNC = VALUES(DATE_SYNC)`
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("upsert instruments: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE INSTRUMENTS SET STATUS = 'DELISTED' WHERE EXID = ? AND MARKET_TYPE = ? AND DATE_SYNC < ?`,
+		exchangeID, market, syncTime,
+	); err != nil {
+		return 0, fmt.Errorf("mark delisted instruments: %w", err)
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// Search ищет инструменты биржи по началу символа или базовой валюте (для автодополнения).
+// Пустой market означает поиск по всем рынкам.
+func (r *InstrumentRepository) Search(exchangeID int, market, term string, limit int) ([]*models.Instrument, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	conditions := []string{"EXID = ?", "STATUS <> 'DELISTED'"}
+	args := []interface{}{exchangeID}
+	if market != "" {
+		conditions = append(conditions, "MARKET_TYPE = ?")
+		args = append(args, market)
+	}
+	term = strings.TrimSpace(term)
+	if term != "" {
+		like := escapeLike(strings.ToUpper(term)) + "%"
+		conditions = append(conditions, "(SYMBOL LIKE ? OR BASE_ASSET LIKE ?)")
+		args = append(args, like, like)
+	}
+	args = append(args, limit)
+
+	query := `SELECT ` + instrumentColumns + ` FROM INSTRUMENTS
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY STATUS = 'TRADING' DESC, SYMBOL ASC
+		LIMIT ?`
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search instruments: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.Instrument, 0, limit)
+	for rows.Next() {
+		item, err := scanInstrument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan instrument: %w", err)
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate instruments rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindBySymbol находит инструмент по точному символу (без учёта регистра).
+// Возвращает nil, nil если инструмент не найден.
+func (r *InstrumentRepository) FindBySymbol(exchangeID int, market, symbol string) (*models.Instrument, error) {
+	query := `SELECT ` + instrumentColumns + ` FROM INSTRUMENTS
+		WHERE EXID = ? AND MARKET_TYPE = ? AND SYMBOL = ?
+		LIMIT 1`
+	item, err := scanInstrument(db.DB.QueryRow(query, exchangeID, market, strings.ToUpper(strings.TrimSpace(symbol))))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find instrument: %w", err)
+	}
+	return item, nil
+}
+
+// FindByAssets находит торгуемый инструмент биржи по базовой и котируемой валюте.
+// Возвращает nil, nil если такой пары нет.
+func (r *InstrumentRepository) FindByAssets(exchangeID int, market, base, quote string) (*models.Instrument, error) {
+	query := `SELECT ` + instrumentColumns + ` FROM INSTRUMENTS
+		WHERE EXID = ? AND MARKET_TYPE = ? AND BASE_ASSET = ? AND QUOTE_ASSET = ? AND STATUS = 'TRADING'
+		ORDER BY SYMBOL ASC
+		LIMIT 1`
+	item, err := scanInstrument(db.DB.QueryRow(query, exchangeID, market,
+		strings.ToUpper(strings.TrimSpace(base)), strings.ToUpper(strings.TrimSpace(quote))))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find instrument: %w", err)
+	}
+	return item, nil
+}
+
+// CountByExchangeMarket возвращает количество инструментов биржи на рынке.
+func (r *InstrumentRepository) CountByExchangeMarket(exchangeID int, market string) (int, error) {
+	var count int
+	if err := db.DB.QueryRow(
+		`SELECT COUNT(*) FROM INSTRUMENTS WHERE EXID = ? AND MARKET_TYPE = ?`,
+		exchangeID, market,
+	).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count instruments: %w", err)
+	}
+	return count, nil
+}
+
+// SharedPairsExchange - биржа, у которой есть общие торгуемые пары с выбранной биржей.
+type SharedPairsExchange struct {
+	ID    int
+	Name  string
+	Pairs int
+}
+
+// CommonPair - пара BASE/QUOTE, торгуемая на двух биржах, с символами каждой из них.
+type CommonPair struct {
+	Base    string
+	Quote   string
+	Symbol1 string
+	Symbol2 string
+}
+
+// FindExchangesSharingPairs возвращает биржи, у которых есть торгуемые пары (по базовой
+// и котируемой валюте) общие с биржей exchangeID на рынке market.
+func (r *InstrumentRepository) FindExchangesSharingPairs(exchangeID int, market string) ([]*SharedPairsExchange, error) {
+	query := `SELECT e.ID, e.NAME, COUNT(DISTINCT b.BASE_ASSET, b.QUOTE_ASSET) AS PAIRS
+		FROM INSTRUMENTS a
+		JOIN INSTRUMENTS b ON b.BASE_ASSET = a.BASE_ASSET AND b.QUOTE_ASSET = a.QUOTE_ASSET
+			AND b.MARKET_TYPE = a.MARKET_TYPE AND b.EXID <> a.EXID AND b.STATUS = 'TRADING'
+		JOIN EXCHANGE e ON e.ID = b.EXID
+		WHERE a.EXID = ? AND a.MARKET_TYPE = ? AND a.STATUS = 'TRADING'
+		GROUP BY e.ID, e.NAME
+		ORDER BY e.NAME ASC`
+	rows, err := db.DB.Query(query, exchangeID, market)
+	if err != nil {
+		return nil, fmt.Errorf("find exchanges sharing pairs: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*SharedPairsExchange, 0)
+	for rows.Next() {
+		var item SharedPairsExchange
+		if err := rows.Scan(&item.ID, &item.Name, &item.Pairs); err != nil {
+			return nil, fmt.Errorf("scan exchange: %w", err)
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate exchanges rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindCommonPairs возвращает пары, торгуемые на обеих биржах на рынке market.
+func (r *InstrumentRepository) FindCommonPairs(exchangeID1, exchangeID2 int, market string) ([]*CommonPair, error) {
+	query := `SELECT a.BASE_ASSET, a.QUOTE_ASSET, MIN(a.SYMBOL), MIN(b.SYMBOL)
+		FROM INSTRUMENTS a
+		JOIN INSTRUMENTS b ON b.BASE_ASSET = a.BASE_ASSET AND b.QUOTE_ASSET = a.QUOTE_ASSET
+			AND b.MARKET_TYPE = a.MARKET_TYPE AND b.EXID = ? AND b.STATUS = 'TRADING'
+		WHERE a.EXID = ? AND a.MARKET_TYPE = ? AND a.STATUS = 'TRADING'
+		GROUP BY a.BASE_ASSET, a.QUOTE_ASSET
+		ORDER BY a.BASE_ASSET ASC, a.QUOTE_ASSET ASC`
+	rows, err := db.DB.Query(query, exchangeID2, exchangeID1, market)
+	if err != nil {
+		return nil, fmt.Errorf("find common pairs: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*CommonPair, 0)
+	for rows.Next() {
+		var item CommonPair
+		if err := rows.Scan(&item.Base, &item.Quote, &item.Symbol1, &item.Symbol2); err != nil {
+			return nil, fmt.Errorf("scan common pair: %w", err)
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate common pairs rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindPairsOnMultipleExchanges возвращает пары BASE/QUOTE рынка market, которые
+// торгуются хотя бы на двух биржах.
+func (r *InstrumentRepository) FindPairsOnMultipleExchanges(market string) ([]string, error) {
+	query := `SELECT BASE_ASSET, QUOTE_ASSET
+		FROM INSTRUMENTS
+		WHERE MARKET_TYPE = ? AND STATUS = 'TRADING'
+		GROUP BY BASE_ASSET, QUOTE_ASSET
+		HAVING COUNT(DISTINCT EXID) > 1
+		ORDER BY BASE_ASSET ASC, QUOTE_ASSET ASC`
+	rows, err := db.DB.Query(query, market)
+	if err != nil {
+		return nil, fmt.Errorf("find shared pairs: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var base, quote string
+		if err := rows.Scan(&base, &quote); err != nil {
+			return nil, fmt.Errorf("scan pair: %w", err)
+		}
+		result = append(result, base+"/"+quote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pairs rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindListings возвращает торгуемые инструменты пары BASE/QUOTE на всех биржах (по одному на биржу).
+func (r *InstrumentRepository) FindListings(market, base, quote string) ([]*models.Instrument, error) {
+	query := `SELECT ` + instrumentColumns + ` FROM INSTRUMENTS
+		WHERE MARKET_TYPE = ? AND BASE_ASSET = ? AND QUOTE_ASSET = ? AND STATUS = 'TRADING'
+		ORDER BY EXID ASC, SYMBOL ASC`
+	rows, err := db.DB.Query(query, market,
+		strings.ToUpper(strings.TrimSpace(base)), strings.ToUpper(strings.TrimSpace(quote)))
+	if err != nil {
+		return nil, fmt.Errorf("find listings: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.Instrument, 0)
+	seen := make(map[int]bool)
+	for rows.Next() {
+		item, err := scanInstrument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan instrument: %w", err)
+		}
+		if seen[item.ExID] {
+			continue
+		}
+		seen[item.ExID] = true
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate instruments rows: %w", err)
+	}
+	return result, nil
+}
+
+// escapeLike экранирует спецсимволы LIKE в пользовательском вводе.
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPositionsNotGroupable - часть позиций не найдена, закрыта или уже входит в другую группу.
+var ErrPositionsNotGroupable = errors.New("positions are not found, closed or already grouped")
+
+// PositionGroupRepository - группы позиций (POS_GROUPS) и привязка ног (POS_POSITIONS.GROUP_ID).
+type PositionGroupRepository struct{}
+
+// NewPositionGroupRepository создаёт новый экземпляр PositionGroupRepository.
+func NewPositionGroupRepository() *PositionGroupRepository {
+	return &PositionGroupRepository{}
+}
+
+// PositionLeg - новая позиция-нога, создаваемая вместе с группой.
+type PositionLeg struct {
+	Name       string
+	ExchangeID int
+	AccountID  *int
+	Market     string
+}
+
+const positionGroupSelect = `SELECT ID, USER_ID, NAME, STRATEGY, CAST(ENTRY_DIFF AS DOUBLE),
+		CASE WHEN STATUS = 1 THEN 'OPEN' ELSE 'CLOSE' END, CREATED, CLOSED
+	FROM POS_GROUPS`
+
+func scanPositionGroup(scanner interface{ Scan(...interface{}) error }) (*models.PositionGroup, error) {
+	var g models.PositionGroup
+	var entryDiff sql.NullFloat64
+	var closed sql.NullTime
+	if err := scanner.Scan(&g.ID, &g.UserID, &g.Name, &g.Strategy, &entryDiff, &g.Status, &g.Created, &closed); err != nil {
+		return nil, err
+	}
+	if entryDiff.Valid {
+		g.EntryDiff = &entryDiff.Float64
+	}
+	if closed.Valid {
+		g.Closed = &closed.Time
+	}
+	return &g, nil
+}
+
+func insertPositionGroup(tx *sql.Tx, group *models.PositionGroup) (int, error) {
+	res, err := tx.Exec(`INSERT INTO POS_GROUPS (USER_ID, NAME, STRATEGY, ENTRY_DIFF, STATUS, CREATED) VALUES (?, ?, ?, ?, 1, ?)`,
+		group.UserID, group.Name, group.Strategy, group.EntryDiff, group.Created.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, fmt.Errorf("create position group: %w", err)
+	}
+	id, err := db.GetLastInsertID(res)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return int(id), nil
+}
+
+// CreateWithPositions в одной транзакции создаёт группу и позиции её ног.
+// Возвращает ID группы и ID позиций в порядке legs.
+func (r *PositionGroupRepository) CreateWithPositions(group *models.PositionGroup, legs []PositionLeg) (int, []int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	groupID, err := insertPositionGroup(tx, group)
+	if err != nil {
+		return 0, nil, err
+	}
+	positionIDs := make([]int, 0, len(legs))
+	for _, leg := range legs {
+		res, err := tx.Exec(`INSERT INTO POS_POSITIONS (NAME, EXID, ACCOUNT_ID, GROUP_ID, CREATED, MARKET_TYPE, USER_ID) VALUES(?,?,?,?,?,?,?)`,
+			leg.Name, leg.ExchangeID, leg.AccountID, groupID, group.Created.UTC().Format("2006-01-02 15:04:05"), leg.Market, group.UserID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("create position: %w", err)
+		}
+		id, err := db.GetLastInsertID(res)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		positionIDs = append(positionIDs, int(id))
+	}
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, nil, err
+	}
+	return groupID, positionIDs, nil
+}
+
+// Create создаёт группу из существующих открытых позиций пользователя, не входящих в группы.
+// Если хотя бы одну позицию привязать нельзя, возвращает ErrPositionsNotGroupable.
+func (r *PositionGroupRepository) Create(group *models.PositionGroup, positionIDs []int) (int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	groupID, err := insertPositionGroup(tx, group)
+	if err != nil {
+		return 0, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(positionIDs)), ",")
+	args := make([]interface{}, 0, len(positionIDs)+2)
+	args = append(args, groupID, group.UserID)
+	for _, id := range positionIDs {
+		args = append(args, id)
+	}
+	res, err := tx.Exec(`UPDATE POS_POSITIONS SET GROUP_ID = ?
+		WHERE USER_ID = ? AND STATUS = 1 AND GROUP_ID IS NULL AND ID IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("link positions to group: %w", err)
+	}
+	affected, err := db.GetRowsAffected(res)
+	if err != nil {
+		return 0, err
+	}
+	if int(affected) != len(positionIDs) {
+		return 0, ErrPositionsNotGroupable
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, err
+	}
+	return groupID, nil
+}
+
+// FindByUser возвращает группы пользователя: открытые первыми, затем новые.
+func (r *PositionGroupRepository) FindByUser(userID int) ([]*models.PositionGroup, error) {
+	rows, err := db.DB.Query(positionGroupSelect+` WHERE USER_ID = ? ORDER BY STATUS DESC, CREATED DESC, ID DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("find position groups: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.PositionGroup, 0)
+	for rows.Next() {
+		g, err := scanPositionGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan position group: %w", err)
+		}
+		result = append(result, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate position groups rows: %w", err)
+	}
+	return result, nil
+}
+
+// FindByID возвращает группу пользователя (nil - не найдена или чужая).
+func (r *PositionGroupRepository) FindByID(id, userID int) (*models.PositionGroup, error) {
+	g, err := scanPositionGroup(db.DB.QueryRow(positionGroupSelect+` WHERE ID = ? AND USER_ID = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find position group: %w", err)
+	}
+	return g, nil
+}
+
+// Close в одной транзакции закрывает открытые ноги группы и саму группу.
+func (r *PositionGroupRepository) Close(id, userID int, closedUTC time.Time) error {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	closed := closedUTC.UTC().Format("2006-01-02 15:04:05")
+	if _, err := tx.Exec(`UPDATE POS_POSITIONS SET STATUS = 0, CLOSED = ? WHERE USER_ID = ? AND GROUP_ID = ? AND STATUS = 1`,
+		closed, userID, id); err != nil {
+		return fmt.Errorf("close group positions: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE POS_GROUPS SET STATUS = 0, CLOSED = ? WHERE USER_ID = ? AND ID = ?`,
+		closed, userID, id); err != nil {
+		return fmt.Errorf("close position group: %w", err)
+	}
+	return db.CommitTransaction(tx)
+}
+
+// Delete расформировывает группу: позиции остаются, но больше не связаны.
+func (r *PositionGroupRepository) Delete(id, userID int) (bool, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE POS_POSITIONS SET GROUP_ID = NULL WHERE USER_ID = ? AND GROUP_ID = ?`, userID, id); err != nil {
+		return false, fmt.Errorf("unlink group positions: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM POS_GROUPS WHERE USER_ID = ? AND ID = ?`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete position group: %w", err)
+	}
+	affected, err := db.GetRowsAffected(res)
+	if err != nil {
+		return false, err
+	}
+	if err := db.CommitTransaction(tx); err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
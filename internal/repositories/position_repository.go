@@ -5,6 +5,7 @@ import (
 	"ctweb/internal/models"
 	"database/sql"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 )
@@ -15,16 +16,71 @@ func NewPositionRepository() *PositionRepository {
 	return &PositionRepository{}
 }
 
-func (r *PositionRepository) CountPositionsByUser(userID int) (int, error) {
-	query := `SELECT COUNT(*) AS count FROM POS_POSITIONS WHERE USER_ID = ?`
+// positionFilterClause возвращает условие фильтра списка позиций для таблицы POS_POSITIONS p.
+func positionFilterClause(filter models.PositionFilter) (string, []interface{}) {
+	clause := ""
+	args := make([]interface{}, 0, 3)
+	if filter.PositionID > 0 {
+		clause += ` AND p.ID = ?`
+		args = append(args, filter.PositionID)
+	}
+	switch {
+	case filter.AccountID > 0:
+		clause += ` AND p.ACCOUNT_ID = ?`
+		args = append(args, filter.AccountID)
+	case filter.AccountID == models.PositionFilterNoAccount:
+		clause += ` AND p.ACCOUNT_ID IS NULL`
+	}
+	switch {
+	case filter.GroupID > 0:
+		clause += ` AND p.GROUP_ID = ?`
+		args = append(args, filter.GroupID)
+	case filter.Grouped:
+		clause += ` AND p.GROUP_ID IS NOT NULL`
+	}
+	switch filter.Status {
+	case "OPEN":
+		clause += ` AND p.STATUS = 1`
+	case "CLOSE":
+		clause += ` AND p.STATUS = 0`
+	}
+	if !filter.TradedBefore.IsZero() {
+		clause += ` AND EXISTS (SELECT 1 FROM POS_TRANSACTIONS pt WHERE pt.POSITION_ID = p.ID AND pt.TRANS_DATE < ?)`
+		args = append(args, filter.TradedBefore.UTC())
+	}
+	return clause, args
+}
+
+func (r *PositionRepository) CountPositionsByUser(userID int, filter models.PositionFilter) (int, error) {
+	clause, args := positionFilterClause(filter)
+	query := `SELECT COUNT(*) AS count FROM POS_POSITIONS p WHERE p.USER_ID = ?` + clause
 	var count int
-	if err := db.DB.QueryRow(query, userID).Scan(&count); err != nil {
+	if err := db.DB.QueryRow(query, append([]interface{}{userID}, args...)...).Scan(&count); err != nil {
 		return 0, fmt.Errorf("count positions: %w", err)
 	}
 	return count, nil
 }
 
-func (r *PositionRepository) GetPositions(userID, limit, offset int) ([]*models.PositionSummary, error) {
+func (r *PositionRepository) GetPositions(userID int, filter models.PositionFilter, limit, offset int) ([]*models.PositionSummary, error) {
+	result := make([]*models.PositionSummary, 0)
+	err := r.eachPosition(userID, filter, limit, offset, func(item *models.PositionSummary) error {
+		result = append(result, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EachPosition передаёт fn позиции пользователя по фильтру (в порядке списка) по одной,
+// не собирая их в память. Ошибка fn прерывает обход и возвращается как есть.
+func (r *PositionRepository) EachPosition(userID int, filter models.PositionFilter, fn func(*models.PositionSummary) error) error {
+	return r.eachPosition(userID, filter, math.MaxInt64, 0, fn)
+}
+
+func (r *PositionRepository) eachPosition(userID int, filter models.PositionFilter, limit, offset int, fn func(*models.PositionSummary) error) error {
+	clause, filterArgs := positionFilterClause(filter)
 	query := `WITH RECURSIVE
 			ordered AS (
 				SELECT
@@ -145,6 +201,11 @@ func (r *PositionRepository) GetPositions(userID, limit, offset int) ([]*models.
 				p.ID AS POSITION_ID,
 				p.NAME AS CONTRACT_NAME,
 				e.NAME AS EXCHANGE_NAME,
+				p.EXID,
+				p.ACCOUNT_ID,
+				a.ACCOUNT_NAME,
+				p.GROUP_ID,
+				g.NAME AS GROUP_NAME,
 				p.MARKET_TYPE,
 				CASE
 					WHEN p.STATUS = 1
@@ -165,22 +226,31 @@ func (r *PositionRepository) GetPositions(userID, limit, offset int) ([]*models.
 				last_calc lc ON lc.POSITION_ID = p.ID
 			LEFT JOIN
 				EXCHANGE e   ON e.ID = p.EXID
+			LEFT JOIN
+				EXCHANGE_ACCOUNTS a ON a.ID = p.ACCOUNT_ID AND a.UID = p.USER_ID
+			LEFT JOIN
+				POS_GROUPS g ON g.ID = p.GROUP_ID AND g.USER_ID = p.USER_ID
 			WHERE
-				p.USER_ID = ?
+				p.USER_ID = ?` + clause + `
 			ORDER BY
 				(p.STATUS='OPEN') ASC,
 				p.CREATED DESC
 			LIMIT ? OFFSET ?`
 
-	rows, err := db.DB.Query(query, userID, userID, limit, offset)
+	args := append([]interface{}{userID, userID}, filterArgs...)
+	args = append(args, limit, offset)
+	rows, err := db.DB.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("get positions: %w", err)
+		return fmt.Errorf("get positions: %w", err)
 	}
 	defer rows.Close()
 
-	result := make([]*models.PositionSummary, 0)
 	for rows.Next() {
 		var item models.PositionSummary
+		var accountID sql.NullInt64
+		var accountName sql.NullString
+		var groupID sql.NullInt64
+		var groupName sql.NullString
 		var created sql.NullTime
 		var closed sql.NullTime
 		var finalPos sql.NullFloat64
@@ -194,6 +264,11 @@ func (r *PositionRepository) GetPositions(userID, limit, offset int) ([]*models.
 			&item.PositionID,
 			&item.ContractName,
 			&item.ExchangeName,
+			&item.ExchangeID,
+			&accountID,
+			&accountName,
+			&groupID,
+			&groupName,
 			&item.MarketType,
 			&item.Status,
 			&created,
@@ -206,9 +281,19 @@ func (r *PositionRepository) GetPositions(userID, limit, offset int) ([]*models.
 			&realized,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("scan positions row: %w", err)
+			return fmt.Errorf("scan positions row: %w", err)
 		}
 
+		if accountID.Valid {
+			id := int(accountID.Int64)
+			item.AccountID = &id
+			item.AccountName = accountName.String
+		}
+		if groupID.Valid {
+			id := int(groupID.Int64)
+			item.GroupID = &id
+			item.GroupName = groupName.String
+		}
 		if created.Valid {
 			item.Created = &created.Time
 		}
@@ -234,19 +319,21 @@ func (r *PositionRepository) GetPositions(userID, limit, offset int) ([]*models.
 			item.TotalRealizedPnL = &realized.Float64
 		}
 
-		result = append(result, &item)
+		if err := fn(&item); err != nil {
+			return err
+		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate positions rows: %w", err)
+		return fmt.Errorf("iterate positions rows: %w", err)
 	}
 
-	return result, nil
+	return nil
 }
 
-func (r *PositionRepository) CreatePosition(name string, exchangeID int, createdUTC time.Time, market string, userID int) error {
-	query := `INSERT INTO POS_POSITIONS (NAME, EXID, CREATED, MARKET_TYPE, USER_ID) VALUES(?,?,?,?,?)`
-	res, err := db.DB.Exec(query, name, exchangeID, createdUTC.Format("2006-01-02 15:04:05"), market, userID)
+func (r *PositionRepository) CreatePosition(name string, exchangeID int, accountID *int, createdUTC time.Time, market string, userID int) error {
+	query := `INSERT INTO POS_POSITIONS (NAME, EXID, ACCOUNT_ID, CREATED, MARKET_TYPE, USER_ID) VALUES(?,?,?,?,?,?)`
+	res, err := db.DB.Exec(query, name, exchangeID, accountID, createdUTC.Format("2006-01-02 15:04:05"), market, userID)
 	if err != nil {
 		return fmt.Errorf("create position: %w", err)
 	}
@@ -262,9 +349,9 @@ func (r *PositionRepository) CreatePosition(name string, exchangeID int, created
 	return nil
 }
 
-func (r *PositionRepository) EditPosition(positionID, userID int, name string, exchangeID int, createdUTC time.Time) (bool, error) {
-	query := `UPDATE POS_POSITIONS SET NAME = ?, EXID = ?, CREATED = ? WHERE USER_ID = ? AND ID = ?`
-	res, err := db.DB.Exec(query, name, exchangeID, createdUTC.Format("2006-01-02 15:04:05"), userID, positionID)
+func (r *PositionRepository) EditPosition(positionID, userID int, name string, exchangeID int, accountID *int, createdUTC time.Time) (bool, error) {
+	query := `UPDATE POS_POSITIONS SET NAME = ?, EXID = ?, ACCOUNT_ID = ?, CREATED = ? WHERE USER_ID = ? AND ID = ?`
+	res, err := db.DB.Exec(query, name, exchangeID, accountID, createdUTC.Format("2006-01-02 15:04:05"), userID, positionID)
 	if err != nil {
 		return false, fmt.Errorf("edit position: %w", err)
 	}
@@ -399,6 +486,9 @@ func (r *PositionRepository) GetPositionByID(userID, positionID int) (*models.Po
 				p.ID AS POSITION_ID,
 				p.NAME AS CONTRACT_NAME,
 				e.NAME AS EXCHANGE_NAME,
+				p.EXID,
+				p.ACCOUNT_ID,
+				a.ACCOUNT_NAME,
 				p.MARKET_TYPE,
 				CASE
 					WHEN p.STATUS = 1
@@ -420,6 +510,8 @@ func (r *PositionRepository) GetPositionByID(userID, positionID int) (*models.Po
 				last_calc lc ON lc.POSITION_ID = p.ID
 			LEFT JOIN
 				EXCHANGE e   ON e.ID = p.EXID
+			LEFT JOIN
+				EXCHANGE_ACCOUNTS a ON a.ID = p.ACCOUNT_ID AND a.UID = p.USER_ID
 			WHERE
 				p.USER_ID = ?
 				AND p.ID= ?
@@ -428,6 +520,8 @@ func (r *PositionRepository) GetPositionByID(userID, positionID int) (*models.Po
 				p.CREATED DESC`
 
 	var item models.PositionDetail
+	var accountID sql.NullInt64
+	var accountName sql.NullString
 	var created sql.NullTime
 	var closed sql.NullTime
 	var finalPos sql.NullFloat64
@@ -442,6 +536,9 @@ func (r *PositionRepository) GetPositionByID(userID, positionID int) (*models.Po
 		&item.PositionID,
 		&item.ContractName,
 		&item.ExchangeName,
+		&item.ExchangeID,
+		&accountID,
+		&accountName,
 		&item.MarketType,
 		&item.Status,
 		&created,
@@ -461,6 +558,11 @@ func (r *PositionRepository) GetPositionByID(userID, positionID int) (*models.Po
 		return nil, fmt.Errorf("get position by id: %w", err)
 	}
 
+	if accountID.Valid {
+		id := int(accountID.Int64)
+		item.AccountID = &id
+		item.AccountName = accountName.String
+	}
 	if created.Valid {
 		item.Created = &created.Time
 	}
@@ -545,25 +647,11 @@ func (r *PositionRepository) GetTransactionsByPosition(positionID, userID, limit
 
 	result := make([]*models.PositionTransaction, 0)
 	for rows.Next() {
-		var item models.PositionTransaction
-		var transDate sql.NullTime
-		err = rows.Scan(
-			&item.ID,
-			&item.Type,
-			&item.Price,
-			&item.Volume,
-			&item.FeeBase,
-			&item.Fee,
-			&item.Funding,
-			&transDate,
-		)
+		item, err := scanPositionTransaction(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scan transactions row: %w", err)
-		}
-		if transDate.Valid {
-			item.TransDate = &transDate.Time
+			return nil, err
 		}
-		result = append(result, &item)
+		result = append(result, item)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -573,6 +661,69 @@ func (r *PositionRepository) GetTransactionsByPosition(positionID, userID, limit
 	return result, nil
 }
 
+// EachTransaction передаёт fn операции позиции пользователя в порядке исполнения по одной,
+// не собирая их в память. Ошибка fn прерывает обход и возвращается как есть.
+func (r *PositionRepository) EachTransaction(positionID, userID int, fn func(*models.PositionTransaction) error) error {
+	rows, err := db.DB.Query(`SELECT
+				t.ID,
+				t.OP_TYPE AS TYPE,
+				CAST(t.PRICE AS DOUBLE) AS PRICE,
+				CAST(t.VOLUME AS DOUBLE) AS VOLUME,
+				CAST(t.FEE_BASE AS DOUBLE) AS FEE_BASE,
+				CAST(t.FEE AS DOUBLE) AS FEE,
+				CAST(t.FUNDING_AMOUNT AS DOUBLE) AS FUNDING,
+				t.TRANS_DATE
+			FROM
+				POS_TRANSACTIONS t
+			JOIN
+				POS_POSITIONS pp ON pp.ID = t.POSITION_ID
+			WHERE
+				t.POSITION_ID = ?
+				AND pp.USER_ID = ?
+			ORDER BY
+				t.TRANS_DATE ASC,
+				t.ID ASC`, positionID, userID)
+	if err != nil {
+		return fmt.Errorf("get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanPositionTransaction(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate transactions rows: %w", err)
+	}
+	return nil
+}
+
+func scanPositionTransaction(rows *sql.Rows) (*models.PositionTransaction, error) {
+	var item models.PositionTransaction
+	var transDate sql.NullTime
+	if err := rows.Scan(
+		&item.ID,
+		&item.Type,
+		&item.Price,
+		&item.Volume,
+		&item.FeeBase,
+		&item.Fee,
+		&item.Funding,
+		&transDate,
+	); err != nil {
+		return nil, fmt.Errorf("scan transactions row: %w", err)
+	}
+	if transDate.Valid {
+		item.TransDate = &transDate.Time
+	}
+	return &item, nil
+}
+
 func (r *PositionRepository) GetPositionMarketType(positionID, userID int) (string, error) {
 	query := `SELECT MARKET_TYPE FROM POS_POSITIONS WHERE ID = ? AND USER_ID = ?`
 	var marketType string
@@ -585,6 +736,182 @@ func (r *PositionRepository) GetPositionMarketType(positionID, userID int) (stri
 	return marketType, nil
 }
 
+func (r *PositionRepository) GetPositionExchangeAndMarket(positionID, userID int) (int, string, error) {
+	query := `SELECT EXID, MARKET_TYPE FROM POS_POSITIONS WHERE ID = ? AND USER_ID = ?`
+	var exchangeID int
+	var marketType string
+	if err := db.DB.QueryRow(query, positionID, userID).Scan(&exchangeID, &marketType); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, "", nil
+		}
+		return 0, "", fmt.Errorf("get position exchange: %w", err)
+	}
+	return exchangeID, marketType, nil
+}
+
+// GetPositionAccountID возвращает аккаунт биржи позиции пользователя; nil - позиция не привязана.
+func (r *PositionRepository) GetPositionAccountID(positionID, userID int) (*int, error) {
+	var accountID sql.NullInt64
+	err := db.DB.QueryRow(`SELECT ACCOUNT_ID FROM POS_POSITIONS WHERE ID = ? AND USER_ID = ?`, positionID, userID).Scan(&accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get position account: %w", err)
+	}
+	if !accountID.Valid {
+		return nil, nil
+	}
+	id := int(accountID.Int64)
+	return &id, nil
+}
+
+// GetOpenPositionUsers возвращает ID пользователей, у которых есть открытые позиции.
+func (r *PositionRepository) GetOpenPositionUsers() ([]int, error) {
+	rows, err := db.DB.Query(`SELECT DISTINCT USER_ID FROM POS_POSITIONS WHERE STATUS = 1 ORDER BY USER_ID`)
+	if err != nil {
+		return nil, fmt.Errorf("get open position users: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]int, 0)
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("scan open position user: %w", err)
+		}
+		result = append(result, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate open position users: %w", err)
+	}
+	return result, nil
+}
+
+// GetPeriodTotals возвращает операции позиций пользователя с TRANS_DATE в [fromUTC, toUTC):
+// число сделок, сумму комиссий и funding по каждой позиции с операциями.
+func (r *PositionRepository) GetPeriodTotals(userID int, fromUTC, toUTC time.Time) (map[int]*models.PositionPeriodTotals, error) {
+	rows, err := db.DB.Query(`SELECT t.POSITION_ID,
+				SUM(CASE WHEN t.OP_TYPE = 'TRADE' THEN 1 ELSE 0 END),
+				CAST(COALESCE(SUM(t.FEE), 0) AS DOUBLE),
+				CAST(COALESCE(SUM(t.FUNDING_AMOUNT), 0) AS DOUBLE)
+			FROM POS_TRANSACTIONS t
+			JOIN POS_POSITIONS p ON p.ID = t.POSITION_ID
+			WHERE p.USER_ID = ? AND t.TRANS_DATE >= ? AND t.TRANS_DATE < ?
+			GROUP BY t.POSITION_ID`, userID, fromUTC, toUTC)
+	if err != nil {
+		return nil, fmt.Errorf("get period totals: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int]*models.PositionPeriodTotals)
+	for rows.Next() {
+		var item models.PositionPeriodTotals
+		if err := rows.Scan(&item.PositionID, &item.Trades, &item.Fee, &item.Funding); err != nil {
+			return nil, fmt.Errorf("scan period totals: %w", err)
+		}
+		result[item.PositionID] = &item
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate period totals: %w", err)
+	}
+	return result, nil
+}
+
+// GetTaxTransactions возвращает операции всех позиций пользователя с TRANS_DATE раньше toUTC
+// в порядке исполнения (для сопоставления лотов FIFO нужна вся история до конца года).
+func (r *PositionRepository) GetTaxTransactions(userID int, toUTC time.Time) ([]*models.TaxTransaction, error) {
+	rows, err := db.DB.Query(`SELECT t.ID, p.ID, p.NAME, p.EXID, COALESCE(e.NAME, ''), p.MARKET_TYPE, t.OP_TYPE,
+				CAST(COALESCE(t.PRICE, 0) AS DOUBLE),
+				CAST(COALESCE(t.VOLUME, 0) AS DOUBLE),
+				CAST(COALESCE(t.FEE, 0) AS DOUBLE),
+				CAST(COALESCE(t.FEE_BASE, 0) AS DOUBLE),
+				CAST(COALESCE(t.FUNDING_AMOUNT, 0) AS DOUBLE),
+				t.TRANS_DATE
+			FROM POS_TRANSACTIONS t
+			JOIN POS_POSITIONS p ON p.ID = t.POSITION_ID
+			LEFT JOIN EXCHANGE e ON e.ID = p.EXID
+			WHERE p.USER_ID = ? AND t.TRANS_DATE < ?
+			ORDER BY t.TRANS_DATE, t.ID`, userID, toUTC)
+	if err != nil {
+		return nil, fmt.Errorf("get tax transactions: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.TaxTransaction, 0)
+	for rows.Next() {
+		var item models.TaxTransaction
+		if err := rows.Scan(&item.ID, &item.PositionID, &item.ContractName, &item.ExchangeID, &item.ExchangeName,
+			&item.MarketType, &item.OpType, &item.Price, &item.Volume, &item.Fee, &item.FeeBase, &item.Funding,
+			&item.TransDate); err != nil {
+			return nil, fmt.Errorf("scan tax transaction: %w", err)
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tax transactions: %w", err)
+	}
+	return result, nil
+}
+
+// CountOpenPositionsByAccount возвращает количество открытых позиций пользователя по
+// привязанным аккаунтам бирж (ACCOUNT_ID -> количество).
+func (r *PositionRepository) CountOpenPositionsByAccount(userID int) (map[int]int, error) {
+	rows, err := db.DB.Query(`SELECT ACCOUNT_ID, COUNT(*)
+			FROM POS_POSITIONS
+			WHERE USER_ID = ? AND STATUS = 1 AND ACCOUNT_ID IS NOT NULL
+			GROUP BY ACCOUNT_ID`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("count open positions by account: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int]int)
+	for rows.Next() {
+		var accountID, count int
+		if err := rows.Scan(&accountID, &count); err != nil {
+			return nil, fmt.Errorf("scan open positions by account: %w", err)
+		}
+		result[accountID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate open positions by account: %w", err)
+	}
+	return result, nil
+}
+
+// GetOpenContracts возвращает контракты открытых позиций всех пользователей
+// с датой открытия самой ранней из них.
+func (r *PositionRepository) GetOpenContracts() ([]*models.ContractRef, error) {
+	query := `SELECT EXID, MARKET_TYPE, UPPER(NAME), MIN(CREATED)
+			FROM POS_POSITIONS
+			WHERE STATUS = 1
+			GROUP BY EXID, MARKET_TYPE, UPPER(NAME)`
+
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("get open contracts: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.ContractRef, 0)
+	for rows.Next() {
+		var item models.ContractRef
+		var since sql.NullTime
+		if err := rows.Scan(&item.ExID, &item.MarketType, &item.Symbol, &since); err != nil {
+			return nil, fmt.Errorf("scan open contract row: %w", err)
+		}
+		if since.Valid {
+			item.Since = since.Time
+		}
+		result = append(result, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate open contract rows: %w", err)
+	}
+	return result, nil
+}
+
 func (r *PositionRepository) InsertFundingTransaction(positionID int, funding float64, transDateUTC time.Time) error {
 	query := `INSERT INTO POS_TRANSACTIONS (POSITION_ID, FUNDING_AMOUNT, TRANS_DATE, OP_TYPE) VALUES(?,?,?,?)`
 	_, err := db.DB.Exec(query, positionID, funding, transDateUTC.Format("2006-01-02 15:04:05"), "FUNDING")
@@ -964,3 +1291,17 @@ func (r *PositionRepository) DeleteTransactionsByIDs(userID, positionID int, tra
 
 	return affected, nil
 }
+
+// GetPositionGroupID возвращает группу позиции (nil - позиция не входит в группу).
+func (r *PositionRepository) GetPositionGroupID(positionID, userID int) (*int, error) {
+	var groupID sql.NullInt64
+	err := db.DB.QueryRow(`SELECT GROUP_ID FROM POS_POSITIONS WHERE USER_ID = ? AND ID = ?`, userID, positionID).Scan(&groupID)
+	if err != nil {
+		return nil, fmt.Errorf("get position group: %w", err)
+	}
+	if !groupID.Valid {
+		return nil, nil
+	}
+	id := int(groupID.Int64)
+	return &id, nil
+}
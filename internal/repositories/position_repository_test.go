@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"ctweb/internal/models"
+	"fmt"
+	"testing"
+)
+
+func TestPositionFilterClause(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter models.PositionFilter
+		clause string
+		args   []interface{}
+	}{
+		{"all positions", models.PositionFilter{}, "", nil},
+		{"account", models.PositionFilter{AccountID: 3}, " AND p.ACCOUNT_ID = ?", []interface{}{3}},
+		{"not linked", models.PositionFilter{AccountID: models.PositionFilterNoAccount}, " AND p.ACCOUNT_ID IS NULL", nil},
+		{"open not linked", models.PositionFilter{AccountID: models.PositionFilterNoAccount, Status: "OPEN"},
+			" AND p.ACCOUNT_ID IS NULL AND p.STATUS = 1", nil},
+	}
+	for _, tc := range cases {
+		clause, args := positionFilterClause(tc.filter)
+		if clause != tc.clause || fmt.Sprint(args) != fmt.Sprint(tc.args) {
+			t.Errorf("%s: got %q %v, want %q %v", tc.name, clause, args, tc.clause, tc.args)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReconciliationRepository - последняя сверка позиций пользователя с биржей (POS_RECONCILIATION).
+type ReconciliationRepository struct{}
+
+// NewReconciliationRepository создаёт новый экземпляр ReconciliationRepository.
+func NewReconciliationRepository() *ReconciliationRepository {
+	return &ReconciliationRepository{}
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitIDs(value string) []int {
+	ids := make([]int, 0)
+	for _, part := range strings.Split(value, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ReplaceReport заменяет сохранённую сверку пользователя новой.
+func (r *ReconciliationRepository) ReplaceReport(report *models.ReconciliationReport) error {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer db.RollbackTransaction(tx)
+
+	if _, err := tx.Exec(`DELETE FROM POS_RECONCILIATION WHERE UID = ?`, report.UID); err != nil {
+		return fmt.Errorf("delete reconciliation: %w", err)
+	}
+
+	if len(report.Items) > 0 {
+		stmt, err := tx.Prepare(`INSERT INTO POS_RECONCILIATION
+			(UID, RUN_AT, EXID, ACCOUNT_ID, MARKET_TYPE, SYMBOL, ASSET, POSITION_IDS,
+			 CALC_SIZE, LIVE_SIZE, CALC_PRICE, LIVE_PRICE, STATUS, MESSAGE)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return fmt.Errorf("prepare reconciliation insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, item := range report.Items {
+			var asset, message interface{}
+			if item.Asset != "" {
+				asset = item.Asset
+			}
+			if item.Message != "" {
+				text := item.Message
+				if runes := []rune(text); len(runes) > 255 {
+					text = string(runes[:255])
+				}
+				message = text
+			}
+			positionIDs := joinIDs(item.PositionIDs)
+			if len(positionIDs) > 255 {
+				positionIDs = positionIDs[:strings.LastIndex(positionIDs[:256], ",")]
+			}
+			if _, err := stmt.Exec(
+				report.UID,
+				report.RunAt,
+				item.ExID,
+				item.AccountID,
+				item.MarketType,
+				item.Symbol,
+				asset,
+				positionIDs,
+				item.CalcSize,
+				item.LiveSize,
+				item.CalcPrice,
+				item.LivePrice,
+				item.Status,
+				message,
+			); err != nil {
+				return fmt.Errorf("insert reconciliation item: %w", err)
+			}
+		}
+	}
+
+	if err := db.CommitTransaction(tx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// FindReport возвращает последнюю сохранённую сверку пользователя (nil - сверок не было).
+func (r *ReconciliationRepository) FindReport(userID int) (*models.ReconciliationReport, error) {
+	rows, err := db.DB.Query(`SELECT
+			r.RUN_AT, r.EXID, COALESCE(e.NAME, ''), r.ACCOUNT_ID, COALESCE(a.ACCOUNT_NAME, ''), r.MARKET_TYPE, r.SYMBOL, r.ASSET, r.POSITION_IDS,
+			r.CALC_SIZE, r.LIVE_SIZE, r.CALC_PRICE, r.LIVE_PRICE, r.STATUS, r.MESSAGE
+		FROM POS_RECONCILIATION r
+		LEFT JOIN EXCHANGE e ON e.ID = r.EXID
+		LEFT JOIN EXCHANGE_ACCOUNTS a ON a.ID = r.ACCOUNT_ID AND a.UID = r.UID
+		WHERE r.UID = ?
+		ORDER BY r.ID`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var report *models.ReconciliationReport
+	for rows.Next() {
+		var item models.ReconciliationItem
+		var runAt sql.NullTime
+		var accountID sql.NullInt64
+		var asset, message sql.NullString
+		var positionIDs string
+		var liveSize, calcPrice, livePrice sql.NullFloat64
+		if err := rows.Scan(
+			&runAt,
+			&item.ExID,
+			&item.ExchangeName,
+			&accountID,
+			&item.AccountName,
+			&item.MarketType,
+			&item.Symbol,
+			&asset,
+			&positionIDs,
+			&item.CalcSize,
+			&liveSize,
+			&calcPrice,
+			&livePrice,
+			&item.Status,
+			&message,
+		); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if report == nil {
+			report = &models.ReconciliationReport{UID: userID, RunAt: runAt.Time}
+		}
+		if accountID.Valid {
+			id := int(accountID.Int64)
+			item.AccountID = &id
+		}
+		item.Asset = asset.String
+		item.Message = message.String
+		item.PositionIDs = splitIDs(positionIDs)
+		if liveSize.Valid {
+			item.LiveSize = &liveSize.Float64
+		}
+		if calcPrice.Valid {
+			item.CalcPrice = &calcPrice.Float64
+		}
+		if livePrice.Valid {
+			item.LivePrice = &livePrice.Float64
+		}
+		if item.IsMismatch() {
+			report.Mismatches++
+		}
+		report.Items = append(report.Items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return report, nil
+}
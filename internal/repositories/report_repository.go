@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReportRepository - подписки на PnL-отчёты (REPORT_SUBSCRIPTIONS) и сформированные
+// отчёты (PNL_REPORTS).
+type ReportRepository struct{}
+
+// NewReportRepository создаёт новый экземпляр ReportRepository.
+func NewReportRepository() *ReportRepository {
+	return &ReportRepository{}
+}
+
+// FindSubscription возвращает подписку пользователя (nil, если не настраивалась).
+func (r *ReportRepository) FindSubscription(userID int) (*models.ReportSubscription, error) {
+	var sub models.ReportSubscription
+	err := db.DB.QueryRow(`SELECT UID, DAILY, MONTHLY, EMAIL FROM REPORT_SUBSCRIPTIONS WHERE UID = ?`, userID).
+		Scan(&sub.UID, &sub.Daily, &sub.Monthly, &sub.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &sub, nil
+}
+
+// FindActiveSubscriptions возвращает подписки хотя бы на один период.
+func (r *ReportRepository) FindActiveSubscriptions() ([]*models.ReportSubscription, error) {
+	rows, err := db.DB.Query(`SELECT UID, DAILY, MONTHLY, EMAIL FROM REPORT_SUBSCRIPTIONS
+		WHERE DAILY = 1 OR MONTHLY = 1 ORDER BY UID`)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.ReportSubscription, 0)
+	for rows.Next() {
+		var sub models.ReportSubscription
+		if err := rows.Scan(&sub.UID, &sub.Daily, &sub.Monthly, &sub.Email); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		result = append(result, &sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return result, nil
+}
+
+// SaveSubscription создаёт или обновляет подписку пользователя.
+func (r *ReportRepository) SaveSubscription(sub *models.ReportSubscription) error {
+	if _, err := db.DB.Exec(`INSERT INTO REPORT_SUBSCRIPTIONS (UID, DAILY, MONTHLY, EMAIL, DATE_UPDATE)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE DAILY = VALUES(DAILY), MONTHLY = VALUES(MONTHLY), EMAIL = VALUES(EMAIL), DATE_UPDATE = VALUES(DATE_UPDATE)`,
+		sub.UID, sub.Daily, sub.Monthly, sub.Email, time.Now().UTC()); err != nil {
+		return fmt.Errorf("save report subscription: %w", err)
+	}
+	return nil
+}
+
+// Exists сообщает, сформирован ли уже отчёт пользователя за период.
+func (r *ReportRepository) Exists(userID int, period string, start time.Time) (bool, error) {
+	var count int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM PNL_REPORTS WHERE UID = ? AND PERIOD = ? AND PERIOD_START = ?`,
+		userID, period, start.Format("2006-01-02")).Scan(&count); err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Save сохраняет отчёт; отчёт за тот же период заменяется вместе с отметкой об отправке.
+// Возвращает ID отчёта.
+func (r *ReportRepository) Save(report *models.PnLReport) (int, error) {
+	result, err := db.DB.Exec(`INSERT INTO PNL_REPORTS
+		(UID, PERIOD, PERIOD_START, PERIOD_END, TIMEZONE, REALIZED_PNL, HTML, CSV, DATE_CREATE)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE ID = LAST_INSERT_ID(ID), PERIOD_END = VALUES(PERIOD_END), TIMEZONE = VALUES(TIMEZONE),
+			REALIZED_PNL = VALUES(REALIZED_PNL), HTML = VALUES(HTML), CSV = VALUES(CSV), DATE_CREATE = VALUES(DATE_CREATE),
+			EMAIL = '', DATE_EMAILED = NULL, EMAIL_ERROR = ''`,
+		report.UID, report.Period, report.PeriodStart.Format("2006-01-02"), report.PeriodEnd.Format("2006-01-02"),
+		report.Timezone, report.RealizedPnL, report.HTML, report.CSV, report.DateCreate)
+	if err != nil {
+		return 0, fmt.Errorf("save pnl report: %w", err)
+	}
+	id, err := db.GetLastInsertID(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return int(id), nil
+}
+
+const reportListColumns = `ID, UID, PERIOD, PERIOD_START, PERIOD_END, TIMEZONE, REALIZED_PNL, DATE_CREATE, EMAIL, DATE_EMAILED, EMAIL_ERROR`
+
+func scanReport(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.PnLReport, error) {
+	var report models.PnLReport
+	var emailed sql.NullTime
+	dest := []interface{}{&report.ID, &report.UID, &report.Period, &report.PeriodStart, &report.PeriodEnd, &report.Timezone,
+		&report.RealizedPnL, &report.DateCreate, &report.Email, &emailed, &report.EmailError}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if emailed.Valid {
+		report.DateEmailed = &emailed.Time
+	}
+	return &report, nil
+}
+
+// FindByUser возвращает последние limit отчётов пользователя без содержимого.
+func (r *ReportRepository) FindByUser(userID, limit int) ([]*models.PnLReport, error) {
+	rows, err := db.DB.Query(`SELECT `+reportListColumns+` FROM PNL_REPORTS
+		WHERE UID = ? ORDER BY PERIOD_START DESC, PERIOD DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]*models.PnLReport, 0)
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		result = append(result, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return result, nil
+}
+
+// FindByID возвращает отчёт пользователя с содержимым (nil, если не найден).
+func (r *ReportRepository) FindByID(id, userID int) (*models.PnLReport, error) {
+	var htmlBody, csvBody string
+	report, err := scanReport(db.DB.QueryRow(`SELECT `+reportListColumns+`, HTML, CSV FROM PNL_REPORTS
+		WHERE ID = ? AND UID = ?`, id, userID), &htmlBody, &csvBody)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	report.HTML = htmlBody
+	report.CSV = csvBody
+	return report, nil
+}
+
+// SetEmailed запоминает результат отправки отчёта. emailedAt == nil - отправка не удалась.
+func (r *ReportRepository) SetEmailed(id int, email string, emailedAt *time.Time, emailError string) error {
+	if len(emailError) > 255 {
+		emailError = emailError[:255]
+	}
+	if _, err := db.DB.Exec(`UPDATE PNL_REPORTS SET EMAIL = ?, DATE_EMAILED = ?, EMAIL_ERROR = ? WHERE ID = ?`,
+		email, emailedAt, emailError, id); err != nil {
+		return fmt.Errorf("update pnl report: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет отчёт пользователя.
+func (r *ReportRepository) Delete(id, userID int) (bool, error) {
+	result, err := db.DB.Exec(`DELETE FROM PNL_REPORTS WHERE ID = ? AND UID = ?`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete pnl report: %w", err)
+	}
+	affected, err := db.GetRowsAffected(result)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"context"
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpreadRepository - замеры межбиржевого спреда в БД котировок (таблица SPREADS).
+// Работает с MySQL или ClickHouse в зависимости от databases.quotes.engine.
+type SpreadRepository struct{}
+
+// NewSpreadRepository создаёт новый экземпляр SpreadRepository.
+func NewSpreadRepository() *SpreadRepository {
+	return &SpreadRepository{}
+}
+
+// Available сообщает, подключена ли БД котировок.
+func (r *SpreadRepository) Available() bool {
+	return db.QuotesEngine() != ""
+}
+
+// spreadRow - строка SPREADS в формате JSON ClickHouse.
+type spreadRow struct {
+	WatchID    int     `json:"WATCH_ID"`
+	SampleTime string  `json:"SAMPLE_TIME"`
+	Bid1       float64 `json:"BID1"`
+	Ask1       float64 `json:"ASK1"`
+	Bid2       float64 `json:"BID2"`
+	Ask2       float64 `json:"ASK2"`
+	BuyOn      int     `json:"BUY_ON"`
+	Gross      float64 `json:"GROSS"`
+	Fees       float64 `json:"FEES"`
+	Net        float64 `json:"NET"`
+}
+
+// InsertBatch сохраняет замеры одного прохода задачи сбора спредов.
+func (r *SpreadRepository) InsertBatch(ctx context.Context, items []*models.SpreadSample) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	switch db.QuotesEngine() {
+	case db.QuotesEngineClickHouse:
+		rows := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			rows = append(rows, spreadRow{
+				WatchID:    item.WatchID,
+				SampleTime: db.ClickHouseTime(item.SampleTime),
+				Bid1:       item.Bid1,
+				Ask1:       item.Ask1,
+				Bid2:       item.Bid2,
+				Ask2:       item.Ask2,
+				BuyOn:      item.BuyOn,
+				Gross:      item.Gross,
+				Fees:       item.Fees,
+				Net:        item.Net,
+			})
+		}
+		if err := db.QuotesClickHouse.InsertJSONEachRow(ctx, "SPREADS", rows); err != nil {
+			return fmt.Errorf("insert spreads: %w", err)
+		}
+		return nil
+
+	case db.QuotesEngineMySQL:
+		placeholders := make([]string, 0, len(items))
+		args := make([]interface{}, 0, len(items)*10)
+		for _, item := range items {
+			placeholders = append(placeholders, "(?,?,?,?,?,?,?,?,?,?)")
+			args = append(args,
+				item.WatchID,
+				item.SampleTime.UTC().Format("2006-01-02 15:04:05"),
+				item.Bid1,
+				item.Ask1,
+				item.Bid2,
+				item.Ask2,
+				item.BuyOn,
+				item.Gross,
+				item.Fees,
+				item.Net,
+			)
+		}
+		// Повторный замер в ту же секунду перезаписывает предыдущий.
+		query := `INSERT INTO SPREADS
+			(WATCH_ID, SAMPLE_TIME, BID1, ASK1, BID2, ASK2, BUY_ON, GROSS, FEES, NET)
+			VALUES ` + strings.Join(placeholders, ",") + `
+			ON DUPLICATE KEY UPDATE
+				BID1 = VALUES(BID1), ASK1 = VALUES(ASK1), BID2 = VALUES(BID2), ASK2 = VALUES(ASK2),
+				BUY_ON = VALUES(BUY_ON), GROSS = VALUES(GROSS), FEES = VALUES(FEES), NET = VALUES(NET)`
+		if _, err := db.Quotes.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("insert spreads: %w", err)
+		}
+		return nil
+
+	default:
+		return db.ErrQuotesNotConfigured
+	}
+}
+
+// FindRange возвращает замеры наблюдения за период [from, to] по возрастанию времени, не больше limit.
+func (r *SpreadRepository) FindRange(ctx context.Context, watchID int, from, to time.Time, limit int) ([]*models.SpreadSample, error) {
+	switch db.QuotesEngine() {
+	case db.QuotesEngineClickHouse:
+		query := `SELECT WATCH_ID, toString(SAMPLE_TIME) AS SAMPLE_TIME, BID1, ASK1, BID2, ASK2, BUY_ON, GROSS, FEES, NET
+			FROM SPREADS
+			WHERE WATCH_ID = {watch:Int32}
+				AND SAMPLE_TIME BETWEEN {from:DateTime('UTC')} AND {to:DateTime('UTC')}
+			ORDER BY SAMPLE_TIME
+			LIMIT {limit:UInt32}`
+		var rows []spreadRow
+		if err := db.QuotesClickHouse.Query(ctx, query, map[string]string{
+			"watch": strconv.Itoa(watchID),
+			"from":  db.ClickHouseTime(from),
+			"to":    db.ClickHouseTime(to),
+			"limit": strconv.Itoa(limit),
+		}, &rows); err != nil {
+			return nil, fmt.Errorf("find spreads: %w", err)
+		}
+
+		result := make([]*models.SpreadSample, 0, len(rows))
+		for _, row := range rows {
+			sampleTime, err := time.ParseInLocation(db.ClickHouseTimeFormat, row.SampleTime, time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("parse spread time: %w", err)
+			}
+			result = append(result, &models.SpreadSample{
+				WatchID:    row.WatchID,
+				SampleTime: sampleTime,
+				Bid1:       row.Bid1,
+				Ask1:       row.Ask1,
+				Bid2:       row.Bid2,
+				Ask2:       row.Ask2,
+				BuyOn:      row.BuyOn,
+				Gross:      row.Gross,
+				Fees:       row.Fees,
+				Net:        row.Net,
+			})
+		}
+		return result, nil
+
+	case db.QuotesEngineMySQL:
+		query := `SELECT WATCH_ID, SAMPLE_TIME, CAST(BID1 AS DOUBLE), CAST(ASK1 AS DOUBLE),
+				CAST(BID2 AS DOUBLE), CAST(ASK2 AS DOUBLE), BUY_ON, GROSS, FEES, NET
+			FROM SPREADS
+			WHERE WATCH_ID = ? AND SAMPLE_TIME BETWEEN ? AND ?
+			ORDER BY SAMPLE_TIME
+			LIMIT ?`
+		rows, err := db.Quotes.QueryContext(ctx, query,
+			watchID,
+			from.UTC().Format("2006-01-02 15:04:05"),
+			to.UTC().Format("2006-01-02 15:04:05"),
+			limit,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("find spreads: %w", err)
+		}
+		defer rows.Close()
+
+		result := make([]*models.SpreadSample, 0)
+		for rows.Next() {
+			var item models.SpreadSample
+			if err := rows.Scan(
+				&item.WatchID,
+				&item.SampleTime,
+				&item.Bid1,
+				&item.Ask1,
+				&item.Bid2,
+				&item.Ask2,
+				&item.BuyOn,
+				&item.Gross,
+				&item.Fees,
+				&item.Net,
+			); err != nil {
+				return nil, fmt.Errorf("scan spread row: %w", err)
+			}
+			result = append(result, &item)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate spread rows: %w", err)
+		}
+		return result, nil
+
+	default:
+		return nil, db.ErrQuotesNotConfigured
+	}
+}
+
+// DeleteBefore удаляет замеры старше before. В ClickHouse старые замеры удаляет TTL таблицы.
+func (r *SpreadRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	switch db.QuotesEngine() {
+	case db.QuotesEngineClickHouse:
+		return 0, nil
+	case db.QuotesEngineMySQL:
+		result, err := db.Quotes.ExecContext(ctx, `DELETE FROM SPREADS WHERE SAMPLE_TIME < ?`,
+			before.UTC().Format("2006-01-02 15:04:05"))
+		if err != nil {
+			return 0, fmt.Errorf("delete old spreads: %w", err)
+		}
+		return db.GetRowsAffected(result)
+	default:
+		return 0, db.ErrQuotesNotConfigured
+	}
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SpreadWatchRepository - наблюдения за межбиржевым спредом (SPREAD_WATCHES).
+type SpreadWatchRepository struct{}
+
+// NewSpreadWatchRepository создаёт новый экземпляр SpreadWatchRepository.
+func NewSpreadWatchRepository() *SpreadWatchRepository {
+	return &SpreadWatchRepository{}
+}
+
+// spreadWatchSelect - выборка наблюдений с названиями бирж, которую читает scanSpreadWatch.
+const spreadWatchSelect = `SELECT w.ID, w.UID, w.EXID1, w.EXID2, COALESCE(e1.NAME, ''), COALESCE(e2.NAME, ''),
+		w.MARKET_TYPE, w.BASE_ASSET, w.QUOTE_ASSET, w.SYMBOL1, w.SYMBOL2, w.IS_ACTIVE,
+		w.DATE_CREATE, w.DATE_LAST_SAMPLE, w.LAST_ERROR
+	FROM SPREAD_WATCHES w
+	LEFT JOIN EXCHANGE e1 ON e1.ID = w.EXID1
+	LEFT JOIN EXCHANGE e2 ON e2.ID = w.EXID2`
+
+func scanSpreadWatch(scanner interface{ Scan(...interface{}) error }) (*models.SpreadWatch, error) {
+	var w models.SpreadWatch
+	var lastSample sql.NullTime
+	if err := scanner.Scan(&w.ID, &w.UID, &w.ExID1, &w.ExID2, &w.Exchange1, &w.Exchange2,
+		&w.MarketType, &w.BaseAsset, &w.QuoteAsset, &w.Symbol1, &w.Symbol2, &w.IsActive,
+		&w.DateCreate, &lastSample, &w.LastError); err != nil {
+		return nil, err
+	}
+	if lastSample.Valid {
+		w.DateLastSample = &lastSample.Time
+	}
+	return &w, nil
+}
+
+func (r *SpreadWatchRepository) query(query string, args ...interface{}) ([]*models.SpreadWatch, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	watches := make([]*models.SpreadWatch, 0)
+	for rows.Next() {
+		w, err := scanSpreadWatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		watches = append(watches, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return watches, nil
+}
+
+// FindAllByUser возвращает наблюдения пользователя.
+func (r *SpreadWatchRepository) FindAllByUser(userID int) ([]*models.SpreadWatch, error) {
+	return r.query(spreadWatchSelect+` WHERE w.UID = ? ORDER BY w.ID ASC`, userID)
+}
+
+// FindActive возвращает активные наблюдения всех пользователей (для задачи сбора спредов).
+func (r *SpreadWatchRepository) FindActive() ([]*models.SpreadWatch, error) {
+	return r.query(spreadWatchSelect + ` WHERE w.IS_ACTIVE = 1 ORDER BY w.ID ASC`)
+}
+
+// FindByID возвращает наблюдение пользователя (nil - не найдено или чужое).
+func (r *SpreadWatchRepository) FindByID(id, userID int) (*models.SpreadWatch, error) {
+	w, err := scanSpreadWatch(db.DB.QueryRow(spreadWatchSelect+` WHERE w.ID = ? AND w.UID = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return w, nil
+}
+
+// CountByUser возвращает количество наблюдений пользователя.
+func (r *SpreadWatchRepository) CountByUser(userID int) (int, error) {
+	var count int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM SPREAD_WATCHES WHERE UID = ?`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	return count, nil
+}
+
+// Exists проверяет, есть ли у пользователя наблюдение за той же парой на тех же биржах.
+func (r *SpreadWatchRepository) Exists(w *models.SpreadWatch) (bool, error) {
+	var count int
+	err := db.DB.QueryRow(`SELECT COUNT(*) FROM SPREAD_WATCHES
+		WHERE UID = ? AND EXID1 = ? AND EXID2 = ? AND MARKET_TYPE = ? AND BASE_ASSET = ? AND QUOTE_ASSET = ?`,
+		w.UID, w.ExID1, w.ExID2, w.MarketType, w.BaseAsset, w.QuoteAsset).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create сохраняет наблюдение и возвращает его ID.
+func (r *SpreadWatchRepository) Create(w *models.SpreadWatch) (int, error) {
+	result, err := db.DB.Exec(`INSERT INTO SPREAD_WATCHES
+		(UID, EXID1, EXID2, MARKET_TYPE, BASE_ASSET, QUOTE_ASSET, SYMBOL1, SYMBOL2, IS_ACTIVE)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.UID, w.ExID1, w.ExID2, w.MarketType, w.BaseAsset, w.QuoteAsset, w.Symbol1, w.Symbol2, w.IsActive)
+	if err != nil {
+		return 0, fmt.Errorf("insert spread watch: %w", err)
+	}
+	id, err := db.GetLastInsertID(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return int(id), nil
+}
+
+// SetActive включает или приостанавливает наблюдение пользователя.
+func (r *SpreadWatchRepository) SetActive(id, userID int, active bool) error {
+	if _, err := db.DB.Exec(`UPDATE SPREAD_WATCHES SET IS_ACTIVE = ? WHERE ID = ? AND UID = ?`, active, id, userID); err != nil {
+		return fmt.Errorf("update spread watch: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет наблюдение пользователя. Замеры в БД котировок удаляются по сроку хранения.
+func (r *SpreadWatchRepository) Delete(id, userID int) (bool, error) {
+	result, err := db.DB.Exec(`DELETE FROM SPREAD_WATCHES WHERE ID = ? AND UID = ?`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete spread watch: %w", err)
+	}
+	affected, err := db.GetRowsAffected(result)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetSampleResult запоминает время и ошибку последнего замера ("" - успешно).
+func (r *SpreadWatchRepository) SetSampleResult(id int, sampledAt time.Time, lastError string) error {
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+	if _, err := db.DB.Exec(`UPDATE SPREAD_WATCHES SET DATE_LAST_SAMPLE = ?, LAST_ERROR = ? WHERE ID = ?`,
+		sampledAt, lastError, id); err != nil {
+		return fmt.Errorf("update spread watch sample: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TelegramRepository - привязки Telegram-чатов (TELEGRAM_LINKS) и одноразовые коды привязки
+// (TELEGRAM_LINK_CODES).
+type TelegramRepository struct{}
+
+// NewTelegramRepository создаёт новый экземпляр TelegramRepository.
+func NewTelegramRepository() *TelegramRepository {
+	return &TelegramRepository{}
+}
+
+func (r *TelegramRepository) findLink(where string, arg interface{}) (*models.TelegramLink, error) {
+	var link models.TelegramLink
+	err := db.DB.QueryRow(`SELECT UID, CHAT_ID, USERNAME, DATE_LINK FROM TELEGRAM_LINKS WHERE `+where, arg).
+		Scan(&link.UID, &link.ChatID, &link.Username, &link.DateLink)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &link, nil
+}
+
+// FindByUser возвращает привязку пользователя (nil, если чат не привязан).
+func (r *TelegramRepository) FindByUser(userID int) (*models.TelegramLink, error) {
+	return r.findLink(`UID = ?`, userID)
+}
+
+// FindByChat возвращает привязку чата (nil, если чат не привязан).
+func (r *TelegramRepository) FindByChat(chatID int64) (*models.TelegramLink, error) {
+	return r.findLink(`CHAT_ID = ?`, chatID)
+}
+
+// SaveCode заменяет код привязки пользователя новым.
+func (r *TelegramRepository) SaveCode(userID int, codeHash string, expire time.Time) error {
+	if _, err := db.DB.Exec(`REPLACE INTO TELEGRAM_LINK_CODES (CODE_HASH, UID, DATE_EXPIRE) VALUES (?, ?, ?)`,
+		codeHash, userID, expire); err != nil {
+		return fmt.Errorf("save telegram link code: %w", err)
+	}
+	return nil
+}
+
+// RedeemCode гасит действующий код и привязывает чат к его владельцу. Прежняя привязка
+// пользователя и прежний владелец чата отвязываются. Возвращает 0, если код не найден
+// или истёк.
+func (r *TelegramRepository) RedeemCode(codeHash string, chatID int64, username string, now time.Time) (int, error) {
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var expire time.Time
+	err = tx.QueryRow(`SELECT UID, DATE_EXPIRE FROM TELEGRAM_LINK_CODES WHERE CODE_HASH = ? FOR UPDATE`, codeHash).
+		Scan(&userID, &expire)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM TELEGRAM_LINK_CODES WHERE CODE_HASH = ?`, codeHash); err != nil {
+		return 0, fmt.Errorf("delete telegram link code: %w", err)
+	}
+	if !expire.After(now) {
+		// Истёкший код удаляется, но транзакция фиксируется
+		if err := db.CommitTransaction(tx); err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+	if _, err := tx.Exec(`DELETE FROM TELEGRAM_LINKS WHERE UID = ? OR CHAT_ID = ?`, userID, chatID); err != nil {
+		return 0, fmt.Errorf("delete telegram link: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO TELEGRAM_LINKS (UID, CHAT_ID, USERNAME, DATE_LINK) VALUES (?, ?, ?, ?)`,
+		userID, chatID, username, now); err != nil {
+		return 0, fmt.Errorf("insert telegram link: %w", err)
+	}
+	if err := db.CommitTransaction(tx); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// DeleteByUser отвязывает чат пользователя и удаляет выданный код.
+func (r *TelegramRepository) DeleteByUser(userID int) (bool, error) {
+	result, err := db.DB.Exec(`DELETE FROM TELEGRAM_LINKS WHERE UID = ?`, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete telegram link: %w", err)
+	}
+	if _, err := db.DB.Exec(`DELETE FROM TELEGRAM_LINK_CODES WHERE UID = ?`, userID); err != nil {
+		return false, fmt.Errorf("delete telegram link code: %w", err)
+	}
+	affected, err := db.GetRowsAffected(result)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteByChat отвязывает чат (команда /unlink или блокировка бота в чате).
+func (r *TelegramRepository) DeleteByChat(chatID int64) (bool, error) {
+	result, err := db.DB.Exec(`DELETE FROM TELEGRAM_LINKS WHERE CHAT_ID = ?`, chatID)
+	if err != nil {
+		return false, fmt.Errorf("delete telegram link: %w", err)
+	}
+	affected, err := db.GetRowsAffected(result)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,231 @@
+// Package secrets - envelope-шифрование секретов (API-ключей бирж) в БД.
+//
+// Каждое значение шифруется AES-256-GCM на собственном случайном ключе данных.
+// Ключ данных заворачивается мастер-ключом через KeyProvider (локальный keyring,
+// KMS или HSM) и хранится рядом с шифротекстом:
+//
+//	enc:v1:<key id>:<завёрнутый ключ данных>:<nonce + шифротекст>
+//
+// Шифротекст привязан к месту хранения (scope, например колонка и ID записи) через
+// дополнительные данные GCM: значение, скопированное в другую запись, не расшифруется.
+//
+// Значения без префикса считаются открытым текстом (записи до включения шифрования)
+// и возвращаются как есть - их переводит в зашифрованный вид команда cmd/keys.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// valuePrefix - признак зашифрованного значения и версия формата.
+	valuePrefix = "enc:v1:"
+	// dataKeySize - размер ключа данных (AES-256).
+	dataKeySize = 32
+)
+
+var (
+	// ErrNotConfigured - в БД есть зашифрованное значение, но мастер-ключи не настроены.
+	ErrNotConfigured = errors.New("encryption keys are not configured")
+	// ErrMalformed - значение с префиксом enc: не разбирается.
+	ErrMalformed = errors.New("malformed encrypted value")
+	// ErrPrefixedPlaintext - открытый текст начинается с префикса зашифрованного значения.
+	ErrPrefixedPlaintext = errors.New("value must not start with " + valuePrefix)
+)
+
+var encoding = base64.RawURLEncoding
+
+// KeyProvider заворачивает и разворачивает ключи данных мастер-ключом.
+// Реализация для KMS/HSM передаёт ключ данных во внешнюю систему и не знает мастер-ключа.
+type KeyProvider interface {
+	// CurrentKeyID - мастер-ключ, которым заворачиваются новые ключи данных.
+	CurrentKeyID() string
+	// WrapKey заворачивает ключ данных текущим мастер-ключом и возвращает его ID.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey разворачивает ключ данных мастер-ключом keyID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope шифрует и расшифровывает значения. Без провайдера шифрование отключено:
+// Encrypt возвращает значение как есть, а Decrypt не может прочитать зашифрованные значения.
+type Envelope struct {
+	provider KeyProvider
+}
+
+// NewEnvelope создаёт шифратор поверх провайдера мастер-ключей (nil - шифрование отключено).
+func NewEnvelope(provider KeyProvider) *Envelope {
+	return &Envelope{provider: provider}
+}
+
+// Enabled сообщает, настроено ли шифрование.
+func (e *Envelope) Enabled() bool {
+	return e != nil && e.provider != nil
+}
+
+// IsEncrypted сообщает, что значение хранится в зашифрованном виде.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, valuePrefix)
+}
+
+// KeyID возвращает ID мастер-ключа зашифрованного значения ("" - открытый текст).
+func KeyID(value string) string {
+	parts, err := splitValue(value)
+	if err != nil {
+		return ""
+	}
+	return parts[0]
+}
+
+func splitValue(value string) ([]string, error) {
+	if !IsEncrypted(value) {
+		return nil, ErrMalformed
+	}
+	parts := strings.Split(strings.TrimPrefix(value, valuePrefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, ErrMalformed
+	}
+	return parts, nil
+}
+
+func sealGCM(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func openGCM(key, sealed, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// additionalData - дополнительные данные GCM: формат значения и место его хранения.
+func additionalData(scope string) []byte {
+	return []byte(valuePrefix + scope)
+}
+
+// Encrypt шифрует значение новым ключом данных с привязкой к scope. Пустые значения,
+// как и все значения при отключённом шифровании, возвращаются без изменений. Значение
+// с префиксом enc: отклоняется: его нельзя отличить от зашифрованного.
+func (e *Envelope) Encrypt(plaintext, scope string) (string, error) {
+	if IsEncrypted(plaintext) {
+		return "", ErrPrefixedPlaintext
+	}
+	if !e.Enabled() || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("generate data key: %w", err)
+	}
+	keyID, wrapped, err := e.provider.WrapKey(context.Background(), dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+	sealed, err := sealGCM(dataKey, []byte(plaintext), additionalData(scope))
+	if err != nil {
+		return "", fmt.Errorf("encrypt value: %w", err)
+	}
+	return valuePrefix + keyID + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение, зашифрованное с тем же scope. Открытый текст
+// возвращается как есть.
+func (e *Envelope) Decrypt(value, scope string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if !e.Enabled() {
+		return "", ErrNotConfigured
+	}
+	parts, err := splitValue(value)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	sealed, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := e.provider.UnwrapKey(context.Background(), parts[0], wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	plaintext, err := openGCM(dataKey, sealed, additionalData(scope))
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation сообщает, что значение нужно перешифровать: оно хранится открытым текстом
+// или его ключ данных завёрнут не текущим мастер-ключом.
+func (e *Envelope) NeedsRotation(value string) bool {
+	if !e.Enabled() || value == "" {
+		return false
+	}
+	return KeyID(value) != e.provider.CurrentKeyID()
+}
+
+// Rewrap заворачивает ключ данных значения текущим мастер-ключом. Шифротекст не меняется,
+// поэтому ротация мастер-ключа не требует расшифровки самих секретов. Открытый текст
+// шифруется с привязкой к scope.
+func (e *Envelope) Rewrap(value, scope string) (string, error) {
+	if !IsEncrypted(value) {
+		return e.Encrypt(value, scope)
+	}
+	if !e.Enabled() {
+		return "", ErrNotConfigured
+	}
+	parts, err := splitValue(value)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := e.provider.UnwrapKey(context.Background(), parts[0], wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	keyID, rewrapped, err := e.provider.WrapKey(context.Background(), dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+	return valuePrefix + keyID + ":" + encoding.EncodeToString(rewrapped) + ":" + parts[2], nil
+}
@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testScope = "EXCHANGE_ACCOUNTS.SECRET_KEY:1"
+
+func testProvider(t *testing.T, current string) *LocalKeyProvider {
+	t.Helper()
+	provider, err := NewLocalKeyProvider(map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, masterKeySize),
+		"k2": bytes.Repeat([]byte{2}, masterKeySize),
+	}, current)
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider: %v", err)
+	}
+	return provider
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	env := NewEnvelope(testProvider(t, "k1"))
+
+	encrypted, err := env.Encrypt("api-secret", testScope)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "api-secret") || KeyID(encrypted) != "k1" {
+		t.Fatalf("unexpected encrypted value: %s", encrypted)
+	}
+	again, _ := env.Encrypt("api-secret", testScope)
+	if again == encrypted {
+		t.Fatal("each value must get its own data key and nonce")
+	}
+
+	plain, err := env.Decrypt(encrypted, testScope)
+	if err != nil || plain != "api-secret" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+
+	// Записи до включения шифрования читаются как есть.
+	if plain, err := env.Decrypt("legacy-key", testScope); err != nil || plain != "legacy-key" {
+		t.Fatalf("legacy plaintext = %q, %v", plain, err)
+	}
+	if value, _ := env.Encrypt("", testScope); value != "" {
+		t.Fatalf("empty value must stay empty, got %q", value)
+	}
+}
+
+func TestEnvelopeTamperAndDisabled(t *testing.T) {
+	env := NewEnvelope(testProvider(t, "k1"))
+	encrypted, _ := env.Encrypt("api-secret", testScope)
+
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if _, err := env.Decrypt(tampered, testScope); err == nil {
+		t.Fatal("tampered ciphertext must not decrypt")
+	}
+	if _, err := env.Decrypt(strings.Replace(encrypted, ":k1:", ":k2:", 1), testScope); err == nil {
+		t.Fatal("data key wrapped by k1 must not unwrap with k2")
+	}
+
+	// Значение привязано к месту хранения: в другой колонке или записи не расшифруется.
+	for _, scope := range []string{"EXCHANGE_ACCOUNTS.API_KEY:1", "EXCHANGE_ACCOUNTS.SECRET_KEY:2"} {
+		if _, err := env.Decrypt(encrypted, scope); err == nil {
+			t.Fatalf("value must not decrypt under scope %s", scope)
+		}
+	}
+	// Открытый текст с префиксом не сохраняется как есть.
+	if _, err := env.Encrypt(encrypted, testScope); !errors.Is(err, ErrPrefixedPlaintext) {
+		t.Fatalf("expected ErrPrefixedPlaintext, got %v", err)
+	}
+
+	disabled := NewEnvelope(nil)
+	if value, _ := disabled.Encrypt("api-secret", testScope); value != "api-secret" {
+		t.Fatalf("disabled envelope must store plaintext, got %q", value)
+	}
+	if _, err := disabled.Encrypt(encrypted, testScope); !errors.Is(err, ErrPrefixedPlaintext) {
+		t.Fatalf("disabled envelope must reject prefixed values, got %v", err)
+	}
+	if _, err := disabled.Decrypt(encrypted, testScope); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestEnvelopeRotation(t *testing.T) {
+	provider := testProvider(t, "k1")
+	old := NewEnvelope(provider)
+	encrypted, _ := old.Encrypt("api-secret", testScope)
+
+	// Новый текущий ключ k2, k1 остаётся в keyring для чтения старых значений.
+	rotated := NewEnvelope(testProvider(t, "k2"))
+	if !rotated.NeedsRotation(encrypted) || !rotated.NeedsRotation("legacy-key") {
+		t.Fatal("values under k1 and plaintext must need rotation")
+	}
+
+	rewrapped, err := rotated.Rewrap(encrypted, testScope)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if KeyID(rewrapped) != "k2" || rotated.NeedsRotation(rewrapped) {
+		t.Fatalf("rewrapped value must use k2: %s", rewrapped)
+	}
+	if encrypted[strings.LastIndex(encrypted, ":"):] != rewrapped[strings.LastIndex(rewrapped, ":"):] {
+		t.Fatal("rewrap must keep the ciphertext and change only the wrapped data key")
+	}
+	if plain, err := rotated.Decrypt(rewrapped, testScope); err != nil || plain != "api-secret" {
+		t.Fatalf("Decrypt after rewrap = %q, %v", plain, err)
+	}
+
+	onlyK2, _ := NewLocalKeyProvider(map[string][]byte{"k2": bytes.Repeat([]byte{2}, masterKeySize)}, "k2")
+	if plain, err := NewEnvelope(onlyK2).Decrypt(rewrapped, testScope); err != nil || plain != "api-secret" {
+		t.Fatalf("after rotation k1 can be removed: %q, %v", plain, err)
+	}
+}
@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"ctweb/internal/config"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// masterKeySize - размер мастер-ключа local-провайдера (AES-256).
+const masterKeySize = 32
+
+// LocalKeyProvider - мастер-ключи в памяти процесса (из конфига, файла или окружения).
+// Ключ данных заворачивается AES-256-GCM, ID мастер-ключа входит в AAD.
+type LocalKeyProvider struct {
+	keys    map[string][]byte
+	current string
+}
+
+// NewLocalKeyProvider создаёт провайдер по набору мастер-ключей (id -> 32 байта).
+func NewLocalKeyProvider(keys map[string][]byte, current string) (*LocalKeyProvider, error) {
+	for id, key := range keys {
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("master key %q must be %d bytes, got %d", id, masterKeySize, len(key))
+		}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current master key %q is not loaded", current)
+	}
+	return &LocalKeyProvider{keys: keys, current: current}, nil
+}
+
+// CurrentKeyID возвращает ID мастер-ключа для новых значений.
+func (p *LocalKeyProvider) CurrentKeyID() string {
+	return p.current
+}
+
+// WrapKey заворачивает ключ данных текущим мастер-ключом.
+func (p *LocalKeyProvider) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := sealGCM(p.keys[p.current], dataKey, []byte(p.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.current, wrapped, nil
+}
+
+// UnwrapKey разворачивает ключ данных мастер-ключом keyID.
+func (p *LocalKeyProvider) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not configured", keyID)
+	}
+	return openGCM(key, wrapped, []byte(keyID))
+}
+
+// LoadMasterKey читает мастер-ключ (base64) из конфига, файла или переменной окружения.
+func LoadMasterKey(cfg config.MasterKeyConfig) ([]byte, error) {
+	var raw string
+	switch {
+	case cfg.Key != "":
+		raw = cfg.Key
+	case cfg.File != "":
+		content, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("read master key %q: %w", cfg.ID, err)
+		}
+		raw = string(content)
+	case cfg.Env != "":
+		raw = os.Getenv(cfg.Env)
+		if raw == "" {
+			return nil, fmt.Errorf("master key %q: environment variable %s is empty", cfg.ID, cfg.Env)
+		}
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("master key %q is not valid base64: %w", cfg.ID, err)
+	}
+	if len(key) != masterKeySize {
+		return nil, fmt.Errorf("master key %q must be %d bytes, got %d", cfg.ID, masterKeySize, len(key))
+	}
+	return key, nil
+}
+
+// GenerateMasterKey возвращает новый случайный мастер-ключ в base64.
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
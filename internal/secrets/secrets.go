@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"ctweb/internal/config"
+	"fmt"
+)
+
+// defaultEnvelope - шифратор приложения, настраивается Init() по конфигурации.
+var defaultEnvelope = NewEnvelope(nil)
+
+// Init настраивает шифрование по security.encryption. Без мастер-ключей
+// шифрование остаётся отключённым, ошибка возвращается только при неверных ключах.
+func Init() error {
+	enc := config.Get().Security.Encryption
+	if len(enc.Keys) == 0 {
+		defaultEnvelope = NewEnvelope(nil)
+		return nil
+	}
+
+	switch enc.Provider {
+	case "", "local":
+		keys := make(map[string][]byte, len(enc.Keys))
+		for _, keyCfg := range enc.Keys {
+			key, err := LoadMasterKey(keyCfg)
+			if err != nil {
+				return err
+			}
+			keys[keyCfg.ID] = key
+		}
+		provider, err := NewLocalKeyProvider(keys, enc.CurrentKeyID)
+		if err != nil {
+			return err
+		}
+		defaultEnvelope = NewEnvelope(provider)
+		return nil
+	default:
+		return fmt.Errorf("unsupported encryption provider: %s", enc.Provider)
+	}
+}
+
+// SetProvider подключает внешний провайдер мастер-ключей (KMS, HSM) вместо local.
+func SetProvider(provider KeyProvider) {
+	defaultEnvelope = NewEnvelope(provider)
+}
+
+// Default возвращает шифратор приложения.
+func Default() *Envelope {
+	return defaultEnvelope
+}
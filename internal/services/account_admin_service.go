@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/logger"
+	"ctweb/internal/repositories"
+	"fmt"
+	"strings"
+)
+
+// maskVisibleChars - сколько последних символов ключа остаются видимыми в админке.
+const maskVisibleChars = 4
+
+// MaskKey маскирует ключ API, оставляя видимыми только последние 4 символа.
+// Ключи короче 8 символов маскируются полностью, пустой ключ остаётся пустым.
+func MaskKey(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	runes := []rune(value)
+	if len(runes) < 2*maskVisibleChars {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-maskVisibleChars) + string(runes[len(runes)-maskVisibleChars:])
+}
+
+// AccountAdminService - аккаунты бирж всех пользователей для администратора.
+// Ключи наружу отдаются только в маскированном виде.
+type AccountAdminService struct {
+	accounts *repositories.ExchangeAccountRepository
+}
+
+// NewAccountAdminService создаёт сервис администрирования аккаунтов бирж.
+func NewAccountAdminService() *AccountAdminService {
+	return &AccountAdminService{accounts: repositories.NewExchangeAccountRepository()}
+}
+
+// List возвращает страницу аккаунтов всех пользователей с маскированными ключами.
+func (s *AccountAdminService) List(req *repositories.DataTablesRequest) (*repositories.AdminAccountsResponse, error) {
+	resp, err := s.accounts.FindAllWithPagination(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range resp.Data {
+		row.Account.ApiKey = MaskKey(row.Account.ApiKey)
+		row.Account.SecretKey = MaskKey(row.Account.SecretKey)
+		if row.Account.AddKey != nil {
+			masked := MaskKey(*row.Account.AddKey)
+			row.Account.AddKey = &masked
+		}
+	}
+	return resp, nil
+}
+
+// Owners возвращает пользователей, у которых есть аккаунты бирж.
+func (s *AccountAdminService) Owners() ([]*repositories.AccountOwner, error) {
+	return s.accounts.FindAccountOwners()
+}
+
+// DisableAccounts отключает аккаунты пользователя userID (все активные, если ids пуст) или
+// выбранные аккаунты ids. Каждое отключение пишется в аудит от имени администратора adminID.
+func (s *AccountAdminService) DisableAccounts(adminID, userID int, ids []int) ([]int, error) {
+	if userID <= 0 && len(ids) == 0 {
+		return nil, fmt.Errorf("user or accounts must be selected")
+	}
+	disabled, err := s.accounts.DisableAccounts(userID, ids, adminID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range disabled {
+		logger.Audit().InfoContext(context.Background(), "audit",
+			"module", "audit",
+			"event_type", "audit",
+			"action", "DISABLE_ACCOUNT",
+			"resource_type", "exchange_account",
+			"resource_id", id,
+			"user_id", adminID,
+			"owner_id", userID,
+			"result", "success",
+		)
+	}
+	return disabled, nil
+}
@@ -0,0 +1,18 @@
+package services
+
+import "testing"
+
+func TestMaskKey(t *testing.T) {
+	cases := map[string]string{
+		"":                   "",
+		"abc":                "***",
+		"abcdefg":            "*******",
+		"abcdefgh":           "****efgh",
+		" vmPUZE6mv9SD5VNH ": "************5VNH",
+	}
+	for value, want := range cases {
+		if got := MaskKey(value); got != want {
+			t.Fatalf("MaskKey(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
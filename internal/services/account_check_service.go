@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AccountCheckService проверяет ключи API аккаунтов бирж ("Test connection").
+type AccountCheckService struct {
+	accounts  *repositories.ExchangeAccountRepository
+	exchanges *repositories.ExchangeRepository
+}
+
+// NewAccountCheckService создаёт сервис проверки ключей.
+func NewAccountCheckService() *AccountCheckService {
+	return &AccountCheckService{
+		accounts:  repositories.NewExchangeAccountRepository(),
+		exchanges: repositories.NewExchangeRepository(),
+	}
+}
+
+// CredentialsOf возвращает ключи аккаунта для подписи приватных запросов.
+func CredentialsOf(acc *models.ExchangeAccount) connectors.Credentials {
+	creds := connectors.Credentials{APIKey: acc.ApiKey, SecretKey: acc.SecretKey}
+	if acc.AddKey != nil {
+		creds.Passphrase = *acc.AddKey
+	}
+	return creds
+}
+
+// ProbeKey запрашивает у биржи права ключа и переводит ответ в результат проверки.
+// Ошибки не возвращаются: любой исход проверки описывается статусом.
+func ProbeKey(ctx context.Context, exchange *models.Exchange, creds connectors.Credentials) *models.AccountKeyCheck {
+	check := &models.AccountKeyCheck{CheckedAt: time.Now().UTC()}
+
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		check.Status, check.Message = models.KeyCheckUnsupported, err.Error()
+		return check
+	}
+	provider, ok := connector.(connectors.PermissionProvider)
+	if !ok {
+		check.Status = models.KeyCheckUnsupported
+		check.Message = fmt.Sprintf("key check is not supported for %s", connector.Name())
+		return check
+	}
+
+	perms, err := provider.FetchKeyPermissions(ctx, creds)
+	if err != nil {
+		var apiErr *connectors.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.IPBlocked:
+			check.Status = models.KeyCheckIPBlocked
+		case errors.As(err, &apiErr) && apiErr.Auth:
+			check.Status = models.KeyCheckInvalid
+		case errors.Is(err, connectors.ErrNotSupported):
+			check.Status = models.KeyCheckUnsupported
+		default:
+			check.Status = models.KeyCheckError
+		}
+		check.Message = err.Error()
+		return check
+	}
+
+	check.Status = models.KeyCheckValid
+	check.Read = perms.Read
+	check.Trade = perms.Trade
+	check.Withdraw = perms.Withdraw
+	check.IPRestricted = perms.IPRestricted
+	return check
+}
+
+// TestConnection проверяет ключ аккаунта пользователя на бирже и сохраняет результат.
+// Если ключ разрешает вывод, нарушение пишется в audit log, а при политике block
+// активный аккаунт отключается.
+func (s *AccountCheckService) TestConnection(ctx context.Context, userID, accountID int) (*models.AccountKeyCheck, error) {
+	acc, err := s.accounts.FindByID(accountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	exchange, err := s.exchanges.FindByID(acc.ExID)
+	if err != nil {
+		return nil, err
+	}
+
+	check := ProbeKey(ctx, exchange, CredentialsOf(acc))
+	if err := s.accounts.SaveKeyCheck(acc.ID, userID, check); err != nil {
+		return nil, fmt.Errorf("save key check: %w", err)
+	}
+
+	policy := withdrawKeyPolicy()
+	switch action := keyCheckAction(policy, check, acc.Active); action {
+	case "deactivated":
+		if err := s.accounts.Deactivate(acc.ID, userID); err != nil {
+			return nil, fmt.Errorf("deactivate account: %w", err)
+		}
+		auditKeyPolicyViolation(userID, acc.ID, exchange, policy, action)
+	case "flagged":
+		auditKeyPolicyViolation(userID, acc.ID, exchange, policy, action)
+	}
+
+	logger.Info().
+		Int("user_id", userID).
+		Int("account_id", acc.ID).
+		Str("exchange", exchange.Name).
+		Str("status", check.Status).
+		Bool("trade", check.Trade).
+		Bool("withdraw", check.Withdraw).
+		Bool("ip_restricted", check.IPRestricted).
+		Msg("exchange account key checked")
+	return check, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"errors"
+	"sort"
+)
+
+// ValidateAccountParent проверяет привязку аккаунта acc к мастер-аккаунту parent.
+// Иерархия одноуровневая, как на биржах: мастер не может быть субаккаунтом, а аккаунт,
+// у которого есть субаккаунты (hasSubAccounts), не может стать субаккаунтом.
+func ValidateAccountParent(acc, parent *models.ExchangeAccount, hasSubAccounts bool) error {
+	if parent == nil {
+		return nil
+	}
+	switch {
+	case acc.ID != 0 && parent.ID == acc.ID:
+		return errors.New("account cannot be its own master account")
+	case parent.UID != acc.UID:
+		return errors.New("master account not found")
+	case parent.ExID != acc.ExID:
+		return errors.New("master account must be on the same exchange")
+	case parent.IsSubAccount():
+		return errors.New("master account cannot be a sub-account")
+	case hasSubAccounts:
+		return errors.New("account with sub-accounts cannot become a sub-account")
+	}
+	return nil
+}
+
+// MasterAccountIDs возвращает ID мастер-аккаунта для каждого аккаунта (для мастера - его ID).
+// Субаккаунт, мастер которого не найден среди accounts, считается самостоятельным.
+func MasterAccountIDs(accounts []*models.ExchangeAccount) map[int]int {
+	byID := make(map[int]bool, len(accounts))
+	for _, acc := range accounts {
+		byID[acc.ID] = true
+	}
+	masters := make(map[int]int, len(accounts))
+	for _, acc := range accounts {
+		masters[acc.ID] = acc.ID
+		if acc.ParentID != nil && byID[*acc.ParentID] {
+			masters[acc.ID] = *acc.ParentID
+		}
+	}
+	return masters
+}
+
+// OrderAccountTree упорядочивает аккаунты для списков: за мастер-аккаунтом идут его
+// субаккаунты. Порядок мастер-аккаунтов и субаккаунтов внутри мастера сохраняется.
+func OrderAccountTree(accounts []*models.ExchangeAccount) []*models.ExchangeAccount {
+	masters := MasterAccountIDs(accounts)
+	subs := make(map[int][]*models.ExchangeAccount)
+	for _, acc := range accounts {
+		if master := masters[acc.ID]; master != acc.ID {
+			subs[master] = append(subs[master], acc)
+		}
+	}
+	ordered := make([]*models.ExchangeAccount, 0, len(accounts))
+	for _, acc := range accounts {
+		if masters[acc.ID] != acc.ID {
+			continue
+		}
+		ordered = append(ordered, acc)
+		ordered = append(ordered, subs[acc.ID]...)
+	}
+	return ordered
+}
+
+// RollupAccounts суммирует последние снимки балансов (snapshots по ID аккаунта) и открытые
+// позиции (positions по ID аккаунта) субаккаунтов в их мастер-аккаунты.
+func RollupAccounts(accounts []*models.ExchangeAccount, snapshots map[int]*models.BalanceSnapshot, positions map[int]int) []*models.AccountRollup {
+	masters := MasterAccountIDs(accounts)
+	byMaster := make(map[int]*models.AccountRollup)
+	var result []*models.AccountRollup
+	for _, acc := range OrderAccountTree(accounts) {
+		masterID := masters[acc.ID]
+		rollup, ok := byMaster[masterID]
+		if !ok {
+			rollup = &models.AccountRollup{MasterID: masterID, MasterName: acc.AccountName, ExID: acc.ExID}
+			byMaster[masterID] = rollup
+			result = append(result, rollup)
+		}
+		rollup.AccountIDs = append(rollup.AccountIDs, acc.ID)
+		if acc.ID != masterID {
+			rollup.SubAccounts++
+		}
+		if snapshot := snapshots[acc.ID]; snapshot != nil {
+			rollup.USDValue += snapshot.USDValue
+			rollup.UnpricedAssets += snapshot.UnpricedAssets
+		} else {
+			rollup.MissingSnapshots++
+		}
+		rollup.OpenPositions += positions[acc.ID]
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].USDValue > result[j].USDValue })
+	return result
+}
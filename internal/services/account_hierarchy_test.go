@@ -0,0 +1,77 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"testing"
+)
+
+func account(id, exID int, parentID *int) *models.ExchangeAccount {
+	return &models.ExchangeAccount{ID: id, ExID: exID, UID: 1, AccountName: "acc", ParentID: parentID}
+}
+
+func TestValidateAccountParent(t *testing.T) {
+	master := account(1, 10, nil)
+	sub := account(2, 10, &master.ID)
+
+	if err := ValidateAccountParent(account(3, 10, nil), master, false); err != nil {
+		t.Fatalf("expected valid parent, got %v", err)
+	}
+	cases := map[string]struct {
+		acc, parent *models.ExchangeAccount
+		hasSubs     bool
+	}{
+		"self":           {master, master, false},
+		"other exchange": {account(3, 20, nil), master, false},
+		"other user":     {&models.ExchangeAccount{ID: 3, ExID: 10, UID: 2}, master, false},
+		"nested":         {account(3, 10, nil), sub, false},
+		"has subs":       {account(3, 10, nil), master, true},
+	}
+	for name, tc := range cases {
+		if err := ValidateAccountParent(tc.acc, tc.parent, tc.hasSubs); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRollupAccounts(t *testing.T) {
+	masterID, missingID := 1, 99
+	accounts := []*models.ExchangeAccount{
+		account(2, 10, &masterID),
+		account(3, 20, nil),
+		account(1, 10, nil),
+		account(4, 10, &masterID),
+		account(5, 10, &missingID),
+	}
+
+	ordered := OrderAccountTree(accounts)
+	var ids []int
+	for _, acc := range ordered {
+		ids = append(ids, acc.ID)
+	}
+	want := []int{3, 1, 2, 4, 5}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, ids)
+		}
+	}
+
+	snapshots := map[int]*models.BalanceSnapshot{
+		1: {USDValue: 100},
+		2: {USDValue: 50, UnpricedAssets: 1},
+		3: {USDValue: 10},
+		5: {USDValue: 5},
+	}
+	positions := map[int]int{1: 1, 4: 2, 3: 1}
+	rollup := RollupAccounts(accounts, snapshots, positions)
+	if len(rollup) != 3 {
+		t.Fatalf("expected 3 masters, got %d", len(rollup))
+	}
+	top := rollup[0]
+	if top.MasterID != 1 || top.SubAccounts != 2 || top.USDValue != 150 || top.UnpricedAssets != 1 ||
+		top.MissingSnapshots != 1 || top.OpenPositions != 3 || len(top.AccountIDs) != 3 {
+		t.Fatalf("unexpected master rollup: %+v", top)
+	}
+	if rollup[1].MasterID != 3 || rollup[2].MasterID != 5 || rollup[2].SubAccounts != 0 {
+		t.Fatalf("orphan sub-account must be rolled up as its own master: %+v %+v", rollup[1], rollup[2])
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"ctweb/internal/secrets"
+	"fmt"
+)
+
+// KeyMigrationResult - итог шифрования или ротации ключей аккаунтов.
+type KeyMigrationResult struct {
+	Total   int // Аккаунтов просмотрено
+	Updated int // Аккаунтов перезаписано (или было бы перезаписано при dry-run)
+	Changed int // Аккаунтов, изменённых параллельно - их нужно обработать повторным запуском
+	Failed  int // Аккаунтов с ошибкой (например, мастер-ключ значения отсутствует в keyring)
+}
+
+// AccountKeyService - перевод API-ключей аккаунтов бирж в зашифрованный вид и ротация мастер-ключа.
+type AccountKeyService struct {
+	accounts *repositories.ExchangeAccountRepository
+	envelope *secrets.Envelope
+}
+
+// NewAccountKeyService создаёт сервис по шифратору приложения (secrets.Init должен быть вызван).
+func NewAccountKeyService() *AccountKeyService {
+	return &AccountKeyService{
+		accounts: repositories.NewExchangeAccountRepository(),
+		envelope: secrets.Default(),
+	}
+}
+
+// transformKeys применяет fn к каждому ключу аккаунта; scope - привязка ключа к колонке и аккаунту.
+func transformKeys(item *models.StoredAccountKeys, fn func(value, scope string) (string, error)) (*models.StoredAccountKeys, error) {
+	updated := &models.StoredAccountKeys{ID: item.ID}
+	var err error
+	if updated.ApiKey, err = fn(item.ApiKey, repositories.AccountKeyScope(item.ID, repositories.AccountKeyColumnAPI)); err != nil {
+		return nil, fmt.Errorf("api key: %w", err)
+	}
+	if updated.SecretKey, err = fn(item.SecretKey, repositories.AccountKeyScope(item.ID, repositories.AccountKeyColumnSecret)); err != nil {
+		return nil, fmt.Errorf("secret key: %w", err)
+	}
+	if item.AddKey != nil {
+		addKey, err := fn(*item.AddKey, repositories.AccountKeyScope(item.ID, repositories.AccountKeyColumnAdd))
+		if err != nil {
+			return nil, fmt.Errorf("additional key: %w", err)
+		}
+		updated.AddKey = &addKey
+	}
+	return updated, nil
+}
+
+// process перезаписывает ключи аккаунтов, для которых needs возвращает true.
+func (s *AccountKeyService) process(ctx context.Context, dryRun bool, needs func(string) bool, fn func(value, scope string) (string, error)) (KeyMigrationResult, error) {
+	var result KeyMigrationResult
+	if !s.envelope.Enabled() {
+		return result, secrets.ErrNotConfigured
+	}
+
+	items, err := s.accounts.FindAllStoredKeys()
+	if err != nil {
+		return result, err
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Total++
+
+		pending := needs(item.ApiKey) || needs(item.SecretKey) || (item.AddKey != nil && needs(*item.AddKey))
+		if !pending {
+			continue
+		}
+
+		updated, err := transformKeys(item, fn)
+		if err != nil {
+			result.Failed++
+			logger.Error().Int("account_id", item.ID).Err(err).Msg("Account key migration failed")
+			continue
+		}
+		if dryRun {
+			result.Updated++
+			continue
+		}
+
+		ok, err := s.accounts.ReplaceStoredKeys(item, updated)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			result.Changed++
+			continue
+		}
+		result.Updated++
+	}
+	return result, nil
+}
+
+// EncryptExisting шифрует ключи, которые ещё хранятся открытым текстом.
+func (s *AccountKeyService) EncryptExisting(ctx context.Context, dryRun bool) (KeyMigrationResult, error) {
+	needs := func(value string) bool {
+		return value != "" && !secrets.IsEncrypted(value)
+	}
+	encrypt := func(value, scope string) (string, error) {
+		if secrets.IsEncrypted(value) {
+			return value, nil
+		}
+		return s.envelope.Encrypt(value, scope)
+	}
+	return s.process(ctx, dryRun, needs, encrypt)
+}
+
+// Rotate переводит все ключи на текущий мастер-ключ. По умолчанию заворачивается заново
+// только ключ данных; reencrypt дополнительно перешифровывает значения новыми ключами данных.
+// Открытый текст при этом тоже шифруется.
+func (s *AccountKeyService) Rotate(ctx context.Context, reencrypt, dryRun bool) (KeyMigrationResult, error) {
+	needs := s.envelope.NeedsRotation
+	fn := s.envelope.Rewrap
+	if reencrypt {
+		needs = func(value string) bool { return value != "" }
+		fn = func(value, scope string) (string, error) {
+			plain, err := s.envelope.Decrypt(value, scope)
+			if err != nil {
+				return "", err
+			}
+			return s.envelope.Encrypt(plain, scope)
+		}
+	}
+	return s.process(ctx, dryRun, needs, fn)
+}
@@ -0,0 +1,593 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/notify"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxAlertRules - сколько правил уведомлений может завести один пользователь.
+	maxAlertRules = 50
+	// alertEventsLimit - сколько последних срабатываний показывать на странице /alerts/.
+	alertEventsLimit = 50
+	// alertFundingWorkers - сколько текущих ставок funding запрашивается с бирж одновременно.
+	alertFundingWorkers = 8
+	// alertSendTimeout - ожидание доставки одного уведомления.
+	alertSendTimeout = 30 * time.Second
+)
+
+// ErrAlertRuleNotFound - правило не найдено или принадлежит другому пользователю.
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// AlertRuleInput - параметры нового правила. Для правила по позиции биржа, рынок и символ
+// берутся из позиции.
+type AlertRuleInput struct {
+	Type       string
+	PositionID int
+	ExchangeID int
+	Market     string
+	Symbol     string
+	Direction  string
+	Threshold  float64
+	Channel    string
+	Target     string
+}
+
+// alertObservation - значения, по которым проверяется правило.
+type alertObservation struct {
+	Price       float64  // Середина спреда, 0 - котировка недоступна
+	PnL         *float64 // PnL позиции (реализованный + нереализованный)
+	FundingRate *float64 // Текущая ставка funding
+}
+
+// positionPnL - реализованный PnL позиции плюс нереализованный по цене price.
+// Комиссии и funding уже учтены движком в средней цене и реализованном PnL.
+// Открытому объёму без цены PnL не считается (ok = false).
+func positionPnL(detail *models.PositionDetail, price float64) (float64, bool) {
+	pnl := 0.0
+	if detail.TotalRealizedPnL != nil {
+		pnl = *detail.TotalRealizedPnL
+	}
+	if detail.FinalPosition == nil || *detail.FinalPosition == 0 || detail.FinalAvgPrice == nil {
+		return pnl, true
+	}
+	if price <= 0 {
+		return 0, false
+	}
+	return pnl + *detail.FinalPosition*(price-*detail.FinalAvgPrice), true
+}
+
+// evaluateAlertRule проверяет условие правила и возвращает проверенное значение.
+// Ошибка - значения для проверки нет.
+func evaluateAlertRule(rule *models.AlertRule, obs alertObservation) (bool, float64, error) {
+	switch rule.Type {
+	case models.AlertTypePrice:
+		if obs.Price <= 0 {
+			return false, 0, fmt.Errorf("price is unavailable")
+		}
+		if rule.Direction == models.AlertDirectionBelow {
+			return obs.Price <= rule.Threshold, obs.Price, nil
+		}
+		return obs.Price >= rule.Threshold, obs.Price, nil
+	case models.AlertTypeLoss:
+		if obs.PnL == nil {
+			return false, 0, fmt.Errorf("position PnL is unavailable")
+		}
+		return *obs.PnL <= -rule.Threshold, *obs.PnL, nil
+	case models.AlertTypeFundingFlip:
+		if obs.FundingRate == nil {
+			return false, 0, fmt.Errorf("funding rate is unavailable")
+		}
+		rate := *obs.FundingRate
+		flipped := rule.LastValue != nil && rate != 0 && *rule.LastValue != 0 && (rate > 0) != (*rule.LastValue > 0)
+		return flipped, rate, nil
+	}
+	return false, 0, fmt.Errorf("unknown alert type %q", rule.Type)
+}
+
+// alertShouldNotify - уведомление отправляется, когда условие начинает выполняться.
+// Смена знака funding - событие, а не состояние, поэтому уведомляется каждая смена.
+func alertShouldNotify(rule *models.AlertRule, met bool) bool {
+	if rule.Type == models.AlertTypeFundingFlip {
+		return met
+	}
+	return met && !rule.IsTriggered
+}
+
+// alertInstrument - инструмент правила для текста уведомления.
+func alertInstrument(rule *models.AlertRule) string {
+	text := rule.Symbol + " " + rule.MarketType
+	if rule.Exchange != "" {
+		text += " on " + rule.Exchange
+	}
+	if rule.PositionID != nil {
+		text = fmt.Sprintf("position #%d %s", *rule.PositionID, text)
+	}
+	return text
+}
+
+// alertMessage формирует уведомление о срабатывании правила со значением value.
+func alertMessage(rule *models.AlertRule, value float64, at time.Time) notify.Message {
+	instrument := alertInstrument(rule)
+	fields := map[string]interface{}{
+		"rule_id":  rule.ID,
+		"exchange": rule.Exchange,
+		"market":   rule.MarketType,
+		"symbol":   rule.Symbol,
+		"value":    value,
+	}
+	if rule.PositionID != nil {
+		fields["position_id"] = *rule.PositionID
+	}
+
+	var subject, text string
+	switch rule.Type {
+	case models.AlertTypePrice:
+		fields["level"] = rule.Threshold
+		fields["direction"] = rule.Direction
+		subject = fmt.Sprintf("%s price %s %s", rule.Symbol, rule.Direction, formatAlertNumber(rule.Threshold))
+		text = fmt.Sprintf("Price of %s is %s (level %s %s).", instrument, formatAlertNumber(value), rule.Direction, formatAlertNumber(rule.Threshold))
+	case models.AlertTypeLoss:
+		fields["max_loss"] = rule.Threshold
+		subject = fmt.Sprintf("%s loss limit reached", rule.Symbol)
+		text = fmt.Sprintf("PnL of %s is %s, loss limit %s.", instrument, formatAlertNumber(value), formatAlertNumber(-rule.Threshold))
+	case models.AlertTypeFundingFlip:
+		sign := "positive (longs pay shorts)"
+		if value < 0 {
+			sign = "negative (shorts pay longs)"
+		}
+		if rule.LastValue != nil {
+			fields["previous"] = *rule.LastValue
+		}
+		subject = fmt.Sprintf("%s funding flipped %s", rule.Symbol, strings.SplitN(sign, " ", 2)[0])
+		text = fmt.Sprintf("Funding rate of %s is now %s: %.4f%%.", instrument, sign, value*100)
+	}
+	text += "\nRule #" + fmt.Sprint(rule.ID) + ", " + at.UTC().Format("2006-01-02 15:04:05") + " UTC."
+	return notify.Message{Event: rule.Type, Subject: subject, Text: text, Fields: fields, Time: at}
+}
+
+// formatAlertNumber выводит число без лишних нулей.
+func formatAlertNumber(value float64) string {
+	if math.Abs(value) >= 1 {
+		return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.4f", value), "0"), ".")
+	}
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.8f", value), "0"), ".")
+}
+
+// AlertService - правила уведомлений по позициям и инструментам, их проверка
+// и доставка срабатываний через каналы notify.
+type AlertService struct {
+	repo         *repositories.AlertRepository
+	positionRepo *repositories.PositionRepository
+	instruments  *repositories.InstrumentRepository
+	exchangeRepo *repositories.ExchangeRepository
+	telegram     *repositories.TelegramRepository
+	notifiers    map[string]notify.Notifier
+}
+
+// NewAlertService создаёт сервис с каналами доставки из notify.*.
+func NewAlertService() *AlertService {
+	return &AlertService{
+		repo:         repositories.NewAlertRepository(),
+		positionRepo: repositories.NewPositionRepository(),
+		instruments:  repositories.NewInstrumentRepository(),
+		exchangeRepo: repositories.NewExchangeRepository(),
+		telegram:     repositories.NewTelegramRepository(),
+		notifiers:    notify.FromConfig(config.Get().Notify),
+	}
+}
+
+// Channels возвращает настроенные каналы доставки.
+func (s *AlertService) Channels() []string {
+	return notify.Channels(s.notifiers)
+}
+
+// ListRules возвращает правила пользователя.
+func (s *AlertService) ListRules(userID int) ([]*models.AlertRule, error) {
+	return s.repo.FindAllByUser(userID)
+}
+
+// ListEvents возвращает последние срабатывания правил пользователя.
+func (s *AlertService) ListEvents(userID int) ([]*models.AlertEvent, error) {
+	return s.repo.FindEventsByUser(userID, alertEventsLimit)
+}
+
+// OpenPositions возвращает открытые позиции пользователя для правил по позиции.
+func (s *AlertService) OpenPositions(userID int) ([]*models.PositionSummary, error) {
+	count, err := s.positionRepo.CountPositionsByUser(userID, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.positionRepo.GetPositions(userID, models.PositionFilter{}, count+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*models.PositionSummary, 0)
+	for _, item := range all {
+		if item.Status == "OPEN" {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// CreateRule проверяет и сохраняет правило пользователя.
+func (s *AlertService) CreateRule(userID int, in AlertRuleInput) (*models.AlertRule, error) {
+	rule := &models.AlertRule{
+		UID:       userID,
+		Type:      in.Type,
+		Direction: in.Direction,
+		Threshold: in.Threshold,
+		Channel:   in.Channel,
+		Target:    in.Target,
+		IsActive:  true,
+	}
+	if in.Type == models.AlertTypeLoss && in.PositionID <= 0 {
+		return nil, fmt.Errorf("loss alert requires a position")
+	}
+
+	if in.PositionID > 0 {
+		position, err := s.positionRepo.GetPositionByID(userID, in.PositionID)
+		if err != nil {
+			return nil, err
+		}
+		if position == nil {
+			return nil, fmt.Errorf("position not found")
+		}
+		if position.Status != "OPEN" {
+			return nil, fmt.Errorf("position is closed")
+		}
+		positionID := position.PositionID
+		rule.PositionID = &positionID
+		rule.ExID = position.ExchangeID
+		rule.MarketType = connectors.NormalizeMarket(position.MarketType)
+		rule.Symbol = position.ContractName
+	} else {
+		if in.ExchangeID <= 0 || strings.TrimSpace(in.Symbol) == "" {
+			return nil, fmt.Errorf("select a position or an exchange and symbol")
+		}
+		market := connectors.NormalizeMarket(in.Market)
+		instrument, err := s.instruments.FindBySymbol(in.ExchangeID, market, strings.ToUpper(strings.TrimSpace(in.Symbol)))
+		if err != nil {
+			return nil, err
+		}
+		if instrument == nil {
+			return nil, fmt.Errorf("symbol is not listed on the exchange")
+		}
+		rule.ExID = in.ExchangeID
+		rule.MarketType = market
+		rule.Symbol = instrument.Symbol
+	}
+	if err := s.ValidateRule(userID, rule); err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAlertRuleLimit(count); err != nil {
+		return nil, err
+	}
+	if rule.ID, err = s.repo.Create(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ValidateRule проверяет условие и канал доставки правила с уже заполненными инструментом
+// и позицией; поля, не нужные типу правила, обнуляются.
+func (s *AlertService) ValidateRule(userID int, rule *models.AlertRule) error {
+	switch rule.Type {
+	case models.AlertTypePrice:
+		if rule.Direction != models.AlertDirectionAbove && rule.Direction != models.AlertDirectionBelow {
+			return fmt.Errorf("select price direction")
+		}
+		if rule.Threshold <= 0 {
+			return fmt.Errorf("price level must be greater than 0")
+		}
+	case models.AlertTypeLoss:
+		if rule.PositionID == nil {
+			return fmt.Errorf("loss alert requires a position")
+		}
+		if rule.Threshold <= 0 {
+			return fmt.Errorf("loss limit must be greater than 0")
+		}
+		rule.Direction = ""
+	case models.AlertTypeFundingFlip:
+		if rule.MarketType != connectors.MarketFutures {
+			return fmt.Errorf("funding alerts are available for futures only")
+		}
+		rule.Direction = ""
+		rule.Threshold = 0
+	default:
+		return fmt.Errorf("unknown alert type")
+	}
+
+	rule.Channel = strings.TrimSpace(rule.Channel)
+	rule.Target = strings.TrimSpace(rule.Target)
+	notifier, ok := s.notifiers[rule.Channel]
+	if !ok {
+		return fmt.Errorf("notification channel is not configured")
+	}
+	if rule.Channel == notify.ChannelTelegram {
+		// Чат берётся из привязки при каждой отправке
+		link, err := s.telegram.FindByUser(userID)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return fmt.Errorf("link your Telegram chat on the profile page first")
+		}
+		rule.Target = ""
+	} else if err := notifier.ValidateTarget(rule.Target); err != nil {
+		return err
+	}
+	if len(rule.Target) > 255 {
+		return fmt.Errorf("notification target is too long")
+	}
+	return nil
+}
+
+// checkAlertRuleLimit проверяет, можно ли добавить правило к count уже заведённым.
+func checkAlertRuleLimit(count int) error {
+	if count >= maxAlertRules {
+		return fmt.Errorf("too many alert rules (max %d)", maxAlertRules)
+	}
+	return nil
+}
+
+// SetRuleActive включает или приостанавливает правило пользователя.
+func (s *AlertService) SetRuleActive(userID, ruleID int, active bool) error {
+	// UPDATE без изменений не считается затронутой строкой, поэтому правило проверяется заранее.
+	rule, err := s.repo.FindByID(ruleID, userID)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return ErrAlertRuleNotFound
+	}
+	return s.repo.SetActive(ruleID, userID, active)
+}
+
+// DeleteRule удаляет правило пользователя.
+func (s *AlertService) DeleteRule(userID, ruleID int) error {
+	ok, err := s.repo.Delete(ruleID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAlertRuleNotFound
+	}
+	return nil
+}
+
+// SendTest отправляет пробное уведомление по каналу правила.
+func (s *AlertService) SendTest(ctx context.Context, userID, ruleID int) error {
+	rule, err := s.repo.FindByID(ruleID, userID)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return ErrAlertRuleNotFound
+	}
+	now := time.Now().UTC()
+	return s.deliver(ctx, rule, notify.Message{
+		Event:   "test",
+		Subject: "Test alert for " + rule.Symbol,
+		Text:    fmt.Sprintf("Test notification for rule #%d (%s, %s).", rule.ID, rule.Type, alertInstrument(rule)),
+		Fields:  map[string]interface{}{"rule_id": rule.ID},
+		Time:    now,
+	})
+}
+
+func (s *AlertService) deliver(ctx context.Context, rule *models.AlertRule, msg notify.Message) error {
+	notifier, ok := s.notifiers[rule.Channel]
+	if !ok {
+		return fmt.Errorf("notification channel %q is not configured", rule.Channel)
+	}
+	target := rule.Target
+	if rule.Channel == notify.ChannelTelegram {
+		link, err := s.telegram.FindByUser(rule.UID)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return fmt.Errorf("telegram chat is not linked")
+		}
+		target = strconv.FormatInt(link.ChatID, 10)
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, alertSendTimeout)
+	defer cancel()
+	return notifier.Send(sendCtx, target, msg)
+}
+
+type fundingResult struct {
+	rate float64
+	err  error
+}
+
+// fetchFundingRates запрашивает текущие ставки funding контрактов (не больше alertFundingWorkers запросов).
+func (s *AlertService) fetchFundingRates(ctx context.Context, keys []tickerKey) map[tickerKey]fundingResult {
+	providers := make(map[int]connectors.FundingRateProvider)
+	providerErrs := make(map[int]error)
+	for _, key := range keys {
+		if _, done := providers[key.ExID]; done {
+			continue
+		}
+		if _, failed := providerErrs[key.ExID]; failed {
+			continue
+		}
+		exchange, err := s.exchangeRepo.FindByID(key.ExID)
+		if err == nil && exchange == nil {
+			err = fmt.Errorf("exchange %d not found", key.ExID)
+		}
+		if err == nil {
+			providers[key.ExID], err = fundingProvider(exchange)
+		}
+		if err != nil {
+			delete(providers, key.ExID)
+			providerErrs[key.ExID] = err
+		}
+	}
+
+	results := make(map[tickerKey]fundingResult, len(keys))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, alertFundingWorkers)
+	for _, key := range keys {
+		if err, failed := providerErrs[key.ExID]; failed {
+			results[key] = fundingResult{err: err}
+			continue
+		}
+		wg.Add(1)
+		go func(key tickerKey, provider connectors.FundingRateProvider) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			forecast, err := provider.FetchFundingForecast(ctx, key.Symbol)
+			result := fundingResult{err: err}
+			if err == nil {
+				result.rate = forecast.Rate
+			}
+			mu.Lock()
+			results[key] = result
+			mu.Unlock()
+		}(key, providers[key.ExID])
+	}
+	wg.Wait()
+	return results
+}
+
+// Evaluate проверяет активные правила всех пользователей и отправляет уведомления
+// о сработавших. Неудачная доставка записывается в историю и повторно не отправляется:
+// правило сработает снова, когда условие перестанет и вновь начнёт выполняться.
+// Ошибка БД по одному правилу пишется в лог и не прерывает проверку остальных.
+func (s *AlertService) Evaluate(ctx context.Context) error {
+	rules, err := s.repo.FindActive()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	priceKeys := make([]tickerKey, 0, len(rules))
+	fundingKeys := make([]tickerKey, 0)
+	seenFunding := make(map[tickerKey]bool)
+	for _, rule := range rules {
+		key := newTickerKey(rule.ExID, rule.MarketType, rule.Symbol)
+		if rule.Type == models.AlertTypeFundingFlip {
+			if !seenFunding[key] {
+				seenFunding[key] = true
+				fundingKeys = append(fundingKeys, key)
+			}
+			continue
+		}
+		priceKeys = append(priceKeys, key)
+	}
+	tickers := sharedPriceCache.Tickers(ctx, priceKeys, 0)
+	rates := s.fetchFundingRates(ctx, fundingKeys)
+
+	type positionKey struct{ userID, positionID int }
+	positions := make(map[positionKey]*models.PositionDetail)
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		key := newTickerKey(rule.ExID, rule.MarketType, rule.Symbol)
+		var obs alertObservation
+		var checkErr error
+		if result := tickers[key]; result.err != nil {
+			checkErr = result.err
+		} else if result.ticker != nil {
+			obs.Price = result.ticker.Mid()
+		}
+		if rule.Type == models.AlertTypeFundingFlip {
+			result := rates[key]
+			checkErr = result.err
+			if result.err == nil {
+				obs.FundingRate = &result.rate
+			}
+		}
+		if rule.Type == models.AlertTypeLoss && rule.PositionID != nil {
+			pk := positionKey{rule.UID, *rule.PositionID}
+			detail, cached := positions[pk]
+			if !cached {
+				detail, err = s.positionRepo.GetPositionByID(rule.UID, *rule.PositionID)
+				if err != nil {
+					logger.Error().Err(err).Int("rule_id", rule.ID).Msg("Alert rule check failed")
+					continue
+				}
+				positions[pk] = detail
+			}
+			switch {
+			case detail == nil:
+				checkErr = fmt.Errorf("position not found")
+			case detail.Status != "OPEN":
+				checkErr = fmt.Errorf("position is closed")
+			default:
+				if pnl, ok := positionPnL(detail, obs.Price); ok {
+					obs.PnL = &pnl
+					checkErr = nil
+				}
+			}
+		}
+
+		if err := s.checkRule(ctx, rule, obs, checkErr, now); err != nil {
+			logger.Error().Err(err).Int("rule_id", rule.ID).Msg("Alert rule check failed")
+		}
+	}
+	return nil
+}
+
+// checkRule сохраняет результат проверки правила по наблюдению obs и при срабатывании
+// отправляет уведомление. Возвращает только ошибки БД.
+func (s *AlertService) checkRule(ctx context.Context, rule *models.AlertRule, obs alertObservation, checkErr error, now time.Time) error {
+	met, value, evalErr := evaluateAlertRule(rule, obs)
+	if evalErr != nil {
+		if checkErr == nil {
+			checkErr = evalErr
+		}
+		return s.repo.SetCheckResult(rule.ID, rule.IsTriggered, nil, now, nil, checkErr.Error())
+	}
+
+	stored := &value
+	if rule.Type == models.AlertTypeFundingFlip && value == 0 {
+		// Нулевая ставка не сбрасывает знак: смена знака через ноль тоже уведомляется.
+		stored = nil
+	}
+	if !alertShouldNotify(rule, met) {
+		return s.repo.SetCheckResult(rule.ID, met, stored, now, nil, "")
+	}
+
+	msg := alertMessage(rule, value, now)
+	deliveryErr := s.deliver(ctx, rule, msg)
+	event := &models.AlertEvent{RuleID: rule.ID, UID: rule.UID, DateCreate: now, Subject: msg.Subject, Message: msg.Text, Delivered: deliveryErr == nil}
+	lastError := ""
+	if deliveryErr != nil {
+		logger.Warn().Int("rule_id", rule.ID).Str("channel", rule.Channel).Err(deliveryErr).Msg("Alert delivery failed")
+		event.Error = deliveryErr.Error()
+		lastError = "delivery failed: " + deliveryErr.Error()
+	}
+	// Уведомление уже отправлено: результат проверки сохраняется и без записи в журнал,
+	// иначе следующий проход отправит его повторно.
+	eventErr := s.repo.InsertEvent(event)
+	if err := s.repo.SetCheckResult(rule.ID, met, stored, now, &now, lastError); err != nil {
+		return err
+	}
+	return eventErr
+}
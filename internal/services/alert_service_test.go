@@ -0,0 +1,144 @@
+package services
+
+import (
+	"ctweb/internal/config"
+	"ctweb/internal/connectors"
+	"ctweb/internal/models"
+	"ctweb/internal/notify"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestEvaluateAlertRulePrice(t *testing.T) {
+	above := &models.AlertRule{Type: models.AlertTypePrice, Direction: models.AlertDirectionAbove, Threshold: 100}
+	below := &models.AlertRule{Type: models.AlertTypePrice, Direction: models.AlertDirectionBelow, Threshold: 100}
+
+	if met, _, err := evaluateAlertRule(above, alertObservation{Price: 100}); err != nil || !met {
+		t.Fatalf("above: level reached must trigger, met=%v err=%v", met, err)
+	}
+	if met, _, _ := evaluateAlertRule(above, alertObservation{Price: 99.9}); met {
+		t.Fatal("above: price under level must not trigger")
+	}
+	if met, value, _ := evaluateAlertRule(below, alertObservation{Price: 95}); !met || value != 95 {
+		t.Fatalf("below: expected trigger at 95, met=%v value=%v", met, value)
+	}
+	if _, _, err := evaluateAlertRule(above, alertObservation{}); err == nil {
+		t.Fatal("expected error without price")
+	}
+}
+
+func TestEvaluateAlertRuleLoss(t *testing.T) {
+	rule := &models.AlertRule{Type: models.AlertTypeLoss, Threshold: 50}
+	if met, _, _ := evaluateAlertRule(rule, alertObservation{PnL: floatPtr(-49.99)}); met {
+		t.Fatal("loss under the limit must not trigger")
+	}
+	if met, value, _ := evaluateAlertRule(rule, alertObservation{PnL: floatPtr(-50)}); !met || value != -50 {
+		t.Fatalf("loss at the limit must trigger, met=%v value=%v", met, value)
+	}
+	if _, _, err := evaluateAlertRule(rule, alertObservation{Price: 10}); err == nil {
+		t.Fatal("expected error without PnL")
+	}
+}
+
+func TestEvaluateAlertRuleFundingFlip(t *testing.T) {
+	rule := &models.AlertRule{Type: models.AlertTypeFundingFlip}
+	// Первая проверка только запоминает знак.
+	if met, _, _ := evaluateAlertRule(rule, alertObservation{FundingRate: floatPtr(0.0001)}); met {
+		t.Fatal("first observation must not trigger")
+	}
+	rule.LastValue = floatPtr(0.0001)
+	if met, _, _ := evaluateAlertRule(rule, alertObservation{FundingRate: floatPtr(0.0003)}); met {
+		t.Fatal("same sign must not trigger")
+	}
+	if met, _, _ := evaluateAlertRule(rule, alertObservation{FundingRate: floatPtr(0)}); met {
+		t.Fatal("zero rate must not trigger")
+	}
+	met, value, _ := evaluateAlertRule(rule, alertObservation{FundingRate: floatPtr(-0.0002)})
+	if !met || value != -0.0002 {
+		t.Fatalf("sign flip must trigger, met=%v value=%v", met, value)
+	}
+	if !alertShouldNotify(&models.AlertRule{Type: models.AlertTypeFundingFlip, IsTriggered: true}, true) {
+		t.Fatal("every funding flip must be notified")
+	}
+}
+
+func TestAlertShouldNotifyOnEdge(t *testing.T) {
+	rule := &models.AlertRule{Type: models.AlertTypePrice}
+	if !alertShouldNotify(rule, true) {
+		t.Fatal("condition becoming true must notify")
+	}
+	rule.IsTriggered = true
+	if alertShouldNotify(rule, true) {
+		t.Fatal("condition staying true must not notify again")
+	}
+	if alertShouldNotify(rule, false) {
+		t.Fatal("condition false must not notify")
+	}
+}
+
+func TestPositionPnL(t *testing.T) {
+	detail := &models.PositionDetail{FinalPosition: floatPtr(-2), FinalAvgPrice: floatPtr(100), TotalRealizedPnL: floatPtr(5)}
+	if pnl, ok := positionPnL(detail, 130); !ok || math.Abs(pnl+55) > 1e-9 {
+		t.Fatalf("short PnL: got %v ok=%v, want -55", pnl, ok)
+	}
+	if _, ok := positionPnL(detail, 0); ok {
+		t.Fatal("open position without price must not have PnL")
+	}
+	flat := &models.PositionDetail{FinalPosition: floatPtr(0), TotalRealizedPnL: floatPtr(-7)}
+	if pnl, ok := positionPnL(flat, 0); !ok || pnl != -7 {
+		t.Fatalf("flat position PnL: got %v ok=%v, want -7", pnl, ok)
+	}
+}
+
+func TestAlertMessage(t *testing.T) {
+	positionID := 7
+	rule := &models.AlertRule{ID: 3, Type: models.AlertTypeLoss, PositionID: &positionID, Exchange: "Bybit",
+		MarketType: "FUTURES", Symbol: "BTCUSDT", Threshold: 100}
+	msg := alertMessage(rule, -123.456, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if msg.Subject != "BTCUSDT loss limit reached" {
+		t.Fatalf("unexpected subject: %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "position #7 BTCUSDT FUTURES on Bybit") || !strings.Contains(msg.Text, "-123.456") ||
+		!strings.Contains(msg.Text, "loss limit -100") {
+		t.Fatalf("unexpected text: %q", msg.Text)
+	}
+	if msg.Fields["position_id"] != 7 || msg.Event != models.AlertTypeLoss {
+		t.Fatalf("unexpected fields: %+v", msg.Fields)
+	}
+}
+
+func TestAlertServiceValidateRule(t *testing.T) {
+	s := &AlertService{notifiers: map[string]notify.Notifier{
+		notify.ChannelEmail: notify.NewSMTPNotifier(config.SMTPConfig{Host: "127.0.0.1", Port: 25, From: "alerts@example.com"}),
+	}}
+	rule := &models.AlertRule{Type: models.AlertTypeFundingFlip, MarketType: connectors.MarketFutures,
+		Direction: models.AlertDirectionAbove, Threshold: 5, Channel: " email ", Target: " trader@example.com "}
+	if err := s.ValidateRule(1, rule); err != nil {
+		t.Fatalf("ValidateRule() error = %v", err)
+	}
+	if rule.Direction != "" || rule.Threshold != 0 || rule.Channel != notify.ChannelEmail || rule.Target != "trader@example.com" {
+		t.Fatalf("rule is not normalized: %+v", rule)
+	}
+	invalid := []*models.AlertRule{
+		{Type: "volume", Channel: notify.ChannelEmail, Target: "trader@example.com"},
+		{Type: models.AlertTypePrice, Direction: "sideways", Threshold: 1, Channel: notify.ChannelEmail, Target: "trader@example.com"},
+		{Type: models.AlertTypeLoss, Threshold: 1, Channel: notify.ChannelEmail, Target: "trader@example.com"},
+		{Type: models.AlertTypeFundingFlip, MarketType: connectors.MarketSpot, Channel: notify.ChannelEmail, Target: "trader@example.com"},
+		{Type: models.AlertTypePrice, Direction: models.AlertDirectionBelow, Threshold: 1, Channel: notify.ChannelWebhook, Target: "https://example.com"},
+		{Type: models.AlertTypePrice, Direction: models.AlertDirectionBelow, Threshold: 1, Channel: notify.ChannelEmail, Target: "not an address"},
+	}
+	for _, rule := range invalid {
+		if err := s.ValidateRule(1, rule); err == nil {
+			t.Errorf("ValidateRule(%+v) accepted an invalid rule", rule)
+		}
+	}
+	if checkAlertRuleLimit(maxAlertRules-1) != nil || checkAlertRuleLimit(maxAlertRules) == nil {
+		t.Fatalf("unexpected alert rule limit")
+	}
+}
@@ -0,0 +1,520 @@
+package services
+
+import (
+	"ctweb/internal/connectors"
+	"ctweb/internal/db"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBackupInvalid - файл не является резервной копией или содержит некорректные данные.
+var ErrBackupInvalid = errors.New("invalid backup file")
+
+// BackupService - резервная копия позиций, операций и настроек пользователя в JSON
+// (схема models.Backup) и восстановление её под выбранным пользователем.
+type BackupService struct {
+	repo      *repositories.BackupRepository
+	groups    *repositories.PositionGroupRepository
+	accounts  *repositories.ExchangeAccountRepository
+	exchanges *repositories.ExchangeRepository
+	alerts    *repositories.AlertRepository
+	reports   *repositories.ReportRepository
+	alertSvc  *AlertService
+	reportSvc *ReportService
+}
+
+// NewBackupService создаёт новый экземпляр BackupService.
+func NewBackupService() *BackupService {
+	return &BackupService{
+		repo:      repositories.NewBackupRepository(),
+		groups:    repositories.NewPositionGroupRepository(),
+		accounts:  repositories.NewExchangeAccountRepository(),
+		exchanges: repositories.NewExchangeRepository(),
+		alerts:    repositories.NewAlertRepository(),
+		reports:   repositories.NewReportRepository(),
+		alertSvc:  NewAlertService(),
+		reportSvc: NewReportService(),
+	}
+}
+
+// BackupFileName возвращает имя файла копии: backup-login-20251018.json.
+func BackupFileName(login string, now time.Time) string {
+	return fmt.Sprintf("backup-%s-%s.json", login, now.Format("20060102"))
+}
+
+// Export собирает резервную копию пользователя. Ключи API аккаунтов в копию не попадают.
+func (s *BackupService) Export(user *models.User) (*models.Backup, error) {
+	positions, err := s.repo.FindPositions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.repo.FindTransactions(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groups.FindByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.accounts.FindAllByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	exchanges, err := s.exchanges.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.alerts.FindAllByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.reports.FindSubscription(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &models.Backup{
+		Format:   models.BackupFormat,
+		Version:  models.BackupVersion,
+		Exported: time.Now().UTC(),
+		User:     models.BackupUser{ID: user.ID, Login: user.Login},
+		Settings: models.BackupSettings{
+			Timezone:   user.Timezone,
+			AlertRules: make([]models.BackupAlertRule, 0, len(rules)),
+		},
+		Exchanges: make([]models.BackupExchange, 0),
+		Accounts:  make([]models.BackupAccount, 0, len(accounts)),
+		Groups:    make([]models.BackupGroup, 0, len(groups)),
+		Positions: positions,
+	}
+	if sub != nil {
+		backup.Settings.ReportSubscription = &models.BackupReportSubscription{Daily: sub.Daily, Monthly: sub.Monthly, Email: sub.Email}
+	}
+
+	usedExchanges := map[int]bool{}
+	for i := range backup.Positions {
+		p := &backup.Positions[i]
+		usedExchanges[p.ExchangeID] = true
+		if txs := transactions[p.ID]; txs != nil {
+			p.Transactions = txs
+		}
+	}
+	for _, rule := range rules {
+		usedExchanges[rule.ExID] = true
+		backup.Settings.AlertRules = append(backup.Settings.AlertRules, models.BackupAlertRule{
+			Type:       rule.Type,
+			PositionID: rule.PositionID,
+			ExchangeID: rule.ExID,
+			MarketType: rule.MarketType,
+			Symbol:     rule.Symbol,
+			Direction:  rule.Direction,
+			Threshold:  rule.Threshold,
+			Channel:    rule.Channel,
+			Target:     rule.Target,
+			IsActive:   rule.IsActive,
+		})
+	}
+	for _, acc := range accounts {
+		backup.Accounts = append(backup.Accounts, models.BackupAccount{ID: acc.ID, ExchangeID: acc.ExID, Name: acc.AccountName})
+	}
+	for _, g := range groups {
+		backup.Groups = append(backup.Groups, models.BackupGroup{
+			ID:        g.ID,
+			Name:      g.Name,
+			Strategy:  g.Strategy,
+			EntryDiff: g.EntryDiff,
+			Status:    g.Status,
+			Created:   g.Created.UTC(),
+			Closed:    g.Closed,
+		})
+	}
+	for _, ex := range exchanges {
+		if usedExchanges[ex.ID] {
+			backup.Exchanges = append(backup.Exchanges, models.BackupExchange{ID: ex.ID, Name: ex.Name})
+		}
+	}
+	return backup, nil
+}
+
+// ParseBackup читает и проверяет файл копии.
+func ParseBackup(data []byte) (*models.Backup, error) {
+	var backup models.Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBackupInvalid, err)
+	}
+	if err := validateBackup(&backup); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// validateBackup проверяет признак и версию схемы и обязательные поля позиций и операций.
+func validateBackup(b *models.Backup) error {
+	if b.Format != models.BackupFormat {
+		return fmt.Errorf("%w: not a backup file", ErrBackupInvalid)
+	}
+	if b.Version < 1 || b.Version > models.BackupVersion {
+		return fmt.Errorf("%w: unsupported version %d (supported up to %d)", ErrBackupInvalid, b.Version, models.BackupVersion)
+	}
+	seen := make(map[int]bool, len(b.Positions))
+	for _, p := range b.Positions {
+		if seen[p.ID] {
+			return fmt.Errorf("%w: duplicate position id %d", ErrBackupInvalid, p.ID)
+		}
+		seen[p.ID] = true
+		if strings.TrimSpace(p.Name) == "" || p.ExchangeID <= 0 || p.Created.IsZero() {
+			return fmt.Errorf("%w: position %d has no name, exchange or creation date", ErrBackupInvalid, p.ID)
+		}
+		if p.Status != "OPEN" && p.Status != "CLOSE" {
+			return fmt.Errorf("%w: position %d has unknown status %q", ErrBackupInvalid, p.ID, p.Status)
+		}
+		for _, t := range p.Transactions {
+			if t.Type != "TRADE" && t.Type != "FUNDING" {
+				return fmt.Errorf("%w: transaction %d has unknown type %q", ErrBackupInvalid, t.ID, t.Type)
+			}
+			if t.TransDate.IsZero() {
+				return fmt.Errorf("%w: transaction %d has no date", ErrBackupInvalid, t.ID)
+			}
+			for _, amount := range []*string{t.Price, t.Volume, t.Fee, t.FeeBase, t.Funding} {
+				if amount == nil {
+					continue
+				}
+				if _, err := strconv.ParseFloat(*amount, 64); err != nil {
+					return fmt.Errorf("%w: transaction %d has invalid amount %q", ErrBackupInvalid, t.ID, *amount)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// backupExchangeMap сопоставляет биржи копии с биржами этой базы по названию; если
+// биржи нет в списке копии, используется тот же ID.
+func backupExchangeMap(src []models.BackupExchange, dst []*models.Exchange) map[int]int {
+	byName := make(map[string]int, len(dst))
+	ids := make(map[int]bool, len(dst))
+	for _, ex := range dst {
+		byName[strings.ToLower(strings.TrimSpace(ex.Name))] = ex.ID
+		ids[ex.ID] = true
+	}
+	result := make(map[int]int)
+	listed := make(map[int]bool, len(src))
+	for _, ex := range src {
+		listed[ex.ID] = true
+		if id, ok := byName[strings.ToLower(strings.TrimSpace(ex.Name))]; ok {
+			result[ex.ID] = id
+		}
+	}
+	for id := range ids {
+		if !listed[id] {
+			result[id] = id
+		}
+	}
+	return result
+}
+
+// backupPositionKey - признак одной и той же позиции в копии и в базе.
+func backupPositionKey(exchangeID int, market, name string, created time.Time) string {
+	return fmt.Sprintf("%d|%s|%s|%s", exchangeID, connectors.NormalizeMarket(market), strings.ToUpper(strings.TrimSpace(name)),
+		created.UTC().Format(dateTimeFormat))
+}
+
+// backupTransactionKey - признак одной и той же операции без SOURCE_TRADE_ID: тип, время
+// и суммы (DECIMAL-строки сравниваются как числа).
+func backupTransactionKey(t *models.BackupTransaction) string {
+	amount := func(v *string) string {
+		if v == nil {
+			return "0"
+		}
+		f, err := strconv.ParseFloat(*v, 64)
+		if err != nil {
+			return *v
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strings.Join([]string{t.Type, t.TransDate.UTC().Format("2006-01-02 15:04:05.000"),
+		amount(t.Price), amount(t.Volume), amount(t.Fee), amount(t.FeeBase), amount(t.Funding)}, "|")
+}
+
+func backupAlertRuleKey(rule *models.AlertRule) string {
+	position := ""
+	if rule.PositionID != nil {
+		position = strconv.Itoa(*rule.PositionID)
+	}
+	return strings.Join([]string{rule.Type, position, strconv.Itoa(rule.ExID), rule.MarketType, rule.Symbol,
+		rule.Direction, strconv.FormatFloat(rule.Threshold, 'g', -1, 64), rule.Channel, rule.Target}, "|")
+}
+
+func backupTradeID(t *models.BackupTransaction) string {
+	if t.SourceTradeID == nil {
+		return ""
+	}
+	return strings.TrimSpace(*t.SourceTradeID)
+}
+
+// Restore восстанавливает копию под пользователем target в одной транзакции. ID позиций
+// и групп назначаются заново. Позиция, которая уже есть у пользователя (та же биржа, рынок,
+// контракт и дата открытия), не создаётся повторно: недостающие операции дописываются в неё.
+// Операции с SOURCE_TRADE_ID, уже загруженным на той же бирже, пропускаются.
+// При dryRun всё выполняется и откатывается: результат показывает, что было бы сделано.
+func (s *BackupService) Restore(target *models.User, backup *models.Backup, dryRun bool) (*models.BackupRestoreResult, error) {
+	result := &models.BackupRestoreResult{DryRun: dryRun, PositionIDs: map[int]int{}, Warnings: make([]string, 0)}
+	warn := func(format string, args ...interface{}) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	exchanges, err := s.exchanges.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	exchangeIDs := backupExchangeMap(backup.Exchanges, exchanges)
+	accounts, err := s.accounts.FindAllByUser(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	accountIDs := make(map[string]int, len(accounts))
+	for _, acc := range accounts {
+		accountIDs[strconv.Itoa(acc.ExID)+"|"+strings.ToLower(acc.AccountName)] = acc.ID
+	}
+	srcAccounts := make(map[int]models.BackupAccount, len(backup.Accounts))
+	for _, acc := range backup.Accounts {
+		srcAccounts[acc.ID] = acc
+	}
+	srcGroups := make(map[int]*models.BackupGroup, len(backup.Groups))
+	for i := range backup.Groups {
+		srcGroups[backup.Groups[i].ID] = &backup.Groups[i]
+	}
+	if backup.Settings.Timezone != "" && backup.Settings.Timezone != target.Timezone {
+		warn("Timezone %s from backup is not applied (user timezone is %s)", backup.Settings.Timezone, target.Timezone)
+	}
+
+	tx, err := db.BeginTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer db.RollbackTransaction(tx)
+
+	existing, err := s.repo.FindExistingPositions(tx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	existingIDs := make(map[string]int, len(existing))
+	for _, p := range existing {
+		existingIDs[backupPositionKey(p.ExchangeID, p.MarketType, p.Name, p.Created)] = p.ID
+	}
+	tradeIDs, err := s.repo.FindSourceTradeIDs(tx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	existingGroups, err := s.repo.FindExistingGroups(tx, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := make(map[string]int, len(existingGroups))
+	for _, g := range existingGroups {
+		groupIDs[g.Name+"|"+g.Created.UTC().Format(dateTimeFormat)] = g.ID
+	}
+
+	// groupFor возвращает группу этой базы для группы копии, создавая её при первой ссылке.
+	restoredGroups := map[int]int{}
+	groupFor := func(srcID *int) (*int, error) {
+		if srcID == nil {
+			return nil, nil
+		}
+		if id, ok := restoredGroups[*srcID]; ok {
+			return &id, nil
+		}
+		g, ok := srcGroups[*srcID]
+		if !ok {
+			warn("Group %d is missing in backup, positions are restored without it", *srcID)
+			return nil, nil
+		}
+		key := g.Name + "|" + g.Created.UTC().Format(dateTimeFormat)
+		id, ok := groupIDs[key]
+		if !ok {
+			if id, err = s.repo.InsertGroup(tx, target.ID, g); err != nil {
+				return nil, err
+			}
+			groupIDs[key] = id
+			result.GroupsCreated++
+		}
+		restoredGroups[*srcID] = id
+		return &id, nil
+	}
+
+	for i := range backup.Positions {
+		p := &backup.Positions[i]
+		exchangeID, ok := exchangeIDs[p.ExchangeID]
+		if !ok {
+			warn("Position %d %s skipped: exchange %d is not found", p.ID, p.Name, p.ExchangeID)
+			result.PositionsSkipped++
+			continue
+		}
+		p.MarketType = connectors.NormalizeMarket(p.MarketType)
+
+		positionID, matched := existingIDs[backupPositionKey(exchangeID, p.MarketType, p.Name, p.Created)]
+		knownTransactions := map[string]bool{}
+		if matched {
+			current, err := s.repo.FindPositionTransactions(tx, target.ID, positionID)
+			if err != nil {
+				return nil, err
+			}
+			for j := range current {
+				knownTransactions[backupTransactionKey(&current[j])] = true
+			}
+			result.PositionsMatched++
+		} else {
+			var accountID *int
+			if p.AccountID != nil {
+				acc, ok := srcAccounts[*p.AccountID]
+				if id, found := accountIDs[strconv.Itoa(exchangeID)+"|"+strings.ToLower(acc.Name)]; ok && found {
+					accountID = &id
+				} else if ok {
+					warn("Position %d %s: exchange account %q is not found, position is not linked", p.ID, p.Name, acc.Name)
+				} else {
+					warn("Position %d %s: exchange account %d is missing in backup, position is not linked", p.ID, p.Name, *p.AccountID)
+				}
+			}
+			groupID, err := groupFor(p.GroupID)
+			if err != nil {
+				return nil, err
+			}
+			if positionID, err = s.repo.InsertPosition(tx, target.ID, p, exchangeID, accountID, groupID); err != nil {
+				return nil, err
+			}
+			existingIDs[backupPositionKey(exchangeID, p.MarketType, p.Name, p.Created)] = positionID
+			result.PositionsCreated++
+		}
+		result.PositionIDs[p.ID] = positionID
+
+		if tradeIDs[exchangeID] == nil {
+			tradeIDs[exchangeID] = map[string]bool{}
+		}
+		for j := range p.Transactions {
+			t := &p.Transactions[j]
+			tradeID := backupTradeID(t)
+			if tradeID != "" && tradeIDs[exchangeID][tradeID] {
+				result.TransactionsDuplicate++
+				continue
+			}
+			if tradeID == "" && matched && knownTransactions[backupTransactionKey(t)] {
+				result.TransactionsDuplicate++
+				continue
+			}
+			inserted, err := s.repo.InsertTransaction(tx, positionID, t)
+			if err != nil {
+				return nil, err
+			}
+			if !inserted {
+				result.TransactionsDuplicate++
+				continue
+			}
+			if tradeID != "" {
+				tradeIDs[exchangeID][tradeID] = true
+			}
+			result.TransactionsCreated++
+		}
+	}
+
+	if err := s.restoreSettings(tx, target, backup, exchangeIDs, result, warn); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		result.PositionIDs = nil
+		return result, nil
+	}
+	if err := db.CommitTransaction(tx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// restoreSettings восстанавливает правила уведомлений (без уже существующих) и подписку
+// на отчёты, если пользователь её ещё не настраивал.
+func (s *BackupService) restoreSettings(tx *sql.Tx, target *models.User, backup *models.Backup, exchangeIDs map[int]int,
+	result *models.BackupRestoreResult, warn func(string, ...interface{})) error {
+	current, err := s.repo.FindAlertRules(tx, target.ID)
+	if err != nil {
+		return err
+	}
+	knownRules := make(map[string]bool, len(current))
+	for _, rule := range current {
+		knownRules[backupAlertRuleKey(rule)] = true
+	}
+	for _, src := range backup.Settings.AlertRules {
+		rule := &models.AlertRule{
+			UID:        target.ID,
+			Type:       src.Type,
+			MarketType: connectors.NormalizeMarket(src.MarketType),
+			Symbol:     src.Symbol,
+			Direction:  src.Direction,
+			Threshold:  src.Threshold,
+			Channel:    src.Channel,
+			Target:     src.Target,
+			IsActive:   src.IsActive,
+		}
+		exchangeID, ok := exchangeIDs[src.ExchangeID]
+		if !ok {
+			warn("Alert rule %s %s skipped: exchange %d is not found", src.Type, src.Symbol, src.ExchangeID)
+			result.AlertRulesSkipped++
+			continue
+		}
+		rule.ExID = exchangeID
+		if src.PositionID != nil {
+			positionID, ok := result.PositionIDs[*src.PositionID]
+			if !ok {
+				warn("Alert rule %s %s skipped: position %d is not restored", src.Type, src.Symbol, *src.PositionID)
+				result.AlertRulesSkipped++
+				continue
+			}
+			rule.PositionID = &positionID
+		}
+		if err := s.alertSvc.ValidateRule(target.ID, rule); err != nil {
+			warn("Alert rule %s %s skipped: %v", src.Type, src.Symbol, err)
+			result.AlertRulesSkipped++
+			continue
+		}
+		key := backupAlertRuleKey(rule)
+		if knownRules[key] {
+			result.AlertRulesSkipped++
+			continue
+		}
+		if err := checkAlertRuleLimit(len(current) + result.AlertRulesCreated); err != nil {
+			warn("Alert rule %s %s skipped: %v", src.Type, src.Symbol, err)
+			result.AlertRulesSkipped++
+			continue
+		}
+		if err := s.repo.InsertAlertRule(tx, rule); err != nil {
+			return err
+		}
+		knownRules[key] = true
+		result.AlertRulesCreated++
+	}
+
+	if sub := backup.Settings.ReportSubscription; sub != nil {
+		email, err := s.reportSvc.ValidateSubscription(target, sub.Daily, sub.Monthly, sub.Email)
+		if err != nil {
+			warn("Report subscription skipped: %v", err)
+			return nil
+		}
+		inserted, err := s.repo.InsertReportSubscription(tx, &models.ReportSubscription{
+			UID: target.ID, Daily: sub.Daily, Monthly: sub.Monthly, Email: email,
+		})
+		if err != nil {
+			return err
+		}
+		result.SubscriptionRestored = inserted
+		if !inserted {
+			warn("Report subscription is already configured and was not changed")
+		}
+	}
+	return nil
+}
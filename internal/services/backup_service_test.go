@@ -0,0 +1,90 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseBackup(t *testing.T) {
+	price, volume := "100.000000000000", "-0.5"
+	backup := models.Backup{
+		Format:  models.BackupFormat,
+		Version: models.BackupVersion,
+		Positions: []models.BackupPosition{{
+			ID: 10, Name: "BTCUSDT", ExchangeID: 1, MarketType: "SPOT", Status: "CLOSE",
+			Created: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Transactions: []models.BackupTransaction{
+				{ID: 1, Type: "TRADE", Price: &price, Volume: &volume, TransDate: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+			},
+		}},
+	}
+	data, err := json.Marshal(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseBackup(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Positions[0].Transactions[0]; *got.Price != price || got.Fee != nil {
+		t.Fatalf("unexpected transaction: %+v", got)
+	}
+
+	invalid := map[string]func(b *models.Backup){
+		"format":  func(b *models.Backup) { b.Format = "other" },
+		"version": func(b *models.Backup) { b.Version = models.BackupVersion + 1 },
+		"status":  func(b *models.Backup) { b.Positions[0].Status = "open" },
+		"type":    func(b *models.Backup) { b.Positions[0].Transactions[0].Type = "DEPOSIT" },
+		"amount": func(b *models.Backup) {
+			bad := "1,5"
+			b.Positions[0].Transactions[0].Volume = &bad
+		},
+		"duplicate": func(b *models.Backup) { b.Positions = append(b.Positions, b.Positions[0]) },
+	}
+	for name, mutate := range invalid {
+		var b models.Backup
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatal(err)
+		}
+		mutate(&b)
+		if err := validateBackup(&b); !errors.Is(err, ErrBackupInvalid) {
+			t.Errorf("%s: got %v, want ErrBackupInvalid", name, err)
+		}
+	}
+	if _, err := ParseBackup([]byte("[]")); !errors.Is(err, ErrBackupInvalid) {
+		t.Errorf("array accepted: %v", err)
+	}
+}
+
+func TestBackupExchangeMap(t *testing.T) {
+	src := []models.BackupExchange{{ID: 1, Name: "Bybit"}, {ID: 2, Name: "Gone"}, {ID: 3, Name: "okx "}}
+	dst := []*models.Exchange{{ID: 5, Name: "bybit"}, {ID: 7, Name: "OKX"}, {ID: 9, Name: "Binance"}}
+	got := backupExchangeMap(src, dst)
+	// Биржа "Gone" не найдена; биржи, которых нет в списке копии, берутся по тому же ID
+	want := map[int]int{1: 5, 3: 7, 5: 5, 7: 7, 9: 9}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBackupTransactionKey(t *testing.T) {
+	date := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	a, b, zero := "100.500000", "100.5", "0.000000"
+	first := &models.BackupTransaction{Type: "TRADE", Price: &a, Fee: &zero, TransDate: date}
+	second := &models.BackupTransaction{Type: "TRADE", Price: &b, TransDate: date.In(time.FixedZone("MSK", 3*60*60))}
+	if backupTransactionKey(first) != backupTransactionKey(second) {
+		t.Fatalf("keys differ: %s, %s", backupTransactionKey(first), backupTransactionKey(second))
+	}
+	second.TransDate = date.Add(time.Millisecond)
+	if backupTransactionKey(first) == backupTransactionKey(second) {
+		t.Fatalf("different time gives the same key")
+	}
+}
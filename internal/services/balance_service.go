@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// priceQuoteAsset - котируемая валюта, через которую оцениваются активы в USD.
+const priceQuoteAsset = "USDT"
+
+// stableAssets - активы, которые оцениваются как 1 USD без запроса цены.
+var stableAssets = map[string]bool{
+	"USD": true, "USDT": true, "USDC": true, "BUSD": true, "FDUSD": true, "TUSD": true, "DAI": true,
+}
+
+// AssetPriceFunc возвращает цену актива в USD (false - цена не найдена).
+type AssetPriceFunc func(asset string) (float64, bool)
+
+// BalanceService делает снимки балансов аккаунтов бирж и отдаёт их историю.
+type BalanceService struct {
+	accounts    *repositories.ExchangeAccountRepository
+	exchanges   *repositories.ExchangeRepository
+	instruments *repositories.InstrumentRepository
+	balances    *repositories.BalanceRepository
+	positions   *repositories.PositionRepository
+}
+
+// NewBalanceService создаёт сервис балансов.
+func NewBalanceService() *BalanceService {
+	return &BalanceService{
+		accounts:    repositories.NewExchangeAccountRepository(),
+		exchanges:   repositories.NewExchangeRepository(),
+		instruments: repositories.NewInstrumentRepository(),
+		balances:    repositories.NewBalanceRepository(),
+		positions:   repositories.NewPositionRepository(),
+	}
+}
+
+// BuildSnapshot собирает снимок из остатков биржи. Оценка в USD берётся у биржи, если она её
+// отдаёт, иначе стейблкоины считаются по 1 USD, остальные активы - по цене price.
+func BuildSnapshot(accountID, userID int, at time.Time, balances []connectors.Balance, price AssetPriceFunc) *models.BalanceSnapshot {
+	snapshot := &models.BalanceSnapshot{AccountID: accountID, UID: userID, SnapshotAt: at}
+	for _, b := range balances {
+		asset := strings.ToUpper(strings.TrimSpace(b.Asset))
+		if asset == "" || b.Total() == 0 {
+			continue
+		}
+		item := &models.AccountBalance{Wallet: b.Wallet, Asset: asset, Free: b.Free, Locked: b.Locked}
+
+		var value float64
+		priced := true
+		switch {
+		case b.USDValue != 0:
+			value = b.USDValue
+		case stableAssets[asset]:
+			value = b.Total()
+		default:
+			var unitPrice float64
+			if unitPrice, priced = price(asset); priced {
+				value = unitPrice * b.Total()
+			}
+		}
+		if priced {
+			item.USDValue = &value
+			snapshot.USDValue += value
+		} else {
+			snapshot.UnpricedAssets++
+		}
+		snapshot.Items = append(snapshot.Items, item)
+	}
+	return snapshot
+}
+
+// spotPricer возвращает функцию цены актива по последней минутной свече спотовой пары
+// ASSET/USDT биржи. Цены кешируются на время одного снимка.
+func (s *BalanceService) spotPricer(ctx context.Context, exchange *models.Exchange, connector connectors.Connector) AssetPriceFunc {
+	cache := make(map[string]*float64)
+	provider, ok := connector.(connectors.CandleProvider)
+	return func(asset string) (float64, bool) {
+		if cached, found := cache[asset]; found {
+			if cached == nil {
+				return 0, false
+			}
+			return *cached, true
+		}
+		cache[asset] = nil
+		if !ok {
+			return 0, false
+		}
+
+		instrument, err := s.instruments.FindByAssets(exchange.ID, connectors.MarketSpot, asset, priceQuoteAsset)
+		if err != nil || instrument == nil {
+			return 0, false
+		}
+		now := time.Now().UTC()
+		candles, err := provider.FetchCandles(ctx, connectors.MarketSpot, instrument.Symbol, "1m", now.Add(-15*time.Minute), now)
+		if err != nil || len(candles) == 0 {
+			if err != nil {
+				logger.Warn().Err(err).Str("exchange", exchange.Name).Str("symbol", instrument.Symbol).Msg("failed to load asset price")
+			}
+			return 0, false
+		}
+		value := candles[len(candles)-1].Close
+		cache[asset] = &value
+		return value, true
+	}
+}
+
+// snapshot читает балансы аккаунта на бирже и сохраняет снимок.
+func (s *BalanceService) snapshot(ctx context.Context, acc *models.ExchangeAccount) (*models.BalanceSnapshot, error) {
+	exchange, err := s.exchanges.FindByID(acc.ExID)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := connector.(connectors.BalanceProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: balances for %s", connectors.ErrNotSupported, connector.Name())
+	}
+
+	balances, err := provider.FetchBalances(ctx, CredentialsOf(acc))
+	if err != nil {
+		return nil, fmt.Errorf("fetch balances: %w", err)
+	}
+
+	snapshot := BuildSnapshot(acc.ID, acc.UID, time.Now().UTC().Truncate(time.Second), balances, s.spotPricer(ctx, exchange, connector))
+	id, err := s.balances.SaveSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.ID = id
+	return snapshot, nil
+}
+
+// SnapshotAccount делает снимок балансов аккаунта пользователя по запросу.
+func (s *BalanceService) SnapshotAccount(ctx context.Context, userID, accountID int) (*models.BalanceSnapshot, error) {
+	acc, err := s.accounts.FindByID(accountID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.snapshot(ctx, acc)
+}
+
+// SnapshotAll делает снимки всех активных аккаунтов (фоновая задача balance_snapshot).
+// Ошибка одного аккаунта не останавливает остальные. Возвращает количество снимков.
+func (s *BalanceService) SnapshotAll(ctx context.Context) (int, error) {
+	accounts, err := s.accounts.FindAllActive()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var failed []string
+	for _, acc := range accounts {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		if _, err := s.snapshot(ctx, acc); err != nil {
+			if errors.Is(err, connectors.ErrNotSupported) {
+				continue
+			}
+			logger.Warn().Err(err).Int("account_id", acc.ID).Int("user_id", acc.UID).Msg("balance snapshot failed")
+			failed = append(failed, fmt.Sprintf("%d", acc.ID))
+			continue
+		}
+		count++
+	}
+	if len(failed) > 0 {
+		return count, fmt.Errorf("balance snapshot failed for accounts: %s", strings.Join(failed, ", "))
+	}
+	return count, nil
+}
+
+// Latest возвращает последний снимок аккаунта (nil - снимков нет).
+func (s *BalanceService) Latest(userID, accountID int) (*models.BalanceSnapshot, error) {
+	if _, err := s.accounts.FindByID(accountID, userID); err != nil {
+		return nil, err
+	}
+	return s.balances.LatestSnapshot(accountID, userID)
+}
+
+// History возвращает историю стоимости аккаунта за последние days дней.
+func (s *BalanceService) History(userID, accountID, days int) ([]*models.BalanceSnapshot, error) {
+	if days <= 0 {
+		days = 30
+	}
+	from := time.Now().UTC().AddDate(0, 0, -days)
+	return s.balances.History(accountID, userID, from, 0)
+}
+
+// Rollup возвращает балансы (последние снимки) и открытые позиции пользователя, сведённые
+// к мастер-аккаунтам: субаккаунты суммируются в свой мастер-аккаунт.
+func (s *BalanceService) Rollup(userID int) ([]*models.AccountRollup, error) {
+	accounts, err := s.accounts.FindAllByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[int]*models.BalanceSnapshot, len(accounts))
+	for _, acc := range accounts {
+		snapshot, err := s.balances.LatestSnapshot(acc.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[acc.ID] = snapshot
+	}
+	positions, err := s.positions.CountOpenPositionsByAccount(userID)
+	if err != nil {
+		return nil, err
+	}
+	return RollupAccounts(accounts, snapshots, positions), nil
+}
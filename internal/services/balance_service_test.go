@@ -0,0 +1,42 @@
+package services
+
+import (
+	"ctweb/internal/connectors"
+	"testing"
+	"time"
+)
+
+func TestBuildSnapshot(t *testing.T) {
+	balances := []connectors.Balance{
+		{Wallet: "spot", Asset: "usdt", Free: 100, Locked: 50},
+		{Wallet: "spot", Asset: "BTC", Free: 0.5, Locked: 0.5},
+		{Wallet: "unified", Asset: "ETH", Free: 1, USDValue: 3000},
+		{Wallet: "spot", Asset: "XYZ", Free: 10},
+		{Wallet: "spot", Asset: "DUST"},
+	}
+	prices := map[string]float64{"BTC": 60000}
+	price := func(asset string) (float64, bool) {
+		value, ok := prices[asset]
+		return value, ok
+	}
+
+	snapshot := BuildSnapshot(7, 3, time.Unix(0, 0), balances, price)
+	if len(snapshot.Items) != 4 {
+		t.Fatalf("expected 4 items without zero balance, got %d", len(snapshot.Items))
+	}
+	if snapshot.Items[0].Asset != "USDT" || *snapshot.Items[0].USDValue != 150 {
+		t.Fatalf("stablecoin must be valued 1:1, got %+v", snapshot.Items[0])
+	}
+	if *snapshot.Items[1].USDValue != 60000 {
+		t.Fatalf("expected BTC value 60000, got %v", *snapshot.Items[1].USDValue)
+	}
+	if *snapshot.Items[2].USDValue != 3000 {
+		t.Fatalf("exchange valuation must be used, got %v", *snapshot.Items[2].USDValue)
+	}
+	if snapshot.Items[3].USDValue != nil || snapshot.UnpricedAssets != 1 {
+		t.Fatalf("expected unpriced XYZ, got %+v (unpriced %d)", snapshot.Items[3], snapshot.UnpricedAssets)
+	}
+	if !almostEqual(snapshot.USDValue, 63150) {
+		t.Fatalf("USDValue = %v, want 63150", snapshot.USDValue)
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/db"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxCandlesPerQuery - ограничение количества свечей в одном ответе API.
+	maxCandlesPerQuery = 5000
+	// candleSyncInterval - интервал свечей, которые фоновая задача держит актуальными для открытых позиций.
+	candleSyncInterval = "1h"
+	// candleSyncDepth - насколько глубоко фоновая задача загружает историю при первом запуске.
+	candleSyncDepth = 90 * 24 * time.Hour
+	// candleRequestPause - пауза между страницами, чтобы не упираться в лимиты бирж.
+	candleRequestPause = 100 * time.Millisecond
+)
+
+// CandleService - загрузка свечей OHLCV с бирж в БД котировок и выборка для графиков.
+type CandleService struct {
+	repo         *repositories.CandleRepository
+	positions    *repositories.PositionRepository
+	exchangeRepo *repositories.ExchangeRepository
+}
+
+// NewCandleService создаёт сервис свечей.
+func NewCandleService() *CandleService {
+	return &CandleService{
+		repo:         repositories.NewCandleRepository(),
+		positions:    repositories.NewPositionRepository(),
+		exchangeRepo: repositories.NewExchangeRepository(),
+	}
+}
+
+// ChooseCandleInterval подбирает наименьший интервал, при котором период span
+// укладывается в maxCandles свечей.
+func ChooseCandleInterval(span time.Duration, maxCandles int) string {
+	for _, interval := range []string{"1m", "5m", "15m", "1h", "4h", "1d"} {
+		if span/connectors.CandleIntervals[interval] <= time.Duration(maxCandles) {
+			return interval
+		}
+	}
+	return "1d"
+}
+
+func (s *CandleService) candleProvider(exchangeID int) (connectors.CandleProvider, error) {
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+	if exchange == nil {
+		return nil, fmt.Errorf("exchange not found")
+	}
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := connector.(connectors.CandleProvider)
+	if !ok {
+		return nil, connectors.ErrNotSupported
+	}
+	return provider, nil
+}
+
+// Backfill загружает свечи контракта за период [from, to] постранично и сохраняет их.
+// Возвращает количество сохранённых свечей.
+func (s *CandleService) Backfill(ctx context.Context, exchangeID int, market, symbol, interval string, from, to time.Time) (int, error) {
+	if !s.repo.Available() {
+		return 0, db.ErrQuotesNotConfigured
+	}
+	step, ok := connectors.CandleIntervals[interval]
+	if !ok {
+		return 0, fmt.Errorf("unsupported interval %q", interval)
+	}
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if symbol == "" {
+		return 0, fmt.Errorf("symbol is empty")
+	}
+	market = connectors.NormalizeMarket(market)
+
+	now := time.Now().UTC()
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+	from = from.UTC().Truncate(step)
+	if !to.After(from) {
+		return 0, fmt.Errorf("invalid period")
+	}
+
+	provider, err := s.candleProvider(exchangeID)
+	if err != nil {
+		return 0, err
+	}
+
+	window := step * time.Duration(connectors.CandlePageSize-1)
+	total := 0
+	for start := from; !start.After(to); start = start.Add(window + step) {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		end := start.Add(window)
+		if end.After(to) {
+			end = to
+		}
+
+		fetched, err := provider.FetchCandles(ctx, market, symbol, interval, start, end)
+		if err != nil {
+			return total, fmt.Errorf("fetch candles %s: %w", start.Format(time.RFC3339), err)
+		}
+		items := make([]*models.Candle, 0, len(fetched))
+		for _, c := range fetched {
+			items = append(items, &models.Candle{
+				ExID:       exchangeID,
+				MarketType: market,
+				Symbol:     symbol,
+				Interval:   interval,
+				OpenTime:   c.OpenTime,
+				Open:       c.Open,
+				High:       c.High,
+				Low:        c.Low,
+				Close:      c.Close,
+				Volume:     c.Volume,
+			})
+		}
+		count, err := s.repo.UpsertBatch(ctx, items)
+		if err != nil {
+			return total, err
+		}
+		total += count
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(candleRequestPause):
+		}
+	}
+	return total, nil
+}
+
+// SyncOpenPositions догружает часовые свечи по контрактам открытых позиций
+// с последней сохранённой свечи (или с даты открытия позиции).
+func (s *CandleService) SyncOpenPositions(ctx context.Context) (int, error) {
+	if !s.repo.Available() {
+		return 0, nil
+	}
+	contracts, err := s.positions.GetOpenContracts()
+	if err != nil {
+		return 0, err
+	}
+
+	unsupported := make(map[int]bool)
+	total, failed := 0, 0
+	now := time.Now().UTC()
+	for _, contract := range contracts {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		if unsupported[contract.ExID] {
+			continue
+		}
+
+		from := contract.Since.UTC()
+		if from.IsZero() || now.Sub(from) > candleSyncDepth {
+			from = now.Add(-candleSyncDepth)
+		}
+		last, err := s.repo.LastOpenTime(ctx, contract.ExID, contract.MarketType, contract.Symbol, candleSyncInterval)
+		if err == nil && last != nil && last.After(from) {
+			// Последняя свеча могла быть незакрытой - загружаем её повторно.
+			from = *last
+		}
+
+		count, err := s.Backfill(ctx, contract.ExID, contract.MarketType, contract.Symbol, candleSyncInterval, from, now)
+		total += count
+		if err != nil {
+			if errors.Is(err, connectors.ErrNotSupported) {
+				unsupported[contract.ExID] = true
+				continue
+			}
+			failed++
+			logger.Warn().
+				Int("exchange_id", contract.ExID).
+				Str("market", contract.MarketType).
+				Str("symbol", contract.Symbol).
+				Err(err).
+				Msg("Candle sync failed")
+		}
+	}
+
+	if failed > 0 {
+		return total, fmt.Errorf("candle sync failed for %d contract(s)", failed)
+	}
+	return total, nil
+}
+
+// GetCandles возвращает сохранённые свечи за период. Пустой interval или "auto" -
+// интервал подбирается так, чтобы период уложился в maxCandlesPerQuery свечей.
+func (s *CandleService) GetCandles(ctx context.Context, exchangeID int, market, symbol, interval string, from, to time.Time) (string, []*models.Candle, error) {
+	if !s.repo.Available() {
+		return "", nil, db.ErrQuotesNotConfigured
+	}
+	if exchangeID <= 0 || strings.TrimSpace(symbol) == "" {
+		return "", nil, fmt.Errorf("exchange and symbol are required")
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if !to.After(from) {
+		return "", nil, fmt.Errorf("invalid period")
+	}
+	if interval == "" || interval == "auto" {
+		interval = ChooseCandleInterval(to.Sub(from), maxCandlesPerQuery)
+	}
+	if _, ok := connectors.CandleIntervals[interval]; !ok {
+		return "", nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+
+	candles, err := s.repo.FindRange(ctx, exchangeID, connectors.NormalizeMarket(market), strings.ToUpper(strings.TrimSpace(symbol)), interval, from, to, maxCandlesPerQuery)
+	if err != nil {
+		return "", nil, err
+	}
+	return interval, candles, nil
+}
+
+// hasCoverage проверяет, что сохранённые свечи покрывают период [from, to]
+// без заметных дыр на краях (допускается отставание на одну свечу).
+func hasCoverage(candles []*models.Candle, step time.Duration, from, to time.Time) bool {
+	if len(candles) == 0 {
+		return false
+	}
+	first := candles[0].OpenTime
+	last := candles[len(candles)-1].OpenTime
+	return !first.After(from.Add(step)) && !last.Before(to.Add(-2*step))
+}
+
+// LoadCandles возвращает свечи за период: из БД котировок, если они покрывают период,
+// иначе загружает их с биржи (и сохраняет, если БД котировок подключена).
+// Второе значение - источник: "quotes" или "exchange".
+func (s *CandleService) LoadCandles(ctx context.Context, exchangeID int, market, symbol, interval string, from, to time.Time) ([]*models.Candle, string, error) {
+	step, ok := connectors.CandleIntervals[interval]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported interval %q", interval)
+	}
+	market = connectors.NormalizeMarket(market)
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	from = from.UTC().Truncate(step)
+	to = to.UTC()
+
+	if s.repo.Available() {
+		stored, err := s.repo.FindRange(ctx, exchangeID, market, symbol, interval, from, to, maxCandlesPerQuery)
+		if err != nil {
+			logger.Warn().Int("exchange_id", exchangeID).Str("symbol", symbol).Err(err).Msg("Load stored candles failed")
+		} else if hasCoverage(stored, step, from, to) {
+			return stored, "quotes", nil
+		}
+	}
+
+	provider, err := s.candleProvider(exchangeID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	window := step * time.Duration(connectors.CandlePageSize-1)
+	result := make([]*models.Candle, 0)
+	for start := from; !start.After(to) && len(result) < maxCandlesPerQuery; start = start.Add(window + step) {
+		end := start.Add(window)
+		if end.After(to) {
+			end = to
+		}
+		fetched, err := provider.FetchCandles(ctx, market, symbol, interval, start, end)
+		if err != nil {
+			return nil, "", fmt.Errorf("fetch candles %s: %w", start.Format(time.RFC3339), err)
+		}
+		for _, c := range fetched {
+			result = append(result, &models.Candle{
+				ExID:       exchangeID,
+				MarketType: market,
+				Symbol:     symbol,
+				Interval:   interval,
+				OpenTime:   c.OpenTime,
+				Open:       c.Open,
+				High:       c.High,
+				Low:        c.Low,
+				Close:      c.Close,
+				Volume:     c.Volume,
+			})
+		}
+	}
+
+	if s.repo.Available() && len(result) > 0 {
+		if _, err := s.repo.UpsertBatch(ctx, result); err != nil {
+			logger.Warn().Int("exchange_id", exchangeID).Str("symbol", symbol).Err(err).Msg("Save fetched candles failed")
+		}
+	}
+	return result, "exchange", nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChooseCandleInterval(t *testing.T) {
+	cases := []struct {
+		span time.Duration
+		want string
+	}{
+		{2 * time.Hour, "1m"},
+		{5000 * time.Minute, "1m"},
+		{5001 * time.Minute, "5m"},
+		{30 * 24 * time.Hour, "15m"},
+		{180 * 24 * time.Hour, "1h"},
+		{2 * 365 * 24 * time.Hour, "4h"},
+		{20 * 365 * 24 * time.Hour, "1d"},
+	}
+	for _, tc := range cases {
+		if got := ChooseCandleInterval(tc.span, 5000); got != tc.want {
+			t.Errorf("ChooseCandleInterval(%s) = %s, want %s", tc.span, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// coinIconSourceURL - откуда загружаются иконки монет (%s - тикер в нижнем регистре).
+	coinIconSourceURL = "https://cdn.jsdelivr.net/gh/spothq/cryptocurrency-icons@master/32/color/%s.png"
+	// coinIconDir - каталог локального набора иконок (отдаётся как /assets/images/coins/).
+	coinIconDir = "web/static/images/coins"
+	// coinIconBatch - сколько иконок загружается за один запуск.
+	coinIconBatch = 200
+	// coinIconMaxSize - максимальный размер файла иконки.
+	coinIconMaxSize = 256 << 10
+	// maxCoinInfoSymbols - сколько монет можно запросить за раз через ajax_coin_info.
+	maxCoinInfoSymbols = 50
+)
+
+// coinSymbolPattern - допустимые символы тикера в имени файла иконки.
+var coinSymbolPattern = regexp.MustCompile(`^[a-z0-9]{1,32}$`)
+
+// CoinSyncResult - результат синхронизации справочника монет одной биржи.
+type CoinSyncResult struct {
+	ExchangeID   int    `json:"exchange_id"`
+	ExchangeName string `json:"exchange_name"`
+	Coins        int    `json:"coins"`
+	Networks     int    `json:"networks"`
+	Error        string `json:"error,omitempty"`
+}
+
+// CoinInfo - монета с сетями для подписей на страницах позиций и анализа.
+type CoinInfo struct {
+	*models.Coin
+	IconURL  string                `json:"icon_url"`
+	Networks []*models.CoinNetwork `json:"networks"`
+}
+
+// BuildCoinCatalog переводит справочник монет биржи в монеты и сети по тикеру.
+// Тикеры приводятся к верхнему регистру, повторяющиеся сети монеты отбрасываются.
+func BuildCoinCatalog(exchangeID int, currencies []connectors.Currency) ([]*models.Coin, map[string][]*models.CoinNetwork) {
+	coins := make([]*models.Coin, 0, len(currencies))
+	networks := make(map[string][]*models.CoinNetwork, len(currencies))
+	seenCoins := make(map[string]bool, len(currencies))
+	for _, currency := range currencies {
+		symbol := strings.ToUpper(strings.TrimSpace(currency.Asset))
+		if symbol == "" {
+			continue
+		}
+		if !seenCoins[symbol] {
+			seenCoins[symbol] = true
+			coins = append(coins, &models.Coin{Symbol: symbol, Name: strings.TrimSpace(currency.Name)})
+		}
+		seenNetworks := make(map[string]bool)
+		for _, n := range networks[symbol] {
+			seenNetworks[n.Network] = true
+		}
+		for _, n := range currency.Networks {
+			code := strings.ToUpper(strings.TrimSpace(n.Network))
+			if code == "" || seenNetworks[code] {
+				continue
+			}
+			seenNetworks[code] = true
+			networks[symbol] = append(networks[symbol], &models.CoinNetwork{
+				ExID:            exchangeID,
+				Network:         code,
+				NetworkName:     strings.TrimSpace(n.Name),
+				ContractAddress: strings.TrimSpace(n.ContractAddress),
+				DepositEnabled:  n.DepositEnabled,
+				WithdrawEnabled: n.WithdrawEnabled,
+				WithdrawFee:     n.WithdrawFee,
+				WithdrawMin:     n.WithdrawMin,
+				Confirmations:   n.Confirmations,
+			})
+		}
+	}
+	return coins, networks
+}
+
+// coinIconFile возвращает имя файла иконки монеты ("" - тикер нельзя использовать в имени файла).
+func coinIconFile(symbol string) string {
+	name := strings.ToLower(strings.TrimSpace(symbol))
+	if !coinSymbolPattern.MatchString(name) {
+		return ""
+	}
+	return name + ".png"
+}
+
+// CoinService синхронизирует справочник монет с биржами и ведёт локальный набор иконок.
+type CoinService struct {
+	coins     *repositories.CoinRepository
+	exchanges *repositories.ExchangeRepository
+	accounts  *repositories.ExchangeAccountRepository
+	client    *http.Client
+}
+
+// NewCoinService создаёт сервис справочника монет.
+func NewCoinService() *CoinService {
+	return &CoinService{
+		coins:     repositories.NewCoinRepository(),
+		exchanges: repositories.NewExchangeRepository(),
+		accounts:  repositories.NewExchangeAccountRepository(),
+		client:    &http.Client{Timeout: connectors.DefaultTimeout},
+	}
+}
+
+// List возвращает страницу справочника монет.
+func (s *CoinService) List(req *repositories.DataTablesRequest) (*repositories.CoinsResponse, error) {
+	return s.coins.FindAllWithPagination(req)
+}
+
+// adminCredentials возвращает ключи активного аккаунта администратора на бирже.
+// Справочники монет Binance, Bybit и OKX приватные, а чужие ключи для этого не используются.
+func adminCredentials(accounts []*models.ExchangeAccount, exchangeID int) (connectors.Credentials, bool) {
+	for _, acc := range accounts {
+		if acc.ExID == exchangeID && acc.IsActive() {
+			return CredentialsOf(acc), true
+		}
+	}
+	return connectors.Credentials{}, false
+}
+
+// Sync загружает справочники монет всех активных бирж. Ключи для приватных справочников
+// берутся из аккаунтов администратора adminID. Ошибка одной биржи не прерывает остальные.
+func (s *CoinService) Sync(ctx context.Context, adminID int) ([]CoinSyncResult, error) {
+	exchanges, err := s.exchanges.FindAllActive()
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.accounts.FindAllByUser(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]CoinSyncResult, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		result := CoinSyncResult{ExchangeID: exchange.ID, ExchangeName: exchange.Name}
+		coins, networks, err := s.syncExchange(ctx, exchange, accounts)
+		if err != nil {
+			result.Error = err.Error()
+			logger.Warn().Str("exchange", exchange.Name).Err(err).Msg("Coin catalog sync failed")
+		} else {
+			result.Coins, result.Networks = coins, networks
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *CoinService) syncExchange(ctx context.Context, exchange *models.Exchange, accounts []*models.ExchangeAccount) (int, int, error) {
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		return 0, 0, err
+	}
+	provider, ok := connector.(connectors.CurrencyProvider)
+	if !ok {
+		return 0, 0, connectors.ErrNotSupported
+	}
+	creds, _ := adminCredentials(accounts, exchange.ID)
+
+	currencies, err := provider.FetchCurrencies(ctx, creds)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(currencies) == 0 {
+		// Пустой ответ не сохраняем, чтобы не удалить все сети биржи.
+		return 0, 0, errors.New("exchange returned empty coin list")
+	}
+	coins, networks := BuildCoinCatalog(exchange.ID, currencies)
+	saved, err := s.coins.SyncExchange(exchange.ID, coins, networks, time.Now().UTC())
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(coins), saved, nil
+}
+
+// GenerateIcons загружает иконки монет, для которых их ещё нет, в локальный набор.
+// Монеты, которых нет в источнике, помечаются пустым путём и больше не запрашиваются.
+// Возвращает количество загруженных и отсутствующих в источнике иконок.
+func (s *CoinService) GenerateIcons(ctx context.Context) (int, int, error) {
+	coins, err := s.coins.FindWithoutIcon(coinIconBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.MkdirAll(coinIconDir, 0o755); err != nil {
+		return 0, 0, fmt.Errorf("create icon dir: %w", err)
+	}
+
+	loaded, missing := 0, 0
+	for _, coin := range coins {
+		if err := ctx.Err(); err != nil {
+			return loaded, missing, err
+		}
+		file := coinIconFile(coin.Symbol)
+		if file == "" {
+			missing++
+			_ = s.coins.SetIcon(coin.ID, "")
+			continue
+		}
+		found, err := s.downloadIcon(ctx, fmt.Sprintf(coinIconSourceURL, strings.TrimSuffix(file, ".png")), filepath.Join(coinIconDir, file))
+		if err != nil {
+			logger.Warn().Str("symbol", coin.Symbol).Err(err).Msg("Download coin icon failed")
+			continue
+		}
+		icon := ""
+		if found {
+			icon = "images/coins/" + file
+			loaded++
+		} else {
+			missing++
+		}
+		if err := s.coins.SetIcon(coin.ID, icon); err != nil {
+			return loaded, missing, err
+		}
+	}
+	return loaded, missing, nil
+}
+
+// downloadIcon сохраняет иконку по url в path. false без ошибки - иконки нет в источнике (404).
+func (s *CoinService) downloadIcon(ctx context.Context, url, path string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, coinIconMaxSize+1))
+	if err != nil {
+		return false, err
+	}
+	if len(body) > coinIconMaxSize {
+		return false, errors.New("icon is too large")
+	}
+	if http.DetectContentType(body) != "image/png" {
+		return false, errors.New("icon is not a PNG image")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, path)
+}
+
+// Info возвращает монеты по списку тикеров с иконками и сетями на биржах.
+// Неизвестные тикеры пропускаются.
+func (s *CoinService) Info(symbols []string) ([]*CoinInfo, error) {
+	unique := make([]string, 0, len(symbols))
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		unique = append(unique, symbol)
+	}
+	if len(unique) > maxCoinInfoSymbols {
+		return nil, fmt.Errorf("too many symbols (max %d)", maxCoinInfoSymbols)
+	}
+
+	coins, err := s.coins.FindBySymbols(unique)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*CoinInfo, 0, len(coins))
+	for _, coin := range coins {
+		networks, err := s.coins.FindNetworks(coin.ID)
+		if err != nil {
+			return nil, err
+		}
+		info := &CoinInfo{Coin: coin, Networks: networks}
+		if coin.Icon != nil && *coin.Icon != "" {
+			info.IconURL = "/assets/" + *coin.Icon
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Symbol < result[j].Symbol })
+	return result, nil
+}
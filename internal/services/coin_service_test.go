@@ -0,0 +1,47 @@
+package services
+
+import (
+	"ctweb/internal/connectors"
+	"testing"
+)
+
+func TestBuildCoinCatalog(t *testing.T) {
+	coins, networks := BuildCoinCatalog(3, []connectors.Currency{
+		{Asset: "usdt", Name: "Tether", Networks: []connectors.CurrencyNetwork{
+			{Network: "trc20", Name: "Tron", DepositEnabled: true, WithdrawEnabled: true, WithdrawFee: 1},
+			{Network: "ERC20", Name: "Ethereum"},
+		}},
+		// Повтор тикера: новая сеть добавляется, уже известная отбрасывается.
+		{Asset: "USDT", Networks: []connectors.CurrencyNetwork{{Network: "TRC20"}, {Network: "SOL"}}},
+		{Asset: " ", Name: "broken"},
+	})
+
+	if len(coins) != 1 || coins[0].Symbol != "USDT" || coins[0].Name != "Tether" {
+		t.Fatalf("unexpected coins: %+v", coins)
+	}
+	usdt := networks["USDT"]
+	if len(usdt) != 3 {
+		t.Fatalf("expected 3 networks, got %d", len(usdt))
+	}
+	if usdt[0].Network != "TRC20" || usdt[0].ExID != 3 || !usdt[0].DepositEnabled || usdt[0].WithdrawFee != 1 {
+		t.Fatalf("unexpected first network: %+v", usdt[0])
+	}
+	if usdt[2].Network != "SOL" {
+		t.Fatalf("unexpected last network: %+v", usdt[2])
+	}
+}
+
+func TestCoinIconFile(t *testing.T) {
+	cases := map[string]string{
+		"BTC":     "btc.png",
+		" 1inch ": "1inch.png",
+		"../etc":  "",
+		"USDT.e":  "",
+		"":        "",
+	}
+	for symbol, want := range cases {
+		if got := coinIconFile(symbol); got != want {
+			t.Errorf("coinIconFile(%q) = %q, want %q", symbol, got, want)
+		}
+	}
+}
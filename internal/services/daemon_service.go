@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/logger"
+)
+
+// DaemonService - управление процессом-обработчиком со страницы /daemon/.
+// Каждый запуск и остановка пишутся в журнал аудита.
+type DaemonService struct {
+	supervisor DaemonSupervisor
+}
+
+// NewDaemonService создаёт сервис по настройкам daemon из конфигурации.
+func NewDaemonService() *DaemonService {
+	return &DaemonService{supervisor: NewDaemonSupervisor(config.Get().Daemon)}
+}
+
+// Status возвращает состояние процесса-обработчика.
+func (s *DaemonService) Status(ctx context.Context) (*DaemonStatus, error) {
+	return s.supervisor.Status(ctx)
+}
+
+// Start запускает процесс-обработчик от имени администратора adminID.
+func (s *DaemonService) Start(ctx context.Context, adminID int) (*DaemonStatus, error) {
+	status, err := s.supervisor.Start(ctx)
+	auditDaemon("START_DAEMON", adminID, status, err)
+	return status, err
+}
+
+// Stop останавливает процесс-обработчик от имени администратора adminID.
+func (s *DaemonService) Stop(ctx context.Context, adminID int) (*DaemonStatus, error) {
+	before, _ := s.supervisor.Status(ctx)
+	status, err := s.supervisor.Stop(ctx)
+	// В аудит пишется PID остановленного процесса, а не текущее состояние.
+	if before != nil {
+		status = before
+	}
+	auditDaemon("STOP_DAEMON", adminID, status, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.supervisor.Status(ctx)
+}
+
+// auditDaemon пишет в audit log запуск или остановку процесса-обработчика (resource_id - PID).
+func auditDaemon(action string, adminID int, status *DaemonStatus, err error) {
+	var resourceID any
+	if status != nil && status.PID > 0 {
+		resourceID = status.PID
+	}
+	if err != nil {
+		logger.Audit().WarnContext(context.Background(), "audit",
+			"module", "audit",
+			"event_type", "audit",
+			"action", action,
+			"resource_type", "daemon",
+			"resource_id", resourceID,
+			"user_id", adminID,
+			"result", "failure",
+			"error", err.Error(),
+		)
+		return
+	}
+	logger.Audit().InfoContext(context.Background(), "audit",
+		"module", "audit",
+		"event_type", "audit",
+		"action", action,
+		"resource_type", "daemon",
+		"resource_id", resourceID,
+		"user_id", adminID,
+		"result", "success",
+	)
+}
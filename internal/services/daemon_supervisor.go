@@ -0,0 +1,484 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonLogTailBytes - сколько байт с конца лога читается для последних строк.
+const daemonLogTailBytes = 64 << 10
+
+// ErrDaemonNotConfigured - управление процессом-обработчиком отключено (daemon.mode пустой).
+var ErrDaemonNotConfigured = errors.New("daemon is not configured")
+
+// DaemonProcess - процесс-обработчик и его дочерние процессы.
+type DaemonProcess struct {
+	PID   int              `json:"pid"`
+	Name  string           `json:"name"`
+	Child []*DaemonProcess `json:"child"`
+}
+
+// DaemonStatus - состояние процесса-обработчика.
+type DaemonStatus struct {
+	Running       bool           `json:"running"`
+	PID           int            `json:"pid"`
+	StartedAt     *time.Time     `json:"started_at"`
+	LastHeartbeat *time.Time     `json:"last_heartbeat"`
+	Logs          []string       `json:"logs"`
+	Processes     *DaemonProcess `json:"processes"`
+}
+
+// Uptime возвращает время работы процесса (0 - не запущен или время старта неизвестно).
+func (s *DaemonStatus) Uptime(now time.Time) time.Duration {
+	if !s.Running || s.StartedAt == nil {
+		return 0
+	}
+	return now.Sub(*s.StartedAt)
+}
+
+// DaemonSupervisor запускает, останавливает и опрашивает процесс-обработчик.
+type DaemonSupervisor interface {
+	Status(ctx context.Context) (*DaemonStatus, error)
+	Start(ctx context.Context) (*DaemonStatus, error)
+	Stop(ctx context.Context) (*DaemonStatus, error)
+}
+
+// NewDaemonSupervisor создаёт супервизор по настройкам daemon.
+func NewDaemonSupervisor(cfg config.DaemonConfig) DaemonSupervisor {
+	switch cfg.Mode {
+	case config.DaemonModeExec:
+		return &execSupervisor{cfg: cfg}
+	case config.DaemonModeHTTP:
+		return newHTTPSupervisor(cfg)
+	default:
+		return disabledSupervisor{}
+	}
+}
+
+type disabledSupervisor struct{}
+
+func (disabledSupervisor) Status(context.Context) (*DaemonStatus, error) {
+	return nil, ErrDaemonNotConfigured
+}
+
+func (disabledSupervisor) Start(context.Context) (*DaemonStatus, error) {
+	return nil, ErrDaemonNotConfigured
+}
+
+func (disabledSupervisor) Stop(context.Context) (*DaemonStatus, error) {
+	return nil, ErrDaemonNotConfigured
+}
+
+// execSupervisor запускает команду daemon.command и следит за ней по PID-файлу.
+// Процесс не завершается вместе с веб-приложением: после перезапуска он находится по PID-файлу.
+type execSupervisor struct {
+	cfg config.DaemonConfig
+	mu  sync.Mutex
+}
+
+func (s *execSupervisor) Status(ctx context.Context) (*DaemonStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status(), nil
+}
+
+func (s *execSupervisor) Start(ctx context.Context) (*DaemonStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pid := s.runningPID(); pid > 0 {
+		return s.status(), fmt.Errorf("daemon is already running (PID %d)", pid)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.LogFile), 0o755); err != nil {
+		return s.status(), fmt.Errorf("create log dir: %w", err)
+	}
+	logFile, err := os.OpenFile(s.cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return s.status(), fmt.Errorf("open log file: %w", err)
+	}
+
+	cmd := exec.Command(s.cfg.Command, s.cfg.Args...)
+	cmd.Dir = s.cfg.WorkDir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// Своя группа процессов: Stop завершает обработчик вместе с дочерними процессами.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return s.status(), fmt.Errorf("start daemon: %w", err)
+	}
+	pid := cmd.Process.Pid
+	if err := os.WriteFile(s.cfg.PIDFile, []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		logFile.Close()
+		return s.status(), fmt.Errorf("write pid file: %w", err)
+	}
+
+	// Ожидание завершения убирает зомби-процесс и устаревший PID-файл.
+	go func() {
+		err := cmd.Wait()
+		logFile.Close()
+		s.mu.Lock()
+		if readPIDFile(s.cfg.PIDFile) == pid {
+			_ = os.Remove(s.cfg.PIDFile)
+		}
+		s.mu.Unlock()
+		if err != nil {
+			logger.Warn().Int("pid", pid).Err(err).Msg("Daemon exited")
+		} else {
+			logger.Info().Int("pid", pid).Msg("Daemon exited")
+		}
+	}()
+
+	logger.Info().Int("pid", pid).Str("command", s.cfg.Command).Msg("Daemon started")
+	return s.status(), nil
+}
+
+func (s *execSupervisor) Stop(ctx context.Context) (*DaemonStatus, error) {
+	s.mu.Lock()
+	pid := s.runningPID()
+	s.mu.Unlock()
+	if pid == 0 {
+		return s.Status(ctx)
+	}
+
+	// Сигнал получает вся группа процессов обработчика, иначе дочерние процессы остаются
+	// сиротами. Если обработчик не лидер своей группы, сигнал идёт только ему: группа
+	// может оказаться группой веб-приложения.
+	target := pid
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		target = -pgid
+	}
+	if err := syscall.Kill(target, syscall.SIGTERM); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return s.Status(ctx)
+		}
+		return nil, fmt.Errorf("stop daemon: %w", err)
+	}
+
+	deadline := time.Now().Add(s.cfg.StopTimeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			logger.Warn().Int("pid", pid).Msg("Daemon did not stop in time, killing")
+			_ = syscall.Kill(target, syscall.SIGKILL)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	s.mu.Lock()
+	if readPIDFile(s.cfg.PIDFile) == pid {
+		_ = os.Remove(s.cfg.PIDFile)
+	}
+	s.mu.Unlock()
+	return s.Status(ctx)
+}
+
+// runningPID возвращает PID из PID-файла, если процесс жив и это та же команда (0 - не запущен).
+func (s *execSupervisor) runningPID() int {
+	pid := readPIDFile(s.cfg.PIDFile)
+	if pid <= 0 || !processAlive(pid) {
+		return 0
+	}
+	// PID мог достаться другому процессу после перезагрузки сервера.
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		name := filepath.Base(s.cfg.Command)
+		if len(name) > 15 {
+			name = name[:15]
+		}
+		if strings.TrimSpace(string(comm)) != name {
+			return 0
+		}
+	}
+	return pid
+}
+
+func (s *execSupervisor) status() *DaemonStatus {
+	status := &DaemonStatus{PID: s.runningPID()}
+	status.Running = status.PID > 0
+	if status.Running {
+		if info, err := os.Stat(s.cfg.PIDFile); err == nil {
+			started := info.ModTime()
+			status.StartedAt = &started
+		}
+		status.Processes = processTree(status.PID)
+	}
+
+	heartbeatFile := s.cfg.HeartbeatFile
+	if heartbeatFile == "" {
+		heartbeatFile = s.cfg.LogFile
+	}
+	if info, err := os.Stat(heartbeatFile); err == nil {
+		heartbeat := info.ModTime()
+		status.LastHeartbeat = &heartbeat
+	}
+
+	if data, err := readFileTail(s.cfg.LogFile, daemonLogTailBytes); err == nil {
+		status.Logs = tailLines(data, s.cfg.LogLines)
+	}
+	return status
+}
+
+// readPIDFile читает PID из файла (0 - файла нет или он повреждён).
+func readPIDFile(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// processAlive проверяет, существует ли процесс (сигнал 0).
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+func readFileTail(path string, size int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - size
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+	// Первая строка прочитана не с начала и может быть обрезана.
+	if offset > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return data, nil
+}
+
+// tailLines возвращает последние n непустых строк.
+func tailLines(data []byte, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
+
+// parseProcStat разбирает /proc/<pid>/stat: PID, имя процесса и PID родителя.
+// Имя в скобках может содержать пробелы и скобки, поэтому ищется последняя ")".
+func parseProcStat(line string) (int, string, int, bool) {
+	open := strings.IndexByte(line, '(')
+	closing := strings.LastIndexByte(line, ')')
+	if open <= 0 || closing < open {
+		return 0, "", 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return 0, "", 0, false
+	}
+	fields := strings.Fields(line[closing+1:])
+	if len(fields) < 2 {
+		return 0, "", 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, "", 0, false
+	}
+	return pid, line[open+1 : closing], ppid, true
+}
+
+// processTree строит дерево процессов с корнем root по /proc.
+// Там, где /proc нет, возвращается только сам процесс.
+func processTree(root int) *DaemonProcess {
+	nodes := map[int]*DaemonProcess{root: {PID: root, Child: []*DaemonProcess{}}}
+	parents := map[int]int{}
+	entries, _ := os.ReadDir("/proc")
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		pid, name, ppid, ok := parseProcStat(string(data))
+		if !ok {
+			continue
+		}
+		if node, exists := nodes[pid]; exists {
+			node.Name = name
+		} else {
+			nodes[pid] = &DaemonProcess{PID: pid, Name: name, Child: []*DaemonProcess{}}
+		}
+		parents[pid] = ppid
+	}
+
+	pids := make([]int, 0, len(parents))
+	for pid := range parents {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	for _, pid := range pids {
+		if pid == root {
+			continue
+		}
+		if parent, ok := nodes[parents[pid]]; ok && descendsFrom(parents, pid, root) {
+			parent.Child = append(parent.Child, nodes[pid])
+		}
+	}
+	return nodes[root]
+}
+
+func descendsFrom(parents map[int]int, pid, root int) bool {
+	for i := 0; i < 64 && pid > 1; i++ {
+		pid = parents[pid]
+		if pid == root {
+			return true
+		}
+	}
+	return false
+}
+
+// DaemonDiagram строит диаграмму mermaid по дереву процессов для страницы /daemon/.
+func DaemonDiagram(root *DaemonProcess) string {
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	if root == nil {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "    %s\n", daemonNode(root))
+	var walk func(p *DaemonProcess)
+	walk = func(p *DaemonProcess) {
+		for _, child := range p.Child {
+			fmt.Fprintf(&b, "    p%d --> %s\n", p.PID, daemonNode(child))
+			walk(child)
+		}
+	}
+	walk(root)
+	return b.String()
+}
+
+func daemonNode(p *DaemonProcess) string {
+	name := p.Name
+	if name == "" {
+		name = "process"
+	}
+	name = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(name)
+	return fmt.Sprintf(`p%d["%s<br/>PID %d"]`, p.PID, name, p.PID)
+}
+
+// httpSupervisor управляет процессом, запущенным отдельно (systemd, docker),
+// через его control endpoint: GET /status, POST /start, POST /stop.
+// Каждый запрос возвращает DaemonStatus в JSON, ошибка - {"error": "..."} с кодом 4xx/5xx.
+type httpSupervisor struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newHTTPSupervisor(cfg config.DaemonConfig) *httpSupervisor {
+	s := &httpSupervisor{
+		baseURL: strings.TrimRight(cfg.ControlURL, "/"),
+		client:  &http.Client{Timeout: cfg.StopTimeout + 5*time.Second},
+	}
+	if socket, ok := strings.CutPrefix(cfg.ControlURL, "unix://"); ok {
+		// Хост в URL не используется: соединение всегда идёт в сокет.
+		s.baseURL = "http://daemon"
+		s.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	return s
+}
+
+func (s *httpSupervisor) Status(ctx context.Context) (*DaemonStatus, error) {
+	return s.call(ctx, http.MethodGet, "/status")
+}
+
+func (s *httpSupervisor) Start(ctx context.Context) (*DaemonStatus, error) {
+	return s.call(ctx, http.MethodPost, "/start")
+}
+
+func (s *httpSupervisor) Stop(ctx context.Context) (*DaemonStatus, error) {
+	return s.call(ctx, http.MethodPost, "/stop")
+}
+
+func (s *httpSupervisor) call(ctx context.Context, method, path string) (*DaemonStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("daemon control endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return nil, errors.New(failure.Error)
+		}
+		return nil, fmt.Errorf("daemon control endpoint: HTTP %d", resp.StatusCode)
+	}
+	var status DaemonStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("daemon control endpoint: %w", err)
+	}
+	return &status, nil
+}
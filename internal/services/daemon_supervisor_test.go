@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailLines(t *testing.T) {
+	data := []byte("one\r\ntwo\n\nthree\nfour\n")
+	got := tailLines(data, 2)
+	if strings.Join(got, "|") != "three|four" {
+		t.Fatalf("unexpected tail: %q", got)
+	}
+	if got := tailLines(data, 10); len(got) != 4 || got[0] != "one" {
+		t.Fatalf("unexpected full tail: %q", got)
+	}
+	if got := tailLines(data, 0); got != nil {
+		t.Fatalf("expected nil for n=0, got %q", got)
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	pid, name, ppid, ok := parseProcStat("4242 (ct (worker) 1) S 4200 4242 4200 0 -1 4194560")
+	if !ok || pid != 4242 || name != "ct (worker) 1" || ppid != 4200 {
+		t.Fatalf("unexpected parse: %d %q %d %v", pid, name, ppid, ok)
+	}
+	if _, _, _, ok := parseProcStat("garbage"); ok {
+		t.Fatal("expected parse failure")
+	}
+}
+
+func TestDaemonDiagram(t *testing.T) {
+	root := &DaemonProcess{PID: 10, Name: "ct-daemon", Child: []*DaemonProcess{
+		{PID: 11, Name: `sh "x"`, Child: []*DaemonProcess{{PID: 12, Name: "curl"}}},
+	}}
+	want := "graph TD\n" +
+		"    p10[\"ct-daemon<br/>PID 10\"]\n" +
+		"    p10 --> p11[\"sh #quot;x#quot;<br/>PID 11\"]\n" +
+		"    p11 --> p12[\"curl<br/>PID 12\"]\n"
+	if got := DaemonDiagram(root); got != want {
+		t.Fatalf("unexpected diagram:\n%s", got)
+	}
+}
+
+func TestHTTPSupervisor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/status":
+			w.Write([]byte(`{"running":true,"pid":321,"started_at":"2024-01-01T00:00:00Z","logs":["ok"]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/start":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"already running"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	supervisor := NewDaemonSupervisor(config.DaemonConfig{Mode: config.DaemonModeHTTP, ControlURL: srv.URL + "/", StopTimeout: time.Second})
+	status, err := supervisor.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.Running || status.PID != 321 || len(status.Logs) != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	uptime := status.Uptime(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC))
+	if uptime != time.Hour {
+		t.Fatalf("unexpected uptime: %v", uptime)
+	}
+
+	if _, err := supervisor.Start(context.Background()); err == nil || err.Error() != "already running" {
+		t.Fatalf("expected control endpoint error, got %v", err)
+	}
+	if _, err := supervisor.Stop(context.Background()); err == nil {
+		t.Fatal("expected error for HTTP 404")
+	}
+}
+
+func TestExecSupervisorStopsProcessGroup(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc")
+	}
+	dir := t.TempDir()
+	supervisor := NewDaemonSupervisor(config.DaemonConfig{
+		Mode:        config.DaemonModeExec,
+		Command:     "/bin/sh",
+		Args:        []string{"-c", "sleep 30 & sleep 30"},
+		PIDFile:     filepath.Join(dir, "daemon.pid"),
+		LogFile:     filepath.Join(dir, "daemon.log"),
+		StopTimeout: 5 * time.Second,
+	})
+	status, err := supervisor.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !status.Running {
+		t.Fatalf("daemon is not running: %+v", status)
+	}
+
+	var children []int
+	for deadline := time.Now().Add(2 * time.Second); len(children) < 2 && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
+		children = children[:0]
+		for _, child := range processTree(status.PID).Child {
+			children = append(children, child.PID)
+		}
+	}
+	if len(children) < 2 {
+		t.Fatalf("expected two child processes, got %v", children)
+	}
+
+	status, err = supervisor.Stop(context.Background())
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if status.Running {
+		t.Fatalf("daemon is still running: %+v", status)
+	}
+	// Дочерние процессы завершаются вместе с группой (зомби у init не в счёт).
+	for _, pid := range children {
+		for deadline := time.Now().Add(2 * time.Second); ; {
+			data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+			if err != nil || strings.Contains(string(data), ") Z ") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("child %d survived Stop", pid)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
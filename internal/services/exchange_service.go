@@ -2,8 +2,11 @@
 package services
 
 import (
+	"context"
+	"ctweb/internal/logger"
 	"ctweb/internal/models"
 	"ctweb/internal/repositories"
+	"ctweb/internal/secrets"
 	"ctweb/internal/utils"
 	"errors"
 	"fmt"
@@ -193,6 +196,17 @@ func (s *ExchangeService) ValidateExchangeAccount(accountName, status string, pr
 	return active, nil
 }
 
+// validateAccountKeys отклоняет ключи с префиксом зашифрованного значения:
+// такой ключ нельзя отличить от шифротекста.
+func validateAccountKeys(keys ...string) error {
+	for _, key := range keys {
+		if secrets.IsEncrypted(strings.TrimSpace(key)) {
+			return errors.New("api keys must not start with \"enc:\" prefix")
+		}
+	}
+	return nil
+}
+
 // EnsureExchangeAccountNameUnique проверяет уникальность имени аккаунта в рамках пользователя и биржи.
 func (s *ExchangeService) EnsureExchangeAccountNameUnique(userID, exchangeID int, name string, excludeID *int) error {
 	if excludeID == nil {
@@ -216,12 +230,18 @@ func (s *ExchangeService) EnsureExchangeAccountNameUnique(userID, exchangeID int
 	return nil
 }
 
-// CreateExchangeAccount валидирует и создаёт аккаунт.
-func (s *ExchangeService) CreateExchangeAccount(userID, exchangeID int, accountName, status string, priority int, apiKey, secretKey, addKey, note string) (int, error) {
+// CreateExchangeAccount валидирует и создаёт аккаунт. Права ключа проверяются на бирже
+// по политике security.withdraw_key_policy, результат проверки сохраняется в аккаунте.
+//
+// parentID - мастер-аккаунт (nil - мастер или самостоятельный аккаунт), subLabel - имя субаккаунта на бирже.
+func (s *ExchangeService) CreateExchangeAccount(ctx context.Context, userID, exchangeID int, accountName, status string, priority int, apiKey, secretKey, addKey, note string, parentID *int, subLabel string) (int, error) {
 	active, err := s.ValidateExchangeAccount(accountName, status, priority, apiKey)
 	if err != nil {
 		return 0, err
 	}
+	if err := validateAccountKeys(apiKey, secretKey, addKey); err != nil {
+		return 0, err
+	}
 	if err := s.EnsureExchangeAccountNameUnique(userID, exchangeID, accountName, nil); err != nil {
 		return 0, err
 	}
@@ -245,16 +265,84 @@ func (s *ExchangeService) CreateExchangeAccount(userID, exchangeID int, accountN
 	if trimmedNote != "" {
 		acc.Note = &trimmedNote
 	}
+	if err := s.setAccountParent(acc, parentID, subLabel); err != nil {
+		return 0, err
+	}
+
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return 0, fmt.Errorf("exchange not found: %w", err)
+	}
+	check, err := enforceKeyPolicy(ctx, withdrawKeyPolicy(), exchange, userID, 0, acc)
+	if err != nil {
+		return 0, err
+	}
 
-	return s.accountRepo.Create(acc)
+	id, err := s.accountRepo.Create(acc)
+	if err != nil {
+		return 0, err
+	}
+	s.saveKeyCheck(id, userID, check)
+	return id, nil
 }
 
-// UpdateExchangeAccount валидирует и обновляет аккаунт.
-func (s *ExchangeService) UpdateExchangeAccount(id, userID, exchangeID int, accountName, status string, priority int, apiKey, secretKey, addKey, note string) error {
+// setAccountParent проверяет мастер-аккаунт parentID и задаёт аккаунту иерархию.
+// Имя субаккаунта хранится только у субаккаунтов.
+func (s *ExchangeService) setAccountParent(acc *models.ExchangeAccount, parentID *int, subLabel string) error {
+	if parentID == nil {
+		return nil
+	}
+	parent, err := s.accountRepo.FindByID(*parentID, acc.UID)
+	if err != nil {
+		return errors.New("master account not found")
+	}
+	hasSubAccounts := false
+	if acc.ID != 0 {
+		count, err := s.accountRepo.CountSubAccounts(acc.ID, acc.UID)
+		if err != nil {
+			return err
+		}
+		hasSubAccounts = count > 0
+	}
+	if err := ValidateAccountParent(acc, parent, hasSubAccounts); err != nil {
+		return err
+	}
+	subLabel = strings.TrimSpace(subLabel)
+	if len([]rune(subLabel)) > 64 {
+		return errors.New("sub-account label must be at most 64 characters")
+	}
+	acc.ParentID = &parent.ID
+	acc.SubAccountLabel = subLabel
+	return nil
+}
+
+// saveKeyCheck сохраняет результат проверки ключа; ошибка не отменяет сохранение аккаунта.
+func (s *ExchangeService) saveKeyCheck(accountID, userID int, check *models.AccountKeyCheck) {
+	if check == nil {
+		return
+	}
+	if err := s.accountRepo.SaveKeyCheck(accountID, userID, check); err != nil {
+		logger.Warn().Err(err).Int("account_id", accountID).Msg("failed to save key check")
+	}
+}
+
+// UpdateExchangeAccount валидирует и обновляет аккаунт. Пустые ApiKey/SecretKey/AddKey не меняются,
+// поэтому права ключа проверяются с ключами, сохранёнными ранее.
+func (s *ExchangeService) UpdateExchangeAccount(ctx context.Context, id, userID, exchangeID int, accountName, status string, priority int, apiKey, secretKey, addKey, note string, parentID *int, subLabel string) error {
+	current, err := s.accountRepo.FindByID(id, userID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(apiKey) == "" {
+		apiKey = current.ApiKey
+	}
 	active, err := s.ValidateExchangeAccount(accountName, status, priority, apiKey)
 	if err != nil {
 		return err
 	}
+	if err := validateAccountKeys(apiKey, secretKey, addKey); err != nil {
+		return err
+	}
 	if err := s.EnsureExchangeAccountNameUnique(userID, exchangeID, accountName, &id); err != nil {
 		return err
 	}
@@ -279,8 +367,32 @@ func (s *ExchangeService) UpdateExchangeAccount(id, userID, exchangeID int, acco
 	if trimmedNote != "" {
 		acc.Note = &trimmedNote
 	}
+	if err := s.setAccountParent(acc, parentID, subLabel); err != nil {
+		return err
+	}
+
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return fmt.Errorf("exchange not found: %w", err)
+	}
+	probe := *acc
+	if probe.SecretKey == "" {
+		probe.SecretKey = current.SecretKey
+	}
+	if probe.AddKey == nil {
+		probe.AddKey = current.AddKey
+	}
+	check, err := enforceKeyPolicy(ctx, withdrawKeyPolicy(), exchange, userID, id, &probe)
+	if err != nil {
+		return err
+	}
+	acc.Active = probe.Active
 
-	return s.accountRepo.Update(acc)
+	if err := s.accountRepo.Update(acc); err != nil {
+		return err
+	}
+	s.saveKeyCheck(id, userID, check)
+	return nil
 }
 
 // SoftDeleteExchangeAccount помечает аккаунт удалённым.
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"ctweb/internal/connectors"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Допустимый диапазон ставок в процентах. Отрицательный maker - ребейт.
+const (
+	minFeePercent = -1.0
+	maxFeePercent = 10.0
+)
+
+// FeeService - комиссии бирж по умолчанию, VIP-переопределения аккаунтов
+// и оценки комиссий для позиций.
+type FeeService struct {
+	repo *repositories.FeeRepository
+}
+
+// NewFeeService создаёт сервис комиссий.
+func NewFeeService() *FeeService {
+	return &FeeService{repo: repositories.NewFeeRepository()}
+}
+
+func parseFeePercent(field, raw string) (float64, error) {
+	value := strings.TrimSpace(strings.ReplaceAll(raw, ",", "."))
+	value = strings.TrimSuffix(value, "%")
+	if value == "" {
+		return 0, fmt.Errorf("%s fee is empty", field)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%s fee is not a number", field)
+	}
+	if f < minFeePercent || f > maxFeePercent {
+		return 0, fmt.Errorf("%s fee must be between %.0f%% and %.0f%%", field, minFeePercent, maxFeePercent)
+	}
+	return f, nil
+}
+
+// GetExchangeFees возвращает ставки биржи для SPOT и FUTURES (незаданные - нули).
+func (s *FeeService) GetExchangeFees(exchangeID int) (map[string]*models.FeeSchedule, error) {
+	items, err := s.repo.FindExchangeFees(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]*models.FeeSchedule{
+		connectors.MarketSpot:    {ExID: exchangeID, MarketType: connectors.MarketSpot},
+		connectors.MarketFutures: {ExID: exchangeID, MarketType: connectors.MarketFutures},
+	}
+	for _, item := range items {
+		result[item.MarketType] = item
+	}
+	return result, nil
+}
+
+// SaveExchangeFee сохраняет ставку биржи по умолчанию.
+func (s *FeeService) SaveExchangeFee(exchangeID int, market, maker, taker string, userID int) error {
+	if exchangeID <= 0 {
+		return fmt.Errorf("invalid exchange id")
+	}
+	makerFee, err := parseFeePercent("maker", maker)
+	if err != nil {
+		return err
+	}
+	takerFee, err := parseFeePercent("taker", taker)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpsertExchangeFee(&models.FeeSchedule{
+		ExID:       exchangeID,
+		MarketType: connectors.NormalizeMarket(market),
+		MakerFee:   makerFee,
+		TakerFee:   takerFee,
+	}, userID)
+}
+
+// GetAccountFees возвращает VIP-переопределения аккаунта.
+func (s *FeeService) GetAccountFees(accountID int) ([]*models.FeeSchedule, error) {
+	return s.repo.FindAccountFees(accountID)
+}
+
+// SaveAccountFee сохраняет VIP-переопределение аккаунта. Пустые maker и taker удаляют переопределение.
+func (s *FeeService) SaveAccountFee(accountID int, market, vipTier, maker, taker string, userID int) error {
+	exchangeID, err := s.repo.FindAccountExchangeID(accountID)
+	if err != nil {
+		return err
+	}
+	market = connectors.NormalizeMarket(market)
+	if strings.TrimSpace(maker) == "" && strings.TrimSpace(taker) == "" {
+		return s.repo.DeleteAccountFee(accountID, market)
+	}
+
+	makerFee, err := parseFeePercent("maker", maker)
+	if err != nil {
+		return err
+	}
+	takerFee, err := parseFeePercent("taker", taker)
+	if err != nil {
+		return err
+	}
+	vipTier = strings.TrimSpace(vipTier)
+	if len(vipTier) > 32 {
+		return fmt.Errorf("vip tier is too long")
+	}
+
+	return s.repo.UpsertAccountFee(&models.FeeSchedule{
+		ExID:       exchangeID,
+		AccountID:  &accountID,
+		MarketType: market,
+		VIPTier:    vipTier,
+		MakerFee:   makerFee,
+		TakerFee:   takerFee,
+	}, userID)
+}
+
+// ResolveFee возвращает действующую для пользователя ставку: VIP-переопределение
+// аккаунта accountID (аккаунт позиции), а без него - аккаунта пользователя на бирже
+// с наибольшим приоритетом, иначе ставку биржи по умолчанию. nil - ставка не задана.
+func (s *FeeService) ResolveFee(userID, exchangeID int, accountID *int, market string) (*models.FeeSchedule, error) {
+	market = connectors.NormalizeMarket(market)
+	var fee *models.FeeSchedule
+	var err error
+	if accountID != nil {
+		fee, err = s.repo.FindLinkedAccountFee(userID, exchangeID, *accountID, market)
+	} else {
+		fee, err = s.repo.FindUserAccountFee(userID, exchangeID, market)
+	}
+	if err != nil || fee != nil {
+		return fee, err
+	}
+	return s.repo.FindExchangeFee(exchangeID, market)
+}
+
+// EstimateTradeFee оценивает комиссию сделки по ставке rate (в долях).
+//
+// Для покупки на споте комиссия списывается в базовой валюте (FEE_BASE),
+// в остальных случаях - в котируемой (FEE), как в ручном вводе транзакций.
+func EstimateTradeFee(market, action string, price, volume, rate float64) (feeQuote, feeBase float64) {
+	volume = math.Abs(volume)
+	if connectors.NormalizeMarket(market) == connectors.MarketSpot && strings.EqualFold(action, "buy") {
+		return 0, volume * rate
+	}
+	return math.Abs(price) * volume * rate, 0
+}
+
+// BreakEvenPrice возвращает цену, при закрытии по которой (с комиссией rate)
+// позиция выходит в ноль. avgPrice уже учитывает комиссии входа и funding.
+// Возвращает 0, если позиция пустая.
+func BreakEvenPrice(position, avgPrice, rate float64) float64 {
+	if position == 0 || avgPrice == 0 {
+		return 0
+	}
+	// long:  (B - A) * q - rate * q * B = 0  =>  B = A / (1 - rate)
+	// short: (A - B) * q - rate * q * B = 0  =>  B = A / (1 + rate)
+	denominator := 1 - rate
+	if position < 0 {
+		denominator = 1 + rate
+	}
+	if denominator <= 0 {
+		return 0
+	}
+	return avgPrice / denominator
+}
+
+// ExitCost оценивает комиссию закрытия позиции по цене price.
+func ExitCost(position, price, rate float64) float64 {
+	return math.Abs(position) * math.Abs(price) * rate
+}
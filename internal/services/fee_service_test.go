@@ -0,0 +1,56 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestBreakEvenPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		position float64
+		avg      float64
+		rate     float64
+		want     float64
+	}{
+		{name: "long", position: 2, avg: 100, rate: 0.001, want: 100 / 0.999},
+		{name: "short", position: -2, avg: 100, rate: 0.001, want: 100 / 1.001},
+		{name: "zero fee", position: 1, avg: 100, rate: 0, want: 100},
+		{name: "empty position", position: 0, avg: 100, rate: 0.001, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BreakEvenPrice(tt.position, tt.avg, tt.rate)
+			if !almostEqual(got, tt.want) {
+				t.Fatalf("BreakEvenPrice() = %v, want %v", got, tt.want)
+			}
+			if tt.position == 0 {
+				return
+			}
+			// Закрытие по безубыточной цене с комиссией должно давать нулевой PnL.
+			pnl := (got-tt.avg)*tt.position - ExitCost(tt.position, got, tt.rate)
+			if !almostEqual(pnl, 0) {
+				t.Fatalf("pnl at break-even = %v, want 0", pnl)
+			}
+		})
+	}
+}
+
+func TestEstimateTradeFee(t *testing.T) {
+	quote, base := EstimateTradeFee("SPOT", "buy", 100, 2, 0.001)
+	if quote != 0 || !almostEqual(base, 0.002) {
+		t.Fatalf("spot buy fee = %v/%v, want 0/0.002", quote, base)
+	}
+	quote, base = EstimateTradeFee("SPOT", "sell", 100, -2, 0.001)
+	if !almostEqual(quote, 0.2) || base != 0 {
+		t.Fatalf("spot sell fee = %v/%v, want 0.2/0", quote, base)
+	}
+	quote, base = EstimateTradeFee("FUTURES", "buy", 100, 2, 0.0005)
+	if !almostEqual(quote, 0.1) || base != 0 {
+		t.Fatalf("futures fee = %v/%v, want 0.1/0", quote, base)
+	}
+}
@@ -0,0 +1,546 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fundingScanMaxPairs - сколько пар BASE/QUOTE опрашивается за одно сканирование.
+	fundingScanMaxPairs = 50
+	// fundingScanWorkers - сколько прогнозов ставок запрашивается с бирж одновременно.
+	fundingScanWorkers = 8
+	// fundingScanResults - сколько лучших связок отдаётся на страницу.
+	fundingScanResults = 100
+	// fundingScanMaxErrors - сколько ошибок бирж показывается под результатами.
+	fundingScanMaxErrors = 20
+	// fundingBasePeriod - к какому периоду приводятся ставки разных бирж для сравнения.
+	fundingBasePeriod = 8 * time.Hour
+	// defaultFundingHorizon - срок удержания связки, на который раскладываются комиссии.
+	defaultFundingHorizon = 7 * 24 * time.Hour
+)
+
+// FundingQuote - инструмент одной биржи в сканировании: текущая (прогнозная) ставка
+// финансирования и taker-комиссия пользователя. Для SPOT ставка нулевая.
+type FundingQuote struct {
+	ExchangeID      int           `json:"exchange_id"`
+	Exchange        string        `json:"exchange"`
+	Market          string        `json:"market"`
+	Symbol          string        `json:"symbol"`
+	Base            string        `json:"base"`
+	Quote           string        `json:"quote"`
+	Rate            float64       `json:"rate"`     // Ставка за период Interval, в долях
+	Interval        time.Duration `json:"interval"` // Период между расчётами
+	NextFundingTime time.Time     `json:"next_funding_time"`
+	MarkPrice       float64       `json:"mark_price"`
+	TakerFee        float64       `json:"taker_fee"`
+}
+
+// Rate8h приводит ставку к периоду fundingBasePeriod: биржи считают funding
+// раз в 1, 4 или 8 часов, и сравнивать ставки напрямую нельзя.
+func (q FundingQuote) Rate8h() float64 {
+	if q.Market != connectors.MarketFutures {
+		return 0
+	}
+	interval := q.Interval
+	if interval <= 0 {
+		interval = connectors.DefaultFundingInterval
+	}
+	return q.Rate * float64(fundingBasePeriod) / float64(interval)
+}
+
+// FundingOpportunity - связка для carry-сделки: лонг ноги Long и шорт ноги Short.
+// Differential, Fees и Net - в долях от стоимости одной ноги.
+type FundingOpportunity struct {
+	Strategy       string       `json:"strategy"`
+	Pair           string       `json:"pair"`
+	Long           FundingQuote `json:"long"`
+	Short          FundingQuote `json:"short"`
+	Differential   float64      `json:"differential"`     // Доход от funding за 8 часов
+	APR            float64      `json:"apr"`              // Годовая доходность без комиссий, в процентах
+	Fees           float64      `json:"fees"`             // Вход и выход обеих ног по taker-ставкам
+	Net            float64      `json:"net"`              // Доход за горизонт удержания за вычетом комиссий
+	BreakEvenHours float64      `json:"break_even_hours"` // Через сколько часов funding окупит комиссии
+}
+
+// newFundingOpportunity считает доходность связки на горизонте horizon.
+func newFundingOpportunity(strategy string, long, short FundingQuote, horizon time.Duration) FundingOpportunity {
+	diff := short.Rate8h() - long.Rate8h()
+	fees := 2 * (long.TakerFee + short.TakerFee)
+	periods := float64(horizon) / float64(fundingBasePeriod)
+	periodsPerYear := float64(365*24*time.Hour) / float64(fundingBasePeriod)
+
+	item := FundingOpportunity{
+		Strategy:     strategy,
+		Pair:         models.PairName(long.Base, long.Quote),
+		Long:         long,
+		Short:        short,
+		Differential: diff,
+		APR:          diff * periodsPerYear * 100,
+		Fees:         fees,
+		Net:          diff*periods - fees,
+	}
+	if diff > 0 {
+		item.BreakEvenHours = fees / diff * fundingBasePeriod.Hours()
+	}
+	return item
+}
+
+// RankFundingArbitrage строит связки по котировкам и сортирует их по чистому доходу
+// за горизонт удержания horizon (limit <= 0 - без ограничения).
+//
+// FUTURES+FUTURES: одна пара на двух биржах, лонг там, где ставка ниже, шорт - где выше.
+// SPOT+FUTURES: покупка на споте и шорт perpetual с положительной ставкой
+// (шорты получают funding). Связки без положительной разницы ставок не возвращаются.
+func RankFundingArbitrage(quotes []FundingQuote, horizon time.Duration, limit int) []FundingOpportunity {
+	if horizon <= 0 {
+		horizon = defaultFundingHorizon
+	}
+
+	type pairQuotes struct {
+		futures []FundingQuote
+		spot    []FundingQuote
+	}
+	byPair := make(map[string]*pairQuotes)
+	for _, q := range quotes {
+		key := models.PairName(q.Base, q.Quote)
+		if byPair[key] == nil {
+			byPair[key] = &pairQuotes{}
+		}
+		if q.Market == connectors.MarketFutures {
+			byPair[key].futures = append(byPair[key].futures, q)
+		} else {
+			byPair[key].spot = append(byPair[key].spot, q)
+		}
+	}
+
+	result := make([]FundingOpportunity, 0)
+	for _, pq := range byPair {
+		for i := 0; i < len(pq.futures); i++ {
+			for j := i + 1; j < len(pq.futures); j++ {
+				a, b := pq.futures[i], pq.futures[j]
+				if a.ExchangeID == b.ExchangeID {
+					continue
+				}
+				if a.Rate8h() > b.Rate8h() {
+					a, b = b, a
+				}
+				if b.Rate8h()-a.Rate8h() <= 0 {
+					continue
+				}
+				result = append(result, newFundingOpportunity(models.GroupStrategyFuturesFutures, a, b, horizon))
+			}
+		}
+		for _, perp := range pq.futures {
+			if perp.Rate8h() <= 0 {
+				continue
+			}
+			for _, spot := range pq.spot {
+				result = append(result, newFundingOpportunity(models.GroupStrategySpotFutures, spot, perp, horizon))
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Net != result[j].Net {
+			return result[i].Net > result[j].Net
+		}
+		if result[i].Differential != result[j].Differential {
+			return result[i].Differential > result[j].Differential
+		}
+		if result[i].Pair != result[j].Pair {
+			return result[i].Pair < result[j].Pair
+		}
+		if result[i].Long.Exchange != result[j].Long.Exchange {
+			return result[i].Long.Exchange < result[j].Long.Exchange
+		}
+		return result[i].Short.Exchange < result[j].Short.Exchange
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// FundingScanResult - результат сканирования ставок финансирования.
+type FundingScanResult struct {
+	ScannedAt     time.Time            `json:"scanned_at"`
+	Exchanges     []string             `json:"exchanges"` // Биржи с поддержкой ставок финансирования
+	Pairs         int                  `json:"pairs"`
+	Truncated     bool                 `json:"truncated"` // Пар больше fundingScanMaxPairs, опрошены первые
+	Opportunities []FundingOpportunity `json:"opportunities"`
+	Errors        []string             `json:"errors"`
+}
+
+// FundingPairRequest - связка, выбранная пользователем для открытия пары позиций.
+type FundingPairRequest struct {
+	Strategy        string
+	LongExchangeID  int
+	LongSymbol      string
+	LongAccountID   int
+	ShortExchangeID int
+	ShortSymbol     string
+	ShortAccountID  int
+	StartDate       string // В часовом поясе пользователя, пусто - текущее время
+}
+
+// FundingPairResult - группа позиций, открытая по связке, и позиции её ног.
+type FundingPairResult struct {
+	GroupID         int
+	LongPositionID  int
+	ShortPositionID int
+}
+
+// FundingArbitrageService - поиск carry-сделок по ставкам финансирования бирж,
+// подключённых через коннекторы, и открытие группы из двух позиций по выбранной связке.
+type FundingArbitrageService struct {
+	exchangeRepo *repositories.ExchangeRepository
+	instruments  *repositories.InstrumentRepository
+	groups       *repositories.PositionGroupRepository
+	positions    *PositionService
+	fees         *FeeService
+}
+
+// NewFundingArbitrageService создаёт сервис арбитража ставок финансирования.
+func NewFundingArbitrageService() *FundingArbitrageService {
+	return &FundingArbitrageService{
+		exchangeRepo: repositories.NewExchangeRepository(),
+		instruments:  repositories.NewInstrumentRepository(),
+		groups:       repositories.NewPositionGroupRepository(),
+		positions:    NewPositionService(),
+		fees:         NewFeeService(),
+	}
+}
+
+// fundingProviders возвращает коннекторы активных бирж с поддержкой ставок финансирования.
+func (s *FundingArbitrageService) fundingProviders() (map[int]connectors.FundingRateProvider, map[int]string, error) {
+	exchanges, err := s.exchangeRepo.FindAllActive()
+	if err != nil {
+		return nil, nil, err
+	}
+	providers := make(map[int]connectors.FundingRateProvider)
+	names := make(map[int]string)
+	for _, exchange := range exchanges {
+		if !exchange.Active {
+			continue
+		}
+		provider, err := fundingProvider(exchange)
+		if err != nil {
+			continue
+		}
+		providers[exchange.ID] = provider
+		names[exchange.ID] = exchange.Name
+	}
+	return providers, names, nil
+}
+
+// scanPairs возвращает пары для сканирования: заданные пользователем (через запятую)
+// или FUTURES-пары котируемой валюты quote, торгуемые хотя бы на двух биржах.
+func (s *FundingArbitrageService) scanPairs(pairsInput, quote string) ([][2]string, bool, error) {
+	result := make([][2]string, 0)
+	seen := make(map[string]bool)
+	add := func(base, quote string) {
+		key := base + "/" + quote
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, [2]string{base, quote})
+		}
+	}
+
+	if strings.TrimSpace(pairsInput) != "" {
+		for _, raw := range strings.Split(pairsInput, ",") {
+			if strings.TrimSpace(raw) == "" {
+				continue
+			}
+			base, q, ok := splitPair(raw)
+			if !ok {
+				return nil, false, fmt.Errorf("invalid trade pair %q", strings.TrimSpace(raw))
+			}
+			add(base, q)
+		}
+	} else {
+		quote = strings.ToUpper(strings.TrimSpace(quote))
+		shared, err := s.instruments.FindPairsOnMultipleExchanges(connectors.MarketFutures)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, pair := range shared {
+			base, q, ok := splitPair(pair)
+			if ok && (quote == "" || q == quote) {
+				add(base, q)
+			}
+		}
+	}
+
+	if len(result) > fundingScanMaxPairs {
+		return result[:fundingScanMaxPairs], true, nil
+	}
+	return result, false, nil
+}
+
+// takerFee возвращает taker-комиссию пользователя на рынке биржи в долях (0, если ставка не задана).
+func (s *FundingArbitrageService) takerFee(userID, exchangeID int, market string) float64 {
+	fee, err := s.fees.ResolveFee(userID, exchangeID, nil, market)
+	if err != nil {
+		logger.Warn().Int("exchange_id", exchangeID).Err(err).Msg("Resolve fee for funding arbitrage failed")
+	}
+	if fee == nil {
+		return 0
+	}
+	return fee.Rate(false)
+}
+
+// Scan запрашивает текущие ставки финансирования по парам на всех биржах с коннекторами
+// и ранжирует связки по доходу за horizon за вычетом taker-комиссий пользователя.
+// Ошибка одной биржи попадает в Errors и не прерывает сканирование.
+func (s *FundingArbitrageService) Scan(ctx context.Context, userID int, pairsInput, quote string, horizon time.Duration) (*FundingScanResult, error) {
+	providers, names, err := s.fundingProviders()
+	if err != nil {
+		return nil, err
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no exchanges with funding rate support")
+	}
+	pairs, truncated, err := s.scanPairs(pairsInput, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FundingScanResult{
+		ScannedAt:     time.Now().UTC(),
+		Exchanges:     make([]string, 0, len(names)),
+		Pairs:         len(pairs),
+		Truncated:     truncated,
+		Opportunities: []FundingOpportunity{},
+		Errors:        []string{},
+	}
+	for _, name := range names {
+		result.Exchanges = append(result.Exchanges, name)
+	}
+	sort.Strings(result.Exchanges)
+
+	fees := make(map[string]float64)
+	feeOf := func(exchangeID int, market string) float64 {
+		key := fmt.Sprintf("%d/%s", exchangeID, market)
+		if fee, ok := fees[key]; ok {
+			return fee
+		}
+		fee := s.takerFee(userID, exchangeID, market)
+		fees[key] = fee
+		return fee
+	}
+
+	quotes := make([]FundingQuote, 0)
+	perps := make([]FundingQuote, 0)
+	for _, pair := range pairs {
+		futures, err := s.instruments.FindListings(connectors.MarketFutures, pair[0], pair[1])
+		if err != nil {
+			return nil, err
+		}
+		listed := 0
+		for _, inst := range futures {
+			if _, ok := providers[inst.ExID]; !ok {
+				continue
+			}
+			listed++
+			perps = append(perps, FundingQuote{
+				ExchangeID: inst.ExID,
+				Exchange:   names[inst.ExID],
+				Market:     connectors.MarketFutures,
+				Symbol:     inst.Symbol,
+				Base:       inst.BaseAsset,
+				Quote:      inst.QuoteAsset,
+				TakerFee:   feeOf(inst.ExID, connectors.MarketFutures),
+			})
+		}
+		if listed == 0 {
+			continue
+		}
+
+		spot, err := s.instruments.FindListings(connectors.MarketSpot, pair[0], pair[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, inst := range spot {
+			quotes = append(quotes, FundingQuote{
+				ExchangeID: inst.ExID,
+				Exchange:   s.exchangeName(names, inst.ExID),
+				Market:     connectors.MarketSpot,
+				Symbol:     inst.Symbol,
+				Base:       inst.BaseAsset,
+				Quote:      inst.QuoteAsset,
+				TakerFee:   feeOf(inst.ExID, connectors.MarketSpot),
+			})
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fundingScanWorkers)
+	for i := range perps {
+		wg.Add(1)
+		go func(q *FundingQuote) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			reqCtx, cancel := context.WithTimeout(ctx, fundingRequestTimeout)
+			defer cancel()
+			forecast, err := providers[q.ExchangeID].FetchFundingForecast(reqCtx, q.Symbol)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if len(result.Errors) < fundingScanMaxErrors {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s %s: %v", q.Exchange, q.Symbol, err))
+				}
+				q.Interval = -1
+				return
+			}
+			q.Rate = forecast.Rate
+			q.Interval = forecast.Interval
+			q.NextFundingTime = forecast.NextFundingTime
+			q.MarkPrice = forecast.MarkPrice
+		}(&perps[i])
+	}
+	wg.Wait()
+
+	for _, q := range perps {
+		if q.Interval < 0 {
+			continue
+		}
+		quotes = append(quotes, q)
+	}
+	result.Opportunities = RankFundingArbitrage(quotes, horizon, fundingScanResults)
+	return result, nil
+}
+
+// exchangeName возвращает название биржи спотовой ноги: спот может торговаться и на бирже
+// без коннектора ставок финансирования.
+func (s *FundingArbitrageService) exchangeName(names map[int]string, exchangeID int) string {
+	if name, ok := names[exchangeID]; ok {
+		return name
+	}
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil || exchange == nil {
+		return fmt.Sprintf("#%d", exchangeID)
+	}
+	names[exchangeID] = exchange.Name
+	return exchange.Name
+}
+
+// currentRate8h запрашивает текущую ставку perpetual-контракта, приведённую к 8 часам.
+func (s *FundingArbitrageService) currentRate8h(ctx context.Context, exchangeID int, symbol string) (float64, error) {
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return 0, err
+	}
+	if exchange == nil {
+		return 0, fmt.Errorf("exchange not found")
+	}
+	provider, err := fundingProvider(exchange)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", exchange.Name, err)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, fundingRequestTimeout)
+	defer cancel()
+	forecast, err := provider.FetchFundingForecast(reqCtx, symbol)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", exchange.Name, err)
+	}
+	q := FundingQuote{Market: connectors.MarketFutures, Rate: forecast.Rate, Interval: forecast.Interval}
+	return q.Rate8h(), nil
+}
+
+// resolveLeg проверяет контракт и аккаунт ноги и возвращает её вместе с инструментом справочника.
+func (s *FundingArbitrageService) resolveLeg(userID, exchangeID, accountID int, market, symbol string) (repositories.PositionLeg, *models.Instrument, error) {
+	if exchangeID <= 0 {
+		return repositories.PositionLeg{}, nil, fmt.Errorf(`Filed "Exchange" is empty`)
+	}
+	if strings.TrimSpace(symbol) == "" {
+		return repositories.PositionLeg{}, nil, fmt.Errorf(`Filed "Contract Name" is empty`)
+	}
+	contract, errText := s.positions.resolveContract(exchangeID, market, symbol)
+	if errText != "" {
+		return repositories.PositionLeg{}, nil, fmt.Errorf("%s", errText)
+	}
+	account, errText := s.positions.resolveAccount(userID, exchangeID, accountID)
+	if errText != "" {
+		return repositories.PositionLeg{}, nil, fmt.Errorf("%s", errText)
+	}
+	instrument, err := s.instruments.FindBySymbol(exchangeID, market, contract)
+	if err != nil {
+		return repositories.PositionLeg{}, nil, err
+	}
+	return repositories.PositionLeg{Name: contract, ExchangeID: exchangeID, AccountID: account, Market: market}, instrument, nil
+}
+
+// CreatePair открывает по выбранной связке две позиции (лонг и шорт) одной группой.
+// Разница ставок на момент открытия запрашивается с бирж заново.
+func (s *FundingArbitrageService) CreatePair(ctx context.Context, userID int, userTimezone string, req FundingPairRequest) (*FundingPairResult, error) {
+	longMarket := connectors.MarketFutures
+	switch req.Strategy {
+	case models.GroupStrategySpotFutures:
+		longMarket = connectors.MarketSpot
+	case models.GroupStrategyFuturesFutures:
+		if req.LongExchangeID == req.ShortExchangeID {
+			return nil, fmt.Errorf("select two different exchanges")
+		}
+	default:
+		return nil, fmt.Errorf("unknown strategy")
+	}
+
+	long, longInst, err := s.resolveLeg(userID, req.LongExchangeID, req.LongAccountID, longMarket, req.LongSymbol)
+	if err != nil {
+		return nil, err
+	}
+	short, shortInst, err := s.resolveLeg(userID, req.ShortExchangeID, req.ShortAccountID, connectors.MarketFutures, req.ShortSymbol)
+	if err != nil {
+		return nil, err
+	}
+	if longInst != nil && shortInst != nil &&
+		(longInst.BaseAsset != shortInst.BaseAsset || longInst.QuoteAsset != shortInst.QuoteAsset) {
+		return nil, fmt.Errorf("legs must have the same trade pair")
+	}
+
+	createdUTC := time.Now().UTC().Truncate(time.Second)
+	if strings.TrimSpace(req.StartDate) != "" {
+		createdUTC, err = s.positions.parseDateTimeInUserTZ(req.StartDate, userTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("Error format Start Date")
+		}
+	}
+
+	diff, err := s.currentRate8h(ctx, short.ExchangeID, short.Name)
+	if err != nil {
+		return nil, err
+	}
+	if longMarket == connectors.MarketFutures {
+		longRate, err := s.currentRate8h(ctx, long.ExchangeID, long.Name)
+		if err != nil {
+			return nil, err
+		}
+		diff -= longRate
+	}
+
+	group := &models.PositionGroup{
+		UserID:    userID,
+		Name:      req.Strategy + " " + long.Name,
+		Strategy:  req.Strategy,
+		EntryDiff: &diff,
+		Created:   createdUTC,
+	}
+	groupID, positionIDs, err := s.groups.CreateWithPositions(group, []repositories.PositionLeg{long, short})
+	if err != nil {
+		return nil, err
+	}
+	return &FundingPairResult{GroupID: groupID, LongPositionID: positionIDs[0], ShortPositionID: positionIDs[1]}, nil
+}
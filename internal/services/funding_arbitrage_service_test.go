@@ -0,0 +1,90 @@
+package services
+
+import (
+	"ctweb/internal/connectors"
+	"ctweb/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFundingQuoteRate8h(t *testing.T) {
+	hourly := FundingQuote{Market: connectors.MarketFutures, Rate: 0.0001, Interval: time.Hour}
+	if got := hourly.Rate8h(); math.Abs(got-0.0008) > 1e-12 {
+		t.Fatalf("hourly rate: got %v, want 0.0008", got)
+	}
+	// Интервал не известен - считается стандартный 8-часовой.
+	unknown := FundingQuote{Market: connectors.MarketFutures, Rate: 0.0003}
+	if got := unknown.Rate8h(); math.Abs(got-0.0003) > 1e-12 {
+		t.Fatalf("default interval rate: got %v, want 0.0003", got)
+	}
+	spot := FundingQuote{Market: connectors.MarketSpot, Rate: 0.01}
+	if got := spot.Rate8h(); got != 0 {
+		t.Fatalf("spot rate must be 0, got %v", got)
+	}
+}
+
+func TestRankFundingArbitrage(t *testing.T) {
+	quotes := []FundingQuote{
+		{ExchangeID: 1, Exchange: "Binance", Market: connectors.MarketFutures, Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT",
+			Rate: 0.0001, Interval: 8 * time.Hour, TakerFee: 0.0005},
+		{ExchangeID: 2, Exchange: "Bybit", Market: connectors.MarketFutures, Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT",
+			Rate: 0.0001, Interval: time.Hour, TakerFee: 0.0005},
+		{ExchangeID: 1, Exchange: "Binance", Market: connectors.MarketSpot, Symbol: "BTCUSDT", Base: "BTC", Quote: "USDT",
+			TakerFee: 0.001},
+		// Отрицательная ставка: SPOT+FUTURES не строится, FUTURES+FUTURES - только с другой биржей.
+		{ExchangeID: 1, Exchange: "Binance", Market: connectors.MarketFutures, Symbol: "ETHUSDT", Base: "ETH", Quote: "USDT",
+			Rate: -0.0002, Interval: 8 * time.Hour},
+		{ExchangeID: 1, Exchange: "Binance", Market: connectors.MarketSpot, Symbol: "ETHUSDT", Base: "ETH", Quote: "USDT"},
+	}
+
+	result := RankFundingArbitrage(quotes, 24*time.Hour, 0)
+	if len(result) != 3 {
+		t.Fatalf("expected 3 opportunities, got %d: %+v", len(result), result)
+	}
+
+	// Bybit платит 0.0008 за 8 часов против 0.0001 на Binance: шорт Bybit, лонг Binance.
+	best := result[0]
+	if best.Strategy != models.GroupStrategyFuturesFutures || best.Long.Exchange != "Binance" || best.Short.Exchange != "Bybit" {
+		t.Fatalf("unexpected best opportunity: %+v", best)
+	}
+	if math.Abs(best.Differential-0.0007) > 1e-12 || math.Abs(best.Fees-0.002) > 1e-12 {
+		t.Fatalf("unexpected differential/fees: %+v", best)
+	}
+	if math.Abs(best.Net-(0.0007*3-0.002)) > 1e-12 {
+		t.Fatalf("unexpected net: %v", best.Net)
+	}
+	if math.Abs(best.APR-0.0007*3*365*100) > 1e-9 {
+		t.Fatalf("unexpected apr: %v", best.APR)
+	}
+	if math.Abs(best.BreakEvenHours-0.002/0.0007*8) > 1e-9 {
+		t.Fatalf("unexpected break-even: %v", best.BreakEvenHours)
+	}
+
+	// Спот Binance + шорт perpetual: Bybit выгоднее Binance.
+	if result[1].Strategy != models.GroupStrategySpotFutures || result[1].Short.Exchange != "Bybit" || result[1].Long.Market != connectors.MarketSpot {
+		t.Fatalf("unexpected second opportunity: %+v", result[1])
+	}
+	if result[2].Strategy != models.GroupStrategySpotFutures || result[2].Short.Exchange != "Binance" {
+		t.Fatalf("unexpected third opportunity: %+v", result[2])
+	}
+	for i := 1; i < len(result); i++ {
+		if result[i].Net > result[i-1].Net {
+			t.Fatalf("opportunities are not sorted by net: %+v", result)
+		}
+	}
+
+	if limited := RankFundingArbitrage(quotes, 24*time.Hour, 1); len(limited) != 1 {
+		t.Fatalf("expected 1 opportunity with limit, got %d", len(limited))
+	}
+}
+
+func TestRankFundingArbitrageSkipsEqualRates(t *testing.T) {
+	quotes := []FundingQuote{
+		{ExchangeID: 1, Market: connectors.MarketFutures, Base: "SOL", Quote: "USDT", Rate: 0.0001, Interval: 8 * time.Hour},
+		{ExchangeID: 2, Market: connectors.MarketFutures, Base: "SOL", Quote: "USDT", Rate: 0.0000125, Interval: time.Hour},
+	}
+	if result := RankFundingArbitrage(quotes, 0, 0); len(result) != 0 {
+		t.Fatalf("expected no opportunities for equal rates, got %+v", result)
+	}
+}
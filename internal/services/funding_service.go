@@ -0,0 +1,522 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// fundingHistoryDepth - насколько глубоко загружается история, если позиция открыта давно.
+	fundingHistoryDepth = 90 * 24 * time.Hour
+	// fundingGapTolerance - допустимое расхождение времени FUNDING-транзакции и расчёта биржи.
+	fundingGapTolerance = 30 * time.Minute
+	// fundingGapsLimit - сколько пропусков отдаётся на страницу позиции.
+	fundingGapsLimit = 200
+	// fundingRequestTimeout - таймаут запроса прогноза к бирже со страницы позиции.
+	fundingRequestTimeout = 10 * time.Second
+)
+
+// FundingService - история ставок финансирования, прогноз ближайшего платежа
+// и поиск пропущенных FUNDING-транзакций для FUTURES-позиций.
+type FundingService struct {
+	repo         *repositories.FundingRepository
+	positions    *repositories.PositionRepository
+	exchangeRepo *repositories.ExchangeRepository
+}
+
+// NewFundingService создаёт сервис ставок финансирования.
+func NewFundingService() *FundingService {
+	return &FundingService{
+		repo:         repositories.NewFundingRepository(),
+		positions:    repositories.NewPositionRepository(),
+		exchangeRepo: repositories.NewExchangeRepository(),
+	}
+}
+
+// fundingProvider возвращает коннектор биржи с поддержкой ставок финансирования.
+func fundingProvider(exchange *models.Exchange) (connectors.FundingRateProvider, error) {
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := connector.(connectors.FundingRateProvider)
+	if !ok {
+		return nil, connectors.ErrNotSupported
+	}
+	return provider, nil
+}
+
+// syncContract догружает историю контракта с последней сохранённой ставки
+// (или с since, если истории ещё нет).
+func (s *FundingService) syncContract(ctx context.Context, provider connectors.FundingRateProvider, exchangeID int, symbol string, since time.Time) (int, error) {
+	now := time.Now().UTC()
+	start := since.UTC()
+	if start.IsZero() || now.Sub(start) > fundingHistoryDepth {
+		start = now.Add(-fundingHistoryDepth)
+	}
+
+	last, err := s.repo.LastFundingTime(exchangeID, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if last != nil && last.After(start) {
+		start = last.Add(time.Second)
+	}
+
+	fetched, err := provider.FetchFundingHistory(ctx, symbol, start, now)
+	if err != nil {
+		return 0, err
+	}
+
+	items := make([]*models.FundingRate, 0, len(fetched))
+	for _, rate := range fetched {
+		item := &models.FundingRate{
+			ExID:        exchangeID,
+			Symbol:      symbol,
+			FundingTime: rate.FundingTime,
+			Rate:        rate.Rate,
+			Source:      "api",
+		}
+		if rate.MarkPrice > 0 {
+			markPrice := rate.MarkPrice
+			item.MarkPrice = &markPrice
+		}
+		items = append(items, item)
+	}
+	return s.repo.UpsertBatch(exchangeID, items)
+}
+
+// SyncOpenPositions загружает историю ставок по контрактам всех открытых FUTURES-позиций.
+// Биржи без коннектора или без поддержки funding пропускаются.
+func (s *FundingService) SyncOpenPositions(ctx context.Context) (int, error) {
+	contracts, err := s.positions.GetOpenContracts()
+	if err != nil {
+		return 0, err
+	}
+
+	providers := make(map[int]connectors.FundingRateProvider)
+	total, failed := 0, 0
+	for _, contract := range contracts {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		if contract.MarketType != connectors.MarketFutures {
+			continue
+		}
+
+		provider, cached := providers[contract.ExID]
+		if !cached {
+			exchange, err := s.exchangeRepo.FindByID(contract.ExID)
+			if err == nil && exchange != nil {
+				provider, _ = fundingProvider(exchange)
+			}
+			providers[contract.ExID] = provider
+		}
+		if provider == nil {
+			continue
+		}
+
+		count, err := s.syncContract(ctx, provider, contract.ExID, contract.Symbol, contract.Since)
+		if err != nil {
+			failed++
+			logger.Warn().
+				Int("exchange_id", contract.ExID).
+				Str("symbol", contract.Symbol).
+				Err(err).
+				Msg("Funding rate sync failed")
+			continue
+		}
+		total += count
+	}
+
+	if failed > 0 {
+		return total, fmt.Errorf("funding sync failed for %d contract(s)", failed)
+	}
+	return total, nil
+}
+
+// ImportFile импортирует историю ставок биржи из CSV.
+//
+// Первая строка - заголовок с колонками symbol, funding_time, rate и
+// необязательной mark_price (порядок любой). Время - RFC3339,
+// "YYYY-MM-DD HH:MM:SS" в UTC или unix-время в миллисекундах.
+// Ставка - в долях (0.0001) или в процентах с суффиксом "%".
+func (s *FundingService) ImportFile(exchangeID int, content []byte) (int, error) {
+	if exchangeID <= 0 {
+		return 0, fmt.Errorf("invalid exchange id")
+	}
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return 0, err
+	}
+	if exchange == nil {
+		return 0, fmt.Errorf("exchange not found")
+	}
+
+	items, err := parseFundingCSV(exchangeID, content)
+	if err != nil {
+		return 0, err
+	}
+	if len(items) == 0 {
+		return 0, fmt.Errorf("file contains no funding rates")
+	}
+	return s.repo.UpsertBatch(exchangeID, items)
+}
+
+func parseFundingCSV(exchangeID int, content []byte) ([]*models.FundingRate, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+		switch key {
+		case "symbol", "contract", "instid":
+			columns["symbol"] = i
+		case "funding_time", "fundingtime", "time", "timestamp":
+			columns["time"] = i
+		case "rate", "funding_rate", "fundingrate":
+			columns["rate"] = i
+		case "mark_price", "markprice":
+			columns["mark"] = i
+		}
+	}
+	for _, required := range []string{"symbol", "time", "rate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must contain symbol, funding_time and rate columns")
+		}
+	}
+
+	field := func(record []string, name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+
+	var items []*models.FundingRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		symbol := strings.ToUpper(field(record, "symbol"))
+		if symbol == "" || len(symbol) > 64 {
+			return nil, fmt.Errorf("line %d: invalid symbol", line)
+		}
+		fundingTime, err := parseFundingTime(field(record, "time"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid funding time", line)
+		}
+		rate, err := parseFundingRate(field(record, "rate"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate", line)
+		}
+
+		item := &models.FundingRate{ExID: exchangeID, Symbol: symbol, FundingTime: fundingTime, Rate: rate, Source: "file"}
+		if raw := field(record, "mark"); raw != "" {
+			if markPrice, err := strconv.ParseFloat(raw, 64); err == nil && markPrice > 0 {
+				item.MarkPrice = &markPrice
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func parseFundingTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed.UTC(), nil
+	}
+	return parseCSVDateUTC(raw)
+}
+
+func parseFundingRate(raw string) (float64, error) {
+	percent := strings.HasSuffix(raw, "%")
+	value, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid rate")
+	}
+	if percent {
+		value /= 100
+	}
+	if math.Abs(value) >= 1 {
+		return 0, fmt.Errorf("rate is out of range")
+	}
+	return value, nil
+}
+
+// FundingPayment - ожидаемый платёж по ставке rate для позиции position по цене markPrice.
+// Положительное значение - получено, отрицательное - уплачено (как FUNDING_AMOUNT).
+// При положительной ставке лонги платят шортам.
+func FundingPayment(position, markPrice, rate float64) float64 {
+	return -position * math.Abs(markPrice) * rate
+}
+
+// AnnualizedFundingCost - годовая стоимость удержания позиции при текущей ставке, в процентах
+// от стоимости позиции. Положительное значение - расход, отрицательное - доход.
+func AnnualizedFundingCost(position, rate float64, interval time.Duration) float64 {
+	if position == 0 || interval <= 0 {
+		return 0
+	}
+	periodsPerYear := float64(365*24*time.Hour) / float64(interval)
+	sign := 1.0
+	if position < 0 {
+		sign = -1
+	}
+	return sign * rate * periodsPerYear * 100
+}
+
+// storedForecast восстанавливает прогноз по сохранённой истории: последняя ставка,
+// интервал - между двумя последними расчётами.
+func (s *FundingService) storedForecast(exchangeID int, symbol string, now time.Time) (*connectors.FundingForecast, error) {
+	latest, err := s.repo.FindLatest(exchangeID, symbol, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) == 0 {
+		return nil, nil
+	}
+
+	interval := connectors.DefaultFundingInterval
+	if len(latest) == 2 {
+		if diff := latest[0].FundingTime.Sub(latest[1].FundingTime); diff > 0 {
+			interval = diff
+		}
+	}
+	next := latest[0].FundingTime
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+
+	forecast := &connectors.FundingForecast{Symbol: symbol, Rate: latest[0].Rate, NextFundingTime: next, Interval: interval}
+	if latest[0].MarkPrice != nil {
+		forecast.MarkPrice = *latest[0].MarkPrice
+	}
+	return forecast, nil
+}
+
+// Forecast считает для открытой FUTURES-позиции ближайший платёж funding
+// и годовую стоимость удержания по текущей ставке биржи (или последней сохранённой).
+func (s *FundingService) Forecast(ctx context.Context, userID int, userTimezone string, positionID int) (map[string]interface{}, bool, string) {
+	item, err := s.positions.GetPositionByID(userID, positionID)
+	if err != nil || item == nil {
+		return nil, false, "Empty Position Data"
+	}
+	if !strings.EqualFold(item.MarketType, connectors.MarketFutures) {
+		return nil, false, "Funding is available for FUTURES positions only"
+	}
+	if !strings.EqualFold(item.Status, "OPEN") || item.FinalPosition == nil || *item.FinalPosition == 0 {
+		return nil, false, "Position is not open"
+	}
+
+	symbol := strings.ToUpper(item.ContractName)
+	source := "exchange"
+	var forecast *connectors.FundingForecast
+	if exchange, err := s.exchangeRepo.FindByID(item.ExchangeID); err == nil && exchange != nil {
+		if provider, err := fundingProvider(exchange); err == nil {
+			reqCtx, cancel := context.WithTimeout(ctx, fundingRequestTimeout)
+			forecast, err = provider.FetchFundingForecast(reqCtx, symbol)
+			cancel()
+			if err != nil {
+				logger.Warn().Int("exchange_id", item.ExchangeID).Str("symbol", symbol).Err(err).Msg("Funding forecast request failed")
+				forecast = nil
+			}
+		}
+	}
+	if forecast == nil {
+		source = "history"
+		forecast, err = s.storedForecast(item.ExchangeID, symbol, time.Now().UTC())
+		if err != nil {
+			return nil, false, "Error load funding rates"
+		}
+		if forecast == nil {
+			return nil, false, "No funding rate data for this contract"
+		}
+	}
+
+	position := *item.FinalPosition
+	markPrice := forecast.MarkPrice
+	if markPrice <= 0 && item.FinalAvgPrice != nil {
+		markPrice = *item.FinalAvgPrice
+	}
+	interval := forecast.Interval
+	if interval <= 0 {
+		interval = connectors.DefaultFundingInterval
+	}
+
+	loc, tzErr := time.LoadLocation(userTimezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	next := ""
+	if !forecast.NextFundingTime.IsZero() {
+		next = forecast.NextFundingTime.In(loc).Format(dateTimeFormat)
+	}
+
+	return map[string]interface{}{
+		"SYMBOL":              symbol,
+		"SOURCE":              source,
+		"NEXT_FUNDING_TIME":   next,
+		"FUNDING_RATE":        forecast.Rate * 100,
+		"INTERVAL_HOURS":      interval.Hours(),
+		"MARK_PRICE":          markPrice,
+		"EXPECTED_PAYMENT":    FundingPayment(position, markPrice, forecast.Rate),
+		"ANNUALIZED_COST_PCT": AnnualizedFundingCost(position, forecast.Rate, interval),
+	}, true, ""
+}
+
+// FindFundingGaps возвращает моменты расчёта funding, на которые позиция была открыта,
+// но FUNDING-транзакции в пределах tolerance нет. Транзакции - в любом порядке.
+func FindFundingGaps(transactions []*models.PositionTransaction, settlements []time.Time, tolerance time.Duration) []time.Time {
+	trades := make([]*models.PositionTransaction, 0, len(transactions))
+	var fundings []time.Time
+	for _, t := range transactions {
+		if t.TransDate == nil {
+			continue
+		}
+		if strings.EqualFold(t.Type, "FUNDING") {
+			fundings = append(fundings, *t.TransDate)
+			continue
+		}
+		trades = append(trades, t)
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].TransDate.Before(*trades[j].TransDate) })
+	sort.Slice(fundings, func(i, j int) bool { return fundings[i].Before(fundings[j]) })
+	sort.Slice(settlements, func(i, j int) bool { return settlements[i].Before(settlements[j]) })
+
+	const epsilon = 1e-12
+	var gaps []time.Time
+	position := 0.0
+	next := 0
+	for _, settlement := range settlements {
+		for next < len(trades) && trades[next].TransDate.Before(settlement) {
+			position += trades[next].Volume
+			next++
+		}
+		if math.Abs(position) < epsilon {
+			continue
+		}
+
+		idx := sort.Search(len(fundings), func(i int) bool { return !fundings[i].Before(settlement.Add(-tolerance)) })
+		if idx < len(fundings) && !fundings[idx].After(settlement.Add(tolerance)) {
+			continue
+		}
+		gaps = append(gaps, settlement)
+	}
+	return gaps
+}
+
+// fundingGrid - расчётные моменты по сетке interval от полуночи UTC (если истории ставок нет).
+func fundingGrid(from, to time.Time, interval time.Duration) []time.Time {
+	if interval <= 0 || !to.After(from) {
+		return nil
+	}
+	t := from.UTC().Truncate(24 * time.Hour)
+	for t.Before(from) {
+		t = t.Add(interval)
+	}
+	var result []time.Time
+	for ; !t.After(to); t = t.Add(interval) {
+		result = append(result, t)
+	}
+	return result
+}
+
+// Gaps ищет расчёты funding, для которых у FUTURES-позиции нет FUNDING-транзакции.
+// Моменты расчёта берутся из сохранённой истории ставок, а без неё - по 8-часовой сетке.
+func (s *FundingService) Gaps(userID int, userTimezone string, positionID int) (map[string]interface{}, bool, string) {
+	item, err := s.positions.GetPositionByID(userID, positionID)
+	if err != nil || item == nil {
+		return nil, false, "Empty Position Data"
+	}
+	if !strings.EqualFold(item.MarketType, connectors.MarketFutures) {
+		return nil, false, "Funding is available for FUTURES positions only"
+	}
+
+	transactions, err := s.positions.GetTransactionsByPosition(positionID, userID, item.TransCount+1, 0)
+	if err != nil {
+		return nil, false, "Error load transactions"
+	}
+
+	var from, to time.Time
+	for _, t := range transactions {
+		if t.TransDate == nil || strings.EqualFold(t.Type, "FUNDING") {
+			continue
+		}
+		if from.IsZero() || t.TransDate.Before(from) {
+			from = *t.TransDate
+		}
+	}
+	to = time.Now().UTC()
+	if item.Closed != nil {
+		to = *item.Closed
+	}
+	if from.IsZero() || !to.After(from) {
+		return map[string]interface{}{"GAPS": []string{}, "GAPS_COUNT": 0, "SOURCE": "none"}, true, ""
+	}
+
+	source := "history"
+	var settlements []time.Time
+	rates, err := s.repo.FindRange(item.ExchangeID, strings.ToUpper(item.ContractName), from, to)
+	if err != nil {
+		return nil, false, "Error load funding rates"
+	}
+	for _, rate := range rates {
+		settlements = append(settlements, rate.FundingTime)
+	}
+	if len(settlements) == 0 {
+		source = "schedule"
+		settlements = fundingGrid(from, to, connectors.DefaultFundingInterval)
+	}
+
+	gaps := FindFundingGaps(transactions, settlements, fundingGapTolerance)
+
+	loc, tzErr := time.LoadLocation(userTimezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	formatted := make([]string, 0, len(gaps))
+	for i := len(gaps) - 1; i >= 0 && len(formatted) < fundingGapsLimit; i-- {
+		formatted = append(formatted, gaps[i].In(loc).Format(dateTimeFormat))
+	}
+
+	return map[string]interface{}{
+		"GAPS":       formatted,
+		"GAPS_COUNT": len(gaps),
+		"SOURCE":     source,
+	}, true, ""
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"testing"
+	"time"
+)
+
+func TestFundingPaymentAndAnnualizedCost(t *testing.T) {
+	// Лонг при положительной ставке платит.
+	if got := FundingPayment(2, 100, 0.0001); !almostEqual(got, -0.02) {
+		t.Fatalf("FundingPayment(long) = %v, want -0.02", got)
+	}
+	if got := FundingPayment(-2, 100, 0.0001); !almostEqual(got, 0.02) {
+		t.Fatalf("FundingPayment(short) = %v, want 0.02", got)
+	}
+
+	// 0.01% каждые 8 часов = 3 * 365 * 0.01% = 10.95% годовых.
+	if got := AnnualizedFundingCost(1, 0.0001, 8*time.Hour); !almostEqual(got, 10.95) {
+		t.Fatalf("AnnualizedFundingCost(long) = %v, want 10.95", got)
+	}
+	if got := AnnualizedFundingCost(-1, 0.0001, 8*time.Hour); !almostEqual(got, -10.95) {
+		t.Fatalf("AnnualizedFundingCost(short) = %v, want -10.95", got)
+	}
+}
+
+func TestFindFundingGaps(t *testing.T) {
+	at := func(hour int) *time.Time {
+		v := time.Date(2024, 1, 1, hour, 0, 0, 0, time.UTC)
+		return &v
+	}
+	transactions := []*models.PositionTransaction{
+		{ID: 1, Type: "TRADE", Volume: 1, TransDate: at(1)},
+		{ID: 2, Type: "FUNDING", Funding: -0.1, TransDate: at(8)},
+		{ID: 3, Type: "TRADE", Volume: -1, TransDate: at(20)},
+	}
+	settlements := []time.Time{*at(0), *at(8), *at(16), *at(23)}
+
+	gaps := FindFundingGaps(transactions, settlements, 30*time.Minute)
+	// 00:00 - позиции ещё нет, 08:00 - funding есть, 23:00 - позиция закрыта.
+	if len(gaps) != 1 || !gaps[0].Equal(*at(16)) {
+		t.Fatalf("FindFundingGaps() = %v, want [16:00]", gaps)
+	}
+}
+
+func TestParseFundingCSV(t *testing.T) {
+	content := []byte("Funding Time,Symbol,Rate,Mark Price\n" +
+		"2024-01-01 08:00:00,btcusdt,0.0001,42000\n" +
+		"1704096000000,BTCUSDT,-0.005%,\n")
+
+	items, err := parseFundingCSV(7, content)
+	if err != nil {
+		t.Fatalf("parseFundingCSV: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 rates, got %d", len(items))
+	}
+	if items[0].Symbol != "BTCUSDT" || items[0].MarkPrice == nil || *items[0].MarkPrice != 42000 {
+		t.Fatalf("unexpected first rate: %+v", items[0])
+	}
+	if !almostEqual(items[1].Rate, -0.00005) || items[1].MarkPrice != nil {
+		t.Fatalf("unexpected second rate: %+v", items[1])
+	}
+	if !items[1].FundingTime.Equal(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected funding time: %v", items[1].FundingTime)
+	}
+
+	if _, err := parseFundingCSV(7, []byte("symbol,rate\nBTCUSDT,0.0001\n")); err == nil {
+		t.Fatal("expected error for missing funding_time column")
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// InstrumentSyncResult - результат синхронизации одного рынка биржи.
+type InstrumentSyncResult struct {
+	ExchangeID   int    `json:"exchange_id"`
+	ExchangeName string `json:"exchange_name"`
+	Market       string `json:"market"`
+	Count        int    `json:"count"`
+	Error        string `json:"error,omitempty"`
+}
+
+// InstrumentService синхронизирует справочник инструментов с биржами
+// и проверяет контракты позиций по нему.
+type InstrumentService struct {
+	repo         *repositories.InstrumentRepository
+	exchangeRepo *repositories.ExchangeRepository
+}
+
+// NewInstrumentService создаёт сервис справочника инструментов.
+func NewInstrumentService() *InstrumentService {
+	return &InstrumentService{
+		repo:         repositories.NewInstrumentRepository(),
+		exchangeRepo: repositories.NewExchangeRepository(),
+	}
+}
+
+// SyncExchange загружает инструменты спота и фьючерсов биржи через коннектор.
+//
+// Рынки, которые коннектор не поддерживает, пропускаются. Пустой ответ биржи
+// не сохраняется, чтобы не пометить весь справочник как DELISTED.
+func (s *InstrumentService) SyncExchange(ctx context.Context, exchangeID int) ([]InstrumentSyncResult, error) {
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+	return s.syncExchange(ctx, exchange)
+}
+
+func (s *InstrumentService) syncExchange(ctx context.Context, exchange *models.Exchange) ([]InstrumentSyncResult, error) {
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]InstrumentSyncResult, 0, 2)
+	var failed []string
+	for _, market := range []string{connectors.MarketSpot, connectors.MarketFutures} {
+		result := InstrumentSyncResult{ExchangeID: exchange.ID, ExchangeName: exchange.Name, Market: market}
+
+		fetched, err := connector.FetchInstruments(ctx, market)
+		if errors.Is(err, connectors.ErrNotSupported) {
+			continue
+		}
+		if err == nil && len(fetched) == 0 {
+			err = fmt.Errorf("exchange returned empty instrument list")
+		}
+		if err == nil {
+			items := make([]*models.Instrument, 0, len(fetched))
+			for _, inst := range fetched {
+				items = append(items, &models.Instrument{
+					ExID:          exchange.ID,
+					Symbol:        strings.ToUpper(inst.Symbol),
+					MarketType:    market,
+					BaseAsset:     strings.ToUpper(inst.BaseAsset),
+					QuoteAsset:    strings.ToUpper(inst.QuoteAsset),
+					TickSize:      inst.TickSize,
+					LotSize:       inst.LotSize,
+					MinQty:        inst.MinQty,
+					ContractValue: inst.ContractValue,
+					Status:        inst.Status,
+				})
+			}
+			result.Count, err = s.repo.UpsertBatch(exchange.ID, market, items, time.Now())
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed = append(failed, market+": "+err.Error())
+			logger.Warn().
+				Int("exchange_id", exchange.ID).
+				Str("exchange", exchange.Name).
+				Str("market", market).
+				Err(err).
+				Msg("Instrument sync failed")
+		}
+		results = append(results, result)
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("instrument sync %s: %s", exchange.Name, strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+// SyncAll синхронизирует все активные биржи, для которых есть коннектор.
+// Ошибка одной биржи не прерывает синхронизацию остальных.
+func (s *InstrumentService) SyncAll(ctx context.Context) ([]InstrumentSyncResult, error) {
+	exchanges, err := s.exchangeRepo.FindAllActive()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []InstrumentSyncResult
+	failed := 0
+	for _, exchange := range exchanges {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		if _, err := connectors.New(exchange); err != nil {
+			continue
+		}
+		exchangeResults, err := s.syncExchange(ctx, exchange)
+		results = append(results, exchangeResults...)
+		if err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("instrument sync failed for %d exchange(s)", failed)
+	}
+	return results, nil
+}
+
+// Search возвращает инструменты для автодополнения поля "Contract".
+func (s *InstrumentService) Search(exchangeID int, market, term string, limit int) ([]*models.Instrument, error) {
+	if exchangeID <= 0 {
+		return []*models.Instrument{}, nil
+	}
+	if strings.TrimSpace(market) != "" {
+		market = connectors.NormalizeMarket(market)
+	}
+	return s.repo.Search(exchangeID, market, term, limit)
+}
+
+// ValidateContract проверяет, что контракт есть в справочнике биржи и не снят с торгов.
+//
+// Если для биржи/рынка справочник ещё не синхронизирован, проверка пропускается -
+// иначе нельзя было бы вести позиции на биржах без коннектора.
+// Возвращает найденный инструмент (nil, если проверка пропущена).
+func (s *InstrumentService) ValidateContract(exchangeID int, market, symbol string) (*models.Instrument, error) {
+	market = connectors.NormalizeMarket(market)
+
+	count, err := s.repo.CountByExchangeMarket(exchangeID, market)
+	if err != nil {
+		logger.Warn().Int("exchange_id", exchangeID).Err(err).Msg("Instrument catalog is unavailable, contract check skipped")
+		return nil, nil
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	instrument, err := s.repo.FindBySymbol(exchangeID, market, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if instrument == nil {
+		return nil, fmt.Errorf("contract %q is not listed on the exchange %s market", strings.TrimSpace(symbol), market)
+	}
+	if instrument.Status == connectors.InstrumentStatusDelisted {
+		return nil, fmt.Errorf("contract %q is delisted on the exchange %s market", instrument.Symbol, market)
+	}
+	return instrument, nil
+}
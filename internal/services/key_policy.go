@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"errors"
+	"strings"
+)
+
+// ErrWithdrawKey - ключ API разрешает вывод средств, а политика security.withdraw_key_policy = block.
+var ErrWithdrawKey = errors.New("api key has withdraw permission: create a key without withdrawals")
+
+// withdrawKeyPolicy возвращает политику для ключей с правом вывода (block, warn, off).
+func withdrawKeyPolicy() string {
+	policy := config.Get().Security.WithdrawKeyPolicy
+	if policy == "" {
+		return config.WithdrawKeyPolicyBlock
+	}
+	return policy
+}
+
+// auditKeyPolicyViolation пишет в audit log найденный ключ с правом вывода или ключ,
+// права которого не удалось проверить при политике block.
+// result: blocked - сохранение отклонено, deactivated - аккаунт отключён, flagged - только пометка.
+func auditKeyPolicyViolation(userID, accountID int, exchange *models.Exchange, policy, result string) {
+	var resourceID any
+	if accountID > 0 {
+		resourceID = accountID
+	}
+	logger.Audit().WarnContext(context.Background(), "audit",
+		"module", "audit",
+		"event_type", "audit",
+		"action", "KEY_POLICY_VIOLATION",
+		"resource_type", "exchange_account",
+		"resource_id", resourceID,
+		"exchange", exchange.Name,
+		"user_id", userID,
+		"policy", policy,
+		"result", result,
+	)
+}
+
+// enforceKeyPolicy проверяет права ключа на бирже согласно политике policy (withdrawKeyPolicy).
+// Возвращает результат проверки (nil при policy = off) и ErrWithdrawKey, если сохранение
+// нужно отклонить.
+//
+// Ключ, право вывода которого биржа подтвердила, при block отклоняется. Если права проверить
+// не удалось (неверный ключ, биржа недоступна или не умеет отдавать права), при block аккаунт
+// сохраняется отключённым (acc.Active = false), при warn - как есть.
+func enforceKeyPolicy(ctx context.Context, policy string, exchange *models.Exchange, userID, accountID int, acc *models.ExchangeAccount) (*models.AccountKeyCheck, error) {
+	if policy == config.WithdrawKeyPolicyOff {
+		return nil, nil
+	}
+
+	check := ProbeKey(ctx, exchange, CredentialsOf(acc))
+	if !check.Valid() {
+		if policy == config.WithdrawKeyPolicyBlock && acc.Active {
+			acc.Active = false
+			check.Message = strings.TrimSpace("withdraw permission is not verified, account saved inactive. " + check.Message)
+			auditKeyPolicyViolation(userID, accountID, exchange, policy, "deactivated")
+		}
+		return check, nil
+	}
+	if !check.Withdraw {
+		return check, nil
+	}
+	if policy == config.WithdrawKeyPolicyBlock {
+		auditKeyPolicyViolation(userID, accountID, exchange, policy, "blocked")
+		return check, ErrWithdrawKey
+	}
+	auditKeyPolicyViolation(userID, accountID, exchange, policy, "flagged")
+	return check, nil
+}
+
+// keyCheckAction возвращает, что делать с сохранённым аккаунтом после проверки ключа
+// ("Test connection"): deactivated - отключить (block, аккаунт активен, а ключ разрешает
+// вывод или его права не удалось проверить), flagged - только записать нарушение,
+// пустая строка - нарушения нет или политика off.
+func keyCheckAction(policy string, check *models.AccountKeyCheck, active bool) string {
+	if !check.Valid() {
+		if policy == config.WithdrawKeyPolicyBlock && active {
+			return "deactivated"
+		}
+		return ""
+	}
+	if !check.Withdraw {
+		return ""
+	}
+	switch {
+	case policy == config.WithdrawKeyPolicyBlock && active:
+		return "deactivated"
+	case policy != config.WithdrawKeyPolicyOff:
+		return "flagged"
+	}
+	return ""
+}
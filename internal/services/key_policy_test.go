@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// keyPolicyExchange поднимает заглушку Binance, отвечающую правами ключа с withdraw.
+func keyPolicyExchange(t *testing.T, withdraw bool) *models.Exchange {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sapi/v1/account/apiRestrictions" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"ipRestrict":false,"enableReading":true,"enableWithdrawals":%t,
+			"enableSpotAndMarginTrading":true,"enableFutures":false}`, withdraw)
+	}))
+	t.Cleanup(srv.Close)
+	return &models.Exchange{ID: 1, Name: "Binance", ClassToFactory: "binance", BaseURL: srv.URL}
+}
+
+func TestEnforceKeyPolicy(t *testing.T) {
+	acc := &models.ExchangeAccount{ApiKey: "key", SecretKey: "secret"}
+	ctx := context.Background()
+
+	// block: ключ с выводом не сохраняется ни при создании, ни при изменении.
+	exchange := keyPolicyExchange(t, true)
+	for _, accountID := range []int{0, 10} {
+		check, err := enforceKeyPolicy(ctx, config.WithdrawKeyPolicyBlock, exchange, 1, accountID, acc)
+		if !errors.Is(err, ErrWithdrawKey) || check == nil || !check.Withdraw {
+			t.Fatalf("block, account %d: got %+v, %v", accountID, check, err)
+		}
+	}
+
+	// warn: сохраняется, в результате проверки остаётся право вывода.
+	check, err := enforceKeyPolicy(ctx, config.WithdrawKeyPolicyWarn, exchange, 1, 10, acc)
+	if err != nil || check == nil || check.Status != models.KeyCheckValid || !check.Withdraw {
+		t.Fatalf("warn: got %+v, %v", check, err)
+	}
+
+	// off: биржа не опрашивается.
+	if check, err := enforceKeyPolicy(ctx, config.WithdrawKeyPolicyOff, exchange, 1, 10, acc); check != nil || err != nil {
+		t.Fatalf("off: got %+v, %v", check, err)
+	}
+
+	// Ключ без вывода проходит и при block.
+	check, err = enforceKeyPolicy(ctx, config.WithdrawKeyPolicyBlock, keyPolicyExchange(t, false), 1, 0, acc)
+	if err != nil || check == nil || check.Withdraw {
+		t.Fatalf("read-only key: got %+v, %v", check, err)
+	}
+
+	// Права не проверены: при block аккаунт сохраняется отключённым, при warn - как есть.
+	down := keyPolicyExchange(t, true)
+	down.BaseURL += "/down"
+	unsupported := &models.Exchange{ID: 2, Name: "Unknown", ClassToFactory: "unknown"}
+	for _, exchange := range []*models.Exchange{down, unsupported} {
+		active := &models.ExchangeAccount{ApiKey: "key", SecretKey: "secret", Active: true}
+		check, err = enforceKeyPolicy(ctx, config.WithdrawKeyPolicyBlock, exchange, 1, 0, active)
+		if err != nil || check == nil || check.Valid() || active.Active {
+			t.Fatalf("block, %s: got %+v, %v, active=%v", exchange.Name, check, err, active.Active)
+		}
+		active.Active = true
+		check, err = enforceKeyPolicy(ctx, config.WithdrawKeyPolicyWarn, exchange, 1, 0, active)
+		if err != nil || check == nil || check.Valid() || !active.Active {
+			t.Fatalf("warn, %s: got %+v, %v, active=%v", exchange.Name, check, err, active.Active)
+		}
+	}
+}
+
+func TestKeyCheckAction(t *testing.T) {
+	exchange := keyPolicyExchange(t, true)
+	check := ProbeKey(context.Background(), exchange, CredentialsOf(&models.ExchangeAccount{ApiKey: "key", SecretKey: "secret"}))
+	if !check.Valid() || !check.Withdraw {
+		t.Fatalf("unexpected probe result: %+v", check)
+	}
+
+	tests := []struct {
+		policy string
+		active bool
+		want   string
+	}{
+		{config.WithdrawKeyPolicyBlock, true, "deactivated"},
+		{config.WithdrawKeyPolicyBlock, false, "flagged"},
+		{config.WithdrawKeyPolicyWarn, true, "flagged"},
+		{config.WithdrawKeyPolicyOff, true, ""},
+	}
+	for _, tt := range tests {
+		if got := keyCheckAction(tt.policy, check, tt.active); got != tt.want {
+			t.Errorf("%s, active=%v: got %q, want %q", tt.policy, tt.active, got, tt.want)
+		}
+	}
+
+	readOnly := *check
+	readOnly.Withdraw = false
+	if got := keyCheckAction(config.WithdrawKeyPolicyBlock, &readOnly, true); got != "" {
+		t.Errorf("read-only key: got %q", got)
+	}
+	// Права не проверены: при block активный аккаунт отключается, при warn - нет.
+	for _, status := range []string{models.KeyCheckInvalid, models.KeyCheckError, models.KeyCheckUnsupported} {
+		unverified := &models.AccountKeyCheck{Status: status}
+		if got := keyCheckAction(config.WithdrawKeyPolicyBlock, unverified, true); got != "deactivated" {
+			t.Errorf("%s key, block: got %q", status, got)
+		}
+		if got := keyCheckAction(config.WithdrawKeyPolicyBlock, unverified, false); got != "" {
+			t.Errorf("%s key, block, inactive: got %q", status, got)
+		}
+		if got := keyCheckAction(config.WithdrawKeyPolicyWarn, unverified, true); got != "" {
+			t.Errorf("%s key, warn: got %q", status, got)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// analysisCandles - сколько последних свечей строит график спреда двух бирж.
+	analysisCandles = 500
+	// maxDirectCandles - ограничение количества свечей одной биржи в таблице прямого арбитража.
+	maxDirectCandles = 1000
+	// maxDirectPeriod - максимальный период таблицы прямого арбитража.
+	maxDirectPeriod = 31 * 24 * time.Hour
+)
+
+// analysisTimeframes - таймфреймы страницы анализа (названия, которые понимает
+// market_analysis.js) и соответствующие им интервалы свечей коннекторов.
+var analysisTimeframes = []struct {
+	Name     string
+	Interval string
+}{
+	{"1min", "1m"},
+	{"5min", "5m"},
+	{"15min", "15m"},
+	{"1hour", "1h"},
+	{"4hour", "4h"},
+}
+
+// SpreadEdge - лучшее направление арбитража между двумя биржами.
+// Доли (Gross, Fees, Net) - в долях, 0.001 = 0.1%.
+type SpreadEdge struct {
+	BuyOn     int     // 1 или 2 - биржа, на которой покупаем по ask
+	BuyPrice  float64 // ask биржи покупки
+	SellPrice float64 // bid биржи продажи
+	Gross     float64 // (SellPrice - BuyPrice) / BuyPrice
+	Fees      float64 // сумма taker-комиссий обеих бирж
+	Net       float64 // Gross - Fees
+}
+
+// BestSpreadEdge выбирает направление с большим спредом: покупка по ask одной биржи
+// и продажа по bid другой. fee1, fee2 - taker-комиссии бирж в долях.
+// Возвращает nil, если у какой-то из бирж нет цены.
+func BestSpreadEdge(bid1, ask1, bid2, ask2, fee1, fee2 float64) *SpreadEdge {
+	if bid1 <= 0 || ask1 <= 0 || bid2 <= 0 || ask2 <= 0 {
+		return nil
+	}
+	edge := &SpreadEdge{BuyOn: 1, BuyPrice: ask1, SellPrice: bid2, Fees: fee1 + fee2}
+	if (bid1-ask2)/ask2 > (bid2-ask1)/ask1 {
+		edge.BuyOn, edge.BuyPrice, edge.SellPrice = 2, ask2, bid1
+	}
+	edge.Gross = (edge.SellPrice - edge.BuyPrice) / edge.BuyPrice
+	edge.Net = edge.Gross - edge.Fees
+	return edge
+}
+
+// SpreadPoint - спред цен закрытия двух бирж на одной свече.
+type SpreadPoint struct {
+	Time   time.Time
+	Close1 float64
+	Close2 float64
+	Profit float64 // |Close1 - Close2| / min(Close1, Close2) - fees
+}
+
+// SpreadSeries сопоставляет свечи двух бирж по времени открытия и считает спред
+// за вычетом комиссий fees (в долях). Свечи без пары пропускаются.
+func SpreadSeries(candles1, candles2 []*models.Candle, fees float64) []SpreadPoint {
+	closes := make(map[int64]float64, len(candles2))
+	for _, c := range candles2 {
+		closes[c.OpenTime.Unix()] = c.Close
+	}
+	points := make([]SpreadPoint, 0, len(candles1))
+	for _, c := range candles1 {
+		close2, ok := closes[c.OpenTime.Unix()]
+		if !ok || c.Close <= 0 || close2 <= 0 {
+			continue
+		}
+		points = append(points, SpreadPoint{
+			Time:   c.OpenTime,
+			Close1: c.Close,
+			Close2: close2,
+			Profit: math.Abs(c.Close-close2)/math.Min(c.Close, close2) - fees,
+		})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points
+}
+
+// DirectListing - свечи пары на одной бирже для расчёта прямого арбитража.
+type DirectListing struct {
+	ExID    int
+	Name    string
+	Fee     float64 // taker-комиссия в долях
+	Candles []*models.Candle
+}
+
+// DirectArbitrage - прибыльная связка продажи на одной бирже и покупки на другой.
+type DirectArbitrage struct {
+	Time        time.Time
+	Sell        string
+	Buy         string
+	PriceSell   float64
+	PriceBuy    float64
+	Fees        float64 // доля
+	VolumeMax   float64 // в базовой валюте: меньший из объёмов свечей
+	Profit      float64 // доля за вычетом комиссий
+	ProfitTotal float64 // в котируемой валюте: Profit * VolumeMax * PriceBuy
+}
+
+// FindDirectArbitrage ищет на каждой свече связки бирж, где продажа по цене закрытия
+// одной биржи и покупка по цене закрытия другой дают прибыль после комиссий.
+// Результат отсортирован по времени (новые сверху), внутри свечи - по прибыли.
+func FindDirectArbitrage(listings []DirectListing) []DirectArbitrage {
+	type quote struct {
+		listing *DirectListing
+		candle  *models.Candle
+	}
+	byTime := make(map[int64][]quote)
+	for i := range listings {
+		for _, c := range listings[i].Candles {
+			if c.Close > 0 {
+				byTime[c.OpenTime.Unix()] = append(byTime[c.OpenTime.Unix()], quote{&listings[i], c})
+			}
+		}
+	}
+
+	result := make([]DirectArbitrage, 0)
+	for _, quotes := range byTime {
+		for _, sell := range quotes {
+			for _, buy := range quotes {
+				if sell.listing == buy.listing || sell.candle.Close <= buy.candle.Close {
+					continue
+				}
+				fees := sell.listing.Fee + buy.listing.Fee
+				profit := (sell.candle.Close-buy.candle.Close)/buy.candle.Close - fees
+				if profit <= 0 {
+					continue
+				}
+				volume := math.Min(sell.candle.Volume, buy.candle.Volume)
+				result = append(result, DirectArbitrage{
+					Time:        sell.candle.OpenTime,
+					Sell:        sell.listing.Name,
+					Buy:         buy.listing.Name,
+					PriceSell:   sell.candle.Close,
+					PriceBuy:    buy.candle.Close,
+					Fees:        fees,
+					VolumeMax:   volume,
+					Profit:      profit,
+					ProfitTotal: profit * volume * buy.candle.Close,
+				})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Time.Equal(result[j].Time) {
+			return result[i].Time.After(result[j].Time)
+		}
+		return result[i].Profit > result[j].Profit
+	})
+	return result
+}
+
+// splitPair разбирает пару вида BASE/QUOTE.
+func splitPair(pair string) (string, string, bool) {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(pair)), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// MarketAnalysisService - анализ межбиржевого арбитража на споте: общие пары бирж,
+// комиссии, текущий спред по стаканам и история спреда по свечам.
+type MarketAnalysisService struct {
+	instruments  *repositories.InstrumentRepository
+	exchangeRepo *repositories.ExchangeRepository
+	sync         *InstrumentService
+	fees         *FeeService
+	candles      *CandleService
+}
+
+// NewMarketAnalysisService создаёт сервис анализа рынка.
+func NewMarketAnalysisService() *MarketAnalysisService {
+	return &MarketAnalysisService{
+		instruments:  repositories.NewInstrumentRepository(),
+		exchangeRepo: repositories.NewExchangeRepository(),
+		sync:         NewInstrumentService(),
+		fees:         NewFeeService(),
+		candles:      NewCandleService(),
+	}
+}
+
+// ensureInstruments синхронизирует справочник инструментов биржи, если он ещё пуст.
+func (s *MarketAnalysisService) ensureInstruments(ctx context.Context, exchangeID int) error {
+	count, err := s.instruments.CountByExchangeMarket(exchangeID, connectors.MarketSpot)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = s.sync.SyncExchange(ctx, exchangeID)
+	return err
+}
+
+// resolveFee возвращает ставку пользователя на споте биржи (нулевую, если ставка не задана).
+func (s *MarketAnalysisService) resolveFee(userID, exchangeID int) *models.FeeSchedule {
+	fee, err := s.fees.ResolveFee(userID, exchangeID, nil, connectors.MarketSpot)
+	if err != nil {
+		logger.Warn().Int("exchange_id", exchangeID).Err(err).Msg("Resolve fee for market analysis failed")
+	}
+	if fee == nil {
+		return &models.FeeSchedule{ExID: exchangeID, MarketType: connectors.MarketSpot}
+	}
+	return fee
+}
+
+// commonPair находит пару BASE/QUOTE на обеих биржах.
+func (s *MarketAnalysisService) commonPair(exchangeID1, exchangeID2 int, pair string) (*repositories.CommonPair, error) {
+	base, quote, ok := splitPair(pair)
+	if !ok {
+		return nil, fmt.Errorf("invalid trade pair")
+	}
+	inst1, err := s.instruments.FindByAssets(exchangeID1, connectors.MarketSpot, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	inst2, err := s.instruments.FindByAssets(exchangeID2, connectors.MarketSpot, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	if inst1 == nil || inst2 == nil {
+		return nil, fmt.Errorf("trade pair is not listed on both exchanges")
+	}
+	return &repositories.CommonPair{Base: base, Quote: quote, Symbol1: inst1.Symbol, Symbol2: inst2.Symbol}, nil
+}
+
+// ExchangesSharingPairs - шаг 1: биржи, у которых есть общие пары с выбранной.
+func (s *MarketAnalysisService) ExchangesSharingPairs(ctx context.Context, exchangeID int) ([]map[string]interface{}, bool, string) {
+	if exchangeID <= 0 {
+		return nil, false, "Exchange is required"
+	}
+	if err := s.ensureInstruments(ctx, exchangeID); err != nil {
+		return nil, false, "Sync trading pairs failed: " + err.Error()
+	}
+	exchanges, err := s.instruments.FindExchangesSharingPairs(exchangeID, connectors.MarketSpot)
+	if err != nil {
+		return nil, false, "Error load exchanges"
+	}
+	rows := make([]map[string]interface{}, 0, len(exchanges))
+	for _, ex := range exchanges {
+		rows = append(rows, map[string]interface{}{"ID": ex.ID, "NAME": ex.Name, "PAIRS": ex.Pairs})
+	}
+	return rows, true, ""
+}
+
+// CommonPairs - шаг 2: пары, торгуемые на обеих биржах.
+func (s *MarketAnalysisService) CommonPairs(ctx context.Context, exchangeID1, exchangeID2 int) ([]map[string]interface{}, bool, string) {
+	if exchangeID1 <= 0 || exchangeID2 <= 0 || exchangeID1 == exchangeID2 {
+		return nil, false, "Select two different exchanges"
+	}
+	if err := s.ensureInstruments(ctx, exchangeID2); err != nil {
+		return nil, false, "Sync trading pairs failed: " + err.Error()
+	}
+	pairs, err := s.instruments.FindCommonPairs(exchangeID1, exchangeID2, connectors.MarketSpot)
+	if err != nil {
+		return nil, false, "Error load trading pairs"
+	}
+	rows := make([]map[string]interface{}, 0, len(pairs))
+	for _, p := range pairs {
+		rows = append(rows, map[string]interface{}{"pair": models.PairName(p.Base, p.Quote), "symbol1": p.Symbol1, "symbol2": p.Symbol2})
+	}
+	return rows, true, ""
+}
+
+// PairDetails - шаг 3: таймфреймы, комиссии обеих бирж и текущий спред по стаканам.
+// Если биржа не отдаёт котировку, spread в ответе равен false, а причина - в spread_error.
+func (s *MarketAnalysisService) PairDetails(ctx context.Context, userID, exchangeID1, exchangeID2 int, pair string) (map[string]interface{}, bool, string) {
+	if exchangeID1 <= 0 || exchangeID2 <= 0 || exchangeID1 == exchangeID2 {
+		return nil, false, "Select two different exchanges"
+	}
+	common, err := s.commonPair(exchangeID1, exchangeID2, pair)
+	if err != nil {
+		return nil, false, err.Error()
+	}
+
+	timeframes := make([]string, 0, len(analysisTimeframes))
+	for _, tf := range analysisTimeframes {
+		timeframes = append(timeframes, tf.Name)
+	}
+	fee1 := s.resolveFee(userID, exchangeID1)
+	fee2 := s.resolveFee(userID, exchangeID2)
+
+	row := map[string]interface{}{
+		"data": timeframes,
+		"fee": map[string]interface{}{
+			"ex1": map[string]float64{"maker_fee": fee1.Rate(true), "taker_fee": fee1.Rate(false)},
+			"ex2": map[string]float64{"maker_fee": fee2.Rate(true), "taker_fee": fee2.Rate(false)},
+		},
+		"spread":       false,
+		"spread_error": "",
+	}
+
+	ticker1, err1 := s.fetchTicker(ctx, exchangeID1, common.Symbol1)
+	ticker2, err2 := s.fetchTicker(ctx, exchangeID2, common.Symbol2)
+	switch {
+	case err1 != nil:
+		row["spread_error"] = err1.Error()
+	case err2 != nil:
+		row["spread_error"] = err2.Error()
+	default:
+		edge := BestSpreadEdge(ticker1.Bid, ticker1.Ask, ticker2.Bid, ticker2.Ask, fee1.Rate(false), fee2.Rate(false))
+		if edge == nil {
+			row["spread_error"] = "empty order book"
+			break
+		}
+		row["spread"] = map[string]interface{}{
+			"bid1":       ticker1.Bid,
+			"ask1":       ticker1.Ask,
+			"bid2":       ticker2.Bid,
+			"ask2":       ticker2.Ask,
+			"buy_on":     edge.BuyOn,
+			"buy_price":  edge.BuyPrice,
+			"sell_price": edge.SellPrice,
+			"gross":      edge.Gross,
+			"fees":       edge.Fees,
+			"net":        edge.Net,
+		}
+	}
+	return row, true, ""
+}
+
+// fetchTicker запрашивает текущие bid/ask инструмента через коннектор биржи.
+func (s *MarketAnalysisService) fetchTicker(ctx context.Context, exchangeID int, symbol string) (*connectors.Ticker, error) {
+	exchange, err := s.exchangeRepo.FindByID(exchangeID)
+	if err != nil {
+		return nil, err
+	}
+	if exchange == nil {
+		return nil, fmt.Errorf("exchange not found")
+	}
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := connector.(connectors.TickerProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", exchange.Name, connectors.ErrNotSupported)
+	}
+	ticker, err := provider.FetchTicker(ctx, connectors.MarketSpot, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", exchange.Name, err)
+	}
+	return ticker, nil
+}
+
+// SpreadHistory - шаг 4: спред цен закрытия двух бирж за последние analysisCandles свечей
+// таймфрейма за вычетом taker-комиссий обеих бирж.
+func (s *MarketAnalysisService) SpreadHistory(ctx context.Context, userID, exchangeID1, exchangeID2 int, pair, timeframe string) (map[string]interface{}, bool, string) {
+	interval := ""
+	for _, tf := range analysisTimeframes {
+		if tf.Name == timeframe {
+			interval = tf.Interval
+		}
+	}
+	if interval == "" {
+		return nil, false, "Unsupported timeframe"
+	}
+	if exchangeID1 <= 0 || exchangeID2 <= 0 || exchangeID1 == exchangeID2 {
+		return nil, false, "Select two different exchanges"
+	}
+	common, err := s.commonPair(exchangeID1, exchangeID2, pair)
+	if err != nil {
+		return nil, false, err.Error()
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-connectors.CandleIntervals[interval] * analysisCandles)
+	candles1, _, err := s.candles.LoadCandles(ctx, exchangeID1, connectors.MarketSpot, common.Symbol1, interval, from, to)
+	if err != nil {
+		return nil, false, "Load candles failed: " + err.Error()
+	}
+	candles2, _, err := s.candles.LoadCandles(ctx, exchangeID2, connectors.MarketSpot, common.Symbol2, interval, from, to)
+	if err != nil {
+		return nil, false, "Load candles failed: " + err.Error()
+	}
+
+	fees := s.resolveFee(userID, exchangeID1).Rate(false) + s.resolveFee(userID, exchangeID2).Rate(false)
+	points := SpreadSeries(candles1, candles2, fees)
+	prices := make([]map[string]interface{}, 0, len(points))
+	profits := make([]map[string]interface{}, 0, len(points))
+	for _, p := range points {
+		ts := p.Time.UnixMilli()
+		prices = append(prices, map[string]interface{}{"date": ts, "open": p.Close1, "close": p.Close2})
+		profits = append(profits, map[string]interface{}{"date": ts, "profit": p.Profit})
+	}
+	return map[string]interface{}{"data": prices, "data2": profits, "fees": fees}, true, ""
+}
+
+// SharedPairs возвращает пары, торгуемые хотя бы на двух биржах (список страницы прямого арбитража).
+func (s *MarketAnalysisService) SharedPairs() ([]string, error) {
+	return s.instruments.FindPairsOnMultipleExchanges(connectors.MarketSpot)
+}
+
+// DirectArbitrageRows считает связки прямого арбитража пары за период [dateStart, dateStop]
+// (в часовом поясе пользователя). Ошибки загрузки отдельных бирж не прерывают расчёт и
+// возвращаются списком warnings.
+func (s *MarketAnalysisService) DirectArbitrageRows(ctx context.Context, userID int, userTimezone, pair, dateStart, dateStop string) ([]DirectArbitrage, []string, error) {
+	base, quote, ok := splitPair(pair)
+	if !ok {
+		return nil, nil, fmt.Errorf("symbol is required")
+	}
+	loc, tzErr := time.LoadLocation(userTimezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	from, err := time.ParseInLocation(dateTimeFormat, strings.TrimSpace(dateStart), loc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid start date")
+	}
+	to, err := time.ParseInLocation(dateTimeFormat, strings.TrimSpace(dateStop), loc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid stop date")
+	}
+	if !to.After(from) {
+		return nil, nil, fmt.Errorf("invalid time interval")
+	}
+	if to.Sub(from) > maxDirectPeriod {
+		return nil, nil, fmt.Errorf("time interval must not exceed %d days", int(maxDirectPeriod.Hours()/24))
+	}
+
+	instruments, err := s.instruments.FindListings(connectors.MarketSpot, base, quote)
+	if err != nil {
+		return nil, nil, err
+	}
+	exchanges, err := s.exchangeRepo.FindAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[int]string, len(exchanges))
+	for _, ex := range exchanges {
+		names[ex.ID] = ex.Name
+	}
+
+	interval := ChooseCandleInterval(to.Sub(from), maxDirectCandles)
+	listings := make([]DirectListing, 0, len(instruments))
+	var warnings []string
+	for _, inst := range instruments {
+		name := names[inst.ExID]
+		if name == "" {
+			name = "#" + strconv.Itoa(inst.ExID)
+		}
+		candles, _, err := s.candles.LoadCandles(ctx, inst.ExID, connectors.MarketSpot, inst.Symbol, interval, from.UTC(), to.UTC())
+		if err != nil {
+			warnings = append(warnings, name+": "+err.Error())
+			continue
+		}
+		listings = append(listings, DirectListing{
+			ExID:    inst.ExID,
+			Name:    name,
+			Fee:     s.resolveFee(userID, inst.ExID).Rate(false),
+			Candles: candles,
+		})
+	}
+	if len(listings) < 2 && len(warnings) == 0 {
+		warnings = append(warnings, "Pair is listed on less than two exchanges")
+	}
+	return FindDirectArbitrage(listings), warnings, nil
+}
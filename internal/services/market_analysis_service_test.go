@@ -0,0 +1,73 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBestSpreadEdge(t *testing.T) {
+	// Дешевле купить на второй бирже (ask 99) и продать на первой (bid 101).
+	edge := BestSpreadEdge(101, 102, 98, 99, 0.001, 0.002)
+	if edge == nil || edge.BuyOn != 2 || edge.BuyPrice != 99 || edge.SellPrice != 101 {
+		t.Fatalf("unexpected edge: %+v", edge)
+	}
+	if math.Abs(edge.Gross-2.0/99) > 1e-12 || math.Abs(edge.Net-(2.0/99-0.003)) > 1e-12 {
+		t.Fatalf("unexpected gross/net: %+v", edge)
+	}
+
+	// Без арбитража спред отрицательный, но направление всё равно выбирается.
+	edge = BestSpreadEdge(100, 100.1, 100, 100.1, 0, 0)
+	if edge == nil || edge.Net >= 0 {
+		t.Fatalf("expected negative edge, got %+v", edge)
+	}
+	if BestSpreadEdge(0, 100, 100, 101, 0, 0) != nil {
+		t.Fatal("expected nil edge for empty book")
+	}
+}
+
+func candle(ts time.Time, close, volume float64) *models.Candle {
+	return &models.Candle{OpenTime: ts, Close: close, Volume: volume}
+}
+
+func TestSpreadSeries(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	points := SpreadSeries(
+		[]*models.Candle{candle(t1, 102, 1), candle(t0, 100, 1)},
+		[]*models.Candle{candle(t0, 101, 1), candle(t1.Add(time.Minute), 50, 1)},
+		0.002,
+	)
+	if len(points) != 1 || !points[0].Time.Equal(t0) {
+		t.Fatalf("expected one matched point, got %+v", points)
+	}
+	if math.Abs(points[0].Profit-(0.01-0.002)) > 1e-12 {
+		t.Fatalf("unexpected profit %v", points[0].Profit)
+	}
+}
+
+func TestFindDirectArbitrage(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	rows := FindDirectArbitrage([]DirectListing{
+		{ExID: 1, Name: "A", Fee: 0.001, Candles: []*models.Candle{candle(t0, 100, 5), candle(t1, 100, 5)}},
+		{ExID: 2, Name: "B", Fee: 0.001, Candles: []*models.Candle{candle(t0, 101, 2), candle(t1, 100.1, 5)}},
+		{ExID: 3, Name: "C", Fee: 0.001, Candles: []*models.Candle{candle(t1, 103, 1)}},
+	})
+
+	// t1: C>A, C>B прибыльны; B>A (0.1% - 0.2% комиссий) - нет. t0: B>A.
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %+v", rows)
+	}
+	if !rows[0].Time.Equal(t1) || rows[0].Sell != "C" || rows[0].Buy != "A" {
+		t.Fatalf("expected newest and most profitable first, got %+v", rows[0])
+	}
+	last := rows[2]
+	if last.Sell != "B" || last.Buy != "A" || last.VolumeMax != 2 {
+		t.Fatalf("unexpected last row %+v", last)
+	}
+	if math.Abs(last.ProfitTotal-last.Profit*2*100) > 1e-9 {
+		t.Fatalf("unexpected profit total %v", last.ProfitTotal)
+	}
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxChartCandles - сколько свечей показывает график позиции (определяет интервал).
+	maxChartCandles = 1000
+	// chartPadding - доля периода позиции, добавляемая к графику слева и справа.
+	chartPadding = 0.05
+	// avgPriceEpsilon - остаток позиции, который считается нулевым.
+	avgPriceEpsilon = 1e-12
+)
+
+// AvgPricePoint - средняя цена позиции после очередной транзакции.
+type AvgPricePoint struct {
+	TransactionID int
+	Time          time.Time
+	Position      float64
+	AvgPrice      *float64 // nil - позиция закрыта или цена не определена
+}
+
+// PositionChartService - данные для графика цены позиции: свечи, сделки и линия средней цены.
+type PositionChartService struct {
+	positions *repositories.PositionRepository
+	candles   *CandleService
+}
+
+// NewPositionChartService создаёт сервис графика позиции.
+func NewPositionChartService() *PositionChartService {
+	return &PositionChartService{
+		positions: repositories.NewPositionRepository(),
+		candles:   NewCandleService(),
+	}
+}
+
+func nullDiv(numerator, denominator float64) *float64 {
+	if denominator == 0 {
+		return nil
+	}
+	value := numerator / denominator
+	return &value
+}
+
+func isZeroPosition(value float64) bool {
+	return math.Abs(value) < avgPriceEpsilon
+}
+
+// RunningAvgPrice повторяет расчёт средней цены из PositionRepository (рекурсивный CTE
+// trade_calc) и возвращает её значение после каждой транзакции в порядке ID.
+func RunningAvgPrice(market string, transactions []*models.PositionTransaction) []AvgPricePoint {
+	ordered := make([]*models.PositionTransaction, len(transactions))
+	copy(ordered, transactions)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].ID < ordered[j].ID })
+
+	spot := strings.EqualFold(market, connectors.MarketSpot)
+	points := make([]AvgPricePoint, 0, len(ordered))
+
+	var pos float64
+	var avg, realized *float64
+	for i, t := range ordered {
+		vol, price, fee, feeBase := t.Volume, t.Price, t.Fee, t.FeeBase
+		delta := vol
+		if spot && vol > 0 {
+			delta = vol - feeBase
+		}
+
+		if i == 0 {
+			switch {
+			case (!spot && vol != 0) || (spot && vol < 0):
+				avg = nullDiv(price*vol+fee, vol)
+			case spot && vol > 0:
+				avg = nullDiv(price*vol, vol-feeBase)
+			default:
+				avg = &price
+			}
+			zero := 0.0
+			realized = &zero
+			pos = delta
+		} else {
+			prevPos, prevAvg, prevRealized := pos, avg, realized
+			prevAvgOrZero := 0.0
+			if prevAvg != nil {
+				prevAvgOrZero = *prevAvg
+			}
+
+			avg = nil
+			switch {
+			case !spot && vol != 0, spot && vol < 0:
+				if prevRealized != nil {
+					avg = nullDiv(prevPos*prevAvgOrZero+vol*price+fee-*prevRealized, prevPos+vol)
+				}
+			case !spot && t.Funding != 0:
+				if prevAvg != nil {
+					avg = nullDiv(prevPos**prevAvg-t.Funding, prevPos)
+				}
+			case spot && vol > 0:
+				if prevRealized != nil && vol-feeBase != 0 {
+					avg = nullDiv(prevPos*prevAvgOrZero+price*vol-*prevRealized, prevPos+vol-feeBase)
+				}
+			}
+
+			zero := 0.0
+			realized = &zero
+			if isZeroPosition(prevPos + delta) {
+				if prevAvg == nil {
+					realized = nil
+				} else {
+					value := (price - *prevAvg) * math.Min(math.Abs(vol), math.Abs(prevPos)) * math.Copysign(1, prevPos)
+					if !spot || vol < 0 {
+						value -= fee
+					}
+					realized = &value
+				}
+			}
+			pos = prevPos + delta
+		}
+
+		point := AvgPricePoint{TransactionID: t.ID, Position: pos}
+		if t.TransDate != nil {
+			point.Time = *t.TransDate
+		}
+		if !isZeroPosition(pos) && avg != nil {
+			value := *avg
+			point.AvgPrice = &value
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// chartPeriod возвращает период графика: от первой сделки (или открытия позиции)
+// до закрытия (или текущего момента) с небольшим запасом по краям.
+func chartPeriod(item *models.PositionDetail, transactions []*models.PositionTransaction, now time.Time) (time.Time, time.Time) {
+	var from, to time.Time
+	if item.Created != nil {
+		from = *item.Created
+	}
+	for _, t := range transactions {
+		if t.TransDate == nil {
+			continue
+		}
+		if from.IsZero() || t.TransDate.Before(from) {
+			from = *t.TransDate
+		}
+		if t.TransDate.After(to) {
+			to = *t.TransDate
+		}
+	}
+	if item.Closed != nil && item.Closed.After(to) {
+		to = *item.Closed
+	}
+	if item.Closed == nil || to.IsZero() {
+		to = now
+	}
+	if from.IsZero() || !to.After(from) {
+		from = to.Add(-24 * time.Hour)
+	}
+
+	padding := time.Duration(float64(to.Sub(from)) * chartPadding)
+	if padding < time.Hour {
+		padding = time.Hour
+	}
+	from = from.Add(-padding)
+	to = to.Add(padding)
+	if to.After(now) {
+		to = now
+	}
+	return from.UTC(), to.UTC()
+}
+
+// Chart возвращает свечи контракта за период позиции, отметки сделок (вход/выход)
+// и ступенчатую линию средней цены. Время во всех сериях - unix-время в миллисекундах.
+func (s *PositionChartService) Chart(ctx context.Context, userID, positionID int) (map[string]interface{}, bool, string) {
+	item, err := s.positions.GetPositionByID(userID, positionID)
+	if err != nil || item == nil {
+		return nil, false, "Empty Position Data"
+	}
+
+	transactions, err := s.positions.GetTransactionsByPosition(positionID, userID, item.TransCount+1, 0)
+	if err != nil {
+		return nil, false, "Error load transactions"
+	}
+
+	from, to := chartPeriod(item, transactions, time.Now().UTC())
+	interval := ChooseCandleInterval(to.Sub(from), maxChartCandles)
+
+	candleRows := make([]map[string]interface{}, 0)
+	source := "none"
+	candleError := ""
+	candles, candleSource, err := s.candles.LoadCandles(ctx, item.ExchangeID, item.MarketType, item.ContractName, interval, from, to)
+	if err != nil {
+		candleError = err.Error()
+	} else {
+		source = candleSource
+		for _, c := range candles {
+			candleRows = append(candleRows, map[string]interface{}{
+				"t": c.OpenTime.UnixMilli(),
+				"o": c.Open,
+				"h": c.High,
+				"l": c.Low,
+				"c": c.Close,
+			})
+		}
+	}
+
+	byID := make(map[int]*models.PositionTransaction, len(transactions))
+	for _, t := range transactions {
+		byID[t.ID] = t
+	}
+
+	points := RunningAvgPrice(item.MarketType, transactions)
+	markers := make([]map[string]interface{}, 0)
+	avgLine := make([]map[string]interface{}, 0, len(points))
+	prevPos := 0.0
+	for _, point := range points {
+		t := byID[point.TransactionID]
+		if point.Time.IsZero() {
+			prevPos = point.Position
+			continue
+		}
+		ts := point.Time.UnixMilli()
+
+		var avgValue interface{}
+		if point.AvgPrice != nil {
+			avgValue = *point.AvgPrice
+		}
+		avgLine = append(avgLine, map[string]interface{}{"t": ts, "v": avgValue})
+
+		if !strings.EqualFold(t.Type, "FUNDING") && t.Volume != 0 {
+			side := "buy"
+			if t.Volume < 0 {
+				side = "sell"
+			}
+			// Вход - сделка увеличивает модуль позиции, выход - уменьшает (или разворачивает).
+			kind := "entry"
+			if !isZeroPosition(prevPos) && math.Signbit(prevPos) != math.Signbit(t.Volume) {
+				kind = "exit"
+			}
+			markers = append(markers, map[string]interface{}{
+				"id":     t.ID,
+				"t":      ts,
+				"side":   side,
+				"kind":   kind,
+				"price":  t.Price,
+				"volume": t.Volume,
+			})
+		}
+		prevPos = point.Position
+	}
+	// Расчёт идёт в порядке ID, а на графике точки нужны в порядке времени.
+	sort.SliceStable(avgLine, func(i, j int) bool { return avgLine[i]["t"].(int64) < avgLine[j]["t"].(int64) })
+	sort.SliceStable(markers, func(i, j int) bool { return markers[i]["t"].(int64) < markers[j]["t"].(int64) })
+
+	return map[string]interface{}{
+		"SYMBOL":       item.ContractName,
+		"MARKET_TYPE":  item.MarketType,
+		"INTERVAL":     interval,
+		"FROM":         from.UnixMilli(),
+		"TO":           to.UnixMilli(),
+		"SOURCE":       source,
+		"CANDLES":      candleRows,
+		"CANDLE_ERROR": candleError,
+		"MARKERS":      markers,
+		"AVG_LINE":     avgLine,
+	}, true, ""
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func chartTrade(id int, price, volume, fee float64, at time.Time) *models.PositionTransaction {
+	return &models.PositionTransaction{ID: id, Type: "TRADE", Price: price, Volume: volume, Fee: fee, TransDate: &at}
+}
+
+func TestRunningAvgPriceFutures(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Передаём в порядке выдачи репозитория (от новых к старым) - расчёт идёт по ID.
+	points := RunningAvgPrice("FUTURES", []*models.PositionTransaction{
+		chartTrade(3, 130, -2, 0, base.Add(2*time.Hour)),
+		chartTrade(2, 120, 1, 0, base.Add(time.Hour)),
+		chartTrade(1, 100, 1, 0, base),
+	})
+	if len(points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(points))
+	}
+	if points[0].AvgPrice == nil || *points[0].AvgPrice != 100 {
+		t.Fatalf("first avg = %v, want 100", points[0].AvgPrice)
+	}
+	if points[1].AvgPrice == nil || math.Abs(*points[1].AvgPrice-110) > 1e-9 || points[1].Position != 2 {
+		t.Fatalf("second point = %+v, want avg 110 at position 2", points[1])
+	}
+	if points[2].AvgPrice != nil || points[2].Position != 0 {
+		t.Fatalf("closed position must have no avg price: %+v", points[2])
+	}
+}
+
+func TestRunningAvgPriceFeeAndFunding(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	funding := &models.PositionTransaction{ID: 2, Type: "FUNDING", Funding: 5, TransDate: &base}
+	points := RunningAvgPrice("FUTURES", []*models.PositionTransaction{
+		chartTrade(1, 100, 10, 10, base),
+		funding,
+	})
+	// Комиссия входа поднимает среднюю: (100*10 + 10) / 10 = 101.
+	if *points[0].AvgPrice != 101 {
+		t.Fatalf("avg with fee = %v, want 101", *points[0].AvgPrice)
+	}
+	// Полученный funding снижает среднюю: (10*101 - 5) / 10 = 100.5.
+	if *points[1].AvgPrice != 100.5 {
+		t.Fatalf("avg after funding = %v, want 100.5", *points[1].AvgPrice)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrPositionNotFound - позиция не найдена или принадлежит другому пользователю.
+var ErrPositionNotFound = errors.New("position not found")
+
+// positionExportColumns - колонки выгрузки списка позиций. Даты - в часовом поясе пользователя.
+var positionExportColumns = []string{
+	"POSITION_ID", "CONTRACT_NAME", "EXCHANGE_NAME", "ACCOUNT_ID", "ACCOUNT_NAME", "GROUP_ID", "GROUP_NAME",
+	"MARKET_TYPE", "STATUS", "CREATED", "CLOSED", "FINAL_POSITION", "FINAL_AVG_PRICE", "FEE_BASE_TOTAL",
+	"FEE_TOTAL", "FUNDING_TOTAL", "TOTAL_REALIZED_PNL", "TAKER_FEE", "BREAK_EVEN_PRICE", "EXIT_FEE_ESTIMATE",
+}
+
+// transactionExportColumns - колонки выгрузки операций позиции.
+var transactionExportColumns = []string{"ID", "TYPE", "PRICE", "VOLUME", "FEE_BASE", "FEE", "FUNDING", "TRANS_DATE"}
+
+func exportTime(t *time.Time, loc *time.Location) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.In(loc).Format(dateTimeFormat)
+}
+
+func exportIntPtr(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func exportFloatPtr(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// ExportPositions пишет позиции пользователя по фильтру с итогами (комиссии, funding,
+// реализованный PnL, безубыточная цена открытых позиций) в w в формате format.
+// Строки читаются из базы и пишутся по одной.
+func (s *PositionService) ExportPositions(w io.Writer, userID int, userTimezone string, filter models.PositionFilter, format string) error {
+	loc, tzErr := time.LoadLocation(userTimezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	tw, err := NewTableWriter(w, format, "Positions", positionExportColumns)
+	if err != nil {
+		return err
+	}
+
+	feeCache := map[string]*models.FeeSchedule{}
+	row := make([]interface{}, len(positionExportColumns))
+	err = s.repo.EachPosition(userID, filter, func(item *models.PositionSummary) error {
+		row = append(row[:0],
+			item.PositionID, item.ContractName, item.ExchangeName, exportIntPtr(item.AccountID), item.AccountName,
+			exportIntPtr(item.GroupID), item.GroupName, item.MarketType, item.Status,
+			exportTime(item.Created, loc), exportTime(item.Closed, loc),
+			exportFloatPtr(item.FinalPosition), exportFloatPtr(item.FinalAvgPrice), exportFloatPtr(item.FeeBaseTotal),
+			exportFloatPtr(item.FeeTotal), exportFloatPtr(item.FundingTotal), exportFloatPtr(item.TotalRealizedPnL),
+			nil, nil, nil,
+		)
+		if item.Status == "OPEN" && item.FinalPosition != nil && item.FinalAvgPrice != nil {
+			key := positionFeeKey(item)
+			fee, ok := feeCache[key]
+			if !ok {
+				fee, _ = s.fees.ResolveFee(userID, item.ExchangeID, item.AccountID, item.MarketType)
+				feeCache[key] = fee
+			}
+			estimates := s.exitEstimates(fee, *item.FinalPosition, *item.FinalAvgPrice)
+			row[17], row[18], row[19] = estimates["TAKER_FEE"], estimates["BREAK_EVEN_PRICE"], estimates["EXIT_FEE_ESTIMATE"]
+		}
+		return tw.WriteRow(row)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ExportTransactions пишет операции позиции в порядке исполнения в w в формате format.
+// Если позиция не принадлежит пользователю, возвращает ErrPositionNotFound, ничего не записав.
+func (s *PositionService) ExportTransactions(w io.Writer, userID int, userTimezone string, positionID int, format string) error {
+	exchangeID, _, err := s.repo.GetPositionExchangeAndMarket(positionID, userID)
+	if err != nil {
+		return err
+	}
+	if exchangeID == 0 {
+		return ErrPositionNotFound
+	}
+	loc, tzErr := time.LoadLocation(userTimezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	tw, err := NewTableWriter(w, format, "Position "+strconv.Itoa(positionID), transactionExportColumns)
+	if err != nil {
+		return err
+	}
+
+	row := make([]interface{}, len(transactionExportColumns))
+	err = s.repo.EachTransaction(positionID, userID, func(item *models.PositionTransaction) error {
+		row = append(row[:0], item.ID, item.Type, item.Price, item.Volume, item.FeeBase, item.Fee, item.Funding,
+			exportTime(item.TransDate, loc))
+		return tw.WriteRow(row)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxGroupLegs - сколько позиций может входить в одну группу.
+const maxGroupLegs = 10
+
+// ErrPositionGroupNotFound - группа не найдена или принадлежит другому пользователю.
+var ErrPositionGroupNotFound = errors.New("position group not found")
+
+// PositionGroupView - группа с ногами и суммарными показателями для списка позиций.
+type PositionGroupView struct {
+	Group  *models.PositionGroup
+	Legs   []PositionValue
+	Totals PositionTotals
+}
+
+// PositionGroupService - группы позиций (хеджированные связки): суммарная экспозиция,
+// PnL и funding по ногам, закрытие ног вместе.
+type PositionGroupService struct {
+	groups       *repositories.PositionGroupRepository
+	positionRepo *repositories.PositionRepository
+	positions    *PositionService
+}
+
+// NewPositionGroupService создаёт сервис групп позиций.
+func NewPositionGroupService() *PositionGroupService {
+	return &PositionGroupService{
+		groups:       repositories.NewPositionGroupRepository(),
+		positionRepo: repositories.NewPositionRepository(),
+		positions:    NewPositionService(),
+	}
+}
+
+// ListGroups возвращает группы пользователя с ногами и суммарными показателями.
+// Открытые ноги оцениваются по кэшу котировок; ошибка котировки не прерывает список.
+func (s *PositionGroupService) ListGroups(ctx context.Context, userID int) ([]*PositionGroupView, error) {
+	groups, err := s.groups.FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return []*PositionGroupView{}, nil
+	}
+
+	filter := models.PositionFilter{Grouped: true}
+	count, err := s.positionRepo.CountPositionsByUser(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := s.positionRepo.GetPositions(userID, filter, count+1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	legs := make(map[int][]PositionValue)
+	for i, value := range valuePositions(ctx, positions) {
+		groupID := *positions[i].GroupID
+		legs[groupID] = append(legs[groupID], value)
+	}
+
+	views := make([]*PositionGroupView, 0, len(groups))
+	for _, group := range groups {
+		groupLegs := legs[group.ID]
+		if groupLegs == nil {
+			groupLegs = []PositionValue{}
+		}
+		views = append(views, &PositionGroupView{Group: group, Legs: groupLegs, Totals: ComputePositionTotals(groupLegs)})
+	}
+	return views, nil
+}
+
+// AvailablePositions возвращает открытые позиции пользователя, не входящие в группы.
+func (s *PositionGroupService) AvailablePositions(userID int) ([]*models.PositionSummary, error) {
+	count, err := s.positionRepo.CountPositionsByUser(userID, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.positionRepo.GetPositions(userID, models.PositionFilter{}, count+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*models.PositionSummary, 0)
+	for _, item := range all {
+		if item.Status == "OPEN" && item.GroupID == nil {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// CreateGroup объединяет открытые позиции пользователя в группу.
+func (s *PositionGroupService) CreateGroup(userID int, name string, positionIDs []int) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf(`Filed "Group Name" is empty`)
+	}
+	if len(name) > 128 {
+		return 0, fmt.Errorf("group name is too long")
+	}
+
+	unique := make([]int, 0, len(positionIDs))
+	seen := make(map[int]bool)
+	for _, id := range positionIDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) < 2 {
+		return 0, fmt.Errorf("select at least two positions")
+	}
+	if len(unique) > maxGroupLegs {
+		return 0, fmt.Errorf("too many positions in group (max %d)", maxGroupLegs)
+	}
+
+	id, err := s.groups.Create(&models.PositionGroup{UserID: userID, Name: name, Created: time.Now().UTC()}, unique)
+	if errors.Is(err, repositories.ErrPositionsNotGroupable) {
+		return 0, fmt.Errorf("only open positions that are not in another group can be grouped")
+	}
+	return id, err
+}
+
+// CloseGroup закрывает группу вместе со всеми ногами (объём каждой ноги должен быть нулевым).
+func (s *PositionGroupService) CloseGroup(userID, groupID int) (bool, string) {
+	return s.positions.closeGroup(userID, groupID)
+}
+
+// DeleteGroup расформировывает группу; позиции остаются.
+func (s *PositionGroupService) DeleteGroup(userID, groupID int) error {
+	ok, err := s.groups.Delete(groupID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPositionGroupNotFound
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"ctweb/internal/models"
 	"ctweb/internal/repositories"
 	"fmt"
 	"html"
@@ -13,11 +14,62 @@ import (
 const dateTimeFormat = "2006-01-02 15:04:05"
 
 type PositionService struct {
-	repo *repositories.PositionRepository
+	repo        *repositories.PositionRepository
+	accounts    *repositories.ExchangeAccountRepository
+	instruments *InstrumentService
+	fees        *FeeService
+	groups      *repositories.PositionGroupRepository
 }
 
 func NewPositionService() *PositionService {
-	return &PositionService{repo: repositories.NewPositionRepository()}
+	return &PositionService{
+		repo:        repositories.NewPositionRepository(),
+		accounts:    repositories.NewExchangeAccountRepository(),
+		instruments: NewInstrumentService(),
+		fees:        NewFeeService(),
+		groups:      repositories.NewPositionGroupRepository(),
+	}
+}
+
+// resolveAccount проверяет, что аккаунт принадлежит пользователю и открыт на бирже позиции.
+// accountID <= 0 - позиция без аккаунта (nil).
+func (s *PositionService) resolveAccount(userID, exchangeID, accountID int) (*int, string) {
+	if accountID <= 0 {
+		return nil, ""
+	}
+	acc, err := s.accounts.FindByID(accountID, userID)
+	if err != nil {
+		return nil, "Exchange account not found"
+	}
+	if errText := checkPositionAccount(acc, userID, exchangeID); errText != "" {
+		return nil, errText
+	}
+	return &acc.ID, ""
+}
+
+// checkPositionAccount проверяет, что к позиции пользователя на бирже exchangeID можно привязать аккаунт acc.
+func checkPositionAccount(acc *models.ExchangeAccount, userID, exchangeID int) string {
+	if acc == nil || acc.UID != userID || acc.Deleted {
+		return "Exchange account not found"
+	}
+	if acc.ExID != exchangeID {
+		return "Exchange account belongs to another exchange"
+	}
+	return ""
+}
+
+// resolveContract проверяет контракт по справочнику инструментов биржи
+// и возвращает символ в том виде, в котором он хранится в справочнике.
+func (s *PositionService) resolveContract(exchangeID int, market, name string) (string, string) {
+	name = strings.TrimSpace(name)
+	instrument, err := s.instruments.ValidateContract(exchangeID, market, name)
+	if err != nil {
+		return "", "Contract validation failed: " + err.Error()
+	}
+	if instrument != nil {
+		return instrument.Symbol, ""
+	}
+	return name, ""
 }
 
 func (s *PositionService) parseDateTimeInUserTZ(value, timezone string) (time.Time, error) {
@@ -39,23 +91,28 @@ func (s *PositionService) normalizeMarket(market string) string {
 	return "FUTURES"
 }
 
-func (s *PositionService) GetPositionsData(userID, start, length int) (int, []map[string]interface{}, error) {
-	count, err := s.repo.CountPositionsByUser(userID)
+func (s *PositionService) GetPositionsData(userID int, filter models.PositionFilter, start, length int) (int, []map[string]interface{}, error) {
+	count, err := s.repo.CountPositionsByUser(userID, filter)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	data, err := s.repo.GetPositions(userID, length, start)
+	data, err := s.repo.GetPositions(userID, filter, length, start)
 	if err != nil {
 		return 0, nil, err
 	}
 
+	feeCache := map[string]*models.FeeSchedule{}
 	rows := make([]map[string]interface{}, 0, len(data))
 	for _, item := range data {
 		row := map[string]interface{}{
 			"POSITION_ID":        item.PositionID,
 			"CONTRACT_NAME":      html.EscapeString(item.ContractName),
 			"EXCHANGE_NAME":      html.EscapeString(item.ExchangeName),
+			"ACCOUNT_ID":         nil,
+			"ACCOUNT_NAME":       html.EscapeString(item.AccountName),
+			"GROUP_ID":           nil,
+			"GROUP_NAME":         html.EscapeString(item.GroupName),
 			"MARKET_TYPE":        html.EscapeString(item.MarketType),
 			"STATUS":             item.Status,
 			"FINAL_POSITION":     nil,
@@ -65,6 +122,12 @@ func (s *PositionService) GetPositionsData(userID, start, length int) (int, []ma
 			"FUNDING_TOTAL":      nil,
 			"TOTAL_REALIZED_PNL": nil,
 		}
+		if item.AccountID != nil {
+			row["ACCOUNT_ID"] = *item.AccountID
+		}
+		if item.GroupID != nil {
+			row["GROUP_ID"] = *item.GroupID
+		}
 		if item.FinalPosition != nil {
 			row["FINAL_POSITION"] = *item.FinalPosition
 		}
@@ -83,13 +146,24 @@ func (s *PositionService) GetPositionsData(userID, start, length int) (int, []ma
 		if item.TotalRealizedPnL != nil {
 			row["TOTAL_REALIZED_PNL"] = *item.TotalRealizedPnL
 		}
+		if item.Status == "OPEN" && item.FinalPosition != nil && item.FinalAvgPrice != nil {
+			key := positionFeeKey(item)
+			fee, ok := feeCache[key]
+			if !ok {
+				fee, _ = s.fees.ResolveFee(userID, item.ExchangeID, item.AccountID, item.MarketType)
+				feeCache[key] = fee
+			}
+			for k, v := range s.exitEstimates(fee, *item.FinalPosition, *item.FinalAvgPrice) {
+				row[k] = v
+			}
+		}
 		rows = append(rows, row)
 	}
 
 	return count, rows, nil
 }
 
-func (s *PositionService) CreatePosition(userID int, userTimezone, name string, exchangeID int, startDate, market string) (bool, string) {
+func (s *PositionService) CreatePosition(userID int, userTimezone, name string, exchangeID, accountID int, startDate, market string) (bool, string) {
 	if strings.TrimSpace(name) == "" {
 		return false, `Filed "Contract Name" is empty`
 	}
@@ -112,14 +186,23 @@ func (s *PositionService) CreatePosition(userID int, userTimezone, name string,
 		return false, "Error format Start Date"
 	}
 
-	if err := s.repo.CreatePosition(strings.TrimSpace(name), exchangeID, startUTC, s.normalizeMarket(market), userID); err != nil {
+	contract, errText := s.resolveContract(exchangeID, market, name)
+	if errText != "" {
+		return false, errText
+	}
+	account, errText := s.resolveAccount(userID, exchangeID, accountID)
+	if errText != "" {
+		return false, errText
+	}
+
+	if err := s.repo.CreatePosition(contract, exchangeID, account, startUTC, s.normalizeMarket(market), userID); err != nil {
 		return false, "Erorr create position"
 	}
 
 	return true, ""
 }
 
-func (s *PositionService) EditPosition(userID int, userTimezone string, positionID int, name string, exchangeID int, startDate string) (bool, string) {
+func (s *PositionService) EditPosition(userID int, userTimezone string, positionID int, name string, exchangeID, accountID int, startDate string) (bool, string) {
 	if positionID <= 0 {
 		return false, "Failed Position ID"
 	}
@@ -142,7 +225,24 @@ func (s *PositionService) EditPosition(userID int, userTimezone string, position
 		return false, "Error format Start Date"
 	}
 
-	updated, err := s.repo.EditPosition(positionID, userID, strings.TrimSpace(name), exchangeID, startUTC)
+	market, err := s.repo.GetPositionMarketType(positionID, userID)
+	if err != nil {
+		return false, "Error edit position"
+	}
+	if market == "" {
+		return false, "Failed Position ID"
+	}
+
+	contract, errText := s.resolveContract(exchangeID, market, name)
+	if errText != "" {
+		return false, errText
+	}
+	account, errText := s.resolveAccount(userID, exchangeID, accountID)
+	if errText != "" {
+		return false, errText
+	}
+
+	updated, err := s.repo.EditPosition(positionID, userID, contract, exchangeID, account, startUTC)
 	if err != nil {
 		return false, "Error edit position"
 	}
@@ -205,6 +305,8 @@ func (s *PositionService) GetPosition(userID int, userTimezone string, positionI
 		"POSITION_ID":        item.PositionID,
 		"CONTRACT_NAME":      html.EscapeString(item.ContractName),
 		"EXCHANGE_NAME":      html.EscapeString(item.ExchangeName),
+		"ACCOUNT_ID":         "",
+		"ACCOUNT_NAME":       html.EscapeString(item.AccountName),
 		"MARKET_TYPE":        html.EscapeString(item.MarketType),
 		"STATUS":             html.EscapeString(strings.ToUpper(item.Status)),
 		"OPENED":             opened,
@@ -218,9 +320,118 @@ func (s *PositionService) GetPosition(userID int, userTimezone string, positionI
 		"TRANS_COUNT":        strconv.Itoa(item.TransCount),
 	}
 
+	if item.AccountID != nil {
+		result["ACCOUNT_ID"] = strconv.Itoa(*item.AccountID)
+	}
+
+	if strings.EqualFold(item.Status, "OPEN") && item.FinalPosition != nil && item.FinalAvgPrice != nil {
+		fee, _ := s.fees.ResolveFee(userID, item.ExchangeID, item.AccountID, item.MarketType)
+		for k, v := range s.exitEstimates(fee, *item.FinalPosition, *item.FinalAvgPrice) {
+			result[k] = v
+		}
+	}
+
 	return result, true, ""
 }
 
+// positionFeeKey - ключ кэша ставок комиссий: ставка зависит от биржи, рынка и аккаунта позиции.
+func positionFeeKey(item *models.PositionSummary) string {
+	key := strconv.Itoa(item.ExchangeID) + "|" + item.MarketType
+	if item.AccountID != nil {
+		key += "|" + strconv.Itoa(*item.AccountID)
+	}
+	return key
+}
+
+// exitEstimates считает безубыточную цену и комиссию закрытия открытой позиции
+// по taker-ставке (закрытие рыночной заявкой). Без ставки возвращает пустые значения.
+func (s *PositionService) exitEstimates(fee *models.FeeSchedule, position, avgPrice float64) map[string]interface{} {
+	result := map[string]interface{}{
+		"TAKER_FEE":         nil,
+		"BREAK_EVEN_PRICE":  nil,
+		"EXIT_FEE_ESTIMATE": nil,
+	}
+	if fee == nil || position == 0 {
+		return result
+	}
+	rate := fee.Rate(false)
+	result["TAKER_FEE"] = fee.TakerFee
+	result["BREAK_EVEN_PRICE"] = BreakEvenPrice(position, avgPrice, rate)
+	result["EXIT_FEE_ESTIMATE"] = ExitCost(position, avgPrice, rate)
+	return result
+}
+
+// SuggestTransactionFee подбирает комиссию сделки по ставке биржи/VIP-аккаунта
+// для предзаполнения формы добавления транзакции.
+func (s *PositionService) SuggestTransactionFee(userID, positionID int, action, price, volume, liquidity string) (map[string]interface{}, bool, string) {
+	exchangeID, marketType, err := s.repo.GetPositionExchangeAndMarket(positionID, userID)
+	if err != nil || exchangeID == 0 {
+		return nil, false, "Position data ERROR"
+	}
+
+	accountID, err := s.repo.GetPositionAccountID(positionID, userID)
+	if err != nil {
+		return nil, false, "Position data ERROR"
+	}
+	fee, err := s.fees.ResolveFee(userID, exchangeID, accountID, marketType)
+	if err != nil {
+		return nil, false, "Error load fees"
+	}
+	if fee == nil {
+		return nil, false, "Fees are not configured for exchange"
+	}
+
+	maker := strings.EqualFold(strings.TrimSpace(liquidity), "maker")
+	priceValue, _ := strconv.ParseFloat(strings.TrimSpace(price), 64)
+	volumeValue, _ := strconv.ParseFloat(strings.TrimSpace(volume), 64)
+	feeQuote, feeBase := EstimateTradeFee(marketType, action, priceValue, volumeValue, fee.Rate(maker))
+
+	source := "exchange"
+	if fee.AccountID != nil {
+		source = "account"
+	}
+
+	return map[string]interface{}{
+		"MAKER_FEE": fee.MakerFee,
+		"TAKER_FEE": fee.TakerFee,
+		"VIP_TIER":  fee.VIPTier,
+		"SOURCE":    source,
+		"FEE_QUOTE": strconv.FormatFloat(feeQuote, 'f', -1, 64),
+		"FEE_BASE":  strconv.FormatFloat(feeBase, 'f', -1, 64),
+	}, true, ""
+}
+
+// prefillTradeFee подставляет комиссию по ставке биржи, если поле комиссии не заполнено.
+func (s *PositionService) prefillTradeFee(userID, positionID int, action string, req map[string]string) map[string]string {
+	feeField := "add_trans_fee_quote"
+	exchangeID, marketType, err := s.repo.GetPositionExchangeAndMarket(positionID, userID)
+	if err != nil || exchangeID == 0 {
+		return req
+	}
+	if marketType == "SPOT" && action == "buy" {
+		feeField = "add_trans_fee_base"
+	}
+	if strings.TrimSpace(req[feeField]) != "" || strings.TrimSpace(req["add_trans_price"]) == "" || strings.TrimSpace(req["add_trans_volume"]) == "" {
+		return req
+	}
+
+	suggested, ok, _ := s.SuggestTransactionFee(userID, positionID, action, req["add_trans_price"], req["add_trans_volume"], req["add_trans_liquidity"])
+	if !ok {
+		return req
+	}
+
+	filled := make(map[string]string, len(req)+1)
+	for k, v := range req {
+		filled[k] = v
+	}
+	if feeField == "add_trans_fee_base" {
+		filled[feeField] = suggested["FEE_BASE"].(string)
+	} else {
+		filled[feeField] = suggested["FEE_QUOTE"].(string)
+	}
+	return filled
+}
+
 func (s *PositionService) GetTransactions(userID int, userTimezone string, positionID, start, length int) (int, []map[string]interface{}, string) {
 	count, err := s.repo.CountTransactionsByPosition(positionID, userID)
 	if err != nil {
@@ -285,6 +496,10 @@ func (s *PositionService) CreateTransaction(userID int, userTimezone string, req
 		return false, "Error format and create Transaction Date"
 	}
 
+	if typeValue != "funding" && action != "" {
+		req = s.prefillTradeFee(userID, positionID, action, req)
+	}
+
 	parseNum := func(name string) float64 {
 		v := strings.TrimSpace(req[name])
 		if v == "" {
@@ -665,6 +880,15 @@ func (s *PositionService) ClosePosition(userID, positionID int) (bool, string) {
 		return false, "Can't close. Position not 0"
 	}
 
+	// Ноги группы закрываются только вместе.
+	groupID, err := s.repo.GetPositionGroupID(positionID, userID)
+	if err != nil {
+		return false, "Can't close. Position not opened"
+	}
+	if groupID != nil {
+		return s.closeGroup(userID, *groupID)
+	}
+
 	updated, err := s.repo.ClosePosition(positionID, userID)
 	if err != nil {
 		return false, "Can't close. Position not opened"
@@ -673,6 +897,32 @@ func (s *PositionService) ClosePosition(userID, positionID int) (bool, string) {
 	return updated, ""
 }
 
+// closeGroup закрывает группу вместе со всеми открытыми ногами. Объём каждой ноги должен быть нулевым.
+func (s *PositionService) closeGroup(userID, groupID int) (bool, string) {
+	group, err := s.groups.FindByID(groupID, userID)
+	if err != nil || group == nil {
+		return false, "Position group not found"
+	}
+	if group.Status != "OPEN" {
+		return false, "Can't close. Group not opened"
+	}
+
+	legs, err := s.repo.GetPositions(userID, models.PositionFilter{GroupID: groupID}, maxGroupLegs, 0)
+	if err != nil {
+		return false, "Can't close. Failed to load group positions"
+	}
+	for _, leg := range legs {
+		if leg.Status == "OPEN" && leg.FinalPosition != nil && math.Abs(*leg.FinalPosition) > 1e-16 {
+			return false, fmt.Sprintf("Can't close. Position #%d %s not 0", leg.PositionID, leg.ContractName)
+		}
+	}
+
+	if err := s.groups.Close(groupID, userID, time.Now().UTC()); err != nil {
+		return false, "Can't close. Group not closed"
+	}
+	return true, ""
+}
+
 func (s *PositionService) DeletePosition(userID, positionID int) (bool, string) {
 	if positionID <= 0 {
 		return false, "Failed Position ID"
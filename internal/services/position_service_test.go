@@ -0,0 +1,25 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"testing"
+)
+
+func TestCheckPositionAccount(t *testing.T) {
+	cases := []struct {
+		name string
+		acc  *models.ExchangeAccount
+		want string
+	}{
+		{"own account on the exchange", &models.ExchangeAccount{ID: 1, UID: 5, ExID: 7}, ""},
+		{"missing account", nil, "Exchange account not found"},
+		{"another user's account", &models.ExchangeAccount{ID: 1, UID: 6, ExID: 7}, "Exchange account not found"},
+		{"deleted account", &models.ExchangeAccount{ID: 1, UID: 5, ExID: 7, Deleted: true}, "Exchange account not found"},
+		{"account of another exchange", &models.ExchangeAccount{ID: 1, UID: 5, ExID: 8}, "Exchange account belongs to another exchange"},
+	}
+	for _, tc := range cases {
+		if got := checkPositionAccount(tc.acc, 5, 7); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
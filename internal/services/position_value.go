@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"math"
+	"time"
+)
+
+// positionPriceTimeout - сколько ждать котировки бирж для оценки открытых позиций.
+const positionPriceTimeout = 10 * time.Second
+
+// PositionValue - позиция (нога группы) с текущей ценой инструмента.
+type PositionValue struct {
+	PositionID int     `json:"position_id"`
+	Name       string  `json:"name"`
+	Exchange   string  `json:"exchange"`
+	Market     string  `json:"market"`
+	Status     string  `json:"status"`
+	Position   float64 `json:"position"`  // Объём: > 0 - лонг, < 0 - шорт
+	AvgPrice   float64 `json:"avg_price"` // 0 - позиция закрыта в ноль
+	Price      float64 `json:"price"`     // Текущая цена, 0 - котировка недоступна
+	Realized   float64 `json:"realized"`
+	Funding    float64 `json:"funding"`
+	Fee        float64 `json:"fee"`
+}
+
+// hasPrice сообщает, есть ли для открытого объёма текущая цена.
+func (v PositionValue) hasPrice() bool {
+	return v.Position != 0 && v.Price > 0 && v.AvgPrice > 0
+}
+
+// Unrealized - нереализованный PnL по текущей цене (0, если цены нет).
+func (v PositionValue) Unrealized() float64 {
+	if !v.hasPrice() {
+		return 0
+	}
+	return v.Position * (v.Price - v.AvgPrice)
+}
+
+// Exposure - стоимость открытого объёма со знаком направления. Без текущей цены
+// считается по средней цене входа.
+func (v PositionValue) Exposure() float64 {
+	if v.Price > 0 {
+		return v.Position * v.Price
+	}
+	return v.Position * v.AvgPrice
+}
+
+// PositionTotals - суммарные показатели позиций (ног группы) в котируемой валюте.
+type PositionTotals struct {
+	NetExposure   float64 `json:"net_exposure"`   // Лонги минус шорты: остаточный риск хеджа
+	GrossExposure float64 `json:"gross_exposure"` // Сумма модулей стоимости позиций
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	NetFunding    float64 `json:"net_funding"` // Получено минус уплачено
+	Fees          float64 `json:"fees"`
+	MissingPrices int     `json:"missing_prices"` // Открытые позиции без текущей цены
+}
+
+// ComputePositionTotals суммирует показатели позиций.
+func ComputePositionTotals(values []PositionValue) PositionTotals {
+	var totals PositionTotals
+	for _, v := range values {
+		exposure := v.Exposure()
+		totals.NetExposure += exposure
+		totals.GrossExposure += math.Abs(exposure)
+		totals.RealizedPnL += v.Realized
+		totals.UnrealizedPnL += v.Unrealized()
+		totals.NetFunding += v.Funding
+		totals.Fees += v.Fee
+		if v.Position != 0 && !v.hasPrice() {
+			totals.MissingPrices++
+		}
+	}
+	return totals
+}
+
+func positionValueFromSummary(item *models.PositionSummary) PositionValue {
+	value := PositionValue{
+		PositionID: item.PositionID,
+		Name:       item.ContractName,
+		Exchange:   item.ExchangeName,
+		Market:     item.MarketType,
+		Status:     item.Status,
+	}
+	if item.FinalPosition != nil {
+		value.Position = *item.FinalPosition
+	}
+	if item.FinalAvgPrice != nil {
+		value.AvgPrice = *item.FinalAvgPrice
+	}
+	if item.TotalRealizedPnL != nil {
+		value.Realized = *item.TotalRealizedPnL
+	}
+	if item.FundingTotal != nil {
+		value.Funding = *item.FundingTotal
+	}
+	if item.FeeTotal != nil {
+		value.Fee = *item.FeeTotal
+	}
+	return value
+}
+
+// valuePositions оценивает позиции по кэшу котировок; результат в порядке items.
+// Ошибка котировки не прерывает оценку: у позиции остаётся Price = 0.
+func valuePositions(ctx context.Context, items []*models.PositionSummary) []PositionValue {
+	keys := make([]tickerKey, 0, len(items))
+	seen := make(map[tickerKey]bool)
+	itemKeys := make(map[int]tickerKey)
+	for _, item := range items {
+		if item.Status != "OPEN" || item.FinalPosition == nil || *item.FinalPosition == 0 {
+			continue
+		}
+		key := newTickerKey(item.ExchangeID, item.MarketType, item.ContractName)
+		itemKeys[item.PositionID] = key
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	priceCtx, cancel := context.WithTimeout(ctx, positionPriceTimeout)
+	defer cancel()
+	tickers := sharedPriceCache.Tickers(priceCtx, keys, 0)
+
+	values := make([]PositionValue, 0, len(items))
+	for _, item := range items {
+		value := positionValueFromSummary(item)
+		if key, ok := itemKeys[item.PositionID]; ok {
+			result := tickers[key]
+			if result.err != nil {
+				logger.Warn().Int("position_id", item.PositionID).Err(result.err).Msg("Position price failed")
+			} else if result.ticker != nil {
+				value.Price = result.ticker.Mid()
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// PositionValues возвращает позиции пользователя по фильтру; открытые оцениваются
+// по кэшу котировок.
+func (s *PositionService) PositionValues(ctx context.Context, userID int, filter models.PositionFilter) ([]PositionValue, error) {
+	count, err := s.repo.CountPositionsByUser(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.repo.GetPositions(userID, filter, count+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return valuePositions(ctx, items), nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPositionValueUnrealizedAndExposure(t *testing.T) {
+	short := PositionValue{Position: -2, AvgPrice: 100, Price: 90}
+	if got := short.Unrealized(); math.Abs(got-20) > 1e-9 {
+		t.Fatalf("short unrealized: got %v, want 20", got)
+	}
+	if got := short.Exposure(); math.Abs(got+180) > 1e-9 {
+		t.Fatalf("short exposure: got %v, want -180", got)
+	}
+	// Цены нет - PnL не считается, экспозиция по цене входа.
+	noPrice := PositionValue{Position: 3, AvgPrice: 10}
+	if got := noPrice.Unrealized(); got != 0 {
+		t.Fatalf("unrealized without price: got %v, want 0", got)
+	}
+	if got := noPrice.Exposure(); math.Abs(got-30) > 1e-9 {
+		t.Fatalf("exposure without price: got %v, want 30", got)
+	}
+}
+
+func TestComputePositionTotals(t *testing.T) {
+	legs := []PositionValue{
+		{Position: 1, AvgPrice: 100, Price: 110, Realized: 5, Funding: -0.5, Fee: 0.1},
+		{Position: -1, AvgPrice: 101, Price: 110, Realized: -2, Funding: 1.5, Fee: 0.2},
+		{Position: 0.5, AvgPrice: 50},
+		{Realized: 3, Fee: 0.05}, // Закрытая в ноль нога
+	}
+	totals := ComputePositionTotals(legs)
+	want := PositionTotals{
+		NetExposure:   25,
+		GrossExposure: 245,
+		RealizedPnL:   6,
+		UnrealizedPnL: 1,
+		NetFunding:    1,
+		Fees:          0.35,
+		MissingPrices: 1,
+	}
+	check := func(name string, got, want float64) {
+		if math.Abs(got-want) > 1e-9 {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+	check("net exposure", totals.NetExposure, want.NetExposure)
+	check("gross exposure", totals.GrossExposure, want.GrossExposure)
+	check("realized", totals.RealizedPnL, want.RealizedPnL)
+	check("unrealized", totals.UnrealizedPnL, want.UnrealizedPnL)
+	check("funding", totals.NetFunding, want.NetFunding)
+	check("fees", totals.Fees, want.Fees)
+	if totals.MissingPrices != want.MissingPrices {
+		t.Fatalf("missing prices: got %d, want %d", totals.MissingPrices, want.MissingPrices)
+	}
+}
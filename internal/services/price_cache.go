@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/repositories"
+	"sync"
+	"time"
+)
+
+// priceCacheTTL - сколько котировка считается свежей, если вызывающий не задал свой срок.
+const priceCacheTTL = 30 * time.Second
+
+// sharedPriceCache - котировки, которые процесс уже получил с бирж: их пополняют замеры спреда,
+// а читают оценка групп позиций и проверка правил уведомлений.
+var sharedPriceCache = NewPriceCache(repositories.NewExchangeRepository())
+
+// newTickerKey возвращает ключ котировки; тип рынка приводится к SPOT/FUTURES.
+func newTickerKey(exchangeID int, market, symbol string) tickerKey {
+	return tickerKey{ExID: exchangeID, Market: connectors.NormalizeMarket(market), Symbol: symbol}
+}
+
+type cachedTicker struct {
+	ticker    *connectors.Ticker
+	fetchedAt time.Time
+}
+
+// PriceCache - кэш bid/ask инструментов в памяти процесса.
+type PriceCache struct {
+	exchangeRepo *repositories.ExchangeRepository
+	mu           sync.Mutex
+	items        map[tickerKey]cachedTicker
+	now          func() time.Time
+}
+
+// NewPriceCache создаёт пустой кэш котировок.
+func NewPriceCache(exchangeRepo *repositories.ExchangeRepository) *PriceCache {
+	return &PriceCache{
+		exchangeRepo: exchangeRepo,
+		items:        make(map[tickerKey]cachedTicker),
+		now:          time.Now,
+	}
+}
+
+// Store сохраняет полученную котировку.
+func (c *PriceCache) Store(key tickerKey, ticker *connectors.Ticker) {
+	if ticker == nil {
+		return
+	}
+	c.mu.Lock()
+	c.items[key] = cachedTicker{ticker: ticker, fetchedAt: c.now()}
+	c.mu.Unlock()
+}
+
+// cached возвращает котировку, полученную не раньше maxAge назад.
+func (c *PriceCache) cached(key tickerKey, maxAge time.Duration) (*connectors.Ticker, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok || c.now().Sub(item.fetchedAt) > maxAge {
+		return nil, false
+	}
+	return item.ticker, true
+}
+
+// Tickers возвращает котировки инструментов keys: свежие (не старше maxAge, 0 - priceCacheTTL)
+// берутся из кэша, остальные запрашиваются с бирж и сохраняются.
+func (c *PriceCache) Tickers(ctx context.Context, keys []tickerKey, maxAge time.Duration) map[tickerKey]tickerResult {
+	if maxAge <= 0 {
+		maxAge = priceCacheTTL
+	}
+	results := make(map[tickerKey]tickerResult, len(keys))
+	missing := make([]tickerKey, 0)
+	for _, key := range keys {
+		if _, seen := results[key]; seen {
+			continue
+		}
+		if ticker, ok := c.cached(key, maxAge); ok {
+			results[key] = tickerResult{ticker: ticker}
+			continue
+		}
+		results[key] = tickerResult{}
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return results
+	}
+	for key, result := range fetchTickers(ctx, c.exchangeRepo, missing) {
+		if result.err == nil {
+			c.Store(key, result.ticker)
+		}
+		results[key] = result
+	}
+	return results
+}
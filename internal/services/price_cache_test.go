@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"testing"
+	"time"
+)
+
+func TestPriceCacheFreshness(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewPriceCache(nil)
+	cache.now = func() time.Time { return now }
+
+	key := newTickerKey(1, "futures", "BTCUSDT")
+	if key.Market != connectors.MarketFutures {
+		t.Fatalf("market must be normalized, got %q", key.Market)
+	}
+	cache.Store(key, &connectors.Ticker{Symbol: "BTCUSDT", Bid: 100, Ask: 102})
+
+	now = now.Add(20 * time.Second)
+	got := cache.Tickers(context.Background(), []tickerKey{key, key}, time.Minute)
+	if len(got) != 1 || got[key].err != nil || got[key].ticker.Mid() != 101 {
+		t.Fatalf("expected cached ticker, got %+v", got)
+	}
+
+	if _, ok := cache.cached(key, 10*time.Second); ok {
+		t.Fatal("ticker older than maxAge must not be returned from cache")
+	}
+}
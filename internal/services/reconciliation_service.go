@@ -0,0 +1,570 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReconcileTolerance - допустимое относительное расхождение расчёта с биржей
+// (0.001 = 0.1% от большего из двух значений).
+type ReconcileTolerance struct {
+	Size  float64
+	Price float64
+}
+
+// DefaultReconcileTolerance - допуск сверки по умолчанию (фоновая задача и endpoint без параметра).
+var DefaultReconcileTolerance = ReconcileTolerance{Size: 0.001, Price: 0.001}
+
+// reconcileEpsilon - абсолютное расхождение, которое считается нулевым (погрешность округления).
+const reconcileEpsilon = 1e-9
+
+// ReconciliationService сверяет открытые позиции с позициями и балансами на бирже.
+type ReconciliationService struct {
+	positions   *repositories.PositionRepository
+	accounts    *repositories.ExchangeAccountRepository
+	exchanges   *repositories.ExchangeRepository
+	instruments *repositories.InstrumentRepository
+	reports     *repositories.ReconciliationRepository
+}
+
+// NewReconciliationService создаёт сервис сверки позиций.
+func NewReconciliationService() *ReconciliationService {
+	return &ReconciliationService{
+		positions:   repositories.NewPositionRepository(),
+		accounts:    repositories.NewExchangeAccountRepository(),
+		exchanges:   repositories.NewExchangeRepository(),
+		instruments: repositories.NewInstrumentRepository(),
+		reports:     repositories.NewReconciliationRepository(),
+	}
+}
+
+func withinTolerance(a, b, rel float64) bool {
+	diff := math.Abs(a - b)
+	return diff <= reconcileEpsilon || diff <= rel*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// sizeAggregate накапливает суммарный размер и средневзвешенную цену нескольких позиций.
+// Цена определена, только если все позиции в одну сторону и у всех есть цена.
+type sizeAggregate struct {
+	size     float64
+	notional float64
+	abs      float64
+	mixed    bool
+	noPrice  bool
+	ids      []int
+}
+
+func (a *sizeAggregate) add(size float64, price *float64) {
+	if a.abs > 0 && size != 0 && math.Signbit(size) != math.Signbit(a.size) {
+		a.mixed = true
+	}
+	a.size += size
+	if price == nil {
+		if size != 0 {
+			a.noPrice = true
+		}
+		return
+	}
+	a.notional += math.Abs(size) * *price
+	a.abs += math.Abs(size)
+}
+
+func (a *sizeAggregate) price() *float64 {
+	if a.mixed || a.noPrice || a.abs == 0 {
+		return nil
+	}
+	value := a.notional / a.abs
+	return &value
+}
+
+func positionSize(p *models.PositionSummary) float64 {
+	if p.FinalPosition == nil {
+		return 0
+	}
+	return *p.FinalPosition
+}
+
+// CompareFutures сверяет открытые фьючерсные позиции одной биржи с позициями на бирже.
+// Позиции сравниваются по символу контракта (без учёта регистра); позиции биржи,
+// которых нет в системе, возвращаются со статусом untracked.
+func CompareFutures(positions []*models.PositionSummary, live []connectors.LivePosition, tol ReconcileTolerance) []*models.ReconciliationItem {
+	calc := make(map[string]*sizeAggregate)
+	var symbols []string
+	for _, p := range positions {
+		symbol := strings.ToUpper(strings.TrimSpace(p.ContractName))
+		agg, ok := calc[symbol]
+		if !ok {
+			agg = &sizeAggregate{}
+			calc[symbol] = agg
+			symbols = append(symbols, symbol)
+		}
+		agg.add(positionSize(p), p.FinalAvgPrice)
+		agg.ids = append(agg.ids, p.PositionID)
+	}
+
+	exchange := make(map[string]*sizeAggregate)
+	for _, lp := range live {
+		symbol := strings.ToUpper(lp.Symbol)
+		agg, ok := exchange[symbol]
+		if !ok {
+			agg = &sizeAggregate{}
+			exchange[symbol] = agg
+		}
+		price := lp.EntryPrice
+		agg.add(lp.Size, &price)
+	}
+
+	items := make([]*models.ReconciliationItem, 0, len(symbols))
+	for _, symbol := range symbols {
+		c := calc[symbol]
+		item := &models.ReconciliationItem{
+			MarketType:  connectors.MarketFutures,
+			Symbol:      symbol,
+			PositionIDs: c.ids,
+			CalcSize:    c.size,
+			CalcPrice:   c.price(),
+			Status:      models.ReconcileOK,
+		}
+		l, ok := exchange[symbol]
+		switch {
+		case !ok && withinTolerance(c.size, 0, tol.Size):
+			// Позиция в системе не закрыта, но её размер нулевой - на бирже её и нет.
+		case !ok:
+			item.Status = models.ReconcileMissing
+			item.Message = "no open position on the exchange"
+		default:
+			liveSize := l.size
+			item.LiveSize = &liveSize
+			item.LivePrice = l.price()
+			if !withinTolerance(c.size, l.size, tol.Size) {
+				item.Status = models.ReconcileMismatch
+				item.Message = fmt.Sprintf("size differs by %g", l.size-c.size)
+			} else if item.CalcPrice != nil && item.LivePrice != nil && !withinTolerance(*item.CalcPrice, *item.LivePrice, tol.Price) {
+				item.Status = models.ReconcileMismatch
+				item.Message = fmt.Sprintf("average price differs by %.4f%%", (*item.LivePrice / *item.CalcPrice - 1)*100)
+			}
+		}
+		items = append(items, item)
+	}
+
+	var untracked []string
+	for symbol := range exchange {
+		if _, ok := calc[symbol]; !ok {
+			untracked = append(untracked, symbol)
+		}
+	}
+	sort.Strings(untracked)
+	for _, symbol := range untracked {
+		l := exchange[symbol]
+		liveSize := l.size
+		items = append(items, &models.ReconciliationItem{
+			MarketType:  connectors.MarketFutures,
+			Symbol:      symbol,
+			PositionIDs: []int{},
+			LiveSize:    &liveSize,
+			LivePrice:   l.price(),
+			Status:      models.ReconcileUntracked,
+			Message:     "open position on the exchange is not tracked",
+		})
+	}
+	return items
+}
+
+// CompareSpot сверяет открытые спотовые позиции одной биржи с остатками активов.
+// assets - базовый актив каждой позиции по её ID. Остатки фьючерсного кошелька не учитываются.
+// Остаток больше суммы позиций не считается расхождением: на счёте могут быть монеты вне позиций.
+func CompareSpot(positions []*models.PositionSummary, assets map[int]string, balances []connectors.Balance, tol ReconcileTolerance) []*models.ReconciliationItem {
+	calc := make(map[string]*sizeAggregate)
+	symbols := make(map[string][]string)
+	var order []string
+	for _, p := range positions {
+		asset := assets[p.PositionID]
+		agg, ok := calc[asset]
+		if !ok {
+			agg = &sizeAggregate{}
+			calc[asset] = agg
+			order = append(order, asset)
+		}
+		agg.add(positionSize(p), p.FinalAvgPrice)
+		agg.ids = append(agg.ids, p.PositionID)
+		symbol := strings.ToUpper(strings.TrimSpace(p.ContractName))
+		if !containsString(symbols[asset], symbol) {
+			symbols[asset] = append(symbols[asset], symbol)
+		}
+	}
+
+	held := make(map[string]float64)
+	for _, b := range balances {
+		if b.Wallet == connectors.MarketFutures {
+			continue
+		}
+		held[strings.ToUpper(b.Asset)] += b.Total()
+	}
+
+	items := make([]*models.ReconciliationItem, 0, len(order))
+	for _, asset := range order {
+		c := calc[asset]
+		item := &models.ReconciliationItem{
+			MarketType:  connectors.MarketSpot,
+			Symbol:      strings.Join(symbols[asset], ","),
+			Asset:       asset,
+			PositionIDs: c.ids,
+			CalcSize:    c.size,
+			CalcPrice:   c.price(),
+			Status:      models.ReconcileOK,
+		}
+		if asset == "" {
+			item.Status = models.ReconcileError
+			item.Message = "base asset is unknown: sync the instrument catalog"
+			items = append(items, item)
+			continue
+		}
+		balance := held[asset]
+		item.LiveSize = &balance
+		switch {
+		case withinTolerance(c.size, balance, tol.Size):
+		case balance < c.size:
+			item.Status = models.ReconcileMismatch
+			item.Message = fmt.Sprintf("balance is lower than positions by %g", c.size-balance)
+		default:
+			item.Message = fmt.Sprintf("balance exceeds positions by %g", balance-c.size)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// liveData - позиции и остатки аккаунтов пользователя на одной бирже.
+type liveData struct {
+	positions    []connectors.LivePosition
+	balances     []connectors.Balance
+	positionsErr error
+	balancesErr  error
+}
+
+// fetchLive читает позиции (если нужны) и остатки (если нужны) со всех аккаунтов.
+func fetchLive(ctx context.Context, connector connectors.Connector, accounts []*models.ExchangeAccount, needPositions, needBalances bool) liveData {
+	var data liveData
+	if needPositions {
+		provider, ok := connector.(connectors.PositionProvider)
+		if !ok {
+			data.positionsErr = fmt.Errorf("%w: positions for %s", connectors.ErrNotSupported, connector.Name())
+		}
+		for _, acc := range accounts {
+			if !ok {
+				break
+			}
+			items, err := provider.FetchPositions(ctx, CredentialsOf(acc))
+			if err != nil {
+				data.positionsErr = fmt.Errorf("account %s: %w", acc.AccountName, err)
+				break
+			}
+			data.positions = append(data.positions, items...)
+		}
+	}
+	if needBalances {
+		provider, ok := connector.(connectors.BalanceProvider)
+		if !ok {
+			data.balancesErr = fmt.Errorf("%w: balances for %s", connectors.ErrNotSupported, connector.Name())
+		}
+		for _, acc := range accounts {
+			if !ok {
+				break
+			}
+			items, err := provider.FetchBalances(ctx, CredentialsOf(acc))
+			if err != nil {
+				data.balancesErr = fmt.Errorf("account %s: %w", acc.AccountName, err)
+				break
+			}
+			data.balances = append(data.balances, items...)
+		}
+	}
+	return data
+}
+
+// statusItems возвращает позиции одним элементом на контракт с общим статусом (ошибка, нет аккаунта).
+func statusItems(positions []*models.PositionSummary, status, message string) []*models.ReconciliationItem {
+	bySymbol := make(map[string]*models.ReconciliationItem)
+	var items []*models.ReconciliationItem
+	for _, p := range positions {
+		market := strings.ToUpper(p.MarketType)
+		symbol := strings.ToUpper(strings.TrimSpace(p.ContractName))
+		key := market + ":" + symbol
+		item, ok := bySymbol[key]
+		if !ok {
+			item = &models.ReconciliationItem{MarketType: market, Symbol: symbol, PositionIDs: []int{}, Status: status, Message: message}
+			bySymbol[key] = item
+			items = append(items, item)
+		}
+		item.PositionIDs = append(item.PositionIDs, p.PositionID)
+		item.CalcSize += positionSize(p)
+	}
+	return items
+}
+
+// reconcileGroup сверяет позиции пользователя на бирже с суммарными данными аккаунтов accounts.
+func (s *ReconciliationService) reconcileGroup(ctx context.Context, exchange *models.Exchange, connector connectors.Connector, positions []*models.PositionSummary, accounts []*models.ExchangeAccount, tol ReconcileTolerance) []*models.ReconciliationItem {
+	if len(accounts) == 0 {
+		return statusItems(positions, models.ReconcileNoAccount, "no active exchange account")
+	}
+
+	var futures, spot []*models.PositionSummary
+	for _, p := range positions {
+		if strings.EqualFold(p.MarketType, connectors.MarketSpot) {
+			spot = append(spot, p)
+		} else {
+			futures = append(futures, p)
+		}
+	}
+
+	_, canPositions := connector.(connectors.PositionProvider)
+	live := fetchLive(ctx, connector, accounts, len(futures) > 0 || canPositions, len(spot) > 0)
+
+	var items []*models.ReconciliationItem
+	switch {
+	case live.positionsErr != nil && len(futures) > 0:
+		items = append(items, statusItems(futures, models.ReconcileError, live.positionsErr.Error())...)
+	case live.positionsErr == nil:
+		items = append(items, CompareFutures(futures, live.positions, tol)...)
+	}
+
+	if len(spot) > 0 {
+		if live.balancesErr != nil {
+			items = append(items, statusItems(spot, models.ReconcileError, live.balancesErr.Error())...)
+		} else {
+			assets := make(map[int]string, len(spot))
+			for _, p := range spot {
+				instrument, err := s.instruments.FindBySymbol(exchange.ID, connectors.MarketSpot, p.ContractName)
+				if err == nil && instrument != nil {
+					assets[p.PositionID] = strings.ToUpper(instrument.BaseAsset)
+				}
+			}
+			items = append(items, CompareSpot(spot, assets, live.balances, tol)...)
+		}
+	}
+	return items
+}
+
+// reconcileLink - открытые позиции, привязанные к одному аккаунту.
+type reconcileLink struct {
+	AccountID int
+	Account   *models.ExchangeAccount // nil - аккаунт отключён, удалён, чужой или другой биржи
+	Positions []*models.PositionSummary
+}
+
+// reconcilePlan - с какими аккаунтами сверяются позиции пользователя на одной бирже.
+type reconcilePlan struct {
+	Linked    []reconcileLink
+	Unlinked  []*models.PositionSummary
+	Targets   []*models.ExchangeAccount // аккаунты для позиций без аккаунта
+	Untracked bool                      // показывать позиции бирж вне системы
+}
+
+// planReconcile распределяет позиции биржи exchangeID по аккаунтам. Позиции, привязанные
+// к аккаунту, сверяются с этим аккаунтом. Позиции без аккаунта сверяются с активными
+// аккаунтами биржи, к которым не привязано ни одной открытой позиции, а если таких нет -
+// со всеми активными аккаунтами (без позиций биржи вне системы: они уже показаны по своим
+// аккаунтам). accounts - аккаунты пользователя по ID.
+func planReconcile(exchangeID int, positions []*models.PositionSummary, accounts map[int]*models.ExchangeAccount) reconcilePlan {
+	var plan reconcilePlan
+	linked := make(map[int][]*models.PositionSummary)
+	var linkedIDs []int
+	for _, p := range positions {
+		if p.AccountID == nil {
+			plan.Unlinked = append(plan.Unlinked, p)
+			continue
+		}
+		if _, ok := linked[*p.AccountID]; !ok {
+			linkedIDs = append(linkedIDs, *p.AccountID)
+		}
+		linked[*p.AccountID] = append(linked[*p.AccountID], p)
+	}
+	sort.Ints(linkedIDs)
+	for _, accountID := range linkedIDs {
+		link := reconcileLink{AccountID: accountID, Positions: linked[accountID]}
+		if acc := accounts[accountID]; acc != nil && acc.IsActive() && acc.ExID == exchangeID {
+			link.Account = acc
+		}
+		plan.Linked = append(plan.Linked, link)
+	}
+
+	var active, free []*models.ExchangeAccount
+	for _, acc := range accounts {
+		if acc.ExID != exchangeID || !acc.IsActive() {
+			continue
+		}
+		active = append(active, acc)
+		if _, ok := linked[acc.ID]; !ok {
+			free = append(free, acc)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+	sort.Slice(free, func(i, j int) bool { return free[i].ID < free[j].ID })
+	plan.Targets, plan.Untracked = free, true
+	if len(free) == 0 {
+		plan.Targets, plan.Untracked = active, false
+	}
+	return plan
+}
+
+// reconcileExchange сверяет позиции пользователя на одной бирже по плану planReconcile.
+func (s *ReconciliationService) reconcileExchange(ctx context.Context, exchangeID int, positions []*models.PositionSummary, accounts map[int]*models.ExchangeAccount, tol ReconcileTolerance) []*models.ReconciliationItem {
+	exchange, err := s.exchanges.FindByID(exchangeID)
+	if err != nil {
+		return statusItems(positions, models.ReconcileError, err.Error())
+	}
+	plan := planReconcile(exchangeID, positions, accounts)
+
+	connector, err := connectors.New(exchange)
+	if err != nil {
+		items := statusItems(positions, models.ReconcileError, err.Error())
+		for _, item := range items {
+			item.ExID, item.ExchangeName = exchange.ID, exchange.Name
+		}
+		return items
+	}
+
+	var items []*models.ReconciliationItem
+	add := func(group []*models.ReconciliationItem, account *models.ExchangeAccount, untracked bool) {
+		for _, item := range group {
+			if !untracked && item.Status == models.ReconcileUntracked {
+				continue
+			}
+			item.ExID, item.ExchangeName = exchange.ID, exchange.Name
+			if account != nil {
+				id := account.ID
+				item.AccountID, item.AccountName = &id, account.AccountName
+			}
+			items = append(items, item)
+		}
+	}
+
+	for _, link := range plan.Linked {
+		if link.Account == nil {
+			group := statusItems(link.Positions, models.ReconcileNoAccount, "linked exchange account is disabled, deleted or on another exchange")
+			for _, item := range group {
+				id := link.AccountID
+				item.AccountID = &id
+				if acc := accounts[link.AccountID]; acc != nil {
+					item.AccountName = acc.AccountName
+				}
+			}
+			add(group, nil, true)
+			continue
+		}
+		add(s.reconcileGroup(ctx, exchange, connector, link.Positions, []*models.ExchangeAccount{link.Account}, tol), link.Account, true)
+	}
+
+	if len(plan.Unlinked) == 0 && !plan.Untracked {
+		return items
+	}
+	var single *models.ExchangeAccount
+	if len(plan.Targets) == 1 {
+		single = plan.Targets[0]
+	}
+	add(s.reconcileGroup(ctx, exchange, connector, plan.Unlinked, plan.Targets, tol), single, plan.Untracked)
+	return items
+}
+
+// Reconcile сверяет все открытые позиции пользователя с биржами и сохраняет результат.
+func (s *ReconciliationService) Reconcile(ctx context.Context, userID int, tol ReconcileTolerance) (*models.ReconciliationReport, error) {
+	accounts, err := s.accounts.FindAllByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	positionsByExchange := make(map[int][]*models.PositionSummary)
+	accountsByID := make(map[int]*models.ExchangeAccount, len(accounts))
+	var exchangeIDs []int
+	seen := make(map[int]bool)
+	addExchange := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			exchangeIDs = append(exchangeIDs, id)
+		}
+	}
+	err = s.positions.EachPosition(userID, models.PositionFilter{Status: "OPEN"}, func(p *models.PositionSummary) error {
+		positionsByExchange[p.ExchangeID] = append(positionsByExchange[p.ExchangeID], p)
+		addExchange(p.ExchangeID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, acc := range accounts {
+		accountsByID[acc.ID] = acc
+		if acc.IsActive() {
+			addExchange(acc.ExID)
+		}
+	}
+	sort.Ints(exchangeIDs)
+
+	report := &models.ReconciliationReport{UID: userID, RunAt: time.Now().UTC().Truncate(time.Second), Items: []*models.ReconciliationItem{}}
+	for _, exchangeID := range exchangeIDs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		items := s.reconcileExchange(ctx, exchangeID, positionsByExchange[exchangeID], accountsByID, tol)
+		for _, item := range items {
+			if item.IsMismatch() {
+				report.Mismatches++
+			}
+			report.Items = append(report.Items, item)
+		}
+	}
+
+	if err := s.reports.ReplaceReport(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// LastReport возвращает последнюю сохранённую сверку пользователя (nil - сверок не было).
+func (s *ReconciliationService) LastReport(userID int) (*models.ReconciliationReport, error) {
+	return s.reports.FindReport(userID)
+}
+
+// ReconcileAll сверяет позиции всех пользователей с открытыми позициями (фоновая задача reconcile).
+// Возвращает общее количество расхождений.
+func (s *ReconciliationService) ReconcileAll(ctx context.Context) (int, error) {
+	userIDs, err := s.positions.GetOpenPositionUsers()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	var errs []error
+	for _, userID := range userIDs {
+		report, err := s.Reconcile(ctx, userID, DefaultReconcileTolerance)
+		if err != nil {
+			if ctx.Err() != nil {
+				return total, ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+			continue
+		}
+		if report.Mismatches > 0 {
+			logger.Warn().Int("user_id", userID).Int("mismatches", report.Mismatches).Msg("position reconciliation mismatches")
+		}
+		total += report.Mismatches
+	}
+	return total, errors.Join(errs...)
+}
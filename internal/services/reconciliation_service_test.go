@@ -0,0 +1,184 @@
+package services
+
+import (
+	"ctweb/internal/connectors"
+	"ctweb/internal/models"
+	"fmt"
+	"testing"
+)
+
+func openPosition(id int, contract, market string, size, price float64) *models.PositionSummary {
+	return &models.PositionSummary{PositionID: id, ContractName: contract, MarketType: market, Status: "OPEN", FinalPosition: &size, FinalAvgPrice: &price}
+}
+
+func TestCompareFutures(t *testing.T) {
+	positions := []*models.PositionSummary{
+		openPosition(1, "btcusdt", "FUTURES", 0.3, 60000),
+		openPosition(2, "BTCUSDT", "FUTURES", 0.2, 65000),
+		openPosition(3, "ETHUSDT", "FUTURES", -2, 3000),
+		openPosition(4, "SOLUSDT", "FUTURES", 10, 150),
+		openPosition(5, "XRPUSDT", "FUTURES", 0, 0.5),
+	}
+	live := []connectors.LivePosition{
+		{Symbol: "BTCUSDT", Size: 0.5, EntryPrice: 62000},
+		{Symbol: "ETHUSDT", Size: -1.5, EntryPrice: 3000},
+		{Symbol: "DOGEUSDT", Size: 1000, EntryPrice: 0.1},
+	}
+
+	items := CompareFutures(positions, live, DefaultReconcileTolerance)
+	if len(items) != 5 {
+		t.Fatalf("expected 5 items, got %d", len(items))
+	}
+	byStatus := map[string]string{}
+	for _, item := range items {
+		byStatus[item.Symbol] = item.Status
+	}
+	want := map[string]string{
+		"BTCUSDT":  models.ReconcileOK,
+		"ETHUSDT":  models.ReconcileMismatch,
+		"SOLUSDT":  models.ReconcileMissing,
+		"XRPUSDT":  models.ReconcileOK,
+		"DOGEUSDT": models.ReconcileUntracked,
+	}
+	for symbol, status := range want {
+		if byStatus[symbol] != status {
+			t.Fatalf("%s: expected %s, got %s", symbol, status, byStatus[symbol])
+		}
+	}
+	if items[0].CalcSize != 0.5 || len(items[0].PositionIDs) != 2 || items[0].CalcPrice == nil || *items[0].CalcPrice != 62000 {
+		t.Fatalf("BTCUSDT legs must be aggregated with weighted price, got %+v", items[0])
+	}
+
+	// Средняя цена за пределами допуска - расхождение даже при совпадающем размере.
+	live[0].EntryPrice = 61000
+	items = CompareFutures(positions[:2], live[:1], DefaultReconcileTolerance)
+	if items[0].Status != models.ReconcileMismatch {
+		t.Fatalf("expected price mismatch, got %+v", items[0])
+	}
+	items = CompareFutures(positions[:2], live[:1], ReconcileTolerance{Size: 0.001, Price: 0.02})
+	if items[0].Status != models.ReconcileOK {
+		t.Fatalf("price within 2%% tolerance must be ok, got %+v", items[0])
+	}
+}
+
+func TestCompareSpot(t *testing.T) {
+	positions := []*models.PositionSummary{
+		openPosition(1, "BTCUSDT", "SPOT", 0.5, 60000),
+		openPosition(2, "BTCUSDC", "SPOT", 0.1, 61000),
+		openPosition(3, "ETHUSDT", "SPOT", 2, 3000),
+		openPosition(4, "SOLUSDT", "SPOT", 10, 150),
+		openPosition(5, "NEWUSDT", "SPOT", 1, 1),
+	}
+	assets := map[int]string{1: "BTC", 2: "BTC", 3: "ETH", 4: "SOL"}
+	balances := []connectors.Balance{
+		{Wallet: connectors.MarketSpot, Asset: "btc", Free: 0.4, Locked: 0.2},
+		{Wallet: connectors.MarketSpot, Asset: "ETH", Free: 1},
+		{Wallet: connectors.MarketFutures, Asset: "ETH", Free: 5},
+		{Wallet: connectors.MarketSpot, Asset: "SOL", Free: 12},
+	}
+
+	items := CompareSpot(positions, assets, balances, DefaultReconcileTolerance)
+	if len(items) != 4 {
+		t.Fatalf("expected 4 items, got %d", len(items))
+	}
+	if items[0].Asset != "BTC" || items[0].Symbol != "BTCUSDT,BTCUSDC" || items[0].Status != models.ReconcileOK {
+		t.Fatalf("BTC positions must match the balance, got %+v", items[0])
+	}
+	if items[1].Status != models.ReconcileMismatch || *items[1].LiveSize != 1 {
+		t.Fatalf("futures wallet must not cover spot ETH, got %+v", items[1])
+	}
+	if items[2].Status != models.ReconcileOK || items[2].Message == "" {
+		t.Fatalf("surplus balance must be ok with a note, got %+v", items[2])
+	}
+	if items[3].Status != models.ReconcileError {
+		t.Fatalf("unknown base asset must be reported as error, got %+v", items[3])
+	}
+}
+
+func TestPlanReconcile(t *testing.T) {
+	linkedTo := func(p *models.PositionSummary, accountID int) *models.PositionSummary {
+		p.AccountID = &accountID
+		return p
+	}
+	accounts := map[int]*models.ExchangeAccount{
+		1: {ID: 1, ExID: 7, UID: 5, Active: true},
+		2: {ID: 2, ExID: 7, UID: 5, Active: true},
+		3: {ID: 3, ExID: 7, UID: 5, Active: false},
+		4: {ID: 4, ExID: 8, UID: 5, Active: true},
+	}
+	cases := []struct {
+		name      string
+		positions []*models.PositionSummary
+		linked    map[int]bool // аккаунт ссылки -> сверяется с ним
+		unlinked  int
+		targets   []int
+		untracked bool
+	}{
+		{
+			name:      "linked position is checked against its account, the rest against free accounts",
+			positions: []*models.PositionSummary{linkedTo(openPosition(1, "BTCUSDT", "FUTURES", 1, 1), 1), openPosition(2, "ETHUSDT", "FUTURES", 1, 1)},
+			linked:    map[int]bool{1: true},
+			unlinked:  1,
+			targets:   []int{2},
+			untracked: true,
+		},
+		{
+			name:      "unlinked positions use every active account of the exchange",
+			positions: []*models.PositionSummary{openPosition(1, "BTCUSDT", "FUTURES", 1, 1)},
+			linked:    map[int]bool{},
+			unlinked:  1,
+			targets:   []int{1, 2},
+			untracked: true,
+		},
+		{
+			name: "without free accounts unlinked positions use all active accounts",
+			positions: []*models.PositionSummary{linkedTo(openPosition(1, "BTCUSDT", "FUTURES", 1, 1), 1),
+				linkedTo(openPosition(2, "BTCUSDT", "FUTURES", 1, 1), 2), openPosition(3, "ETHUSDT", "FUTURES", 1, 1)},
+			linked:    map[int]bool{1: true, 2: true},
+			unlinked:  1,
+			targets:   []int{1, 2},
+			untracked: false,
+		},
+		{
+			name:      "another user's account is not used",
+			positions: []*models.PositionSummary{linkedTo(openPosition(1, "BTCUSDT", "FUTURES", 1, 1), 99)},
+			linked:    map[int]bool{99: false},
+			targets:   []int{1, 2},
+			untracked: true,
+		},
+		{
+			name:      "account of another exchange is not used",
+			positions: []*models.PositionSummary{linkedTo(openPosition(1, "BTCUSDT", "FUTURES", 1, 1), 4)},
+			linked:    map[int]bool{4: false},
+			targets:   []int{1, 2},
+			untracked: true,
+		},
+		{
+			name:      "disabled account is not used",
+			positions: []*models.PositionSummary{linkedTo(openPosition(1, "BTCUSDT", "FUTURES", 1, 1), 3)},
+			linked:    map[int]bool{3: false},
+			targets:   []int{1, 2},
+			untracked: true,
+		},
+	}
+	for _, tc := range cases {
+		plan := planReconcile(7, tc.positions, accounts)
+		if len(plan.Linked) != len(tc.linked) || len(plan.Unlinked) != tc.unlinked || plan.Untracked != tc.untracked {
+			t.Errorf("%s: unexpected plan %+v", tc.name, plan)
+			continue
+		}
+		for _, link := range plan.Linked {
+			want, ok := tc.linked[link.AccountID]
+			if !ok || (link.Account != nil) != want || (link.Account != nil && link.Account.ID != link.AccountID) {
+				t.Errorf("%s: unexpected link %+v", tc.name, link)
+			}
+		}
+		targets := make([]int, 0, len(plan.Targets))
+		for _, acc := range plan.Targets {
+			targets = append(targets, acc.ID)
+		}
+		if fmt.Sprint(targets) != fmt.Sprint(tc.targets) {
+			t.Errorf("%s: targets %v, want %v", tc.name, targets, tc.targets)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportHTMLTemplate - отчёт для письма и скачивания: только встроенные стили,
+// чтобы почтовые клиенты показывали его без внешних ресурсов.
+var reportHTMLTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"num":    formatAlertNumber,
+	"signed": formatSignedNumber,
+	"date":   func(t time.Time) string { return t.Format("2006-01-02") },
+	"dt":     reportTime,
+	"pnl": func(value float64) template.CSS {
+		switch {
+		case value > 0:
+			return "color:#47a447"
+		case value < 0:
+			return "color:#d2322d"
+		}
+		return ""
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Data.Title}}</title></head>
+<body style="font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#333">
+<h2 style="margin-bottom:4px">{{.Data.Title}}</h2>
+<p style="color:#777;margin-top:0">{{date .Data.Start}}{{if ne .Data.Period "daily"}} — {{date .Data.LastDay}}{{end}} ({{.Location}}), generated {{dt .Data.Generated .Loc}}</p>
+
+<table cellpadding="4" cellspacing="0" style="border-collapse:collapse;margin-bottom:16px">
+<tr><td>Realized PnL</td><td style="font-weight:bold;{{pnl .Data.Summary.RealizedPnL}}">{{signed .Data.Summary.RealizedPnL}}</td></tr>
+<tr><td>Fees</td><td>{{num .Data.Summary.Fees}}</td></tr>
+<tr><td>Funding</td><td style="{{pnl .Data.Summary.Funding}}">{{signed .Data.Summary.Funding}}</td></tr>
+<tr><td>New positions</td><td>{{.Data.Summary.NewPositions}}</td></tr>
+<tr><td>Closed positions</td><td>{{.Data.Summary.ClosedPositions}}</td></tr>
+<tr><td>Open positions</td><td>{{.Data.Summary.OpenPositions}}{{if .Data.Summary.Open.MissingPrices}} ({{.Data.Summary.Open.MissingPrices}} without price){{end}}</td></tr>
+<tr><td>Net exposure</td><td>{{num .Data.Summary.Open.NetExposure}}</td></tr>
+<tr><td>Gross exposure</td><td>{{num .Data.Summary.Open.GrossExposure}}</td></tr>
+<tr><td>Unrealized PnL</td><td style="{{pnl .Data.Summary.Open.UnrealizedPnL}}">{{signed .Data.Summary.Open.UnrealizedPnL}}</td></tr>
+</table>
+
+{{if .Data.Lines}}
+<table cellpadding="4" cellspacing="0" border="1" style="border-collapse:collapse;border-color:#ddd;font-size:12px">
+<tr style="background:#f5f5f5">
+<th>ID</th><th>Position</th><th>Exchange</th><th>Status</th><th>Created</th><th>Closed</th>
+<th>Trades</th><th>Fees</th><th>Funding</th><th>Realized PnL</th>
+<th>Position</th><th>Avg price</th><th>Price</th><th>Exposure</th><th>Unrealized PnL</th>
+</tr>
+{{range .Data.Lines}}
+<tr>
+<td>{{.PositionID}}</td>
+<td>{{.Name}}{{if .OpenedInPeriod}} <small style="color:#47a447">new</small>{{end}}</td>
+<td>{{.Exchange}} {{.Market}}</td>
+<td>{{.Status}}</td>
+<td>{{if .Created}}{{dt .Created $.Loc}}{{end}}</td>
+<td>{{if .ClosedInPeriod}}{{dt .Closed $.Loc}}{{end}}</td>
+<td align="right">{{.Trades}}</td>
+<td align="right">{{num .PeriodFee}}</td>
+<td align="right">{{signed .PeriodFunding}}</td>
+<td align="right" style="{{pnl .PeriodRealized}}">{{if .ClosedInPeriod}}{{signed .PeriodRealized}}{{end}}</td>
+{{if eq .Status "OPEN"}}
+<td align="right">{{num .Position}}</td>
+<td align="right">{{num .AvgPrice}}</td>
+<td align="right">{{if gt .Price 0.0}}{{num .Price}}{{else}}n/a{{end}}</td>
+<td align="right">{{num .Exposure}}</td>
+<td align="right" style="{{pnl .Unrealized}}">{{signed .Unrealized}}</td>
+{{else}}
+<td></td><td></td><td></td><td></td><td></td>
+{{end}}
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No position activity in this period.</p>
+{{end}}
+<p style="color:#777;font-size:11px">Realized PnL is booked in the period a position is closed and already includes its fees and funding.
+Open positions, exposure and unrealized PnL are valued at the time the report was generated.</p>
+</body>
+</html>
+`))
+
+func reportTime(t interface{}, loc *time.Location) string {
+	switch v := t.(type) {
+	case time.Time:
+		return v.In(loc).Format("2006-01-02 15:04:05")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.In(loc).Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
+
+// renderReportHTML возвращает отчёт в HTML.
+func renderReportHTML(data ReportData) (string, error) {
+	loc := data.Start.Location()
+	var buf bytes.Buffer
+	err := reportHTMLTemplate.Execute(&buf, struct {
+		Data     ReportData
+		Loc      *time.Location
+		Location string
+	}{data, loc, loc.String()})
+	return buf.String(), err
+}
+
+// reportCSVHeader - колонки CSV-отчёта; последняя строка TOTAL содержит итоги.
+var reportCSVHeader = []string{
+	"Position ID", "Name", "Exchange", "Market", "Status", "Created", "Closed",
+	"Opened In Period", "Closed In Period", "Trades", "Fees", "Funding", "Realized PnL",
+	"Position", "Avg Price", "Price", "Exposure", "Unrealized PnL",
+}
+
+// renderReportCSV возвращает строки отчёта в CSV (время - в часовом поясе отчёта).
+func renderReportCSV(data ReportData) (string, error) {
+	loc := data.Start.Location()
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	flag := func(v bool) string {
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(reportCSVHeader); err != nil {
+		return "", err
+	}
+	for _, line := range data.Lines {
+		closed := ""
+		if line.Status != "OPEN" {
+			closed = reportTime(line.Closed, loc)
+		}
+		row := []string{
+			strconv.Itoa(line.PositionID), line.Name, line.Exchange, strings.ToUpper(line.Market), line.Status,
+			reportTime(line.Created, loc), closed, flag(line.OpenedInPeriod), flag(line.ClosedInPeriod),
+			strconv.Itoa(line.Trades), num(line.PeriodFee), num(line.PeriodFunding), num(line.PeriodRealized),
+			"", "", "", "", "",
+		}
+		if line.Status == "OPEN" {
+			price := ""
+			if line.Price > 0 {
+				price = num(line.Price)
+			}
+			row[13], row[14], row[15], row[16], row[17] = num(line.Position), num(line.AvgPrice), price, num(line.Exposure()), num(line.Unrealized())
+		}
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+	s := data.Summary
+	total := []string{
+		"TOTAL", "", "", "", "", "", "", strconv.Itoa(s.NewPositions), strconv.Itoa(s.ClosedPositions), "",
+		num(s.Fees), num(s.Funding), num(s.RealizedPnL), "", "", "", num(s.Open.NetExposure), num(s.Open.UnrealizedPnL),
+	}
+	if err := w.Write(total); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
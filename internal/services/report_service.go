@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/config"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/notify"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// reportHistoryLimit - сколько последних отчётов показывает история /reports/.
+	reportHistoryLimit = 100
+	// reportSendTimeout - ограничение на отправку одного письма с отчётом.
+	reportSendTimeout = time.Minute
+)
+
+// ErrReportNotFound - отчёт не найден или принадлежит другому пользователю.
+var ErrReportNotFound = errors.New("report not found")
+
+// ReportLine - позиция в отчёте: операции за период и текущая оценка открытого объёма.
+type ReportLine struct {
+	PositionValue
+	Created        *time.Time
+	Closed         *time.Time
+	OpenedInPeriod bool
+	ClosedInPeriod bool
+	Trades         int     // Сделок за период
+	PeriodFee      float64 // Комиссии за период
+	PeriodFunding  float64 // Funding за период
+	PeriodRealized float64 // Реализованный PnL позиции, закрытой в периоде
+}
+
+// ReportSummary - итоги отчёта. Open - открытые позиции на момент формирования.
+type ReportSummary struct {
+	RealizedPnL     float64
+	Fees            float64
+	Funding         float64
+	NewPositions    int
+	ClosedPositions int
+	OpenPositions   int
+	Open            PositionTotals
+}
+
+// ReportData - содержимое отчёта за период [Start, End) в часовом поясе пользователя.
+type ReportData struct {
+	Period    string
+	Start     time.Time
+	End       time.Time
+	Generated time.Time
+	Lines     []ReportLine
+	Summary   ReportSummary
+}
+
+// LastDay - последний календарный день периода.
+func (d ReportData) LastDay() time.Time {
+	return d.End.AddDate(0, 0, -1)
+}
+
+// Title - заголовок отчёта (тема письма).
+func (d ReportData) Title() string {
+	return reportTitle(d.Period, d.Start)
+}
+
+func reportTitle(period string, start time.Time) string {
+	if period == models.ReportPeriodMonthly {
+		return "Monthly PnL report " + start.Format("2006-01")
+	}
+	return "Daily PnL report " + start.Format("2006-01-02")
+}
+
+// reportPeriodStart возвращает начало дня или месяца, содержащего t, в часовом поясе t.
+func reportPeriodStart(period string, t time.Time) time.Time {
+	if period == models.ReportPeriodMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// reportPeriodEnd возвращает начало следующего периода.
+func reportPeriodEnd(period string, start time.Time) time.Time {
+	if period == models.ReportPeriodMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// previousReportPeriod возвращает начало последнего завершившегося периода на момент now.
+func previousReportPeriod(period string, now time.Time) time.Time {
+	current := reportPeriodStart(period, now)
+	if period == models.ReportPeriodMonthly {
+		return current.AddDate(0, -1, 0)
+	}
+	return current.AddDate(0, 0, -1)
+}
+
+// parseReportPeriod разбирает период отчёта: YYYY-MM-DD для дневного, YYYY-MM для месячного.
+func parseReportPeriod(period, value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	switch period {
+	case models.ReportPeriodDaily:
+		day, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date must be in YYYY-MM-DD format")
+		}
+		return day, nil
+	case models.ReportPeriodMonthly:
+		if len(value) > 7 {
+			value = value[:7]
+		}
+		month, err := time.ParseInLocation("2006-01", value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("month must be in YYYY-MM format")
+		}
+		return month, nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown report period")
+	}
+}
+
+// buildReportData собирает отчёт за период из итогов позиций (как в GetPositionsData),
+// их оценки по текущим ценам (values в порядке items) и операций за период.
+// Реализованный PnL учитывается в периоде закрытия позиции и уже включает её комиссии и funding.
+func buildReportData(period string, start, generated time.Time, items []*models.PositionSummary, values []PositionValue, activity map[int]*models.PositionPeriodTotals) ReportData {
+	end := reportPeriodEnd(period, start)
+	inPeriod := func(t *time.Time) bool {
+		return t != nil && !t.Before(start) && t.Before(end)
+	}
+
+	data := ReportData{Period: period, Start: start, End: end, Generated: generated.In(start.Location())}
+	open := make([]PositionValue, 0)
+	for i, item := range items {
+		if item.Created != nil && !item.Created.Before(end) {
+			continue
+		}
+		line := ReportLine{
+			PositionValue:  values[i],
+			Created:        item.Created,
+			Closed:         item.Closed,
+			OpenedInPeriod: inPeriod(item.Created),
+			ClosedInPeriod: item.Status != "OPEN" && inPeriod(item.Closed),
+		}
+		if act := activity[item.PositionID]; act != nil {
+			line.Trades = act.Trades
+			line.PeriodFee = act.Fee
+			line.PeriodFunding = act.Funding
+		}
+		if line.ClosedInPeriod {
+			line.PeriodRealized = line.Realized
+		}
+		isOpen := item.Status == "OPEN"
+		if !isOpen && !line.OpenedInPeriod && !line.ClosedInPeriod && activity[item.PositionID] == nil {
+			continue
+		}
+
+		data.Summary.RealizedPnL += line.PeriodRealized
+		data.Summary.Fees += line.PeriodFee
+		data.Summary.Funding += line.PeriodFunding
+		if line.OpenedInPeriod {
+			data.Summary.NewPositions++
+		}
+		if line.ClosedInPeriod {
+			data.Summary.ClosedPositions++
+		}
+		if isOpen {
+			data.Summary.OpenPositions++
+			open = append(open, line.PositionValue)
+		}
+		data.Lines = append(data.Lines, line)
+	}
+	data.Summary.Open = ComputePositionTotals(open)
+
+	// Сначала закрытые в периоде, затем остальные по номеру позиции
+	sort.SliceStable(data.Lines, func(i, j int) bool {
+		if data.Lines[i].ClosedInPeriod != data.Lines[j].ClosedInPeriod {
+			return data.Lines[i].ClosedInPeriod
+		}
+		return data.Lines[i].PositionID < data.Lines[j].PositionID
+	})
+	return data
+}
+
+// ReportService - дневные и месячные PnL-отчёты: формирование, история,
+// подписки и рассылка по email.
+type ReportService struct {
+	repo      *repositories.ReportRepository
+	users     *repositories.UserRepository
+	positions *PositionService
+	mailer    notify.Notifier // nil - notify.smtp не настроен
+}
+
+// NewReportService создаёт сервис отчётов; письма отправляются каналом email из notify.smtp.
+func NewReportService() *ReportService {
+	return &ReportService{
+		repo:      repositories.NewReportRepository(),
+		users:     repositories.NewUserRepository(),
+		positions: NewPositionService(),
+		mailer:    notify.FromConfig(config.Get().Notify)[notify.ChannelEmail],
+	}
+}
+
+// EmailEnabled сообщает, можно ли отправлять отчёты по email.
+func (s *ReportService) EmailEnabled() bool {
+	return s.mailer != nil
+}
+
+// Subscription возвращает подписку пользователя; без сохранённой подписки - выключенную
+// подписку на email пользователя.
+func (s *ReportService) Subscription(user *models.User) (*models.ReportSubscription, error) {
+	sub, err := s.repo.FindSubscription(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		sub = &models.ReportSubscription{UID: user.ID}
+	}
+	if sub.Email == "" {
+		sub.Email = user.Email
+	}
+	return sub, nil
+}
+
+// SaveSubscription сохраняет подписку пользователя. Пустой email - адрес из профиля.
+func (s *ReportService) SaveSubscription(user *models.User, daily, monthly bool, email string) error {
+	email, err := s.ValidateSubscription(user, daily, monthly, email)
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveSubscription(&models.ReportSubscription{UID: user.ID, Daily: daily, Monthly: monthly, Email: email})
+}
+
+// ValidateSubscription проверяет адрес подписки и возвращает его; пустой адрес - email из профиля.
+func (s *ReportService) ValidateSubscription(user *models.User, daily, monthly bool, email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		email = user.Email
+	}
+	if daily || monthly {
+		if s.mailer == nil {
+			return "", fmt.Errorf("email delivery is not configured (notify.smtp)")
+		}
+		if err := s.mailer.ValidateTarget(email); err != nil {
+			return "", fmt.Errorf("invalid email address")
+		}
+	}
+	if len(email) > 255 {
+		return "", fmt.Errorf("email address is too long")
+	}
+	return email, nil
+}
+
+// History возвращает последние отчёты пользователя без содержимого.
+func (s *ReportService) History(userID int) ([]*models.PnLReport, error) {
+	return s.repo.FindByUser(userID, reportHistoryLimit)
+}
+
+// Get возвращает отчёт пользователя с содержимым.
+func (s *ReportService) Get(userID, reportID int) (*models.PnLReport, error) {
+	report, err := s.repo.FindByID(reportID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
+
+// Delete удаляет отчёт пользователя из истории.
+func (s *ReportService) Delete(userID, reportID int) error {
+	ok, err := s.repo.Delete(reportID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReportNotFound
+	}
+	return nil
+}
+
+// Generate формирует (или пересобирает) отчёт пользователя за день YYYY-MM-DD или месяц YYYY-MM.
+// Текущий период допускается: отчёт будет неполным.
+func (s *ReportService) Generate(ctx context.Context, user *models.User, period, value string) (*models.PnLReport, error) {
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	start, err := parseReportPeriod(period, value, loc)
+	if err != nil {
+		return nil, err
+	}
+	if start.After(time.Now()) {
+		return nil, fmt.Errorf("report period has not started yet")
+	}
+	return s.generate(ctx, user.ID, period, reportPeriodStart(period, start))
+}
+
+// generate формирует и сохраняет отчёт за период, начинающийся в start (в часовом поясе пользователя).
+func (s *ReportService) generate(ctx context.Context, userID int, period string, start time.Time) (*models.PnLReport, error) {
+	end := reportPeriodEnd(period, start)
+	count, err := s.positions.repo.CountPositionsByUser(userID, models.PositionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.positions.repo.GetPositions(userID, models.PositionFilter{}, count+1, 0)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.positions.repo.GetPeriodTotals(userID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	data := buildReportData(period, start, now, items, valuePositions(ctx, items), activity)
+
+	htmlBody, err := renderReportHTML(data)
+	if err != nil {
+		return nil, fmt.Errorf("render report html: %w", err)
+	}
+	csvBody, err := renderReportCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("render report csv: %w", err)
+	}
+	report := &models.PnLReport{
+		UID:         userID,
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   data.LastDay(),
+		Timezone:    start.Location().String(),
+		RealizedPnL: data.Summary.RealizedPnL,
+		HTML:        htmlBody,
+		CSV:         csvBody,
+		DateCreate:  now.Truncate(time.Second),
+	}
+	if report.ID, err = s.repo.Save(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Send отправляет отчёт из истории на адрес подписки пользователя.
+func (s *ReportService) Send(ctx context.Context, user *models.User, reportID int) error {
+	if s.mailer == nil {
+		return fmt.Errorf("email delivery is not configured (notify.smtp)")
+	}
+	report, err := s.Get(user.ID, reportID)
+	if err != nil {
+		return err
+	}
+	sub, err := s.Subscription(user)
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, report, sub.Email)
+}
+
+// send отправляет отчёт письмом (HTML в теле, CSV во вложении) и запоминает результат.
+func (s *ReportService) send(ctx context.Context, report *models.PnLReport, email string) error {
+	title := reportTitle(report.Period, report.PeriodStart)
+	msg := notify.Message{
+		Event:   "report",
+		Subject: title,
+		Text: fmt.Sprintf("%s (%s)\nRealized PnL: %s\n\nThe full report is in the HTML version of this email and in the CSV attachment.",
+			title, report.Timezone, formatSignedNumber(report.RealizedPnL)),
+		HTML:        report.HTML,
+		Attachments: []notify.Attachment{{Name: ReportFileName(report, "csv"), ContentType: "text/csv", Data: []byte(report.CSV)}},
+		Time:        time.Now().UTC(),
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, reportSendTimeout)
+	defer cancel()
+	sendErr := s.mailer.Send(sendCtx, email, msg)
+
+	var emailedAt *time.Time
+	errText := ""
+	if sendErr != nil {
+		errText = sendErr.Error()
+	} else {
+		sent := msg.Time.Truncate(time.Second)
+		emailedAt = &sent
+	}
+	if err := s.repo.SetEmailed(report.ID, email, emailedAt, errText); err != nil {
+		logger.Warn().Int("report_id", report.ID).Err(err).Msg("Failed to save report delivery result")
+	}
+	return sendErr
+}
+
+// ReportFileName возвращает имя файла отчёта для скачивания и вложения: pnl-daily-2026-01-02.csv.
+func ReportFileName(report *models.PnLReport, ext string) string {
+	date := report.PeriodStart.Format("2006-01-02")
+	if report.Period == models.ReportPeriodMonthly {
+		date = report.PeriodStart.Format("2006-01")
+	}
+	return fmt.Sprintf("pnl-%s-%s.%s", report.Period, date, ext)
+}
+
+// RunScheduled формирует отчёты за завершившиеся день и месяц для подписчиков
+// и отправляет их. Отчёт, уже сформированный за период, повторно не отправляется.
+func (s *ReportService) RunScheduled(ctx context.Context) error {
+	if s.mailer == nil {
+		return nil
+	}
+	subs, err := s.repo.FindActiveSubscriptions()
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	users, err := s.users.FindAllActive()
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]*models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	failed := 0
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		user := byID[sub.UID]
+		if user == nil {
+			continue
+		}
+		loc, tzErr := time.LoadLocation(user.Timezone)
+		if tzErr != nil {
+			loc = time.UTC
+		}
+		now := time.Now().In(loc)
+		for _, period := range []string{models.ReportPeriodDaily, models.ReportPeriodMonthly} {
+			if (period == models.ReportPeriodDaily && !sub.Daily) || (period == models.ReportPeriodMonthly && !sub.Monthly) {
+				continue
+			}
+			start := previousReportPeriod(period, now)
+			exists, err := s.repo.Exists(user.ID, period, start)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			report, err := s.generate(ctx, user.ID, period, start)
+			if err != nil {
+				failed++
+				logger.Warn().Int("uid", user.ID).Str("period", period).Err(err).Msg("PnL report generation failed")
+				continue
+			}
+			if err := s.send(ctx, report, sub.Email); err != nil {
+				failed++
+				logger.Warn().Int("uid", user.ID).Int("report_id", report.ID).Err(err).Msg("PnL report delivery failed")
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d pnl reports failed", failed)
+	}
+	return nil
+}
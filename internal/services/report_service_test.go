@@ -0,0 +1,119 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportPeriods(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	now := time.Date(2026, 3, 1, 0, 30, 0, 0, loc)
+
+	if got, want := previousReportPeriod(models.ReportPeriodDaily, now), time.Date(2026, 2, 28, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("previous day: got %v, want %v", got, want)
+	}
+	if got, want := previousReportPeriod(models.ReportPeriodMonthly, now), time.Date(2026, 2, 1, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("previous month: got %v, want %v", got, want)
+	}
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, loc)
+	if got, want := reportPeriodEnd(models.ReportPeriodMonthly, start), time.Date(2026, 3, 1, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("month end: got %v, want %v", got, want)
+	}
+
+	month, err := parseReportPeriod(models.ReportPeriodMonthly, "2026-02-15", loc)
+	if err != nil || !month.Equal(start) {
+		t.Fatalf("parse month: got %v, %v", month, err)
+	}
+	if _, err := parseReportPeriod(models.ReportPeriodDaily, "2026-02", loc); err == nil {
+		t.Fatalf("daily period accepted a month")
+	}
+	if _, err := parseReportPeriod("weekly", "2026-02-01", loc); err == nil {
+		t.Fatalf("unknown period accepted")
+	}
+}
+
+func TestBuildReportData(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	start := time.Date(2026, 2, 10, 0, 0, 0, 0, loc)
+	at := func(day, hour int) *time.Time {
+		v := time.Date(2026, 2, day, hour, 0, 0, 0, time.UTC)
+		return &v
+	}
+
+	items := []*models.PositionSummary{
+		{PositionID: 1, Status: "OPEN", Created: at(1, 0)},                        // Открыта раньше, без операций
+		{PositionID: 2, Status: "CLOSED", Created: at(10, 1), Closed: at(10, 12)}, // Открыта и закрыта в периоде
+		{PositionID: 3, Status: "CLOSED", Created: at(1, 0), Closed: at(9, 22)},   // 01:00 10-го по UTC+3
+		{PositionID: 4, Status: "CLOSED", Created: at(1, 0), Closed: at(5, 0)},    // Закрыта раньше
+		{PositionID: 5, Status: "OPEN", Created: at(11, 0)},                       // Открыта позже периода
+	}
+	values := []PositionValue{
+		{PositionID: 1, Status: "OPEN", Position: 2, AvgPrice: 100, Price: 110},
+		{PositionID: 2, Status: "CLOSED", Realized: 7},
+		{PositionID: 3, Status: "CLOSED", Realized: -3},
+		{PositionID: 4, Status: "CLOSED", Realized: 100},
+		{PositionID: 5, Status: "OPEN", Position: 1, AvgPrice: 10},
+	}
+	activity := map[int]*models.PositionPeriodTotals{
+		1: {PositionID: 1, Funding: 0.5},
+		2: {PositionID: 2, Trades: 2, Fee: 0.2},
+	}
+
+	data := buildReportData(models.ReportPeriodDaily, start, start.Add(30*time.Hour), items, values, activity)
+	if len(data.Lines) != 3 {
+		t.Fatalf("lines: got %d, want 3", len(data.Lines))
+	}
+	// Закрытые в периоде идут первыми
+	if data.Lines[0].PositionID != 2 || data.Lines[1].PositionID != 3 || data.Lines[2].PositionID != 1 {
+		t.Fatalf("unexpected line order: %d, %d, %d", data.Lines[0].PositionID, data.Lines[1].PositionID, data.Lines[2].PositionID)
+	}
+	s := data.Summary
+	if math.Abs(s.RealizedPnL-4) > 1e-9 || math.Abs(s.Fees-0.2) > 1e-9 || math.Abs(s.Funding-0.5) > 1e-9 {
+		t.Fatalf("unexpected totals: %+v", s)
+	}
+	if s.NewPositions != 1 || s.ClosedPositions != 2 || s.OpenPositions != 1 {
+		t.Fatalf("unexpected counts: %+v", s)
+	}
+	if math.Abs(s.Open.NetExposure-220) > 1e-9 || math.Abs(s.Open.UnrealizedPnL-20) > 1e-9 {
+		t.Fatalf("unexpected open totals: %+v", s.Open)
+	}
+	if got := data.LastDay(); !got.Equal(start) {
+		t.Fatalf("last day of daily report: got %v", got)
+	}
+}
+
+func TestRenderReportCSV(t *testing.T) {
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	data := buildReportData(models.ReportPeriodMonthly, start, start, []*models.PositionSummary{
+		{PositionID: 7, Status: "OPEN"},
+	}, []PositionValue{
+		{PositionID: 7, Name: "BTC, perp", Market: "futures", Status: "OPEN", Position: -1, AvgPrice: 100},
+	}, nil)
+
+	out, err := renderReportCSV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("rows: got %d, want 3", len(rows))
+	}
+	line := rows[1]
+	if line[1] != "BTC, perp" || line[3] != "FUTURES" || line[15] != "" || line[16] != "-100" {
+		t.Fatalf("unexpected line: %q", line)
+	}
+	if rows[2][0] != "TOTAL" || rows[2][16] != "-100" {
+		t.Fatalf("unexpected total: %q", rows[2])
+	}
+
+	if _, err := renderReportHTML(data); err != nil {
+		t.Fatalf("render html: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/logger"
+	"time"
+)
+
+// RunPeriodic запускает fn сразу и затем каждые interval, пока не отменён ctx.
+//
+// Ошибки fn логируются и не прерывают цикл. Паника внутри fn перехватывается,
+// чтобы фоновая задача не роняла веб-сервер. Нулевой интервал - задача отключена.
+func RunPeriodic(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		logger.Info().Str("job", name).Dur("interval", interval).Msg("Background job started")
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runJob(ctx, name, fn)
+			select {
+			case <-ctx.Done():
+				logger.Info().Str("job", name).Msg("Background job stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func runJob(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error().Str("job", name).Interface("panic", r).Msg("Background job panicked")
+		}
+	}()
+
+	started := time.Now()
+	if err := fn(ctx); err != nil {
+		logger.Error().Str("job", name).Err(err).Dur("duration", time.Since(started)).Msg("Background job failed")
+		return
+	}
+	logger.Debug().Str("job", name).Dur("duration", time.Since(started)).Msg("Background job finished")
+}
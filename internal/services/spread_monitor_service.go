@@ -0,0 +1,491 @@
+package services
+
+import (
+	"context"
+	"ctweb/internal/connectors"
+	"ctweb/internal/db"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSpreadWatches - сколько наблюдений может завести один пользователь.
+	maxSpreadWatches = 20
+	// spreadRetention - срок хранения замеров спреда (в ClickHouse - TTL таблицы SPREADS).
+	spreadRetention = 90 * 24 * time.Hour
+	// spreadCleanupEvery - как часто задача сбора удаляет устаревшие замеры.
+	spreadCleanupEvery = time.Hour
+	// maxSpreadSamples - сколько замеров читается для статистики за период.
+	maxSpreadSamples = 200000
+	// maxSpreadChartPoints - сколько точек отдаётся на график.
+	maxSpreadChartPoints = 2000
+	// spreadTickerWorkers - сколько котировок запрашивается с бирж одновременно.
+	spreadTickerWorkers = 8
+)
+
+// spreadPeriods - периоды истории спреда на странице /spread_monitor/.
+var spreadPeriods = map[string]time.Duration{
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// ErrSpreadWatchNotFound - наблюдение не найдено или принадлежит другому пользователю.
+var ErrSpreadWatchNotFound = errors.New("spread watch not found")
+
+// SpreadStats - распределение спреда за период (в долях, 0.001 = 0.1%).
+type SpreadStats struct {
+	Count    int     `json:"count"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Mean     float64 `json:"mean"`
+	P5       float64 `json:"p5"`
+	P25      float64 `json:"p25"`
+	P50      float64 `json:"p50"`
+	P75      float64 `json:"p75"`
+	P95      float64 `json:"p95"`
+	Positive float64 `json:"positive"` // Доля замеров со спредом больше нуля
+}
+
+// Percentile возвращает перцентиль p (0..100) отсортированных значений с линейной интерполяцией.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// ComputeSpreadStats считает распределение значений спреда. values не изменяется.
+func ComputeSpreadStats(values []float64) SpreadStats {
+	if len(values) == 0 {
+		return SpreadStats{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	stats := SpreadStats{Count: len(sorted), Min: sorted[0], Max: sorted[len(sorted)-1]}
+	positive, sum := 0, 0.0
+	for _, v := range sorted {
+		sum += v
+		if v > 0 {
+			positive++
+		}
+	}
+	stats.Mean = sum / float64(len(sorted))
+	stats.Positive = float64(positive) / float64(len(sorted))
+	stats.P5 = Percentile(sorted, 5)
+	stats.P25 = Percentile(sorted, 25)
+	stats.P50 = Percentile(sorted, 50)
+	stats.P75 = Percentile(sorted, 75)
+	stats.P95 = Percentile(sorted, 95)
+	return stats
+}
+
+// DownsampleSpreads прореживает замеры до max точек для графика. Из каждого интервала
+// берётся замер с наибольшим чистым спредом, чтобы на графике не терялись всплески.
+func DownsampleSpreads(samples []*models.SpreadSample, max int) []*models.SpreadSample {
+	if max <= 0 || len(samples) <= max {
+		return samples
+	}
+	result := make([]*models.SpreadSample, 0, max)
+	step := float64(len(samples)) / float64(max)
+	for i := 0; i < max; i++ {
+		start := int(float64(i) * step)
+		end := int(float64(i+1) * step)
+		if end > len(samples) {
+			end = len(samples)
+		}
+		best := samples[start]
+		for _, s := range samples[start:end] {
+			if s.Net > best.Net {
+				best = s
+			}
+		}
+		result = append(result, best)
+	}
+	return result
+}
+
+// SpreadHistoryResult - история спреда наблюдения за период со статистикой.
+type SpreadHistoryResult struct {
+	Watch     *models.SpreadWatch    `json:"watch"`
+	From      time.Time              `json:"from"`
+	To        time.Time              `json:"to"`
+	Points    []*models.SpreadSample `json:"points"`
+	Gross     SpreadStats            `json:"gross"`
+	Net       SpreadStats            `json:"net"`
+	Last      *models.SpreadSample   `json:"last"`
+	Truncated bool                   `json:"truncated"` // Замеров больше maxSpreadSamples, статистика по первым
+}
+
+// SpreadMonitorService - наблюдения за межбиржевым спредом: замеры bid/ask обеих бирж
+// фоновой задачей, чистый спред за вычетом taker-комиссий пользователя и статистика за период.
+type SpreadMonitorService struct {
+	watches      *repositories.SpreadWatchRepository
+	spreads      *repositories.SpreadRepository
+	instruments  *repositories.InstrumentRepository
+	exchangeRepo *repositories.ExchangeRepository
+	fees         *FeeService
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewSpreadMonitorService создаёт сервис мониторинга спредов.
+func NewSpreadMonitorService() *SpreadMonitorService {
+	return &SpreadMonitorService{
+		watches:      repositories.NewSpreadWatchRepository(),
+		spreads:      repositories.NewSpreadRepository(),
+		instruments:  repositories.NewInstrumentRepository(),
+		exchangeRepo: repositories.NewExchangeRepository(),
+		fees:         NewFeeService(),
+	}
+}
+
+// ListWatches возвращает наблюдения пользователя.
+func (s *SpreadMonitorService) ListWatches(userID int) ([]*models.SpreadWatch, error) {
+	return s.watches.FindAllByUser(userID)
+}
+
+// CreateWatch заводит наблюдение за парой BASE/QUOTE между двумя биржами.
+func (s *SpreadMonitorService) CreateWatch(userID, exchangeID1, exchangeID2 int, market, pair string) (*models.SpreadWatch, error) {
+	if exchangeID1 <= 0 || exchangeID2 <= 0 || exchangeID1 == exchangeID2 {
+		return nil, fmt.Errorf("select two different exchanges")
+	}
+	base, quote, ok := splitPair(pair)
+	if !ok {
+		return nil, fmt.Errorf("invalid trade pair")
+	}
+	market = connectors.NormalizeMarket(market)
+
+	count, err := s.watches.CountByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxSpreadWatches {
+		return nil, fmt.Errorf("too many spread watches (max %d)", maxSpreadWatches)
+	}
+
+	inst1, err := s.instruments.FindByAssets(exchangeID1, market, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	inst2, err := s.instruments.FindByAssets(exchangeID2, market, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	if inst1 == nil || inst2 == nil {
+		return nil, fmt.Errorf("trade pair is not listed on both exchanges")
+	}
+
+	watch := &models.SpreadWatch{
+		UID:        userID,
+		ExID1:      exchangeID1,
+		ExID2:      exchangeID2,
+		MarketType: market,
+		BaseAsset:  base,
+		QuoteAsset: quote,
+		Symbol1:    inst1.Symbol,
+		Symbol2:    inst2.Symbol,
+		IsActive:   true,
+	}
+	exists, err := s.watches.Exists(watch)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("this pair is already watched on these exchanges")
+	}
+	if watch.ID, err = s.watches.Create(watch); err != nil {
+		return nil, err
+	}
+	return watch, nil
+}
+
+// SetWatchActive включает или приостанавливает наблюдение пользователя.
+func (s *SpreadMonitorService) SetWatchActive(userID, watchID int, active bool) error {
+	// UPDATE без изменений не считается затронутой строкой, поэтому наблюдение проверяется заранее.
+	watch, err := s.watches.FindByID(watchID, userID)
+	if err != nil {
+		return err
+	}
+	if watch == nil {
+		return ErrSpreadWatchNotFound
+	}
+	return s.watches.SetActive(watchID, userID, active)
+}
+
+// DeleteWatch удаляет наблюдение пользователя.
+func (s *SpreadMonitorService) DeleteWatch(userID, watchID int) error {
+	ok, err := s.watches.Delete(watchID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSpreadWatchNotFound
+	}
+	return nil
+}
+
+// tickerKey - инструмент, котировка которого нужна для замеров или оценки позиций.
+type tickerKey struct {
+	ExID   int
+	Market string
+	Symbol string
+}
+
+type tickerResult struct {
+	ticker *connectors.Ticker
+	err    error
+}
+
+// fetchTickers запрашивает котировки инструментов параллельно (не больше spreadTickerWorkers запросов).
+// keys не должны повторяться: вызывающий собирает каждый инструмент один раз.
+func fetchTickers(ctx context.Context, exchangeRepo *repositories.ExchangeRepository, keys []tickerKey) map[tickerKey]tickerResult {
+	providers := make(map[int]connectors.TickerProvider)
+	providerErrs := make(map[int]error)
+	for _, key := range keys {
+		if _, done := providers[key.ExID]; done {
+			continue
+		}
+		if _, failed := providerErrs[key.ExID]; failed {
+			continue
+		}
+		exchange, err := exchangeRepo.FindByID(key.ExID)
+		if err == nil && exchange == nil {
+			err = fmt.Errorf("exchange %d not found", key.ExID)
+		}
+		if err != nil {
+			providerErrs[key.ExID] = err
+			continue
+		}
+		connector, err := connectors.New(exchange)
+		if err != nil {
+			providerErrs[key.ExID] = err
+			continue
+		}
+		provider, ok := connector.(connectors.TickerProvider)
+		if !ok {
+			providerErrs[key.ExID] = fmt.Errorf("%s: %w", exchange.Name, connectors.ErrNotSupported)
+			continue
+		}
+		providers[key.ExID] = provider
+	}
+
+	results := make(map[tickerKey]tickerResult, len(keys))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, spreadTickerWorkers)
+	for _, key := range keys {
+		if err, failed := providerErrs[key.ExID]; failed {
+			results[key] = tickerResult{err: err}
+			continue
+		}
+		wg.Add(1)
+		go func(key tickerKey, provider connectors.TickerProvider) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			ticker, err := provider.FetchTicker(ctx, key.Market, key.Symbol)
+			mu.Lock()
+			results[key] = tickerResult{ticker: ticker, err: err}
+			mu.Unlock()
+		}(key, providers[key.ExID])
+	}
+	wg.Wait()
+	return results
+}
+
+// takerFee возвращает taker-комиссию пользователя на рынке биржи в долях (0, если ставка не задана).
+func (s *SpreadMonitorService) takerFee(userID, exchangeID int, market string) float64 {
+	fee, err := s.fees.ResolveFee(userID, exchangeID, nil, market)
+	if err != nil {
+		logger.Warn().Int("exchange_id", exchangeID).Err(err).Msg("Resolve fee for spread monitor failed")
+	}
+	if fee == nil {
+		return 0
+	}
+	return fee.Rate(false)
+}
+
+// Sample снимает bid/ask по всем активным наблюдениям и сохраняет спред в БД котировок.
+// Ошибка котировки одной биржи записывается в наблюдение и не прерывает остальные.
+func (s *SpreadMonitorService) Sample(ctx context.Context) error {
+	watches, err := s.watches.FindActive()
+	if err != nil {
+		return err
+	}
+	if len(watches) == 0 {
+		return nil
+	}
+	if !s.spreads.Available() {
+		return db.ErrQuotesNotConfigured
+	}
+
+	keys := make([]tickerKey, 0, len(watches)*2)
+	seen := make(map[tickerKey]bool, len(watches)*2)
+	for _, w := range watches {
+		for _, key := range []tickerKey{{w.ExID1, w.MarketType, w.Symbol1}, {w.ExID2, w.MarketType, w.Symbol2}} {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sampledAt := time.Now().UTC().Truncate(time.Second)
+	tickers := fetchTickers(ctx, s.exchangeRepo, keys)
+	for key, result := range tickers {
+		if result.err == nil {
+			sharedPriceCache.Store(key, result.ticker)
+		}
+	}
+
+	type feeKey struct {
+		UserID, ExID int
+		Market       string
+	}
+	fees := make(map[feeKey]float64)
+	feeOf := func(userID, exchangeID int, market string) float64 {
+		key := feeKey{userID, exchangeID, market}
+		if fee, ok := fees[key]; ok {
+			return fee
+		}
+		fee := s.takerFee(userID, exchangeID, market)
+		fees[key] = fee
+		return fee
+	}
+
+	samples := make([]*models.SpreadSample, 0, len(watches))
+	sampled := make([]*models.SpreadWatch, 0, len(watches))
+	for _, w := range watches {
+		t1 := tickers[tickerKey{w.ExID1, w.MarketType, w.Symbol1}]
+		t2 := tickers[tickerKey{w.ExID2, w.MarketType, w.Symbol2}]
+		lastError := ""
+		switch {
+		case t1.err != nil:
+			lastError = t1.err.Error()
+		case t2.err != nil:
+			lastError = t2.err.Error()
+		default:
+			edge := BestSpreadEdge(t1.ticker.Bid, t1.ticker.Ask, t2.ticker.Bid, t2.ticker.Ask,
+				feeOf(w.UID, w.ExID1, w.MarketType), feeOf(w.UID, w.ExID2, w.MarketType))
+			if edge == nil {
+				lastError = "empty order book"
+				break
+			}
+			samples = append(samples, &models.SpreadSample{
+				WatchID:    w.ID,
+				SampleTime: sampledAt,
+				Bid1:       t1.ticker.Bid,
+				Ask1:       t1.ticker.Ask,
+				Bid2:       t2.ticker.Bid,
+				Ask2:       t2.ticker.Ask,
+				BuyOn:      edge.BuyOn,
+				Gross:      edge.Gross,
+				Fees:       edge.Fees,
+				Net:        edge.Net,
+			})
+			sampled = append(sampled, w)
+			continue
+		}
+		if err := s.watches.SetSampleResult(w.ID, sampledAt, lastError); err != nil {
+			logger.Warn().Int("watch_id", w.ID).Err(err).Msg("Save spread watch error failed")
+		}
+	}
+
+	if err := s.spreads.InsertBatch(ctx, samples); err != nil {
+		return err
+	}
+	for _, w := range sampled {
+		if err := s.watches.SetSampleResult(w.ID, sampledAt, ""); err != nil {
+			logger.Warn().Int("watch_id", w.ID).Err(err).Msg("Save spread watch sample failed")
+		}
+	}
+
+	s.cleanup(ctx)
+	return nil
+}
+
+// cleanup удаляет замеры старше spreadRetention не чаще раза в spreadCleanupEvery.
+func (s *SpreadMonitorService) cleanup(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastCleanup) < spreadCleanupEvery {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = time.Now()
+	s.mu.Unlock()
+
+	deleted, err := s.spreads.DeleteBefore(ctx, time.Now().Add(-spreadRetention))
+	if err != nil {
+		logger.Warn().Err(err).Msg("Delete old spreads failed")
+		return
+	}
+	if deleted > 0 {
+		logger.Info().Int("deleted", int(deleted)).Msg("Old spreads deleted")
+	}
+}
+
+// History возвращает замеры наблюдения пользователя за период (1h, 6h, 24h, 7d, 30d)
+// с перцентилями валового и чистого спреда.
+func (s *SpreadMonitorService) History(ctx context.Context, userID, watchID int, period string) (*SpreadHistoryResult, error) {
+	duration, ok := spreadPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid period")
+	}
+	watch, err := s.watches.FindByID(watchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if watch == nil {
+		return nil, ErrSpreadWatchNotFound
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-duration)
+	samples, err := s.spreads.FindRange(ctx, watch.ID, from, to, maxSpreadSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	gross := make([]float64, len(samples))
+	net := make([]float64, len(samples))
+	for i, sample := range samples {
+		gross[i] = sample.Gross
+		net[i] = sample.Net
+	}
+	result := &SpreadHistoryResult{
+		Watch:     watch,
+		From:      from,
+		To:        to,
+		Points:    DownsampleSpreads(samples, maxSpreadChartPoints),
+		Gross:     ComputeSpreadStats(gross),
+		Net:       ComputeSpreadStats(net),
+		Truncated: len(samples) >= maxSpreadSamples,
+	}
+	if len(samples) > 0 {
+		result.Last = samples[len(samples)-1]
+	}
+	return result, nil
+}
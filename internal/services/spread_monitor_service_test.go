@@ -0,0 +1,69 @@
+package services
+
+import (
+	"ctweb/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	cases := map[float64]float64{0: 1, 25: 2, 50: 3, 90: 4.6, 100: 5}
+	for p, want := range cases {
+		if got := Percentile(sorted, p); math.Abs(got-want) > 1e-12 {
+			t.Errorf("Percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Fatalf("expected 0 for empty input, got %v", got)
+	}
+}
+
+func TestComputeSpreadStats(t *testing.T) {
+	values := []float64{0.002, -0.001, 0.001, -0.003}
+	stats := ComputeSpreadStats(values)
+	if stats.Count != 4 || stats.Min != -0.003 || stats.Max != 0.002 || stats.Positive != 0.5 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if math.Abs(stats.Mean-(-0.00025)) > 1e-12 || math.Abs(stats.P50-0) > 1e-12 {
+		t.Fatalf("unexpected mean/median: %+v", stats)
+	}
+	// Исходный срез не сортируется.
+	if values[0] != 0.002 {
+		t.Fatalf("input slice was modified: %v", values)
+	}
+	if empty := ComputeSpreadStats(nil); empty.Count != 0 {
+		t.Fatalf("unexpected stats for empty input: %+v", empty)
+	}
+}
+
+func TestDownsampleSpreads(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := make([]*models.SpreadSample, 10)
+	for i := range samples {
+		samples[i] = &models.SpreadSample{SampleTime: start.Add(time.Duration(i) * time.Minute), Net: float64(i % 3)}
+	}
+	// Всплеск не должен потеряться при прореживании.
+	samples[7].Net = 10
+
+	got := DownsampleSpreads(samples, 4)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 points, got %d", len(got))
+	}
+	found := false
+	for i, s := range got {
+		if s.Net == 10 {
+			found = true
+		}
+		if i > 0 && !s.SampleTime.After(got[i-1].SampleTime) {
+			t.Fatalf("points are not ordered by time: %v", got)
+		}
+	}
+	if !found {
+		t.Fatal("spike was lost after downsampling")
+	}
+	if len(DownsampleSpreads(samples, 20)) != 10 {
+		t.Fatal("short series must be returned as is")
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Форматы выгрузки таблиц (позиции, операции позиции).
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+	ExportFormatJSON = "json"
+)
+
+// ExportFormats - поддерживаемые форматы в порядке показа.
+var ExportFormats = []string{ExportFormatCSV, ExportFormatXLSX, ExportFormatJSON}
+
+// ExportContentType возвращает MIME-тип файла формата; пустая строка - формат не поддерживается.
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportFormatJSON:
+		return "application/json; charset=utf-8"
+	}
+	return ""
+}
+
+// TableWriter пишет таблицу построчно, не держа её в памяти.
+// Значения ячеек: nil (пусто), string, int, float64, bool.
+type TableWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewTableWriter создаёт писатель таблицы с колонками columns в формате format.
+// sheet - имя листа XLSX. Close дописывает хвост файла, но не закрывает w.
+func NewTableWriter(w io.Writer, format, sheet string, columns []string) (TableWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		tw := &csvTableWriter{w: csv.NewWriter(w)}
+		if err := tw.w.Write(columns); err != nil {
+			return nil, err
+		}
+		return tw, nil
+	case ExportFormatXLSX:
+		return newXLSXWriter(w, sheet, columns)
+	case ExportFormatJSON:
+		return newJSONTableWriter(w, columns)
+	}
+	return nil, fmt.Errorf("unknown export format")
+}
+
+// exportCell приводит значение ячейки к тексту CSV.
+func exportCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+type csvTableWriter struct {
+	w   *csv.Writer
+	row []string
+}
+
+func (t *csvTableWriter) WriteRow(values []interface{}) error {
+	t.row = t.row[:0]
+	for _, v := range values {
+		t.row = append(t.row, exportCell(v))
+	}
+	return t.w.Write(t.row)
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// jsonTableWriter пишет массив объектов с ключами в порядке колонок.
+type jsonTableWriter struct {
+	w     *bufio.Writer
+	keys  [][]byte
+	value bytes.Buffer
+	enc   *json.Encoder
+	count int
+}
+
+func newJSONTableWriter(w io.Writer, columns []string) (*jsonTableWriter, error) {
+	t := &jsonTableWriter{w: bufio.NewWriter(w), keys: make([][]byte, len(columns))}
+	t.enc = json.NewEncoder(&t.value)
+	t.enc.SetEscapeHTML(false)
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return nil, err
+		}
+		t.keys[i] = key
+	}
+	if _, err := t.w.WriteString("["); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *jsonTableWriter) WriteRow(values []interface{}) error {
+	if t.count > 0 {
+		t.w.WriteString(",")
+	}
+	t.count++
+	t.w.WriteString("\n{")
+	for i, key := range t.keys {
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			value = nil
+		}
+		t.value.Reset()
+		if err := t.enc.Encode(value); err != nil {
+			return err
+		}
+		if i > 0 {
+			t.w.WriteString(",")
+		}
+		t.w.Write(key)
+		t.w.WriteString(":")
+		if _, err := t.w.Write(bytes.TrimRight(t.value.Bytes(), "\n")); err != nil {
+			return err
+		}
+	}
+	_, err := t.w.WriteString("}")
+	return err
+}
+
+func (t *jsonTableWriter) Close() error {
+	if _, err := t.w.WriteString("\n]\n"); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+// ExportFileName возвращает имя файла выгрузки: positions-20250301-1504.csv.
+func ExportFileName(prefix, format string, now time.Time) string {
+	return fmt.Sprintf("%s-%s.%s", prefix, now.Format("20060102-1504"), format)
+}
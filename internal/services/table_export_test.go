@@ -0,0 +1,108 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func writeTable(t *testing.T, format string, rows [][]interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw, err := NewTableWriter(&buf, format, "Position [1]", []string{"ID", "NAME", "PRICE", "DATE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := tw.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var tableExportRows = [][]interface{}{
+	{1, "BTC<USDT> & co", 0.5, "2025-03-01 10:00:00"},
+	{2, nil, math.NaN(), nil},
+}
+
+func TestTableWriterCSV(t *testing.T) {
+	rows, err := csv.NewReader(bytes.NewReader(writeTable(t, ExportFormatCSV, tableExportRows))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][1] != "BTC<USDT> & co" || rows[1][2] != "0.5" || rows[2][2] != "" {
+		t.Fatalf("unexpected csv: %q", rows)
+	}
+}
+
+func TestTableWriterJSON(t *testing.T) {
+	out := writeTable(t, ExportFormatJSON, tableExportRows)
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(out, &rows); err != nil {
+		t.Fatalf("invalid json %s: %v", out, err)
+	}
+	if len(rows) != 2 || rows[0]["PRICE"] != 0.5 || rows[1]["PRICE"] != nil || rows[1]["NAME"] != nil {
+		t.Fatalf("unexpected json: %v", rows)
+	}
+	// Ключи идут в порядке колонок
+	if !strings.Contains(string(out), `{"ID":1,"NAME":"BTC<USDT> & co","PRICE":0.5,`) {
+		t.Fatalf("unexpected key order: %s", out)
+	}
+	if empty := writeTable(t, ExportFormatJSON, nil); strings.TrimSpace(string(empty)) != "[\n]" {
+		t.Fatalf("unexpected empty json: %q", empty)
+	}
+}
+
+func TestTableWriterXLSX(t *testing.T) {
+	out := writeTable(t, ExportFormatXLSX, tableExportRows)
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Position _1_"`) {
+		t.Fatalf("unexpected workbook: %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A2"><v>1</v></c>`,
+		`<t xml:space="preserve">BTC&lt;USDT&gt; &amp; co</t>`,
+		`<c r="C2"><v>0.5</v></c>`,
+		`<row r="3"><c r="A3"><v>2</v></c></row>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("sheet has no %s: %s", want, sheet)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(index); got != want {
+			t.Errorf("%d: got %s, want %s", index, got, want)
+		}
+	}
+}
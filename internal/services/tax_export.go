@@ -0,0 +1,181 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Форматы налоговой выгрузки.
+const (
+	TaxFormatKoinly       = "koinly"
+	TaxFormatCoinTracking = "cointracking"
+	TaxFormat8949         = "form8949"
+)
+
+// TaxFormats - поддерживаемые форматы в порядке показа.
+var TaxFormats = []string{TaxFormatKoinly, TaxFormatCoinTracking, TaxFormat8949}
+
+// TaxFileName возвращает имя файла выгрузки: tax-2025-EUR-koinly.csv.
+func TaxFileName(report *TaxReport, format string) string {
+	return fmt.Sprintf("tax-%d-%s-%s.csv", report.Year, report.Currency, format)
+}
+
+// WriteTaxCSV пишет выгрузку в формате format. Form 8949 содержит только продажи:
+// доходные строки FUTURES в эту форму не входят.
+func WriteTaxCSV(w io.Writer, report *TaxReport, format string) error {
+	cw := csv.NewWriter(w)
+	var err error
+	switch format {
+	case TaxFormatKoinly:
+		err = writeTaxKoinly(cw, report)
+	case TaxFormatCoinTracking:
+		err = writeTaxCoinTracking(cw, report)
+	case TaxFormat8949:
+		err = writeTax8949(cw, report)
+	default:
+		return fmt.Errorf("unknown export format")
+	}
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func taxAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func taxMoney(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', 2, 64)
+}
+
+// taxDisposalComment описывает лот продажи для поля комментария.
+func taxDisposalComment(report *TaxReport, d TaxDisposal) string {
+	acquired := "unknown acquisition (no cost basis)"
+	if d.Acquired != nil {
+		acquired = "acquired " + d.Acquired.In(report.Location).Format("2006-01-02")
+	}
+	return fmt.Sprintf("FIFO lot %s, cost basis %s %s, position #%d", acquired, taxMoney(d.CostBasis), report.Currency, d.PositionID)
+}
+
+func taxIncomeComment(line TaxIncome) string {
+	if line.Kind == TaxIncomeFunding {
+		return fmt.Sprintf("Funding %s, position #%d", line.Contract, line.PositionID)
+	}
+	return fmt.Sprintf("Realized PnL %s (excluding funding), position #%d", line.Contract, line.PositionID)
+}
+
+// writeTaxKoinly - универсальный CSV Koinly (время в UTC).
+func writeTaxKoinly(w *csv.Writer, report *TaxReport) error {
+	if err := w.Write([]string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency",
+		"Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"}); err != nil {
+		return err
+	}
+	date := func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") + " UTC" }
+	for i, d := range report.Disposals {
+		if err := w.Write([]string{
+			date(d.Disposed), taxAmount(d.Quantity), d.Asset, taxAmount(d.ProceedsQuote), d.Quote,
+			"", "", taxMoney(d.Proceeds), report.Currency, "", taxDisposalComment(report, d),
+			fmt.Sprintf("ctweb-trade-%d-%d", d.TransactionID, i),
+		}); err != nil {
+			return err
+		}
+	}
+	for _, line := range report.Income {
+		sentAmount, sentCurrency, receivedAmount, receivedCurrency := "", "", "", ""
+		if line.Amount >= 0 {
+			receivedAmount, receivedCurrency = taxAmount(line.Amount), line.Asset
+		} else {
+			sentAmount, sentCurrency = taxAmount(-line.Amount), line.Asset
+		}
+		label, hash := "realized gain", fmt.Sprintf("ctweb-pnl-%d", line.PositionID)
+		if line.Kind == TaxIncomeFunding {
+			label, hash = "income", fmt.Sprintf("ctweb-funding-%d", line.TransactionID)
+			if line.Amount < 0 {
+				label = "margin fee"
+			}
+		}
+		if err := w.Write([]string{
+			date(line.Date), sentAmount, sentCurrency, receivedAmount, receivedCurrency,
+			"", "", taxMoney(math.Abs(line.Value)), report.Currency, label, taxIncomeComment(line), hash,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTaxCoinTracking - CSV импорта CoinTracking (время в часовом поясе пользователя).
+func writeTaxCoinTracking(w *csv.Writer, report *TaxReport) error {
+	if err := w.Write([]string{"Type", "Buy Amount", "Buy Currency", "Sell Amount", "Sell Currency",
+		"Fee", "Fee Currency", "Exchange", "Trade-Group", "Comment", "Date"}); err != nil {
+		return err
+	}
+	date := func(t time.Time) string { return t.In(report.Location).Format("2006-01-02 15:04:05") }
+	for _, d := range report.Disposals {
+		if err := w.Write([]string{
+			"Trade", taxAmount(d.ProceedsQuote), d.Quote, taxAmount(d.Quantity), d.Asset,
+			"", "", d.Exchange, "", taxDisposalComment(report, d), date(d.Disposed),
+		}); err != nil {
+			return err
+		}
+	}
+	for _, line := range report.Income {
+		row := []string{"", "", "", "", "", "", "", line.Exchange, "", taxIncomeComment(line), date(line.Date)}
+		switch {
+		case line.Kind == TaxIncomeFunding && line.Amount >= 0:
+			row[0] = "Income"
+		case line.Kind == TaxIncomeFunding:
+			row[0] = "Margin Fee"
+		case line.Amount >= 0:
+			row[0] = "Derivatives / Futures Profit"
+		default:
+			row[0] = "Derivatives / Futures Loss"
+		}
+		if line.Amount >= 0 {
+			row[1], row[2] = taxAmount(line.Amount), line.Asset
+		} else {
+			row[3], row[4] = taxAmount(-line.Amount), line.Asset
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTax8949 - продажи в колонках Form 8949: сначала краткосрочные (Part I), затем долгосрочные (Part II).
+func writeTax8949(w *csv.Writer, report *TaxReport) error {
+	if err := w.Write([]string{"Description of property", "Date acquired", "Date sold or disposed of",
+		"Proceeds", "Cost or other basis", "Code", "Amount of adjustment", "Gain or (loss)", "Term"}); err != nil {
+		return err
+	}
+	disposals := make([]TaxDisposal, len(report.Disposals))
+	copy(disposals, report.Disposals)
+	sort.SliceStable(disposals, func(i, j int) bool {
+		return !disposals[i].LongTerm() && disposals[j].LongTerm()
+	})
+	date := func(t time.Time) string { return t.In(report.Location).Format("01/02/2006") }
+	for _, d := range disposals {
+		acquired, term := "", "Short-term"
+		if d.Acquired != nil {
+			acquired = date(*d.Acquired)
+		}
+		if d.LongTerm() {
+			term = "Long-term"
+		}
+		if err := w.Write([]string{
+			taxAmount(d.Quantity) + " " + d.Asset, acquired, date(d.Disposed),
+			taxMoney(d.Proceeds), taxMoney(d.CostBasis), "", "", taxMoney(d.Gain()), term,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,506 @@
+package services
+
+import (
+	"bytes"
+	"ctweb/internal/connectors"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/repositories"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Виды доходных строк налоговой выгрузки.
+const (
+	TaxIncomeFuturesPnL = "futures_pnl"
+	TaxIncomeFunding    = "funding"
+)
+
+// taxQtyEpsilon - остаток лота меньше этого объёма считается исчерпанным.
+const taxQtyEpsilon = 1e-12
+
+// maxFiatRatesFile - ограничение на размер CSV-файла курсов.
+const maxFiatRatesFile = 5 << 20
+
+// ErrFiatRateMissing - нет курса актива к выбранной валюте на дату операции.
+var ErrFiatRateMissing = errors.New("fiat rate is missing")
+
+// taxQuoteAssets - котируемые активы для разбора символа позиции без справочника
+// инструментов (от длинных к коротким, чтобы USDT не читался как USD).
+var taxQuoteAssets = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "USD", "EUR", "GBP", "TRY", "BRL", "DAI", "BTC", "ETH", "BNB"}
+
+// taxCashAssets - фиат и стейблкоины. Их трата на покупку не считается продажей: лоты по ним
+// не ведутся. Остальные котируемые активы (BTC, ETH, ...) при покупке за них списываются
+// из лотов FIFO, а при продаже за них - поступают в лоты.
+var taxCashAssets = map[string]bool{
+	"FDUSD": true, "USDT": true, "USDC": true, "BUSD": true, "TUSD": true, "USD": true,
+	"EUR": true, "GBP": true, "TRY": true, "BRL": true, "DAI": true,
+}
+
+// TaxDisposal - продажа части лота SPOT: объём, дата покупки, себестоимость и выручка в фиате.
+type TaxDisposal struct {
+	TransactionID int
+	PositionID    int
+	Exchange      string
+	Asset         string // Проданный актив (базовый; котируемый - при покупке за криптовалюту)
+	Quote         string // Актив, полученный за продажу
+	Quantity      float64
+	Acquired      *time.Time // nil - покупка не найдена в истории, себестоимость 0
+	Disposed      time.Time
+	ProceedsQuote float64 // Выручка за вычетом комиссии в Quote
+	Proceeds      float64
+	CostBasis     float64
+}
+
+// Gain - финансовый результат продажи.
+func (d TaxDisposal) Gain() float64 {
+	return d.Proceeds - d.CostBasis
+}
+
+// LongTerm сообщает, держался ли лот больше года.
+func (d TaxDisposal) LongTerm() bool {
+	return d.Acquired != nil && d.Disposed.After(d.Acquired.AddDate(1, 0, 0))
+}
+
+// TaxIncome - доходная строка FUTURES: реализованный PnL закрытой позиции или funding.
+type TaxIncome struct {
+	Kind          string // TaxIncomeFuturesPnL, TaxIncomeFunding
+	TransactionID int    // 0 - PnL позиции
+	PositionID    int
+	Exchange      string
+	Contract      string
+	Asset         string // Валюта расчётов контракта
+	Date          time.Time
+	Amount        float64 // Со знаком, в Asset
+	Value         float64 // Со знаком, в фиате
+}
+
+// TaxSummary - итоги налогового года в фиате.
+type TaxSummary struct {
+	Disposals     int     `json:"disposals"`
+	Proceeds      float64 `json:"proceeds"`
+	CostBasis     float64 `json:"cost_basis"`
+	Gain          float64 `json:"gain"`
+	ShortTermGain float64 `json:"short_term_gain"`
+	LongTermGain  float64 `json:"long_term_gain"`
+	MissingBasis  int     `json:"missing_basis"` // Продажи без найденной покупки
+	FuturesPnL    float64 `json:"futures_pnl"`
+	Funding       float64 `json:"funding"`
+	IncomeLines   int     `json:"income_lines"`
+}
+
+// TaxReport - продажи и доходные строки налогового года в часовом поясе пользователя.
+type TaxReport struct {
+	Year      int
+	Currency  string
+	Location  *time.Location
+	Disposals []TaxDisposal
+	Income    []TaxIncome
+	Summary   TaxSummary
+}
+
+// taxAssetPair - базовый и котируемый актив инструмента позиции.
+type taxAssetPair struct {
+	Base  string
+	Quote string
+}
+
+// splitContractName разбирает символ вида BTCUSDT, BTC/USDT, BTC-USDT-SWAP на активы.
+func splitContractName(name string) taxAssetPair {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	for _, sep := range []string{"/", "-", "_"} {
+		if parts := strings.Split(name, sep); len(parts) >= 2 && parts[0] != "" && parts[1] != "" {
+			return taxAssetPair{Base: parts[0], Quote: parts[1]}
+		}
+	}
+	name = strings.TrimSuffix(name, "PERP")
+	for _, quote := range taxQuoteAssets {
+		if len(name) > len(quote) && strings.HasSuffix(name, quote) {
+			return taxAssetPair{Base: strings.TrimSuffix(name, quote), Quote: quote}
+		}
+	}
+	return taxAssetPair{Base: name}
+}
+
+// fiatRateBook - дневные курсы активов к одной фиатной валюте.
+type fiatRateBook struct {
+	currency string
+	rates    map[string][]*models.FiatRate // Актив -> курсы по возрастанию даты
+}
+
+// rate возвращает курс актива на дату at: последний загруженный курс не позже дня at (UTC).
+func (b *fiatRateBook) rate(asset string, at time.Time) (float64, error) {
+	if asset == b.currency {
+		return 1, nil
+	}
+	day := at.UTC().Truncate(24 * time.Hour)
+	rates := b.rates[asset]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(day) })
+	if i == 0 {
+		if asset == "" {
+			return 0, fmt.Errorf("%w: unknown settlement asset", ErrFiatRateMissing)
+		}
+		return 0, fmt.Errorf("%w: %s/%s on %s", ErrFiatRateMissing, asset, b.currency, day.Format("2006-01-02"))
+	}
+	return rates[i-1].Rate, nil
+}
+
+type taxLot struct {
+	acquired time.Time
+	qty      float64
+	cost     float64 // Оставшаяся себестоимость в фиате
+}
+
+// taxFuturesClose - закрытая позиция FUTURES с реализованным PnL без учёта funding.
+type taxFuturesClose struct {
+	PositionID int
+	Exchange   string
+	Contract   string
+	Closed     time.Time
+	PnL        float64
+}
+
+// buildTaxReport сопоставляет продажи SPOT с покупками по FIFO (лоты по активу, общие
+// для всех бирж) и собирает доходные строки FUTURES за налоговый год [1 января year, 1 января year+1)
+// в часовом поясе loc. txs - вся история до конца года в порядке исполнения.
+// Покупка за котируемую криптовалюту (ETHBTC) - ещё и продажа потраченного BTC по его
+// рыночной стоимости, продажа за неё - покупка полученного BTC.
+func buildTaxReport(year int, loc *time.Location, txs []*models.TaxTransaction, assets map[int]taxAssetPair, closes []taxFuturesClose, rates *fiatRateBook) (*TaxReport, error) {
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+	inYear := func(t time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	}
+	report := &TaxReport{Year: year, Currency: rates.currency, Location: loc}
+
+	lots := make(map[string][]*taxLot)
+	// dispose списывает qty актива asset из лотов FIFO; received - полученный взамен актив
+	// в объёме receivedQty, proceeds - выручка в фиате. Продажи вне года только расходуют лоты.
+	dispose := func(tx *models.TaxTransaction, asset, received string, qty, receivedQty, proceeds float64) {
+		take := func(amount, cost float64, acquired *time.Time) {
+			if !inYear(tx.TransDate) {
+				return
+			}
+			share := amount / qty
+			report.Disposals = append(report.Disposals, TaxDisposal{
+				TransactionID: tx.ID, PositionID: tx.PositionID, Exchange: tx.ExchangeName,
+				Asset: asset, Quote: received, Quantity: amount, Acquired: acquired, Disposed: tx.TransDate,
+				ProceedsQuote: receivedQty * share, Proceeds: proceeds * share, CostBasis: cost,
+			})
+		}
+		remaining := qty
+		queue := lots[asset]
+		for remaining > taxQtyEpsilon && len(queue) > 0 {
+			lot := queue[0]
+			amount := math.Min(lot.qty, remaining)
+			cost := lot.cost * amount / lot.qty
+			acquired := lot.acquired
+			take(amount, cost, &acquired)
+			lot.qty -= amount
+			lot.cost -= cost
+			remaining -= amount
+			if lot.qty <= taxQtyEpsilon {
+				queue = queue[1:]
+			}
+		}
+		lots[asset] = queue
+		if remaining > taxQtyEpsilon {
+			take(remaining, 0, nil)
+		}
+	}
+	for _, tx := range txs {
+		pair := assets[tx.PositionID]
+		if tx.OpType == "FUNDING" {
+			if !inYear(tx.TransDate) || tx.Funding == 0 {
+				continue
+			}
+			rate, err := rates.rate(pair.Quote, tx.TransDate)
+			if err != nil {
+				return nil, err
+			}
+			report.Income = append(report.Income, TaxIncome{
+				Kind: TaxIncomeFunding, TransactionID: tx.ID, PositionID: tx.PositionID, Exchange: tx.ExchangeName,
+				Contract: tx.ContractName, Asset: pair.Quote, Date: tx.TransDate, Amount: tx.Funding, Value: tx.Funding * rate,
+			})
+			continue
+		}
+		if tx.MarketType != connectors.MarketSpot || tx.Volume == 0 {
+			continue
+		}
+
+		rate, err := rates.rate(pair.Quote, tx.TransDate)
+		if err != nil {
+			return nil, err
+		}
+		cryptoQuote := pair.Quote != "" && pair.Quote != rates.currency && !taxCashAssets[pair.Quote]
+		if tx.Volume > 0 {
+			spent := tx.Price*tx.Volume + tx.Fee
+			qty := tx.Volume - tx.FeeBase
+			if cryptoQuote {
+				dispose(tx, pair.Quote, pair.Base, spent, qty, spent*rate)
+			}
+			if qty <= taxQtyEpsilon {
+				continue
+			}
+			lots[pair.Base] = append(lots[pair.Base], &taxLot{acquired: tx.TransDate, qty: qty, cost: spent * rate})
+			continue
+		}
+
+		qty := -tx.Volume
+		proceedsQuote := tx.Price*qty - tx.Fee
+		dispose(tx, pair.Base, pair.Quote, qty, proceedsQuote, proceedsQuote*rate)
+		if cryptoQuote && proceedsQuote > taxQtyEpsilon {
+			lots[pair.Quote] = append(lots[pair.Quote], &taxLot{acquired: tx.TransDate, qty: proceedsQuote, cost: proceedsQuote * rate})
+		}
+	}
+
+	for _, item := range closes {
+		if !inYear(item.Closed) || item.PnL == 0 {
+			continue
+		}
+		pair := assets[item.PositionID]
+		rate, err := rates.rate(pair.Quote, item.Closed)
+		if err != nil {
+			return nil, err
+		}
+		report.Income = append(report.Income, TaxIncome{
+			Kind: TaxIncomeFuturesPnL, PositionID: item.PositionID, Exchange: item.Exchange, Contract: item.Contract,
+			Asset: pair.Quote, Date: item.Closed, Amount: item.PnL, Value: item.PnL * rate,
+		})
+	}
+	sort.SliceStable(report.Income, func(i, j int) bool {
+		return report.Income[i].Date.Before(report.Income[j].Date)
+	})
+
+	s := &report.Summary
+	for _, d := range report.Disposals {
+		s.Disposals++
+		s.Proceeds += d.Proceeds
+		s.CostBasis += d.CostBasis
+		s.Gain += d.Gain()
+		if d.LongTerm() {
+			s.LongTermGain += d.Gain()
+		} else {
+			s.ShortTermGain += d.Gain()
+		}
+		if d.Acquired == nil {
+			s.MissingBasis++
+		}
+	}
+	for _, line := range report.Income {
+		s.IncomeLines++
+		if line.Kind == TaxIncomeFunding {
+			s.Funding += line.Value
+		} else {
+			s.FuturesPnL += line.Value
+		}
+	}
+	return report, nil
+}
+
+// parseFiatRatesCSV разбирает курсы из CSV: строки "YYYY-MM-DD,rate" (заголовок,
+// разделитель ";" и десятичная запятая при ";" допускаются).
+func parseFiatRatesCSV(asset, currency string, content []byte) ([]*models.FiatRate, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if firstLine, _, _ := strings.Cut(string(content), "\n"); strings.Contains(firstLine, ";") {
+		reader.Comma = ';'
+	}
+
+	byDate := make(map[string]*models.FiatRate)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			if line == 1 {
+				continue // Заголовок
+			}
+			return nil, fmt.Errorf("line %d: date must be in YYYY-MM-DD format", line)
+		}
+		value := strings.TrimSpace(record[1])
+		if reader.Comma == ';' {
+			value = strings.ReplaceAll(value, ",", ".")
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("line %d: rate must be a positive number", line)
+		}
+		byDate[date.Format("2006-01-02")] = &models.FiatRate{Asset: asset, Currency: currency, Date: date, Rate: rate}
+	}
+	if len(byDate) == 0 {
+		return nil, fmt.Errorf("file contains no rates")
+	}
+
+	rates := make([]*models.FiatRate, 0, len(byDate))
+	for _, rate := range byDate {
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	return rates, nil
+}
+
+// normalizeTaxAsset проверяет код актива или валюты: латиница и цифры, до maxLen символов.
+func normalizeTaxAsset(value string, maxLen int) (string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" || len(value) > maxLen {
+		return "", false
+	}
+	for _, r := range value {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// TaxService - налоговая выгрузка за год: продажи SPOT по FIFO, PnL и funding FUTURES
+// с пересчётом в фиат по курсам FIAT_RATES.
+type TaxService struct {
+	positions   *repositories.PositionRepository
+	instruments *repositories.InstrumentRepository
+	rates       *repositories.FiatRateRepository
+}
+
+// NewTaxService создаёт новый экземпляр TaxService.
+func NewTaxService() *TaxService {
+	return &TaxService{
+		positions:   repositories.NewPositionRepository(),
+		instruments: repositories.NewInstrumentRepository(),
+		rates:       repositories.NewFiatRateRepository(),
+	}
+}
+
+// Currencies возвращает валюты, в которые можно пересчитать выгрузку.
+func (s *TaxService) Currencies() ([]string, error) {
+	return s.rates.FindCurrencies()
+}
+
+// RateSeries возвращает загруженные курсы по парам актив/валюта.
+func (s *TaxService) RateSeries() ([]*models.FiatRateSeries, error) {
+	return s.rates.FindSeries()
+}
+
+// ImportRates загружает курсы актива к валюте из CSV. Возвращает количество курсов.
+func (s *TaxService) ImportRates(asset, currency string, content []byte) (int, error) {
+	asset, ok := normalizeTaxAsset(asset, 32)
+	if !ok {
+		return 0, fmt.Errorf("invalid asset")
+	}
+	currency, ok = normalizeTaxAsset(currency, 8)
+	if !ok {
+		return 0, fmt.Errorf("invalid currency")
+	}
+	if asset == currency {
+		return 0, fmt.Errorf("asset and currency must differ")
+	}
+	if len(content) > maxFiatRatesFile {
+		return 0, fmt.Errorf("file is too large")
+	}
+	rates, err := parseFiatRatesCSV(asset, currency, content)
+	if err != nil {
+		return 0, err
+	}
+	return s.rates.UpsertBatch(rates)
+}
+
+// DeleteRates удаляет курсы пары актив/валюта.
+func (s *TaxService) DeleteRates(asset, currency string) error {
+	asset, okAsset := normalizeTaxAsset(asset, 32)
+	currency, okCurrency := normalizeTaxAsset(currency, 8)
+	if !okAsset || !okCurrency {
+		return fmt.Errorf("invalid asset or currency")
+	}
+	_, err := s.rates.DeleteSeries(asset, currency)
+	return err
+}
+
+// Build собирает налоговую выгрузку пользователя за год в валюте currency.
+func (s *TaxService) Build(user *models.User, year int, currency string) (*TaxReport, error) {
+	currency, ok := normalizeTaxAsset(currency, 8)
+	if !ok {
+		return nil, fmt.Errorf("invalid currency")
+	}
+	loc, tzErr := time.LoadLocation(user.Timezone)
+	if tzErr != nil {
+		loc = time.UTC
+	}
+	if year < 2000 || year > time.Now().In(loc).Year() {
+		return nil, fmt.Errorf("invalid tax year")
+	}
+	end := time.Date(year+1, 1, 1, 0, 0, 0, 0, loc)
+
+	txs, err := s.positions.GetTaxTransactions(user.ID, end.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	// Лоты FIFO строятся по всей истории, поэтому нужны все позиции с операциями до конца года.
+	assets := make(map[int]taxAssetPair)
+	pairs := make(map[tickerKey]taxAssetPair)
+	quotes := make(map[string]bool)
+	closes := make([]taxFuturesClose, 0)
+	err = s.positions.EachPosition(user.ID, models.PositionFilter{TradedBefore: end}, func(item *models.PositionSummary) error {
+		key := newTickerKey(item.ExchangeID, item.MarketType, item.ContractName)
+		pair, ok := pairs[key]
+		if !ok {
+			pair = s.assetPair(item.ExchangeID, item.MarketType, item.ContractName)
+			pairs[key] = pair
+		}
+		assets[item.PositionID] = pair
+		quotes[pair.Quote] = true
+		if item.MarketType == connectors.MarketFutures && item.Status != "OPEN" && item.Closed != nil {
+			value := positionValueFromSummary(item)
+			closes = append(closes, taxFuturesClose{
+				PositionID: item.PositionID,
+				Exchange:   item.ExchangeName,
+				Contract:   item.ContractName,
+				Closed:     *item.Closed,
+				PnL:        value.Realized - value.Funding, // Funding выгружается отдельными строками
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	book := &fiatRateBook{currency: currency, rates: make(map[string][]*models.FiatRate)}
+	for quote := range quotes {
+		if quote == "" || quote == currency {
+			continue
+		}
+		if book.rates[quote], err = s.rates.FindRates(quote, currency, end); err != nil {
+			return nil, err
+		}
+	}
+	return buildTaxReport(year, loc, txs, assets, closes, book)
+}
+
+// assetPair определяет активы инструмента позиции по справочнику, иначе по символу.
+func (s *TaxService) assetPair(exchangeID int, market, symbol string) taxAssetPair {
+	instrument, err := s.instruments.FindBySymbol(exchangeID, connectors.NormalizeMarket(market), symbol)
+	if err != nil {
+		logger.Warn().Int("exchange_id", exchangeID).Str("symbol", symbol).Err(err).Msg("Instrument lookup failed")
+	}
+	if instrument != nil && instrument.BaseAsset != "" && instrument.QuoteAsset != "" {
+		return taxAssetPair{Base: instrument.BaseAsset, Quote: instrument.QuoteAsset}
+	}
+	return splitContractName(symbol)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"bytes"
+	"ctweb/internal/models"
+	"encoding/csv"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSplitContractName(t *testing.T) {
+	cases := map[string]taxAssetPair{
+		"btcusdt":       {Base: "BTC", Quote: "USDT"},
+		"ETH/USDC":      {Base: "ETH", Quote: "USDC"},
+		"BTC-USDT-SWAP": {Base: "BTC", Quote: "USDT"},
+		"ETHBTC":        {Base: "ETH", Quote: "BTC"},
+		"BTCUSD":        {Base: "BTC", Quote: "USD"},
+		"BTCPERP":       {Base: "BTC"},
+	}
+	for name, want := range cases {
+		if got := splitContractName(name); got != want {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestFiatRateBook(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	book := &fiatRateBook{currency: "EUR", rates: map[string][]*models.FiatRate{
+		"USDT": {{Date: day(1), Rate: 0.9}, {Date: day(5), Rate: 0.95}},
+	}}
+	if rate, err := book.rate("USDT", day(4).Add(23*time.Hour)); err != nil || rate != 0.9 {
+		t.Fatalf("rate before update: got %v, %v", rate, err)
+	}
+	if rate, err := book.rate("USDT", day(5).Add(time.Hour)); err != nil || rate != 0.95 {
+		t.Fatalf("rate on update day: got %v, %v", rate, err)
+	}
+	if rate, err := book.rate("EUR", day(1)); err != nil || rate != 1 {
+		t.Fatalf("same currency: got %v, %v", rate, err)
+	}
+	if _, err := book.rate("USDT", day(1).Add(-time.Hour)); !errors.Is(err, ErrFiatRateMissing) {
+		t.Fatalf("rate before first: got %v", err)
+	}
+}
+
+func TestBuildTaxReportFIFO(t *testing.T) {
+	at := func(year, month, day int) time.Time {
+		return time.Date(year, time.Month(month), day, 12, 0, 0, 0, time.UTC)
+	}
+	txs := []*models.TaxTransaction{
+		{ID: 1, PositionID: 1, MarketType: "SPOT", OpType: "TRADE", Price: 100, Volume: 1.01, FeeBase: 0.01, TransDate: at(2024, 1, 10)},
+		{ID: 2, PositionID: 1, MarketType: "SPOT", OpType: "TRADE", Price: 200, Volume: 1, Fee: 2, TransDate: at(2025, 2, 1)},
+		{ID: 3, PositionID: 1, MarketType: "SPOT", OpType: "TRADE", Price: 300, Volume: -1.5, Fee: 3, TransDate: at(2025, 3, 1)},
+		{ID: 4, PositionID: 2, MarketType: "SPOT", OpType: "TRADE", Price: 10, Volume: -2, TransDate: at(2025, 4, 1)},
+		{ID: 5, PositionID: 3, MarketType: "FUTURES", OpType: "TRADE", Price: 50, Volume: 1, TransDate: at(2025, 5, 1)},
+		{ID: 6, PositionID: 3, MarketType: "FUTURES", OpType: "FUNDING", Funding: -4, TransDate: at(2025, 5, 2)},
+		{ID: 7, PositionID: 3, MarketType: "FUTURES", OpType: "FUNDING", Funding: 1, TransDate: at(2024, 12, 31)},
+	}
+	assets := map[int]taxAssetPair{
+		1: {Base: "BTC", Quote: "USDT"},
+		2: {Base: "SOL", Quote: "USDT"},
+		3: {Base: "ETH", Quote: "USDT"},
+	}
+	closes := []taxFuturesClose{
+		{PositionID: 3, Closed: at(2025, 6, 1), PnL: 20},
+		{PositionID: 3, Closed: at(2024, 6, 1), PnL: 99},
+	}
+	book := &fiatRateBook{currency: "USD", rates: map[string][]*models.FiatRate{
+		"USDT": {{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Rate: 1}, {Date: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Rate: 2}},
+	}}
+
+	report, err := buildTaxReport(2025, time.UTC, txs, assets, closes, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Disposals) != 3 {
+		t.Fatalf("disposals: got %d, want 3", len(report.Disposals))
+	}
+	// Первый лот: 1 BTC (комиссия в BTC уменьшает объём) за 101, держался больше года
+	first := report.Disposals[0]
+	if first.Quantity != 1 || !first.LongTerm() || math.Abs(first.CostBasis-101) > 1e-9 || math.Abs(first.Proceeds-596) > 1e-9 {
+		t.Fatalf("unexpected first disposal: %+v", first)
+	}
+	// Второй лот: половина покупки за 202
+	second := report.Disposals[1]
+	if math.Abs(second.Quantity-0.5) > 1e-12 || second.LongTerm() || math.Abs(second.CostBasis-101) > 1e-9 {
+		t.Fatalf("unexpected second disposal: %+v", second)
+	}
+	// Продажа без покупки - нулевая себестоимость
+	if third := report.Disposals[2]; third.Acquired != nil || third.CostBasis != 0 || math.Abs(third.Proceeds-40) > 1e-9 {
+		t.Fatalf("unexpected disposal without lots: %+v", third)
+	}
+
+	s := report.Summary
+	if s.MissingBasis != 1 || math.Abs(s.Gain-(894-202+40)) > 1e-9 || math.Abs(s.LongTermGain-(596-101)) > 1e-9 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	if len(report.Income) != 2 || report.Income[0].Kind != TaxIncomeFunding || report.Income[1].Kind != TaxIncomeFuturesPnL {
+		t.Fatalf("unexpected income lines: %+v", report.Income)
+	}
+	if math.Abs(s.Funding+8) > 1e-9 || math.Abs(s.FuturesPnL-40) > 1e-9 {
+		t.Fatalf("unexpected income totals: %+v", s)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTaxCSV(&buf, report, TaxFormat8949); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Краткосрочные продажи идут перед долгосрочными
+	if len(rows) != 4 || rows[1][8] != "Short-term" || rows[3][8] != "Long-term" || rows[3][1] != "01/10/2024" {
+		t.Fatalf("unexpected form 8949 rows: %q", rows)
+	}
+}
+
+func TestBuildTaxReportCryptoQuote(t *testing.T) {
+	day := func(month, d int) time.Time {
+		return time.Date(2025, time.Month(month), d, 0, 0, 0, 0, time.UTC)
+	}
+	at := func(month, d int) time.Time {
+		return day(month, d).Add(12 * time.Hour)
+	}
+	txs := []*models.TaxTransaction{
+		// 1 BTC за 100 USDT, на 0.5 BTC куплено 10 ETH, 10 ETH проданы за 0.6 BTC
+		{ID: 1, PositionID: 1, MarketType: "SPOT", OpType: "TRADE", Price: 100, Volume: 1, TransDate: at(1, 10)},
+		{ID: 2, PositionID: 2, MarketType: "SPOT", OpType: "TRADE", Price: 0.05, Volume: 10, TransDate: at(2, 1)},
+		{ID: 3, PositionID: 2, MarketType: "SPOT", OpType: "TRADE", Price: 0.06, Volume: -10, TransDate: at(3, 1)},
+	}
+	assets := map[int]taxAssetPair{
+		1: {Base: "BTC", Quote: "USDT"},
+		2: {Base: "ETH", Quote: "BTC"},
+	}
+	book := &fiatRateBook{currency: "USD", rates: map[string][]*models.FiatRate{
+		"USDT": {{Date: day(1, 1), Rate: 1}},
+		"BTC":  {{Date: day(1, 1), Rate: 100}, {Date: day(2, 1), Rate: 200}, {Date: day(3, 1), Rate: 300}},
+	}}
+
+	report, err := buildTaxReport(2025, time.UTC, txs, assets, nil, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Disposals) != 2 {
+		t.Fatalf("disposals: got %+v, want 2", report.Disposals)
+	}
+	// Покупка ETH за BTC: продано 0.5 BTC из лота за 50 по курсу 200
+	spent := report.Disposals[0]
+	if spent.Asset != "BTC" || spent.Quote != "ETH" || math.Abs(spent.Quantity-0.5) > 1e-12 ||
+		math.Abs(spent.CostBasis-50) > 1e-9 || math.Abs(spent.Proceeds-100) > 1e-9 || spent.ProceedsQuote != 10 {
+		t.Fatalf("unexpected BTC disposal: %+v", spent)
+	}
+	// Продажа ETH за BTC: себестоимость ETH - стоимость потраченного BTC
+	sold := report.Disposals[1]
+	if sold.Asset != "ETH" || sold.Quote != "BTC" || math.Abs(sold.CostBasis-100) > 1e-9 || math.Abs(sold.Proceeds-180) > 1e-9 {
+		t.Fatalf("unexpected ETH disposal: %+v", sold)
+	}
+	if report.Summary.MissingBasis != 0 {
+		t.Fatalf("unexpected summary: %+v", report.Summary)
+	}
+}
+
+func TestBuildTaxReportMissingRate(t *testing.T) {
+	txs := []*models.TaxTransaction{
+		{ID: 1, PositionID: 1, MarketType: "SPOT", OpType: "TRADE", Price: 1, Volume: 1, TransDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	book := &fiatRateBook{currency: "EUR", rates: map[string][]*models.FiatRate{}}
+	_, err := buildTaxReport(2025, time.UTC, txs, map[int]taxAssetPair{1: {Base: "BTC", Quote: "USDT"}}, nil, book)
+	if !errors.Is(err, ErrFiatRateMissing) {
+		t.Fatalf("got %v, want ErrFiatRateMissing", err)
+	}
+}
+
+func TestParseFiatRatesCSV(t *testing.T) {
+	rates, err := parseFiatRatesCSV("USDT", "EUR", []byte("date;rate\n2025-01-02;0,91\n2025-01-01;0,9\n2025-01-02;0,92\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0].Rate != 0.9 || rates[1].Rate != 0.92 {
+		t.Fatalf("unexpected rates: %+v, %+v", rates[0], rates[1])
+	}
+	if _, err := parseFiatRatesCSV("USDT", "EUR", []byte("2025-01-01,0.9\n2025-01-02,-1\n")); err == nil {
+		t.Fatalf("negative rate accepted")
+	}
+}
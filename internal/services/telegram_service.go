@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"ctweb/internal/config"
+	"ctweb/internal/logger"
+	"ctweb/internal/models"
+	"ctweb/internal/notify"
+	"ctweb/internal/repositories"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// telegramLinkCodeAlphabet - символы кода привязки без похожих друг на друга (0/O, 1/I).
+	telegramLinkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	telegramLinkCodeLength   = 8
+	// telegramRetryDelay - пауза после ошибки getUpdates.
+	telegramRetryDelay = 5 * time.Second
+	// telegramMaxPositions - сколько позиций выводит /positions (предел длины сообщения).
+	telegramMaxPositions = 20
+)
+
+// ErrTelegramDisabled - бот не настроен (пустой notify.telegram.bot_token).
+var ErrTelegramDisabled = errors.New("telegram bot is not configured")
+
+// telegramHelp - ответ на /help и на сообщения, не являющиеся командами.
+const telegramHelp = `Commands:
+/positions - open positions with current prices
+/pnl - PnL summary
+/position <id> - position details
+/unlink - unlink this chat
+
+To link the chat, get a code on your profile page and send /start <code>.`
+
+// TelegramService - привязка Telegram-чатов к пользователям одноразовым кодом и бот,
+// отвечающий на команды по позициям привязанного пользователя.
+type TelegramService struct {
+	repo      *repositories.TelegramRepository
+	positions *PositionService
+	client    *notify.TelegramClient // nil - бот не настроен
+	botName   string
+	codeTTL   time.Duration
+}
+
+// NewTelegramService создаёт сервис по настройкам notify.telegram.
+func NewTelegramService() *TelegramService {
+	cfg := config.Get().Notify.Telegram
+	s := &TelegramService{
+		repo:      repositories.NewTelegramRepository(),
+		positions: NewPositionService(),
+		botName:   cfg.BotName,
+		codeTTL:   cfg.LinkCodeTTL,
+	}
+	if cfg.BotToken != "" {
+		s.client = notify.NewTelegramClient(cfg)
+	}
+	return s
+}
+
+// Enabled сообщает, настроен ли бот.
+func (s *TelegramService) Enabled() bool {
+	return s.client != nil
+}
+
+// BotName возвращает имя бота без @ ("" - не задано в настройках).
+func (s *TelegramService) BotName() string {
+	return s.botName
+}
+
+// Link возвращает привязку пользователя (nil - чат не привязан).
+func (s *TelegramService) Link(userID int) (*models.TelegramLink, error) {
+	return s.repo.FindByUser(userID)
+}
+
+// CreateLinkCode выдаёт пользователю новый одноразовый код привязки; прежний код перестаёт действовать.
+func (s *TelegramService) CreateLinkCode(userID int) (string, time.Time, error) {
+	if !s.Enabled() {
+		return "", time.Time{}, ErrTelegramDisabled
+	}
+	code, err := newTelegramLinkCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expire := time.Now().UTC().Add(s.codeTTL).Truncate(time.Second)
+	if err := s.repo.SaveCode(userID, hashTelegramLinkCode(code), expire); err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expire, nil
+}
+
+// Unlink отвязывает чат пользователя.
+func (s *TelegramService) Unlink(userID int) error {
+	_, err := s.repo.DeleteByUser(userID)
+	return err
+}
+
+func newTelegramLinkCode() (string, error) {
+	buf := make([]byte, telegramLinkCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	// 256 делится на длину алфавита, поэтому остаток распределён равномерно
+	for i, b := range buf {
+		buf[i] = telegramLinkCodeAlphabet[int(b)%len(telegramLinkCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// hashTelegramLinkCode - ключ кода в TELEGRAM_LINK_CODES. Регистр и пробелы не важны.
+func hashTelegramLinkCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseTelegramCommand разбирает "/cmd@BotName arg1 arg2" на команду в нижнем регистре
+// и аргументы. Для текста без "/" возвращает пустую команду.
+func parseTelegramCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+	command := strings.TrimPrefix(fields[0], "/")
+	if i := strings.IndexByte(command, '@'); i >= 0 {
+		command = command[:i]
+	}
+	return strings.ToLower(command), fields[1:]
+}
+
+// Run получает сообщения бота long polling'ом и отвечает на команды до отмены ctx.
+// Ненастроенный бот сразу возвращает управление.
+func (s *TelegramService) Run(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	logger.Info().Msg("Telegram bot started")
+	var offset int64
+	for {
+		updates, err := s.client.GetUpdates(ctx, offset)
+		if ctx.Err() != nil {
+			logger.Info().Msg("Telegram bot stopped")
+			return
+		}
+		if err != nil {
+			logger.Warn().Err(err).Msg("Telegram getUpdates failed")
+			select {
+			case <-ctx.Done():
+				logger.Info().Msg("Telegram bot stopped")
+				return
+			case <-time.After(telegramRetryDelay):
+			}
+			continue
+		}
+		for _, update := range updates {
+			if update.UpdateID >= offset {
+				offset = update.UpdateID + 1
+			}
+			if update.Message != nil {
+				s.handleMessage(ctx, update.Message)
+			}
+		}
+	}
+}
+
+func (s *TelegramService) handleMessage(ctx context.Context, msg *notify.TelegramMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error().Interface("panic", r).Msg("Telegram bot command panicked")
+		}
+	}()
+
+	text := s.reply(ctx, msg)
+	if text == "" {
+		return
+	}
+	if err := s.client.SendMessage(ctx, msg.Chat.ID, text); err != nil {
+		logger.Warn().Int64("chat_id", msg.Chat.ID).Err(err).Msg("Telegram reply failed")
+	}
+}
+
+// reply выполняет команду сообщения и возвращает текст ответа.
+func (s *TelegramService) reply(ctx context.Context, msg *notify.TelegramMessage) string {
+	if msg.Chat.Type != "private" {
+		return "This bot works in private chats only."
+	}
+	command, args := parseTelegramCommand(msg.Text)
+	switch command {
+	case "start", "link":
+		if len(args) == 0 {
+			return telegramHelp
+		}
+		username := ""
+		if msg.From != nil {
+			username = msg.From.Username
+		}
+		userID, err := s.repo.RedeemCode(hashTelegramLinkCode(args[0]), msg.Chat.ID, username, time.Now().UTC())
+		if err != nil {
+			logger.Error().Err(err).Msg("Telegram link failed")
+			return "Failed to link the chat, try again later."
+		}
+		if userID == 0 {
+			return "The code is invalid or expired. Get a new one on your profile page."
+		}
+		return "Chat linked. Send /help to see the commands."
+	case "unlink":
+		ok, err := s.repo.DeleteByChat(msg.Chat.ID)
+		if err != nil {
+			logger.Error().Err(err).Msg("Telegram unlink failed")
+			return "Failed to unlink the chat, try again later."
+		}
+		if !ok {
+			return "This chat is not linked."
+		}
+		return "Chat unlinked. Telegram alerts will not be delivered here anymore."
+	case "", "help":
+		return telegramHelp
+	case "positions", "pnl", "position":
+	default:
+		return "Unknown command.\n\n" + telegramHelp
+	}
+
+	link, err := s.repo.FindByChat(msg.Chat.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Telegram link lookup failed")
+		return "Service is temporarily unavailable, try again later."
+	}
+	if link == nil {
+		return "This chat is not linked. Get a code on your profile page and send /start <code>."
+	}
+
+	filter := models.PositionFilter{}
+	if command == "position" {
+		id := 0
+		if len(args) > 0 {
+			id, _ = strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		}
+		if id <= 0 {
+			return "Usage: /position <id>"
+		}
+		filter.PositionID = id
+	}
+	values, err := s.positions.PositionValues(ctx, link.UID, filter)
+	if err != nil {
+		logger.Error().Int("uid", link.UID).Err(err).Msg("Telegram positions query failed")
+		return "Failed to load positions, try again later."
+	}
+	switch command {
+	case "positions":
+		return formatTelegramPositions(values)
+	case "pnl":
+		return formatTelegramPnL(values)
+	default:
+		if len(values) == 0 {
+			return fmt.Sprintf("Position #%d not found.", filter.PositionID)
+		}
+		return formatTelegramPosition(values[0])
+	}
+}
+
+// formatSignedNumber - число со знаком "+" для положительных значений PnL.
+func formatSignedNumber(value float64) string {
+	if value > 0 {
+		return "+" + formatAlertNumber(value)
+	}
+	return formatAlertNumber(value)
+}
+
+func telegramPositionTitle(v PositionValue) string {
+	return fmt.Sprintf("#%d %s · %s %s", v.PositionID, v.Name, v.Exchange, strings.ToUpper(v.Market))
+}
+
+func telegramSide(position float64) string {
+	switch {
+	case position > 0:
+		return "Long " + formatAlertNumber(position)
+	case position < 0:
+		return "Short " + formatAlertNumber(math.Abs(position))
+	default:
+		return "Flat"
+	}
+}
+
+func telegramPrice(v PositionValue) string {
+	if v.Price <= 0 {
+		return "n/a"
+	}
+	return formatAlertNumber(v.Price)
+}
+
+// formatTelegramPositions - ответ на /positions: открытые позиции с ценой и нереализованным PnL.
+func formatTelegramPositions(values []PositionValue) string {
+	open := make([]PositionValue, 0, len(values))
+	for _, v := range values {
+		if v.Status == "OPEN" {
+			open = append(open, v)
+		}
+	}
+	if len(open) == 0 {
+		return "No open positions."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Open positions: %d", len(open))
+	for i, v := range open {
+		if i == telegramMaxPositions {
+			fmt.Fprintf(&b, "\n\n…and %d more", len(open)-telegramMaxPositions)
+			break
+		}
+		fmt.Fprintf(&b, "\n\n%s\n%s @ %s → %s", telegramPositionTitle(v), telegramSide(v.Position), formatAlertNumber(v.AvgPrice), telegramPrice(v))
+		unrealized := "n/a"
+		if v.Position == 0 || v.hasPrice() {
+			unrealized = formatSignedNumber(v.Unrealized())
+		}
+		fmt.Fprintf(&b, "\nUnrealized: %s · Realized: %s", unrealized, formatSignedNumber(v.Realized))
+	}
+	return b.String()
+}
+
+// formatTelegramPnL - ответ на /pnl: PnL открытых позиций по текущим ценам и реализованный
+// PnL закрытых. Комиссии и funding уже учтены в реализованном PnL.
+func formatTelegramPnL(values []PositionValue) string {
+	var open, closed []PositionValue
+	for _, v := range values {
+		if v.Status == "OPEN" {
+			open = append(open, v)
+		} else {
+			closed = append(closed, v)
+		}
+	}
+	openTotals := ComputePositionTotals(open)
+	closedTotals := ComputePositionTotals(closed)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "PnL summary\n\nOpen positions: %d", len(open))
+	if openTotals.MissingPrices > 0 {
+		fmt.Fprintf(&b, " (%d without price)", openTotals.MissingPrices)
+	}
+	fmt.Fprintf(&b, "\nUnrealized: %s", formatSignedNumber(openTotals.UnrealizedPnL))
+	fmt.Fprintf(&b, "\nRealized (open): %s", formatSignedNumber(openTotals.RealizedPnL))
+	fmt.Fprintf(&b, "\nRealized (closed, %d): %s", len(closed), formatSignedNumber(closedTotals.RealizedPnL))
+	total := openTotals.UnrealizedPnL + openTotals.RealizedPnL + closedTotals.RealizedPnL
+	fmt.Fprintf(&b, "\nTotal: %s", formatSignedNumber(total))
+	fmt.Fprintf(&b, "\n\nFunding: %s · Fees: %s",
+		formatSignedNumber(openTotals.NetFunding+closedTotals.NetFunding), formatAlertNumber(openTotals.Fees+closedTotals.Fees))
+	return b.String()
+}
+
+// formatTelegramPosition - ответ на /position <id>.
+func formatTelegramPosition(v PositionValue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s · %s", telegramPositionTitle(v), v.Status)
+	fmt.Fprintf(&b, "\nPosition: %s", telegramSide(v.Position))
+	if v.Position != 0 {
+		fmt.Fprintf(&b, "\nAvg price: %s\nPrice: %s", formatAlertNumber(v.AvgPrice), telegramPrice(v))
+		if v.hasPrice() {
+			fmt.Fprintf(&b, "\nUnrealized: %s", formatSignedNumber(v.Unrealized()))
+		}
+	}
+	fmt.Fprintf(&b, "\nRealized: %s\nFunding: %s\nFees: %s",
+		formatSignedNumber(v.Realized), formatSignedNumber(v.Funding), formatAlertNumber(v.Fee))
+	return b.String()
+}
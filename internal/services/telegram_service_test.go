@@ -0,0 +1,76 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTelegramCommand(t *testing.T) {
+	cases := []struct {
+		text    string
+		command string
+		args    []string
+	}{
+		{"/positions", "positions", []string{}},
+		{"/Position@ct_bot  42 ", "position", []string{"42"}},
+		{"/start ABCD2345", "start", []string{"ABCD2345"}},
+		{"hello", "", nil},
+		{"", "", nil},
+	}
+	for _, tc := range cases {
+		command, args := parseTelegramCommand(tc.text)
+		if command != tc.command || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("parseTelegramCommand(%q) = %q %v, want %q %v", tc.text, command, args, tc.command, tc.args)
+		}
+	}
+}
+
+func TestTelegramLinkCode(t *testing.T) {
+	code, err := newTelegramLinkCode()
+	if err != nil {
+		t.Fatalf("newTelegramLinkCode() error = %v", err)
+	}
+	if len(code) != telegramLinkCodeLength || strings.Trim(code, telegramLinkCodeAlphabet) != "" {
+		t.Fatalf("unexpected code %q", code)
+	}
+	if hashTelegramLinkCode(" "+strings.ToLower(code)+"\n") != hashTelegramLinkCode(code) {
+		t.Fatal("code hash must ignore case and surrounding spaces")
+	}
+}
+
+func TestFormatTelegramReplies(t *testing.T) {
+	values := []PositionValue{
+		{PositionID: 1, Name: "BTCUSDT", Exchange: "Binance", Market: "futures", Status: "OPEN", Position: -0.5, AvgPrice: 60000, Price: 59000, Realized: -2, Funding: 1.5, Fee: 3},
+		{PositionID: 2, Name: "ETHUSDT", Exchange: "Bybit", Market: "SPOT", Status: "OPEN", Position: 2, AvgPrice: 3000},
+		{PositionID: 3, Name: "SOLUSDT", Exchange: "OKX", Market: "SPOT", Status: "CLOSED", Realized: 100, Fee: 1},
+	}
+
+	positions := formatTelegramPositions(values)
+	for _, want := range []string{"Open positions: 2", "#1 BTCUSDT · Binance FUTURES", "Short 0.5 @ 60000 → 59000", "Unrealized: +500", "→ n/a", "Unrealized: n/a"} {
+		if !strings.Contains(positions, want) {
+			t.Errorf("/positions reply misses %q:\n%s", want, positions)
+		}
+	}
+	if strings.Contains(positions, "SOLUSDT") {
+		t.Errorf("/positions must list open positions only:\n%s", positions)
+	}
+
+	pnl := formatTelegramPnL(values)
+	for _, want := range []string{"Open positions: 2 (1 without price)", "Unrealized: +500", "Realized (open): -2", "Realized (closed, 1): +100", "Total: +598", "Funding: +1.5 · Fees: 4"} {
+		if !strings.Contains(pnl, want) {
+			t.Errorf("/pnl reply misses %q:\n%s", want, pnl)
+		}
+	}
+
+	position := formatTelegramPosition(values[0])
+	for _, want := range []string{"#1 BTCUSDT · Binance FUTURES · OPEN", "Position: Short 0.5", "Price: 59000", "Unrealized: +500", "Fees: 3"} {
+		if !strings.Contains(position, want) {
+			t.Errorf("/position reply misses %q:\n%s", want, position)
+		}
+	}
+
+	if got := formatTelegramPositions(values[2:]); got != "No open positions." {
+		t.Errorf("unexpected reply without open positions: %q", got)
+	}
+}
@@ -0,0 +1,18 @@
+-- Справочник торговых инструментов бирж (синхронизируется через коннекторы).
+CREATE TABLE IF NOT EXISTS INSTRUMENTS (
+    ID             INT UNSIGNED    NOT NULL AUTO_INCREMENT,
+    EXID           INT             NOT NULL,
+    SYMBOL         VARCHAR(64)     NOT NULL,
+    MARKET_TYPE    VARCHAR(16)     NOT NULL,
+    BASE_ASSET     VARCHAR(32)     NOT NULL DEFAULT '',
+    QUOTE_ASSET    VARCHAR(32)     NOT NULL DEFAULT '',
+    TICK_SIZE      DECIMAL(36, 18) NOT NULL DEFAULT 0,
+    LOT_SIZE       DECIMAL(36, 18) NOT NULL DEFAULT 0,
+    MIN_QTY        DECIMAL(36, 18) NOT NULL DEFAULT 0,
+    CONTRACT_VALUE DECIMAL(36, 18) NOT NULL DEFAULT 1,
+    STATUS         VARCHAR(16)     NOT NULL DEFAULT 'TRADING',
+    DATE_SYNC      DATETIME        NOT NULL,
+    PRIMARY KEY (ID),
+    UNIQUE KEY UX_INSTRUMENTS_EX_MARKET_SYMBOL (EXID, MARKET_TYPE, SYMBOL),
+    KEY IX_INSTRUMENTS_BASE (BASE_ASSET, QUOTE_ASSET)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
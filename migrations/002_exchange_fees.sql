@@ -0,0 +1,22 @@
+-- Комиссии бирж по умолчанию и VIP-переопределения для аккаунтов.
+-- Значения хранятся в процентах (0.1 = 0.1%), как их показывает market_analysis.js.
+CREATE TABLE IF NOT EXISTS EXCHANGE_FEES (
+    EXID        INT            NOT NULL,
+    MARKET_TYPE VARCHAR(16)    NOT NULL,
+    MAKER_FEE   DECIMAL(10, 6) NOT NULL DEFAULT 0,
+    TAKER_FEE   DECIMAL(10, 6) NOT NULL DEFAULT 0,
+    USER_MODIFY INT            NULL,
+    DATE_MODIFY DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (EXID, MARKET_TYPE)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS EXCHANGE_ACCOUNT_FEES (
+    ACCOUNT_ID  INT            NOT NULL,
+    MARKET_TYPE VARCHAR(16)    NOT NULL,
+    VIP_TIER    VARCHAR(32)    NOT NULL DEFAULT '',
+    MAKER_FEE   DECIMAL(10, 6) NOT NULL DEFAULT 0,
+    TAKER_FEE   DECIMAL(10, 6) NOT NULL DEFAULT 0,
+    USER_MODIFY INT            NULL,
+    DATE_MODIFY DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (ACCOUNT_ID, MARKET_TYPE)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
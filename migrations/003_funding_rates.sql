@@ -0,0 +1,13 @@
+-- История ставок финансирования бессрочных контрактов.
+-- RATE хранится в долях (0.0001 = 0.01%), как его отдают биржи.
+-- SOURCE: 'api' - загружено через коннектор, 'file' - импортировано из файла.
+CREATE TABLE IF NOT EXISTS FUNDING_RATES (
+    EXID         INT             NOT NULL,
+    SYMBOL       VARCHAR(64)     NOT NULL,
+    FUNDING_TIME DATETIME        NOT NULL,
+    RATE         DECIMAL(20, 12) NOT NULL,
+    MARK_PRICE   DECIMAL(30, 12) NULL,
+    SOURCE       VARCHAR(8)      NOT NULL DEFAULT 'api',
+    DATE_SYNC    DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (EXID, SYMBOL, FUNDING_TIME)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
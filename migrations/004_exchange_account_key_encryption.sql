@@ -0,0 +1,8 @@
+-- Шифрование API-ключей аккаунтов бирж (security.encryption).
+-- Зашифрованное значение длиннее исходного ключа: префикс enc:v1:, ID мастер-ключа,
+-- завёрнутый ключ данных и шифротекст в base64. После применения миграции
+-- существующие ключи шифруются командой: go run ./cmd/keys encrypt
+ALTER TABLE EXCHANGE_ACCOUNTS
+    MODIFY API_KEY    VARCHAR(1024) NOT NULL,
+    MODIFY SECRET_KEY VARCHAR(1024) NOT NULL,
+    MODIFY ADD_KEY    VARCHAR(1024) NULL;
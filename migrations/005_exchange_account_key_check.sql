@@ -0,0 +1,11 @@
+-- Результат последней проверки ключа API аккаунта ("Test connection").
+-- LAST_CHECK_STATUS: valid, invalid, ip_blocked, error, unsupported.
+-- PERM_*: права ключа по данным биржи на момент проверки (NULL - не проверялись).
+ALTER TABLE EXCHANGE_ACCOUNTS
+    ADD COLUMN LAST_CHECK_AT      DATETIME     NULL,
+    ADD COLUMN LAST_CHECK_STATUS  VARCHAR(16)  NULL,
+    ADD COLUMN LAST_CHECK_MESSAGE VARCHAR(255) NULL,
+    ADD COLUMN PERM_READ          TINYINT(1)   NULL,
+    ADD COLUMN PERM_TRADE         TINYINT(1)   NULL,
+    ADD COLUMN PERM_WITHDRAW      TINYINT(1)   NULL,
+    ADD COLUMN IP_RESTRICTED      TINYINT(1)   NULL;
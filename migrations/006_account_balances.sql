@@ -0,0 +1,31 @@
+-- Снимки балансов аккаунтов бирж (по запросу со страницы аккаунтов и задачей jobs.balance_snapshot_interval).
+-- ACCOUNT_BALANCE_SNAPSHOTS - заголовок снимка с суммарной оценкой в USD (история стоимости аккаунта),
+-- ACCOUNT_BALANCES - ненулевые остатки активов на момент снимка.
+CREATE TABLE IF NOT EXISTS ACCOUNT_BALANCE_SNAPSHOTS (
+    ID              INT            NOT NULL AUTO_INCREMENT,
+    ACCOUNT_ID      INT            NOT NULL,
+    UID             INT            NOT NULL,
+    SNAPSHOT_AT     DATETIME       NOT NULL,
+    USD_VALUE       DECIMAL(30, 8) NOT NULL DEFAULT 0, -- Сумма оценённых активов
+    UNPRICED_ASSETS INT            NOT NULL DEFAULT 0, -- Активы без цены (не вошли в USD_VALUE)
+    PRIMARY KEY (ID),
+    KEY IX_ACCOUNT_BALANCE_SNAPSHOTS_ACCOUNT (ACCOUNT_ID, SNAPSHOT_AT),
+    KEY IX_ACCOUNT_BALANCE_SNAPSHOTS_UID (UID)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS ACCOUNT_BALANCES (
+    ID          INT             NOT NULL AUTO_INCREMENT,
+    SNAPSHOT_ID INT             NOT NULL,
+    ACCOUNT_ID  INT             NOT NULL,
+    SNAPSHOT_AT DATETIME        NOT NULL,
+    WALLET      VARCHAR(32)     NOT NULL, -- Кошелёк биржи: spot, futures, unified, trading, main, trade...
+    ASSET       VARCHAR(32)     NOT NULL,
+    FREE        DECIMAL(30, 12) NOT NULL DEFAULT 0,
+    LOCKED      DECIMAL(30, 12) NOT NULL DEFAULT 0,
+    USD_VALUE   DECIMAL(30, 8)  NULL,     -- NULL - цена актива не найдена
+    PRIMARY KEY (ID),
+    KEY IX_ACCOUNT_BALANCES_SNAPSHOT (SNAPSHOT_ID),
+    KEY IX_ACCOUNT_BALANCES_ACCOUNT (ACCOUNT_ID, SNAPSHOT_AT, ASSET),
+    CONSTRAINT FK_ACCOUNT_BALANCES_SNAPSHOT FOREIGN KEY (SNAPSHOT_ID)
+        REFERENCES ACCOUNT_BALANCE_SNAPSHOTS (ID) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,22 @@
+-- Последняя сверка открытых позиций пользователя с биржей (задача jobs.reconcile_interval
+-- и кнопка "Reconcile" в списке позиций). Каждая сверка заменяет строки пользователя.
+-- FUTURES: размер и средняя цена контракта, SPOT: размер позиций и остаток базового актива.
+CREATE TABLE IF NOT EXISTS POS_RECONCILIATION (
+    ID           INT             NOT NULL AUTO_INCREMENT,
+    UID          INT             NOT NULL,
+    RUN_AT       DATETIME        NOT NULL,
+    EXID         INT             NOT NULL,
+    ACCOUNT_ID   INT             NULL,     -- NULL - все аккаунты пользователя на бирже
+    MARKET_TYPE  VARCHAR(16)     NOT NULL,
+    SYMBOL       VARCHAR(64)     NOT NULL,
+    ASSET        VARCHAR(32)     NULL,
+    POSITION_IDS VARCHAR(255)    NOT NULL DEFAULT '',
+    CALC_SIZE    DECIMAL(30, 12) NOT NULL DEFAULT 0,
+    LIVE_SIZE    DECIMAL(30, 12) NULL,
+    CALC_PRICE   DECIMAL(30, 12) NULL,
+    LIVE_PRICE   DECIMAL(30, 12) NULL,
+    STATUS       VARCHAR(16)     NOT NULL, -- ok, mismatch, missing, untracked, no_account, error
+    MESSAGE      VARCHAR(255)    NULL,
+    PRIMARY KEY (ID),
+    KEY IX_POS_RECONCILIATION_UID (UID, RUN_AT)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+-- Привязка позиции к аккаунту биржи (у пользователя может быть несколько аккаунтов на одной бирже).
+-- NULL - позиция не привязана к аккаунту: сверка идёт со всеми активными аккаунтами пользователя на бирже.
+ALTER TABLE POS_POSITIONS
+    ADD COLUMN ACCOUNT_ID INT NULL AFTER EXID,
+    ADD KEY IX_POS_POSITIONS_ACCOUNT (ACCOUNT_ID);
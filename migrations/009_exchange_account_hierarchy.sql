@@ -0,0 +1,7 @@
+-- Мастер-аккаунты и субаккаунты бирж (Bybit, Binance, OKX, KuCoin).
+-- PARENT_ID: ID мастер-аккаунта того же пользователя на той же бирже (NULL - мастер или самостоятельный аккаунт).
+-- SUB_ACCOUNT_LABEL: имя субаккаунта на бирже (uid/логин субаккаунта), необязательно.
+ALTER TABLE EXCHANGE_ACCOUNTS
+    ADD COLUMN PARENT_ID         INT         NULL AFTER UID,
+    ADD COLUMN SUB_ACCOUNT_LABEL VARCHAR(64) NULL AFTER ACCOUNT_NAME,
+    ADD KEY IX_EXCHANGE_ACCOUNTS_PARENT (PARENT_ID);
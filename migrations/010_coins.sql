@@ -0,0 +1,32 @@
+-- Справочник монет (страница /coins/, синхронизация со справочниками монет бирж).
+-- COINS - монета (одна запись на тикер) и путь к локальной иконке,
+-- COIN_NETWORKS - сети ввода/вывода монеты на каждой бирже со статусами депозита и вывода.
+CREATE TABLE IF NOT EXISTS COINS (
+    ID          INT          NOT NULL AUTO_INCREMENT,
+    SYMBOL      VARCHAR(32)  NOT NULL,
+    NAME        VARCHAR(128) NOT NULL DEFAULT '',
+    ICON        VARCHAR(255) NULL,     -- Путь к иконке относительно /assets (NULL - не загружалась, пусто - нет в источнике)
+    DATE_SYNC   DATETIME     NOT NULL,
+    PRIMARY KEY (ID),
+    UNIQUE KEY UX_COINS_SYMBOL (SYMBOL)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS COIN_NETWORKS (
+    ID               INT             NOT NULL AUTO_INCREMENT,
+    COIN_ID          INT             NOT NULL,
+    EXID             INT             NOT NULL,
+    NETWORK          VARCHAR(64)     NOT NULL, -- Код сети на бирже: ETH, TRX, BSC, ERC20...
+    NETWORK_NAME     VARCHAR(128)    NOT NULL DEFAULT '',
+    CONTRACT_ADDRESS VARCHAR(255)    NOT NULL DEFAULT '',
+    DEPOSIT_ENABLED  TINYINT(1)      NOT NULL DEFAULT 0,
+    WITHDRAW_ENABLED TINYINT(1)      NOT NULL DEFAULT 0,
+    WITHDRAW_FEE     DECIMAL(30, 12) NOT NULL DEFAULT 0,
+    WITHDRAW_MIN     DECIMAL(30, 12) NOT NULL DEFAULT 0,
+    CONFIRMATIONS    INT             NOT NULL DEFAULT 0,
+    DATE_SYNC        DATETIME        NOT NULL,
+    PRIMARY KEY (ID),
+    UNIQUE KEY UX_COIN_NETWORKS (COIN_ID, EXID, NETWORK),
+    KEY IX_COIN_NETWORKS_EXID (EXID),
+    CONSTRAINT FK_COIN_NETWORKS_COIN FOREIGN KEY (COIN_ID)
+        REFERENCES COINS (ID) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,21 @@
+-- Наблюдения за межбиржевым спредом (страница /spread_monitor/).
+-- Задача jobs.spread_sample_interval снимает bid/ask обеих бирж по активным наблюдениям
+-- и пишет спред в таблицу SPREADS БД котировок (migrations/quotes/002_spreads.*.sql).
+CREATE TABLE IF NOT EXISTS SPREAD_WATCHES (
+    ID               INT          NOT NULL AUTO_INCREMENT,
+    UID              INT          NOT NULL,
+    EXID1            INT          NOT NULL,
+    EXID2            INT          NOT NULL,
+    MARKET_TYPE      VARCHAR(16)  NOT NULL DEFAULT 'SPOT',
+    BASE_ASSET       VARCHAR(32)  NOT NULL,
+    QUOTE_ASSET      VARCHAR(32)  NOT NULL,
+    SYMBOL1          VARCHAR(64)  NOT NULL, -- Символ инструмента на первой бирже
+    SYMBOL2          VARCHAR(64)  NOT NULL, -- Символ инструмента на второй бирже
+    IS_ACTIVE        TINYINT(1)   NOT NULL DEFAULT 1,
+    DATE_CREATE      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    DATE_LAST_SAMPLE DATETIME     NULL,
+    LAST_ERROR       VARCHAR(255) NOT NULL DEFAULT '', -- Ошибка последнего замера ('' - успешно)
+    PRIMARY KEY (ID),
+    UNIQUE KEY UX_SPREAD_WATCHES (UID, EXID1, EXID2, MARKET_TYPE, BASE_ASSET, QUOTE_ASSET),
+    KEY IX_SPREAD_WATCHES_ACTIVE (IS_ACTIVE)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,19 @@
+-- Группы позиций (хеджированные связки): несколько позиций POS_POSITIONS - ноги одной сделки,
+-- например спот-лонг на одной бирже и шорт perpetual на другой. Группы из двух ног создаются
+-- со страницы /funding_arbitrage/ по выбранной связке.
+CREATE TABLE IF NOT EXISTS POS_GROUPS (
+    ID         INT             NOT NULL AUTO_INCREMENT,
+    USER_ID    INT             NOT NULL,
+    NAME       VARCHAR(128)    NOT NULL,
+    STRATEGY   VARCHAR(32)     NOT NULL DEFAULT '', -- SPOT+FUTURES, FUTURES+FUTURES ('' - группа собрана вручную)
+    ENTRY_DIFF DECIMAL(20, 12) NULL,                -- Разница ставок финансирования за 8 часов при открытии
+    STATUS     TINYINT(1)      NOT NULL DEFAULT 1,
+    CREATED    DATETIME        NOT NULL,
+    CLOSED     DATETIME        NULL,
+    PRIMARY KEY (ID),
+    KEY IX_POS_GROUPS_USER (USER_ID)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE POS_POSITIONS
+    ADD COLUMN GROUP_ID INT NULL AFTER ACCOUNT_ID,
+    ADD KEY IX_POS_POSITIONS_GROUP (GROUP_ID);
@@ -0,0 +1,41 @@
+-- Правила уведомлений (страница /alerts/).
+-- Задача jobs.alert_evaluate_interval проверяет активные правила по кэшу котировок и PnL позиций
+-- и отправляет уведомление при переходе условия из "не выполнено" в "выполнено".
+CREATE TABLE IF NOT EXISTS ALERT_RULES (
+    ID             INT            NOT NULL AUTO_INCREMENT,
+    UID            INT            NOT NULL,
+    RULE_TYPE      VARCHAR(16)    NOT NULL,             -- loss, price, funding_flip
+    POSITION_ID    INT            NULL,                 -- Правило по позиции (NULL - по инструменту)
+    EXID           INT            NOT NULL,
+    MARKET_TYPE    VARCHAR(16)    NOT NULL DEFAULT 'SPOT',
+    SYMBOL         VARCHAR(64)    NOT NULL,
+    DIRECTION      VARCHAR(8)     NOT NULL DEFAULT '',  -- above, below (price)
+    THRESHOLD      DECIMAL(30,12) NOT NULL DEFAULT 0,   -- Уровень цены (price) или допустимый убыток (loss)
+    CHANNEL        VARCHAR(16)    NOT NULL,             -- email, webhook
+    TARGET         VARCHAR(255)   NOT NULL DEFAULT '',  -- Адрес получателя канала
+    IS_ACTIVE      TINYINT(1)     NOT NULL DEFAULT 1,
+    IS_TRIGGERED   TINYINT(1)     NOT NULL DEFAULT 0,   -- Условие выполнено при последней проверке
+    LAST_VALUE     DOUBLE         NULL,                 -- Значение последней проверки (цена, PnL, ставка)
+    DATE_CREATE    DATETIME       NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    DATE_CHECKED   DATETIME       NULL,
+    DATE_TRIGGERED DATETIME       NULL,
+    LAST_ERROR     VARCHAR(255)   NOT NULL DEFAULT '',  -- Ошибка последней проверки или доставки ('' - успешно)
+    PRIMARY KEY (ID),
+    KEY IX_ALERT_RULES_USER (UID),
+    KEY IX_ALERT_RULES_ACTIVE (IS_ACTIVE)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- История срабатываний с результатом доставки.
+CREATE TABLE IF NOT EXISTS ALERT_EVENTS (
+    ID          INT          NOT NULL AUTO_INCREMENT,
+    RULE_ID     INT          NOT NULL,
+    UID         INT          NOT NULL,
+    DATE_CREATE DATETIME     NOT NULL,
+    SUBJECT     VARCHAR(255) NOT NULL,
+    MESSAGE     TEXT         NOT NULL,
+    DELIVERED   TINYINT(1)   NOT NULL DEFAULT 0,
+    ERROR       VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (ID),
+    KEY IX_ALERT_EVENTS_USER (UID, DATE_CREATE),
+    KEY IX_ALERT_EVENTS_RULE (RULE_ID)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,20 @@
+-- Привязка Telegram-чата к пользователю: канал telegram правил /alerts/ (ALERT_RULES.CHANNEL = 'telegram',
+-- TARGET пустой - чат берётся из привязки) и команды бота. Один пользователь - один чат, один чат - один пользователь.
+CREATE TABLE IF NOT EXISTS TELEGRAM_LINKS (
+    UID       INT          NOT NULL,
+    CHAT_ID   BIGINT       NOT NULL,
+    USERNAME  VARCHAR(64)  NOT NULL DEFAULT '',  -- @username отправителя на момент привязки
+    DATE_LINK DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (UID),
+    UNIQUE KEY UX_TELEGRAM_LINKS_CHAT (CHAT_ID)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Одноразовые коды привязки со страницы профиля. Хранится только SHA-256 кода;
+-- код удаляется при использовании и при выдаче нового.
+CREATE TABLE IF NOT EXISTS TELEGRAM_LINK_CODES (
+    CODE_HASH   CHAR(64)  NOT NULL,
+    UID         INT       NOT NULL,
+    DATE_EXPIRE DATETIME  NOT NULL,
+    PRIMARY KEY (CODE_HASH),
+    UNIQUE KEY UX_TELEGRAM_LINK_CODES_USER (UID)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
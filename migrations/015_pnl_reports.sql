@@ -0,0 +1,30 @@
+-- Подписки на PnL-отчёты (страница /reports/). Задача jobs.report_interval формирует
+-- отчёт за завершившийся день/месяц по часовому поясу пользователя и отправляет его на EMAIL.
+CREATE TABLE IF NOT EXISTS REPORT_SUBSCRIPTIONS (
+    UID         INT           NOT NULL,
+    DAILY       TINYINT(1)    NOT NULL DEFAULT 0,
+    MONTHLY     TINYINT(1)    NOT NULL DEFAULT 0,
+    EMAIL       VARCHAR(255)  NOT NULL DEFAULT '',
+    DATE_UPDATE DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (UID)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Сформированные отчёты: HTML и CSV хранятся целиком и скачиваются из истории.
+-- Повторная генерация за тот же период заменяет отчёт.
+CREATE TABLE IF NOT EXISTS PNL_REPORTS (
+    ID           INT           NOT NULL AUTO_INCREMENT,
+    UID          INT           NOT NULL,
+    PERIOD       VARCHAR(8)    NOT NULL,             -- daily, monthly
+    PERIOD_START DATE          NOT NULL,             -- Первый день периода (часовой пояс TIMEZONE)
+    PERIOD_END   DATE          NOT NULL,             -- Последний день периода
+    TIMEZONE     VARCHAR(64)   NOT NULL DEFAULT 'UTC',
+    REALIZED_PNL DOUBLE        NOT NULL DEFAULT 0,   -- Итог для списка отчётов
+    HTML         MEDIUMTEXT    NOT NULL,
+    CSV          MEDIUMTEXT    NOT NULL,
+    DATE_CREATE  DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    EMAIL        VARCHAR(255)  NOT NULL DEFAULT '',  -- Адрес последней отправки
+    DATE_EMAILED DATETIME      NULL,
+    EMAIL_ERROR  VARCHAR(255)  NOT NULL DEFAULT '',
+    PRIMARY KEY (ID),
+    UNIQUE KEY UX_PNL_REPORTS_PERIOD (UID, PERIOD, PERIOD_START)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,11 @@
+-- Дневные курсы активов к фиатным валютам для налоговой выгрузки (/tax/).
+-- RATE - стоимость 1 ASSET в CURRENCY на дату RATE_DATE (UTC). Курсы загружает
+-- администратор CSV-файлом; для даты без курса берётся последний предыдущий.
+CREATE TABLE IF NOT EXISTS FIAT_RATES (
+    ASSET       VARCHAR(32)     NOT NULL,  -- Котируемый актив позиций: USDT, USDC, BTC...
+    CURRENCY    VARCHAR(8)      NOT NULL,  -- Фиатная валюта: USD, EUR...
+    RATE_DATE   DATE            NOT NULL,
+    RATE        DECIMAL(30, 12) NOT NULL,
+    DATE_UPDATE DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (ASSET, CURRENCY, RATE_DATE)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
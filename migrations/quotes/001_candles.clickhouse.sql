@@ -0,0 +1,17 @@
+-- Свечи OHLCV в БД котировок (databases.quotes, engine = clickhouse).
+-- ReplacingMergeTree схлопывает повторно загруженные свечи по DATE_SYNC, запросы читают с FINAL.
+CREATE TABLE IF NOT EXISTS CANDLES (
+    EXID          Int32,
+    MARKET_TYPE   LowCardinality(String),
+    SYMBOL        String,
+    INTERVAL_CODE LowCardinality(String),
+    OPEN_TIME     DateTime('UTC'),
+    OPEN_PRICE    Float64,
+    HIGH_PRICE    Float64,
+    LOW_PRICE     Float64,
+    CLOSE_PRICE   Float64,
+    VOLUME        Float64,
+    DATE_SYNC     DateTime('UTC') DEFAULT now()
+) ENGINE = ReplacingMergeTree(DATE_SYNC)
+PARTITION BY toYYYYMM(OPEN_TIME)
+ORDER BY (EXID, MARKET_TYPE, SYMBOL, INTERVAL_CODE, OPEN_TIME);
@@ -0,0 +1,16 @@
+-- Свечи OHLCV в БД котировок (databases.quotes, engine = mysql).
+-- INTERVAL_CODE: 1m, 5m, 15m, 1h, 4h, 1d. OPEN_TIME - начало периода в UTC.
+CREATE TABLE IF NOT EXISTS CANDLES (
+    EXID          INT             NOT NULL,
+    MARKET_TYPE   VARCHAR(16)     NOT NULL,
+    SYMBOL        VARCHAR(64)     NOT NULL,
+    INTERVAL_CODE VARCHAR(8)      NOT NULL,
+    OPEN_TIME     DATETIME        NOT NULL,
+    OPEN_PRICE    DECIMAL(30, 12) NOT NULL,
+    HIGH_PRICE    DECIMAL(30, 12) NOT NULL,
+    LOW_PRICE     DECIMAL(30, 12) NOT NULL,
+    CLOSE_PRICE   DECIMAL(30, 12) NOT NULL,
+    VOLUME        DECIMAL(38, 12) NOT NULL DEFAULT 0,
+    DATE_SYNC     DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (EXID, MARKET_TYPE, SYMBOL, INTERVAL_CODE, OPEN_TIME)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,17 @@
+-- Замеры межбиржевого спреда по наблюдениям SPREAD_WATCHES (databases.quotes, engine = clickhouse).
+-- GROSS, FEES, NET - в долях (0.001 = 0.1%), BUY_ON - биржа покупки (1 или 2).
+CREATE TABLE IF NOT EXISTS SPREADS (
+    WATCH_ID    Int32,
+    SAMPLE_TIME DateTime('UTC'),
+    BID1        Float64,
+    ASK1        Float64,
+    BID2        Float64,
+    ASK2        Float64,
+    BUY_ON      UInt8,
+    GROSS       Float64,
+    FEES        Float64,
+    NET         Float64
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(SAMPLE_TIME)
+ORDER BY (WATCH_ID, SAMPLE_TIME)
+TTL SAMPLE_TIME + INTERVAL 90 DAY;
@@ -0,0 +1,17 @@
+-- Замеры межбиржевого спреда по наблюдениям SPREAD_WATCHES (databases.quotes, engine = mysql).
+-- GROSS, FEES, NET - в долях (0.001 = 0.1%), BUY_ON - биржа покупки (1 или 2).
+-- Замеры старше 90 дней удаляет задача сбора спредов.
+CREATE TABLE IF NOT EXISTS SPREADS (
+    WATCH_ID    INT             NOT NULL,
+    SAMPLE_TIME DATETIME        NOT NULL,
+    BID1        DECIMAL(30, 12) NOT NULL,
+    ASK1        DECIMAL(30, 12) NOT NULL,
+    BID2        DECIMAL(30, 12) NOT NULL,
+    ASK2        DECIMAL(30, 12) NOT NULL,
+    BUY_ON      TINYINT         NOT NULL,
+    GROSS       DOUBLE          NOT NULL,
+    FEES        DOUBLE          NOT NULL,
+    NET         DOUBLE          NOT NULL,
+    PRIMARY KEY (WATCH_ID, SAMPLE_TIME),
+    KEY IX_SPREADS_TIME (SAMPLE_TIME)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,182 @@
+$(document).ready(function() {
+    var typeTitles = {
+        price: 'Price',
+        loss: 'Loss',
+        funding_flip: 'Funding flip'
+    };
+
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function notifySuccess(text) {
+        new PNotify({
+                text: text,
+                type: 'success',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
+    function post(url, params, onSuccess) {
+        $.post(url, params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            onSuccess(ret);
+        }, 'json').fail(requestError);
+    }
+
+    function ruleText(r) {
+        switch(r.type) {
+            case 'price':
+                return 'Price ' + ctEscapeHtml(r.direction) + ' ' + r.threshold;
+            case 'loss':
+                return 'Loss &ge; ' + r.threshold;
+            case 'funding_flip':
+                return 'Funding sign flip';
+        }
+        return ctEscapeHtml(r.type);
+    }
+
+    function lastValueText(r) {
+        if(r.last_value == null) {
+            return '';
+        }
+        if(r.type == 'funding_flip') {
+            return (r.last_value * 100).toFixed(4) + '%';
+        }
+        return r.type == 'loss' ? r.last_value.toFixed(2) : String(r.last_value);
+    }
+
+    function loadRules() {
+        post('/alerts/ajax_get_rules.php', {}, function(ret) {
+            var tbody = $('#table-alert-rules tbody').empty();
+            if(!ret.data.length) {
+                tbody.append('<tr><td colspan="9" class="text-muted">No alert rules yet</td></tr>');
+            }
+            ret.data.forEach(function(r) {
+                var instrument = ctEscapeHtml(r.symbol) + ' <small class="text-muted">' + ctEscapeHtml(r.market) + ' ' + ctEscapeHtml(r.exchange) + '</small>';
+                if(r.position_id) {
+                    instrument = '<a href="/positions_calc/position/?position=' + parseInt(r.position_id) + '">#' + r.position_id + '</a> ' + instrument;
+                }
+                var status = r.is_active ? '<span class="text-success">active</span>' : '<span class="text-muted">paused</span>';
+                if(r.is_active && r.is_triggered) {
+                    status = '<span class="text-warning">triggered</span>';
+                }
+                if(r.last_error) {
+                    status += ' <i class="fa fa-exclamation-triangle text-danger" title="' + ctEscapeHtml(r.last_error) + '"></i>';
+                }
+                tbody.append('<tr data-id="' + r.id + '">' +
+                    '<td>' + r.id + '</td>' +
+                    '<td>' + ruleText(r) + '</td>' +
+                    '<td>' + instrument + '</td>' +
+                    '<td>' + ctEscapeHtml(r.channel) + ' <small class="text-muted">' + ctEscapeHtml(r.target) + '</small></td>' +
+                    '<td>' + ctEscapeHtml(lastValueText(r)) + '</td>' +
+                    '<td>' + ctEscapeHtml(r.date_checked) + '</td>' +
+                    '<td>' + ctEscapeHtml(r.date_triggered) + '</td>' +
+                    '<td>' + status + '</td>' +
+                    '<td class="text-nowrap">' +
+                        '<a href="#" class="alert-test" title="Send test notification"><i class="fa fa-paper-plane-o"></i></a> ' +
+                        '<a href="#" class="alert-toggle" data-active="' + (r.is_active ? 0 : 1) + '" title="' + (r.is_active ? 'Pause' : 'Resume') + '"><i class="fa ' + (r.is_active ? 'fa-pause' : 'fa-play') + '"></i></a> ' +
+                        '<a href="#" class="alert-delete" title="Delete"><i class="fa fa-trash-o"></i></a>' +
+                    '</td>' +
+                '</tr>');
+            });
+
+            var events = $('#table-alert-events tbody').empty();
+            if(!ret.events.length) {
+                events.append('<tr><td colspan="4" class="text-muted">No notifications yet</td></tr>');
+            }
+            ret.events.forEach(function(e) {
+                var delivery = e.delivered ? '<span class="text-success">sent</span>' :
+                    '<span class="text-danger" title="' + ctEscapeHtml(e.error) + '">failed</span>';
+                events.append('<tr>' +
+                    '<td class="text-nowrap">' + ctEscapeHtml(e.date) + '</td>' +
+                    '<td>' + e.rule_id + '</td>' +
+                    '<td><strong>' + ctEscapeHtml(e.subject) + '</strong><br><small>' + ctEscapeHtml(e.message).replace(/\n/g, '<br>') + '</small></td>' +
+                    '<td>' + delivery + '</td>' +
+                '</tr>');
+            });
+        });
+    }
+
+    // Поля формы зависят от типа правила: уровень и направление - для цены,
+    // допустимый убыток - только по позиции, символ - если позиция не выбрана;
+    // адрес получателя - от канала.
+    function updateForm() {
+        var type = $('#alert_type').val();
+        var byPosition = $('#alert_position').val() !== '';
+        $('#alert_position option[value=""]').prop('disabled', type == 'loss');
+        if(type == 'loss' && !byPosition) {
+            var first = $('#alert_position option[value!=""]').first().val();
+            $('#alert_position').val(first || '');
+            byPosition = !!first;
+        }
+        $('#alert_symbol_fields').toggle(!byPosition);
+        $('#alert_direction').toggle(type == 'price');
+        $('#alert_threshold').toggle(type != 'funding_flip')
+            .attr('placeholder', type == 'loss' ? 'Max loss' : 'Price');
+        if(type == 'funding_flip' && !byPosition) {
+            $('#alert_market').val('FUTURES');
+        }
+        // Для Telegram адрес не нужен: уведомление уходит в чат, привязанный в профиле
+        var telegram = $('#alert_channel').val() == 'telegram';
+        $('#alert_target').toggle(!telegram);
+        $('#alert_telegram_hint').toggle(telegram);
+    }
+
+    ctBindInstrumentAutocomplete('#alert_symbol', '#alert_symbol_list',
+        function() { return $('#alert_exchange').val(); },
+        function() { return $('#alert_market').val(); });
+
+    $('#alert_type, #alert_position, #alert_channel').on('change', updateForm);
+    updateForm();
+
+    $('#form_create_rule').on('submit', function(e) {
+        e.preventDefault();
+        var form = this;
+        post('/alerts/ajax_create_rule.php', $(form).serialize(), function(ret) {
+            $(form).find('[name=threshold], [name=symbol]').val('');
+            notifySuccess('Alert #' + ret.id + ' added');
+            loadRules();
+        });
+    });
+
+    $('#table-alert-rules').on('click', 'a.alert-toggle', function(e) {
+        e.preventDefault();
+        post('/alerts/ajax_edit_rule.php', {id: $(this).closest('tr').data('id'), active: $(this).data('active')}, loadRules);
+    });
+
+    $('#table-alert-rules').on('click', 'a.alert-delete', function(e) {
+        e.preventDefault();
+        if(!confirm('Delete alert rule?')) {
+            return;
+        }
+        post('/alerts/ajax_delete_rule.php', {id: $(this).closest('tr').data('id')}, loadRules);
+    });
+
+    $('#table-alert-rules').on('click', 'a.alert-test', function(e) {
+        e.preventDefault();
+        post('/alerts/ajax_test_rule.php', {id: $(this).closest('tr').data('id')}, function() {
+            notifySuccess('Test notification sent');
+        });
+    });
+
+    loadRules();
+    setInterval(loadRules, 60000);
+});
@@ -1,9 +1,28 @@
 $(document).ready(function() {
+    var table;
+
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
     $('#update_coins_button').on('click', function(e) {
         $('#result_update_coins').html('<img src="/assets/images/loading2.gif" style="padding-right:10px">');
         $.ajax({
             url: "/coins/ajax_update_coins.php",
-            type: "POST", 
+            type: "POST",
             dataType: "html",
             data: {'action':"update"},
             success: function(response) { //Данные отправлены успешно
@@ -11,13 +30,7 @@ $(document).ready(function() {
                 $('#result_update_coins').html('');
                 //console.log(ret);
                 if(ret.error !== false && ret.error !== '') {
-                    new PNotify({
-                            title: 'Error',
-                            text: ret.error,
-                            type: 'error',
-                            addclass: 'stack-bar-top',
-                            width: "100%"
-                    });
+                    notifyError(ret.error);
                 }
                 else if(ret.success === true) {
                      new PNotify({
@@ -28,23 +41,158 @@ $(document).ready(function() {
                             width: "100%"
                     });
                     $('#result_update_coins').html(ret.data);
+                    if(table) {
+                        table.ajax.reload(null, false);
+                    }
                 }
             },
             error: function (data, textStatus) {
-                if(data.status == 401) {
-                    setTimeout(function(){ location.reload(); }, 1000);
-                }
-                new PNotify({
-                            title: 'Error',
-                            text: "Error " + data.status + " " + data.statusText,
-                            type: 'error',
-                            addclass: 'stack-bar-top',
-                            width: "100%"
-                    });
-            }  
+                $('#result_update_coins').html('');
+                requestError(data, textStatus);
+            }
         });
     });
-     $('#gen_icon_list_coins_button').on('click', function(e) {
-        alert(2);
+
+    // Загрузка недостающих иконок в локальный набор /assets/images/coins/
+    $('#gen_icon_list_coins_button').on('click', function(e) {
+        $('#result_update_coins').html('<img src="/assets/images/loading2.gif" style="padding-right:10px">');
+        $.post('/coins/ajax_gen_icons.php', {}, function(ret) {
+            $('#result_update_coins').html('');
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            new PNotify({
+                    text: 'Icons loaded: ' + ret.loaded + ', not found: ' + ret.missing,
+                    type: 'success',
+                    addclass: 'stack-bar-top',
+                    width: "100%"
+            });
+            if(table) {
+                table.ajax.reload(null, false);
+            }
+        }, 'json').fail(function(data, textStatus) {
+            $('#result_update_coins').html('');
+            requestError(data, textStatus);
+        });
     });
-});
\ No newline at end of file
+
+    function showNetworks(symbol) {
+        $.post('/coins/ajax_coin_info.php', {'symbols': symbol}, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            var tbody = $('#table-coin-networks tbody').empty();
+            var coin = ret.data && ret.data.length ? ret.data[0] : null;
+            $('#coin_networks_symbol').text(symbol);
+            if(!coin || !coin.networks.length) {
+                tbody.append('<tr><td colspan="8" class="text-muted">No networks</td></tr>');
+            }
+            else {
+                coin.networks.forEach(function(n) {
+                    tbody.append('<tr>' +
+                        '<td>' + ctEscapeHtml(n.exchange_name) + '</td>' +
+                        '<td>' + ctEscapeHtml(n.network) + (n.network_name && n.network_name !== n.network ? ' <small class="text-muted">' + ctEscapeHtml(n.network_name) + '</small>' : '') + '</td>' +
+                        '<td><small>' + ctEscapeHtml(n.contract_address) + '</small></td>' +
+                        '<td>' + (n.deposit_enabled ? '<span class="text-success">enabled</span>' : '<span class="text-danger">suspended</span>') + '</td>' +
+                        '<td>' + (n.withdraw_enabled ? '<span class="text-success">enabled</span>' : '<span class="text-danger">suspended</span>') + '</td>' +
+                        '<td>' + n.withdraw_fee + '</td>' +
+                        '<td>' + n.withdraw_min + '</td>' +
+                        '<td>' + n.confirmations + '</td>' +
+                    '</tr>');
+                });
+            }
+            $('#coin_networks_panel').show();
+        }, 'json').fail(requestError);
+    }
+
+    var dtcoins = document.getElementById('dt-coins');
+    if(dtcoins) {
+        //Insert into header table input field for search
+        var nm = Array(
+                "",
+                "coin_id",
+                "coin_symbol",
+                "coin_name",
+                "coin_exchanges",
+                "coin_networks"
+             );
+        $('#dt-coins thead tr th').each(function (i) {
+            var title = $(this).text();
+            if (i > 0 && i < nm.length) {
+                $(this).html(title+' <input type="text" name="'+nm[i]+'@'+i+'" class="form-control input-sm mb-md input-search" placeholder="" style="padding:1px" onclick="event.stopPropagation();" onkeypress="event.stopPropagation();keysearchCoin(event)" />');
+            }
+        });
+
+        table = $('#dt-coins').DataTable({
+            processing: true,
+            serverSide: true,
+            pageLength: 50,
+            lengthMenu: [50, 100, 200],
+            pagingExtraNumberForNext: true,
+            bScrollCollapse: true,
+            ajax: {
+                url: '/coins/ajax_get_coins.php',
+                type: 'POST'
+            },
+            columns: [
+                { data: 'icon', render: function(data) { return data ? '<img src="' + ctEscapeHtml(data) + '" width="20" height="20" alt="">' : ''; } },
+                { data: 'id' },
+                { data: 'symbol', render: function(data) { return '<a href="#" class="coin-networks" data-symbol="' + ctEscapeHtml(data) + '">' + ctEscapeHtml(data) + '</a>'; } },
+                { data: 'name', render: ctEscapeHtml },
+                { data: 'exchanges' },
+                { data: 'networks' },
+                { data: 'deposit_networks', render: function(data, type, row) { return data + ' / ' + row.networks; } },
+                { data: 'withdraw_networks', render: function(data, type, row) { return data + ' / ' + row.networks; } },
+                { data: 'date_sync' }
+            ],
+            columnDefs: [
+                {
+                    targets: "_all",
+                    className: 'dt-body-left',
+                    searchable: true
+                },
+                {
+                    searchable: false,
+                    orderable: false,
+                    targets: [0]
+                }
+            ],
+            createdRow: function(row, data) {
+                if (data.networks > 0 && (data.deposit_networks === 0 || data.withdraw_networks === 0)) {
+                    $(row).addClass('warning');
+                }
+            },
+            order: [4, 'desc'],
+            language: {
+                processing: "Processing...",
+                lengthMenu: "_MENU_ coins per page",
+                zeroRecords: "Data not found",
+                info: "Filtered from _START_ to _END_ of _TOTAL_",
+                infoEmpty: "Data not found",
+                infoFiltered: "(Total coins _MAX_)"
+            }
+        });
+
+        var search = document.getElementById('dt-coins_filter');
+        if (search) {
+            search.style.display = 'none';
+        }
+
+        $('#dt-coins').on('click', 'a.coin-networks', function(e) {
+            e.preventDefault();
+            showNetworks($(this).data('symbol'));
+        });
+    }
+});
+
+function keysearchCoin(event) {
+    if (event.keyCode === 13) {
+        var table = $('#dt-coins').DataTable();
+        var input = event.target;
+        var col_index = input.name.match(/\d+$/)[0];
+
+        table.columns(col_index).search(input.value).draw();
+    }
+}
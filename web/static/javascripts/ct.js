@@ -293,4 +293,86 @@ window.addEventListener("DOMContentLoaded", function() {
 
   });
 
-});
\ No newline at end of file
+});
+/*
+* Автодополнение поля "Contract" по справочнику инструментов биржи.
+* getExchange/getMarket - функции, возвращающие текущие значения формы.
+*/
+function ctBindInstrumentAutocomplete(inputSelector, datalistSelector, getExchange, getMarket) {
+    var $input = $(inputSelector);
+    var $list = $(datalistSelector);
+    if (!$input.length || !$list.length) {
+        return;
+    }
+
+    var timer = null;
+    var lastQuery = '';
+
+    function load() {
+        var exchangeId = getExchange();
+        if (!exchangeId) {
+            $list.empty();
+            return;
+        }
+        var market = getMarket ? getMarket() : '';
+        var term = $.trim($input.val());
+        var query = exchangeId + '|' + market + '|' + term;
+        if (query === lastQuery) {
+            return;
+        }
+        lastQuery = query;
+
+        $.post('/positions_calc/ajax_instruments.php', {exchange_id: exchangeId, market: market, term: term, limit: 30}, function(ret) {
+            $list.empty();
+            if (!ret || !ret.success || !ret.items) {
+                return;
+            }
+            $.each(ret.items, function(i, item) {
+                var label = item.base + '/' + item.quote + ' ' + item.market;
+                if (item.status !== 'TRADING') {
+                    label += ' (' + item.status + ')';
+                }
+                $('<option>').attr('value', item.symbol).text(label).appendTo($list);
+            });
+        }, 'json');
+    }
+
+    $input.on('input focus', function() {
+        clearTimeout(timer);
+        timer = setTimeout(load, 250);
+    });
+}
+
+/*
+* Список аккаунтов позиции: показываются только аккаунты выбранной биржи
+* (option data-exid), выбранный аккаунт другой биржи сбрасывается.
+*/
+function ctBindAccountSelect(accountSelector, exchangeSelector) {
+    var $account = $(accountSelector);
+    var $exchange = $(exchangeSelector);
+    if (!$account.length || !$exchange.length) {
+        return function() {};
+    }
+
+    function refresh() {
+        var exchangeId = String($exchange.val() || '');
+        $account.find('option[data-exid]').each(function() {
+            var visible = exchangeId !== '' && String($(this).data('exid')) === exchangeId;
+            $(this).prop('hidden', !visible).prop('disabled', !visible);
+        });
+        if ($account.find('option:selected').prop('disabled')) {
+            $account.val('');
+        }
+    }
+
+    $exchange.on('change', refresh);
+    refresh();
+    return refresh;
+}
+
+/*
+* Экранирует текст для вставки в HTML (null/undefined - пустая строка).
+*/
+function ctEscapeHtml(value) {
+    return $('<div>').text(value == null ? '' : value).html();
+}
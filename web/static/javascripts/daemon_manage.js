@@ -6,6 +6,7 @@ $(document).ready(function() {
         $.ajax({
             url: "/daemon/ajax_check_status.php",
             type: "POST", 
+            dataType: "html",
             success: function(response) { //Данные отправлены успешно
                 var ret = JSON.parse(response);
                 if(ret.error !== false && ret.error !== '') {
@@ -27,6 +28,8 @@ $(document).ready(function() {
                     });
                 }
                 $('#daemon_status').html(ret.status);
+                renderDaemonInfo(ret.info);
+                getDaemonStatus();
                 $('.darkness').css('display','none');
                 $('.layer').css('display','none');
             },
@@ -54,6 +57,7 @@ $(document).ready(function() {
         $.ajax({
             url: "/daemon/ajax_start.php",
             type: "POST", 
+            dataType: "html",
             success: function(response) { //Данные отправлены успешно
                 var ret = JSON.parse(response);
                 if(ret.error !== false && ret.error !== '') {
@@ -75,6 +79,8 @@ $(document).ready(function() {
                     });
                 }
                 $('#daemon_status').html(ret.status);
+                renderDaemonInfo(ret.info);
+                getDaemonStatus();
                 $('.darkness').css('display','none');
                 $('.layer').css('display','none');
             },
@@ -102,6 +108,7 @@ $(document).ready(function() {
         $.ajax({
             url: "/daemon/ajax_stop.php",
             type: "POST", 
+            dataType: "html",
             success: function(response) { //Данные отправлены успешно
                 var ret = JSON.parse(response);
                 if(ret.error !== false && ret.error !== '') {
@@ -123,6 +130,8 @@ $(document).ready(function() {
                     });
                 }
                 $('#daemon_status').html(ret.status);
+                renderDaemonInfo(ret.info);
+                getDaemonStatus();
                 $('.darkness').css('display','none');
                 $('.layer').css('display','none');
             },
@@ -147,14 +156,33 @@ $(document).ready(function() {
     if($('#daemon_check_button')) {
         $( "#daemon_check_button" ).click();   
     }
+
+    // Дерево процессов обновляется, пока демон запущен
+    if($('#output').length) {
+        setInterval(getDaemonStatus, 10000);
+    }
 });
 
+// PID, время работы, последний heartbeat и последние строки лога
+function renderDaemonInfo(info) {
+    if(!info) {
+        $('#daemon_pid, #daemon_started, #daemon_uptime, #daemon_heartbeat, #daemon_logs').text('');
+        return;
+    }
+    $('#daemon_pid').text(info.pid > 0 ? info.pid : '');
+    $('#daemon_started').text(info.started_at);
+    $('#daemon_uptime').text(info.uptime);
+    $('#daemon_heartbeat').text(info.last_heartbeat ? info.last_heartbeat + ' (' + info.heartbeat_age + ' ago)' : '');
+    $('#daemon_logs').text(info.logs.join('\n'));
+}
+
 function getDaemonStatus () {
     var run = $('#daemon_status').text();
     if(run === 'ACTIVE') {
         $.ajax({
             url: "/daemon/ajax_daemon_stat.php",
             type: "POST", 
+            dataType: "html",
             success: function(response) { //Данные отправлены успешно
                 var ret = JSON.parse(response);
                 if(ret.error !== false && ret.error !== '') {
@@ -1,6 +1,9 @@
 // Client-side logic for Exchange Accounts page (DataTables + modals).
 (function() {
     let table;
+    // Фильтр мастер-аккаунтов по бирже в формах (см. ctBindAccountSelect)
+    let refreshCreateParents = function() {};
+    let refreshEditParents = function() {};
 
     function initTable() {
         // Добавляем input поля поиска в заголовки таблицы
@@ -12,12 +15,13 @@
             "ex_acc_priority",
             "ex_acc_status",
             "ex_acc_api_key",
-            "ex_acc_note"
+            "ex_acc_note",
+            "ex_acc_key_check"
         );
 
         $('#dt-exchange-accounts thead tr th').each(function (i) {
             var title = $(this).text();
-            if(i > 0) {
+            if(i > 0 && i < columnNames.length - 1) {
                 $(this).html(title + ' <input type="text" name="' + columnNames[i] + '@' + i + '" class="form-control input-sm mb-md input-search" placeholder="" style="padding:1px" onclick="event.stopPropagation();" onkeypress="event.stopPropagation();keysearchExchangeAccount(event)" />');
             }
         });
@@ -37,11 +41,12 @@
                 { data: null, render: function(){ return "<input type='checkbox' class='t-row chbx-ch' value=''/>"; }},
                 { data: 'id' },
                 { data: 'exchange_id' },
-                { data: 'account_name' },
+                { data: 'account_name', render: renderAccountName },
                 { data: 'priority' },
                 { data: 'status' },
                 { data: 'api_key' },
-                { data: 'note' }
+                { data: 'note' },
+                { data: 'key_check', render: renderKeyCheck }
             ],
             columnDefs: [
                 {
@@ -54,9 +59,15 @@
                     orderable: false, 
                     visible: true,
                     className: 'no-sort',
-                    targets: [0]
+                    targets: [0, 8]
                 }
             ],
+            createdRow: function(row, data) {
+                // Key allows withdrawals: policy violation (security.withdraw_key_policy)
+                if (data.key_violation) {
+                    $(row).addClass('danger');
+                }
+            },
             order: [1, 'asc'],
             language: {
                 processing: "Processing...",
@@ -74,6 +85,15 @@
             document.getElementById('dt-exchange-accounts_filter').style.display = 'none';
         }
 
+        $('#dt-exchange-accounts tbody').on('click', 'tr', function(e) {
+            if ($(e.target).is('input')) return;
+            const data = table.row(this).data();
+            if (!data) return;
+            $('#dt-exchange-accounts tbody tr').removeClass('info');
+            $(this).addClass('info');
+            selectAccountBalances(data);
+        });
+
         $('#dt-exchange-accounts tbody').on('dblclick', 'tr', function() {
             const data = table.row(this).data();
             if (!data) return;
@@ -81,6 +101,60 @@
         });
     }
 
+    // Субаккаунт выводится с отступом под мастер-аккаунтом, с именем субаккаунта на бирже.
+    function renderAccountName(name, type, row) {
+        const safe = $('<div>').text(name || '').html();
+        if (!row.parent_id) {
+            return safe;
+        }
+        let html = '<span class="text-muted" title="Sub-account of ' + $('<div>').text(row.parent_name || '').html() + '">&#8627;</span> ' + safe;
+        if (row.sub_label) {
+            html += ' <small class="text-muted">(' + $('<div>').text(row.sub_label).html() + ')</small>';
+        }
+        return html;
+    }
+
+    // Результат последней проверки ключа: статус, права и время проверки.
+    function renderKeyCheck(check) {
+        if (!check) {
+            return '<span class="text-muted">not checked</span>';
+        }
+        const labels = {
+            valid: 'label-success',
+            invalid: 'label-danger',
+            ip_blocked: 'label-warning',
+            error: 'label-default',
+            unsupported: 'label-default'
+        };
+        let html = '<span class="label ' + (labels[check.status] || 'label-default') + '" title="' +
+            $('<div>').text(check.message || '').html() + '">' + check.status + '</span>';
+        if (check.status === 'valid') {
+            const perms = [];
+            if (check.read) perms.push('read');
+            if (check.trade) perms.push('trade');
+            if (check.withdraw) perms.push('<b class="text-danger" title="Withdraw permission violates key policy"><i class="fa fa-exclamation-triangle"></i> withdraw</b>');
+            html += ' ' + perms.join(', ');
+            html += check.ip_restricted ? ' <i class="fa fa-lock" title="IP whitelist"></i>' : ' <i class="fa fa-unlock text-warning" title="No IP whitelist"></i>';
+        }
+        html += '<br><small class="text-muted">' + new Date(check.checked_at).toLocaleString() + '</small>';
+        return html;
+    }
+
+    function keyCheckSummary(check) {
+        if (check.status !== 'valid') {
+            return check.status + (check.message ? ': ' + check.message : '');
+        }
+        return 'Key is valid. Read: ' + (check.read ? 'yes' : 'no') +
+            ', trade: ' + (check.trade ? 'yes' : 'no') +
+            ', withdraw: ' + (check.withdraw ? 'yes' : 'no') +
+            ', IP whitelist: ' + (check.ip_restricted ? 'yes' : 'no');
+    }
+
+    function notifyRequestError(xhr) {
+        const error = xhr.responseJSON && xhr.responseJSON.error ? xhr.responseJSON.error : 'Request failed';
+        new PNotify({ title: 'Error', text: error, type: 'error', addclass: 'stack-bar-top', width: '100%' });
+    }
+
     function loadAccountForEdit(id) {
         $.post('/exchange_accounts/ajax_getid_accounts', { id: id }, function(resp) {
             if (resp.error) {
@@ -94,10 +168,21 @@
             $('[name=edit_exchange_account_account_name]').val(resp.account_name);
             $('[name=edit_exchange_account_priority]').val(resp.priority);
             $('[name=edit_exchange_account_status]').val(resp.status);
-            $('[name=edit_exchange_account_api_key]').val(resp.api_key);
-            $('[name=edit_exchange_account_secret_key]').val(resp.secret_key);
-            $('[name=edit_exchange_account_add_key]').val(resp.add_key);
+            // Ключи не приходят с сервера: пустое поле оставляет текущее значение
+            $('[name=edit_exchange_account_api_key]').val('').attr('placeholder', resp.api_key);
+            $('[name=edit_exchange_account_secret_key]').val('')
+                .attr('placeholder', resp.has_secret ? 'Saved, leave empty to keep' : '');
+            $('[name=edit_exchange_account_add_key]').val('')
+                .attr('placeholder', resp.has_add_key ? 'Saved, leave empty to keep' : '');
             $('[name=edit_exchange_account_note]').val(resp.note || '');
+            refreshEditParents();
+            $('#edit_exchange_account_parent_id option').each(function() {
+                if (String(this.value) === String(resp.id)) {
+                    $(this).prop('hidden', true).prop('disabled', true);
+                }
+            });
+            $('[name=edit_exchange_account_parent_id]').val(resp.parent_id || '');
+            $('[name=edit_exchange_account_sub_label]').val(resp.sub_label || '');
             $.magnificPopup.open({
                 type: 'inline',
                 items: {
@@ -121,9 +206,18 @@
                 }
                 new PNotify({ title: 'Success', text: 'Account created', type: 'success', addclass: 'stack-bar-top', width: '100%' });
                 $.magnificPopup.close();
+                if (!$('#create_exchange_account_parent_id').val()) {
+                    // Новый мастер-аккаунт сразу доступен для субаккаунтов
+                    const option = $('<option>').val(resp.id)
+                        .attr('data-exid', $('#create_exchange_account_exid').val())
+                        .text($('#create_exchange_account_account_name').val());
+                    $('#create_exchange_account_parent_id, #edit_exchange_account_parent_id').append(option.clone());
+                }
                 form[0].reset();
+                refreshCreateParents();
                 table.ajax.reload(null, false);
-            }, 'json');
+                loadRollup();
+            }, 'json').fail(notifyRequestError);
         });
     }
 
@@ -138,14 +232,204 @@
                 new PNotify({ title: 'Success', text: 'Account updated', type: 'success', addclass: 'stack-bar-top', width: '100%' });
                 $.magnificPopup.close();
                 table.ajax.reload(null, false);
-            }, 'json');
+                loadRollup();
+            }, 'json').fail(notifyRequestError);
+        });
+    }
+
+    function bindTestConnection() {
+        $('#btn-test-exaccount').on('click', function() {
+            const btn = $(this);
+            const id = $('[name=edit_exchange_account_id]').val();
+            btn.prop('disabled', true);
+            $.post('/exchange_accounts/ajax_test_connection', { id: id }, function(resp) {
+                const check = resp.check;
+                const ok = check.status === 'valid';
+                if (ok && check.withdraw) {
+                    new PNotify({
+                        title: 'Key policy violation',
+                        text: 'The key allows withdrawals. Create a key without withdraw permission.',
+                        type: 'error',
+                        addclass: 'stack-bar-top',
+                        width: '100%'
+                    });
+                }
+                new PNotify({
+                    title: ok ? 'Connection OK' : 'Connection failed',
+                    text: keyCheckSummary(check),
+                    type: ok ? (check.withdraw ? 'notice' : 'success') : 'error',
+                    addclass: 'stack-bar-top',
+                    width: '100%'
+                });
+                table.ajax.reload(null, false);
+            }, 'json').fail(notifyRequestError).always(function() {
+                btn.prop('disabled', false);
+            });
+        });
+    }
+
+    // --- Balances ---------------------------------------------------------
+
+    let balancesAccountId = null;
+
+    function formatAmount(value) {
+        if (value === null || value === undefined) return '—';
+        const abs = Math.abs(value);
+        const digits = abs >= 1000 ? 2 : (abs >= 1 ? 4 : 8);
+        return Number(value).toLocaleString(undefined, { maximumFractionDigits: digits });
+    }
+
+    function selectAccountBalances(account) {
+        balancesAccountId = account.id;
+        $('#balances-account').text('— ' + account.exchange_name + ' / ' + account.account_name);
+        $('#btn-snapshot-balances').prop('disabled', false);
+        loadBalances();
+    }
+
+    function loadBalances() {
+        if (!balancesAccountId) return;
+        $.post('/exchange_accounts/ajax_get_balances', { id: balancesAccountId, days: 30 }, function(resp) {
+            renderBalances(resp.latest);
+            renderBalanceHistory(document.getElementById('balances-history'), resp.history || []);
+        }, 'json').fail(notifyRequestError);
+    }
+
+    function renderBalances(snapshot) {
+        const tbody = $('#table-balances tbody').empty();
+        if (!snapshot) {
+            $('#balances-info').text('No snapshots yet');
+            return;
+        }
+        let info = 'Snapshot ' + new Date(snapshot.snapshot_at).toLocaleString() +
+            ', total $' + formatAmount(snapshot.usd_value);
+        if (snapshot.unpriced_assets > 0) {
+            info += ' (' + snapshot.unpriced_assets + ' assets without price)';
+        }
+        $('#balances-info').text(info);
+        (snapshot.items || []).forEach(function(item) {
+            $('<tr>')
+                .append($('<td>').text(item.wallet))
+                .append($('<td>').text(item.asset))
+                .append($('<td class="text-right">').text(formatAmount(item.free)))
+                .append($('<td class="text-right">').text(formatAmount(item.locked)))
+                .append($('<td class="text-right">').text(item.usd_value === null ? '—' : '$' + formatAmount(item.usd_value)))
+                .appendTo(tbody);
+        });
+        if (!snapshot.items || snapshot.items.length === 0) {
+            tbody.append($('<tr>').append($('<td colspan="5" class="text-muted">').text('Account is empty')));
+        }
+    }
+
+    // Account value history: plain SVG line, t - unix ms, v - USD value
+    function renderBalanceHistory(container, points) {
+        const ns = 'http://www.w3.org/2000/svg';
+        $(container).empty();
+        if (points.length === 0) {
+            $(container).append($('<p class="text-muted">').text('No value history'));
+            return;
+        }
+        const width = container.clientWidth || 500;
+        const height = container.clientHeight || 260;
+        const pad = { left: 10, right: 80, top: 10, bottom: 24 };
+        const plotW = width - pad.left - pad.right;
+        const plotH = height - pad.top - pad.bottom;
+
+        let lo = Infinity, hi = -Infinity;
+        points.forEach(function(p) { lo = Math.min(lo, p.v); hi = Math.max(hi, p.v); });
+        if (hi === lo) {
+            hi += hi * 0.01 || 1;
+            lo -= lo * 0.01 || 1;
+        }
+        let t0 = points[0].t, t1 = points[points.length - 1].t;
+        if (t1 === t0) {
+            t0 -= 3600000;
+            t1 += 3600000;
+        }
+        function x(t) { return pad.left + (t - t0) / (t1 - t0) * plotW; }
+        function y(v) { return pad.top + (hi - v) / (hi - lo) * plotH; }
+        function el(name, attrs, parent) {
+            const node = document.createElementNS(ns, name);
+            for (const key in attrs) {
+                node.setAttribute(key, attrs[key]);
+            }
+            parent.appendChild(node);
+            return node;
+        }
+
+        const svg = document.createElementNS(ns, 'svg');
+        svg.setAttribute('width', width);
+        svg.setAttribute('height', height);
+        for (let i = 0; i <= 4; i++) {
+            const v = lo + (hi - lo) * i / 4;
+            el('line', { x1: pad.left, x2: pad.left + plotW, y1: y(v), y2: y(v), stroke: '#eee' }, svg);
+            el('text', { x: pad.left + plotW + 6, y: y(v) + 4, 'font-size': 11, fill: '#777' }, svg).textContent = '$' + formatAmount(v);
+        }
+        [t0, t1].forEach(function(t, i) {
+            el('text', { x: x(t), y: height - 6, 'font-size': 11, fill: '#777', 'text-anchor': i === 0 ? 'start' : 'end' }, svg)
+                .textContent = new Date(t).toLocaleDateString();
+        });
+
+        const path = points.map(function(p, i) { return (i === 0 ? 'M' : 'L') + x(p.t).toFixed(1) + ',' + y(p.v).toFixed(1); }).join(' ');
+        el('path', { d: path, fill: 'none', stroke: '#0088cc', 'stroke-width': 2 }, svg);
+        points.forEach(function(p) {
+            const dot = el('circle', { cx: x(p.t), cy: y(p.v), r: 2.5, fill: p.unpriced > 0 ? '#f0ad4e' : '#0088cc' }, svg);
+            el('title', {}, dot).textContent = new Date(p.t).toLocaleString() + ': $' + formatAmount(p.v) +
+                (p.unpriced > 0 ? ' (' + p.unpriced + ' assets without price)' : '');
+        });
+        container.appendChild(svg);
+    }
+
+    // --- Master account rollup ----------------------------------------------
+
+    function loadRollup() {
+        $.post('/exchange_accounts/ajax_get_rollup', {}, function(resp) {
+            const tbody = $('#table-rollup tbody').empty();
+            (resp.items || []).forEach(function(item) {
+                let value = '$' + formatAmount(item.usd_value);
+                const notes = [];
+                if (item.missing_snapshots > 0) notes.push(item.missing_snapshots + ' without snapshot');
+                if (item.unpriced_assets > 0) notes.push(item.unpriced_assets + ' assets without price');
+                if (notes.length) {
+                    value += ' <i class="fa fa-exclamation-circle text-warning" title="' + notes.join(', ') + '"></i>';
+                }
+                $('<tr>')
+                    .append($('<td>').text(item.exchange_name))
+                    .append($('<td>').text(item.master_name))
+                    .append($('<td class="text-right">').text(item.sub_accounts))
+                    .append($('<td class="text-right">').html(value))
+                    .append($('<td class="text-right">').text(item.open_positions))
+                    .appendTo(tbody);
+            });
+            if (!resp.items || resp.items.length === 0) {
+                tbody.append('<tr><td colspan="5" class="text-center text-muted">No accounts</td></tr>');
+            }
+        }, 'json').fail(notifyRequestError);
+    }
+
+    function bindBalances() {
+        $('#btn-snapshot-balances').on('click', function() {
+            if (!balancesAccountId) return;
+            const btn = $(this);
+            btn.prop('disabled', true);
+            $.post('/exchange_accounts/ajax_snapshot_balances', { id: balancesAccountId }, function() {
+                new PNotify({ title: 'Success', text: 'Balances updated', type: 'success', addclass: 'stack-bar-top', width: '100%' });
+                loadBalances();
+                loadRollup();
+            }, 'json').fail(notifyRequestError).always(function() {
+                btn.prop('disabled', false);
+            });
         });
     }
 
     $(function() {
+        refreshCreateParents = ctBindAccountSelect('#create_exchange_account_parent_id', '#create_exchange_account_exid');
+        refreshEditParents = ctBindAccountSelect('#edit_exchange_account_parent_id', '#edit_exchange_account_exid');
         initTable();
+        loadRollup();
         bindCreate();
         bindEdit();
+        bindTestConnection();
+        bindBalances();
     });
 })();
 
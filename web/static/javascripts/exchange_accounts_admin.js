@@ -0,0 +1,170 @@
+// Client-side logic for the admin page with exchange accounts of all users.
+// Keys come from the server already masked (only the last 4 characters are visible).
+(function() {
+    let table;
+
+    function notify(title, text, type) {
+        new PNotify({ title: title, text: text, type: type, addclass: 'stack-bar-top', width: '100%' });
+    }
+
+    function notifyRequestError(xhr) {
+        const error = xhr.responseJSON && xhr.responseJSON.error ? xhr.responseJSON.error : 'Request failed';
+        notify('Error', error, 'error');
+    }
+
+    function renderKeyCheck(check) {
+        if (!check) {
+            return '<span class="text-muted">not checked</span>';
+        }
+        let html = ctEscapeHtml(check.status);
+        if (check.status === 'valid' && check.withdraw) {
+            html += ' <b class="text-danger" title="Withdraw permission violates key policy"><i class="fa fa-exclamation-triangle"></i> withdraw</b>';
+        }
+        return html;
+    }
+
+    function initTable() {
+        // Добавляем input поля поиска в заголовки таблицы (ключи не ищутся - они зашифрованы)
+        var columnNames = Array(
+            "",
+            "adm_acc_id",
+            "adm_acc_user_login",
+            "adm_acc_exchange_name",
+            "adm_acc_account_name",
+            "adm_acc_status"
+        );
+
+        $('#dt-exchange-accounts-admin thead tr th').each(function (i) {
+            var title = $(this).text();
+            if (i > 0 && i < columnNames.length) {
+                $(this).html(title + ' <input type="text" name="' + columnNames[i] + '@' + i + '" class="form-control input-sm mb-md input-search" placeholder="" style="padding:1px" onclick="event.stopPropagation();" onkeypress="event.stopPropagation();keysearchAdminAccount(event)" />');
+            }
+        });
+
+        table = $('#dt-exchange-accounts-admin').DataTable({
+            processing: true,
+            serverSide: true,
+            pageLength: 30,
+            lengthMenu: [15, 30, 50, 100],
+            pagingExtraNumberForNext: true,
+            bScrollCollapse: true,
+            ajax: {
+                url: '/exchange_accounts/ajax_get_all_accounts',
+                type: 'POST'
+            },
+            columns: [
+                { data: null, render: function(data, type, row){ return "<input type='checkbox' class='t-row chbx-ch' value='" + row.id + "'/>"; }},
+                { data: 'id' },
+                { data: 'user_login', render: function(data, type, row) { return ctEscapeHtml(data || ('#' + row.uid)); } },
+                { data: 'exchange_name', render: ctEscapeHtml },
+                { data: 'account_name', render: function(data, type, row) {
+                    if (!row.parent_id) {
+                        return ctEscapeHtml(data);
+                    }
+                    return '<span class="text-muted">&#8627;</span> ' + ctEscapeHtml(data) +
+                        ' <small class="text-muted">sub of ' + ctEscapeHtml(row.parent_name || ('#' + row.parent_id)) +
+                        (row.sub_label ? ', ' + ctEscapeHtml(row.sub_label) : '') + '</small>';
+                } },
+                { data: 'status' },
+                { data: 'api_key', render: function(data) { return '<code>' + ctEscapeHtml(data) + '</code>'; } },
+                { data: 'secret_key', render: function(data) { return '<code>' + ctEscapeHtml(data) + '</code>'; } },
+                { data: 'key_check', render: renderKeyCheck },
+                { data: 'date_create' }
+            ],
+            columnDefs: [
+                {
+                    targets: "_all",
+                    className: 'dt-body-left',
+                    searchable: true
+                },
+                {
+                    searchable: false,
+                    orderable: false,
+                    visible: true,
+                    className: 'no-sort',
+                    targets: [0, 6, 7]
+                }
+            ],
+            createdRow: function(row, data) {
+                if (data.status !== 'Active') {
+                    $(row).addClass('text-muted');
+                }
+                if (data.key_violation) {
+                    $(row).addClass('danger');
+                }
+            },
+            order: [2, 'asc'],
+            language: {
+                processing: "Processing...",
+                lengthMenu: "_MENU_ accounts per page",
+                zeroRecords: "Data not found",
+                info: "Filtered from _START_ to _END_ of _TOTAL_",
+                infoEmpty: "Data not found",
+                infoFiltered: "(Total accounts _MAX_)"
+            }
+        });
+
+        var search = document.getElementById('dt-exchange-accounts-admin_filter');
+        if (search) {
+            search.style.display = 'none';
+        }
+
+        $('#checkallAdminAccounts').on('click', function(e) {
+            e.stopPropagation();
+            $('#dt-exchange-accounts-admin tbody input.chbx-ch').prop('checked', this.checked);
+        });
+    }
+
+    function disableAccounts(params, confirmText) {
+        if (!confirm(confirmText)) {
+            return;
+        }
+        $.post('/exchange_accounts/ajax_disable_accounts', params, function(resp) {
+            if (resp.error) {
+                notify('Error', resp.error, 'error');
+                return;
+            }
+            notify('Success', 'Disabled accounts: ' + resp.disabled, 'success');
+            $('#checkallAdminAccounts').prop('checked', false);
+            table.ajax.reload(null, false);
+        }, 'json').fail(notifyRequestError);
+    }
+
+    function bindDisable() {
+        $('#btn-disable-selected').on('click', function() {
+            const ids = $('#dt-exchange-accounts-admin tbody input.chbx-ch:checked').map(function() {
+                return this.value;
+            }).get();
+            if (ids.length === 0) {
+                notify('Error', 'Select accounts in the table', 'error');
+                return;
+            }
+            disableAccounts({ ids: ids.join(',') }, 'Disable ' + ids.length + ' selected account(s)?');
+        });
+
+        $('#btn-disable-user').on('click', function() {
+            const userID = $('#disable_user_id').val();
+            if (!userID) {
+                notify('Error', 'Select a user', 'error');
+                return;
+            }
+            const label = $('#disable_user_id option:selected').text();
+            disableAccounts({ user_id: userID }, 'Disable all exchange accounts of ' + label + '?');
+        });
+    }
+
+    $(function() {
+        initTable();
+        bindDisable();
+    });
+})();
+
+function keysearchAdminAccount(event) {
+    if (event.keyCode === 13) {
+        var table = $('#dt-exchange-accounts-admin').DataTable();
+        var input = event.target;
+        var col_index = input.name.match(/\d+/)[0];
+
+        table.columns(col_index).search(input.value).draw();
+    }
+}
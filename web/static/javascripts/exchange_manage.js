@@ -97,6 +97,7 @@
             $('[name=edit_exchange_class]').val(resp.class);
             $('[name=edit_exchange_status]').val(resp.status);
             $('[name=edit_exchange_description]').val(resp.description || '');
+            loadExchangeFees(resp.id);
             $.magnificPopup.open({
                 type: 'inline',
                 items: {
@@ -110,6 +111,65 @@
         }, 'json');
     }
 
+    function loadExchangeFees(id) {
+        $('#form-edit-exchange-fees')[0].reset();
+        $('#form-account-fees')[0].reset();
+        $('#fee_exchange_id').val(id);
+        $.post('/exchange_manage/ajax_get_fees', { exchange_id: id }, function(resp) {
+            if (!resp.success) return;
+            $.each(resp.fees || {}, function(market, fee) {
+                const prefix = '#fee_' + market.toLowerCase();
+                $(prefix + '_maker').val(fee.maker_fee);
+                $(prefix + '_taker').val(fee.taker_fee);
+            });
+        }, 'json');
+    }
+
+    function saveExchangeFees(done) {
+        $.ajax({
+            url: '/exchange_manage/ajax_save_fees',
+            type: 'POST',
+            data: $('#form-edit-exchange-fees').serialize(),
+            dataType: 'json'
+        }).done(function() {
+            done();
+        }).fail(function(xhr) {
+            const resp = xhr.responseJSON || {};
+            new PNotify({ title: 'Error', text: resp.error || ('Error ' + xhr.status), type: 'error', addclass: 'stack-bar-top', width: '100%' });
+        });
+    }
+
+    function bindAccountFees() {
+        $('#account_fee_account_id, #account_fee_market').on('change', function() {
+            const accountId = $('#account_fee_account_id').val();
+            if (!accountId) return;
+            $.post('/exchange_manage/ajax_get_account_fees', { account_id: accountId }, function(resp) {
+                const market = $('#account_fee_market').val().toUpperCase();
+                let current = null;
+                $.each(resp.fees || [], function(i, fee) {
+                    if (fee.market === market) current = fee;
+                });
+                $('#account_fee_vip_tier').val(current ? current.vip_tier : '');
+                $('#account_fee_maker').val(current ? current.maker_fee : '');
+                $('#account_fee_taker').val(current ? current.taker_fee : '');
+            }, 'json');
+        });
+
+        $('#btn-save-account-fees').on('click', function() {
+            $.ajax({
+                url: '/exchange_manage/ajax_save_account_fees',
+                type: 'POST',
+                data: $('#form-account-fees').serialize(),
+                dataType: 'json'
+            }).done(function() {
+                new PNotify({ title: 'Success', text: 'Account fees saved', type: 'success', addclass: 'stack-bar-top', width: '100%' });
+            }).fail(function(xhr) {
+                const resp = xhr.responseJSON || {};
+                new PNotify({ title: 'Error', text: resp.error || ('Error ' + xhr.status), type: 'error', addclass: 'stack-bar-top', width: '100%' });
+            });
+        });
+    }
+
     function bindCreate() {
         $('#btn-save-exchange').on('click', function() {
             const form = $('#form-create-exchange');
@@ -134,17 +194,88 @@
                     new PNotify({ title: 'Error', text: resp.error, type: 'error', addclass: 'stack-bar-top', width: '100%' });
                     return;
                 }
-                new PNotify({ title: 'Success', text: 'Exchange updated', type: 'success', addclass: 'stack-bar-top', width: '100%' });
-                $.magnificPopup.close();
-                table.ajax.reload(null, false);
+                saveExchangeFees(function() {
+                    new PNotify({ title: 'Success', text: 'Exchange updated', type: 'success', addclass: 'stack-bar-top', width: '100%' });
+                    $.magnificPopup.close();
+                    table.ajax.reload(null, false);
+                });
             }, 'json');
         });
     }
 
+    function bindSyncInstruments() {
+        $('#btn-sync-instruments').on('click', function() {
+            const btn = $(this);
+            btn.prop('disabled', true);
+            $.ajax({
+                url: '/exchange_manage/ajax_sync_instruments',
+                type: 'POST',
+                dataType: 'json'
+            }).done(function(resp) {
+                const lines = (resp.results || []).map(function(r) {
+                    return r.exchange_name + ' ' + r.market + ': ' + r.count;
+                });
+                new PNotify({ title: 'Success', text: 'Instruments synced<br>' + lines.join('<br>'), type: 'success', addclass: 'stack-bar-top', width: '100%' });
+            }).fail(function(xhr) {
+                const resp = xhr.responseJSON || {};
+                new PNotify({ title: 'Error', text: resp.error || ('Error ' + xhr.status), type: 'error', addclass: 'stack-bar-top', width: '100%' });
+            }).always(function() {
+                btn.prop('disabled', false);
+            });
+        });
+    }
+
+    function bindFunding() {
+        $('#btn-sync-funding').on('click', function() {
+            const btn = $(this);
+            btn.prop('disabled', true);
+            $.ajax({
+                url: '/exchange_manage/ajax_sync_funding',
+                type: 'POST',
+                dataType: 'json'
+            }).done(function(resp) {
+                new PNotify({ title: 'Success', text: 'Funding rates synced: ' + resp.count, type: 'success', addclass: 'stack-bar-top', width: '100%' });
+            }).fail(function(xhr) {
+                const resp = xhr.responseJSON || {};
+                new PNotify({ title: 'Error', text: resp.error || ('Error ' + xhr.status), type: 'error', addclass: 'stack-bar-top', width: '100%' });
+            }).always(function() {
+                btn.prop('disabled', false);
+            });
+        });
+
+        $('#btn-import-funding').on('click', function() {
+            const file = $('#funding_import_file')[0].files[0];
+            if (!file) {
+                new PNotify({ title: 'Error', text: 'Choose a CSV file', type: 'error', addclass: 'stack-bar-top', width: '100%' });
+                return;
+            }
+            const formData = new FormData();
+            formData.append('exchange_id', $('#fee_exchange_id').val());
+            formData.append('file', file);
+            $.ajax({
+                url: '/exchange_manage/ajax_import_funding',
+                type: 'POST',
+                data: formData,
+                processData: false,
+                contentType: false,
+                dataType: 'json'
+            }).done(function(resp) {
+                $('#form-import-funding')[0].reset();
+                new PNotify({ title: 'Success', text: 'Funding rates imported: ' + resp.count, type: 'success', addclass: 'stack-bar-top', width: '100%' });
+            }).fail(function(xhr) {
+                const resp = xhr.responseJSON || {};
+                new PNotify({ title: 'Error', text: resp.error || ('Error ' + xhr.status), type: 'error', addclass: 'stack-bar-top', width: '100%' });
+            });
+        });
+    }
+
     $(function() {
         initTable();
         bindCreate();
         bindEdit();
+        bindSyncInstruments();
+        bindAccountFees();
+        bindFunding();
     });
 })();
 
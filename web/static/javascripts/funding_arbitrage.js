@@ -0,0 +1,129 @@
+$(document).ready(function() {
+    var opportunities = [];
+
+    function pct(value, digits) {
+        return (value * 100).toFixed(digits == null ? 4 : digits) + '%';
+    }
+
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
+    function post(url, params, onSuccess, onComplete) {
+        $.post(url, params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            onSuccess(ret);
+        }, 'json').fail(requestError).always(function() {
+            if(onComplete) {
+                onComplete();
+            }
+        });
+    }
+
+    function legHtml(leg) {
+        var html = ctEscapeHtml(leg.exchange) + ' <small class="text-muted">' + ctEscapeHtml(leg.market) + ' ' + ctEscapeHtml(leg.symbol) + '</small>';
+        if(leg.market == 'FUTURES') {
+            // Ставка биржи за её интервал и время следующего расчёта
+            html += '<br><small title="Next funding ' + ctEscapeHtml(leg.next_funding) + '">' +
+                pct(leg.rate) + ' / ' + leg.interval + 'h</small>';
+        }
+        return html;
+    }
+
+    function renderOpportunities() {
+        var tbody = $('#table-funding-opportunities tbody').empty();
+        if(!opportunities.length) {
+            tbody.append('<tr><td colspan="10" class="text-muted">No opportunities with a positive funding differential</td></tr>');
+            return;
+        }
+        opportunities.forEach(function(o, i) {
+            var netClass = o.net > 0 ? 'text-success' : 'text-danger';
+            var breakEven = o.break_even_hours > 0 ? o.break_even_hours.toFixed(1) + 'h' : '-';
+            tbody.append('<tr data-index="' + i + '">' +
+                '<td>' + ctEscapeHtml(o.pair) + '</td>' +
+                '<td>' + ctEscapeHtml(o.strategy) + '</td>' +
+                '<td>' + legHtml(o.long) + '</td>' +
+                '<td>' + legHtml(o.short) + '</td>' +
+                '<td>' + pct(o.differential) + '</td>' +
+                '<td>' + o.apr.toFixed(2) + '%</td>' +
+                '<td>' + pct(o.fees, 3) + '</td>' +
+                '<td class="' + netClass + '">' + pct(o.net, 3) + '</td>' +
+                '<td>' + breakEven + '</td>' +
+                '<td><a href="#" class="funding-open-pair" title="Open position group"><i class="fa fa-plus-square"></i></a></td>' +
+            '</tr>');
+        });
+    }
+
+    var refreshLongAccount = ctBindAccountSelect('#pair_long_account', '#pair_long_exchange');
+    var refreshShortAccount = ctBindAccountSelect('#pair_short_account', '#pair_short_exchange');
+
+    $('#form_funding_scan').on('submit', function(e) {
+        e.preventDefault();
+        var button = $('#funding_scan_button').prop('disabled', true);
+        $('#funding_scan_info').text('Scanning...');
+        post('/funding_arbitrage/ajax_funding_scan.php', $(this).serialize(), function(ret) {
+            opportunities = ret.data;
+            renderOpportunities();
+            var info = 'Scanned ' + ret.pairs + ' pairs on ' + ret.exchanges.join(', ') + ' at ' + ret.scanned_at;
+            if(ret.truncated) {
+                info += ' (pair limit reached, narrow the list to scan the rest)';
+            }
+            $('#funding_scan_info').text(info);
+            $('#funding_scan_errors').html(ret.errors.map(ctEscapeHtml).join('<br>'));
+        }, function() {
+            button.prop('disabled', false);
+        });
+    });
+
+    $('#table-funding-opportunities').on('click', 'a.funding-open-pair', function(e) {
+        e.preventDefault();
+        var o = opportunities[$(this).closest('tr').data('index')];
+        var form = $('#create-pair-form');
+        form.trigger('reset');
+        form.find('[name=strategy]').val(o.strategy);
+        form.find('[name=long_exchange_id]').val(o.long.exchange_id);
+        form.find('[name=long_symbol]').val(o.long.symbol);
+        form.find('[name=short_exchange_id]').val(o.short.exchange_id);
+        form.find('[name=short_symbol]').val(o.short.symbol);
+        refreshLongAccount();
+        refreshShortAccount();
+        $('#create_pair_title').text(o.strategy + ' ' + o.pair + ': long ' + o.long.exchange + ' ' + o.long.market +
+            ', short ' + o.short.exchange + ' ' + o.short.market);
+        $.magnificPopup.open({
+            items: { src: '#modalForm-create-pair' },
+            type: 'inline',
+            preloader: false,
+            modal: true
+        });
+    });
+
+    $('#create_pair_button').on('click', function(e) {
+        e.preventDefault();
+        post('/funding_arbitrage/ajax_create_pair.php', $('#create-pair-form').serialize(), function(ret) {
+            $.magnificPopup.close();
+            new PNotify({
+                    text: 'Positions #' + ret.long_position_id + ' and #' + ret.short_position_id +
+                        ' created in a group. See <a href="/positions_calc/">Positions</a>.',
+                    type: 'success',
+                    addclass: 'stack-bar-top',
+                    width: "100%"
+            });
+        });
+    });
+});
@@ -60,6 +60,7 @@ $(document).ready(function() {
             $('#fee_1_taker').text('');
             $('#fee_2_maker').text('');
             $('#fee_2_taker').text('');
+            $('#spread_info').text('');
         }
         else {
             $('#exchange_2 option').each(function() {
@@ -73,6 +74,7 @@ $(document).ready(function() {
             $('#fee_1_taker').text('');
             $('#fee_2_maker').text('');
             $('#fee_2_taker').text('');
+            $('#spread_info').text('');
         }
     });
     $('#exchange_2').on('change', function(e) {
@@ -138,6 +140,7 @@ $(document).ready(function() {
             $('#fee_1_taker').text('');
             $('#fee_2_maker').text('');
             $('#fee_2_taker').text('');
+            $('#spread_info').text('');
         }
         else {
             $('#trade_list option').each(function() {
@@ -147,6 +150,7 @@ $(document).ready(function() {
             $('#fee_1_taker').text('');
             $('#fee_2_maker').text('');
             $('#fee_2_taker').text('');
+            $('#spread_info').text('');
         }
     });
 
@@ -202,6 +206,21 @@ $(document).ready(function() {
                                  $('#fee_2_maker').text(ret.fee.ex2.maker_fee*100+'%');
                                  $('#fee_2_taker').text(ret.fee.ex2.taker_fee*100+'%');
                             }
+                            // Текущий спред по стаканам: покупка по ask одной биржи, продажа по bid другой
+                            if(ret.spread) {
+                                var sp = ret.spread;
+                                var buyEx = $('#exchange_' + sp.buy_on + ' option:selected').text();
+                                var sellEx = $('#exchange_' + (sp.buy_on === 1 ? 2 : 1) + ' option:selected').text();
+                                $('#spread_info').removeClass('text-danger text-success text-muted')
+                                    .addClass(sp.net > 0 ? 'text-success' : 'text-muted')
+                                    .text('Buy on ' + buyEx + ' at ' + sp.buy_price + ', sell on ' + sellEx + ' at ' + sp.sell_price +
+                                          ': spread ' + (sp.gross*100).toFixed(4) + '%, fees ' + (sp.fees*100).toFixed(4) +
+                                          '%, net edge ' + (sp.net*100).toFixed(4) + '%');
+                            }
+                            else {
+                                $('#spread_info').removeClass('text-success text-muted').addClass('text-danger')
+                                    .text(ret.spread_error ? 'Current spread is unavailable: ' + ret.spread_error : '');
+                            }
                             am5.array.each(am5.registry.rootElements, function(root) {
                                 root.dispose();
                             });
@@ -720,7 +739,6 @@ $(document).ready(function() {
     if(dtmaexs) {
         //Insert into header table input field for search
         var nm = Array(
-                "",
                 "exs_time", 
                 "exs_sell",
                 "exm_buy",  
@@ -733,7 +751,7 @@ $(document).ready(function() {
              );
         $('#dt-direct_exs thead tr th').each(function (i) {
             var title = $(this).text();
-            $(this).html(title+' <input type="text" name="'+nm[i]+'@'+i+'" class="form-control input-sm mb-md input-search" placeholder="" style="padding:1px" onclick="event.stopPropagation();" onkeypress="event.stopPropagation();keysearchUser(event)" />');
+            $(this).html(title+' <input type="text" name="'+nm[i]+'@'+i+'" class="form-control input-sm mb-md input-search" placeholder="" style="padding:1px" onclick="event.stopPropagation();" onkeypress="event.stopPropagation();keysearchDirectExs(event)" />');
         });
         	
         $.fn.dataTable.ext.errMode = 'throw';
@@ -828,3 +846,13 @@ $(document).ready(function() {
     }
 
  });
+
+function keysearchDirectExs(event) {
+    if (event.keyCode === 13) {
+        var table = $('#dt-direct_exs').DataTable();
+        var input = event.target;
+        var col_index = input.name.match(/\d+$/)[0];
+
+        table.columns(col_index).search(input.value).draw();
+    }
+}
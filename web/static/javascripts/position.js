@@ -10,6 +10,14 @@ $(document).ready(function() {
   }
     
     getPosition(position_id);
+
+    $('.trans-export').on('click', function(e) {
+        e.preventDefault();
+        window.location = '/positions_calc/position/export_trans.php?' + $.param({
+            position_id: position_id,
+            format: $(this).data('format')
+        });
+    });
     
     var dttrans = document.getElementById('dt-trans');
     if(dttrans) {
@@ -272,6 +280,7 @@ function getPosition(position_id) {
                     $('#p_contract_name').text(ret.CONTRACT_NAME);
                     $('#import_trans_csv_contract_name').val(ret.CONTRACT_NAME);
                     $('#p_exchange_name').text(ret.EXCHANGE_NAME);
+                    $('#p_account_name').text(ret.ACCOUNT_NAME || '—').attr('data-account-id', ret.ACCOUNT_ID || '');
                     $('#p_market').text(ret.MARKET_TYPE);
                     $('#p_status').text(ret.STATUS);
                     $('#p_date_open').text(formatDateTimeNoMillis(ret.OPENED));
@@ -282,9 +291,14 @@ function getPosition(position_id) {
                     $('#p_fee_base_curr').text(formatDisplayNumber(ret.FEE_BASE_CURR, 8));
                     $('#p_fee_quote_curr').text(formatDisplayNumber(ret.FEE_QUOTE_CURR, 8));
                     $('#p_funding').text(formatDisplayNumber(ret.FUNDING, 8));
+                    $('#p_break_even').text(ret.BREAK_EVEN_PRICE ? formatDisplayNumber(ret.BREAK_EVEN_PRICE, 8) : '—');
+                    $('#p_exit_fee').text(ret.EXIT_FEE_ESTIMATE ? formatDisplayNumber(ret.EXIT_FEE_ESTIMATE, 8) + ' (' + ret.TAKER_FEE + '%)' : '—');
                     $('#p_total_realized_pnl').text(formatDisplayNumber(ret.TOTAL_REALIZED_PNL, 8));
                     $('#p_trans_count').text(ret.TRANS_COUNT);
                     
+                    loadFundingInfo(position_id, ret);
+                    loadPriceChart(position_id);
+
                     if(ret.STATUS == 'OPEN') {
                         document.getElementById('close-pos-btn').style.setProperty('display','inline');
                     }
@@ -386,6 +400,177 @@ function getPosition(position_id) {
         });
     }
 }
+// Funding block for FUTURES positions: forecast of the next payment and missing settlements.
+function loadFundingInfo(position_id, position) {
+    if (position.MARKET_TYPE !== 'FUTURES') {
+        $('#funding-info').hide();
+        return;
+    }
+    $('#funding-info').show();
+
+    if (position.STATUS === 'OPEN') {
+        $.post('/positions_calc/position/ajax_funding_forecast.php', {position_id: position_id}, function(ret) {
+            if (!ret || ret.success !== true) {
+                $('#p_next_funding').text(ret && ret.error ? ret.error : '—');
+                $('#p_funding_rate, #p_funding_expected, #p_funding_annual').text('—');
+                return;
+            }
+            $('#p_next_funding').text(formatDateTimeNoMillis(ret.NEXT_FUNDING_TIME) + ' (every ' + trimTrailingZeros(ret.INTERVAL_HOURS.toFixed(2)) + 'h)');
+            $('#p_funding_rate').text(trimTrailingZeros(ret.FUNDING_RATE.toFixed(6)) + '%' + (ret.SOURCE === 'history' ? ' (last known)' : ''));
+            $('#p_funding_expected').text((ret.EXPECTED_PAYMENT > 0 ? '+' : '') + formatDisplayNumber(ret.EXPECTED_PAYMENT, 8))
+                .css('color', ret.EXPECTED_PAYMENT < 0 ? 'red' : 'green');
+            $('#p_funding_annual').text(trimTrailingZeros(ret.ANNUALIZED_COST_PCT.toFixed(2)) + '%')
+                .css('color', ret.ANNUALIZED_COST_PCT > 0 ? 'red' : 'green');
+        }, 'json');
+    } else {
+        $('#p_next_funding, #p_funding_rate, #p_funding_expected, #p_funding_annual').text('—');
+    }
+
+    $.post('/positions_calc/position/ajax_funding_gaps.php', {position_id: position_id}, function(ret) {
+        var $list = $('#p_funding_gaps_list').empty();
+        if (!ret || ret.success !== true) {
+            $('#p_funding_gaps').text('—');
+            return;
+        }
+        var label = ret.GAPS_COUNT === 0 ? 'none' : ret.GAPS_COUNT + ' settlement(s)';
+        if (ret.SOURCE === 'schedule') {
+            label += ' (8h schedule, no rate history)';
+        }
+        $('#p_funding_gaps').text(label).css('color', ret.GAPS_COUNT > 0 ? 'red' : 'black');
+        $.each(ret.GAPS, function(i, gap) {
+            $list.append($('<li>').text(formatDateTimeNoMillis(gap)));
+        });
+    }, 'json');
+}
+
+// Price chart: candles for the position period, trade markers and the running average price.
+function loadPriceChart(position_id) {
+    $.post('/positions_calc/position/ajax_get_chart.php', {position_id: position_id}, function(ret) {
+        var $chart = $('#position-chart');
+        if (!ret || ret.success !== true) {
+            $chart.empty().append($('<p class="text-muted">').text(ret && ret.error ? ret.error : 'Chart is unavailable'));
+            $('#chart-info').text('');
+            return;
+        }
+        var info = ret.SYMBOL + ' · ' + ret.INTERVAL;
+        if (ret.SOURCE === 'quotes') {
+            info += ' · stored quotes';
+        } else if (ret.SOURCE === 'exchange') {
+            info += ' · exchange API';
+        }
+        if (ret.CANDLE_ERROR) {
+            info += ' · candles: ' + ret.CANDLE_ERROR;
+        }
+        $('#chart-info').text(info);
+        renderPriceChart($chart.get(0), ret);
+    }, 'json');
+}
+
+function formatChartTime(ms) {
+    var d = new Date(ms);
+    function two(n) { return (n < 10 ? '0' : '') + n; }
+    return d.getFullYear() + '-' + two(d.getMonth() + 1) + '-' + two(d.getDate()) + ' ' + two(d.getHours()) + ':' + two(d.getMinutes());
+}
+
+function renderPriceChart(container, data) {
+    var ns = 'http://www.w3.org/2000/svg';
+    var width = container.clientWidth || 800;
+    var height = container.clientHeight || 360;
+    var pad = {left: 10, right: 80, top: 10, bottom: 24};
+    var plotW = width - pad.left - pad.right;
+    var plotH = height - pad.top - pad.bottom;
+
+    var candles = data.CANDLES || [];
+    var markers = data.MARKERS || [];
+    var avgLine = data.AVG_LINE || [];
+
+    var lo = Infinity, hi = -Infinity;
+    candles.forEach(function(c) { lo = Math.min(lo, c.l); hi = Math.max(hi, c.h); });
+    markers.forEach(function(m) { lo = Math.min(lo, m.price); hi = Math.max(hi, m.price); });
+    avgLine.forEach(function(p) { if (p.v !== null) { lo = Math.min(lo, p.v); hi = Math.max(hi, p.v); } });
+    if (!isFinite(lo) || !isFinite(hi)) {
+        $(container).empty().append($('<p class="text-muted">').text('No price data for this period'));
+        return;
+    }
+    if (hi === lo) {
+        hi += hi * 0.01 || 1;
+        lo -= lo * 0.01 || 1;
+    }
+    var margin = (hi - lo) * 0.05;
+    hi += margin;
+    lo -= margin;
+
+    var t0 = data.FROM, t1 = data.TO;
+    function x(t) { return pad.left + (t - t0) / (t1 - t0) * plotW; }
+    function y(p) { return pad.top + (hi - p) / (hi - lo) * plotH; }
+    function el(name, attrs, parent) {
+        var node = document.createElementNS(ns, name);
+        for (var key in attrs) {
+            node.setAttribute(key, attrs[key]);
+        }
+        parent.appendChild(node);
+        return node;
+    }
+
+    var svg = document.createElementNS(ns, 'svg');
+    svg.setAttribute('width', width);
+    svg.setAttribute('height', height);
+
+    // Price grid and axis labels
+    for (var i = 0; i <= 4; i++) {
+        var price = lo + (hi - lo) * i / 4;
+        el('line', {x1: pad.left, x2: pad.left + plotW, y1: y(price), y2: y(price), stroke: '#eee'}, svg);
+        el('text', {x: pad.left + plotW + 6, y: y(price) + 4, 'font-size': 11, fill: '#777'}, svg).textContent = formatAdaptivePrice(price);
+    }
+    for (var j = 0; j <= 4; j++) {
+        var t = t0 + (t1 - t0) * j / 4;
+        el('text', {x: x(t), y: height - 6, 'font-size': 11, fill: '#777', 'text-anchor': j === 0 ? 'start' : (j === 4 ? 'end' : 'middle')}, svg)
+            .textContent = formatChartTime(t);
+    }
+
+    // Candles
+    var bodyW = Math.max(1, Math.min(12, plotW / Math.max(candles.length, 1) * 0.7));
+    candles.forEach(function(c) {
+        var color = c.c >= c.o ? '#26a69a' : '#ef5350';
+        var cx = x(c.t);
+        el('line', {x1: cx, x2: cx, y1: y(c.h), y2: y(c.l), stroke: color}, svg);
+        el('rect', {x: cx - bodyW / 2, y: y(Math.max(c.o, c.c)), width: bodyW, height: Math.max(1, Math.abs(y(c.o) - y(c.c))), fill: color}, svg);
+    });
+
+    // Running average price as a step line; gaps where the position is flat
+    var path = '';
+    for (var k = 0; k < avgLine.length; k++) {
+        var point = avgLine[k];
+        if (point.v === null) {
+            continue;
+        }
+        var endT = k + 1 < avgLine.length ? avgLine[k + 1].t : t1;
+        path += 'M' + x(point.t) + ' ' + y(point.v) + ' H' + x(endT) + ' ';
+    }
+    if (path) {
+        el('path', {d: path, stroke: '#ef6c00', 'stroke-width': 2, fill: 'none'}, svg);
+    }
+
+    // Trade markers: triangles up for buys, down for sells; exits are hollow
+    markers.forEach(function(m) {
+        var mx = x(m.t), my = y(m.price), s = 6;
+        var color = m.side === 'buy' ? '#2e7d32' : '#c62828';
+        var points = m.side === 'buy'
+            ? [mx, my - s, mx - s, my + s, mx + s, my + s]
+            : [mx, my + s, mx - s, my - s, mx + s, my - s];
+        var marker = el('polygon', {
+            points: points.join(' '),
+            fill: m.kind === 'entry' ? color : '#fff',
+            stroke: color,
+            'stroke-width': 1.5
+        }, svg);
+        el('title', {}, marker).textContent = '#' + m.id + ' ' + m.side + ' ' + m.kind + ': '
+            + formatDisplayNumber(m.volume, 8) + ' @ ' + formatAdaptivePrice(m.price);
+    });
+
+    $(container).empty().append(svg);
+}
+
 //Обработка каждого выделения или снятия выделения строки (checkbox) для подсчета Сумма удержания с ТСП RUB
 $('#dt-trans tbody').on('change', 'input[type="checkbox"]', function () {
     calcAVGPriceChecked();   
@@ -662,6 +847,9 @@ $('.modal-with-form[href="#modalForm-edit-position"]').on('click', function(e) {
     // Populate the form fields
     $('#edit_position_name_contract').val(contractName);
     $("#edit_position_exchange option:contains("+exchangeName+")").attr('selected', true);
+    refreshEditAccount();
+    $('#edit_position_account').val($('#p_account_name').attr('data-account-id') || '');
+    refreshEditAccount();
     $('#edit_position_date_start').val(dateStart);
     
     // Open the modal
@@ -685,6 +873,14 @@ $('.modal-with-form[href="#modalForm-edit-position"]').on('click', function(e) {
     });
 });
 
+// Account list follows the selected exchange
+var refreshEditAccount = ctBindAccountSelect('#edit_position_account', '#edit_position_exchange');
+
+// Contract autocomplete from the exchange instrument catalog
+ctBindInstrumentAutocomplete('#edit_position_name_contract', '#edit_position_contract_list',
+    function() { return $('#edit_position_exchange').val(); },
+    function() { return $('#p_market').text().trim(); });
+
 // Edit Position Submit
 $('#edit_position_button').on('click', function(e) {
     e.preventDefault();
@@ -705,6 +901,7 @@ $('#edit_position_button').on('click', function(e) {
             position_id: position_id,
             name_contract: contractName,
             exchange_id: exchangeId,
+            account_id: $('#edit_position_account').val(),
             date_start: dateStart
         };
 
@@ -1383,6 +1580,8 @@ function updateTransactionAddFields() {
         $('#add_trans_price').prop('disabled', false);
         $('#div_add_trans_volume').css("display","block");
         $('#add_trans_volume').prop('disabled', false);
+        $('#div_add_trans_liquidity').css("display","block");
+        $('#add_trans_liquidity').prop('disabled', false);
         if(market == 'FUTURES') {
             $('#div_add_trans_fee_quote').css("display","block");
             $('#add_trans_fee_quote').prop('disabled', false);
@@ -1400,6 +1599,8 @@ function updateTransactionAddFields() {
         $('#add_trans_price').prop('disabled', true);
         $('#div_add_trans_volume').css("display","none");
         $('#add_trans_volume').prop('disabled', true);
+        $('#div_add_trans_liquidity').css("display","none");
+        $('#add_trans_liquidity').prop('disabled', true);
         $('#div_add_trans_fee_quote').css("display","none");
         $('#add_trans_fee_quote').prop('disabled', true);
         $('#div_add_trans_fee_base').css("display","none");
@@ -1407,6 +1608,44 @@ function updateTransactionAddFields() {
     }
 }
 
+// Pre-fill fee from the exchange/account fee schedule.
+// A fee typed by the user is kept; only empty or previously auto-filled values are replaced.
+function prefillTransactionFee() {
+    var action = $("#add_trans_action option:selected").val();
+    var price = $.trim($('#add_trans_price').val());
+    var volume = $.trim($('#add_trans_volume').val());
+    if (!action || !price || !volume) {
+        return;
+    }
+    var params = new URLSearchParams(window.location.search);
+    $.post('/positions_calc/position/ajax_get_fee.php', {
+        position_id: parseInt(params.get("position")),
+        action: action,
+        price: price,
+        volume: volume,
+        liquidity: $('#add_trans_liquidity').val()
+    }, function(ret) {
+        if (!ret || ret.success !== true) {
+            return;
+        }
+        $.each([['#add_trans_fee_quote', ret.FEE_QUOTE], ['#add_trans_fee_base', ret.FEE_BASE]], function(i, pair) {
+            var $field = $(pair[0]);
+            if ($field.prop('disabled')) {
+                return;
+            }
+            if ($field.val() === '' || $field.data('autofilled')) {
+                $field.val(pair[1]).data('autofilled', true);
+            }
+        });
+    }, 'json');
+}
+
+$('#add_trans_price, #add_trans_volume').on('change', prefillTransactionFee);
+$('#add_trans_action, #add_trans_liquidity').on('change', prefillTransactionFee);
+$('#add_trans_fee_quote, #add_trans_fee_base').on('input', function() {
+    $(this).data('autofilled', false);
+});
+
 function updateTransactionEditFields() {
   var type = $("#edit_trans_type option:selected").val();
   if(type == 'funding') {
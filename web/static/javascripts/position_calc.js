@@ -95,12 +95,22 @@ $(document).ready(function() {
                 //{ "data":null, render:function(){return "<input type='checkbox' class='t-row chbx-ch' value=''/>";}}, //0
                 { "data": "POSITION_ID" },    //1             
                 { "data": "CONTRACT_NAME"},            //2
+                {
+                    "data": "GROUP_NAME", "defaultContent": "",  //2a
+                    "render": function(data, type, row) {
+                        if (type !== 'display' || !row.GROUP_ID) {
+                            return data || '';
+                        }
+                        return $('<span class="label label-info">').attr('title', 'Group #' + row.GROUP_ID).text(data)[0].outerHTML;
+                    }
+                },
                 { "data": "EXCHANGE_NAME"},   //3
-                { "data": "MARKET_TYPE"},     //4
-                { "data": "STATUS"},          //5
-                { "data": "FINAL_POSITION"},  //6
+                { "data": "ACCOUNT_NAME", "defaultContent": ""}, //4
+                { "data": "MARKET_TYPE"},     //5
+                { "data": "STATUS"},          //6
+                { "data": "FINAL_POSITION"},  //7
                 {
-                    "data": "FINAL_AVG_PRICE",   //7
+                    "data": "FINAL_AVG_PRICE",   //8
                     "render": function(data, type) {
                         if (type !== 'display') {
                             return data;
@@ -108,11 +118,11 @@ $(document).ready(function() {
                         return formatAdaptivePrice(data);
                     }
                 },
-                { "data": "FEE_BASE_TOTAL"},  //8
-                { "data": "FEE_TOTAL"},       //9
-                { "data": "FUNDING_TOTAL"},   //10
+                { "data": "FEE_BASE_TOTAL"},  //9
+                { "data": "FEE_TOTAL"},       //10
+                { "data": "FUNDING_TOTAL"},   //11
                 { 
-                    "data": "TOTAL_REALIZED_PNL",    //11
+                    "data": "TOTAL_REALIZED_PNL",    //12
                     "render": function(data, type, row) {
                         if (type === 'display') {
                             var value = parseFloat(data);
@@ -145,6 +155,7 @@ $(document).ready(function() {
                 "method": "POST",
                 "url": "/positions_calc/ajax_get_positions.php",
                 "data": function ( d ) {
+                    d.filter_account = $('#filter_account').val();
                     //d.filterMyInWork = $('#button-filter-my-in-work').val();
                     //d.filterMy = $('#button-filter-my').val();
                 },
@@ -180,6 +191,17 @@ $(document).ready(function() {
             "drawCallback": function( settings ) {
             },     
         } );
+        $('#filter_account').on('change', function() {
+            table.draw();
+        });
+        $('.positions-export').on('click', function(e) {
+            e.preventDefault();
+            window.location = '/positions_calc/export.php?' + $.param({
+                format: $(this).data('format'),
+                filter_account: $('#filter_account').val()
+            });
+        });
+
         //Hide field search
         var search = document.getElementById('dt-positions_filter');
         if (search) {
@@ -224,7 +246,245 @@ $(document).ready(function() {
         });*/
     }
         
+    /*
+    * 2. Reconciliation with exchange positions and balances
+    */
+    var reconcileReport = null;
+    var reconcileStatusClass = {
+        ok: 'label-success',
+        mismatch: 'label-danger',
+        missing: 'label-danger',
+        untracked: 'label-warning',
+        no_account: 'label-default',
+        error: 'label-default'
+    };
+
+    function renderReconciliation() {
+        var $body = $('#reconcile-table tbody').empty();
+        if (!reconcileReport) {
+            $('#reconcile-info').text('— not run yet');
+            return;
+        }
+        $('#reconcile-info').text('— ' + new Date(reconcileReport.run_at).toLocaleString() +
+            ', mismatches: ' + reconcileReport.mismatches);
+
+        var onlyMismatches = $('#reconcile_only_mismatches').is(':checked');
+        var shown = 0;
+        $.each(reconcileReport.items || [], function(i, item) {
+            if (onlyMismatches && item.status === 'ok') {
+                return;
+            }
+            var $ids = $('<td>');
+            $.each(item.position_ids || [], function(j, id) {
+                if (j > 0) {
+                    $ids.append(', ');
+                }
+                $ids.append($('<a>').attr('href', '/positions_calc/position/?position=' + parseInt(id)).text(id));
+            });
+            var $status = $('<span class="label">').addClass(reconcileStatusClass[item.status] || 'label-default').text(item.status);
+            $body.append(
+                $('<tr>')
+                    .toggleClass('danger', item.status === 'mismatch' || item.status === 'missing')
+                    .append($('<td>').text(item.exchange_name))
+                    .append($('<td>').text(item.account_name || (item.account_id ? '#' + item.account_id : 'all')))
+                    .append($('<td>').text(item.market_type))
+                    .append($('<td>').text(item.asset ? item.symbol + ' (' + item.asset + ')' : item.symbol))
+                    .append($ids)
+                    .append($('<td class="text-right">').text(formatAdaptivePrice(item.calc_size)))
+                    .append($('<td class="text-right">').text(formatAdaptivePrice(item.live_size)))
+                    .append($('<td class="text-right">').text(formatAdaptivePrice(item.calc_price)))
+                    .append($('<td class="text-right">').text(formatAdaptivePrice(item.live_price)))
+                    .append($('<td>').append($status).append(item.message ? $('<small>').text(' ' + item.message) : ''))
+            );
+            shown++;
+        });
+        if (shown === 0) {
+            $body.append($('<tr>').append($('<td colspan="10" class="text-center">').text(
+                onlyMismatches ? 'No mismatches' : 'No open positions or exchange accounts')));
+        }
+    }
+
+    function reconcileRequest(url, data) {
+        return $.ajax({url: url, type: 'POST', data: data || {}}).done(function(response) {
+            var ret = parseAjaxResponse(response);
+            if (ret.success !== true) {
+                new PNotify({title: 'Error', text: ret.error, type: 'error', addclass: 'stack-bar-top', width: "100%"});
+                return;
+            }
+            reconcileReport = ret.report;
+            renderReconciliation();
+        }).fail(function(data) {
+            if (data.status == 401) {
+                setTimeout(function(){ location.reload(); }, 800);
+            }
+            new PNotify({title: 'Error', text: "Error " + data.status + " " + data.statusText, type: 'error', addclass: 'stack-bar-top', width: "100%"});
+        });
+    }
+
+    if (document.getElementById('reconcile-table')) {
+        reconcileRequest('/positions_calc/ajax_get_reconciliation.php');
+
+        $('#reconcile_only_mismatches').on('change', renderReconciliation);
+        $('#reconcile_button').on('click', function(e) {
+            e.preventDefault();
+            var $button = $(this).prop('disabled', true);
+            reconcileRequest('/positions_calc/ajax_reconcile.php', {tolerance: $('#reconcile_tolerance').val()})
+                .always(function() { $button.prop('disabled', false); });
+        });
+    }
+
+    /*
+    * 3. Position groups (hedged legs) with combined exposure and PnL
+    */
+    var availableGroupPositions = [];
+
+    function groupNotifyError(text) {
+        new PNotify({title: 'Error', text: text, type: 'error', addclass: 'stack-bar-top', width: "100%"});
+    }
+
+    function groupRequest(url, data) {
+        return $.ajax({url: url, type: 'POST', data: data || {}}).then(function(response) {
+            var ret = parseAjaxResponse(response);
+            if (ret.success !== true) {
+                groupNotifyError(ret.error);
+                return $.Deferred().reject().promise();
+            }
+            return ret;
+        }, function(data) {
+            if (data.status == 401) {
+                setTimeout(function(){ location.reload(); }, 800);
+            }
+            groupNotifyError("Error " + data.status + " " + data.statusText);
+        });
+    }
+
+    function pnlCell(value) {
+        var $td = $('<td class="text-right">').text(value.toFixed(2));
+        if (value > 0) {
+            $td.css('color', 'green').text('+' + value.toFixed(2));
+        } else if (value < 0) {
+            $td.css('color', 'red');
+        }
+        return $td;
+    }
+
+    function renderGroups(groups) {
+        var $body = $('#groups-table tbody').empty();
+        if (!groups.length) {
+            $body.append($('<tr>').append($('<td colspan="10" class="text-center">').text('No position groups')));
+            return;
+        }
+        $.each(groups, function(i, g) {
+            var $legs = $('<td>');
+            $.each(g.legs, function(j, leg) {
+                if (j > 0) {
+                    $legs.append('<br>');
+                }
+                var side = leg.position > 0 ? 'long' : (leg.position < 0 ? 'short' : 'flat');
+                $legs.append($('<a>').attr('href', '/positions_calc/position/?position=' + parseInt(leg.position_id))
+                    .text('#' + leg.position_id + ' ' + leg.name))
+                    .append($('<small class="text-muted">').text(' ' + leg.exchange + ' ' + leg.market + ', ' + side +
+                        ' ' + formatAdaptivePrice(leg.position) + (leg.price > 0 ? ' @ ' + formatAdaptivePrice(leg.price) : '')));
+            });
+            var $exposure = $('<td class="text-right">').text(g.totals.net_exposure.toFixed(2))
+                .attr('title', 'Gross ' + g.totals.gross_exposure.toFixed(2));
+            var $unrealized = pnlCell(g.totals.unrealized_pnl);
+            if (g.totals.missing_prices > 0) {
+                $unrealized.append($('<small class="text-muted">').text(' (' + g.totals.missing_prices + ' w/o price)'));
+            }
+            var $status = $('<td>').append($('<span class="label">')
+                .addClass(g.status === 'OPEN' ? 'label-success' : 'label-default').text(g.status))
+                .append($('<br><small class="text-muted">').text(g.closed || g.created));
+            var $actions = $('<td class="actions">');
+            if (g.status === 'OPEN') {
+                $actions.append($('<a href="#" class="group-close" title="Close group with all legs"><i class="fa fa-check-square-o"></i></a> '));
+            }
+            $actions.append($('<a href="#" class="group-delete" title="Ungroup positions"><i class="fa fa-chain-broken"></i></a>'));
+            $body.append($('<tr>').attr('data-id', g.id)
+                .append($('<td>').text(g.name))
+                .append($('<td>').text(g.strategy || ''))
+                .append($legs)
+                .append($exposure)
+                .append(pnlCell(g.totals.realized_pnl))
+                .append($unrealized)
+                .append(pnlCell(g.totals.net_funding))
+                .append($('<td class="text-right">').text(g.totals.fees.toFixed(2)))
+                .append($status)
+                .append($actions));
+        });
+    }
+
+    function loadGroups() {
+        return groupRequest('/positions_calc/ajax_get_groups.php').done(function(ret) {
+            availableGroupPositions = ret.positions;
+            renderGroups(ret.data);
+        });
+    }
+
+    if (document.getElementById('groups-table')) {
+        loadGroups();
+
+        $('#create_group_open').on('click', function(e) {
+            e.preventDefault();
+            $('#create-group-form').trigger('reset');
+            var $list = $('#create_group_positions').empty();
+            if (!availableGroupPositions.length) {
+                $list.append($('<p class="text-muted">').text('No open positions outside groups'));
+            }
+            $.each(availableGroupPositions, function(i, p) {
+                $list.append($('<div class="checkbox">').append($('<label>')
+                    .append($('<input type="checkbox" name="position_ids[]">').val(p.id))
+                    .append(document.createTextNode(' #' + p.id + ' ' + p.name + ' (' + p.exchange + ', ' + p.market + ')'))));
+            });
+            $.magnificPopup.open({
+                items: { src: '#modalForm-create-group' },
+                type: 'inline',
+                preloader: false,
+                modal: true
+            });
+        });
+
+        $('#create_group_button').on('click', function(e) {
+            e.preventDefault();
+            groupRequest('/positions_calc/ajax_create_group.php', $('#create-group-form').serialize()).done(function(ret) {
+                $.magnificPopup.close();
+                new PNotify({text: 'Group #' + ret.group_id + ' created', type: 'success', addclass: 'stack-bar-top', width: "100%"});
+                loadGroups();
+                table.draw(false);
+            });
+        });
+
+        $('#groups-table').on('click', 'a.group-close', function(e) {
+            e.preventDefault();
+            if (!confirm('Close the group and all its positions?')) {
+                return;
+            }
+            groupRequest('/positions_calc/ajax_close_group.php', {group_id: $(this).closest('tr').data('id')}).done(function() {
+                loadGroups();
+                table.draw(false);
+            });
+        });
+
+        $('#groups-table').on('click', 'a.group-delete', function(e) {
+            e.preventDefault();
+            if (!confirm('Ungroup the positions? Positions will stay as they are.')) {
+                return;
+            }
+            groupRequest('/positions_calc/ajax_delete_group.php', {group_id: $(this).closest('tr').data('id')}).done(function() {
+                loadGroups();
+                table.draw(false);
+            });
+        });
+    }
+
     //Create Position
+    // Contract autocomplete from the exchange instrument catalog
+    ctBindInstrumentAutocomplete('#add_position_name_contract', '#add_position_contract_list',
+        function() { return $('#add_position_exchange').val(); },
+        function() { return $('#add_position_market').val(); });
+    // Account list follows the selected exchange
+    var refreshAddAccount = ctBindAccountSelect('#add_position_account', '#add_position_exchange');
+
     $('#add_position_button').on('click', function(e) {
         e.preventDefault();
         var isNotValid = false;
@@ -280,6 +540,7 @@ $(document).ready(function() {
                         //setTimeout(function(){ location.reload(); }, 2000);
                         //сброс полей формы
                         $("form#add-position-form").trigger('reset');
+                        refreshAddAccount();
                     }
                 },
                 error: function (data, textStatus) {
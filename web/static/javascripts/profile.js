@@ -0,0 +1,137 @@
+$(document).ready(function() {
+    var pollTimer = null;
+
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
+    function post(url, params, onSuccess) {
+        $.post(url, params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            onSuccess(ret);
+        }, 'json').fail(requestError);
+    }
+
+    function stopPolling() {
+        if(pollTimer) {
+            clearInterval(pollTimer);
+            pollTimer = null;
+        }
+    }
+
+    function loadTelegram() {
+        post('/profile/ajax_get_telegram.php', {}, function(ret) {
+            if(ret.linked) {
+                var who = ret.username ? '@' + ctEscapeHtml(ret.username) : 'a chat';
+                $('#telegram_status').removeClass('text-muted')
+                    .html('<i class="fa fa-check text-success"></i> Linked to ' + who + ' since ' + ctEscapeHtml(ret.date_link));
+                $('#telegram_code_box').hide();
+                $('#telegram_unlink_button').show();
+                $('#telegram_code_button').html('<i class="fa fa-link"></i> Link another chat');
+                stopPolling();
+            } else {
+                $('#telegram_status').addClass('text-muted').text('Telegram chat is not linked');
+                $('#telegram_unlink_button').hide();
+                $('#telegram_code_button').html('<i class="fa fa-link"></i> Get link code');
+            }
+        });
+    }
+
+    $('#telegram_code_button').on('click', function() {
+        post('/profile/ajax_create_telegram_code.php', {}, function(ret) {
+            $('#telegram_code_command').text('/start ' + ret.code);
+            $('#telegram_code_expire').text(ret.expire);
+            if(ret.link) {
+                $('#telegram_bot_link').attr('href', ret.link);
+            }
+            $('#telegram_code_box').show();
+            // Ждём, пока пользователь отправит код боту
+            stopPolling();
+            pollTimer = setInterval(loadTelegram, 5000);
+            setTimeout(stopPolling, 15 * 60 * 1000);
+        });
+    });
+
+    $('#telegram_unlink_button').on('click', function() {
+        if(!confirm('Unlink Telegram chat? Telegram alerts will stop being delivered.')) {
+            return;
+        }
+        post('/profile/ajax_delete_telegram_link.php', {}, loadTelegram);
+    });
+
+    if($('#telegram_status').length) {
+        loadTelegram();
+    }
+
+    function showBackupResult(ret) {
+        var r = ret.data;
+        var title = r.dry_run ? 'Dry run for ' + ctEscapeHtml(ret.target) + ': nothing was saved' : 'Restored under ' + ctEscapeHtml(ret.target);
+        var rows = [
+            ['Positions created', r.positions_created],
+            ['Positions already present', r.positions_matched],
+            ['Positions skipped', r.positions_skipped],
+            ['Groups created', r.groups_created],
+            ['Transactions created', r.transactions_created],
+            ['Duplicate transactions skipped', r.transactions_duplicate],
+            ['Alert rules created', r.alert_rules_created],
+            ['Alert rules skipped', r.alert_rules_skipped],
+            ['Report subscription restored', r.subscription_restored ? 'yes' : 'no']
+        ];
+        var html = '<div class="alert ' + (r.dry_run ? 'alert-info' : 'alert-success') + '">' + title + '</div>'
+            + '<table class="table table-condensed"><tbody>';
+        $.each(rows, function(i, row) {
+            html += '<tr><th style="width: 240px">' + row[0] + '</th><td>' + row[1] + '</td></tr>';
+        });
+        html += '</tbody></table>';
+        if(r.warnings && r.warnings.length) {
+            html += '<ul class="text-warning">';
+            $.each(r.warnings, function(i, w) {
+                html += '<li>' + ctEscapeHtml(w) + '</li>';
+            });
+            html += '</ul>';
+        }
+        $('#backup_result').html(html).show();
+    }
+
+    $('#form_import_backup').on('submit', function(e) {
+        e.preventDefault();
+        var form = this;
+        var dryRun = $(form).find('input[name="dry_run"]').is(':checked');
+        if(!dryRun && !confirm('Restore backup? Positions and transactions will be created.')) {
+            return;
+        }
+        var $button = $('#import_backup_button').prop('disabled', true);
+        $.ajax({
+            url: '/profile/ajax_import_backup.php',
+            type: 'POST',
+            data: new FormData(form),
+            processData: false,
+            contentType: false,
+            dataType: 'json'
+        }).done(function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            showBackupResult(ret);
+        }).fail(requestError).always(function() {
+            $button.prop('disabled', false);
+        });
+    });
+});
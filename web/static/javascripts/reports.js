@@ -0,0 +1,139 @@
+$(document).ready(function() {
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function notifySuccess(text) {
+        new PNotify({
+                title: 'Success',
+                text: text,
+                type: 'success'
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
+    function post(url, params, onSuccess) {
+        $.post(url, params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            onSuccess(ret);
+        }, 'json').fail(requestError);
+    }
+
+    function formatPnL(value) {
+        var cls = value > 0 ? 'text-success' : (value < 0 ? 'text-danger' : '');
+        var text = (value > 0 ? '+' : '') + Number(value).toFixed(2);
+        return '<span class="' + cls + '">' + text + '</span>';
+    }
+
+    function emailCell(r) {
+        if(r.date_emailed) {
+            return ctEscapeHtml(r.date_emailed) + '<br><small class="text-muted">' + ctEscapeHtml(r.email) + '</small>';
+        }
+        if(r.email_error) {
+            return '<span class="text-danger" title="' + ctEscapeHtml(r.email_error) + '"><i class="fa fa-exclamation-triangle"></i> failed</span>';
+        }
+        return '<span class="text-muted">-</span>';
+    }
+
+    function loadReports() {
+        post('/reports/ajax_get_reports.php', {}, function(ret) {
+            var $body = $('#table-reports tbody').empty();
+            if(!ret.data.length) {
+                $body.append('<tr><td colspan="7" class="text-center text-muted">No reports yet</td></tr>');
+                return;
+            }
+            $.each(ret.data, function(i, r) {
+                var dates = r.period == 'monthly' ? r.period_start.substr(0, 7) : r.period_start;
+                var download = '/reports/download.php?id=' + r.id;
+                $body.append('<tr>'
+                    + '<td>' + ctEscapeHtml(r.period) + '</td>'
+                    + '<td>' + ctEscapeHtml(dates) + '</td>'
+                    + '<td>' + ctEscapeHtml(r.timezone) + '</td>'
+                    + '<td class="text-right">' + formatPnL(r.realized_pnl) + '</td>'
+                    + '<td>' + ctEscapeHtml(r.date_create) + '</td>'
+                    + '<td>' + emailCell(r) + '</td>'
+                    + '<td class="text-nowrap">'
+                    + '<a class="btn btn-default btn-xs" href="' + download + '&format=html" target="_blank" rel="noopener" title="Open"><i class="fa fa-eye"></i></a> '
+                    + '<a class="btn btn-default btn-xs" href="' + download + '&format=csv" title="Download CSV"><i class="fa fa-download"></i></a> '
+                    + '<button type="button" class="btn btn-default btn-xs report-send" data-id="' + r.id + '" title="Send by email"><i class="fa fa-envelope-o"></i></button> '
+                    + '<button type="button" class="btn btn-danger btn-xs report-delete" data-id="' + r.id + '" title="Delete"><i class="fa fa-trash-o"></i></button>'
+                    + '</td>'
+                    + '</tr>');
+            });
+        });
+    }
+
+    $('#report_period').on('change', function() {
+        var monthly = $(this).val() == 'monthly';
+        $('#report_date').toggle(!monthly);
+        $('#report_month').toggle(monthly);
+    });
+
+    $('#form_subscription').on('submit', function(e) {
+        e.preventDefault();
+        post('/reports/ajax_save_subscription.php', {
+            daily: $('#report_daily').is(':checked') ? 1 : 0,
+            monthly: $('#report_monthly').is(':checked') ? 1 : 0,
+            email: $('#report_email').val()
+        }, function() {
+            notifySuccess('Subscription saved');
+        });
+    });
+
+    $('#form_create_report').on('submit', function(e) {
+        e.preventDefault();
+        var period = $('#report_period').val();
+        var $button = $(this).find('button[type=submit]').prop('disabled', true);
+        $.post('/reports/ajax_create_report.php', {
+            period: period,
+            date: period == 'monthly' ? $('#report_month').val() : $('#report_date').val()
+        }, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            loadReports();
+            window.open('/reports/download.php?id=' + ret.id + '&format=html', '_blank');
+        }, 'json').fail(requestError).always(function() {
+            $button.prop('disabled', false);
+        });
+    });
+
+    $('#table-reports').on('click', '.report-send', function() {
+        var $button = $(this).prop('disabled', true);
+        $.post('/reports/ajax_send_report.php', {id: $button.data('id')}, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+            } else {
+                notifySuccess('Report sent');
+            }
+            loadReports();
+        }, 'json').fail(requestError).always(function() {
+            $button.prop('disabled', false);
+        });
+    });
+
+    $('#table-reports').on('click', '.report-delete', function() {
+        if(!confirm('Delete this report?')) {
+            return;
+        }
+        post('/reports/ajax_delete_report.php', {id: $(this).data('id')}, loadReports);
+    });
+
+    loadReports();
+});
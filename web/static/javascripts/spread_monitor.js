@@ -0,0 +1,184 @@
+$(document).ready(function() {
+    var currentWatch = null;
+    var chartRoot = null;
+
+    function pct(value) {
+        return (value * 100).toFixed(4) + '%';
+    }
+
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
+    function post(url, params, onSuccess) {
+        $.post(url, params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            onSuccess(ret);
+        }, 'json').fail(requestError);
+    }
+
+    function loadWatches() {
+        post('/spread_monitor/ajax_get_watches.php', {}, function(ret) {
+            var tbody = $('#table-spread-watches tbody').empty();
+            if(!ret.data.length) {
+                tbody.append('<tr><td colspan="8" class="text-muted">No watches yet</td></tr>');
+                return;
+            }
+            ret.data.forEach(function(w) {
+                var status = w.is_active ? '<span class="text-success">active</span>' : '<span class="text-muted">paused</span>';
+                if(w.last_error) {
+                    status += ' <i class="fa fa-exclamation-triangle text-danger" title="' + ctEscapeHtml(w.last_error) + '"></i>';
+                }
+                tbody.append('<tr data-id="' + w.id + '" data-title="' + ctEscapeHtml(w.pair + ' ' + w.exchange1 + ' / ' + w.exchange2) + '">' +
+                    '<td>' + w.id + '</td>' +
+                    '<td><a href="#" class="spread-show">' + ctEscapeHtml(w.pair) + '</a></td>' +
+                    '<td>' + ctEscapeHtml(w.market) + '</td>' +
+                    '<td>' + ctEscapeHtml(w.exchange1) + ' <small class="text-muted">' + ctEscapeHtml(w.symbol1) + '</small></td>' +
+                    '<td>' + ctEscapeHtml(w.exchange2) + ' <small class="text-muted">' + ctEscapeHtml(w.symbol2) + '</small></td>' +
+                    '<td>' + ctEscapeHtml(w.last_sample) + '</td>' +
+                    '<td>' + status + '</td>' +
+                    '<td class="text-nowrap">' +
+                        '<a href="#" class="spread-toggle" data-active="' + (w.is_active ? 0 : 1) + '" title="' + (w.is_active ? 'Pause' : 'Resume') + '"><i class="fa ' + (w.is_active ? 'fa-pause' : 'fa-play') + '"></i></a> ' +
+                        '<a href="#" class="spread-delete" title="Delete"><i class="fa fa-trash-o"></i></a>' +
+                    '</td>' +
+                '</tr>');
+            });
+        });
+    }
+
+    function drawChart(points) {
+        if(chartRoot) {
+            chartRoot.dispose();
+        }
+        chartRoot = am5.Root.new("spread_chart");
+        chartRoot.setThemes([am5themes_Animated.new(chartRoot)]);
+
+        var chart = chartRoot.container.children.push(
+            am5xy.XYChart.new(chartRoot, { panX: true, wheelX: "panX", wheelY: "zoomX" })
+        );
+        chart.set("cursor", am5xy.XYCursor.new(chartRoot, { behavior: "zoomX" }));
+
+        var xAxis = chart.xAxes.push(
+            am5xy.DateAxis.new(chartRoot, {
+                baseInterval: { timeUnit: "second", count: 1 },
+                renderer: am5xy.AxisRendererX.new(chartRoot, {}),
+                tooltip: am5.Tooltip.new(chartRoot, {})
+            })
+        );
+        var yAxis = chart.yAxes.push(
+            am5xy.ValueAxis.new(chartRoot, {
+                renderer: am5xy.AxisRendererY.new(chartRoot, {}),
+                numberFormat: "#.####'%'"
+            })
+        );
+
+        // Валовый и чистый (за вычетом taker-комиссий) спред в процентах
+        var data = points.map(function(p) {
+            return { date: p.time, gross: p.gross * 100, net: p.net * 100 };
+        });
+        [["gross", "Gross"], ["net", "Net of fees"]].forEach(function(field) {
+            var series = chart.series.push(
+                am5xy.LineSeries.new(chartRoot, {
+                    name: field[1],
+                    xAxis: xAxis,
+                    yAxis: yAxis,
+                    valueYField: field[0],
+                    valueXField: "date",
+                    tooltip: am5.Tooltip.new(chartRoot, {
+                        labelText: field[1] + ": {valueY.formatNumber('#.0000')}%"
+                    })
+                })
+            );
+            series.data.setAll(data);
+        });
+
+        chart.set("scrollbarX", am5.Scrollbar.new(chartRoot, { orientation: "horizontal" }));
+        chart.children.push(am5.Legend.new(chartRoot, {})).data.setAll(chart.series.values);
+    }
+
+    function statsRow(title, s) {
+        return '<tr><th>' + title + '</th><td>' + s.count + '</td>' +
+            '<td>' + pct(s.min) + '</td><td>' + pct(s.p5) + '</td><td>' + pct(s.p25) + '</td>' +
+            '<td>' + pct(s.p50) + '</td><td>' + pct(s.p75) + '</td><td>' + pct(s.p95) + '</td>' +
+            '<td>' + pct(s.max) + '</td><td>' + pct(s.mean) + '</td>' +
+            '<td>' + (s.positive * 100).toFixed(1) + '%</td></tr>';
+    }
+
+    function loadHistory() {
+        if(!currentWatch) {
+            return;
+        }
+        post('/spread_monitor/ajax_get_spread_stats.php', {'id': currentWatch, 'period': $('#spread_period').val()}, function(ret) {
+            $('#spread_history_panel').show();
+            drawChart(ret.data);
+            $('#table-spread-stats tbody').html(statsRow('Gross', ret.gross) + statsRow('Net of fees', ret.net));
+            if(ret.truncated) {
+                notifyError('Too many samples for this period, statistics are calculated for the first part only');
+            }
+        });
+    }
+
+    $('#form_create_watch').on('submit', function(e) {
+        e.preventDefault();
+        post('/spread_monitor/ajax_create_watch.php', $(this).serialize(), function(ret) {
+            new PNotify({
+                    text: 'Watch added',
+                    type: 'success',
+                    addclass: 'stack-bar-top',
+                    width: "100%"
+            });
+            $('#form_create_watch input[name=trade_pair]').val('');
+            loadWatches();
+        });
+    });
+
+    $('#table-spread-watches').on('click', 'a.spread-show', function(e) {
+        e.preventDefault();
+        var row = $(this).closest('tr');
+        currentWatch = row.data('id');
+        $('#spread_watch_title').text(row.data('title'));
+        loadHistory();
+    });
+
+    $('#table-spread-watches').on('click', 'a.spread-toggle', function(e) {
+        e.preventDefault();
+        var id = $(this).closest('tr').data('id');
+        post('/spread_monitor/ajax_edit_watch.php', {'id': id, 'active': $(this).data('active')}, loadWatches);
+    });
+
+    $('#table-spread-watches').on('click', 'a.spread-delete', function(e) {
+        e.preventDefault();
+        var id = $(this).closest('tr').data('id');
+        if(!confirm('Delete this watch?')) {
+            return;
+        }
+        post('/spread_monitor/ajax_delete_watch.php', {'id': id}, function() {
+            if(currentWatch == id) {
+                currentWatch = null;
+                $('#spread_history_panel').hide();
+            }
+            loadWatches();
+        });
+    });
+
+    $('#spread_period').on('change', loadHistory);
+
+    loadWatches();
+    setInterval(loadWatches, 60000);
+});
@@ -0,0 +1,151 @@
+$(document).ready(function() {
+    var isAdmin = $('#form_import_rates').length > 0;
+
+    function notifyError(text) {
+        new PNotify({
+                title: 'Error',
+                text: text,
+                type: 'error',
+                addclass: 'stack-bar-top',
+                width: "100%"
+        });
+    }
+
+    function notifySuccess(text) {
+        new PNotify({
+                title: 'Success',
+                text: text,
+                type: 'success'
+        });
+    }
+
+    function requestError(data, textStatus) {
+        if(data.status == 401) {
+            setTimeout(function(){ location.reload(); }, 1000);
+        }
+        notifyError("Error " + data.status + " " + data.statusText);
+    }
+
+    function post(url, params, onSuccess) {
+        $.post(url, params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            onSuccess(ret);
+        }, 'json').fail(requestError);
+    }
+
+    function formatMoney(value, currency) {
+        var cls = value > 0 ? 'text-success' : (value < 0 ? 'text-danger' : '');
+        return '<span class="' + cls + '">' + Number(value).toFixed(2) + ' ' + ctEscapeHtml(currency) + '</span>';
+    }
+
+    function exportParams() {
+        return {
+            year: $('#tax_year').val(),
+            currency: $.trim($('#tax_currency').val()).toUpperCase()
+        };
+    }
+
+    function loadSummary() {
+        var params = exportParams();
+        var $button = $('#tax_summary_button').prop('disabled', true);
+        $.post('/tax/ajax_get_tax_summary.php', params, function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            var s = ret.data;
+            var rows = [
+                ['Sales (disposals)', s.disposals],
+                ['Proceeds', formatMoney(s.proceeds, ret.currency)],
+                ['Cost basis', formatMoney(s.cost_basis, ret.currency)],
+                ['Gain or loss', formatMoney(s.gain, ret.currency)],
+                ['Short-term', formatMoney(s.short_term_gain, ret.currency)],
+                ['Long-term', formatMoney(s.long_term_gain, ret.currency)],
+                ['FUTURES realized PnL', formatMoney(s.futures_pnl, ret.currency)],
+                ['Funding', formatMoney(s.funding, ret.currency)]
+            ];
+            if(s.missing_basis > 0) {
+                rows.push(['Sales without purchase', '<span class="text-warning"><i class="fa fa-exclamation-triangle"></i> ' + s.missing_basis + ' (zero cost basis)</span>']);
+            }
+            var $body = $('#table-tax-summary tbody').empty();
+            $.each(rows, function(i, row) {
+                $body.append('<tr><th style="width: 200px">' + row[0] + '</th><td>' + row[1] + '</td></tr>');
+            });
+            $('#tax_summary_empty').hide();
+            $('#table-tax-summary').show();
+        }, 'json').fail(requestError).always(function() {
+            $button.prop('disabled', false);
+        });
+    }
+
+    function loadRates() {
+        post('/tax/ajax_get_rates.php', {}, function(ret) {
+            var $body = $('#table-fiat-rates tbody').empty();
+            if(!ret.data.length) {
+                $body.append('<tr><td colspan="' + (isAdmin ? 6 : 5) + '" class="text-center text-muted">No rates uploaded</td></tr>');
+                return;
+            }
+            $.each(ret.data, function(i, r) {
+                var actions = '';
+                if(isAdmin) {
+                    actions = '<td><button type="button" class="btn btn-danger btn-xs rates-delete" data-asset="' + ctEscapeHtml(r.asset)
+                        + '" data-currency="' + ctEscapeHtml(r.currency) + '" title="Delete"><i class="fa fa-trash-o"></i></button></td>';
+                }
+                $body.append('<tr>'
+                    + '<td>' + ctEscapeHtml(r.asset) + '</td>'
+                    + '<td>' + ctEscapeHtml(r.currency) + '</td>'
+                    + '<td>' + r.count + '</td>'
+                    + '<td>' + ctEscapeHtml(r.from) + '</td>'
+                    + '<td>' + ctEscapeHtml(r.to) + '</td>'
+                    + actions
+                    + '</tr>');
+            });
+        });
+    }
+
+    $('#tax_summary_button').on('click', loadSummary);
+
+    $('#form_tax_export').on('submit', function(e) {
+        e.preventDefault();
+        var params = exportParams();
+        params.format = $('#tax_format').val();
+        // Ошибку (например, нет курса) показываем до скачивания
+        post('/tax/ajax_get_tax_summary.php', {year: params.year, currency: params.currency}, function() {
+            window.location = '/tax/export.php?' + $.param(params);
+        });
+    });
+
+    $('#form_import_rates').on('submit', function(e) {
+        e.preventDefault();
+        var form = this;
+        $.ajax({
+            url: '/tax/ajax_import_rates.php',
+            type: 'POST',
+            data: new FormData(form),
+            processData: false,
+            contentType: false,
+            dataType: 'json'
+        }).done(function(ret) {
+            if(ret.error !== false && ret.error !== '') {
+                notifyError(ret.error);
+                return;
+            }
+            notifySuccess(ret.data + ' rates saved');
+            form.reset();
+            loadRates();
+        }).fail(requestError);
+    });
+
+    $('#table-fiat-rates').on('click', '.rates-delete', function() {
+        var asset = $(this).data('asset'), currency = $(this).data('currency');
+        if(!confirm('Delete all ' + asset + '/' + currency + ' rates?')) {
+            return;
+        }
+        post('/tax/ajax_delete_rates.php', {asset: asset, currency: currency}, loadRates);
+    });
+
+    loadRates();
+});